@@ -0,0 +1,9 @@
+/// Client for the RoxBox torrent streaming server.
+///
+/// [RoxboxApi] and the model classes are generated from the server's
+/// OpenAPI document (go_server/cmd/dartgen); [StatusListener] follows
+/// /status/ws and keeps reconnecting.
+library roxbox_client;
+
+export 'src/api.g.dart';
+export 'src/status_listener.dart';
@@ -0,0 +1,3216 @@
+// GENERATED CODE - DO NOT EDIT.
+// Produced by go_server/cmd/dartgen from the server's OpenAPI document;
+// regenerate with `go run . openapi | go run ./cmd/dartgen -out ...`.
+
+// ignore_for_file: unnecessary_cast, prefer_null_aware_operators
+
+import 'dart:convert';
+
+import 'package:http/http.dart' as http;
+
+class AddRequest {
+  const AddRequest({
+    this.episode,
+    this.fallbacks,
+    this.file,
+    this.fileIndex,
+    this.headBytes,
+    this.labels,
+    this.magnet,
+    this.paused,
+    this.peers,
+    this.policy,
+    this.sequential,
+    this.swarm,
+    this.tailBytes,
+    this.title,
+    this.trackers,
+    this.url,
+  });
+
+  final String? episode;
+  final List<String>? fallbacks;
+  final String? file;
+  final int? fileIndex;
+  final int? headBytes;
+  final Map<String, String>? labels;
+  final String? magnet;
+  final bool? paused;
+  final List<String>? peers;
+  final StreamPolicy? policy;
+  final bool? sequential;
+  final dynamic? swarm;
+  final int? tailBytes;
+  final String? title;
+  final List<String>? trackers;
+  final String? url;
+
+  factory AddRequest.fromJson(Map<String, dynamic> json) => AddRequest(
+        episode: json['episode'] == null ? null : json['episode'] as String,
+        fallbacks: json['fallbacks'] == null ? null : (json['fallbacks'] as List).map((e) => e as String).toList(),
+        file: json['file'] == null ? null : json['file'] as String,
+        fileIndex: json['file_index'] == null ? null : (json['file_index'] as num).toInt(),
+        headBytes: json['head_bytes'] == null ? null : (json['head_bytes'] as num).toInt(),
+        labels: json['labels'] == null ? null : (json['labels'] as Map).map((k, v) => MapEntry(k as String, v as String)),
+        magnet: json['magnet'] == null ? null : json['magnet'] as String,
+        paused: json['paused'] == null ? null : json['paused'] as bool,
+        peers: json['peers'] == null ? null : (json['peers'] as List).map((e) => e as String).toList(),
+        policy: json['policy'] == null ? null : StreamPolicy.fromJson(json['policy'] as Map<String, dynamic>),
+        sequential: json['sequential'] == null ? null : json['sequential'] as bool,
+        swarm: json['swarm'] == null ? null : json['swarm'],
+        tailBytes: json['tail_bytes'] == null ? null : (json['tail_bytes'] as num).toInt(),
+        title: json['title'] == null ? null : json['title'] as String,
+        trackers: json['trackers'] == null ? null : (json['trackers'] as List).map((e) => e as String).toList(),
+        url: json['url'] == null ? null : json['url'] as String,
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (episode != null) 'episode': episode,
+        if (fallbacks != null) 'fallbacks': fallbacks,
+        if (file != null) 'file': file,
+        if (fileIndex != null) 'file_index': fileIndex,
+        if (headBytes != null) 'head_bytes': headBytes,
+        if (labels != null) 'labels': labels,
+        if (magnet != null) 'magnet': magnet,
+        if (paused != null) 'paused': paused,
+        if (peers != null) 'peers': peers,
+        if (policy != null) 'policy': policy!.toJson(),
+        if (sequential != null) 'sequential': sequential,
+        if (swarm != null) 'swarm': swarm,
+        if (tailBytes != null) 'tail_bytes': tailBytes,
+        if (title != null) 'title': title,
+        if (trackers != null) 'trackers': trackers,
+        if (url != null) 'url': url,
+      };
+}
+
+class AddRequestJSON {
+  const AddRequestJSON({
+    this.authorization,
+    this.cookie,
+    this.episode,
+    this.fallbacks,
+    this.file,
+    this.fileIndex,
+    this.headBytes,
+    this.labels,
+    this.magnet,
+    this.paused,
+    this.peers,
+    this.policy,
+    this.sequential,
+    this.swarm,
+    this.tailBytes,
+    this.title,
+    this.trackers,
+    this.url,
+  });
+
+  final String? authorization;
+  final String? cookie;
+  final String? episode;
+  final List<String>? fallbacks;
+  final String? file;
+  final int? fileIndex;
+  final int? headBytes;
+  final Map<String, String>? labels;
+  final String? magnet;
+  final bool? paused;
+  final List<String>? peers;
+  final StreamPolicy? policy;
+  final bool? sequential;
+  final dynamic? swarm;
+  final int? tailBytes;
+  final String? title;
+  final List<String>? trackers;
+  final String? url;
+
+  factory AddRequestJSON.fromJson(Map<String, dynamic> json) => AddRequestJSON(
+        authorization: json['authorization'] == null ? null : json['authorization'] as String,
+        cookie: json['cookie'] == null ? null : json['cookie'] as String,
+        episode: json['episode'] == null ? null : json['episode'] as String,
+        fallbacks: json['fallbacks'] == null ? null : (json['fallbacks'] as List).map((e) => e as String).toList(),
+        file: json['file'] == null ? null : json['file'] as String,
+        fileIndex: json['file_index'] == null ? null : (json['file_index'] as num).toInt(),
+        headBytes: json['head_bytes'] == null ? null : (json['head_bytes'] as num).toInt(),
+        labels: json['labels'] == null ? null : (json['labels'] as Map).map((k, v) => MapEntry(k as String, v as String)),
+        magnet: json['magnet'] == null ? null : json['magnet'] as String,
+        paused: json['paused'] == null ? null : json['paused'] as bool,
+        peers: json['peers'] == null ? null : (json['peers'] as List).map((e) => e as String).toList(),
+        policy: json['policy'] == null ? null : StreamPolicy.fromJson(json['policy'] as Map<String, dynamic>),
+        sequential: json['sequential'] == null ? null : json['sequential'] as bool,
+        swarm: json['swarm'] == null ? null : json['swarm'],
+        tailBytes: json['tail_bytes'] == null ? null : (json['tail_bytes'] as num).toInt(),
+        title: json['title'] == null ? null : json['title'] as String,
+        trackers: json['trackers'] == null ? null : (json['trackers'] as List).map((e) => e as String).toList(),
+        url: json['url'] == null ? null : json['url'] as String,
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (authorization != null) 'authorization': authorization,
+        if (cookie != null) 'cookie': cookie,
+        if (episode != null) 'episode': episode,
+        if (fallbacks != null) 'fallbacks': fallbacks,
+        if (file != null) 'file': file,
+        if (fileIndex != null) 'file_index': fileIndex,
+        if (headBytes != null) 'head_bytes': headBytes,
+        if (labels != null) 'labels': labels,
+        if (magnet != null) 'magnet': magnet,
+        if (paused != null) 'paused': paused,
+        if (peers != null) 'peers': peers,
+        if (policy != null) 'policy': policy!.toJson(),
+        if (sequential != null) 'sequential': sequential,
+        if (swarm != null) 'swarm': swarm,
+        if (tailBytes != null) 'tail_bytes': tailBytes,
+        if (title != null) 'title': title,
+        if (trackers != null) 'trackers': trackers,
+        if (url != null) 'url': url,
+      };
+}
+
+class ApiToken {
+  const ApiToken({
+    this.created,
+    this.hash,
+    this.name,
+    this.scope,
+  });
+
+  final DateTime? created;
+  final String? hash;
+  final String? name;
+  final String? scope;
+
+  factory ApiToken.fromJson(Map<String, dynamic> json) => ApiToken(
+        created: json['created'] == null ? null : DateTime.parse(json['created'] as String),
+        hash: json['hash'] == null ? null : json['hash'] as String,
+        name: json['name'] == null ? null : json['name'] as String,
+        scope: json['scope'] == null ? null : json['scope'] as String,
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (created != null) 'created': created!.toIso8601String(),
+        if (hash != null) 'hash': hash,
+        if (name != null) 'name': name,
+        if (scope != null) 'scope': scope,
+      };
+}
+
+class Ban {
+  const Ban({
+    this.added,
+    this.expires,
+    this.ip,
+    this.reason,
+    this.source,
+  });
+
+  final DateTime? added;
+  final DateTime? expires;
+  final String? ip;
+  final String? reason;
+  final String? source;
+
+  factory Ban.fromJson(Map<String, dynamic> json) => Ban(
+        added: json['added'] == null ? null : DateTime.parse(json['added'] as String),
+        expires: json['expires'] == null ? null : DateTime.parse(json['expires'] as String),
+        ip: json['ip'] == null ? null : json['ip'] as String,
+        reason: json['reason'] == null ? null : json['reason'] as String,
+        source: json['source'] == null ? null : json['source'] as String,
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (added != null) 'added': added!.toIso8601String(),
+        if (expires != null) 'expires': expires!.toIso8601String(),
+        if (ip != null) 'ip': ip,
+        if (reason != null) 'reason': reason,
+        if (source != null) 'source': source,
+      };
+}
+
+class BanRequest {
+  const BanRequest({
+    this.duration,
+    this.ip,
+    this.reason,
+  });
+
+  final String? duration;
+  final String? ip;
+  final String? reason;
+
+  factory BanRequest.fromJson(Map<String, dynamic> json) => BanRequest(
+        duration: json['duration'] == null ? null : json['duration'] as String,
+        ip: json['ip'] == null ? null : json['ip'] as String,
+        reason: json['reason'] == null ? null : json['reason'] as String,
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (duration != null) 'duration': duration,
+        if (ip != null) 'ip': ip,
+        if (reason != null) 'reason': reason,
+      };
+}
+
+class BansState {
+  const BansState({
+    this.bans,
+  });
+
+  final List<Ban>? bans;
+
+  factory BansState.fromJson(Map<String, dynamic> json) => BansState(
+        bans: json['bans'] == null ? null : (json['bans'] as List).map((e) => Ban.fromJson(e as Map<String, dynamic>)).toList(),
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (bans != null) 'bans': bans!.map((e) => e.toJson()).toList(),
+      };
+}
+
+class CapabilitiesResponse {
+  const CapabilitiesResponse({
+    this.arch,
+    this.features,
+    this.instance,
+    this.os,
+    this.version,
+  });
+
+  final String? arch;
+  final Map<String, Capability>? features;
+  final String? instance;
+  final String? os;
+  final String? version;
+
+  factory CapabilitiesResponse.fromJson(Map<String, dynamic> json) => CapabilitiesResponse(
+        arch: json['arch'] == null ? null : json['arch'] as String,
+        features: json['features'] == null ? null : (json['features'] as Map).map((k, v) => MapEntry(k as String, Capability.fromJson(v as Map<String, dynamic>))),
+        instance: json['instance'] == null ? null : json['instance'] as String,
+        os: json['os'] == null ? null : json['os'] as String,
+        version: json['version'] == null ? null : json['version'] as String,
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (arch != null) 'arch': arch,
+        if (features != null) 'features': features!.map((k, v) => MapEntry(k, v.toJson())),
+        if (instance != null) 'instance': instance,
+        if (os != null) 'os': os,
+        if (version != null) 'version': version,
+      };
+}
+
+class Capability {
+  const Capability({
+    this.compiled,
+    this.detail,
+    this.enabled,
+  });
+
+  final bool? compiled;
+  final String? detail;
+  final bool? enabled;
+
+  factory Capability.fromJson(Map<String, dynamic> json) => Capability(
+        compiled: json['compiled'] == null ? null : json['compiled'] as bool,
+        detail: json['detail'] == null ? null : json['detail'] as String,
+        enabled: json['enabled'] == null ? null : json['enabled'] as bool,
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (compiled != null) 'compiled': compiled,
+        if (detail != null) 'detail': detail,
+        if (enabled != null) 'enabled': enabled,
+      };
+}
+
+class CodecWarning {
+  const CodecWarning({
+    this.codec,
+    this.kind,
+    this.message,
+  });
+
+  final String? codec;
+  final String? kind;
+  final String? message;
+
+  factory CodecWarning.fromJson(Map<String, dynamic> json) => CodecWarning(
+        codec: json['codec'] == null ? null : json['codec'] as String,
+        kind: json['kind'] == null ? null : json['kind'] as String,
+        message: json['message'] == null ? null : json['message'] as String,
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (codec != null) 'codec': codec,
+        if (kind != null) 'kind': kind,
+        if (message != null) 'message': message,
+      };
+}
+
+class DebugStats {
+  const DebugStats({
+    this.goroutines,
+    this.heapMb,
+    this.io,
+    this.pipeline,
+  });
+
+  final int? goroutines;
+  final double? heapMb;
+  final IOThrottleStats? io;
+  final PipelineStats? pipeline;
+
+  factory DebugStats.fromJson(Map<String, dynamic> json) => DebugStats(
+        goroutines: json['goroutines'] == null ? null : (json['goroutines'] as num).toInt(),
+        heapMb: json['heap_mb'] == null ? null : (json['heap_mb'] as num).toDouble(),
+        io: json['io'] == null ? null : IOThrottleStats.fromJson(json['io'] as Map<String, dynamic>),
+        pipeline: json['pipeline'] == null ? null : PipelineStats.fromJson(json['pipeline'] as Map<String, dynamic>),
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (goroutines != null) 'goroutines': goroutines,
+        if (heapMb != null) 'heap_mb': heapMb,
+        if (io != null) 'io': io!.toJson(),
+        if (pipeline != null) 'pipeline': pipeline!.toJson(),
+      };
+}
+
+class EmbeddedSubtitle {
+  const EmbeddedSubtitle({
+    this.codec,
+    this.default_,
+    this.forced,
+    this.format,
+    this.indexed,
+    this.lang,
+    this.name,
+    this.track,
+    this.url,
+    this.vttUrl,
+  });
+
+  final String? codec;
+  final bool? default_;
+  final bool? forced;
+  final String? format;
+  final bool? indexed;
+  final String? lang;
+  final String? name;
+  final int? track;
+  final String? url;
+  final String? vttUrl;
+
+  factory EmbeddedSubtitle.fromJson(Map<String, dynamic> json) => EmbeddedSubtitle(
+        codec: json['codec'] == null ? null : json['codec'] as String,
+        default_: json['default'] == null ? null : json['default'] as bool,
+        forced: json['forced'] == null ? null : json['forced'] as bool,
+        format: json['format'] == null ? null : json['format'] as String,
+        indexed: json['indexed'] == null ? null : json['indexed'] as bool,
+        lang: json['lang'] == null ? null : json['lang'] as String,
+        name: json['name'] == null ? null : json['name'] as String,
+        track: json['track'] == null ? null : (json['track'] as num).toInt(),
+        url: json['url'] == null ? null : json['url'] as String,
+        vttUrl: json['vtt_url'] == null ? null : json['vtt_url'] as String,
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (codec != null) 'codec': codec,
+        if (default_ != null) 'default': default_,
+        if (forced != null) 'forced': forced,
+        if (format != null) 'format': format,
+        if (indexed != null) 'indexed': indexed,
+        if (lang != null) 'lang': lang,
+        if (name != null) 'name': name,
+        if (track != null) 'track': track,
+        if (url != null) 'url': url,
+        if (vttUrl != null) 'vtt_url': vttUrl,
+      };
+}
+
+class EmbeddedSubtitlesResponse {
+  const EmbeddedSubtitlesResponse({
+    this.file,
+    this.note,
+    this.subtitles,
+  });
+
+  final String? file;
+  final String? note;
+  final List<EmbeddedSubtitle>? subtitles;
+
+  factory EmbeddedSubtitlesResponse.fromJson(Map<String, dynamic> json) => EmbeddedSubtitlesResponse(
+        file: json['file'] == null ? null : json['file'] as String,
+        note: json['note'] == null ? null : json['note'] as String,
+        subtitles: json['subtitles'] == null ? null : (json['subtitles'] as List).map((e) => EmbeddedSubtitle.fromJson(e as Map<String, dynamic>)).toList(),
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (file != null) 'file': file,
+        if (note != null) 'note': note,
+        if (subtitles != null) 'subtitles': subtitles!.map((e) => e.toJson()).toList(),
+      };
+}
+
+class Event {
+  const Event({
+    this.data,
+    this.kind,
+    this.message,
+    this.profile,
+    this.requestId,
+    this.seq,
+    this.time,
+  });
+
+  final Map<String, dynamic>? data;
+  final String? kind;
+  final String? message;
+  final String? profile;
+  final String? requestId;
+  final int? seq;
+  final DateTime? time;
+
+  factory Event.fromJson(Map<String, dynamic> json) => Event(
+        data: json['data'] == null ? null : (json['data'] as Map).map((k, v) => MapEntry(k as String, v)),
+        kind: json['kind'] == null ? null : json['kind'] as String,
+        message: json['message'] == null ? null : json['message'] as String,
+        profile: json['profile'] == null ? null : json['profile'] as String,
+        requestId: json['request_id'] == null ? null : json['request_id'] as String,
+        seq: json['seq'] == null ? null : (json['seq'] as num).toInt(),
+        time: json['time'] == null ? null : DateTime.parse(json['time'] as String),
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (data != null) 'data': data,
+        if (kind != null) 'kind': kind,
+        if (message != null) 'message': message,
+        if (profile != null) 'profile': profile,
+        if (requestId != null) 'request_id': requestId,
+        if (seq != null) 'seq': seq,
+        if (time != null) 'time': time!.toIso8601String(),
+      };
+}
+
+class ExportJob {
+  const ExportJob({
+    this.copied,
+    this.dest,
+    this.error,
+    this.file,
+    this.infoHash,
+    this.profile,
+    this.size,
+    this.state,
+  });
+
+  final int? copied;
+  final String? dest;
+  final String? error;
+  final String? file;
+  final String? infoHash;
+  final String? profile;
+  final int? size;
+  final String? state;
+
+  factory ExportJob.fromJson(Map<String, dynamic> json) => ExportJob(
+        copied: json['copied'] == null ? null : (json['copied'] as num).toInt(),
+        dest: json['dest'] == null ? null : json['dest'] as String,
+        error: json['error'] == null ? null : json['error'] as String,
+        file: json['file'] == null ? null : json['file'] as String,
+        infoHash: json['info_hash'] == null ? null : json['info_hash'] as String,
+        profile: json['profile'] == null ? null : json['profile'] as String,
+        size: json['size'] == null ? null : (json['size'] as num).toInt(),
+        state: json['state'] == null ? null : json['state'] as String,
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (copied != null) 'copied': copied,
+        if (dest != null) 'dest': dest,
+        if (error != null) 'error': error,
+        if (file != null) 'file': file,
+        if (infoHash != null) 'info_hash': infoHash,
+        if (profile != null) 'profile': profile,
+        if (size != null) 'size': size,
+        if (state != null) 'state': state,
+      };
+}
+
+class FallbackStatus {
+  const FallbackStatus({
+    this.active,
+    this.failed,
+    this.sources,
+  });
+
+  final int? active;
+  final List<String>? failed;
+  final int? sources;
+
+  factory FallbackStatus.fromJson(Map<String, dynamic> json) => FallbackStatus(
+        active: json['active'] == null ? null : (json['active'] as num).toInt(),
+        failed: json['failed'] == null ? null : (json['failed'] as List).map((e) => e as String).toList(),
+        sources: json['sources'] == null ? null : (json['sources'] as num).toInt(),
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (active != null) 'active': active,
+        if (failed != null) 'failed': failed,
+        if (sources != null) 'sources': sources,
+      };
+}
+
+class FileEntry {
+  const FileEntry({
+    this.index,
+    this.lang,
+    this.path,
+    this.progress,
+    this.role,
+    this.size,
+    this.type,
+    this.url,
+  });
+
+  final int? index;
+  final String? lang;
+  final String? path;
+  final double? progress;
+  final String? role;
+  final int? size;
+  final String? type;
+  final String? url;
+
+  factory FileEntry.fromJson(Map<String, dynamic> json) => FileEntry(
+        index: json['index'] == null ? null : (json['index'] as num).toInt(),
+        lang: json['lang'] == null ? null : json['lang'] as String,
+        path: json['path'] == null ? null : json['path'] as String,
+        progress: json['progress'] == null ? null : (json['progress'] as num).toDouble(),
+        role: json['role'] == null ? null : json['role'] as String,
+        size: json['size'] == null ? null : (json['size'] as num).toInt(),
+        type: json['type'] == null ? null : json['type'] as String,
+        url: json['url'] == null ? null : json['url'] as String,
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (index != null) 'index': index,
+        if (lang != null) 'lang': lang,
+        if (path != null) 'path': path,
+        if (progress != null) 'progress': progress,
+        if (role != null) 'role': role,
+        if (size != null) 'size': size,
+        if (type != null) 'type': type,
+        if (url != null) 'url': url,
+      };
+}
+
+class FilesResponse {
+  const FilesResponse({
+    this.files,
+  });
+
+  final List<FileEntry>? files;
+
+  factory FilesResponse.fromJson(Map<String, dynamic> json) => FilesResponse(
+        files: json['files'] == null ? null : (json['files'] as List).map((e) => FileEntry.fromJson(e as Map<String, dynamic>)).toList(),
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (files != null) 'files': files!.map((e) => e.toJson()).toList(),
+      };
+}
+
+class GlobalLimits {
+  const GlobalLimits({
+    this.maxActive,
+    this.maxConns,
+  });
+
+  final int? maxActive;
+  final int? maxConns;
+
+  factory GlobalLimits.fromJson(Map<String, dynamic> json) => GlobalLimits(
+        maxActive: json['max_active'] == null ? null : (json['max_active'] as num).toInt(),
+        maxConns: json['max_conns'] == null ? null : (json['max_conns'] as num).toInt(),
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (maxActive != null) 'max_active': maxActive,
+        if (maxConns != null) 'max_conns': maxConns,
+      };
+}
+
+class HandoffBundle {
+  const HandoffBundle({
+    this.created,
+    this.file,
+    this.format,
+    this.name,
+    this.playerState,
+    this.positionSec,
+    this.serverVersion,
+    this.swarm,
+  });
+
+  final DateTime? created;
+  final String? file;
+  final int? format;
+  final String? name;
+  final String? playerState;
+  final double? positionSec;
+  final String? serverVersion;
+  final SwarmSnapshot? swarm;
+
+  factory HandoffBundle.fromJson(Map<String, dynamic> json) => HandoffBundle(
+        created: json['created'] == null ? null : DateTime.parse(json['created'] as String),
+        file: json['file'] == null ? null : json['file'] as String,
+        format: json['format'] == null ? null : (json['format'] as num).toInt(),
+        name: json['name'] == null ? null : json['name'] as String,
+        playerState: json['player_state'] == null ? null : json['player_state'] as String,
+        positionSec: json['position_sec'] == null ? null : (json['position_sec'] as num).toDouble(),
+        serverVersion: json['server_version'] == null ? null : json['server_version'] as String,
+        swarm: json['swarm'] == null ? null : SwarmSnapshot.fromJson(json['swarm'] as Map<String, dynamic>),
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (created != null) 'created': created!.toIso8601String(),
+        if (file != null) 'file': file,
+        if (format != null) 'format': format,
+        if (name != null) 'name': name,
+        if (playerState != null) 'player_state': playerState,
+        if (positionSec != null) 'position_sec': positionSec,
+        if (serverVersion != null) 'server_version': serverVersion,
+        if (swarm != null) 'swarm': swarm!.toJson(),
+      };
+}
+
+class Hint {
+  const Hint({
+    this.episode,
+    this.file,
+    this.title,
+  });
+
+  final String? episode;
+  final String? file;
+  final String? title;
+
+  factory Hint.fromJson(Map<String, dynamic> json) => Hint(
+        episode: json['episode'] == null ? null : json['episode'] as String,
+        file: json['file'] == null ? null : json['file'] as String,
+        title: json['title'] == null ? null : json['title'] as String,
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (episode != null) 'episode': episode,
+        if (file != null) 'file': file,
+        if (title != null) 'title': title,
+      };
+}
+
+class HistoryEntry {
+  const HistoryEntry({
+    this.file,
+    this.infoHash,
+    this.name,
+    this.time,
+  });
+
+  final String? file;
+  final String? infoHash;
+  final String? name;
+  final DateTime? time;
+
+  factory HistoryEntry.fromJson(Map<String, dynamic> json) => HistoryEntry(
+        file: json['file'] == null ? null : json['file'] as String,
+        infoHash: json['info_hash'] == null ? null : json['info_hash'] as String,
+        name: json['name'] == null ? null : json['name'] as String,
+        time: json['time'] == null ? null : DateTime.parse(json['time'] as String),
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (file != null) 'file': file,
+        if (infoHash != null) 'info_hash': infoHash,
+        if (name != null) 'name': name,
+        if (time != null) 'time': time!.toIso8601String(),
+      };
+}
+
+class IOThrottleStats {
+  const IOThrottleStats({
+    this.hashedMb,
+    this.playing,
+    this.rateMbs,
+    this.throttledMb,
+    this.waitedSeconds,
+    this.windowPieces,
+  });
+
+  final double? hashedMb;
+  final bool? playing;
+  final double? rateMbs;
+  final double? throttledMb;
+  final double? waitedSeconds;
+  final int? windowPieces;
+
+  factory IOThrottleStats.fromJson(Map<String, dynamic> json) => IOThrottleStats(
+        hashedMb: json['hashed_mb'] == null ? null : (json['hashed_mb'] as num).toDouble(),
+        playing: json['playing'] == null ? null : json['playing'] as bool,
+        rateMbs: json['rate_mbs'] == null ? null : (json['rate_mbs'] as num).toDouble(),
+        throttledMb: json['throttled_mb'] == null ? null : (json['throttled_mb'] as num).toDouble(),
+        waitedSeconds: json['waited_seconds'] == null ? null : (json['waited_seconds'] as num).toDouble(),
+        windowPieces: json['window_pieces'] == null ? null : (json['window_pieces'] as num).toInt(),
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (hashedMb != null) 'hashed_mb': hashedMb,
+        if (playing != null) 'playing': playing,
+        if (rateMbs != null) 'rate_mbs': rateMbs,
+        if (throttledMb != null) 'throttled_mb': throttledMb,
+        if (waitedSeconds != null) 'waited_seconds': waitedSeconds,
+        if (windowPieces != null) 'window_pieces': windowPieces,
+      };
+}
+
+class InfoResponse {
+  const InfoResponse({
+    this.file,
+    this.fileSize,
+    this.infoHash,
+    this.name,
+    this.numPieces,
+    this.pieceClass,
+    this.pieceLength,
+    this.readahead,
+    this.selection,
+    this.warnings,
+  });
+
+  final String? file;
+  final int? fileSize;
+  final String? infoHash;
+  final String? name;
+  final int? numPieces;
+  final String? pieceClass;
+  final int? pieceLength;
+  final int? readahead;
+  final Selection? selection;
+  final List<String>? warnings;
+
+  factory InfoResponse.fromJson(Map<String, dynamic> json) => InfoResponse(
+        file: json['file'] == null ? null : json['file'] as String,
+        fileSize: json['file_size'] == null ? null : (json['file_size'] as num).toInt(),
+        infoHash: json['info_hash'] == null ? null : json['info_hash'] as String,
+        name: json['name'] == null ? null : json['name'] as String,
+        numPieces: json['num_pieces'] == null ? null : (json['num_pieces'] as num).toInt(),
+        pieceClass: json['piece_class'] == null ? null : json['piece_class'] as String,
+        pieceLength: json['piece_length'] == null ? null : (json['piece_length'] as num).toInt(),
+        readahead: json['readahead'] == null ? null : (json['readahead'] as num).toInt(),
+        selection: json['selection'] == null ? null : Selection.fromJson(json['selection'] as Map<String, dynamic>),
+        warnings: json['warnings'] == null ? null : (json['warnings'] as List).map((e) => e as String).toList(),
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (file != null) 'file': file,
+        if (fileSize != null) 'file_size': fileSize,
+        if (infoHash != null) 'info_hash': infoHash,
+        if (name != null) 'name': name,
+        if (numPieces != null) 'num_pieces': numPieces,
+        if (pieceClass != null) 'piece_class': pieceClass,
+        if (pieceLength != null) 'piece_length': pieceLength,
+        if (readahead != null) 'readahead': readahead,
+        if (selection != null) 'selection': selection!.toJson(),
+        if (warnings != null) 'warnings': warnings,
+      };
+}
+
+class InspectFile {
+  const InspectFile({
+    this.index,
+    this.path,
+    this.size,
+    this.video,
+  });
+
+  final int? index;
+  final String? path;
+  final int? size;
+  final bool? video;
+
+  factory InspectFile.fromJson(Map<String, dynamic> json) => InspectFile(
+        index: json['index'] == null ? null : (json['index'] as num).toInt(),
+        path: json['path'] == null ? null : json['path'] as String,
+        size: json['size'] == null ? null : (json['size'] as num).toInt(),
+        video: json['video'] == null ? null : json['video'] as bool,
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (index != null) 'index': index,
+        if (path != null) 'path': path,
+        if (size != null) 'size': size,
+        if (video != null) 'video': video,
+      };
+}
+
+class InspectResponse {
+  const InspectResponse({
+    this.files,
+    this.infoHash,
+    this.name,
+    this.numPieces,
+    this.pieceLength,
+    this.selected,
+    this.selection,
+    this.source,
+    this.totalSize,
+  });
+
+  final List<InspectFile>? files;
+  final String? infoHash;
+  final String? name;
+  final int? numPieces;
+  final int? pieceLength;
+  final int? selected;
+  final Selection? selection;
+  final String? source;
+  final int? totalSize;
+
+  factory InspectResponse.fromJson(Map<String, dynamic> json) => InspectResponse(
+        files: json['files'] == null ? null : (json['files'] as List).map((e) => InspectFile.fromJson(e as Map<String, dynamic>)).toList(),
+        infoHash: json['info_hash'] == null ? null : json['info_hash'] as String,
+        name: json['name'] == null ? null : json['name'] as String,
+        numPieces: json['num_pieces'] == null ? null : (json['num_pieces'] as num).toInt(),
+        pieceLength: json['piece_length'] == null ? null : (json['piece_length'] as num).toInt(),
+        selected: json['selected'] == null ? null : (json['selected'] as num).toInt(),
+        selection: json['selection'] == null ? null : Selection.fromJson(json['selection'] as Map<String, dynamic>),
+        source: json['source'] == null ? null : json['source'] as String,
+        totalSize: json['total_size'] == null ? null : (json['total_size'] as num).toInt(),
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (files != null) 'files': files!.map((e) => e.toJson()).toList(),
+        if (infoHash != null) 'info_hash': infoHash,
+        if (name != null) 'name': name,
+        if (numPieces != null) 'num_pieces': numPieces,
+        if (pieceLength != null) 'piece_length': pieceLength,
+        if (selected != null) 'selected': selected,
+        if (selection != null) 'selection': selection!.toJson(),
+        if (source != null) 'source': source,
+        if (totalSize != null) 'total_size': totalSize,
+      };
+}
+
+class MediaInfo {
+  const MediaInfo({
+    this.audio,
+    this.codecWarnings,
+    this.container,
+    this.file,
+    this.note,
+    this.probedBytes,
+    this.video,
+  });
+
+  final List<String>? audio;
+  final List<CodecWarning>? codecWarnings;
+  final String? container;
+  final String? file;
+  final String? note;
+  final int? probedBytes;
+  final List<String>? video;
+
+  factory MediaInfo.fromJson(Map<String, dynamic> json) => MediaInfo(
+        audio: json['audio'] == null ? null : (json['audio'] as List).map((e) => e as String).toList(),
+        codecWarnings: json['codec_warnings'] == null ? null : (json['codec_warnings'] as List).map((e) => CodecWarning.fromJson(e as Map<String, dynamic>)).toList(),
+        container: json['container'] == null ? null : json['container'] as String,
+        file: json['file'] == null ? null : json['file'] as String,
+        note: json['note'] == null ? null : json['note'] as String,
+        probedBytes: json['probed_bytes'] == null ? null : (json['probed_bytes'] as num).toInt(),
+        video: json['video'] == null ? null : (json['video'] as List).map((e) => e as String).toList(),
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (audio != null) 'audio': audio,
+        if (codecWarnings != null) 'codec_warnings': codecWarnings!.map((e) => e.toJson()).toList(),
+        if (container != null) 'container': container,
+        if (file != null) 'file': file,
+        if (note != null) 'note': note,
+        if (probedBytes != null) 'probed_bytes': probedBytes,
+        if (video != null) 'video': video,
+      };
+}
+
+class ParseResponse {
+  const ParseResponse({
+    this.errors,
+    this.infoHash,
+    this.name,
+    this.params,
+    this.peers,
+    this.trackers,
+    this.valid,
+  });
+
+  final List<String>? errors;
+  final String? infoHash;
+  final String? name;
+  final Map<String, List<String>>? params;
+  final List<String>? peers;
+  final List<String>? trackers;
+  final bool? valid;
+
+  factory ParseResponse.fromJson(Map<String, dynamic> json) => ParseResponse(
+        errors: json['errors'] == null ? null : (json['errors'] as List).map((e) => e as String).toList(),
+        infoHash: json['info_hash'] == null ? null : json['info_hash'] as String,
+        name: json['name'] == null ? null : json['name'] as String,
+        params: json['params'] == null ? null : (json['params'] as Map).map((k, v) => MapEntry(k as String, (v as List).map((e) => e as String).toList())),
+        peers: json['peers'] == null ? null : (json['peers'] as List).map((e) => e as String).toList(),
+        trackers: json['trackers'] == null ? null : (json['trackers'] as List).map((e) => e as String).toList(),
+        valid: json['valid'] == null ? null : json['valid'] as bool,
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (errors != null) 'errors': errors,
+        if (infoHash != null) 'info_hash': infoHash,
+        if (name != null) 'name': name,
+        if (params != null) 'params': params,
+        if (peers != null) 'peers': peers,
+        if (trackers != null) 'trackers': trackers,
+        if (valid != null) 'valid': valid,
+      };
+}
+
+class PartyControl {
+  const PartyControl({
+    this.playing,
+    this.positionSec,
+  });
+
+  final bool? playing;
+  final double? positionSec;
+
+  factory PartyControl.fromJson(Map<String, dynamic> json) => PartyControl(
+        playing: json['playing'] == null ? null : json['playing'] as bool,
+        positionSec: json['position_sec'] == null ? null : (json['position_sec'] as num).toDouble(),
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (playing != null) 'playing': playing,
+        if (positionSec != null) 'position_sec': positionSec,
+      };
+}
+
+class PartyMember {
+  const PartyMember({
+    this.id,
+    this.name,
+    this.positionSec,
+    this.seen,
+    this.state,
+  });
+
+  final String? id;
+  final String? name;
+  final double? positionSec;
+  final DateTime? seen;
+  final String? state;
+
+  factory PartyMember.fromJson(Map<String, dynamic> json) => PartyMember(
+        id: json['id'] == null ? null : json['id'] as String,
+        name: json['name'] == null ? null : json['name'] as String,
+        positionSec: json['position_sec'] == null ? null : (json['position_sec'] as num).toDouble(),
+        seen: json['seen'] == null ? null : DateTime.parse(json['seen'] as String),
+        state: json['state'] == null ? null : json['state'] as String,
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (id != null) 'id': id,
+        if (name != null) 'name': name,
+        if (positionSec != null) 'position_sec': positionSec,
+        if (seen != null) 'seen': seen!.toIso8601String(),
+        if (state != null) 'state': state,
+      };
+}
+
+class PartySetup {
+  const PartySetup({
+    this.file,
+    this.id,
+    this.key,
+    this.magnet,
+    this.name,
+    this.playing,
+    this.positionSec,
+  });
+
+  final String? file;
+  final String? id;
+  final String? key;
+  final String? magnet;
+  final String? name;
+  final bool? playing;
+  final double? positionSec;
+
+  factory PartySetup.fromJson(Map<String, dynamic> json) => PartySetup(
+        file: json['file'] == null ? null : json['file'] as String,
+        id: json['id'] == null ? null : json['id'] as String,
+        key: json['key'] == null ? null : json['key'] as String,
+        magnet: json['magnet'] == null ? null : json['magnet'] as String,
+        name: json['name'] == null ? null : json['name'] as String,
+        playing: json['playing'] == null ? null : json['playing'] as bool,
+        positionSec: json['position_sec'] == null ? null : (json['position_sec'] as num).toDouble(),
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (file != null) 'file': file,
+        if (id != null) 'id': id,
+        if (key != null) 'key': key,
+        if (magnet != null) 'magnet': magnet,
+        if (name != null) 'name': name,
+        if (playing != null) 'playing': playing,
+        if (positionSec != null) 'position_sec': positionSec,
+      };
+}
+
+class PartyState {
+  const PartyState({
+    this.clockAt,
+    this.file,
+    this.id,
+    this.magnet,
+    this.members,
+    this.name,
+    this.playing,
+    this.positionSec,
+    this.serverTime,
+    this.waiting,
+  });
+
+  final DateTime? clockAt;
+  final String? file;
+  final String? id;
+  final String? magnet;
+  final List<PartyMember>? members;
+  final String? name;
+  final bool? playing;
+  final double? positionSec;
+  final DateTime? serverTime;
+  final bool? waiting;
+
+  factory PartyState.fromJson(Map<String, dynamic> json) => PartyState(
+        clockAt: json['clock_at'] == null ? null : DateTime.parse(json['clock_at'] as String),
+        file: json['file'] == null ? null : json['file'] as String,
+        id: json['id'] == null ? null : json['id'] as String,
+        magnet: json['magnet'] == null ? null : json['magnet'] as String,
+        members: json['members'] == null ? null : (json['members'] as List).map((e) => PartyMember.fromJson(e as Map<String, dynamic>)).toList(),
+        name: json['name'] == null ? null : json['name'] as String,
+        playing: json['playing'] == null ? null : json['playing'] as bool,
+        positionSec: json['position_sec'] == null ? null : (json['position_sec'] as num).toDouble(),
+        serverTime: json['server_time'] == null ? null : DateTime.parse(json['server_time'] as String),
+        waiting: json['waiting'] == null ? null : json['waiting'] as bool,
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (clockAt != null) 'clock_at': clockAt!.toIso8601String(),
+        if (file != null) 'file': file,
+        if (id != null) 'id': id,
+        if (magnet != null) 'magnet': magnet,
+        if (members != null) 'members': members!.map((e) => e.toJson()).toList(),
+        if (name != null) 'name': name,
+        if (playing != null) 'playing': playing,
+        if (positionSec != null) 'position_sec': positionSec,
+        if (serverTime != null) 'server_time': serverTime!.toIso8601String(),
+        if (waiting != null) 'waiting': waiting,
+      };
+}
+
+class PartyView {
+  const PartyView({
+    this.driftSec,
+    this.error,
+    this.joinUrl,
+    this.offsetMs,
+    this.party,
+    this.role,
+    this.targetPositionSec,
+  });
+
+  final double? driftSec;
+  final String? error;
+  final String? joinUrl;
+  final double? offsetMs;
+  final PartyState? party;
+  final String? role;
+  final double? targetPositionSec;
+
+  factory PartyView.fromJson(Map<String, dynamic> json) => PartyView(
+        driftSec: json['drift_sec'] == null ? null : (json['drift_sec'] as num).toDouble(),
+        error: json['error'] == null ? null : json['error'] as String,
+        joinUrl: json['join_url'] == null ? null : json['join_url'] as String,
+        offsetMs: json['offset_ms'] == null ? null : (json['offset_ms'] as num).toDouble(),
+        party: json['party'] == null ? null : PartyState.fromJson(json['party'] as Map<String, dynamic>),
+        role: json['role'] == null ? null : json['role'] as String,
+        targetPositionSec: json['target_position_sec'] == null ? null : (json['target_position_sec'] as num).toDouble(),
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (driftSec != null) 'drift_sec': driftSec,
+        if (error != null) 'error': error,
+        if (joinUrl != null) 'join_url': joinUrl,
+        if (offsetMs != null) 'offset_ms': offsetMs,
+        if (party != null) 'party': party!.toJson(),
+        if (role != null) 'role': role,
+        if (targetPositionSec != null) 'target_position_sec': targetPositionSec,
+      };
+}
+
+class PieceEntry {
+  const PieceEntry({
+    this.complete,
+    this.index,
+    this.partial,
+    this.priority,
+  });
+
+  final bool? complete;
+  final int? index;
+  final bool? partial;
+  final String? priority;
+
+  factory PieceEntry.fromJson(Map<String, dynamic> json) => PieceEntry(
+        complete: json['complete'] == null ? null : json['complete'] as bool,
+        index: json['index'] == null ? null : (json['index'] as num).toInt(),
+        partial: json['partial'] == null ? null : json['partial'] as bool,
+        priority: json['priority'] == null ? null : json['priority'] as String,
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (complete != null) 'complete': complete,
+        if (index != null) 'index': index,
+        if (partial != null) 'partial': partial,
+        if (priority != null) 'priority': priority,
+      };
+}
+
+class PipelineStats {
+  const PipelineStats({
+    this.maxDepth,
+    this.minDepth,
+    this.peers,
+  });
+
+  final int? maxDepth;
+  final int? minDepth;
+  final int? peers;
+
+  factory PipelineStats.fromJson(Map<String, dynamic> json) => PipelineStats(
+        maxDepth: json['max_depth'] == null ? null : (json['max_depth'] as num).toInt(),
+        minDepth: json['min_depth'] == null ? null : (json['min_depth'] as num).toInt(),
+        peers: json['peers'] == null ? null : (json['peers'] as num).toInt(),
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (maxDepth != null) 'max_depth': maxDepth,
+        if (minDepth != null) 'min_depth': minDepth,
+        if (peers != null) 'peers': peers,
+      };
+}
+
+class PrioritiesResponse {
+  const PrioritiesResponse({
+    this.file,
+    this.fileLength,
+    this.fileOffset,
+    this.pieceLength,
+    this.pieces,
+    this.readers,
+    this.trickle,
+  });
+
+  final String? file;
+  final int? fileLength;
+  final int? fileOffset;
+  final int? pieceLength;
+  final List<PieceEntry>? pieces;
+  final List<ReaderWindow>? readers;
+  final bool? trickle;
+
+  factory PrioritiesResponse.fromJson(Map<String, dynamic> json) => PrioritiesResponse(
+        file: json['file'] == null ? null : json['file'] as String,
+        fileLength: json['file_length'] == null ? null : (json['file_length'] as num).toInt(),
+        fileOffset: json['file_offset'] == null ? null : (json['file_offset'] as num).toInt(),
+        pieceLength: json['piece_length'] == null ? null : (json['piece_length'] as num).toInt(),
+        pieces: json['pieces'] == null ? null : (json['pieces'] as List).map((e) => PieceEntry.fromJson(e as Map<String, dynamic>)).toList(),
+        readers: json['readers'] == null ? null : (json['readers'] as List).map((e) => ReaderWindow.fromJson(e as Map<String, dynamic>)).toList(),
+        trickle: json['trickle'] == null ? null : json['trickle'] as bool,
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (file != null) 'file': file,
+        if (fileLength != null) 'file_length': fileLength,
+        if (fileOffset != null) 'file_offset': fileOffset,
+        if (pieceLength != null) 'piece_length': pieceLength,
+        if (pieces != null) 'pieces': pieces!.map((e) => e.toJson()).toList(),
+        if (readers != null) 'readers': readers!.map((e) => e.toJson()).toList(),
+        if (trickle != null) 'trickle': trickle,
+      };
+}
+
+class ProfileSettings {
+  const ProfileSettings({
+    this.blockPattern,
+    this.dataSaverSecs,
+    this.fillOrder,
+    this.fillSharePct,
+    this.idleDropMins,
+    this.idlePauseMins,
+    this.maxFileSizeMb,
+    this.selectAvoidWords,
+    this.selectDenyExts,
+    this.selectExcludePaths,
+    this.selectMinSizeMb,
+    this.selectPreferExts,
+  });
+
+  final String? blockPattern;
+  final int? dataSaverSecs;
+  final String? fillOrder;
+  final int? fillSharePct;
+  final int? idleDropMins;
+  final int? idlePauseMins;
+  final int? maxFileSizeMb;
+  final List<String>? selectAvoidWords;
+  final List<String>? selectDenyExts;
+  final List<String>? selectExcludePaths;
+  final int? selectMinSizeMb;
+  final List<String>? selectPreferExts;
+
+  factory ProfileSettings.fromJson(Map<String, dynamic> json) => ProfileSettings(
+        blockPattern: json['block_pattern'] == null ? null : json['block_pattern'] as String,
+        dataSaverSecs: json['data_saver_secs'] == null ? null : (json['data_saver_secs'] as num).toInt(),
+        fillOrder: json['fill_order'] == null ? null : json['fill_order'] as String,
+        fillSharePct: json['fill_share_pct'] == null ? null : (json['fill_share_pct'] as num).toInt(),
+        idleDropMins: json['idle_drop_mins'] == null ? null : (json['idle_drop_mins'] as num).toInt(),
+        idlePauseMins: json['idle_pause_mins'] == null ? null : (json['idle_pause_mins'] as num).toInt(),
+        maxFileSizeMb: json['max_file_size_mb'] == null ? null : (json['max_file_size_mb'] as num).toInt(),
+        selectAvoidWords: json['select_avoid_words'] == null ? null : (json['select_avoid_words'] as List).map((e) => e as String).toList(),
+        selectDenyExts: json['select_deny_exts'] == null ? null : (json['select_deny_exts'] as List).map((e) => e as String).toList(),
+        selectExcludePaths: json['select_exclude_paths'] == null ? null : (json['select_exclude_paths'] as List).map((e) => e as String).toList(),
+        selectMinSizeMb: json['select_min_size_mb'] == null ? null : (json['select_min_size_mb'] as num).toInt(),
+        selectPreferExts: json['select_prefer_exts'] == null ? null : (json['select_prefer_exts'] as List).map((e) => e as String).toList(),
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (blockPattern != null) 'block_pattern': blockPattern,
+        if (dataSaverSecs != null) 'data_saver_secs': dataSaverSecs,
+        if (fillOrder != null) 'fill_order': fillOrder,
+        if (fillSharePct != null) 'fill_share_pct': fillSharePct,
+        if (idleDropMins != null) 'idle_drop_mins': idleDropMins,
+        if (idlePauseMins != null) 'idle_pause_mins': idlePauseMins,
+        if (maxFileSizeMb != null) 'max_file_size_mb': maxFileSizeMb,
+        if (selectAvoidWords != null) 'select_avoid_words': selectAvoidWords,
+        if (selectDenyExts != null) 'select_deny_exts': selectDenyExts,
+        if (selectExcludePaths != null) 'select_exclude_paths': selectExcludePaths,
+        if (selectMinSizeMb != null) 'select_min_size_mb': selectMinSizeMb,
+        if (selectPreferExts != null) 'select_prefer_exts': selectPreferExts,
+      };
+}
+
+class QueueAddItem {
+  const QueueAddItem({
+    this.authorization,
+    this.cookie,
+    this.episode,
+    this.fallbacks,
+    this.file,
+    this.fileIndex,
+    this.headBytes,
+    this.labels,
+    this.magnet,
+    this.paused,
+    this.peers,
+    this.policy,
+    this.priority,
+    this.sequential,
+    this.swarm,
+    this.tailBytes,
+    this.title,
+    this.trackers,
+    this.url,
+  });
+
+  final String? authorization;
+  final String? cookie;
+  final String? episode;
+  final List<String>? fallbacks;
+  final String? file;
+  final int? fileIndex;
+  final int? headBytes;
+  final Map<String, String>? labels;
+  final String? magnet;
+  final bool? paused;
+  final List<String>? peers;
+  final StreamPolicy? policy;
+  final int? priority;
+  final bool? sequential;
+  final dynamic? swarm;
+  final int? tailBytes;
+  final String? title;
+  final List<String>? trackers;
+  final String? url;
+
+  factory QueueAddItem.fromJson(Map<String, dynamic> json) => QueueAddItem(
+        authorization: json['authorization'] == null ? null : json['authorization'] as String,
+        cookie: json['cookie'] == null ? null : json['cookie'] as String,
+        episode: json['episode'] == null ? null : json['episode'] as String,
+        fallbacks: json['fallbacks'] == null ? null : (json['fallbacks'] as List).map((e) => e as String).toList(),
+        file: json['file'] == null ? null : json['file'] as String,
+        fileIndex: json['file_index'] == null ? null : (json['file_index'] as num).toInt(),
+        headBytes: json['head_bytes'] == null ? null : (json['head_bytes'] as num).toInt(),
+        labels: json['labels'] == null ? null : (json['labels'] as Map).map((k, v) => MapEntry(k as String, v as String)),
+        magnet: json['magnet'] == null ? null : json['magnet'] as String,
+        paused: json['paused'] == null ? null : json['paused'] as bool,
+        peers: json['peers'] == null ? null : (json['peers'] as List).map((e) => e as String).toList(),
+        policy: json['policy'] == null ? null : StreamPolicy.fromJson(json['policy'] as Map<String, dynamic>),
+        priority: json['priority'] == null ? null : (json['priority'] as num).toInt(),
+        sequential: json['sequential'] == null ? null : json['sequential'] as bool,
+        swarm: json['swarm'] == null ? null : json['swarm'],
+        tailBytes: json['tail_bytes'] == null ? null : (json['tail_bytes'] as num).toInt(),
+        title: json['title'] == null ? null : json['title'] as String,
+        trackers: json['trackers'] == null ? null : (json['trackers'] as List).map((e) => e as String).toList(),
+        url: json['url'] == null ? null : json['url'] as String,
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (authorization != null) 'authorization': authorization,
+        if (cookie != null) 'cookie': cookie,
+        if (episode != null) 'episode': episode,
+        if (fallbacks != null) 'fallbacks': fallbacks,
+        if (file != null) 'file': file,
+        if (fileIndex != null) 'file_index': fileIndex,
+        if (headBytes != null) 'head_bytes': headBytes,
+        if (labels != null) 'labels': labels,
+        if (magnet != null) 'magnet': magnet,
+        if (paused != null) 'paused': paused,
+        if (peers != null) 'peers': peers,
+        if (policy != null) 'policy': policy!.toJson(),
+        if (priority != null) 'priority': priority,
+        if (sequential != null) 'sequential': sequential,
+        if (swarm != null) 'swarm': swarm,
+        if (tailBytes != null) 'tail_bytes': tailBytes,
+        if (title != null) 'title': title,
+        if (trackers != null) 'trackers': trackers,
+        if (url != null) 'url': url,
+      };
+}
+
+class QueueAddRequest {
+  const QueueAddRequest({
+    this.concurrency,
+    this.items,
+    this.magnets,
+    this.policy,
+  });
+
+  final int? concurrency;
+  final List<QueueAddItem>? items;
+  final List<String>? magnets;
+  final String? policy;
+
+  factory QueueAddRequest.fromJson(Map<String, dynamic> json) => QueueAddRequest(
+        concurrency: json['concurrency'] == null ? null : (json['concurrency'] as num).toInt(),
+        items: json['items'] == null ? null : (json['items'] as List).map((e) => QueueAddItem.fromJson(e as Map<String, dynamic>)).toList(),
+        magnets: json['magnets'] == null ? null : (json['magnets'] as List).map((e) => e as String).toList(),
+        policy: json['policy'] == null ? null : json['policy'] as String,
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (concurrency != null) 'concurrency': concurrency,
+        if (items != null) 'items': items!.map((e) => e.toJson()).toList(),
+        if (magnets != null) 'magnets': magnets,
+        if (policy != null) 'policy': policy,
+      };
+}
+
+class QueueEntry {
+  const QueueEntry({
+    this.added,
+    this.error,
+    this.id,
+    this.infoHash,
+    this.position,
+    this.priority,
+    this.request,
+    this.state,
+    this.status,
+  });
+
+  final DateTime? added;
+  final String? error;
+  final int? id;
+  final String? infoHash;
+  final int? position;
+  final int? priority;
+  final AddRequest? request;
+  final String? state;
+  final StatusResponse? status;
+
+  factory QueueEntry.fromJson(Map<String, dynamic> json) => QueueEntry(
+        added: json['added'] == null ? null : DateTime.parse(json['added'] as String),
+        error: json['error'] == null ? null : json['error'] as String,
+        id: json['id'] == null ? null : (json['id'] as num).toInt(),
+        infoHash: json['info_hash'] == null ? null : json['info_hash'] as String,
+        position: json['position'] == null ? null : (json['position'] as num).toInt(),
+        priority: json['priority'] == null ? null : (json['priority'] as num).toInt(),
+        request: json['request'] == null ? null : AddRequest.fromJson(json['request'] as Map<String, dynamic>),
+        state: json['state'] == null ? null : json['state'] as String,
+        status: json['status'] == null ? null : StatusResponse.fromJson(json['status'] as Map<String, dynamic>),
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (added != null) 'added': added!.toIso8601String(),
+        if (error != null) 'error': error,
+        if (id != null) 'id': id,
+        if (infoHash != null) 'info_hash': infoHash,
+        if (position != null) 'position': position,
+        if (priority != null) 'priority': priority,
+        if (request != null) 'request': request!.toJson(),
+        if (state != null) 'state': state,
+        if (status != null) 'status': status!.toJson(),
+      };
+}
+
+class QueueResponse {
+  const QueueResponse({
+    this.added,
+    this.concurrency,
+    this.items,
+    this.policy,
+  });
+
+  final List<int>? added;
+  final int? concurrency;
+  final List<QueueEntry>? items;
+  final String? policy;
+
+  factory QueueResponse.fromJson(Map<String, dynamic> json) => QueueResponse(
+        added: json['added'] == null ? null : (json['added'] as List).map((e) => (e as num).toInt()).toList(),
+        concurrency: json['concurrency'] == null ? null : (json['concurrency'] as num).toInt(),
+        items: json['items'] == null ? null : (json['items'] as List).map((e) => QueueEntry.fromJson(e as Map<String, dynamic>)).toList(),
+        policy: json['policy'] == null ? null : json['policy'] as String,
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (added != null) 'added': added,
+        if (concurrency != null) 'concurrency': concurrency,
+        if (items != null) 'items': items!.map((e) => e.toJson()).toList(),
+        if (policy != null) 'policy': policy,
+      };
+}
+
+class RangeAgentStats {
+  const RangeAgentStats({
+    this.bounded,
+    this.firstSeen,
+    this.full,
+    this.lastSeen,
+    this.open,
+    this.pattern,
+    this.recent,
+    this.requests,
+    this.seek,
+    this.seeks,
+    this.tail,
+    this.userAgent,
+  });
+
+  final int? bounded;
+  final DateTime? firstSeen;
+  final int? full;
+  final DateTime? lastSeen;
+  final int? open;
+  final String? pattern;
+  final List<RangeSample>? recent;
+  final int? requests;
+  final int? seek;
+  final List<int>? seeks;
+  final int? tail;
+  final String? userAgent;
+
+  factory RangeAgentStats.fromJson(Map<String, dynamic> json) => RangeAgentStats(
+        bounded: json['bounded'] == null ? null : (json['bounded'] as num).toInt(),
+        firstSeen: json['first_seen'] == null ? null : DateTime.parse(json['first_seen'] as String),
+        full: json['full'] == null ? null : (json['full'] as num).toInt(),
+        lastSeen: json['last_seen'] == null ? null : DateTime.parse(json['last_seen'] as String),
+        open: json['open'] == null ? null : (json['open'] as num).toInt(),
+        pattern: json['pattern'] == null ? null : json['pattern'] as String,
+        recent: json['recent'] == null ? null : (json['recent'] as List).map((e) => RangeSample.fromJson(e as Map<String, dynamic>)).toList(),
+        requests: json['requests'] == null ? null : (json['requests'] as num).toInt(),
+        seek: json['seek'] == null ? null : (json['seek'] as num).toInt(),
+        seeks: json['seeks'] == null ? null : (json['seeks'] as List).map((e) => (e as num).toInt()).toList(),
+        tail: json['tail'] == null ? null : (json['tail'] as num).toInt(),
+        userAgent: json['user_agent'] == null ? null : json['user_agent'] as String,
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (bounded != null) 'bounded': bounded,
+        if (firstSeen != null) 'first_seen': firstSeen!.toIso8601String(),
+        if (full != null) 'full': full,
+        if (lastSeen != null) 'last_seen': lastSeen!.toIso8601String(),
+        if (open != null) 'open': open,
+        if (pattern != null) 'pattern': pattern,
+        if (recent != null) 'recent': recent!.map((e) => e.toJson()).toList(),
+        if (requests != null) 'requests': requests,
+        if (seek != null) 'seek': seek,
+        if (seeks != null) 'seeks': seeks,
+        if (tail != null) 'tail': tail,
+        if (userAgent != null) 'user_agent': userAgent,
+      };
+}
+
+class RangeSample {
+  const RangeSample({
+    this.kind,
+    this.length,
+    this.offset,
+    this.time,
+  });
+
+  final String? kind;
+  final int? length;
+  final int? offset;
+  final DateTime? time;
+
+  factory RangeSample.fromJson(Map<String, dynamic> json) => RangeSample(
+        kind: json['kind'] == null ? null : json['kind'] as String,
+        length: json['length'] == null ? null : (json['length'] as num).toInt(),
+        offset: json['offset'] == null ? null : (json['offset'] as num).toInt(),
+        time: json['time'] == null ? null : DateTime.parse(json['time'] as String),
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (kind != null) 'kind': kind,
+        if (length != null) 'length': length,
+        if (offset != null) 'offset': offset,
+        if (time != null) 'time': time!.toIso8601String(),
+      };
+}
+
+class ReaderWindow {
+  const ReaderWindow({
+    this.aheadUntil,
+    this.offset,
+    this.paced,
+    this.piece,
+    this.readahead,
+  });
+
+  final int? aheadUntil;
+  final int? offset;
+  final bool? paced;
+  final int? piece;
+  final int? readahead;
+
+  factory ReaderWindow.fromJson(Map<String, dynamic> json) => ReaderWindow(
+        aheadUntil: json['ahead_until'] == null ? null : (json['ahead_until'] as num).toInt(),
+        offset: json['offset'] == null ? null : (json['offset'] as num).toInt(),
+        paced: json['paced'] == null ? null : json['paced'] as bool,
+        piece: json['piece'] == null ? null : (json['piece'] as num).toInt(),
+        readahead: json['readahead'] == null ? null : (json['readahead'] as num).toInt(),
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (aheadUntil != null) 'ahead_until': aheadUntil,
+        if (offset != null) 'offset': offset,
+        if (paced != null) 'paced': paced,
+        if (piece != null) 'piece': piece,
+        if (readahead != null) 'readahead': readahead,
+      };
+}
+
+class RelayStatus {
+  const RelayStatus({
+    this.channel,
+    this.connected,
+    this.enabled,
+    this.error,
+    this.hub,
+    this.hubConns,
+    this.rejected,
+    this.requests,
+    this.since,
+    this.url,
+  });
+
+  final String? channel;
+  final bool? connected;
+  final bool? enabled;
+  final String? error;
+  final bool? hub;
+  final int? hubConns;
+  final int? rejected;
+  final int? requests;
+  final DateTime? since;
+  final String? url;
+
+  factory RelayStatus.fromJson(Map<String, dynamic> json) => RelayStatus(
+        channel: json['channel'] == null ? null : json['channel'] as String,
+        connected: json['connected'] == null ? null : json['connected'] as bool,
+        enabled: json['enabled'] == null ? null : json['enabled'] as bool,
+        error: json['error'] == null ? null : json['error'] as String,
+        hub: json['hub'] == null ? null : json['hub'] as bool,
+        hubConns: json['hub_conns'] == null ? null : (json['hub_conns'] as num).toInt(),
+        rejected: json['rejected'] == null ? null : (json['rejected'] as num).toInt(),
+        requests: json['requests'] == null ? null : (json['requests'] as num).toInt(),
+        since: json['since'] == null ? null : DateTime.parse(json['since'] as String),
+        url: json['url'] == null ? null : json['url'] as String,
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (channel != null) 'channel': channel,
+        if (connected != null) 'connected': connected,
+        if (enabled != null) 'enabled': enabled,
+        if (error != null) 'error': error,
+        if (hub != null) 'hub': hub,
+        if (hubConns != null) 'hub_conns': hubConns,
+        if (rejected != null) 'rejected': rejected,
+        if (requests != null) 'requests': requests,
+        if (since != null) 'since': since!.toIso8601String(),
+        if (url != null) 'url': url,
+      };
+}
+
+class RestoredSession {
+  const RestoredSession({
+    this.addId,
+    this.error,
+    this.infoHash,
+    this.primary,
+    this.profile,
+    this.status,
+  });
+
+  final int? addId;
+  final String? error;
+  final String? infoHash;
+  final bool? primary;
+  final String? profile;
+  final String? status;
+
+  factory RestoredSession.fromJson(Map<String, dynamic> json) => RestoredSession(
+        addId: json['add_id'] == null ? null : (json['add_id'] as num).toInt(),
+        error: json['error'] == null ? null : json['error'] as String,
+        infoHash: json['info_hash'] == null ? null : json['info_hash'] as String,
+        primary: json['primary'] == null ? null : json['primary'] as bool,
+        profile: json['profile'] == null ? null : json['profile'] as String,
+        status: json['status'] == null ? null : json['status'] as String,
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (addId != null) 'add_id': addId,
+        if (error != null) 'error': error,
+        if (infoHash != null) 'info_hash': infoHash,
+        if (primary != null) 'primary': primary,
+        if (profile != null) 'profile': profile,
+        if (status != null) 'status': status,
+      };
+}
+
+class RssFeed {
+  const RssFeed({
+    this.exclude,
+    this.id,
+    this.include,
+    this.intervalMin,
+    this.lastChecked,
+    this.lastError,
+    this.url,
+  });
+
+  final String? exclude;
+  final String? id;
+  final String? include;
+  final int? intervalMin;
+  final DateTime? lastChecked;
+  final String? lastError;
+  final String? url;
+
+  factory RssFeed.fromJson(Map<String, dynamic> json) => RssFeed(
+        exclude: json['exclude'] == null ? null : json['exclude'] as String,
+        id: json['id'] == null ? null : json['id'] as String,
+        include: json['include'] == null ? null : json['include'] as String,
+        intervalMin: json['interval_min'] == null ? null : (json['interval_min'] as num).toInt(),
+        lastChecked: json['last_checked'] == null ? null : DateTime.parse(json['last_checked'] as String),
+        lastError: json['last_error'] == null ? null : json['last_error'] as String,
+        url: json['url'] == null ? null : json['url'] as String,
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (exclude != null) 'exclude': exclude,
+        if (id != null) 'id': id,
+        if (include != null) 'include': include,
+        if (intervalMin != null) 'interval_min': intervalMin,
+        if (lastChecked != null) 'last_checked': lastChecked!.toIso8601String(),
+        if (lastError != null) 'last_error': lastError,
+        if (url != null) 'url': url,
+      };
+}
+
+class RssItem {
+  const RssItem({
+    this.done,
+    this.error,
+    this.feedId,
+    this.guid,
+    this.infoHash,
+    this.link,
+    this.queuedAt,
+    this.title,
+  });
+
+  final bool? done;
+  final String? error;
+  final String? feedId;
+  final String? guid;
+  final String? infoHash;
+  final String? link;
+  final DateTime? queuedAt;
+  final String? title;
+
+  factory RssItem.fromJson(Map<String, dynamic> json) => RssItem(
+        done: json['done'] == null ? null : json['done'] as bool,
+        error: json['error'] == null ? null : json['error'] as String,
+        feedId: json['feed_id'] == null ? null : json['feed_id'] as String,
+        guid: json['guid'] == null ? null : json['guid'] as String,
+        infoHash: json['info_hash'] == null ? null : json['info_hash'] as String,
+        link: json['link'] == null ? null : json['link'] as String,
+        queuedAt: json['queued_at'] == null ? null : DateTime.parse(json['queued_at'] as String),
+        title: json['title'] == null ? null : json['title'] as String,
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (done != null) 'done': done,
+        if (error != null) 'error': error,
+        if (feedId != null) 'feed_id': feedId,
+        if (guid != null) 'guid': guid,
+        if (infoHash != null) 'info_hash': infoHash,
+        if (link != null) 'link': link,
+        if (queuedAt != null) 'queued_at': queuedAt!.toIso8601String(),
+        if (title != null) 'title': title,
+      };
+}
+
+class RssState {
+  const RssState({
+    this.feeds,
+    this.items,
+  });
+
+  final List<RssFeed>? feeds;
+  final List<RssItem>? items;
+
+  factory RssState.fromJson(Map<String, dynamic> json) => RssState(
+        feeds: json['feeds'] == null ? null : (json['feeds'] as List).map((e) => RssFeed.fromJson(e as Map<String, dynamic>)).toList(),
+        items: json['items'] == null ? null : (json['items'] as List).map((e) => RssItem.fromJson(e as Map<String, dynamic>)).toList(),
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (feeds != null) 'feeds': feeds!.map((e) => e.toJson()).toList(),
+        if (items != null) 'items': items!.map((e) => e.toJson()).toList(),
+      };
+}
+
+class SeekNearest {
+  const SeekNearest({
+    this.buffered,
+    this.nearest,
+    this.offset,
+    this.partial,
+    this.runEnd,
+    this.runStart,
+  });
+
+  final bool? buffered;
+  final int? nearest;
+  final int? offset;
+  final bool? partial;
+  final int? runEnd;
+  final int? runStart;
+
+  factory SeekNearest.fromJson(Map<String, dynamic> json) => SeekNearest(
+        buffered: json['buffered'] == null ? null : json['buffered'] as bool,
+        nearest: json['nearest'] == null ? null : (json['nearest'] as num).toInt(),
+        offset: json['offset'] == null ? null : (json['offset'] as num).toInt(),
+        partial: json['partial'] == null ? null : json['partial'] as bool,
+        runEnd: json['run_end'] == null ? null : (json['run_end'] as num).toInt(),
+        runStart: json['run_start'] == null ? null : (json['run_start'] as num).toInt(),
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (buffered != null) 'buffered': buffered,
+        if (nearest != null) 'nearest': nearest,
+        if (offset != null) 'offset': offset,
+        if (partial != null) 'partial': partial,
+        if (runEnd != null) 'run_end': runEnd,
+        if (runStart != null) 'run_start': runStart,
+      };
+}
+
+class Selection {
+  const Selection({
+    this.candidates,
+    this.episode,
+    this.extras,
+    this.filtered,
+    this.hint,
+    this.reason,
+  });
+
+  final int? candidates;
+  final String? episode;
+  final int? extras;
+  final int? filtered;
+  final Hint? hint;
+  final String? reason;
+
+  factory Selection.fromJson(Map<String, dynamic> json) => Selection(
+        candidates: json['candidates'] == null ? null : (json['candidates'] as num).toInt(),
+        episode: json['episode'] == null ? null : json['episode'] as String,
+        extras: json['extras'] == null ? null : (json['extras'] as num).toInt(),
+        filtered: json['filtered'] == null ? null : (json['filtered'] as num).toInt(),
+        hint: json['hint'] == null ? null : Hint.fromJson(json['hint'] as Map<String, dynamic>),
+        reason: json['reason'] == null ? null : json['reason'] as String,
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (candidates != null) 'candidates': candidates,
+        if (episode != null) 'episode': episode,
+        if (extras != null) 'extras': extras,
+        if (filtered != null) 'filtered': filtered,
+        if (hint != null) 'hint': hint!.toJson(),
+        if (reason != null) 'reason': reason,
+      };
+}
+
+class SessionEntry {
+  const SessionEntry({
+    this.infoHash,
+    this.kind,
+    this.maxConns,
+    this.name,
+    this.peers,
+    this.position,
+    this.profile,
+    this.reading,
+    this.state,
+  });
+
+  final String? infoHash;
+  final String? kind;
+  final int? maxConns;
+  final String? name;
+  final int? peers;
+  final int? position;
+  final String? profile;
+  final bool? reading;
+  final String? state;
+
+  factory SessionEntry.fromJson(Map<String, dynamic> json) => SessionEntry(
+        infoHash: json['info_hash'] == null ? null : json['info_hash'] as String,
+        kind: json['kind'] == null ? null : json['kind'] as String,
+        maxConns: json['max_conns'] == null ? null : (json['max_conns'] as num).toInt(),
+        name: json['name'] == null ? null : json['name'] as String,
+        peers: json['peers'] == null ? null : (json['peers'] as num).toInt(),
+        position: json['position'] == null ? null : (json['position'] as num).toInt(),
+        profile: json['profile'] == null ? null : json['profile'] as String,
+        reading: json['reading'] == null ? null : json['reading'] as bool,
+        state: json['state'] == null ? null : json['state'] as String,
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (infoHash != null) 'info_hash': infoHash,
+        if (kind != null) 'kind': kind,
+        if (maxConns != null) 'max_conns': maxConns,
+        if (name != null) 'name': name,
+        if (peers != null) 'peers': peers,
+        if (position != null) 'position': position,
+        if (profile != null) 'profile': profile,
+        if (reading != null) 'reading': reading,
+        if (state != null) 'state': state,
+      };
+}
+
+class SessionsResponse {
+  const SessionsResponse({
+    this.downloading,
+    this.limits,
+    this.queued,
+    this.sessions,
+  });
+
+  final int? downloading;
+  final GlobalLimits? limits;
+  final int? queued;
+  final List<SessionEntry>? sessions;
+
+  factory SessionsResponse.fromJson(Map<String, dynamic> json) => SessionsResponse(
+        downloading: json['downloading'] == null ? null : (json['downloading'] as num).toInt(),
+        limits: json['limits'] == null ? null : GlobalLimits.fromJson(json['limits'] as Map<String, dynamic>),
+        queued: json['queued'] == null ? null : (json['queued'] as num).toInt(),
+        sessions: json['sessions'] == null ? null : (json['sessions'] as List).map((e) => SessionEntry.fromJson(e as Map<String, dynamic>)).toList(),
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (downloading != null) 'downloading': downloading,
+        if (limits != null) 'limits': limits!.toJson(),
+        if (queued != null) 'queued': queued,
+        if (sessions != null) 'sessions': sessions!.map((e) => e.toJson()).toList(),
+      };
+}
+
+class SnapshotBlob {
+  const SnapshotBlob({
+    this.sessions,
+    this.snapshot,
+    this.taken,
+  });
+
+  final int? sessions;
+  final String? snapshot;
+  final DateTime? taken;
+
+  factory SnapshotBlob.fromJson(Map<String, dynamic> json) => SnapshotBlob(
+        sessions: json['sessions'] == null ? null : (json['sessions'] as num).toInt(),
+        snapshot: json['snapshot'] == null ? null : json['snapshot'] as String,
+        taken: json['taken'] == null ? null : DateTime.parse(json['taken'] as String),
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (sessions != null) 'sessions': sessions,
+        if (snapshot != null) 'snapshot': snapshot,
+        if (taken != null) 'taken': taken!.toIso8601String(),
+      };
+}
+
+class StartupTimings {
+  const StartupTimings({
+    this.addedAt,
+    this.firstByteMs,
+    this.firstPieceMs,
+    this.metadataMs,
+    this.readyMs,
+  });
+
+  final int? addedAt;
+  final int? firstByteMs;
+  final int? firstPieceMs;
+  final int? metadataMs;
+  final int? readyMs;
+
+  factory StartupTimings.fromJson(Map<String, dynamic> json) => StartupTimings(
+        addedAt: json['added_at'] == null ? null : (json['added_at'] as num).toInt(),
+        firstByteMs: json['first_byte_ms'] == null ? null : (json['first_byte_ms'] as num).toInt(),
+        firstPieceMs: json['first_piece_ms'] == null ? null : (json['first_piece_ms'] as num).toInt(),
+        metadataMs: json['metadata_ms'] == null ? null : (json['metadata_ms'] as num).toInt(),
+        readyMs: json['ready_ms'] == null ? null : (json['ready_ms'] as num).toInt(),
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (addedAt != null) 'added_at': addedAt,
+        if (firstByteMs != null) 'first_byte_ms': firstByteMs,
+        if (firstPieceMs != null) 'first_piece_ms': firstPieceMs,
+        if (metadataMs != null) 'metadata_ms': metadataMs,
+        if (readyMs != null) 'ready_ms': readyMs,
+      };
+}
+
+class StatusDelta {
+  const StatusDelta({
+    this.changed,
+    this.full,
+    this.removed,
+    this.seq,
+  });
+
+  final Map<String, dynamic>? changed;
+  final bool? full;
+  final List<String>? removed;
+  final int? seq;
+
+  factory StatusDelta.fromJson(Map<String, dynamic> json) => StatusDelta(
+        changed: json['changed'] == null ? null : (json['changed'] as Map).map((k, v) => MapEntry(k as String, v)),
+        full: json['full'] == null ? null : json['full'] as bool,
+        removed: json['removed'] == null ? null : (json['removed'] as List).map((e) => e as String).toList(),
+        seq: json['seq'] == null ? null : (json['seq'] as num).toInt(),
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (changed != null) 'changed': changed,
+        if (full != null) 'full': full,
+        if (removed != null) 'removed': removed,
+        if (seq != null) 'seq': seq,
+      };
+}
+
+class StatusResponse {
+  const StatusResponse({
+    this.addId,
+    this.availability,
+    this.completedMb,
+    this.dataSaver,
+    this.downloadMb,
+    this.durationSec,
+    this.error,
+    this.fallback,
+    this.fillThrottled,
+    this.globalPosition,
+    this.globalQueued,
+    this.idlePaused,
+    this.infoHash,
+    this.labels,
+    this.paused,
+    this.peers,
+    this.playerState,
+    this.positionSec,
+    this.private,
+    this.progress,
+    this.ratio,
+    this.resumeAtSec,
+    this.seeding,
+    this.speedKbs,
+    this.state,
+    this.streamUrl,
+    this.superSeeding,
+    this.swarmWarning,
+    this.timings,
+    this.trickle,
+    this.uploadSpeedKbs,
+    this.uploadedMb,
+    this.windowAvailability,
+  });
+
+  final int? addId;
+  final double? availability;
+  final double? completedMb;
+  final bool? dataSaver;
+  final double? downloadMb;
+  final double? durationSec;
+  final String? error;
+  final FallbackStatus? fallback;
+  final bool? fillThrottled;
+  final int? globalPosition;
+  final bool? globalQueued;
+  final bool? idlePaused;
+  final String? infoHash;
+  final Map<String, String>? labels;
+  final bool? paused;
+  final int? peers;
+  final String? playerState;
+  final double? positionSec;
+  final bool? private;
+  final double? progress;
+  final double? ratio;
+  final double? resumeAtSec;
+  final bool? seeding;
+  final double? speedKbs;
+  final String? state;
+  final String? streamUrl;
+  final bool? superSeeding;
+  final String? swarmWarning;
+  final StartupTimings? timings;
+  final bool? trickle;
+  final double? uploadSpeedKbs;
+  final double? uploadedMb;
+  final double? windowAvailability;
+
+  factory StatusResponse.fromJson(Map<String, dynamic> json) => StatusResponse(
+        addId: json['add_id'] == null ? null : (json['add_id'] as num).toInt(),
+        availability: json['availability'] == null ? null : (json['availability'] as num).toDouble(),
+        completedMb: json['completed_mb'] == null ? null : (json['completed_mb'] as num).toDouble(),
+        dataSaver: json['data_saver'] == null ? null : json['data_saver'] as bool,
+        downloadMb: json['download_mb'] == null ? null : (json['download_mb'] as num).toDouble(),
+        durationSec: json['duration_sec'] == null ? null : (json['duration_sec'] as num).toDouble(),
+        error: json['error'] == null ? null : json['error'] as String,
+        fallback: json['fallback'] == null ? null : FallbackStatus.fromJson(json['fallback'] as Map<String, dynamic>),
+        fillThrottled: json['fill_throttled'] == null ? null : json['fill_throttled'] as bool,
+        globalPosition: json['global_position'] == null ? null : (json['global_position'] as num).toInt(),
+        globalQueued: json['global_queued'] == null ? null : json['global_queued'] as bool,
+        idlePaused: json['idle_paused'] == null ? null : json['idle_paused'] as bool,
+        infoHash: json['info_hash'] == null ? null : json['info_hash'] as String,
+        labels: json['labels'] == null ? null : (json['labels'] as Map).map((k, v) => MapEntry(k as String, v as String)),
+        paused: json['paused'] == null ? null : json['paused'] as bool,
+        peers: json['peers'] == null ? null : (json['peers'] as num).toInt(),
+        playerState: json['player_state'] == null ? null : json['player_state'] as String,
+        positionSec: json['position_sec'] == null ? null : (json['position_sec'] as num).toDouble(),
+        private: json['private'] == null ? null : json['private'] as bool,
+        progress: json['progress'] == null ? null : (json['progress'] as num).toDouble(),
+        ratio: json['ratio'] == null ? null : (json['ratio'] as num).toDouble(),
+        resumeAtSec: json['resume_at_sec'] == null ? null : (json['resume_at_sec'] as num).toDouble(),
+        seeding: json['seeding'] == null ? null : json['seeding'] as bool,
+        speedKbs: json['speed_kbs'] == null ? null : (json['speed_kbs'] as num).toDouble(),
+        state: json['state'] == null ? null : json['state'] as String,
+        streamUrl: json['stream_url'] == null ? null : json['stream_url'] as String,
+        superSeeding: json['super_seeding'] == null ? null : json['super_seeding'] as bool,
+        swarmWarning: json['swarm_warning'] == null ? null : json['swarm_warning'] as String,
+        timings: json['timings'] == null ? null : StartupTimings.fromJson(json['timings'] as Map<String, dynamic>),
+        trickle: json['trickle'] == null ? null : json['trickle'] as bool,
+        uploadSpeedKbs: json['upload_speed_kbs'] == null ? null : (json['upload_speed_kbs'] as num).toDouble(),
+        uploadedMb: json['uploaded_mb'] == null ? null : (json['uploaded_mb'] as num).toDouble(),
+        windowAvailability: json['window_availability'] == null ? null : (json['window_availability'] as num).toDouble(),
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (addId != null) 'add_id': addId,
+        if (availability != null) 'availability': availability,
+        if (completedMb != null) 'completed_mb': completedMb,
+        if (dataSaver != null) 'data_saver': dataSaver,
+        if (downloadMb != null) 'download_mb': downloadMb,
+        if (durationSec != null) 'duration_sec': durationSec,
+        if (error != null) 'error': error,
+        if (fallback != null) 'fallback': fallback!.toJson(),
+        if (fillThrottled != null) 'fill_throttled': fillThrottled,
+        if (globalPosition != null) 'global_position': globalPosition,
+        if (globalQueued != null) 'global_queued': globalQueued,
+        if (idlePaused != null) 'idle_paused': idlePaused,
+        if (infoHash != null) 'info_hash': infoHash,
+        if (labels != null) 'labels': labels,
+        if (paused != null) 'paused': paused,
+        if (peers != null) 'peers': peers,
+        if (playerState != null) 'player_state': playerState,
+        if (positionSec != null) 'position_sec': positionSec,
+        if (private != null) 'private': private,
+        if (progress != null) 'progress': progress,
+        if (ratio != null) 'ratio': ratio,
+        if (resumeAtSec != null) 'resume_at_sec': resumeAtSec,
+        if (seeding != null) 'seeding': seeding,
+        if (speedKbs != null) 'speed_kbs': speedKbs,
+        if (state != null) 'state': state,
+        if (streamUrl != null) 'stream_url': streamUrl,
+        if (superSeeding != null) 'super_seeding': superSeeding,
+        if (swarmWarning != null) 'swarm_warning': swarmWarning,
+        if (timings != null) 'timings': timings!.toJson(),
+        if (trickle != null) 'trickle': trickle,
+        if (uploadSpeedKbs != null) 'upload_speed_kbs': uploadSpeedKbs,
+        if (uploadedMb != null) 'uploaded_mb': uploadedMb,
+        if (windowAvailability != null) 'window_availability': windowAvailability,
+      };
+}
+
+class StreamPolicy {
+  const StreamPolicy({
+    this.blockPattern,
+    this.maxFileSizeMb,
+  });
+
+  final String? blockPattern;
+  final int? maxFileSizeMb;
+
+  factory StreamPolicy.fromJson(Map<String, dynamic> json) => StreamPolicy(
+        blockPattern: json['block_pattern'] == null ? null : json['block_pattern'] as String,
+        maxFileSizeMb: json['max_file_size_mb'] == null ? null : (json['max_file_size_mb'] as num).toInt(),
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (blockPattern != null) 'block_pattern': blockPattern,
+        if (maxFileSizeMb != null) 'max_file_size_mb': maxFileSizeMb,
+      };
+}
+
+class StreamProfile {
+  const StreamProfile({
+    this.name,
+    this.nowait,
+    this.readahead,
+    this.tailPrefetch,
+  });
+
+  final String? name;
+  final bool? nowait;
+  final int? readahead;
+  final int? tailPrefetch;
+
+  factory StreamProfile.fromJson(Map<String, dynamic> json) => StreamProfile(
+        name: json['name'] == null ? null : json['name'] as String,
+        nowait: json['nowait'] == null ? null : json['nowait'] as bool,
+        readahead: json['readahead'] == null ? null : (json['readahead'] as num).toInt(),
+        tailPrefetch: json['tail_prefetch'] == null ? null : (json['tail_prefetch'] as num).toInt(),
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (name != null) 'name': name,
+        if (nowait != null) 'nowait': nowait,
+        if (readahead != null) 'readahead': readahead,
+        if (tailPrefetch != null) 'tail_prefetch': tailPrefetch,
+      };
+}
+
+class StreamProfilesResponse {
+  const StreamProfilesResponse({
+    this.detected,
+    this.profiles,
+  });
+
+  final String? detected;
+  final List<StreamProfile>? profiles;
+
+  factory StreamProfilesResponse.fromJson(Map<String, dynamic> json) => StreamProfilesResponse(
+        detected: json['detected'] == null ? null : json['detected'] as String,
+        profiles: json['profiles'] == null ? null : (json['profiles'] as List).map((e) => StreamProfile.fromJson(e as Map<String, dynamic>)).toList(),
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (detected != null) 'detected': detected,
+        if (profiles != null) 'profiles': profiles!.map((e) => e.toJson()).toList(),
+      };
+}
+
+class SubtitleEntry {
+  const SubtitleEntry({
+    this.companion,
+    this.format,
+    this.index,
+    this.lang,
+    this.path,
+    this.progress,
+    this.size,
+    this.url,
+    this.vttUrl,
+  });
+
+  final bool? companion;
+  final String? format;
+  final int? index;
+  final String? lang;
+  final String? path;
+  final double? progress;
+  final int? size;
+  final String? url;
+  final String? vttUrl;
+
+  factory SubtitleEntry.fromJson(Map<String, dynamic> json) => SubtitleEntry(
+        companion: json['companion'] == null ? null : json['companion'] as bool,
+        format: json['format'] == null ? null : json['format'] as String,
+        index: json['index'] == null ? null : (json['index'] as num).toInt(),
+        lang: json['lang'] == null ? null : json['lang'] as String,
+        path: json['path'] == null ? null : json['path'] as String,
+        progress: json['progress'] == null ? null : (json['progress'] as num).toDouble(),
+        size: json['size'] == null ? null : (json['size'] as num).toInt(),
+        url: json['url'] == null ? null : json['url'] as String,
+        vttUrl: json['vtt_url'] == null ? null : json['vtt_url'] as String,
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (companion != null) 'companion': companion,
+        if (format != null) 'format': format,
+        if (index != null) 'index': index,
+        if (lang != null) 'lang': lang,
+        if (path != null) 'path': path,
+        if (progress != null) 'progress': progress,
+        if (size != null) 'size': size,
+        if (url != null) 'url': url,
+        if (vttUrl != null) 'vtt_url': vttUrl,
+      };
+}
+
+class SubtitlesResponse {
+  const SubtitlesResponse({
+    this.subtitles,
+  });
+
+  final List<SubtitleEntry>? subtitles;
+
+  factory SubtitlesResponse.fromJson(Map<String, dynamic> json) => SubtitlesResponse(
+        subtitles: json['subtitles'] == null ? null : (json['subtitles'] as List).map((e) => SubtitleEntry.fromJson(e as Map<String, dynamic>)).toList(),
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (subtitles != null) 'subtitles': subtitles!.map((e) => e.toJson()).toList(),
+      };
+}
+
+class SwarmSnapshot {
+  const SwarmSnapshot({
+    this.availability,
+    this.have,
+    this.infoHash,
+    this.magnet,
+    this.numPieces,
+    this.peers,
+    this.taken,
+  });
+
+  final List<int>? availability;
+  final String? have;
+  final String? infoHash;
+  final String? magnet;
+  final int? numPieces;
+  final List<String>? peers;
+  final DateTime? taken;
+
+  factory SwarmSnapshot.fromJson(Map<String, dynamic> json) => SwarmSnapshot(
+        availability: json['availability'] == null ? null : (json['availability'] as List).map((e) => (e as num).toInt()).toList(),
+        have: json['have'] == null ? null : json['have'] as String,
+        infoHash: json['info_hash'] == null ? null : json['info_hash'] as String,
+        magnet: json['magnet'] == null ? null : json['magnet'] as String,
+        numPieces: json['num_pieces'] == null ? null : (json['num_pieces'] as num).toInt(),
+        peers: json['peers'] == null ? null : (json['peers'] as List).map((e) => e as String).toList(),
+        taken: json['taken'] == null ? null : DateTime.parse(json['taken'] as String),
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (availability != null) 'availability': availability,
+        if (have != null) 'have': have,
+        if (infoHash != null) 'info_hash': infoHash,
+        if (magnet != null) 'magnet': magnet,
+        if (numPieces != null) 'num_pieces': numPieces,
+        if (peers != null) 'peers': peers,
+        if (taken != null) 'taken': taken!.toIso8601String(),
+      };
+}
+
+class TeeStatus {
+  const TeeStatus({
+    this.complete,
+    this.path,
+    this.size,
+    this.written,
+  });
+
+  final bool? complete;
+  final String? path;
+  final int? size;
+  final int? written;
+
+  factory TeeStatus.fromJson(Map<String, dynamic> json) => TeeStatus(
+        complete: json['complete'] == null ? null : json['complete'] as bool,
+        path: json['path'] == null ? null : json['path'] as String,
+        size: json['size'] == null ? null : (json['size'] as num).toInt(),
+        written: json['written'] == null ? null : (json['written'] as num).toInt(),
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (complete != null) 'complete': complete,
+        if (path != null) 'path': path,
+        if (size != null) 'size': size,
+        if (written != null) 'written': written,
+      };
+}
+
+class TelemetrySettings {
+  const TelemetrySettings({
+    this.enabled,
+    this.endpoint,
+    this.installId,
+  });
+
+  final bool? enabled;
+  final String? endpoint;
+  final String? installId;
+
+  factory TelemetrySettings.fromJson(Map<String, dynamic> json) => TelemetrySettings(
+        enabled: json['enabled'] == null ? null : json['enabled'] as bool,
+        endpoint: json['endpoint'] == null ? null : json['endpoint'] as String,
+        installId: json['install_id'] == null ? null : json['install_id'] as String,
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (enabled != null) 'enabled': enabled,
+        if (endpoint != null) 'endpoint': endpoint,
+        if (installId != null) 'install_id': installId,
+      };
+}
+
+class TokenCreated {
+  const TokenCreated({
+    this.created,
+    this.hash,
+    this.name,
+    this.scope,
+    this.token,
+  });
+
+  final DateTime? created;
+  final String? hash;
+  final String? name;
+  final String? scope;
+  final String? token;
+
+  factory TokenCreated.fromJson(Map<String, dynamic> json) => TokenCreated(
+        created: json['created'] == null ? null : DateTime.parse(json['created'] as String),
+        hash: json['hash'] == null ? null : json['hash'] as String,
+        name: json['name'] == null ? null : json['name'] as String,
+        scope: json['scope'] == null ? null : json['scope'] as String,
+        token: json['token'] == null ? null : json['token'] as String,
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (created != null) 'created': created!.toIso8601String(),
+        if (hash != null) 'hash': hash,
+        if (name != null) 'name': name,
+        if (scope != null) 'scope': scope,
+        if (token != null) 'token': token,
+      };
+}
+
+class TorrentEntry {
+  const TorrentEntry({
+    this.file,
+    this.infoHash,
+    this.name,
+    this.primary,
+    this.status,
+  });
+
+  final String? file;
+  final String? infoHash;
+  final String? name;
+  final bool? primary;
+  final StatusResponse? status;
+
+  factory TorrentEntry.fromJson(Map<String, dynamic> json) => TorrentEntry(
+        file: json['file'] == null ? null : json['file'] as String,
+        infoHash: json['info_hash'] == null ? null : json['info_hash'] as String,
+        name: json['name'] == null ? null : json['name'] as String,
+        primary: json['primary'] == null ? null : json['primary'] as bool,
+        status: json['status'] == null ? null : StatusResponse.fromJson(json['status'] as Map<String, dynamic>),
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (file != null) 'file': file,
+        if (infoHash != null) 'info_hash': infoHash,
+        if (name != null) 'name': name,
+        if (primary != null) 'primary': primary,
+        if (status != null) 'status': status!.toJson(),
+      };
+}
+
+class TrackersConfig {
+  const TrackersConfig({
+    this.trackers,
+  });
+
+  final List<String>? trackers;
+
+  factory TrackersConfig.fromJson(Map<String, dynamic> json) => TrackersConfig(
+        trackers: json['trackers'] == null ? null : (json['trackers'] as List).map((e) => e as String).toList(),
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (trackers != null) 'trackers': trackers,
+      };
+}
+
+class TrackersResponse {
+  const TrackersResponse({
+    this.file,
+    this.filePath,
+    this.trackers,
+  });
+
+  final List<String>? file;
+  final String? filePath;
+  final List<String>? trackers;
+
+  factory TrackersResponse.fromJson(Map<String, dynamic> json) => TrackersResponse(
+        file: json['file'] == null ? null : (json['file'] as List).map((e) => e as String).toList(),
+        filePath: json['file_path'] == null ? null : json['file_path'] as String,
+        trackers: json['trackers'] == null ? null : (json['trackers'] as List).map((e) => e as String).toList(),
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (file != null) 'file': file,
+        if (filePath != null) 'file_path': filePath,
+        if (trackers != null) 'trackers': trackers,
+      };
+}
+
+class TreeDir {
+  const TreeDir({
+    this.completed,
+    this.dirs,
+    this.fileCount,
+    this.files,
+    this.name,
+    this.path,
+    this.progress,
+    this.size,
+    this.truncated,
+  });
+
+  final int? completed;
+  final List<TreeDir>? dirs;
+  final int? fileCount;
+  final List<FileEntry>? files;
+  final String? name;
+  final String? path;
+  final double? progress;
+  final int? size;
+  final bool? truncated;
+
+  factory TreeDir.fromJson(Map<String, dynamic> json) => TreeDir(
+        completed: json['completed'] == null ? null : (json['completed'] as num).toInt(),
+        dirs: json['dirs'] == null ? null : (json['dirs'] as List).map((e) => TreeDir.fromJson(e as Map<String, dynamic>)).toList(),
+        fileCount: json['file_count'] == null ? null : (json['file_count'] as num).toInt(),
+        files: json['files'] == null ? null : (json['files'] as List).map((e) => FileEntry.fromJson(e as Map<String, dynamic>)).toList(),
+        name: json['name'] == null ? null : json['name'] as String,
+        path: json['path'] == null ? null : json['path'] as String,
+        progress: json['progress'] == null ? null : (json['progress'] as num).toDouble(),
+        size: json['size'] == null ? null : (json['size'] as num).toInt(),
+        truncated: json['truncated'] == null ? null : json['truncated'] as bool,
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (completed != null) 'completed': completed,
+        if (dirs != null) 'dirs': dirs!.map((e) => e.toJson()).toList(),
+        if (fileCount != null) 'file_count': fileCount,
+        if (files != null) 'files': files!.map((e) => e.toJson()).toList(),
+        if (name != null) 'name': name,
+        if (path != null) 'path': path,
+        if (progress != null) 'progress': progress,
+        if (size != null) 'size': size,
+        if (truncated != null) 'truncated': truncated,
+      };
+}
+
+class WatermarkSpec {
+  const WatermarkSpec({
+    this.fontFile,
+    this.opacity,
+    this.position,
+    this.text,
+  });
+
+  final String? fontFile;
+  final double? opacity;
+  final String? position;
+  final String? text;
+
+  factory WatermarkSpec.fromJson(Map<String, dynamic> json) => WatermarkSpec(
+        fontFile: json['font_file'] == null ? null : json['font_file'] as String,
+        opacity: json['opacity'] == null ? null : (json['opacity'] as num).toDouble(),
+        position: json['position'] == null ? null : json['position'] as String,
+        text: json['text'] == null ? null : json['text'] as String,
+      );
+
+  Map<String, dynamic> toJson() => {
+        if (fontFile != null) 'font_file': fontFile,
+        if (opacity != null) 'opacity': opacity,
+        if (position != null) 'position': position,
+        if (text != null) 'text': text,
+      };
+}
+
+/// Thrown for non-2xx responses; message is the server's text/plain error.
+class RoxboxApiException implements Exception {
+  RoxboxApiException(this.statusCode, this.message);
+
+  final int statusCode;
+  final String message;
+
+  @override
+  String toString() => 'RoxboxApiException($statusCode): $message';
+}
+
+/// Typed access to every documented endpoint. [profile] scopes all calls to
+/// one profile namespace; [token] is an API token from /tokens, needed from
+/// other devices once the server has any.
+class RoxboxApi {
+  RoxboxApi(this.baseUrl, {this.profile, this.token, http.Client? httpClient})
+      : _http = httpClient ?? http.Client();
+
+  final Uri baseUrl;
+  final String? profile;
+  final String? token;
+  final http.Client _http;
+
+  void close() => _http.close();
+
+  Uri _uri(String path, Map<String, Object?> query) {
+    final q = <String, dynamic>{};
+    query.forEach((k, v) {
+      if (v is List) {
+        q[k] = v.map((e) => e.toString()).toList();
+      } else if (v != null) {
+        q[k] = v.toString();
+      }
+    });
+    if (profile != null) q['profile'] = profile!;
+    return baseUrl.replace(
+      path: baseUrl.path.replaceAll(RegExp(r'/$'), '') + path,
+      queryParameters: q.isEmpty ? null : q,
+    );
+  }
+
+  Future<String> _send(String method, String path, Map<String, Object?> query,
+      {Object? body, Map<String, String?> headers = const {}}) async {
+    final req = http.Request(method, _uri(path, query));
+    headers.forEach((k, v) {
+      if (v != null) req.headers[k] = v;
+    });
+    if (token != null) req.headers['Authorization'] = 'Bearer $token';
+    if (body != null) {
+      req.headers['Content-Type'] = 'application/json';
+      req.body = jsonEncode(body);
+    }
+    final resp = await http.Response.fromStream(await _http.send(req));
+    if (resp.statusCode < 200 || resp.statusCode > 299) {
+      throw RoxboxApiException(resp.statusCode, resp.body.trim());
+    }
+    return resp.body;
+  }
+
+
+  /// Start streaming a magnet, .torrent or direct video URL (replaces the profile's session); params, a JSON body, a raw application/x-bittorrent body or a multipart form with a torrent file part. Answers status loading, superseded (a newer add won) or exists (the session already has this torrent and file; info_hash, state and stream_url included) and the add_id
+  Future<Map<String, dynamic>> postAdd({String? idempotencyKey, String? magnet, String? url, String? cookie, String? authorization, String? tracker, String? peer, String? fallback, String? swarm, String? file, String? title, String? episode, AddRequestJSON? body}) async {
+    final body_ = await _send('POST', '/add', {'magnet': magnet, 'url': url, 'cookie': cookie, 'authorization': authorization, 'tracker': tracker, 'peer': peer, 'fallback': fallback, 'swarm': swarm, 'file': file, 'title': title, 'episode': episode}, body: body == null ? null : body.toJson(), headers: {'Idempotency-Key': idempotencyKey});
+    return (jsonDecode(body_) as Map).map((k, v) => MapEntry(k as String, v));
+  }
+
+  /// Stream a video already on disk through /stream and /files (replaces the profile's session)
+  Future<Map<String, dynamic>> postAddLocal({required String path}) async {
+    final body_ = await _send('POST', '/add/local', {'path': path});
+    return (jsonDecode(body_) as Map).map((k, v) => MapEntry(k as String, v));
+  }
+
+  /// Resolve a page, magnet or .torrent URL and start streaming it
+  Future<Map<String, dynamic>> postAddUrl({required String url, String? selector, String? cookie, String? authorization}) async {
+    final body_ = await _send('POST', '/add/url', {'url': url, 'selector': selector, 'cookie': cookie, 'authorization': authorization});
+    return (jsonDecode(body_) as Map).map((k, v) => MapEntry(k as String, v));
+  }
+
+  /// BitTorrent tracker announce (BEP 3); needs -tracker
+  Future<String> getAnnounce({required String infoHash, required String peerId, required int port, int? left, String? event, int? compact, int? numwant}) async {
+    return await _send('GET', '/announce', {'info_hash': infoHash, 'peer_id': peerId, 'port': port, 'left': left, 'event': event, 'compact': compact, 'numwant': numwant});
+  }
+
+  /// Banned peers, newest first: the client's bans for corrupt data or protocol violations and manual ones
+  Future<BansState> getBans() async {
+    final body_ = await _send('GET', '/bans', {});
+    return BansState.fromJson(jsonDecode(body_) as Map<String, dynamic>);
+  }
+
+  /// Ban a peer IP (a week unless duration says otherwise) and close its connections
+  Future<BansState> postBans({required BanRequest body}) async {
+    final body_ = await _send('POST', '/bans', {}, body: body.toJson());
+    return BansState.fromJson(jsonDecode(body_) as Map<String, dynamic>);
+  }
+
+  /// Lift a ban
+  Future<BansState> deleteBans({required String ip}) async {
+    final body_ = await _send('DELETE', '/bans', {'ip': ip});
+    return BansState.fromJson(jsonDecode(body_) as Map<String, dynamic>);
+  }
+
+  /// Optional features compiled into this binary and whether they are usable now
+  Future<CapabilitiesResponse> getCapabilities() async {
+    final body_ = await _send('GET', '/capabilities', {});
+    return CapabilitiesResponse.fromJson(jsonDecode(body_) as Map<String, dynamic>);
+  }
+
+  /// Recent request spans and server events, oldest first
+  Future<List<Event>> getDebugEvents({String? requestId, String? kind, int? since, int? limit}) async {
+    final body_ = await _send('GET', '/debug/events', {'request_id': requestId, 'kind': kind, 'since': since, 'limit': limit});
+    return (jsonDecode(body_) as List).map((e) => Event.fromJson(e as Map<String, dynamic>)).toList();
+  }
+
+  /// Current log level per module
+  Future<Map<String, String>> getDebugLoglevel() async {
+    final body_ = await _send('GET', '/debug/loglevel', {});
+    return (jsonDecode(body_) as Map).map((k, v) => MapEntry(k as String, v as String));
+  }
+
+  /// Change one module's log level at runtime
+  Future<Map<String, String>> postDebugLoglevel({required String module, required String level}) async {
+    final body_ = await _send('POST', '/debug/loglevel', {'module': module, 'level': level});
+    return (jsonDecode(body_) as Map).map((k, v) => MapEntry(k as String, v as String));
+  }
+
+  /// Effective priority of every piece of the active file, with each /stream reader's offset and readahead
+  Future<PrioritiesResponse> getDebugPriorities() async {
+    final body_ = await _send('GET', '/debug/priorities', {});
+    return PrioritiesResponse.fromJson(jsonDecode(body_) as Map<String, dynamic>);
+  }
+
+  /// Range request patterns per player User-Agent: open probes, tail reads, seek distribution
+  Future<List<RangeAgentStats>> getDebugRangestats() async {
+    final body_ = await _send('GET', '/debug/rangestats', {});
+    return (jsonDecode(body_) as List).map((e) => RangeAgentStats.fromJson(e as Map<String, dynamic>)).toList();
+  }
+
+  /// Reset the range statistics
+  Future<String> deleteDebugRangestats() async {
+    return await _send('DELETE', '/debug/rangestats', {});
+  }
+
+  /// Disk I/O throttle (background writes and hashing paced while a player reads), request pipeline depths and Go runtime counters
+  Future<DebugStats> getDebugStats() async {
+    final body_ = await _send('GET', '/debug/stats', {});
+    return DebugStats.fromJson(jsonDecode(body_) as Map<String, dynamic>);
+  }
+
+  /// Every file of the torrent as a ZIP archive (stored, not compressed), written as the pieces complete; no Content-Length; 403 while a watermark is set
+  Uri getDownloadZipUri() => _uri('/download.zip', {});
+
+  /// List export jobs
+  Future<List<ExportJob>> getExport() async {
+    final body_ = await _send('GET', '/export', {});
+    return (jsonDecode(body_) as List).map((e) => ExportJob.fromJson(e as Map<String, dynamic>)).toList();
+  }
+
+  /// Export the active file; resumes from its journal
+  Future<List<ExportJob>> postExport({required String dest, String? idempotencyKey}) async {
+    final body_ = await _send('POST', '/export', {'dest': dest}, headers: {'Idempotency-Key': idempotencyKey});
+    return (jsonDecode(body_) as List).map((e) => ExportJob.fromJson(e as Map<String, dynamic>)).toList();
+  }
+
+  /// Cancel an export
+  Future<String> deleteExport({required String dest, String? idempotencyKey}) async {
+    return await _send('DELETE', '/export', {'dest': dest}, headers: {'Idempotency-Key': idempotencyKey});
+  }
+
+  /// Every file of the session's torrent with its type and progress; the streamed one has role video, its paired subtitle/audio files their kind
+  Future<FilesResponse> getFiles() async {
+    final body_ = await _send('GET', '/files', {});
+    return FilesResponse.fromJson(jsonDecode(body_) as Map<String, dynamic>);
+  }
+
+  /// A file of the torrent by index; supports Range requests (the streamed file is re-encoded without them while a watermark is set)
+  Uri getFilesRawUri({required int index}) => _uri('/files/raw', {'index': index});
+
+  /// Export a playback handoff bundle
+  Future<HandoffBundle> getHandoff() async {
+    final body_ = await _send('GET', '/handoff', {});
+    return HandoffBundle.fromJson(jsonDecode(body_) as Map<String, dynamic>);
+  }
+
+  /// Continue playback from another device's bundle
+  Future<Map<String, dynamic>> postHandoff({required HandoffBundle body}) async {
+    final body_ = await _send('POST', '/handoff', {}, body: body.toJson());
+    return (jsonDecode(body_) as Map).map((k, v) => MapEntry(k as String, v));
+  }
+
+  /// Liveness probe (every response carries X-Roxbox-Instance, this process's UUID)
+  Future<String> getHealth() async {
+    return await _send('GET', '/health', {});
+  }
+
+  /// Torrent and piece layout details
+  Future<InfoResponse> getInfo() async {
+    final body_ = await _send('GET', '/info', {});
+    return InfoResponse.fromJson(jsonDecode(body_) as Map<String, dynamic>);
+  }
+
+  /// Fetch a magnet's metadata only and list its files, without downloading data or touching the session
+  Future<InspectResponse> getInspect({required String magnet, String? tracker, String? title, String? episode, String? timeout}) async {
+    final body_ = await _send('GET', '/inspect', {'magnet': magnet, 'tracker': tracker, 'title': title, 'episode': episode, 'timeout': timeout});
+    return InspectResponse.fromJson(jsonDecode(body_) as Map<String, dynamic>);
+  }
+
+  /// Container and codecs of the active file from its first pieces, with codec_warnings for codecs the device likely can't decode
+  Future<MediaInfo> getMediainfo({String? supports}) async {
+    final body_ = await _send('GET', '/mediainfo', {'supports': supports});
+    return MediaInfo.fromJson(jsonDecode(body_) as Map<String, dynamic>);
+  }
+
+  /// This document
+  Future<Map<String, dynamic>> getOpenapiJson() async {
+    final body_ = await _send('GET', '/openapi.json', {});
+    return (jsonDecode(body_) as Map).map((k, v) => MapEntry(k as String, v));
+  }
+
+  /// Decode and check a magnet without adding it: infohash, name, trackers, peer hints and what /add would reject
+  Future<ParseResponse> getParse({required String magnet}) async {
+    final body_ = await _send('GET', '/parse', {'magnet': magnet});
+    return ParseResponse.fromJson(jsonDecode(body_) as Map<String, dynamic>);
+  }
+
+  /// Start a watch party around the session's file with this instance as coordinator; answers the URL other instances join with; 409 for a private torrent. With a body, host a party for a coordinator elsewhere (as its relay); 503 when this instance hosts the most it will
+  Future<Map<String, dynamic>> postPartyCreate({String? relay, String? member, PartySetup? body}) async {
+    final body_ = await _send('POST', '/party/create', {'relay': relay, 'member': member}, body: body == null ? null : body.toJson());
+    return (jsonDecode(body_) as Map).map((k, v) => MapEntry(k as String, v));
+  }
+
+  /// Join a watch party: starts its file at the party clock's position and reports buffering to its host
+  Future<Map<String, dynamic>> postPartyJoin({required String url, String? member}) async {
+    final body_ = await _send('POST', '/party/join', {'url': url, 'member': member});
+    return (jsonDecode(body_) as Map).map((k, v) => MapEntry(k as String, v));
+  }
+
+  /// This device's side of its party: the party, where playback should be now and how far the player is off. With party=, the hosted party itself
+  Future<PartyView> getPartyState({String? party}) async {
+    final body_ = await _send('GET', '/party/state', {'party': party});
+    return PartyView.fromJson(jsonDecode(body_) as Map<String, dynamic>);
+  }
+
+  /// Coordinator: play, pause or seek the party. With party=, a member's report to the party's host, answered with the party
+  Future<PartyView> postPartyState({required PartyControl body, String? party}) async {
+    final body_ = await _send('POST', '/party/state', {'party': party}, body: body.toJson());
+    return PartyView.fromJson(jsonDecode(body_) as Map<String, dynamic>);
+  }
+
+  /// Leave the party; a coordinator hosting it ends it
+  Future<String> deletePartyState() async {
+    return await _send('DELETE', '/party/state', {});
+  }
+
+  /// Report player state; long pauses enter trickle mode
+  Future<String> postPlayerState({required String state, double? position, double? duration}) async {
+    return await _send('POST', '/player/state', {'state': state, 'position': position, 'duration': duration});
+  }
+
+  /// M3U8 playlist of the torrent's videos and audio files in episode order; each entry is /stream?file=<index>, which switches the session to it
+  Uri getPlaylistM3uUri() => _uri('/playlist.m3u', {});
+
+  /// Profile watch history
+  Future<List<HistoryEntry>> getProfileHistory() async {
+    final body_ = await _send('GET', '/profile/history', {});
+    return (jsonDecode(body_) as List).map((e) => HistoryEntry.fromJson(e as Map<String, dynamic>)).toList();
+  }
+
+  /// Clear profile watch history
+  Future<String> deleteProfileHistory() async {
+    return await _send('DELETE', '/profile/history', {});
+  }
+
+  /// Profile policies
+  Future<ProfileSettings> getProfileSettings() async {
+    final body_ = await _send('GET', '/profile/settings', {});
+    return ProfileSettings.fromJson(jsonDecode(body_) as Map<String, dynamic>);
+  }
+
+  /// Replace profile policies
+  Future<ProfileSettings> putProfileSettings({required ProfileSettings body}) async {
+    final body_ = await _send('PUT', '/profile/settings', {}, body: body.toJson());
+    return ProfileSettings.fromJson(jsonDecode(body_) as Map<String, dynamic>);
+  }
+
+  /// The profile's download queue: policy, concurrency and items with their position and session status
+  Future<QueueResponse> getQueue() async {
+    final body_ = await _send('GET', '/queue', {});
+    return QueueResponse.fromJson(jsonDecode(body_) as Map<String, dynamic>);
+  }
+
+  /// Queue torrents to download in background sessions, in policy order with at most concurrency running; a JSON body or repeated magnet params
+  Future<QueueResponse> postQueue({String? magnet, String? policy, int? concurrency, QueueAddRequest? body}) async {
+    final body_ = await _send('POST', '/queue', {'magnet': magnet, 'policy': policy, 'concurrency': concurrency}, body: body == null ? null : body.toJson());
+    return QueueResponse.fromJson(jsonDecode(body_) as Map<String, dynamic>);
+  }
+
+  /// Remove an item (stopping its download), or without id every item not downloading
+  Future<QueueResponse> deleteQueue({int? id}) async {
+    final body_ = await _send('DELETE', '/queue', {'id': id});
+    return QueueResponse.fromJson(jsonDecode(body_) as Map<String, dynamic>);
+  }
+
+  /// The connection to the remote control relay (-relay) and, with -relay-hub, the relay served here
+  Future<RelayStatus> getRelay() async {
+    final body_ = await _send('GET', '/relay', {});
+    return RelayStatus.fromJson(jsonDecode(body_) as Map<String, dynamic>);
+  }
+
+  /// Bring back the state of a /snapshot after a restart: settings are written and sessions added again at their position; ones already holding their torrent are left as they are
+  Future<List<RestoredSession>> postRestore({required SnapshotBlob body}) async {
+    final body_ = await _send('POST', '/restore', {}, body: body.toJson());
+    return (jsonDecode(body_) as List).map((e) => RestoredSession.fromJson(e as Map<String, dynamic>)).toList();
+  }
+
+  /// Feeds and queued items
+  Future<RssState> getRss() async {
+    final body_ = await _send('GET', '/rss', {});
+    return RssState.fromJson(jsonDecode(body_) as Map<String, dynamic>);
+  }
+
+  /// Add a feed
+  Future<RssFeed> postRss({required RssFeed body}) async {
+    final body_ = await _send('POST', '/rss', {}, body: body.toJson());
+    return RssFeed.fromJson(jsonDecode(body_) as Map<String, dynamic>);
+  }
+
+  /// Replace a feed
+  Future<RssFeed> putRss({required String id, required RssFeed body}) async {
+    final body_ = await _send('PUT', '/rss', {'id': id}, body: body.toJson());
+    return RssFeed.fromJson(jsonDecode(body_) as Map<String, dynamic>);
+  }
+
+  /// Remove a feed
+  Future<String> deleteRss({required String id}) async {
+    return await _send('DELETE', '/rss', {'id': id});
+  }
+
+  /// BitTorrent tracker scrape; needs -tracker
+  Future<String> getScrape({String? infoHash}) async {
+    return await _send('GET', '/scrape', {'info_hash': infoHash});
+  }
+
+  /// Names of stored secrets
+  Future<Map<String, dynamic>> getSecrets() async {
+    final body_ = await _send('GET', '/secrets', {});
+    return (jsonDecode(body_) as Map).map((k, v) => MapEntry(k as String, v));
+  }
+
+  /// Store a secret (requires ROXBOX_SECRET_KEY); cookie:<domain> and authorization:<domain> log fetches from that site in; passkey:<domain> goes into that tracker's announce URLs, replacing the old one in running torrents from their next announce
+  Future<String> putSecrets({required String name, required Map<String, dynamic> body}) async {
+    return await _send('PUT', '/secrets', {'name': name}, body: body);
+  }
+
+  /// Remove a secret
+  Future<String> deleteSecrets({required String name}) async {
+    return await _send('DELETE', '/secrets', {'name': name});
+  }
+
+  /// Buffered byte of the active file closest to offset, for snap-to-buffered seeking
+  Future<SeekNearest> getSeekNearest({required int offset}) async {
+    final body_ = await _send('GET', '/seek/nearest', {'offset': offset});
+    return SeekNearest.fromJson(jsonDecode(body_) as Map<String, dynamic>);
+  }
+
+  /// Stream another file of the torrent: its priorities, readahead and companions replace the old file's, and /status starts over for it. Answers /info
+  Future<InfoResponse> postSelect({int? file, String? pattern}) async {
+    final body_ = await _send('POST', '/select', {'file': file, 'pattern': pattern});
+    return InfoResponse.fromJson(jsonDecode(body_) as Map<String, dynamic>);
+  }
+
+  /// Swarm snapshot of the active session
+  Future<SwarmSnapshot> getSessionIdSwarmExport({required String id}) async {
+    final body_ = await _send('GET', '/session/${Uri.encodeComponent(id.toString())}/swarm/export', {});
+    return SwarmSnapshot.fromJson(jsonDecode(body_) as Map<String, dynamic>);
+  }
+
+  /// Every profile's sessions under the global limits (-max-active, -max-conns): downloading, queued with their position, paused, complete
+  Future<SessionsResponse> getSessions() async {
+    final body_ = await _send('GET', '/sessions', {});
+    return SessionsResponse.fromJson(jsonDecode(body_) as Map<String, dynamic>);
+  }
+
+  /// Every profile's policies and torrent sessions (file, position, options, peers) as an opaque string for the app's saved state
+  Future<SnapshotBlob> getSnapshot() async {
+    final body_ = await _send('GET', '/snapshot', {});
+    return SnapshotBlob.fromJson(jsonDecode(body_) as Map<String, dynamic>);
+  }
+
+  /// Session status; with changed_since, only the fields changed since that X-Status-Seq
+  Future<StatusResponse> getStatus({int? changedSince}) async {
+    final body_ = await _send('GET', '/status', {'changed_since': changedSince});
+    return StatusResponse.fromJson(jsonDecode(body_) as Map<String, dynamic>);
+  }
+
+  /// Long-poll until the status matches; X-Condition-Met tells whether it did
+  Future<StatusResponse> getStatusWait({String? state, double? minProgress, int? minPeers, String? timeout}) async {
+    final body_ = await _send('GET', '/status/wait', {'state': state, 'min_progress': minProgress, 'min_peers': minPeers, 'timeout': timeout});
+    return StatusResponse.fromJson(jsonDecode(body_) as Map<String, dynamic>);
+  }
+
+  /// Stop the profile's session
+  Future<String> postStop({String? idempotencyKey}) async {
+    return await _send('POST', '/stop', {}, headers: {'Idempotency-Key': idempotencyKey});
+  }
+
+  /// Selected file bytes; supports Range requests (not with a watermark)
+  Uri getStreamUri({double? t, String? player, int? file}) => _uri('/stream', {'t': t, 'player': player, 'file': file});
+
+  /// Player streaming profiles (readahead, tail prefetch, nowait) and the one this client is detected as
+  Future<StreamProfilesResponse> getStreamProfiles() async {
+    final body_ = await _send('GET', '/stream/profiles', {});
+    return StreamProfilesResponse.fromJson(jsonDecode(body_) as Map<String, dynamic>);
+  }
+
+  /// The subtitle files of the torrent (srt, ass, ssa, vtt, sub, idx), the streamed video's companions first
+  Future<SubtitlesResponse> getSubtitles() async {
+    final body_ = await _send('GET', '/subtitles', {});
+    return SubtitlesResponse.fromJson(jsonDecode(body_) as Map<String, dynamic>);
+  }
+
+  /// The text subtitle tracks (SubRip, ASS, WebVTT) inside the streamed Matroska video, read from its header and cues; indexed tracks (the cues point at their blocks) are extracted ahead of the video. An empty list with a note when the file isn't Matroska
+  Future<EmbeddedSubtitlesResponse> getSubtitlesEmbedded() async {
+    final body_ = await _send('GET', '/subtitles/embedded', {});
+    return EmbeddedSubtitlesResponse.fromJson(jsonDecode(body_) as Map<String, dynamic>);
+  }
+
+  /// An embedded subtitle track as a sidecar file in its own format (srt, ass or vtt), UTF-8. Holds the blocks downloaded so far and raises the pieces holding the rest; X-Roxbox-Complete says whether it is the whole track and X-Roxbox-Cues how many cues it has
+  Future<String> getSubtitlesEmbeddedTrack({required int track, bool? wait}) async {
+    return await _send('GET', '/subtitles/embedded/${Uri.encodeComponent(track.toString())}', {'wait': wait});
+  }
+
+  /// An embedded subtitle track as WebVTT (ASS converted without its styling), as /subtitles/embedded/{track}
+  Uri getSubtitlesEmbeddedTrackVttUri({required int track, bool? wait}) => _uri('/subtitles/embedded/${Uri.encodeComponent(track.toString())}.vtt', {'wait': wait});
+
+  /// A subtitle file by its /files index as UTF-8 text/plain, converted from the encoding it was saved in (X-Roxbox-Charset names it); the bitmap .sub of a VobSub pair is served as it is. Waits for the file to download
+  Future<String> getSubtitlesIndex({required int index}) async {
+    return await _send('GET', '/subtitles/${Uri.encodeComponent(index.toString())}', {});
+  }
+
+  /// A .srt or .vtt subtitle file as WebVTT for web-based players: SubRip is converted on the fly (cue timings rewritten, <font> and {\an8} tags dropped) after the same encoding detection as /subtitles/{index}; other formats answer 415
+  Uri getSubtitlesIndexVttUri({required int index}) => _uri('/subtitles/${Uri.encodeComponent(index.toString())}.vtt', {});
+
+  /// Stream tee status
+  Future<TeeStatus> getTee() async {
+    final body_ = await _send('GET', '/tee', {});
+    return TeeStatus.fromJson(jsonDecode(body_) as Map<String, dynamic>);
+  }
+
+  /// Mirror streamed bytes into a local file
+  Future<TeeStatus> postTee({required String path}) async {
+    final body_ = await _send('POST', '/tee', {'path': path});
+    return TeeStatus.fromJson(jsonDecode(body_) as Map<String, dynamic>);
+  }
+
+  /// Stop the tee (the partial .part file is kept)
+  Future<String> deleteTee() async {
+    return await _send('DELETE', '/tee', {});
+  }
+
+  /// Telemetry settings
+  Future<TelemetrySettings> getTelemetry() async {
+    final body_ = await _send('GET', '/telemetry', {});
+    return TelemetrySettings.fromJson(jsonDecode(body_) as Map<String, dynamic>);
+  }
+
+  /// Opt in to or out of anonymous crash and QoE reports
+  Future<TelemetrySettings> putTelemetry({required TelemetrySettings body}) async {
+    final body_ = await _send('PUT', '/telemetry', {}, body: body.toJson());
+    return TelemetrySettings.fromJson(jsonDecode(body_) as Map<String, dynamic>);
+  }
+
+  /// API tokens (names and scopes). Once one exists, requests from other hosts need a token: Authorization: Bearer or token=
+  Future<List<ApiToken>> getTokens() async {
+    final body_ = await _send('GET', '/tokens', {});
+    return (jsonDecode(body_) as List).map((e) => ApiToken.fromJson(e as Map<String, dynamic>)).toList();
+  }
+
+  /// Create a token; the answer is the only time it is shown. A read token may only GET status, files and streams
+  Future<TokenCreated> postTokens({required Map<String, dynamic> body}) async {
+    final body_ = await _send('POST', '/tokens', {}, body: body);
+    return TokenCreated.fromJson(jsonDecode(body_) as Map<String, dynamic>);
+  }
+
+  /// Revoke a token
+  Future<List<ApiToken>> deleteTokens({required String name}) async {
+    final body_ = await _send('DELETE', '/tokens', {'name': name});
+    return (jsonDecode(body_) as List).map((e) => ApiToken.fromJson(e as Map<String, dynamic>)).toList();
+  }
+
+  /// Every torrent session of the profile, primary first
+  Future<List<TorrentEntry>> getTorrents() async {
+    final body_ = await _send('GET', '/torrents', {});
+    return (jsonDecode(body_) as List).map((e) => TorrentEntry.fromJson(e as Map<String, dynamic>)).toList();
+  }
+
+  /// Add a magnet in a background session, next to the primary one; same params as /add. Answers info_hash, stream_url, add_id and status loading, superseded or exists
+  Future<Map<String, dynamic>> postTorrents({String? magnet, String? url, String? cookie, String? authorization, String? tracker, String? peer, String? swarm, String? file, String? title, String? episode, AddRequestJSON? body}) async {
+    final body_ = await _send('POST', '/torrents', {'magnet': magnet, 'url': url, 'cookie': cookie, 'authorization': authorization, 'tracker': tracker, 'peer': peer, 'swarm': swarm, 'file': file, 'title': title, 'episode': episode}, body: body == null ? null : body.toJson());
+    return (jsonDecode(body_) as Map).map((k, v) => MapEntry(k as String, v));
+  }
+
+  /// Every file of the session holding hash as a ZIP archive, as /download.zip
+  Uri getTorrentsHashDownloadZipUri({required String hash}) => _uri('/torrents/${Uri.encodeComponent(hash.toString())}/download.zip', {});
+
+  /// The session's .torrent (bencoded metainfo), once its metadata is resolved; 409 before; needs a full token, as it carries tracker passkeys
+  Uri getTorrentsHashExportTorrentUri({required String hash}) => _uri('/torrents/${Uri.encodeComponent(hash.toString())}/export.torrent', {});
+
+  /// Every file of the session holding hash, as /files
+  Future<FilesResponse> getTorrentsHashFiles({required String hash}) async {
+    final body_ = await _send('GET', '/torrents/${Uri.encodeComponent(hash.toString())}/files', {});
+    return FilesResponse.fromJson(jsonDecode(body_) as Map<String, dynamic>);
+  }
+
+  /// A file of the session holding hash by index; supports Range requests
+  Uri getTorrentsHashFilesRawUri({required String hash, required int index}) => _uri('/torrents/${Uri.encodeComponent(hash.toString())}/files/raw', {'index': index});
+
+  /// Dial known peers for the session holding hash; a JSON body {"peers": ["host:port", …]} or repeated peer params. Answers added and posted counts
+  Future<Map<String, int>> postTorrentsHashPeers({required String hash, String? peer}) async {
+    final body_ = await _send('POST', '/torrents/${Uri.encodeComponent(hash.toString())}/peers', {'peer': peer});
+    return (jsonDecode(body_) as Map).map((k, v) => MapEntry(k as String, (v as num).toInt()));
+  }
+
+  /// M3U8 playlist of the session holding hash, as /playlist.m3u
+  Uri getTorrentsHashPlaylistM3uUri({required String hash}) => _uri('/torrents/${Uri.encodeComponent(hash.toString())}/playlist.m3u', {});
+
+  /// Stream another file of the session holding hash, as /select
+  Future<InfoResponse> postTorrentsHashSelect({required String hash, int? file, String? pattern}) async {
+    final body_ = await _send('POST', '/torrents/${Uri.encodeComponent(hash.toString())}/select', {'file': file, 'pattern': pattern});
+    return InfoResponse.fromJson(jsonDecode(body_) as Map<String, dynamic>);
+  }
+
+  /// Status of the session holding hash
+  Future<StatusResponse> getTorrentsHashStatus({required String hash, int? changedSince}) async {
+    final body_ = await _send('GET', '/torrents/${Uri.encodeComponent(hash.toString())}/status', {'changed_since': changedSince});
+    return StatusResponse.fromJson(jsonDecode(body_) as Map<String, dynamic>);
+  }
+
+  /// Stop the session holding hash; a background session is removed
+  Future<String> postTorrentsHashStop({required String hash}) async {
+    return await _send('POST', '/torrents/${Uri.encodeComponent(hash.toString())}/stop', {});
+  }
+
+  /// Selected file bytes of the session holding hash; supports Range requests
+  Uri getTorrentsHashStreamUri({required String hash, String? player, int? file}) => _uri('/torrents/${Uri.encodeComponent(hash.toString())}/stream', {'player': player, 'file': file});
+
+  /// The subtitle files of the session holding hash, as /subtitles
+  Future<SubtitlesResponse> getTorrentsHashSubtitles({required String hash}) async {
+    final body_ = await _send('GET', '/torrents/${Uri.encodeComponent(hash.toString())}/subtitles', {});
+    return SubtitlesResponse.fromJson(jsonDecode(body_) as Map<String, dynamic>);
+  }
+
+  /// The text subtitle tracks inside the Matroska video of the session holding hash, as /subtitles/embedded
+  Future<EmbeddedSubtitlesResponse> getTorrentsHashSubtitlesEmbedded({required String hash}) async {
+    final body_ = await _send('GET', '/torrents/${Uri.encodeComponent(hash.toString())}/subtitles/embedded', {});
+    return EmbeddedSubtitlesResponse.fromJson(jsonDecode(body_) as Map<String, dynamic>);
+  }
+
+  /// An embedded subtitle track of the session holding hash as a sidecar file, as /subtitles/embedded/{track}
+  Future<String> getTorrentsHashSubtitlesEmbeddedTrack({required String hash, required int track, bool? wait}) async {
+    return await _send('GET', '/torrents/${Uri.encodeComponent(hash.toString())}/subtitles/embedded/${Uri.encodeComponent(track.toString())}', {'wait': wait});
+  }
+
+  /// An embedded subtitle track of the session holding hash as WebVTT, as /subtitles/embedded/{track}.vtt
+  Uri getTorrentsHashSubtitlesEmbeddedTrackVttUri({required String hash, required int track, bool? wait}) => _uri('/torrents/${Uri.encodeComponent(hash.toString())}/subtitles/embedded/${Uri.encodeComponent(track.toString())}.vtt', {'wait': wait});
+
+  /// A subtitle file of the session holding hash as UTF-8 text, as /subtitles/{index}
+  Future<String> getTorrentsHashSubtitlesIndex({required String hash, required int index}) async {
+    return await _send('GET', '/torrents/${Uri.encodeComponent(hash.toString())}/subtitles/${Uri.encodeComponent(index.toString())}', {});
+  }
+
+  /// A .srt or .vtt subtitle of the session holding hash as WebVTT, as /subtitles/{index}.vtt
+  Uri getTorrentsHashSubtitlesIndexVttUri({required String hash, required int index}) => _uri('/torrents/${Uri.encodeComponent(hash.toString())}/subtitles/${Uri.encodeComponent(index.toString())}.vtt', {});
+
+  /// The files of the session holding hash as a directory tree, as /tree
+  Future<TreeDir> getTorrentsHashTree({required String hash, String? path, int? depth}) async {
+    final body_ = await _send('GET', '/torrents/${Uri.encodeComponent(hash.toString())}/tree', {'path': path, 'depth': depth});
+    return TreeDir.fromJson(jsonDecode(body_) as Map<String, dynamic>);
+  }
+
+  /// Extra trackers added to every torrent: the configured list and the list file's
+  Future<TrackersResponse> getTrackers() async {
+    final body_ = await _send('GET', '/trackers', {});
+    return TrackersResponse.fromJson(jsonDecode(body_) as Map<String, dynamic>);
+  }
+
+  /// Replace the configured extra trackers; running torrents get them too
+  Future<TrackersResponse> putTrackers({required TrackersConfig body}) async {
+    final body_ = await _send('PUT', '/trackers', {}, body: body.toJson());
+    return TrackersResponse.fromJson(jsonDecode(body_) as Map<String, dynamic>);
+  }
+
+  /// The files of /files as a directory tree; each directory has the size, bytes on disk, progress and file count of everything under it
+  Future<TreeDir> getTree({String? path, int? depth}) async {
+    final body_ = await _send('GET', '/tree', {'path': path, 'depth': depth});
+    return TreeDir.fromJson(jsonDecode(body_) as Map<String, dynamic>);
+  }
+
+  /// The session's burned-in text overlay
+  Future<WatermarkSpec> getWatermark() async {
+    final body_ = await _send('GET', '/watermark', {});
+    return WatermarkSpec.fromJson(jsonDecode(body_) as Map<String, dynamic>);
+  }
+
+  /// Burn a text overlay into /stream (and /files/raw of the streamed file) via ffmpeg; font_file names a font in the -fonts directory
+  Future<WatermarkSpec> putWatermark({required WatermarkSpec body}) async {
+    final body_ = await _send('PUT', '/watermark', {}, body: body.toJson());
+    return WatermarkSpec.fromJson(jsonDecode(body_) as Map<String, dynamic>);
+  }
+
+  /// Remove the overlay
+  Future<String> deleteWatermark() async {
+    return await _send('DELETE', '/watermark', {});
+  }
+}
@@ -0,0 +1,101 @@
+import 'dart:async';
+import 'dart:convert';
+
+import 'package:web_socket_channel/web_socket_channel.dart';
+
+import 'api.g.dart';
+
+/// Follows a profile's status over the /status/ws WebSocket.
+///
+/// The server sends deltas; the listener applies them and emits the whole
+/// [StatusResponse] each time. When the connection drops it reconnects with
+/// exponential backoff, resuming from the last sequence number so only the
+/// fields that changed in between are re-sent.
+class StatusListener {
+  StatusListener(
+    this.baseUrl, {
+    this.profile,
+    this.minBackoff = const Duration(milliseconds: 500),
+    this.maxBackoff = const Duration(seconds: 15),
+  });
+
+  /// The server's HTTP base URL, e.g. http://127.0.0.1:8888.
+  final Uri baseUrl;
+  final String? profile;
+  final Duration minBackoff;
+  final Duration maxBackoff;
+
+  final _controller = StreamController<StatusResponse>.broadcast();
+  final _fields = <String, dynamic>{};
+  WebSocketChannel? _channel;
+  StreamSubscription<dynamic>? _sub;
+  Timer? _retry;
+  int? _seq;
+  Duration? _backoff;
+  bool _closed = false;
+
+  /// Status updates; starts the connection on first use.
+  Stream<StatusResponse> get stream {
+    if (_channel == null && _retry == null && !_closed) _connect();
+    return _controller.stream;
+  }
+
+  /// The last status received, or null before the first message.
+  StatusResponse? get current =>
+      _fields.isEmpty ? null : StatusResponse.fromJson(_fields);
+
+  Uri get _uri {
+    final q = <String, String>{
+      if (_seq != null) 'changed_since': '$_seq',
+      if (profile != null) 'profile': profile!,
+    };
+    return baseUrl.replace(
+      scheme: baseUrl.scheme == 'https' ? 'wss' : 'ws',
+      path: '${baseUrl.path.replaceAll(RegExp(r'/$'), '')}/status/ws',
+      queryParameters: q.isEmpty ? null : q,
+    );
+  }
+
+  void _connect() {
+    _retry = null;
+    final channel = WebSocketChannel.connect(_uri);
+    _channel = channel;
+    _sub = channel.stream.listen(
+      _onMessage,
+      onError: (_) => _reconnect(),
+      onDone: _reconnect,
+      cancelOnError: true,
+    );
+  }
+
+  void _onMessage(dynamic data) {
+    _backoff = null; // healthy again
+    final delta = StatusDelta.fromJson(jsonDecode(data as String) as Map<String, dynamic>);
+    if (delta.full == true) _fields.clear();
+    _fields.addAll(delta.changed ?? const {});
+    for (final k in delta.removed ?? const <String>[]) {
+      _fields.remove(k);
+    }
+    _seq = delta.seq;
+    _controller.add(StatusResponse.fromJson(_fields));
+  }
+
+  void _reconnect() {
+    _sub?.cancel();
+    _sub = null;
+    _channel = null;
+    if (_closed) return;
+    final b = _backoff == null ? minBackoff : _backoff! * 2;
+    _backoff = b > maxBackoff ? maxBackoff : b;
+    _retry = Timer(_backoff!, _connect);
+  }
+
+  /// Stops listening for good.
+  Future<void> close() async {
+    _closed = true;
+    _retry?.cancel();
+    await _sub?.cancel();
+    await _channel?.sink.close();
+    await _controller.close();
+  }
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"github.com/anacrolix/torrent"
+)
+
+// ── Add queue ─────────────────────────────────────────────────────────────────
+// Adds to a session run one at a time on a per-session worker, so a burst of
+// /add calls (the user tapping through several results) can't interleave:
+// each add stops whatever came before and brings its own torrent up. A newer
+// add supersedes every older one still queued or waiting for metadata; those
+// give up without touching the session and their callers get "superseded".
+// Every add has an ID, echoed as add_id in /status while it owns the session.
+// Adding the torrent the session already has is answered with "exists"
+// (startOnce) rather than a restart.
+
+type addCmd struct {
+	id     uint64
+	opts   addOptions
+	add    func() (*torrent.Torrent, error)
+	cancel chan struct{} // closed once superseded
+	begun  chan struct{} // closed when the worker has taken over the session
+}
+
+func (c *addCmd) superseded() bool {
+	select {
+	case <-c.cancel:
+		return true
+	default:
+		return false
+	}
+}
+
+// start queues an add that replaces the session's torrent and waits until
+// it owns the session (status "loading", add_id set). ok is false when a
+// newer add superseded it first.
+func (s *session) start(opts addOptions, add func() (*torrent.Torrent, error)) (id uint64, ok bool) {
+	s.addMu.Lock()
+	s.addSeq++
+	cmd := &addCmd{id: s.addSeq, opts: opts, add: add,
+		cancel: make(chan struct{}), begun: make(chan struct{})}
+	s.cancelLatestAdd()
+	s.addLatest = cmd
+	if s.addQueue == nil {
+		s.addQueue = make(chan *addCmd, 16)
+		go s.addWorker()
+	}
+	s.addMu.Unlock()
+
+	s.addQueue <- cmd
+	select {
+	case <-cmd.begun:
+		return cmd.id, true
+	case <-cmd.cancel:
+		select {
+		case <-cmd.begun: // superseded after it took over
+			return cmd.id, true
+		default:
+			return cmd.id, false
+		}
+	}
+}
+
+// startOnce is start, except that an add of the torrent (and file) the
+// session already has or is bringing up is a no-op: a retried /add keeps
+// its peers and pieces. status is "exists" then, else as for addStatus.
+func (s *session) startOnce(opts addOptions, add func() (*torrent.Torrent, error)) (id uint64, status string) {
+	s.addMu.Lock()
+	c := s.addLatest
+	dup := c != nil && !c.superseded() && c.opts.sameTarget(opts) && s.snapshotStatus().State != "error"
+	s.addMu.Unlock()
+	if dup {
+		s.event(opts.RequestID, "duplicate add, keeping the session")
+		return c.id, "exists"
+	}
+	id, ok := s.start(opts, add)
+	return id, addStatus(ok)
+}
+
+// sameTarget reports whether two adds ask for the same torrent and file.
+func (o addOptions) sameTarget(p addOptions) bool {
+	if o.InfoHash == "" || o.InfoHash != p.InfoHash {
+		return false
+	}
+	if (o.FileIndex == nil) != (p.FileIndex == nil) || o.FileIndex != nil && *o.FileIndex != *p.FileIndex {
+		return false
+	}
+	return o.File == p.File && o.Title == p.Title && o.Episode == p.Episode
+}
+
+// addStatus is the "status" of an add response.
+func addStatus(ok bool) string {
+	if ok {
+		return "loading"
+	}
+	return "superseded"
+}
+
+// supersede cancels the pending or in-flight add, if any (/stop).
+func (s *session) supersede() {
+	s.addMu.Lock()
+	defer s.addMu.Unlock()
+	s.cancelLatestAdd()
+}
+
+// cancelLatestAdd supersedes the newest add. Caller holds s.addMu.
+func (s *session) cancelLatestAdd() {
+	if s.addLatest != nil && !s.addLatest.superseded() {
+		close(s.addLatest.cancel)
+	}
+}
+
+func (s *session) addWorker() {
+	defer guard()
+	for cmd := range s.addQueue {
+		if cmd.superseded() {
+			s.event(cmd.opts.RequestID, "superseded before it started")
+			continue
+		}
+		s.bringUp(cmd)
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+// ── /add request body ─────────────────────────────────────────────────────────
+// /add takes either query/form params (magnet, swarm, file, title, episode,
+// repeated tracker) or the same thing as a JSON body, which survives
+// magnets with many trackers intact and carries labels and policies:
+//
+//	POST /add
+//	Content-Type: application/json
+//	{"magnet": "magnet:?xt=…", "trackers": ["udp://…"], "file": "S01/E02.mkv",
+//	 "labels": {"source": "rss"}, "policy": {"max_file_size_mb": 4096},
+//	 "file_index": 3, "paused": false, "sequential": true,
+//	 "head_bytes": 33554432, "tail_bytes": 8388608}
+//
+// Instead of a magnet it takes a .torrent file, for trackers that don't
+// hand out magnets: the raw file as an application/x-bittorrent body (other
+// params in the query string) or a multipart form with a "torrent" file part.
+// Or it takes url=, a .torrent to fetch (30s and 10 MB at most), so the app
+// doesn't have to download and parse it itself; cookie= and authorization=
+// log the fetch in to a private tracker (sitecreds.go). fallbacks lists
+// other magnets for the same title to switch to while the first one stalls
+// (fallback.go).
+
+const maxAddBody = 1 << 20
+
+// addRequest is everything /add accepts.
+type addRequest struct {
+	Magnet   string            `json:"magnet"`
+	Trackers []string          `json:"trackers,omitempty"` // appended to the magnet's own
+	File     string            `json:"file,omitempty"`     // display path to stream
+	Title    string            `json:"title,omitempty"`    // selection hints
+	Episode  string            `json:"episode,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"` // echoed in /status
+	Policy   *streamPolicy     `json:"policy,omitempty"` // on top of the profile's
+	Swarm    json.RawMessage   `json:"swarm,omitempty"`  // snapshot object or base64 string
+	URL      string            `json:"url,omitempty"`    // http(s) URL of a .torrent or a video, instead of Magnet
+	Peers    []string          `json:"peers,omitempty"`  // host:port peers to dial (peers.go)
+
+	Fallbacks []string `json:"fallbacks,omitempty"` // magnets to fail over to, in order (fallback.go)
+
+	// Per-add streaming options (JSON only).
+	FileIndex  *int  `json:"file_index,omitempty"` // index in the torrent's file list; wins over file
+	Paused     bool  `json:"paused,omitempty"`     // fetch metadata only until the first read or "playing"
+	Sequential *bool `json:"sequential,omitempty"` // false: plain download, no head/tail boost
+	HeadBytes  int64 `json:"head_bytes,omitempty"` // boosted at the start (default 5% of the file)
+	TailBytes  int64 `json:"tail_bytes,omitempty"` // boosted at the end (default 1%)
+
+	fetchAuth // cookie and authorization for fetching url (sitecreds.go)
+
+	Metainfo *metainfo.MetaInfo `json:"-"` // uploaded or fetched .torrent, used instead of Magnet
+	Source   *httpSource        `json:"-"` // url is a video (httpsource.go)
+	ResumeAt float64            `json:"-"` // playback position of a restored session (snapshot.go)
+}
+
+// addRequestJSON is an addRequest as a JSON body carries it.
+type addRequestJSON struct {
+	addRequest
+	fetchAuthJSON
+}
+
+var errUnsupportedMedia = errors.New("Content-Type must be application/json, form-encoded or application/x-bittorrent")
+
+// parseAddRequest reads an /add request in any of its forms.
+func parseAddRequest(r *http.Request) (addRequest, error) {
+	var req addRequest
+	ct := r.Header.Get("Content-Type")
+	mt, _, _ := mime.ParseMediaType(ct)
+	switch mt {
+	case "application/json":
+		var in addRequestJSON
+		dec := json.NewDecoder(io.LimitReader(r.Body, maxAddBody))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&in); err != nil {
+			return req, fmt.Errorf("invalid JSON body: %v", err)
+		}
+		req = in.addRequest
+		req.fetchAuth = in.fetchAuthJSON.auth()
+	case "application/x-bittorrent":
+		mi, err := metainfo.Load(io.LimitReader(r.Body, maxTorrentFileSize))
+		if err != nil {
+			return req, fmt.Errorf("invalid .torrent body: %v", err)
+		}
+		req.Metainfo = mi
+		readAddForm(r, &req)
+	case "", "application/x-www-form-urlencoded", "multipart/form-data":
+		if mt == "multipart/form-data" {
+			r.Body = http.MaxBytesReader(nil, r.Body, maxTorrentFileSize+maxAddBody)
+			_ = r.ParseMultipartForm(maxAddBody)
+			mi, err := multipartTorrent(r)
+			if err != nil {
+				return req, err
+			}
+			req.Metainfo = mi
+		} else {
+			_ = r.ParseForm()
+		}
+		readAddForm(r, &req)
+	default:
+		return req, errUnsupportedMedia
+	}
+	if req.Magnet == "" {
+		req.Magnet = r.URL.Query().Get("magnet")
+	}
+	if req.URL == "" {
+		req.URL = r.URL.Query().Get("url")
+	}
+	return req, req.validate()
+}
+
+// validate checks a parsed add request.
+func (req addRequest) validate() error {
+	if req.Magnet == "" && req.Metainfo == nil && req.URL == "" {
+		return errors.New("magnet or url param, or a .torrent file, required")
+	}
+	if req.URL != "" {
+		if err := sourceURL(req.URL); err != nil {
+			return err
+		}
+	}
+	if _, err := peerInfos(req.Peers); err != nil {
+		return err
+	}
+	if err := validateFallbacks(req.Fallbacks); err != nil {
+		return err
+	}
+	if req.FileIndex != nil && *req.FileIndex < 0 {
+		return errors.New("file_index must be >= 0")
+	}
+	if req.HeadBytes < 0 || req.TailBytes < 0 {
+		return errors.New("head_bytes and tail_bytes must be >= 0")
+	}
+	if req.Policy != nil && req.Policy.BlockPattern != "" {
+		if _, err := regexp.Compile(req.Policy.BlockPattern); err != nil {
+			return fmt.Errorf("policy.block_pattern: %v", err)
+		}
+	}
+	return nil
+}
+
+// readAddRequest parses an add request, fetches the .torrent its url names
+// and decodes its swarm snapshot. On failure it writes the error and
+// returns ok false.
+func readAddRequest(w http.ResponseWriter, r *http.Request) (req addRequest, snap *SwarmSnapshot, ok bool) {
+	req, err := parseAddRequest(r)
+	if err == errUnsupportedMedia {
+		http.Error(w, err.Error(), 415)
+		return req, nil, false
+	} else if err != nil {
+		http.Error(w, err.Error(), 400)
+		return req, nil, false
+	}
+	// Optional swarm snapshot from another device to warm-start with.
+	if snap, err = req.swarm(); err != nil {
+		http.Error(w, err.Error(), 400)
+		return req, nil, false
+	}
+	if req.URL != "" && req.Magnet == "" && req.Metainfo == nil {
+		if req.Metainfo, req.Source, err = fetchSource(req.URL, req.fetchAuth.forURL(req.URL)); err != nil {
+			http.Error(w, err.Error(), 422)
+			return req, nil, false
+		}
+	}
+	return req, snap, true
+}
+
+// readAddForm fills req from query and form params.
+func readAddForm(r *http.Request, req *addRequest) {
+	req.Magnet = r.FormValue("magnet")
+	req.URL = r.FormValue("url")
+	req.Trackers = r.Form["tracker"]
+	req.Peers = r.Form["peer"]
+	req.Fallbacks = r.Form["fallback"]
+	req.File = r.FormValue("file")
+	req.Title = r.FormValue("title")
+	req.Episode = r.FormValue("episode")
+	req.fetchAuth = authForm(r)
+	if s := r.FormValue("swarm"); s != "" {
+		req.Swarm = json.RawMessage(s)
+	}
+}
+
+// multipartTorrent parses the form's "torrent" file part, if any.
+func multipartTorrent(r *http.Request) (*metainfo.MetaInfo, error) {
+	if r.MultipartForm == nil || len(r.MultipartForm.File["torrent"]) == 0 {
+		return nil, nil
+	}
+	fh := r.MultipartForm.File["torrent"][0]
+	if fh.Size > maxTorrentFileSize {
+		return nil, fmt.Errorf(".torrent file too large (max %d bytes)", maxTorrentFileSize)
+	}
+	f, err := fh.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	mi, err := metainfo.Load(f)
+	if err != nil {
+		return nil, fmt.Errorf("invalid .torrent file: %v", err)
+	}
+	return mi, nil
+}
+
+// infoHash is the hex infohash of the torrent the request names.
+func (req addRequest) infoHash() (string, error) {
+	if req.Metainfo != nil {
+		return req.Metainfo.HashInfoBytes().HexString(), nil
+	}
+	spec, err := torrent.TorrentSpecFromMagnetUri(req.Magnet)
+	if err != nil {
+		return "", fmt.Errorf("magnet: %v", err)
+	}
+	return spec.InfoHash.HexString(), nil
+}
+
+// options are the addOptions the request asks for.
+func (req addRequest) options(requestID string) addOptions {
+	opts := addOptions{File: req.File, Title: req.Title, Episode: req.Episode, Labels: req.Labels, Policy: req.Policy, RequestID: requestID,
+		FileIndex: req.FileIndex, Paused: req.Paused, Fallbacks: req.Fallbacks, ResumeAt: req.ResumeAt}
+	opts.Prio = streamPrio{Bulk: req.Sequential != nil && !*req.Sequential, HeadBytes: req.HeadBytes, TailBytes: req.TailBytes}
+	opts.InfoHash, _ = req.infoHash()
+	return opts
+}
+
+// swarm decodes the optional warm-start snapshot.
+func (req addRequest) swarm() (*SwarmSnapshot, error) {
+	if len(req.Swarm) == 0 {
+		return nil, nil
+	}
+	s := string(req.Swarm)
+	var quoted string
+	if json.Unmarshal(req.Swarm, &quoted) == nil {
+		s = quoted // base64 (or JSON) passed as a string
+	}
+	return decodeSwarm(s)
+}
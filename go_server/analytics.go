@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ── Analytics store ───────────────────────────────────────────────────────────
+// An append-only JSON-lines file in the cache dir. Every record carries the
+// server version so numbers can be compared across releases.
+
+const analyticsFile = "analytics.jsonl"
+
+var analyticsMu sync.Mutex
+
+type analyticsRecord struct {
+	Kind    string    `json:"kind"`
+	Time    time.Time `json:"time"`
+	Version string    `json:"version"`
+	Data    any       `json:"data"`
+}
+
+func recordAnalytics(kind string, data any) {
+	b, err := json.Marshal(analyticsRecord{Kind: kind, Time: time.Now(), Version: version, Data: data})
+	if err != nil {
+		log.Printf("analytics: %v", err)
+		return
+	}
+	analyticsMu.Lock()
+	defer analyticsMu.Unlock()
+	f, err := os.OpenFile(filepath.Join(cacheDir, analyticsFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Printf("analytics: %v", err)
+		return
+	}
+	defer f.Close()
+	_, _ = f.Write(append(b, '\n'))
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"net/http"
+	"time"
+)
+
+// ── Backpressure ──────────────────────────────────────────────────────────────
+// A player with a full buffer stops reading, and our writes to its socket
+// block. While that's most of the time, the player drains the stream at its
+// playback bitrate and is far ahead of what it shows; a big readahead then
+// only races to download the file. pacedWriter watches for this and cuts the
+// reader's readahead to about backpressureAhead of playback at the drain
+// rate; once writes stop blocking (a seek, a rebuffer) the reader's own
+// readahead comes back.
+
+const (
+	backpressureWindow = 2 * time.Second  // measuring interval (at least)
+	backpressureAfter  = 6 * time.Second  // of mostly blocked writes before pacing
+	backpressureAhead  = 30 * time.Second // of playback, at the measured drain rate
+)
+
+type pacedWriter struct {
+	http.ResponseWriter
+	reader *trackedReader
+	floor  int64 // smallest readahead it sets
+
+	windowStart time.Time
+	bytes       int64
+	blocked     time.Duration
+	limited     time.Duration // how long the player has been the bottleneck
+}
+
+func pace(w http.ResponseWriter, reader *trackedReader, floor int64) *pacedWriter {
+	return &pacedWriter{ResponseWriter: w, reader: reader, floor: floor, windowStart: time.Now()}
+}
+
+func (w *pacedWriter) Write(p []byte) (int, error) {
+	start := time.Now()
+	n, err := w.ResponseWriter.Write(p)
+	now := time.Now()
+	w.blocked += now.Sub(start)
+	w.bytes += int64(n)
+	if elapsed := now.Sub(w.windowStart); elapsed >= backpressureWindow {
+		w.endWindow(elapsed)
+		w.windowStart, w.bytes, w.blocked = now, 0, 0
+	}
+	return n, err
+}
+
+func (w *pacedWriter) endWindow(elapsed time.Duration) {
+	r := w.reader
+	if r.sess.trickling() { // trickle mode owns the readahead (player.go)
+		w.limited = 0
+		r.paced.Store(false)
+		return
+	}
+	if w.blocked*2 < elapsed { // the player takes what we give, or we starve it
+		w.limited = 0
+		if r.paced.Swap(false) {
+			r.SetReadahead(r.base)
+			debugf("reader", "backpressure off, readahead %d", r.base)
+		}
+		return
+	}
+	if w.limited += elapsed; w.limited < backpressureAfter {
+		return
+	}
+	rate := float64(w.bytes) / elapsed.Seconds()
+	ra := min(max(int64(rate*backpressureAhead.Seconds()), w.floor), r.base)
+	if ra < r.base && ra != r.readahead.Load() {
+		r.paced.Store(true)
+		r.SetReadahead(ra)
+		debugf("reader", "backpressure: player drains %.0f KB/s, readahead %d", rate/1024, ra)
+	}
+}
+
+func (w *pacedWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"time"
+
+	"github.com/anacrolix/torrent"
+
+	"github.com/roxbox/torrent_server/engine"
+)
+
+// ── Bandwidth tiers ───────────────────────────────────────────────────────────
+// Pieces inside a reader's playback window (its position plus readahead)
+// come first; the rest of the file is background fill. Piece priorities
+// alone only order requests, and fill requests to other peers still eat
+// the downlink the window needs. So while any window has missing pieces,
+// fill pieces are held at no priority and let back in one piece at a time,
+// as a budget of fill_share_pct of the measured download rate allows.
+// Once the windows are complete for a moment, fill gets everything back.
+// Trickle mode and data saver manage priorities themselves and turn this
+// off.
+//
+// Which fill piece goes next is the profile's fill_order. "rarest", the
+// default, takes the one the fewest connected peers have (and not one that
+// none has): on a thinly seeded torrent that gets the pieces only one peer
+// holds while that peer is still around, and helps the swarm by handing
+// them on. Once fill runs free the client's own request order does the
+// same within a priority. "sequential" goes on from the furthest window
+// instead, and keeps to it once free by letting fillAhead bytes in at a
+// time. Both budgets are in bytes with a floor in pieces, so tiny pieces
+// get as much in flight as ordinary ones and huge ones still a few.
+
+const (
+	defaultFillSharePct = 25
+	fillTick            = 500 * time.Millisecond
+	fillRelease         = 2 * time.Second // windows complete this long before fill runs free
+	fillBurst           = 4 << 20         // budget cap
+	fillBurstPieces     = 4               // … and at least this many pieces
+	fillAhead           = 8 << 20         // let in at a time by free sequential fill
+	fillAheadPieces     = 8               // … and at least this many pieces
+
+	fillRarest     = "rarest"
+	fillSequential = "sequential"
+)
+
+func validFillOrder(v string) bool { return v == "" || v == fillRarest || v == fillSequential }
+
+// fillOrder is the profile's fill order, fillRarest or fillSequential.
+func (p *profile) fillOrder() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.settings.FillOrder == "" {
+		return fillRarest
+	}
+	return p.settings.FillOrder
+}
+
+// fillSharePct is the profile's fill share in [0, 100].
+func (p *profile) fillSharePct() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch v := p.settings.FillSharePct; {
+	case v == 0:
+		return defaultFillSharePct
+	case v < 0:
+		return 0
+	default:
+		return min(v, 100)
+	}
+}
+
+// fillGovernor is the per-stream state of governFill. Only its goroutine
+// touches it.
+type fillGovernor struct {
+	s        *session
+	t        *torrent.Torrent
+	f        *torrent.File
+	span     engine.PieceSpan
+	fileHeld bool         // we set the file itself to no priority
+	gated    map[int]bool // fill pieces we set to no priority
+	admitted map[int]bool // let back in by the budget, until complete
+	tokens   float64      // fill budget, bytes
+	bytes    int64        // useful bytes read, last tick
+	calm     time.Time    // since when every window has been complete
+}
+
+// governFill runs the tiers for f until the session moves on.
+func (s *session) governFill(t *torrent.Torrent, f *torrent.File) {
+	defer guard()
+	g := &fillGovernor{s: s, t: t, f: f, span: engine.PieceRange(f), gated: map[int]bool{}, admitted: map[int]bool{}}
+	if g.span.Len() == 0 {
+		return
+	}
+	g.bytes = g.usefulBytes()
+	tick := time.NewTicker(fillTick)
+	defer tick.Stop()
+	for range tick.C {
+		s.mu.RLock()
+		cur, curF := s.torr, s.file
+		s.mu.RUnlock()
+		if cur != t || curF != f {
+			return
+		}
+		g.step()
+	}
+}
+
+func (g *fillGovernor) step() {
+	bytes := g.usefulBytes()
+	rate := float64(bytes-g.bytes) / fillTick.Seconds()
+	g.bytes = bytes
+
+	share, order := g.s.profile.fillSharePct(), g.s.profile.fillOrder()
+	if g.s.trickling() || g.s.profile.dataSaverSecs() > 0 {
+		g.forget() // their priorities, not ours
+		return
+	}
+	windows := g.windows()
+	if share >= 100 || !g.pending(windows) {
+		if g.calm.IsZero() {
+			g.calm = time.Now()
+		}
+		if share >= 100 || time.Since(g.calm) >= fillRelease {
+			if order == fillSequential {
+				g.runAhead(windows)
+			} else {
+				g.release()
+			}
+		}
+		return
+	}
+	g.calm = time.Time{}
+	g.gate()
+	g.setThrottled(true)
+	var copies []int
+	if order == fillRarest {
+		copies = engine.PieceCopies(g.t)
+	}
+
+	pieceLen := float64(g.span.PieceLength)
+	burst := float64(g.span.PiecesFor(fillBurst, fillBurstPieces)) * pieceLen
+	g.tokens = min(g.tokens+rate*float64(share)/100*fillTick.Seconds(), burst)
+	for g.tokens >= pieceLen {
+		i := g.nextFill(windows, copies)
+		if i < 0 {
+			break
+		}
+		g.admit(i)
+		g.tokens -= pieceLen
+	}
+}
+
+// runAhead is free sequential fill: fillAhead bytes of gated pieces after the
+// furthest window are let in, the others held back until those complete.
+func (g *fillGovernor) runAhead(windows [][2]int) {
+	g.gate()
+	g.tokens = 0
+	g.setThrottled(false)
+	for ahead := g.span.PiecesFor(fillAhead, fillAheadPieces); len(g.admitted) < ahead; {
+		i := g.nextFill(windows, nil)
+		if i < 0 {
+			break
+		}
+		g.admit(i)
+	}
+}
+
+func (g *fillGovernor) admit(i int) {
+	g.t.Piece(i).SetPriority(torrent.PiecePriorityNormal)
+	delete(g.gated, i)
+	g.admitted[i] = true
+}
+
+func (g *fillGovernor) usefulBytes() int64 {
+	st := g.t.Stats()
+	return st.BytesReadUsefulData.Int64()
+}
+
+func (g *fillGovernor) windows() [][2]int { return g.s.readerWindows(g.span) }
+
+// readerWindows returns each reader's playback window (its position plus
+// readahead) as a piece range of span.
+func (s *session) readerWindows(span engine.PieceSpan) [][2]int {
+	s.readersMu.Lock()
+	defer s.readersMu.Unlock()
+	var out [][2]int
+	for rd := range s.readers {
+		pos, ahead := rd.pos.Load(), rd.readahead.Load()
+		out = append(out, [2]int{span.PieceAt(pos), span.PieceAt(pos+max(ahead, 1)-1) + 1})
+	}
+	return out
+}
+
+func (g *fillGovernor) pending(windows [][2]int) bool {
+	for _, w := range windows {
+		for i := max(w[0], g.span.Begin); i < min(w[1], g.span.End); i++ {
+			if !g.t.PieceState(i).Complete {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// gate holds the file's incomplete normal-priority pieces back. A piece's
+// priority is the highest of its own, its files' and its readers', so the
+// file goes to no priority too. Readers still raise the pieces inside their
+// windows, and boosted pieces (head, tail, companions) aren't fill.
+func (g *fillGovernor) gate() {
+	if g.f.Priority() != torrent.PiecePriorityNone {
+		g.f.SetPriority(torrent.PiecePriorityNone)
+		g.fileHeld = true
+	}
+	for i := g.span.Begin; i < g.span.End; i++ {
+		ps := g.t.PieceState(i)
+		if ps.Complete {
+			delete(g.admitted, i)
+			continue
+		}
+		if ps.Priority == torrent.PiecePriorityNormal && !g.admitted[i] {
+			g.t.Piece(i).SetPriority(torrent.PiecePriorityNone)
+			g.gated[i] = true
+		}
+	}
+}
+
+// nextFill picks the gated piece to fetch next: the first one after the
+// furthest window, wrapping to the start of the file. Given the pieces'
+// copies (engine.PieceCopies) it is the rarest one instead, the first of
+// those on that way; a piece no peer has isn't picked.
+func (g *fillGovernor) nextFill(windows [][2]int, copies []int) int {
+	from := g.span.Begin
+	for _, w := range windows {
+		from = max(from, w[1])
+	}
+	best := -1
+	for n := 0; n < g.span.End-g.span.Begin; n++ {
+		i := from + n
+		if i >= g.span.End {
+			i -= g.span.End - g.span.Begin
+		}
+		if !g.gated[i] || g.t.PieceState(i).Complete {
+			continue
+		}
+		if copies == nil {
+			return i
+		}
+		if i < len(copies) && copies[i] > 0 && (best < 0 || copies[i] < copies[best]) {
+			best = i
+		}
+	}
+	return best
+}
+
+// release gives the file and the gated pieces their priority back.
+func (g *fillGovernor) release() {
+	if g.fileHeld && g.f.Priority() == torrent.PiecePriorityNone {
+		g.f.SetPriority(torrent.PiecePriorityNormal)
+	}
+	for i := range g.gated {
+		if g.t.PieceState(i).Priority == torrent.PiecePriorityNone {
+			g.t.Piece(i).SetPriority(torrent.PiecePriorityNormal)
+		}
+	}
+	g.forget()
+}
+
+func (g *fillGovernor) forget() {
+	g.fileHeld = false
+	clear(g.gated)
+	clear(g.admitted)
+	g.tokens = 0
+	g.setThrottled(false)
+}
+
+func (g *fillGovernor) setThrottled(on bool) {
+	g.s.mu.Lock()
+	if g.s.torr == g.t && g.s.status.FillThrottled != on {
+		g.s.status.FillThrottled = on
+		g.s.touch()
+	}
+	g.s.mu.Unlock()
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"sort"
+	"time"
+
+	"github.com/roxbox/torrent_server/engine"
+)
+
+// ── Peer bans ─────────────────────────────────────────────────────────────────
+// Peers the client bans for corrupt data or protocol violations are picked
+// up every minute and kept in bans.json for banFor, so they stay blocked
+// across restarts and client rebuilds (engine.DefaultBans). /bans lists
+// them and takes manual bans; lifting a client ban lasts until the client
+// bans the peer again, since the client keeps its own list while it runs.
+
+const (
+	bansFile      = "bans.json"
+	banFor        = 7 * 24 * time.Hour
+	bansSyncEvery = time.Minute
+)
+
+type bansState struct {
+	Bans []engine.Ban `json:"bans"`
+}
+
+// loadBans reads bans.json into the ban list and starts collecting the
+// client's bans.
+func loadBans() {
+	var st bansState
+	if err := loadJSON(bansFile, &st); err != nil {
+		log.Printf("bans: load: %v", err)
+	}
+	for _, b := range st.Bans {
+		engine.DefaultBans.Add(b)
+	}
+	if n := len(engine.DefaultBans.List()); n > 0 {
+		log.Printf("Banned peers: %d", n)
+	}
+	go bansLoop()
+}
+
+func bansLoop() {
+	defer guard()
+	for range time.Tick(bansSyncEvery) {
+		if syncClientBans() > 0 {
+			saveBans()
+		}
+	}
+}
+
+// syncClientBans adds the client's new bans to the list and says how many.
+func syncClientBans() int {
+	n := 0
+	now := time.Now()
+	for _, s := range currentClient().BadPeerIPs() {
+		ip, err := netip.ParseAddr(s)
+		if err != nil || engine.DefaultBans.Has(ip) {
+			continue
+		}
+		engine.DefaultBans.Add(engine.Ban{IP: ip, Reason: "corrupt data or protocol violation", Source: "client", Added: now, Expires: now.Add(banFor)})
+		n++
+	}
+	if n > 0 {
+		log.Printf("bans: %d peers banned by the client", n)
+	}
+	return n
+}
+
+func saveBans() {
+	if err := saveJSON(bansFile, bansState{Bans: engine.DefaultBans.List()}); err != nil {
+		log.Printf("bans: save: %v", err)
+	}
+}
+
+// dropBannedConns closes the connections to ip.
+func dropBannedConns(ip netip.Addr) {
+	for _, t := range currentClient().Torrents() {
+		for _, c := range t.PeerConns() {
+			host, _, _ := net.SplitHostPort(c.RemoteAddr.String())
+			if a, err := netip.ParseAddr(host); err == nil && a.Unmap() == ip.Unmap() {
+				c.Close()
+			}
+		}
+	}
+}
+
+// ── GET | POST | DELETE /bans ─────────────────────────────────────────────────
+//
+//	GET                                                  → the bans, newest first
+//	POST {"ip": "…", "reason": "…", "duration": "72h"}   → ban (duration defaults to a week)
+//	DELETE ?ip=                                          → lift
+type banRequest struct {
+	IP       string `json:"ip"`
+	Reason   string `json:"reason,omitempty"`
+	Duration string `json:"duration,omitempty"` // Go duration; default 168h
+}
+
+func handleBans(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		syncClientBans()
+	case http.MethodPost:
+		var req banRequest
+		if err := json.NewDecoder(io.LimitReader(r.Body, 1<<16)).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON body: "+err.Error(), 400)
+			return
+		}
+		ip, err := netip.ParseAddr(req.IP)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("ip %q: not an IP address", req.IP), 400)
+			return
+		}
+		d := banFor
+		if req.Duration != "" {
+			if d, err = time.ParseDuration(req.Duration); err != nil || d <= 0 {
+				http.Error(w, fmt.Sprintf("duration %q: want a positive Go duration like 72h", req.Duration), 400)
+				return
+			}
+		}
+		now := time.Now()
+		engine.DefaultBans.Add(engine.Ban{IP: ip, Reason: req.Reason, Source: "manual", Added: now, Expires: now.Add(d)})
+		dropBannedConns(ip)
+		saveBans()
+	case http.MethodDelete:
+		ip, err := netip.ParseAddr(r.URL.Query().Get("ip"))
+		if err != nil {
+			http.Error(w, "ip param required", 400)
+			return
+		}
+		if !engine.DefaultBans.Remove(ip) {
+			http.Error(w, "not banned", 404)
+			return
+		}
+		saveBans()
+	default:
+		http.Error(w, "GET, POST or DELETE only", 405)
+		return
+	}
+	bans := engine.DefaultBans.List()
+	sort.Slice(bans, func(i, j int) bool { return bans[i].Added.After(bans[j].Added) })
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(bansState{Bans: bans})
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"crypto/rand"
+	"flag"
+	"fmt"
+	"io"
+	mrand "math/rand"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/anacrolix/torrent/storage"
+)
+
+// ── bench-storage ─────────────────────────────────────────────────────────────
+// `torrent_server bench-storage` measures the cache directory the server
+// would use (ROXBOX_CACHE or the default) — raw file I/O and the torrent
+// storage backend — and says whether it keeps up with a target bitrate.
+// Piece data arrives out of order while the player reads sequentially, so
+// random 16 KiB writes and backend writes matter more than the sequential
+// numbers.
+
+var (
+	benchMB      = flag.Int("bench-mb", 256, "bench-storage: test file size in MB")
+	benchBitrate = flag.Float64("bitrate", 25, "bench-storage: target video bitrate in Mbit/s (4K ≈ 25–80)")
+)
+
+const (
+	benchBlock   = 16 << 10 // torrent request size
+	benchPiece   = 1 << 20
+	benchRandOps = 2000
+	// Buffering downloads and playback run at the same time, and the
+	// download must outrun playback to build a buffer.
+	benchHeadroom = 2.0
+)
+
+type benchResult struct {
+	Name string
+	MBs  float64
+	Note string
+}
+
+func runBenchStorage() int {
+	dir := os.Getenv("ROXBOX_CACHE")
+	if dir == "" {
+		dir = defaultCacheDir()
+	}
+	size := int64(max(*benchMB, 16)) << 20
+	_ = os.MkdirAll(dir, 0755)
+	benchDir, err := os.MkdirTemp(dir, "bench-")
+	if err != nil {
+		fmt.Println("FAIL:", err)
+		return 1
+	}
+	defer os.RemoveAll(benchDir)
+	fmt.Printf("Benchmarking %s with %d MB\n\n", dir, size>>20)
+
+	var results []benchResult
+	for _, b := range []struct {
+		name string
+		run  func(string, int64) (benchResult, error)
+	}{
+		{"sequential write", benchSeqWrite},
+		{"sequential read", benchSeqRead},
+		{"random write 16K", benchRandWrite},
+		{"random read 16K", benchRandRead},
+		{"backend write", benchBackendWrite},
+		{"backend read", benchBackendRead},
+	} {
+		r, err := b.run(benchDir, size)
+		if err != nil {
+			fmt.Printf("%-18s FAIL: %v\n", b.name, err)
+			return 1
+		}
+		r.Name = b.name
+		fmt.Printf("%-18s %8.1f MB/s %s\n", r.Name, r.MBs, r.Note)
+		results = append(results, r)
+	}
+
+	need := *benchBitrate / 8 * benchHeadroom
+	worst := results[0]
+	for _, r := range results {
+		if r.MBs < worst.MBs {
+			worst = r
+		}
+	}
+	fmt.Printf("\nTarget %.0f Mbit/s needs ≈ %.1f MB/s sustained (×%.0f headroom).\n", *benchBitrate, need, benchHeadroom)
+	if worst.MBs < need {
+		fmt.Printf("TOO SLOW: %s manages %.1f MB/s; expect stutter at this bitrate. Try a faster cache dir (internal storage rather than SD card).\n", worst.Name, worst.MBs)
+		return 1
+	}
+	fmt.Printf("OK: slowest path (%s) has %.1fx the required throughput.\n", worst.Name, worst.MBs/need)
+	return 0
+}
+
+func mbs(n int64, d time.Duration) float64 {
+	return float64(n) / (1 << 20) / max(d.Seconds(), 1e-9)
+}
+
+func benchSeqWrite(dir string, size int64) (benchResult, error) {
+	f, err := os.Create(filepath.Join(dir, "seq.bin"))
+	if err != nil {
+		return benchResult{}, err
+	}
+	defer f.Close()
+	buf := make([]byte, 1<<20)
+	_, _ = rand.Read(buf)
+	start := time.Now()
+	for n := int64(0); n < size; n += int64(len(buf)) {
+		if _, err := f.Write(buf); err != nil {
+			return benchResult{}, err
+		}
+	}
+	if err := f.Sync(); err != nil {
+		return benchResult{}, err
+	}
+	return benchResult{MBs: mbs(size, time.Since(start)), Note: "(incl. fsync)"}, nil
+}
+
+func benchSeqRead(dir string, size int64) (benchResult, error) {
+	f, err := os.Open(filepath.Join(dir, "seq.bin"))
+	if err != nil {
+		return benchResult{}, err
+	}
+	defer f.Close()
+	start := time.Now()
+	n, err := io.CopyBuffer(io.Discard, f, make([]byte, 1<<20))
+	if err != nil {
+		return benchResult{}, err
+	}
+	return benchResult{MBs: mbs(n, time.Since(start)), Note: "(may be served from page cache)"}, nil
+}
+
+func benchRandWrite(dir string, size int64) (benchResult, error) {
+	f, err := os.OpenFile(filepath.Join(dir, "seq.bin"), os.O_WRONLY, 0644)
+	if err != nil {
+		return benchResult{}, err
+	}
+	defer f.Close()
+	rng := mrand.New(mrand.NewSource(1))
+	buf := make([]byte, benchBlock)
+	_, _ = rand.Read(buf)
+	blocks := size / benchBlock
+	start := time.Now()
+	for i := 0; i < benchRandOps; i++ {
+		if _, err := f.WriteAt(buf, rng.Int63n(blocks)*benchBlock); err != nil {
+			return benchResult{}, err
+		}
+	}
+	if err := f.Sync(); err != nil {
+		return benchResult{}, err
+	}
+	d := time.Since(start)
+	return benchResult{MBs: mbs(benchRandOps*benchBlock, d), Note: fmt.Sprintf("(%.0f IOPS)", benchRandOps/d.Seconds())}, nil
+}
+
+func benchRandRead(dir string, size int64) (benchResult, error) {
+	f, err := os.Open(filepath.Join(dir, "seq.bin"))
+	if err != nil {
+		return benchResult{}, err
+	}
+	defer f.Close()
+	rng := mrand.New(mrand.NewSource(2))
+	buf := make([]byte, benchBlock)
+	blocks := size / benchBlock
+	start := time.Now()
+	for i := 0; i < benchRandOps; i++ {
+		if _, err := f.ReadAt(buf, rng.Int63n(blocks)*benchBlock); err != nil {
+			return benchResult{}, err
+		}
+	}
+	d := time.Since(start)
+	return benchResult{MBs: mbs(benchRandOps*benchBlock, d), Note: fmt.Sprintf("(%.0f IOPS)", benchRandOps/d.Seconds())}, nil
+}
+
+// benchTorrent opens a fake single-file torrent of size in the same storage
+// backend the server uses.
+func benchTorrent(dir string, size int64) (*metainfo.Info, storage.TorrentImpl, func(), error) {
+	info := &metainfo.Info{
+		Name:        "bench.bin",
+		Length:      size,
+		PieceLength: benchPiece,
+	}
+	info.Pieces = make([]byte, 20*((size+benchPiece-1)/benchPiece))
+	var ih metainfo.Hash
+	_, _ = rand.Read(ih[:])
+	backendDir := filepath.Join(dir, "backend")
+	if err := os.MkdirAll(backendDir, 0755); err != nil {
+		return nil, storage.TorrentImpl{}, nil, err
+	}
+	cl := storage.NewFileByInfoHash(backendDir)
+	t, err := cl.OpenTorrent(info, ih)
+	if err != nil {
+		cl.Close()
+		return nil, storage.TorrentImpl{}, nil, err
+	}
+	return info, t, func() {
+		if t.Close != nil {
+			_ = t.Close()
+		}
+		cl.Close()
+	}, nil
+}
+
+var benchBackend struct {
+	info  *metainfo.Info
+	t     storage.TorrentImpl
+	close func()
+}
+
+// benchBackendWrite writes whole pieces in random order, block by block,
+// like a download does.
+func benchBackendWrite(dir string, size int64) (benchResult, error) {
+	info, t, closeFn, err := benchTorrent(dir, size)
+	if err != nil {
+		return benchResult{}, err
+	}
+	benchBackend.info, benchBackend.t, benchBackend.close = info, t, closeFn
+	buf := make([]byte, benchBlock)
+	_, _ = rand.Read(buf)
+	order := mrand.New(mrand.NewSource(3)).Perm(info.NumPieces())
+	start := time.Now()
+	for _, i := range order {
+		p := t.Piece(info.Piece(i))
+		for off := int64(0); off < info.Piece(i).Length(); off += benchBlock {
+			if _, err := p.WriteAt(buf, off); err != nil {
+				return benchResult{}, err
+			}
+		}
+		if err := p.MarkComplete(); err != nil {
+			return benchResult{}, err
+		}
+	}
+	if t.Flush != nil {
+		_ = t.Flush()
+	}
+	return benchResult{MBs: mbs(size, time.Since(start)), Note: "(random piece order)"}, nil
+}
+
+func benchBackendRead(dir string, size int64) (benchResult, error) {
+	info, t := benchBackend.info, benchBackend.t
+	if info == nil {
+		return benchResult{}, fmt.Errorf("backend write did not run")
+	}
+	defer benchBackend.close()
+	buf := make([]byte, 256<<10) // roughly a reader's request size
+	start := time.Now()
+	var n int64
+	for i := 0; i < info.NumPieces(); i++ {
+		p := t.Piece(info.Piece(i))
+		for off := int64(0); off < info.Piece(i).Length(); off += int64(len(buf)) {
+			m, err := p.ReadAt(buf, off)
+			n += int64(m)
+			if err != nil && err != io.EOF {
+				return benchResult{}, err
+			}
+		}
+	}
+	return benchResult{MBs: mbs(n, time.Since(start)), Note: "(sequential, as a player reads)"}, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+)
+
+// ── GET /capabilities ─────────────────────────────────────────────────────────
+// Which optional features this binary has, so the app can adapt its UI to
+// the server build it was shipped with. "compiled" says the code is in the
+// binary, "enabled" that it can be used right now (e.g. an ffmpeg binary was
+// found). Features the app knows about but this build lacks are listed as
+// not compiled rather than left out.
+
+type capability struct {
+	Compiled bool   `json:"compiled"`
+	Enabled  bool   `json:"enabled"`
+	Detail   string `json:"detail,omitempty"`
+}
+
+type capabilitiesResponse struct {
+	Version  string                `json:"version"`
+	Instance string                `json:"instance"`
+	OS       string                `json:"os"`
+	Arch     string                `json:"arch"`
+	Features map[string]capability `json:"features"`
+}
+
+// knownFeatures are always reported, compiled or not.
+var knownFeatures = []string{"casting", "ffmpeg", "hls", "rar", "rss", "search", "tracker", "tray", "v2-torrents", "watermark", "webdav"}
+
+// capabilityProbes report the features built into this binary. Optional
+// subsystems add theirs through registerModule (modules.go).
+var capabilityProbes = map[string]func() capability{
+	"ffmpeg": func() capability {
+		p, err := ffmpegPath()
+		if err != nil {
+			return capability{Compiled: true, Detail: "no ffmpeg binary found (-ffmpeg, libffmpeg.so or PATH)"}
+		}
+		return capability{Compiled: true, Enabled: true, Detail: p}
+	},
+	"watermark": func() capability {
+		_, err := ffmpegPath()
+		return capability{Compiled: true, Enabled: err == nil, Detail: "needs ffmpeg"}
+	},
+	"tray": func() capability {
+		if runTray == nil {
+			return capability{Detail: "build with -tags tray"}
+		}
+		return capability{Compiled: true, Enabled: *desktopMode}
+	},
+	"v2-torrents": func() capability {
+		return capability{Detail: "BitTorrent v2 infohashes are not supported; hybrid torrents work through their v1 infohash"}
+	},
+}
+
+func currentCapabilities() capabilitiesResponse {
+	out := capabilitiesResponse{
+		Version:  version,
+		Instance: instanceID,
+		OS:       runtime.GOOS,
+		Arch:     runtime.GOARCH,
+		Features: map[string]capability{},
+	}
+	for _, name := range knownFeatures {
+		out.Features[name] = capability{}
+	}
+	for name, probe := range capabilityProbes {
+		out.Features[name] = probe()
+	}
+	return out
+}
+
+func handleCapabilities(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "GET only", 405)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(currentCapabilities())
+}
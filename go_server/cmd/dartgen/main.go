@@ -0,0 +1,460 @@
+// Command dartgen turns the server's OpenAPI document into the typed Dart
+// client shipped in clients/dart:
+//
+//	cd go_server
+//	go run . openapi | go run ./cmd/dartgen -out ../clients/dart/lib/src/api.g.dart
+//
+// It covers what apiDocs produces — query/path params, JSON bodies,
+// JSON/text/binary responses and component schemas — not OpenAPI at large.
+// Binary responses (streams) become URI builders for the player, and
+// WebSocket routes are left to the hand-written listener.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+var (
+	inFlag  = flag.String("in", "", "OpenAPI JSON (default stdin)")
+	outFlag = flag.String("out", "", "Dart output file (default stdout)")
+)
+
+type schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Format               string             `json:"format"`
+	Items                *schema            `json:"items"`
+	Properties           map[string]*schema `json:"properties"`
+	AdditionalProperties *schema            `json:"additionalProperties"`
+	Enum                 []string           `json:"enum"`
+}
+
+type param struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Required    bool    `json:"required"`
+	Description string  `json:"description"`
+	Schema      *schema `json:"schema"`
+}
+
+type media struct {
+	Schema *schema `json:"schema"`
+}
+
+type operation struct {
+	Summary     string  `json:"summary"`
+	OperationID string  `json:"operationId"`
+	Parameters  []param `json:"parameters"`
+	RequestBody *struct {
+		Required bool             `json:"required"`
+		Content  map[string]media `json:"content"`
+	} `json:"requestBody"`
+	Responses map[string]struct {
+		Content map[string]media `json:"content"`
+	} `json:"responses"`
+	WebSocket bool `json:"x-websocket"`
+}
+
+type document struct {
+	Paths      map[string]map[string]*operation `json:"paths"`
+	Components struct {
+		Schemas map[string]*schema `json:"schemas"`
+	} `json:"components"`
+}
+
+func main() {
+	flag.Parse()
+	var in io.Reader = os.Stdin
+	if *inFlag != "" {
+		f, err := os.Open(*inFlag)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		in = f
+	}
+	var doc document
+	if err := json.NewDecoder(in).Decode(&doc); err != nil {
+		log.Fatalf("dartgen: %v", err)
+	}
+	var buf bytes.Buffer
+	generate(&buf, &doc)
+	if *outFlag == "" {
+		os.Stdout.Write(buf.Bytes())
+		return
+	}
+	if err := os.WriteFile(*outFlag, buf.Bytes(), 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+var dartReserved = map[string]bool{
+	"default": true, "in": true, "is": true, "class": true, "new": true,
+	"switch": true, "case": true, "final": true, "var": true, "this": true,
+}
+
+// camel turns "min_progress" / "Idempotency-Key" into "minProgress" /
+// "idempotencyKey".
+func camel(s string) string {
+	parts := strings.FieldsFunc(s, func(r rune) bool { return r == '_' || r == '-' || r == '.' })
+	for i, p := range parts {
+		if i == 0 {
+			parts[i] = strings.ToLower(p[:1]) + p[1:]
+		} else {
+			parts[i] = strings.ToUpper(p[:1]) + p[1:]
+		}
+	}
+	out := strings.Join(parts, "")
+	if dartReserved[out] {
+		out += "_"
+	}
+	return out
+}
+
+func refName(ref string) string { return ref[strings.LastIndex(ref, "/")+1:] }
+
+// dartType is the (non-nullable) Dart type for s.
+func dartType(s *schema) string {
+	if s == nil {
+		return "dynamic"
+	}
+	if s.Ref != "" {
+		return refName(s.Ref)
+	}
+	switch s.Type {
+	case "string":
+		if s.Format == "date-time" {
+			return "DateTime"
+		}
+		return "String"
+	case "integer":
+		return "int"
+	case "number":
+		return "double"
+	case "boolean":
+		return "bool"
+	case "array":
+		return "List<" + dartType(s.Items) + ">"
+	case "object":
+		if s.AdditionalProperties != nil {
+			return "Map<String, " + dartType(s.AdditionalProperties) + ">"
+		}
+		return "Map<String, dynamic>"
+	}
+	return "dynamic"
+}
+
+// decode converts the non-null JSON value expr to dartType(s).
+func decode(s *schema, expr string) string {
+	if s == nil {
+		return expr
+	}
+	if s.Ref != "" {
+		return fmt.Sprintf("%s.fromJson(%s as Map<String, dynamic>)", refName(s.Ref), expr)
+	}
+	switch s.Type {
+	case "string":
+		if s.Format == "date-time" {
+			return fmt.Sprintf("DateTime.parse(%s as String)", expr)
+		}
+		return expr + " as String"
+	case "integer":
+		return fmt.Sprintf("(%s as num).toInt()", expr)
+	case "number":
+		return fmt.Sprintf("(%s as num).toDouble()", expr)
+	case "boolean":
+		return expr + " as bool"
+	case "array":
+		return fmt.Sprintf("(%s as List).map((e) => %s).toList()", expr, decode(s.Items, "e"))
+	case "object":
+		if s.AdditionalProperties != nil {
+			return fmt.Sprintf("(%s as Map).map((k, v) => MapEntry(k as String, %s))", expr, decode(s.AdditionalProperties, "v"))
+		}
+		return expr + " as Map<String, dynamic>"
+	}
+	return expr
+}
+
+// encode converts the non-null Dart value expr back to JSON-able data.
+func encode(s *schema, expr string) string {
+	if s == nil {
+		return expr
+	}
+	if s.Ref != "" {
+		return expr + ".toJson()"
+	}
+	switch s.Type {
+	case "string":
+		if s.Format == "date-time" {
+			return expr + ".toIso8601String()"
+		}
+	case "array":
+		if inner := encode(s.Items, "e"); inner != "e" {
+			return fmt.Sprintf("%s.map((e) => %s).toList()", expr, inner)
+		}
+	case "object":
+		if s.AdditionalProperties != nil {
+			if inner := encode(s.AdditionalProperties, "v"); inner != "v" {
+				return fmt.Sprintf("%s.map((k, v) => MapEntry(k, %s))", expr, inner)
+			}
+		}
+	}
+	return expr
+}
+
+func generate(w *bytes.Buffer, doc *document) {
+	p := func(format string, args ...any) { fmt.Fprintf(w, format+"\n", args...) }
+	p("// GENERATED CODE - DO NOT EDIT.")
+	p("// Produced by go_server/cmd/dartgen from the server's OpenAPI document;")
+	p("// regenerate with `go run . openapi | go run ./cmd/dartgen -out ...`.")
+	p("")
+	p("// ignore_for_file: unnecessary_cast, prefer_null_aware_operators")
+	p("")
+	p("import 'dart:convert';")
+	p("")
+	p("import 'package:http/http.dart' as http;")
+	p("")
+
+	for _, name := range sortedKeys(doc.Components.Schemas) {
+		genModel(p, name, doc.Components.Schemas[name])
+	}
+	genClient(p, doc)
+}
+
+func genModel(p func(string, ...any), name string, s *schema) {
+	props := sortedKeys(s.Properties)
+	p("class %s {", name)
+	if len(props) == 0 {
+		p("  const %s();", name)
+	} else {
+		p("  const %s({", name)
+		for _, k := range props {
+			p("    this.%s,", camel(k))
+		}
+		p("  });")
+	}
+	p("")
+	for _, k := range props {
+		p("  final %s? %s;", dartType(s.Properties[k]), camel(k))
+	}
+	if len(props) > 0 {
+		p("")
+	}
+	p("  factory %s.fromJson(Map<String, dynamic> json) => %s(", name, name)
+	for _, k := range props {
+		v := fmt.Sprintf("json['%s']", k)
+		p("        %s: %s == null ? null : %s,", camel(k), v, decode(s.Properties[k], v))
+	}
+	p("      );")
+	p("")
+	p("  Map<String, dynamic> toJson() => {")
+	for _, k := range props {
+		f := camel(k)
+		v := encode(s.Properties[k], f+"!")
+		if v == f+"!" {
+			v = f // no conversion: the nullable value is fine in the map
+		}
+		p("        if (%s != null) '%s': %s,", f, k, v)
+	}
+	p("      };")
+	p("}")
+	p("")
+}
+
+type genParam struct {
+	param
+	dartName string
+}
+
+func genClient(p func(string, ...any), doc *document) {
+	p(`/// Thrown for non-2xx responses; message is the server's text/plain error.
+class RoxboxApiException implements Exception {
+  RoxboxApiException(this.statusCode, this.message);
+
+  final int statusCode;
+  final String message;
+
+  @override
+  String toString() => 'RoxboxApiException($statusCode): $message';
+}
+
+/// Typed access to every documented endpoint. [profile] scopes all calls to
+/// one profile namespace; [token] is an API token from /tokens, needed from
+/// other devices once the server has any.
+class RoxboxApi {
+  RoxboxApi(this.baseUrl, {this.profile, this.token, http.Client? httpClient})
+      : _http = httpClient ?? http.Client();
+
+  final Uri baseUrl;
+  final String? profile;
+  final String? token;
+  final http.Client _http;
+
+  void close() => _http.close();
+
+  Uri _uri(String path, Map<String, Object?> query) {
+    final q = <String, dynamic>{};
+    query.forEach((k, v) {
+      if (v is List) {
+        q[k] = v.map((e) => e.toString()).toList();
+      } else if (v != null) {
+        q[k] = v.toString();
+      }
+    });
+    if (profile != null) q['profile'] = profile!;
+    return baseUrl.replace(
+      path: baseUrl.path.replaceAll(RegExp(r'/$'), '') + path,
+      queryParameters: q.isEmpty ? null : q,
+    );
+  }
+
+  Future<String> _send(String method, String path, Map<String, Object?> query,
+      {Object? body, Map<String, String?> headers = const {}}) async {
+    final req = http.Request(method, _uri(path, query));
+    headers.forEach((k, v) {
+      if (v != null) req.headers[k] = v;
+    });
+    if (token != null) req.headers['Authorization'] = 'Bearer $token';
+    if (body != null) {
+      req.headers['Content-Type'] = 'application/json';
+      req.body = jsonEncode(body);
+    }
+    final resp = await http.Response.fromStream(await _http.send(req));
+    if (resp.statusCode < 200 || resp.statusCode > 299) {
+      throw RoxboxApiException(resp.statusCode, resp.body.trim());
+    }
+    return resp.body;
+  }
+`)
+	for _, path := range sortedKeys(doc.Paths) {
+		item := doc.Paths[path]
+		for _, method := range []string{"get", "post", "put", "delete"} {
+			if op := item[method]; op != nil && !op.WebSocket {
+				genOp(p, path, strings.ToUpper(method), op)
+			}
+		}
+	}
+	p("}")
+}
+
+func genOp(p func(string, ...any), path, method string, op *operation) {
+	var required, optional []genParam
+	var headers []genParam
+	for _, prm := range op.Parameters {
+		gp := genParam{prm, camel(prm.Name)}
+		switch {
+		case prm.In == "header" && prm.Name == "Idempotency-Key":
+			headers = append(headers, gp)
+			optional = append(optional, gp)
+		case prm.In == "header":
+			// profile header: the client passes ?profile= instead
+		case prm.Required:
+			required = append(required, gp)
+		default:
+			optional = append(optional, gp)
+		}
+	}
+	var bodySchema *schema
+	bodyRequired := false
+	if op.RequestBody != nil {
+		if m, ok := op.RequestBody.Content["application/json"]; ok {
+			bodySchema, bodyRequired = m.Schema, op.RequestBody.Required
+		}
+	}
+
+	var resp *schema
+	kind := "text"
+	for ct, m := range op.Responses["200"].Content {
+		switch {
+		case ct == "application/json":
+			resp, kind = m.Schema, "json"
+		case ct != "text/plain":
+			kind = "raw"
+		}
+	}
+
+	var args []string
+	for _, gp := range required {
+		args = append(args, fmt.Sprintf("required %s %s", dartType(gp.Schema), gp.dartName))
+	}
+	if bodySchema != nil && bodyRequired {
+		args = append(args, fmt.Sprintf("required %s body", dartType(bodySchema)))
+	}
+	for _, gp := range optional {
+		if kind == "raw" && gp.In == "header" {
+			continue
+		}
+		args = append(args, fmt.Sprintf("%s? %s", dartType(gp.Schema), gp.dartName))
+	}
+	if bodySchema != nil && !bodyRequired {
+		args = append(args, fmt.Sprintf("%s? body", dartType(bodySchema)))
+	}
+	sig := ""
+	if len(args) > 0 {
+		sig = "{" + strings.Join(args, ", ") + "}"
+	}
+
+	pathExpr := path
+	var query []string
+	for _, gp := range append(required, optional...) {
+		switch gp.In {
+		case "path":
+			pathExpr = strings.ReplaceAll(pathExpr, "{"+gp.Name+"}", "${Uri.encodeComponent("+gp.dartName+".toString())}")
+		case "query":
+			query = append(query, fmt.Sprintf("'%s': %s", gp.Name, gp.dartName))
+		}
+	}
+	queryExpr := "{" + strings.Join(query, ", ") + "}"
+
+	p("")
+	p("  /// %s", op.Summary)
+	if kind == "raw" {
+		p("  Uri %sUri(%s) => _uri('%s', %s);", op.OperationID, sig, pathExpr, queryExpr)
+		return
+	}
+	ret := "String"
+	if kind == "json" {
+		ret = dartType(resp)
+	}
+	p("  Future<%s> %s(%s) async {", ret, op.OperationID, sig)
+	call := fmt.Sprintf("await _send('%s', '%s', %s", method, pathExpr, queryExpr)
+	if bodySchema != nil {
+		b := encode(bodySchema, "body")
+		if !bodyRequired && b != "body" {
+			b = "body == null ? null : " + b
+		}
+		call += ", body: " + b
+	}
+	if len(headers) > 0 {
+		var hs []string
+		for _, h := range headers {
+			hs = append(hs, fmt.Sprintf("'%s': %s", h.Name, h.dartName))
+		}
+		call += ", headers: {" + strings.Join(hs, ", ") + "}"
+	}
+	call += ")"
+	if kind == "json" {
+		p("    final body_ = %s;", call)
+		p("    return %s;", decode(resp, "jsonDecode(body_)"))
+	} else {
+		p("    return %s;", call)
+	}
+	p("  }")
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// ── Daemon mode ───────────────────────────────────────────────────────────────
+// For running roxbox permanently on a home server (systemd, launchd, or as
+// a Windows service — see service_windows.go):
+//
+//	roxbox -daemon [-pidfile /run/roxbox.pid] [-log /var/log/roxbox.log]
+//
+// writes a PID file, logs to a file, and on SIGHUP reloads secrets, API
+// tokens, the extra tracker list and profile settings from disk and reopens
+// the log without dropping streams.
+//
+// A systemd unit only needs:
+//
+//	[Service]
+//	ExecStart=/usr/local/bin/roxbox -daemon -lan
+//	ExecReload=/bin/kill -HUP $MAINPID
+//	Environment=ROXBOX_CACHE=/var/cache/roxbox
+//	Restart=on-failure
+
+var (
+	daemonMode = flag.Bool("daemon", false, "run as a service: PID file, log file, SIGHUP reload")
+	pidPath    = flag.String("pidfile", "", "PID file (default <cache>/roxbox.pid with -daemon)")
+	logPath    = flag.String("log", "", "log file (default <cache>/roxbox.log with -daemon)")
+)
+
+// runService is set by service_windows.go. It reports false when the
+// process wasn't started by the service manager; otherwise it runs serve
+// until the manager asks to stop, calling stop to shut the server down.
+var runService func(serve, stop func()) bool
+
+// setupDaemon applies -log/-pidfile (and their -daemon defaults). Call once
+// cacheDir is known; the returned func removes the PID file.
+func setupDaemon() func() {
+	lp, pp := *logPath, *pidPath
+	if *daemonMode {
+		if lp == "" {
+			lp = filepath.Join(cacheDir, "roxbox.log")
+		}
+		if pp == "" {
+			pp = filepath.Join(cacheDir, "roxbox.pid")
+		}
+	}
+	if lp != "" {
+		setLogFile(lp)
+	}
+	if pp == "" {
+		return func() {}
+	}
+	if err := os.WriteFile(pp, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644); err != nil {
+		log.Printf("pid file %s: %v", pp, err)
+		return func() {}
+	}
+	return func() { _ = os.Remove(pp) }
+}
+
+// reload re-reads what can change on disk while running: the log file
+// handle, secrets, API tokens, extra trackers and each loaded profile's
+// settings.
+func reload() {
+	if activeLog != nil {
+		if err := activeLog.reopen(); err != nil {
+			log.Printf("reload: log: %v", err)
+		}
+	}
+	loadSecrets()
+	loadTokens()
+	loadExtraTrackers()
+
+	profilesMu.Lock()
+	ps := make([]*profile, 0, len(profiles))
+	for _, p := range profiles {
+		ps = append(ps, p)
+	}
+	profilesMu.Unlock()
+	for _, p := range ps {
+		var s profileSettings
+		if err := loadJSONAt(filepath.Join(p.dir, settingsFile), &s); err != nil {
+			log.Printf("reload: profile %s: %v", p.ID, err)
+			continue
+		}
+		if err := s.validate(); err != nil {
+			log.Printf("reload: profile %s: %v; keeping the current settings", p.ID, err)
+			continue
+		}
+		p.mu.Lock()
+		p.settings = s
+		p.mu.Unlock()
+	}
+	log.Println("Reloaded configuration")
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"io"
+
+	"github.com/anacrolix/torrent"
+
+	"github.com/roxbox/torrent_server/engine"
+)
+
+// ── Data saver ────────────────────────────────────────────────────────────────
+// With the profile's data_saver_secs set, a session downloads only what is
+// about to be watched: there is no bulk download of the file, readers fetch
+// a small readahead, and /stream holds back bytes more than that many
+// seconds past the playback position the app reports with /player/state
+// (or past where the request started, right after a seek). Seconds become
+// bytes through the duration the app reports; without one a typical 1080p
+// bitrate is assumed. The app must keep reporting its position, or playback
+// stalls once the window is used up.
+
+const dataSaverDefaultRate = 1 << 20 // bytes/s (~8 Mbit/s) when no duration is known
+
+// dataSaverSecs is the profile's window in seconds; 0 means data saver is off.
+func (p *profile) dataSaverSecs() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return max(p.settings.DataSaverSecs, 0)
+}
+
+// dataSaverWindow returns the window in bytes and the byte at the
+// reported playback position; ok is false when data saver is off.
+func (s *session) dataSaverWindow() (window, playing int64, ok bool) {
+	secs := s.profile.dataSaverSecs()
+	if secs == 0 {
+		return 0, 0, false
+	}
+	s.mu.RLock()
+	f, st := s.file, s.status
+	s.mu.RUnlock()
+	rate := float64(dataSaverDefaultRate)
+	if f != nil && st.DurationSec > 0 {
+		rate = float64(f.Length()) / st.DurationSec
+	}
+	return int64(rate * float64(secs)), int64(rate * st.PositionSec), true
+}
+
+// prioritise sets the file's download priorities: the streaming ones as
+// the add adjusted them, or none at all under data saver (readers fetch
+// what they need).
+func (s *session) prioritise(t *torrent.Torrent, f *torrent.File) {
+	if s.profile.dataSaverSecs() > 0 {
+		f.SetPriority(torrent.PiecePriorityNone)
+		return
+	}
+	s.mu.RLock()
+	prio := s.prio
+	s.mu.RUnlock()
+	f.Download()
+	switch {
+	case prio.Bulk:
+	case prio.HeadBytes > 0 || prio.TailBytes > 0:
+		head, tail := prio.HeadBytes, prio.TailBytes
+		if head == 0 {
+			head = f.Length() / 20
+		}
+		if tail == 0 {
+			tail = f.Length() / 100
+		}
+		engine.PrioritiseFileBounds(t, f, head, tail)
+	default:
+		engine.PrioritiseFile(t, f)
+	}
+}
+
+// windowedReader holds reads back at the data saver window.
+type windowedReader struct {
+	io.ReadSeeker
+	sess *session
+	ctx  context.Context
+	pos  int64
+	base int64 // where the response starts
+	read bool
+}
+
+func (r *windowedReader) Seek(off int64, whence int) (int64, error) {
+	pos, err := r.ReadSeeker.Seek(off, whence)
+	if err == nil {
+		r.pos = pos
+		if !r.read { // http.ServeContent seeks to the range start before reading
+			r.base = pos
+		}
+	}
+	return pos, err
+}
+
+func (r *windowedReader) Read(p []byte) (int, error) {
+	r.read = true
+	for {
+		window, playing, ok := r.sess.dataSaverWindow()
+		if !ok {
+			break
+		}
+		limit := max(playing, r.base) + window
+		if r.pos < limit {
+			if n := limit - r.pos; int64(len(p)) > n {
+				p = p[:n]
+			}
+			break
+		}
+		_, changed := r.sess.statusWatch() // position reports, stats ticks
+		select {
+		case <-changed:
+		case <-r.ctx.Done():
+			return 0, r.ctx.Err()
+		}
+	}
+	n, err := r.ReadSeeker.Read(p)
+	r.pos += int64(n)
+	return n, err
+}
@@ -0,0 +1,249 @@
+//go:build !minimal && !no_debrid
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/roxbox/torrent_server/engine"
+)
+
+// ── Debrid service ────────────────────────────────────────────────────────────
+// With a Real-Debrid API token in the secret store (PUT /secrets?name=
+// realdebrid_token), /add first asks the service whether it already holds
+// the torrent. If it does, the session streams the service's copy over HTTP
+// (httpsource.go), which starts at once and at full line speed; if it
+// doesn't, or anything goes wrong, the add goes to P2P as usual. Torrents
+// the service doesn't have cached are removed from the account again.
+
+const (
+	debridTokenSecret = "realdebrid_token"
+	debridTimeout     = 15 * time.Second // for the whole lookup
+)
+
+var realDebridAPI = "https://api.real-debrid.com/rest/1.0"
+
+var debridClient = &http.Client{Timeout: debridTimeout}
+
+func init() {
+	registerModule(module{
+		Name:   "debrid",
+		Source: debridSource,
+		Probe: func() capability {
+			if getSecret(debridTokenSecret) == "" {
+				return capability{Compiled: true, Detail: "store a Real-Debrid API token as secret " + debridTokenSecret}
+			}
+			return capability{Compiled: true, Enabled: true, Detail: "real-debrid"}
+		},
+	})
+}
+
+type rdFile struct {
+	ID       int    `json:"id"`
+	Path     string `json:"path"`
+	Bytes    int64  `json:"bytes"`
+	Selected int    `json:"selected"`
+}
+
+type rdTorrent struct {
+	ID     string   `json:"id"`
+	Status string   `json:"status"`
+	Files  []rdFile `json:"files"`
+	Links  []string `json:"links"`
+}
+
+// debridSource is the module's Source hook.
+func debridSource(s *session, req addRequest, requestID string) *httpSource {
+	token := getSecret(debridTokenSecret)
+	if token == "" {
+		return nil
+	}
+	hash, err := req.infoHash()
+	if err != nil {
+		return nil
+	}
+	s.mu.RLock()
+	cur := s.http
+	s.mu.RUnlock()
+	if cur != nil && cur.InfoHash == hash {
+		return cur // a repeated add; addSource answers "exists"
+	}
+	magnet := req.Magnet
+	if magnet == "" {
+		magnet = "magnet:?xt=urn:btih:" + hash
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), debridTimeout)
+	defer cancel()
+	rd := rdClient{ctx: ctx, token: token}
+	link, err := rd.cachedLink(magnet, req)
+	if err != nil {
+		debugf("debrid", "%s: %v", hash, err)
+		s.event(requestID, "debrid: "+err.Error()+"; using P2P")
+		return nil
+	}
+	_, src, err := fetchSource(link, fetchAuth{})
+	if err != nil || src == nil {
+		debugf("debrid", "%s: fetch %s: %v", hash, link, err)
+		return nil
+	}
+	src.InfoHash, src.Provider = hash, "real-debrid"
+	s.event(requestID, "debrid: streaming the real-debrid copy")
+	return src
+}
+
+type rdClient struct {
+	ctx   context.Context
+	token string
+}
+
+// cachedLink returns a direct download link for the file req asks for, if
+// the service has the torrent cached.
+func (rd rdClient) cachedLink(magnet string, req addRequest) (string, error) {
+	var added struct {
+		ID string `json:"id"`
+	}
+	if err := rd.call("POST", "/torrents/addMagnet", url.Values{"magnet": {magnet}}, &added); err != nil {
+		return "", err
+	}
+	keep := false
+	defer func() {
+		if !keep { // on its own context: the lookup's may have run out
+			_ = rdClient{ctx: context.Background(), token: rd.token}.call("DELETE", "/torrents/delete/"+added.ID, nil, nil)
+		}
+	}()
+
+	// Cached torrents pass magnet conversion and file selection at once
+	// and come back "downloaded".
+	t, err := rd.info(added.ID, "waiting_files_selection")
+	if err != nil {
+		return "", err
+	}
+	if t.Status == "waiting_files_selection" {
+		if err := rd.call("POST", "/torrents/selectFiles/"+added.ID, url.Values{"files": {"all"}}, nil); err != nil {
+			return "", err
+		}
+		if t, err = rd.info(added.ID, "downloaded"); err != nil {
+			return "", err
+		}
+	}
+	if t.Status != "downloaded" {
+		return "", fmt.Errorf("not cached (%s)", t.Status)
+	}
+
+	var selected []rdFile
+	for _, f := range t.Files {
+		if f.Selected == 1 {
+			selected = append(selected, f)
+		}
+	}
+	sort.Slice(selected, func(i, j int) bool { return selected[i].ID < selected[j].ID })
+	i := pickDebridFile(selected, req)
+	if i < 0 || i >= len(t.Links) {
+		return "", fmt.Errorf("no playable file")
+	}
+
+	var un struct {
+		Download string `json:"download"`
+	}
+	if err := rd.call("POST", "/unrestrict/link", url.Values{"link": {t.Links[i]}}, &un); err != nil {
+		return "", err
+	}
+	keep = true
+	return un.Download, nil
+}
+
+// info polls the torrent for a few seconds until it reaches status (or is
+// downloaded already).
+func (rd rdClient) info(id, status string) (rdTorrent, error) {
+	var t rdTorrent
+	for tries := 0; ; tries++ {
+		if err := rd.call("GET", "/torrents/info/"+id, nil, &t); err != nil {
+			return t, err
+		}
+		if t.Status == status || t.Status == "downloaded" || tries == 5 {
+			return t, nil
+		}
+		select {
+		case <-time.After(500 * time.Millisecond):
+		case <-rd.ctx.Done():
+			return t, rd.ctx.Err()
+		}
+	}
+}
+
+// pickDebridFile chooses as /add would: file_index, file, the episode
+// hint, then the largest video.
+func pickDebridFile(files []rdFile, req addRequest) int {
+	if req.FileIndex != nil {
+		for i, f := range files {
+			if f.ID == *req.FileIndex+1 { // the service numbers torrent files from 1
+				return i
+			}
+		}
+		return -1
+	}
+	if req.File != "" {
+		for i, f := range files {
+			if strings.HasSuffix("/"+req.File, f.Path) {
+				return i
+			}
+		}
+	}
+	want, hasEp := engine.ParseEpisode(req.Episode)
+	best := -1
+	for i, f := range files {
+		if !engine.IsVideo(f.Path) {
+			continue
+		}
+		if hasEp {
+			if ep, ok := engine.ParseEpisode(f.Path); ok && ep == want {
+				return i
+			}
+		}
+		if best < 0 || f.Bytes > files[best].Bytes {
+			best = i
+		}
+	}
+	return best
+}
+
+// call makes an API request; out (optional) receives the JSON answer.
+func (rd rdClient) call(method, path string, form url.Values, out any) error {
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+	req, err := http.NewRequestWithContext(rd.ctx, method, realDebridAPI+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+rd.token)
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	resp, err := debridClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		var e struct {
+			Error string `json:"error"`
+		}
+		_ = json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&e)
+		return fmt.Errorf("%s %s: %s %s", method, path, resp.Status, e.Error)
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+
+	"github.com/roxbox/torrent_server/engine"
+)
+
+// ── GET /debug/stats ──────────────────────────────────────────────────────────
+// Counters of the engine's adaptive machinery, for tuning it on a device:
+// the disk I/O throttle (iothrottle.go), the request pipelines
+// (pipeline.go) and the Go runtime.
+
+type debugStats struct {
+	IO         engine.IOThrottleStats `json:"io"`
+	Pipeline   engine.PipelineStats   `json:"pipeline"`
+	Goroutines int                    `json:"goroutines"`
+	HeapMB     float64                `json:"heap_mb"`
+}
+
+func handleDebugStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "GET only", 405)
+		return
+	}
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(debugStats{
+		IO:         engine.DefaultIOThrottle.Stats(),
+		Pipeline:   engine.DefaultPipeline.Stats(),
+		Goroutines: runtime.NumGoroutine(),
+		HeapMB:     float64(ms.HeapAlloc) / (1 << 20),
+	})
+}
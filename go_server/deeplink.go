@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+const maxPageSize = 2 << 20 // pages bigger than 2 MB are not link pages
+
+// defaultLinkSelectors find the first magnet or .torrent link in a page.
+// A selector with a capture group yields group 1, otherwise the whole match.
+// ROXBOX_LINK_SELECTORS (newline separated) overrides the defaults.
+var defaultLinkSelectors = []string{
+	`magnet:\?[^\s"'<>]+`,
+	`href\s*=\s*["']([^"']+\.torrent(?:\?[^"']*)?)["']`,
+}
+
+func linkSelectors(extra []string) ([]*regexp.Regexp, error) {
+	exprs := defaultLinkSelectors
+	if env := strings.TrimSpace(os.Getenv("ROXBOX_LINK_SELECTORS")); env != "" {
+		exprs = strings.Split(env, "\n")
+	}
+	// Per-request selectors are tried first.
+	exprs = append(append([]string{}, extra...), exprs...)
+
+	var res []*regexp.Regexp
+	for _, e := range exprs {
+		if e = strings.TrimSpace(e); e == "" {
+			continue
+		}
+		re, err := regexp.Compile(e)
+		if err != nil {
+			return nil, fmt.Errorf("selector %q: %v", e, err)
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+// resolvePageLink fetches pageURL and returns the magnet / .torrent link it
+// points at. If the URL already serves a .torrent, its metainfo is returned
+// directly instead.
+func resolvePageLink(pageURL string, selectors []*regexp.Regexp, auth fetchAuth) (string, *metainfo.MetaInfo, error) {
+	req, err := newFetchRequest(context.Background(), pageURL, auth)
+	if err != nil {
+		return "", nil, err
+	}
+	resp, err := fetchClient.Do(req)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", nil, fmt.Errorf("fetch %s: %s", pageURL, resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxPageSize))
+	if err != nil {
+		return "", nil, err
+	}
+
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/x-bittorrent") ||
+		bytes.HasPrefix(body, []byte("d8:announce")) || bytes.HasPrefix(body, []byte("d4:info")) {
+		mi, err := metainfo.Load(bytes.NewReader(body))
+		if err != nil {
+			return "", nil, fmt.Errorf("parse %s: %w", pageURL, err)
+		}
+		return "", mi, nil
+	}
+
+	for _, re := range selectors {
+		m := re.FindSubmatch(body)
+		if m == nil {
+			continue
+		}
+		link := string(m[0])
+		if len(m) > 1 {
+			link = string(m[1])
+		}
+		link = html.UnescapeString(link)
+		if strings.HasPrefix(link, "magnet:") {
+			return link, nil, nil
+		}
+		base, _ := url.Parse(pageURL)
+		ref, err := url.Parse(link)
+		if err != nil {
+			continue
+		}
+		return base.ResolveReference(ref).String(), nil, nil
+	}
+	return "", nil, fmt.Errorf("no magnet or .torrent link found on %s", pageURL)
+}
+
+// ── POST /add/url?url=<page>[&selector=<regexp>...] ──────────────────────────
+// Accepts whatever URL a browser "open with" hands the app: a magnet, a
+// direct .torrent link, or an indexer page containing one of those.
+func handleAddURL(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST only", 405)
+		return
+	}
+	sess := sessionFor(w, r)
+	if sess == nil {
+		return
+	}
+	_ = r.ParseForm()
+	pageURL := strings.TrimSpace(r.FormValue("url"))
+	if pageURL == "" {
+		http.Error(w, "url param required", 400)
+		return
+	}
+
+	var (
+		link string
+		mi   *metainfo.MetaInfo
+		auth = authForm(r).forURL(pageURL) // for the page only (sitecreds.go)
+	)
+	if strings.HasPrefix(pageURL, "magnet:") {
+		link = pageURL
+	} else {
+		if !strings.HasPrefix(pageURL, "http://") && !strings.HasPrefix(pageURL, "https://") {
+			http.Error(w, "url must be magnet or http(s)", 400)
+			return
+		}
+		selectors, err := linkSelectors(r.Form["selector"])
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		link, mi, err = resolvePageLink(pageURL, selectors, auth)
+		if err != nil {
+			http.Error(w, err.Error(), 422)
+			return
+		}
+	}
+
+	id, ok := sess.start(addOptions{RequestID: requestID(r)}, func() (*torrent.Torrent, error) {
+		if mi != nil {
+			return sess.profile.addMetainfo(mi)
+		}
+		return sess.profile.addByURI(link, auth)
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"status": addStatus(ok), "add_id": id, "link": link})
+}
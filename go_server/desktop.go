@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"net"
+	"os"
+	"path/filepath"
+)
+
+// ── Desktop companion mode ────────────────────────────────────────────────────
+// The same binary runs on Windows, macOS and Linux. -desktop switches the
+// cache to the OS's per-user cache dir and, in builds with the "tray" tag,
+// shows a system-tray icon. -lan listens on all interfaces so phones and TVs
+// on the home network can play from it (the control API is exposed too,
+// so only use it on trusted networks).
+
+var (
+	desktopMode = flag.Bool("desktop", false, "desktop mode: per-user cache dir and tray icon (tray builds)")
+	lanMode     = flag.Bool("lan", false, "listen on all interfaces so LAN devices can stream")
+)
+
+// runTray is set by tray.go in builds with the "tray" tag. It blocks on the
+// main goroutine until the user quits, then calls quit.
+var runTray func(quit func())
+
+// stopTray closes the tray when the server is shut down by a signal.
+var stopTray = func() {}
+
+// defaultCacheDir is used when ROXBOX_CACHE is unset.
+func defaultCacheDir() string {
+	if *desktopMode {
+		// ~/.cache, ~/Library/Caches or %LocalAppData%
+		if d, err := os.UserCacheDir(); err == nil {
+			return filepath.Join(d, "roxbox")
+		}
+	}
+	return filepath.Join(os.TempDir(), "roxbox_torrent")
+}
+
+// listenHost is the interface the HTTP server binds to.
+func listenHost() string {
+	if *lanMode {
+		return "0.0.0.0"
+	}
+	return "127.0.0.1"
+}
+
+// advertiseHost is the host put in stream URLs: loopback normally, this
+// machine's LAN address in -lan mode so the URL works from other devices.
+func advertiseHost() string {
+	if !*lanMode {
+		return "127.0.0.1"
+	}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return "127.0.0.1"
+	}
+	for _, a := range addrs {
+		if ipn, ok := a.(*net.IPNet); ok {
+			if ip := ipn.IP.To4(); ip != nil && ip.IsPrivate() {
+				return ip.String()
+			}
+		}
+	}
+	return "127.0.0.1"
+}
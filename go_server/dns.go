@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/roxbox/torrent_server/engine"
+)
+
+// ── Tracker DNS ───────────────────────────────────────────────────────────────
+// Carrier DNS often refuses tracker domains. With -dns (or ROXBOX_DNS) the
+// client resolves tracker hostnames, for HTTP and UDP trackers alike, with
+// the given servers instead of the system's:
+//
+//	-dns 1.1.1.1,9.9.9.9:53                   plain DNS, tried in turn
+//	-dns https://cloudflare-dns.com/dns-query DNS-over-HTTPS (RFC 8484)
+//
+// A DoH URL by IP (https://1.1.1.1/dns-query) avoids resolving the DoH
+// server itself through the blocked DNS.
+
+var dnsFlag = flag.String("dns", "", "DNS servers (host[:port],…) or a DNS-over-HTTPS URL for tracker hostnames (env ROXBOX_DNS)")
+
+const dnsTimeout = 10 * time.Second
+
+// applyDNS points tracker lookups at the configured resolver. They go
+// through the engine's dual-stack dialer (engine/dualstack.go).
+func applyDNS() {
+	spec := *dnsFlag
+	if spec == "" {
+		spec = os.Getenv("ROXBOX_DNS")
+	}
+	if spec == "" {
+		return
+	}
+	res, err := newResolver(spec)
+	if err != nil {
+		log.Fatalf("-dns: %v", err)
+	}
+	engine.DefaultDualStack.Resolver = res
+	log.Printf("Tracker DNS: %s", spec)
+}
+
+// newResolver builds a resolver for a -dns value.
+func newResolver(spec string) (*net.Resolver, error) {
+	if strings.HasPrefix(spec, "https://") {
+		if _, err := url.Parse(spec); err != nil {
+			return nil, err
+		}
+		return &net.Resolver{PreferGo: true, Dial: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return &dohConn{ctx: ctx, url: spec}, nil
+		}}, nil
+	}
+	var servers []string
+	for _, s := range strings.Split(spec, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(s); err != nil {
+			s = net.JoinHostPort(s, "53")
+		}
+		host, _, _ := net.SplitHostPort(s)
+		if net.ParseIP(host) == nil {
+			return nil, fmt.Errorf("%q: DNS servers must be IP addresses", s)
+		}
+		servers = append(servers, s)
+	}
+	if len(servers) == 0 {
+		return nil, errors.New("no DNS servers")
+	}
+	var next atomic.Uint32
+	return &net.Resolver{PreferGo: true, Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+		server := servers[int(next.Add(1)-1)%len(servers)] // a retry goes to the next one
+		var d net.Dialer
+		return d.DialContext(ctx, network, server)
+	}}, nil
+}
+
+var dohClient = &http.Client{Timeout: dnsTimeout}
+
+// dohConn carries the Go resolver's DNS-over-TCP exchange over HTTPS: each
+// length-prefixed query written is POSTed to the DoH server and its answer
+// queued, length-prefixed, for reading.
+type dohConn struct {
+	ctx   context.Context
+	url   string
+	reply bytes.Buffer
+}
+
+func (c *dohConn) Write(b []byte) (int, error) {
+	if len(b) < 2 || int(b[0])<<8|int(b[1]) != len(b)-2 {
+		return 0, errors.New("doh: expected one length-prefixed DNS message")
+	}
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodPost, c.url, bytes.NewReader(b[2:]))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+	resp, err := dohClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("doh: %s", resp.Status)
+	}
+	msg, err := io.ReadAll(io.LimitReader(resp.Body, 65535))
+	if err != nil {
+		return 0, err
+	}
+	c.reply.Write([]byte{byte(len(msg) >> 8), byte(len(msg))})
+	c.reply.Write(msg)
+	return len(b), nil
+}
+
+func (c *dohConn) Read(b []byte) (int, error) { return c.reply.Read(b) }
+
+func (c *dohConn) Close() error                     { return nil }
+func (c *dohConn) LocalAddr() net.Addr              { return dohAddr{} }
+func (c *dohConn) RemoteAddr() net.Addr             { return dohAddr{} }
+func (c *dohConn) SetDeadline(time.Time) error      { return nil }
+func (c *dohConn) SetReadDeadline(time.Time) error  { return nil }
+func (c *dohConn) SetWriteDeadline(time.Time) error { return nil }
+
+type dohAddr struct{}
+
+func (dohAddr) Network() string { return "doh" }
+func (dohAddr) String() string  { return "doh" }
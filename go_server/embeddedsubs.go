@@ -0,0 +1,384 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/types"
+
+	"github.com/roxbox/torrent_server/engine"
+)
+
+// ── GET /subtitles/embedded · GET /subtitles/embedded/{track}[.vtt] ──────────
+// The text subtitle tracks inside the streamed Matroska video, as sidecar
+// files: many Android players can't pick an embedded track from an HTTP
+// stream, but all of them load a subtitle URL. /subtitles/embedded lists
+// the tracks (reading the file's header and its cues, the seek index);
+// /subtitles/embedded/{track} serves one in its own format (SubRip, ASS or
+// WebVTT) and {track}.vtt as WebVTT.
+//
+// When the cues point at a track's blocks (mkvmerge writes them so), the
+// pieces holding them are raised to high priority, below the player's
+// window, and each block is read where it lies; they get their priority
+// back once the whole track is read, or when the session switches to
+// another file. A track the cues don't index can only be read from whole
+// clusters. Either way an answer holds what is on disk so far and says
+// with X-Roxbox-Complete whether that is the whole track; the app asks
+// again later for the rest, or passes wait=true to have the answer wait
+// for every block (for an unindexed track, that is the whole file). What
+// has been read is kept for the session's file, so asking again only reads
+// what's new.
+
+const (
+	embeddedReadahead = 256 << 10 // blocks are small; don't prefetch video around them
+	embeddedCacheMax  = 8
+)
+
+type embeddedSubtitle struct {
+	Track   uint64 `json:"track"` // the Matroska track number; /subtitles/embedded/{track} serves it
+	Codec   string `json:"codec"`
+	Format  string `json:"format"` // "srt" | "ass" | "vtt"
+	Lang    string `json:"lang,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Default bool   `json:"default"`
+	Forced  bool   `json:"forced"`
+	Indexed bool   `json:"indexed"` // the cues point at its blocks: it arrives ahead of the video
+	URL     string `json:"url"`
+	VTTURL  string `json:"vtt_url"`
+}
+
+type embeddedSubtitlesResponse struct {
+	File      string             `json:"file"`
+	Subtitles []embeddedSubtitle `json:"subtitles"`
+	Note      string             `json:"note,omitempty"`
+}
+
+// mkvSource is the streamed file, for reading at random.
+type mkvSource struct {
+	key  string // what the extraction cache knows it by
+	r    io.ReaderAt
+	size int64
+	have func(off, n int64) bool // nil: all on disk
+	t    *torrent.Torrent
+	f    *torrent.File
+	done func()
+}
+
+// mkvExtract is a file's parsed header and the blocks read from it.
+type mkvExtract struct {
+	mkv *engine.MKV
+
+	mu     sync.Mutex
+	blocks map[uint64]map[engine.MKVCue][]engine.MKVBlock // track → where → blocks
+	raised map[uint64]map[int]types.PiecePriority         // track → piece raised → its priority before
+	t      *torrent.Torrent
+	f      *torrent.File
+}
+
+var mkvCache struct {
+	sync.Mutex
+	m map[string]*mkvExtract
+}
+
+// ctxReaderAt reads a torrent file at random, waiting for pieces until ctx
+// is done.
+type ctxReaderAt struct {
+	mu   sync.Mutex
+	ctx  context.Context
+	r    torrent.Reader
+	size int64
+}
+
+func (a *ctxReaderAt) ReadAt(b []byte, off int64) (int, error) {
+	if off >= a.size {
+		return 0, io.EOF
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, err := a.r.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+	// Exactly to EOF: a file's reader may hand out the rest of its last piece.
+	n, err := io.ReadFull(ctxReader{a.ctx, a.r}, b[:min(int64(len(b)), a.size-off)])
+	if err == nil && n < len(b) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// mkvSourceOf opens the session's streamed file; close it with done.
+func mkvSourceOf(ctx context.Context, sess *session) (*mkvSource, int, error) {
+	sess.mu.RLock()
+	t, f, local, src := sess.torr, sess.file, sess.local, sess.http
+	sess.mu.RUnlock()
+	switch {
+	case local != nil:
+		fh, err := os.Open(local.Path)
+		if err != nil {
+			return nil, 500, err
+		}
+		return &mkvSource{key: "local:" + local.Path, r: fh, size: local.Size, done: func() { fh.Close() }}, 0, nil
+	case src != nil:
+		return nil, 409, errors.New("a video URL has no embedded subtitles to read here")
+	case f == nil:
+		return nil, 503, errors.New("no active torrent")
+	}
+	reader := engine.NewReader(f, embeddedReadahead)
+	span := engine.PieceRange(f)
+	have := func(off, n int64) bool {
+		for i := span.PieceAt(off); i >= 0 && i <= span.PieceAt(off+n-1); i++ {
+			if !t.PieceState(i).Complete {
+				return false
+			}
+		}
+		return true
+	}
+	return &mkvSource{key: t.InfoHash().HexString() + "/" + f.Path(), r: &ctxReaderAt{ctx: ctx, r: reader, size: f.Length()},
+		size: f.Length(), have: have, t: t, f: f, done: func() { reader.Close() }}, 0, nil
+}
+
+// extractOf answers the cached extraction of src, parsing its header the
+// first time.
+func extractOf(src *mkvSource) (*mkvExtract, error) {
+	mkvCache.Lock()
+	ex := mkvCache.m[src.key]
+	mkvCache.Unlock()
+	if ex != nil {
+		return ex, nil
+	}
+	m, err := engine.ParseMKV(src.r, src.size)
+	if err != nil {
+		return nil, err
+	}
+	ex = &mkvExtract{mkv: m, blocks: map[uint64]map[engine.MKVCue][]engine.MKVBlock{}, raised: map[uint64]map[int]types.PiecePriority{},
+		t: src.t, f: src.f}
+	mkvCache.Lock()
+	var dropped map[string]*mkvExtract
+	if mkvCache.m == nil || len(mkvCache.m) >= embeddedCacheMax {
+		dropped, mkvCache.m = mkvCache.m, map[string]*mkvExtract{}
+	}
+	if had := mkvCache.m[src.key]; had != nil {
+		ex = had // another request parsed it meanwhile
+	} else {
+		mkvCache.m[src.key] = ex
+	}
+	mkvCache.Unlock()
+	for _, old := range dropped {
+		old.lowerAll() // whoever still reads it raises them again
+	}
+	return ex, nil
+}
+
+// read collects track's blocks: those on disk, or every one with wait.
+// It answers them in file order and whether that is all of them.
+func (ex *mkvExtract) read(src *mkvSource, track uint64, wait bool) ([]engine.MKVBlock, bool, error) {
+	have := src.have
+	if wait {
+		have = nil
+	}
+	cues, complete := ex.mkv.CuesFor(track), true
+	if len(cues) > 0 {
+		ex.mu.Lock()
+		if _, ok := ex.raised[track]; !ok && src.t != nil {
+			ex.raised[track] = raiseCuePieces(src, cues)
+		}
+		ex.mu.Unlock()
+	} else {
+		cues, complete = ex.mkv.Clusters(src.r, track, have)
+	}
+	var out []engine.MKVBlock
+	for _, c := range cues {
+		ex.mu.Lock()
+		bl, ok := ex.blocks[track][c]
+		ex.mu.Unlock()
+		if !ok {
+			var err error
+			bl, err = ex.mkv.ReadCue(src.r, c, have)
+			if errors.Is(err, engine.ErrMKVNotPresent) {
+				complete = false
+				continue
+			}
+			if err != nil {
+				return nil, false, err
+			}
+			ex.mu.Lock()
+			if ex.blocks[track] == nil {
+				ex.blocks[track] = map[engine.MKVCue][]engine.MKVBlock{}
+			}
+			ex.blocks[track][c] = bl
+			ex.mu.Unlock()
+		}
+		out = append(out, bl...)
+	}
+	if complete {
+		ex.mu.Lock()
+		ex.lower(track, false)
+		ex.mu.Unlock()
+	}
+	return out, complete, nil
+}
+
+// lower gives the pieces raised for track their priority back, or none
+// when the file is no longer streamed, and marks the track as done with
+// them unless forget. Caller holds ex.mu.
+func (ex *mkvExtract) lower(track uint64, forget bool) {
+	for i, was := range ex.raised[track] {
+		if forget {
+			was = torrent.PiecePriorityNone
+		}
+		ex.t.Piece(i).SetPriority(was)
+	}
+	if forget {
+		delete(ex.raised, track)
+	} else if ex.raised[track] != nil {
+		ex.raised[track] = map[int]types.PiecePriority{}
+	}
+}
+
+// lowerEmbeddedCues undoes raiseCuePieces for f, which the session stops
+// streaming; asking for its subtitles again raises them again.
+func lowerEmbeddedCues(f *torrent.File) {
+	mkvCache.Lock()
+	var exs []*mkvExtract
+	for _, ex := range mkvCache.m {
+		if ex.f == f {
+			exs = append(exs, ex)
+		}
+	}
+	mkvCache.Unlock()
+	for _, ex := range exs {
+		ex.lowerAll()
+	}
+}
+
+// lowerAll lowers every track's pieces.
+func (ex *mkvExtract) lowerAll() {
+	ex.mu.Lock()
+	defer ex.mu.Unlock()
+	for track := range ex.raised {
+		ex.lower(track, true)
+	}
+}
+
+// raiseCuePieces asks for the pieces holding the cues' blocks ahead of the
+// rest of the file. The player's window (readahead priority) still comes
+// first. It answers the pieces it raised and their priorities before.
+func raiseCuePieces(src *mkvSource, cues []engine.MKVCue) map[int]types.PiecePriority {
+	raised := map[int]types.PiecePriority{}
+	span := engine.PieceRange(src.f)
+	seen := map[int]bool{}
+	for _, c := range cues {
+		for _, off := range []int64{c.Cluster, c.Cluster + 16 + max(c.Relative, 0)} {
+			i := span.PieceAt(off)
+			if i < 0 || seen[i] {
+				continue
+			}
+			seen[i] = true
+			if ps := src.t.PieceState(i); !ps.Complete && ps.Priority < torrent.PiecePriorityHigh {
+				raised[i] = ps.Priority
+				src.t.Piece(i).SetPriority(torrent.PiecePriorityHigh)
+			}
+		}
+	}
+	return raised
+}
+
+// serveEmbedded answers /subtitles/embedded[/…] for sess
+// (/torrents/{hash}/subtitles/embedded[/…] too); rest is what follows
+// "embedded/".
+func serveEmbedded(w http.ResponseWriter, r *http.Request, sess *session, rest string) {
+	wait := r.URL.Query().Get("wait") == "true"
+	timeout := mediaProbeTimeout
+	if wait {
+		timeout = 30 * time.Minute // the request's own context ends it sooner
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+	src, code, err := mkvSourceOf(ctx, sess)
+	if err != nil {
+		http.Error(w, err.Error(), code)
+		return
+	}
+	defer src.done()
+	sess.active()
+	ex, err := extractOf(src)
+	if rest == "" {
+		writeEmbedded(w, sess, src, ex, err)
+		return
+	}
+	if err != nil {
+		http.Error(w, "reading the Matroska header: "+err.Error(), 422)
+		return
+	}
+	num, vtt := strings.CutSuffix(rest, ".vtt")
+	n, err := strconv.ParseUint(num, 10, 64)
+	track, ok := ex.mkv.Track(n)
+	if err != nil || !ok || engine.MKVSubtitleFormat(track.CodecID) == "" {
+		http.Error(w, "no such text subtitle track", 404)
+		return
+	}
+	blocks, complete, err := ex.read(src, n, wait)
+	if err != nil {
+		http.Error(w, "reading track "+num+": "+err.Error(), 504)
+		return
+	}
+	for i := range blocks {
+		blocks[i].Data = bytes.ToValidUTF8(blocks[i].Data, []byte("\uFFFD"))
+	}
+	format := engine.MKVSubtitleFormat(track.CodecID)
+	ctype := "text/plain; charset=utf-8"
+	var text string
+	switch {
+	case vtt && format == "ass":
+		text = engine.SRTToVTT(engine.MKVSubtitleText(engine.MKVTrack{CodecID: "S_TEXT/UTF8"}, engine.MKVAssToSRT(blocks)))
+	case vtt && format == "srt":
+		text = engine.SRTToVTT(engine.MKVSubtitleText(track, blocks))
+	default:
+		text = engine.MKVSubtitleText(track, blocks)
+	}
+	if vtt || format == "vtt" {
+		ctype, format = "text/vtt; charset=utf-8", "vtt"
+	}
+	w.Header().Set("Content-Type", ctype)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Roxbox-Complete", strconv.FormatBool(complete))
+	w.Header().Set("X-Roxbox-Cues", strconv.Itoa(len(blocks)))
+	base := path.Base(filepath.ToSlash(src.key))
+	name := strings.TrimSuffix(base, path.Ext(base)) + "." + num + "." + format
+	http.ServeContent(w, r, name, time.Time{}, strings.NewReader(text))
+}
+
+// writeEmbedded answers /subtitles/embedded.
+func writeEmbedded(w http.ResponseWriter, sess *session, src *mkvSource, ex *mkvExtract, err error) {
+	out := embeddedSubtitlesResponse{File: src.key, Subtitles: []embeddedSubtitle{}}
+	if src.f != nil {
+		out.File = src.f.DisplayPath()
+	} else if _, p, ok := strings.Cut(src.key, ":"); ok {
+		out.File = p
+	}
+	switch {
+	case err != nil:
+		out.Note = "reading the Matroska header: " + err.Error()
+	default:
+		for _, t := range ex.mkv.SubtitleTracks() {
+			n := strconv.FormatUint(t.Number, 10)
+			out.Subtitles = append(out.Subtitles, embeddedSubtitle{Track: t.Number, Codec: t.CodecID, Format: engine.MKVSubtitleFormat(t.CodecID),
+				Lang: t.Lang, Name: t.Name, Default: t.Default, Forced: t.Forced, Indexed: ex.mkv.Indexed(t.Number),
+				URL: sess.subtitleURL("embedded/" + n), VTTURL: sess.subtitleURL("embedded/" + n + ".vtt")})
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(out)
+}
@@ -0,0 +1,58 @@
+package engine
+
+import (
+	"math"
+
+	"github.com/anacrolix/torrent"
+)
+
+// Swarm availability as distributed copies: the fewest copies of any piece
+// among the connected peers, plus the share of pieces that have more than
+// that. 1.5 means every piece is held by at least one peer and half of
+// them by two or more; below 1 some piece has no source at all, and
+// playback will stall when it gets there unless a peer with it shows up.
+
+// PieceCopies counts, for each piece of t, the connected peers that have
+// it. It is nil before the metadata.
+func PieceCopies(t *torrent.Torrent) []int {
+	if t.Info() == nil {
+		return nil
+	}
+	copies := make([]int, t.NumPieces())
+	for _, pc := range t.PeerConns() {
+		pc.PeerPieces().Iterate(func(i uint32) bool {
+			if int(i) < len(copies) {
+				copies[i]++
+			}
+			return true
+		})
+	}
+	return copies
+}
+
+// DistributedCopies is the availability of pieces by copies, from
+// PieceCopies, rounded to three decimals; nil pieces means all of them.
+// With no pieces it is 0.
+func DistributedCopies(copies []int, pieces []int) float64 {
+	if pieces == nil {
+		pieces = make([]int, len(copies))
+		for i := range pieces {
+			pieces[i] = i
+		}
+	}
+	if len(pieces) == 0 {
+		return 0
+	}
+	least := math.MaxInt
+	for _, i := range pieces {
+		least = min(least, copies[i])
+	}
+	more := 0
+	for _, i := range pieces {
+		if copies[i] > least {
+			more++
+		}
+	}
+	d := float64(least) + float64(more)/float64(len(pieces))
+	return math.Round(d*1000) / 1000
+}
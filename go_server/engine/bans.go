@@ -0,0 +1,116 @@
+package engine
+
+import (
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/iplist"
+)
+
+// Banned peers. The client bans an IP that sends data failing the piece
+// hash or breaks the protocol, but only until it's closed. A BanList keeps
+// bans with an expiry and blocks them in every client it's applied to, so
+// the server can persist them across restarts and client rebuilds.
+
+// Ban is one banned IP.
+type Ban struct {
+	IP      netip.Addr `json:"ip"`
+	Reason  string     `json:"reason,omitempty"`
+	Source  string     `json:"source"` // "client" (corrupt data, protocol violation) | "manual"
+	Added   time.Time  `json:"added"`
+	Expires time.Time  `json:"expires"`
+}
+
+// BanList is a set of bans. Its methods are safe for concurrent use.
+type BanList struct {
+	mu   sync.Mutex
+	bans map[netip.Addr]Ban
+}
+
+// DefaultBans is the one NewClientConfig installs.
+var DefaultBans = &BanList{}
+
+// Apply blocks the list's IPs in cfg, on top of its existing blocklist.
+func (b *BanList) Apply(cfg *torrent.ClientConfig) {
+	cfg.IPBlocklist = banRanger{b, cfg.IPBlocklist}
+}
+
+// Add bans b.IP, replacing any earlier ban of it.
+func (b *BanList) Add(ban Ban) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.bans == nil {
+		b.bans = map[netip.Addr]Ban{}
+	}
+	b.bans[ban.IP.Unmap()] = ban
+}
+
+// Remove lifts the ban of ip; false if it wasn't banned.
+func (b *BanList) Remove(ip netip.Addr) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	_, ok := b.bans[ip.Unmap()]
+	delete(b.bans, ip.Unmap())
+	return ok
+}
+
+// Has reports whether ip is banned now.
+func (b *BanList) Has(ip netip.Addr) bool {
+	_, ok := b.lookup(ip)
+	return ok
+}
+
+// List returns the bans that haven't expired, dropping the rest.
+func (b *BanList) List() []Ban {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	out := make([]Ban, 0, len(b.bans))
+	for ip, ban := range b.bans {
+		if now.After(ban.Expires) {
+			delete(b.bans, ip)
+			continue
+		}
+		out = append(out, ban)
+	}
+	return out
+}
+
+func (b *BanList) lookup(ip netip.Addr) (Ban, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ban, ok := b.bans[ip.Unmap()]
+	if !ok || time.Now().After(ban.Expires) {
+		return Ban{}, false
+	}
+	return ban, true
+}
+
+// banRanger is the list in front of the client's other blocklist.
+type banRanger struct {
+	bans *BanList
+	next iplist.Ranger // may be nil
+}
+
+func (r banRanger) Lookup(ip net.IP) (iplist.Range, bool) {
+	if addr, ok := netip.AddrFromSlice(ip); ok {
+		if ban, ok := r.bans.lookup(addr); ok {
+			return iplist.Range{First: ip, Last: ip, Description: "banned: " + ban.Reason}, true
+		}
+	}
+	if r.next != nil {
+		return r.next.Lookup(ip)
+	}
+	return iplist.Range{}, false
+}
+
+func (r banRanger) NumRanges() int {
+	n := 1
+	if r.next != nil {
+		n += r.next.NumRanges()
+	}
+	return n
+}
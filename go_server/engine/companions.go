@@ -0,0 +1,83 @@
+package engine
+
+import (
+	"path"
+	"strings"
+
+	"github.com/anacrolix/torrent"
+)
+
+// Companion is a sidecar file that belongs with the streamed video.
+type Companion struct {
+	File *torrent.File
+	Kind string // "subtitle" | "audio"
+	Lang string // from "Movie.en.srt"-style names, if present
+}
+
+var companionKinds = map[string]string{
+	".srt": "subtitle", ".ass": "subtitle", ".ssa": "subtitle",
+	".vtt": "subtitle", ".idx": "subtitle", ".sub": "subtitle",
+	".ac3": "audio", ".eac3": "audio", ".dts": "audio",
+	".aac": "audio", ".mka": "audio",
+}
+
+// subtitleDirs are where packs commonly keep subtitles next to the video.
+var subtitleDirs = map[string]bool{"subs": true, "subtitles": true, "sub": true}
+
+// FindCompanions returns the subtitle and audio files that pair with
+// video: same directory (or a Subs folder beside it) and the same base
+// name, optionally followed by a language tag.
+func FindCompanions(t *torrent.Torrent, video *torrent.File) []Companion {
+	var out []Companion
+	for _, f := range t.Files() {
+		if f == video {
+			continue
+		}
+		if kind, lang, ok := PairCompanion(video.DisplayPath(), f.DisplayPath()); ok {
+			out = append(out, Companion{File: f, Kind: kind, Lang: lang})
+		}
+	}
+	return out
+}
+
+// PairCompanion reports whether the file at other (a slash-separated path)
+// is a subtitle or audio companion of the video at video, by FindCompanions'
+// rules. Local files use it too.
+func PairCompanion(video, other string) (kind, lang string, ok bool) {
+	vdir, vname := path.Split(video)
+	vbase := strings.ToLower(strings.TrimSuffix(vname, path.Ext(vname)))
+	dir, name := path.Split(other)
+	kind = companionKinds[strings.ToLower(path.Ext(name))]
+	if kind == "" {
+		return "", "", false
+	}
+	if dir != vdir && !(strings.HasPrefix(dir, vdir) && subtitleDirs[strings.ToLower(strings.Trim(dir[len(vdir):], "/"))]) {
+		return "", "", false
+	}
+	base := strings.ToLower(strings.TrimSuffix(name, path.Ext(name)))
+	switch {
+	case base == vbase:
+	case strings.HasPrefix(base, vbase+"."):
+		lang = base[len(vbase)+1:]
+	case dir != vdir:
+		// Subs/English.srt, Subs/2_English.srt: the folder is the pairing.
+		lang = strings.TrimLeft(base, "0123456789_ ")
+	default:
+		return "", "", false
+	}
+	return kind, lang, true
+}
+
+// IsSubtitleDir reports whether a folder named name commonly holds the
+// subtitles of the videos beside it.
+func IsSubtitleDir(name string) bool {
+	return subtitleDirs[strings.ToLower(name)]
+}
+
+// PrioritiseCompanions fetches the companions ahead of the video body;
+// they are small and the player asks for them at start-up.
+func PrioritiseCompanions(cs []Companion) {
+	for _, c := range cs {
+		c.File.SetPriority(torrent.PiecePriorityHigh)
+	}
+}
@@ -0,0 +1,251 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/iplist"
+)
+
+// Happy Eyeballs (RFC 8305) for trackers and peers. On networks that hand
+// out IPv6 addresses but drop the traffic, every IPv6 attempt hangs until
+// its timeout. Tracker connections race the families: IPv6 first, IPv4
+// after a short head start, first to connect wins. When IPv6 keeps losing
+// (or the host has no IPv6 route at all) it is marked broken for a while:
+// trackers dial IPv4 first, UDP trackers announce over IPv4, and IPv6 peers
+// aren't dialled, so they don't tie up half-open slots. The mark expires,
+// and NetworkChanged clears it, so a repaired path is picked up again.
+
+const (
+	eyeballsDelay  = 250 * time.Millisecond // IPv6's head start
+	ipv6MaxMisses  = 3                      // lost races in a row before IPv6 is broken
+	ipv6BrokenFor  = 10 * time.Minute
+	ipv6RouteProbe = "[2001:4860:4860::8888]:53" // only routed, nothing is sent
+)
+
+// DualStack holds the host's IPv6 health and dials with it. Its methods are
+// safe for concurrent use.
+type DualStack struct {
+	// Resolver looks up tracker hostnames; nil means the system's.
+	Resolver *net.Resolver
+
+	mu          sync.Mutex
+	misses      int
+	brokenUntil time.Time
+	reason      string
+	probed      time.Time // last routedIPv6 check
+}
+
+// DefaultDualStack is the one NewClientConfig installs: IPv6 health is a
+// property of the host's network, shared by every client in the process.
+var DefaultDualStack = &DualStack{}
+
+// Apply makes cfg dial trackers and filter peers through d.
+func (d *DualStack) Apply(cfg *torrent.ClientConfig) {
+	cfg.TrackerDialContext = d.DialContext
+	cfg.LookupTrackerIp = d.lookupTrackerIP
+	cfg.IPBlocklist = d
+}
+
+// IPv6Broken reports whether IPv6 is currently avoided, and why.
+func (d *DualStack) IPv6Broken() (bool, string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := time.Now()
+	if now.After(d.brokenUntil) && now.Sub(d.probed) > ipv6BrokenFor {
+		d.probed = now
+		if !routedIPv6() {
+			d.markBrokenLocked("no IPv6 route")
+		}
+	}
+	if now.After(d.brokenUntil) {
+		return false, ""
+	}
+	return true, d.reason
+}
+
+// Reset forgets what was learned about IPv6, as after a network change.
+func (d *DualStack) Reset() {
+	d.mu.Lock()
+	d.misses, d.brokenUntil, d.reason, d.probed = 0, time.Time{}, "", time.Time{}
+	d.mu.Unlock()
+}
+
+func (d *DualStack) markBrokenLocked(reason string) {
+	if time.Now().After(d.brokenUntil) || d.reason != reason {
+		log.Printf("engine: IPv6 avoided for %v: %s", ipv6BrokenFor, reason)
+	}
+	d.misses, d.brokenUntil, d.reason = 0, time.Now().Add(ipv6BrokenFor), reason
+}
+
+// record notes how IPv6 did in a race: ok, or beaten by IPv4 / failed.
+func (d *DualStack) record(ok bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if ok {
+		if d.reason != "" && time.Now().Before(d.brokenUntil) {
+			log.Printf("engine: IPv6 works again")
+		}
+		d.misses, d.brokenUntil, d.reason = 0, time.Time{}, ""
+		return
+	}
+	if d.misses++; d.misses >= ipv6MaxMisses {
+		d.markBrokenLocked("IPv6 connections keep losing to IPv4")
+	}
+}
+
+// routedIPv6 reports whether the host has a route to the IPv6 internet.
+// Connecting a UDP socket only consults the routing table.
+func routedIPv6() bool {
+	c, err := net.Dial("udp6", ipv6RouteProbe)
+	if err != nil {
+		return false
+	}
+	c.Close()
+	return true
+}
+
+// Lookup implements iplist.Ranger: IPv6 peers are skipped while IPv6 is
+// broken. (Trusted peers, e.g. ones the app posts, are still dialled.)
+func (d *DualStack) Lookup(ip net.IP) (iplist.Range, bool) {
+	if ip.To4() != nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() {
+		return iplist.Range{}, false
+	}
+	if broken, reason := d.IPv6Broken(); broken {
+		return iplist.Range{First: ip, Last: ip, Description: reason}, true
+	}
+	return iplist.Range{}, false
+}
+
+// NumRanges implements iplist.Ranger.
+func (d *DualStack) NumRanges() int { return 1 }
+
+// lookupTrackerIP resolves a UDP tracker's host, IPv4 first while IPv6 is
+// broken (the client announces to the first address).
+func (d *DualStack) lookupTrackerIP(u *url.URL) ([]net.IP, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	ips, err := d.resolver().LookupIP(ctx, "ip", u.Hostname())
+	if err != nil {
+		return nil, err
+	}
+	v6, v4 := splitFamilies(ips)
+	if broken, _ := d.IPv6Broken(); broken {
+		return append(v4, v6...), nil
+	}
+	return append(v6, v4...), nil
+}
+
+func (d *DualStack) resolver() *net.Resolver {
+	if d.Resolver != nil {
+		return d.Resolver
+	}
+	return net.DefaultResolver
+}
+
+func splitFamilies(ips []net.IP) (v6, v4 []net.IP) {
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			v4 = append(v4, ip)
+		} else {
+			v6 = append(v6, ip)
+		}
+	}
+	return v6, v4
+}
+
+type dialResult struct {
+	conn net.Conn
+	err  error
+	v6   bool
+}
+
+// DialContext connects to addr over whichever family connects first.
+func (d *DualStack) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	var dialer net.Dialer
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || net.ParseIP(host) != nil || (network != "tcp" && network != "udp") {
+		return dialer.DialContext(ctx, network, addr)
+	}
+	ips, err := d.resolver().LookupIP(ctx, "ip", host)
+	if err != nil {
+		return nil, err
+	}
+	v6, v4 := splitFamilies(ips)
+	if len(v6) == 0 || len(v4) == 0 {
+		dialer.Resolver = d.Resolver
+		return dialer.DialContext(ctx, network, addr)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make(chan dialResult, 2)
+	dialFamily := func(ips []net.IP, v6 bool) {
+		var err error
+		for _, ip := range ips {
+			var c net.Conn
+			if c, err = dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port)); err == nil {
+				results <- dialResult{conn: c, v6: v6}
+				return
+			}
+		}
+		results <- dialResult{err: err, v6: v6}
+	}
+	first, second, firstV6 := v6, v4, true
+	if broken, _ := d.IPv6Broken(); broken {
+		first, second, firstV6 = v4, v6, false
+	}
+	go dialFamily(first, firstV6)
+	fallback := time.NewTimer(eyeballsDelay)
+	defer fallback.Stop()
+	started, pending := false, 1
+	startSecond := func() {
+		if !started {
+			started, pending = true, pending+1
+			go dialFamily(second, !firstV6)
+		}
+	}
+
+	var errs []error
+	scored := false // one verdict on IPv6 per dial
+	for {
+		select {
+		case <-fallback.C:
+			startSecond()
+			continue
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				if !scored && (r.v6 || started) { // a v4 win only counts once v6 was racing
+					d.record(r.v6)
+				}
+				go drainRaces(results, pending)
+				return r.conn, nil
+			}
+			if r.v6 && !scored {
+				d.record(false)
+				scored = true
+			}
+			errs = append(errs, r.err)
+			startSecond()
+			if pending == 0 {
+				return nil, errors.Join(errs...)
+			}
+		}
+	}
+}
+
+// drainRaces closes connections from the races that lost.
+func drainRaces(results <-chan dialResult, pending int) {
+	for ; pending > 0; pending-- {
+		if r := <-results; r.conn != nil {
+			r.conn.Close()
+		}
+	}
+}
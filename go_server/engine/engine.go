@@ -0,0 +1,227 @@
+// Package engine is RoxBox's torrent streaming core: a tuned anacrolix
+// client plus the file selection, piece priorities and reader setup that
+// make a torrent playable while it downloads.
+//
+// Engine is the embeddable entry point — one stream at a time, no HTTP
+// server of its own:
+//
+//	e, err := engine.NewEngine(engine.Config{DataDir: dir})
+//	_ = e.AddMagnet(uri)
+//	http.HandleFunc("/stream", e.Stream)
+//
+// The roxbox server builds its per-profile sessions on the same helpers.
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	pp "github.com/anacrolix/torrent/peer_protocol"
+	"github.com/anacrolix/torrent/storage"
+)
+
+// Config configures NewEngine.
+type Config struct {
+	DataDir string // torrent data, one directory per infohash
+}
+
+// NewClientConfig returns the client settings tuned for streaming.
+func NewClientConfig(dataDir string) *torrent.ClientConfig {
+	cfg := torrent.NewDefaultClientConfig()
+	cfg.DataDir = dataDir
+	// Background writes and hashing are paced during playback (iothrottle.go)
+	cfg.DefaultStorage = DefaultIOThrottle.Wrap(storage.NewFileByInfoHash(dataDir))
+	cfg.Seed = false // We're a pure leecher for streaming
+	cfg.EstablishedConnsPerTorrent = EstablishedConns
+	cfg.HalfOpenConnsPerTorrent = 50
+	cfg.NoDHT = false
+	cfg.NoDefaultPortForwarding = true
+	// Sequential read optimisation: high connection count, fast unchoke
+	cfg.DisableIPv6 = false
+	DefaultDualStack.Apply(cfg) // Happy Eyeballs for trackers, IPv6 peers (dualstack.go)
+	DefaultBans.Apply(cfg)      // persisted peer bans (bans.go)
+	DefaultJitter.Apply(cfg)    // irregular announce and dial timing, when on (jitter.go)
+	DefaultPipeline.Apply(cfg)  // request queue depths from each peer's bandwidth-delay product (pipeline.go)
+	// DHT announces and PEX honour the private flag (private.go); far peers
+	// start with shallower request queues (geoip.go)
+	cfg.PeriodicallyAnnounceTorrentsToDht = false
+	cfg.Callbacks.ReadExtendedHandshake = func(c *torrent.PeerConn, m *pp.ExtendedHandshakeMessage) {
+		asked := m.Reqq
+		hidePrivatePex(c, m)
+		DefaultGeo.handshake(c, m)
+		DefaultPipeline.handshake(c, asked, m.Reqq)
+	}
+	return cfg
+}
+
+// Status is a snapshot of the engine's stream.
+type Status struct {
+	State       string  `json:"state"`    // "idle" | "loading" | "ready" | "error"
+	Progress    float64 `json:"progress"` // of the streamed file
+	CompletedMB float64 `json:"completed_mb"`
+	DownloadMB  float64 `json:"download_mb"` // this session
+	SpeedKBs    float64 `json:"speed_kbs"`
+	Peers       int     `json:"peers"`
+	InfoHash    string  `json:"info_hash,omitempty"`
+	Name        string  `json:"name,omitempty"` // streamed file, once known
+	Error       string  `json:"error,omitempty"`
+	Network     string  `json:"network,omitempty"`    // last NetworkChanged kind
+	Background  bool    `json:"background,omitempty"` // host app is backgrounded
+	Suspended   bool    `json:"suspended,omitempty"`  // its background time ran out: downloads paused
+	Private     bool    `json:"private,omitempty"`    // no DHT or PEX (private.go)
+}
+
+// Engine streams one torrent at a time.
+type Engine struct {
+	client *torrent.Client
+
+	mu     sync.RWMutex
+	t      *torrent.Torrent
+	f      *torrent.File
+	pieces PieceProfile
+	status Status
+}
+
+func NewEngine(cfg Config) (*Engine, error) {
+	if cfg.DataDir == "" {
+		return nil, errors.New("engine: DataDir required")
+	}
+	c, err := torrent.NewClient(NewClientConfig(cfg.DataDir))
+	if err != nil {
+		return nil, fmt.Errorf("torrent client: %w", err)
+	}
+	return &Engine{client: c, status: Status{State: "idle"}}, nil
+}
+
+// Client exposes the underlying torrent client.
+func (e *Engine) Client() *torrent.Client { return e.client }
+
+// AddMagnet replaces the current stream with uri. It returns once the
+// torrent is added; metadata and file selection continue in the
+// background (watch Status).
+func (e *Engine) AddMagnet(uri string) error {
+	e.Stop()
+	t, err := e.client.AddMagnet(uri)
+	if err != nil {
+		return fmt.Errorf("AddMagnet: %w", err)
+	}
+	e.mu.Lock()
+	e.t = t
+	e.status.State, e.status.InfoHash = "loading", t.InfoHash().HexString()
+	e.mu.Unlock()
+
+	AnnouncePublic(e.client, t)
+	e.applyLifecycle(t)
+	go e.bringUp(t)
+	return nil
+}
+
+func (e *Engine) bringUp(t *torrent.Torrent) {
+	select {
+	case <-t.GotInfo():
+	case <-t.Closed():
+		return
+	}
+	f, _ := SelectFile(t, Hint{}, Filter{DenyExts: DefaultDenyExts, AvoidWords: DefaultAvoidWords})
+	if f == nil {
+		e.setError(t, "no video file found in torrent")
+		return
+	}
+	prof := ProfilePieces(t)
+	ApplyPieceProfile(t, prof)
+
+	e.mu.Lock()
+	if e.t != t {
+		e.mu.Unlock()
+		return
+	}
+	e.f = f
+	e.pieces = prof
+	e.status.Name = f.DisplayPath()
+	e.status.Private = IsPrivate(t)
+	e.mu.Unlock()
+
+	f.Download()
+	PrioritiseFile(t, f)
+	e.statsLoop(t, f)
+}
+
+func (e *Engine) statsLoop(t *torrent.Torrent, f *torrent.File) {
+	var last Sample
+	for {
+		select {
+		case <-time.After(time.Second):
+		case <-t.Closed():
+			return
+		}
+		s := Measure(t, f, last)
+		last = s
+
+		e.mu.Lock()
+		if e.t != t {
+			e.mu.Unlock()
+			return
+		}
+		st := &e.status
+		st.Progress, st.CompletedMB, st.DownloadMB, st.SpeedKBs, st.Peers = s.Progress, s.CompletedMB, s.DownloadMB, s.SpeedKBs, s.Peers
+		if s.Progress >= ReadyPercent {
+			st.State = "ready"
+		}
+		e.mu.Unlock()
+	}
+}
+
+func (e *Engine) setError(t *torrent.Torrent, msg string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.t == t {
+		e.status.State = "error"
+		e.status.Error = msg
+	}
+	log.Println("ERROR:", msg)
+}
+
+// Status returns the current stream status.
+func (e *Engine) Status() Status {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.status
+}
+
+// Stream serves the selected file with Range support; 503 until the file
+// has been picked.
+func (e *Engine) Stream(w http.ResponseWriter, r *http.Request) {
+	e.mu.RLock()
+	f, readahead := e.f, e.pieces.Readahead
+	e.mu.RUnlock()
+	if f == nil {
+		http.Error(w, "no active torrent", 503)
+		return
+	}
+	reader := NewReader(f, readahead)
+	defer reader.Close()
+	ServeContent(w, r, f.DisplayPath(), reader)
+}
+
+// Stop drops the current torrent, if any.
+func (e *Engine) Stop() {
+	e.mu.Lock()
+	t := e.t
+	e.t, e.f, e.pieces = nil, nil, PieceProfile{}
+	e.status = Status{State: "idle", Network: e.status.Network, Background: e.status.Background, Suspended: e.status.Suspended}
+	e.mu.Unlock()
+	if t != nil {
+		t.Drop()
+	}
+}
+
+// Close stops streaming and shuts the client down.
+func (e *Engine) Close() {
+	e.Stop()
+	e.client.Close()
+}
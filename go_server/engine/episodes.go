@@ -0,0 +1,120 @@
+package engine
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+
+	"github.com/anacrolix/torrent"
+)
+
+// Episode lookup by name, for apps that know which episode the user wants
+// but not how the release spells it. A pattern is anything ParseEpisode
+// reads ("S01E05", "1x05", "Season 1 Episode 5") or a bare episode number
+// ("E05", "Ep 5", "5"), which needs the episode to be in one season of the
+// torrent. Files holding several episodes (S01E05E06, S01E05-06) match each
+// of them. Anime numbers episodes from the first one on, without seasons
+// ("[Group] Show - 105 (1080p).mkv"); a bare number matches those too.
+
+var (
+	episodeNumberRe = regexp.MustCompile(`(?i)^(?:e|ep|episode)?[ ._-]?(\d{1,4})$`)
+	multiEpisodeRe  = regexp.MustCompile(`(?i)s\d{1,2}[ ._-]?e\d{1,3}(?:-?e|-)(\d{1,3})(?:[^0-9]|$)`)
+	// The number after a spaced dash in the file's own name, before the
+	// tags, the extension or another dash.
+	absoluteEpisodeRe = regexp.MustCompile(`(?i)[ _]-[ _](\d{1,4})(?:v\d)?(?:[ _]*[\[(.-]|$)`)
+)
+
+// absoluteEpisode is the absolute episode number in path's file name; a
+// year isn't one.
+func absoluteEpisode(p string) (int, bool) {
+	m := absoluteEpisodeRe.FindStringSubmatch(path.Base(p))
+	if m == nil {
+		return 0, false
+	}
+	n, _ := strconv.Atoi(m[1])
+	if len(m[1]) == 4 && n >= 1900 && n < 2100 {
+		return 0, false
+	}
+	return n, true
+}
+
+// episodeSpan is the episode a path holds, and the last one for a
+// multi-episode file. An absolute number comes as season 0.
+func episodeSpan(path string) (Episode, int, bool) {
+	e, ok := ParseEpisode(path)
+	if !ok {
+		n, ok := absoluteEpisode(path)
+		return Episode{Number: n}, n, ok
+	}
+	last := e.Number
+	if m := multiEpisodeRe.FindStringSubmatch(path); m != nil {
+		if n, _ := strconv.Atoi(m[1]); n > e.Number {
+			last = n
+		}
+	}
+	return e, last, true
+}
+
+// parseEpisodePattern reads a pattern: an episode, or with anySeason only
+// its number.
+func parseEpisodePattern(pattern string) (want Episode, anySeason bool, err error) {
+	if want, ok := ParseEpisode(pattern); ok {
+		return want, false, nil
+	}
+	m := episodeNumberRe.FindStringSubmatch(pattern)
+	if m == nil {
+		return Episode{}, false, fmt.Errorf("pattern %q names no episode (S01E05, 1x05 or E05)", pattern)
+	}
+	want.Number, _ = strconv.Atoi(m[1])
+	return want, true, nil
+}
+
+// holdsEpisode reports whether path holds the episode a pattern asked for
+// (parseEpisodePattern), and in which season.
+func holdsEpisode(path string, want Episode, anySeason bool) (season int, ok bool) {
+	e, last, ok := episodeSpan(path)
+	if !ok || want.Number < e.Number || want.Number > last || !anySeason && e.Season != want.Season {
+		return 0, false
+	}
+	return e.Season, true
+}
+
+// MatchEpisode finds the video file for an episode pattern. Among several
+// matches, files filter avoids (a release's sample) lose, then the
+// preferred container and the largest file win.
+func MatchEpisode(t *torrent.Torrent, pattern string, filter Filter) (*torrent.File, Selection, error) {
+	want, anySeason, err := parseEpisodePattern(pattern)
+	if err != nil {
+		return nil, Selection{}, err
+	}
+	var match []*torrent.File
+	seasons := map[int]bool{}
+	for _, f := range t.Files() {
+		if !IsVideo(f.DisplayPath()) {
+			continue
+		}
+		season, ok := holdsEpisode(f.DisplayPath(), want, anySeason)
+		if !ok {
+			continue
+		}
+		match = append(match, f)
+		seasons[season] = true
+	}
+	name := want.String()
+	if anySeason {
+		name = fmt.Sprintf("episode %d", want.Number)
+	}
+	if len(match) == 0 {
+		return nil, Selection{}, fmt.Errorf("no video file is %s", name)
+	}
+	if len(seasons) > 1 {
+		return nil, Selection{}, fmt.Errorf("%s is in %d seasons; name the season, as in S01E05", name, len(seasons))
+	}
+	sel := Selection{Reason: "matched pattern " + strconv.Quote(pattern)}
+	match, sel.Extras = filter.preferMain(match)
+	sel.Candidates = len(match)
+	filter.sortPreferred(match)
+	sel.Reason += filter.pickedBy(match)
+	return match[0], sel.WithEpisode(match[0]), nil
+}
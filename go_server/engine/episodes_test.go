@@ -0,0 +1,169 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/bencode"
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+func TestParseEpisode(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want Episode
+		ok   bool
+	}{
+		{"Show.S01E02.1080p.WEB.x264.mkv", Episode{1, 2}, true},
+		{"show s1e2.mkv", Episode{1, 2}, true},
+		{"Show.S01.E02.mkv", Episode{1, 2}, true},
+		{"Show - S10E105 - Title.mkv", Episode{10, 105}, true},
+		{"Show 1x02 Title.avi", Episode{1, 2}, true},
+		{"Show.12x103.mkv", Episode{12, 103}, true},
+		{"Show Season 1 Episode 2.mp4", Episode{1, 2}, true},
+		{"Show/Season 2/Show.S02E05.mkv", Episode{2, 5}, true},
+		{"Show.S01E05E06.mkv", Episode{1, 5}, true}, // the first of several
+		{"Movie.2019.1080p.x264.mkv", Episode{}, false},
+		{"Movie.1920x1080.mkv", Episode{}, false},
+		{"BOSS01E02.mkv", Episode{}, false},                 // no word break before the S
+		{"[Group] Show - 05 (1080p).mkv", Episode{}, false}, // absolute: see episodeSpan
+	} {
+		t.Run(tc.in, func(t *testing.T) {
+			if got, ok := ParseEpisode(tc.in); got != tc.want || ok != tc.ok {
+				t.Errorf("got %v, %v; want %v, %v", got, ok, tc.want, tc.ok)
+			}
+		})
+	}
+}
+
+func TestEpisodeSpan(t *testing.T) {
+	for _, tc := range []struct {
+		in       string
+		want     Episode
+		wantLast int
+		ok       bool
+	}{
+		{"Show.S01E02.mkv", Episode{1, 2}, 2, true},
+		{"Show.S01E05E06.mkv", Episode{1, 5}, 6, true},
+		{"Show.S01E05-E07.mkv", Episode{1, 5}, 7, true},
+		{"Show.S01E05-06.mkv", Episode{1, 5}, 6, true},
+		{"Show.S01E05-1080p.mkv", Episode{1, 5}, 5, true},
+		{"Show.S01E09-E08.mkv", Episode{1, 9}, 9, true}, // backwards: not a range
+		{"[Group] Show - 05 (1080p) [ABCD1234].mkv", Episode{0, 5}, 5, true},
+		{"[Group] Show - 1071 [1080p].mkv", Episode{0, 1071}, 1071, true},
+		{"Show - 12v2.mkv", Episode{0, 12}, 12, true},
+		{"Show - 03 - Title.mkv", Episode{0, 3}, 3, true},
+		{"Show_-_24_[720p].mkv", Episode{0, 24}, 24, true},
+		{"Show - 101/Extras - Making Of.mkv", Episode{}, 0, false}, // only the file's own name counts
+		{"Movie - 2019.mkv", Episode{}, 0, false},
+		{"Movie - 1080p.mkv", Episode{}, 0, false},
+		{"Movie.mkv", Episode{}, 0, false},
+	} {
+		t.Run(tc.in, func(t *testing.T) {
+			got, last, ok := episodeSpan(tc.in)
+			if got != tc.want || last != tc.wantLast || ok != tc.ok {
+				t.Errorf("got %v to %d, %v; want %v to %d, %v", got, last, ok, tc.want, tc.wantLast, tc.ok)
+			}
+		})
+	}
+}
+
+func TestParseEpisodePattern(t *testing.T) {
+	for _, tc := range []struct {
+		in        string
+		want      Episode
+		anySeason bool
+		err       bool
+	}{
+		{"S01E05", Episode{1, 5}, false, false},
+		{"s2e10", Episode{2, 10}, false, false},
+		{"1x05", Episode{1, 5}, false, false},
+		{"Season 3 Episode 7", Episode{3, 7}, false, false},
+		{"E05", Episode{0, 5}, true, false},
+		{"Ep 5", Episode{0, 5}, true, false},
+		{"episode-12", Episode{0, 12}, true, false},
+		{"1071", Episode{0, 1071}, true, false},
+		{"5", Episode{0, 5}, true, false},
+		{"", Episode{}, false, true},
+		{"pilot", Episode{}, false, true},
+		{"S01", Episode{}, false, true},
+	} {
+		t.Run(tc.in, func(t *testing.T) {
+			got, anySeason, err := parseEpisodePattern(tc.in)
+			if (err != nil) != tc.err {
+				t.Fatalf("error %v, want one: %v", err, tc.err)
+			}
+			if got != tc.want || anySeason != tc.anySeason {
+				t.Errorf("got %v (any season %v), want %v (%v)", got, anySeason, tc.want, tc.anySeason)
+			}
+		})
+	}
+}
+
+// packTorrent is a torrent of the given paths, 1 MiB each, on a client that
+// never connects anywhere.
+func packTorrent(t *testing.T, paths ...string) *torrent.Torrent {
+	t.Helper()
+	info := metainfo.Info{Name: "pack", PieceLength: 1 << 20}
+	for _, p := range paths {
+		info.Files = append(info.Files, metainfo.FileInfo{Path: strings.Split(p, "/"), Length: 1 << 20})
+	}
+	info.Pieces = make([]byte, 20*len(paths))
+	mi := metainfo.MetaInfo{}
+	var err error
+	if mi.InfoBytes, err = bencode.Marshal(info); err != nil {
+		t.Fatal(err)
+	}
+	cfg := torrent.NewDefaultClientConfig()
+	cfg.DataDir = t.TempDir()
+	cfg.ListenPort = 0
+	cfg.NoDHT = true
+	cfg.DisableTrackers = true
+	cfg.NoDefaultPortForwarding = true
+	cl, err := torrent.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cl.Close() })
+	tor, err := cl.AddTorrent(&mi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tor
+}
+
+func TestSelectFileEpisode(t *testing.T) {
+	anime := packTorrent(t,
+		"[Group] Show - 105 (1080p).mkv",
+		"[Group] Show - 103 (1080p).mkv",
+		"[Group] Show - 104 (1080p).mkv",
+	)
+	season := packTorrent(t,
+		"Show.S01E02.mkv",
+		"Show.S01E01.mkv",
+		"Show.S01E03E04.mkv",
+	)
+	for _, tc := range []struct {
+		name    string
+		tor     *torrent.Torrent
+		episode string
+		want    string
+	}{
+		{"absolute number", anime, "105", "pack/[Group] Show - 105 (1080p).mkv"},
+		{"absolute E", anime, "E104", "pack/[Group] Show - 104 (1080p).mkv"},
+		{"no such episode", anime, "7", "pack/[Group] Show - 103 (1080p).mkv"},
+		{"absolute pack starts at the first", anime, "", "pack/[Group] Show - 103 (1080p).mkv"},
+		{"season episode", season, "S01E02", "pack/Show.S01E02.mkv"},
+		{"inside a multi-episode file", season, "S01E04", "pack/Show.S01E03E04.mkv"},
+		{"number in any season", season, "3", "pack/Show.S01E03E04.mkv"},
+		{"season pack starts at the first", season, "", "pack/Show.S01E01.mkv"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			f, sel := SelectFile(tc.tor, Hint{Episode: tc.episode}, Filter{})
+			if f == nil || f.Path() != tc.want {
+				t.Errorf("picked %v (%s), want %s", f, sel.Reason, tc.want)
+			}
+		})
+	}
+}
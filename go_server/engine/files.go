@@ -0,0 +1,182 @@
+package engine
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/types"
+)
+
+var videoExts = map[string]bool{
+	".mp4": true, ".mkv": true, ".avi": true,
+	".mov": true, ".wmv": true, ".webm": true,
+	".m4v": true, ".ts": true,
+}
+
+// IsVideo reports whether name has a video file extension.
+func IsVideo(name string) bool {
+	return videoExts[strings.ToLower(filepath.Ext(name))]
+}
+
+// fileTypes classifies the rest of what torrents carry besides video.
+var fileTypes = map[string]string{
+	".mp3": "audio", ".flac": "audio", ".m4a": "audio", ".ogg": "audio", ".opus": "audio", ".wav": "audio",
+	".jpg": "image", ".jpeg": "image", ".png": "image", ".gif": "image", ".webp": "image", ".bmp": "image",
+	".zip": "archive", ".rar": "archive", ".7z": "archive", ".tar": "archive", ".gz": "archive",
+	".txt": "text", ".nfo": "text", ".md": "text", ".sfv": "text", ".md5": "text",
+	".exe": "executable", ".bat": "executable", ".scr": "executable", ".lnk": "executable", ".msi": "executable",
+}
+
+// FileType names what kind of file name is, by extension: "video",
+// "subtitle", "audio", "image", "archive", "text", "executable" or "other".
+// Split archives (.r00, .001) count as archives.
+func FileType(name string) string {
+	ext := strings.ToLower(filepath.Ext(name))
+	if videoExts[ext] {
+		return "video"
+	}
+	if kind, ok := companionKinds[ext]; ok {
+		return kind
+	}
+	if kind, ok := fileTypes[ext]; ok {
+		return kind
+	}
+	if len(ext) == 4 && (ext[1] == 'r' || ext[1] == '0') && ext[2] >= '0' && ext[2] <= '9' && ext[3] >= '0' && ext[3] <= '9' {
+		return "archive"
+	}
+	return "other"
+}
+
+// FileByPath finds a file by its display path; nil when path is empty or
+// not part of the torrent.
+func FileByPath(t *torrent.Torrent, path string) *torrent.File {
+	if path == "" {
+		return nil
+	}
+	for _, f := range t.Files() {
+		if f.DisplayPath() == path {
+			return f
+		}
+	}
+	return nil
+}
+
+// LargestFile picks the largest video file, or the largest file of any kind
+// when the torrent has no recognisable video. Samples and extras
+// (DefaultAvoidWords) lose to anything else.
+func LargestFile(t *torrent.Torrent) *torrent.File {
+	files := t.Files()
+	if len(files) == 0 {
+		return nil
+	}
+	// Filter to video files only
+	var videos []*torrent.File
+	for _, f := range files {
+		ext := strings.ToLower(filepath.Ext(f.DisplayPath()))
+		if videoExts[ext] {
+			videos = append(videos, f)
+		}
+	}
+	if len(videos) == 0 {
+		// Fall back to all files
+		videos = files
+	}
+	videos, _ = Filter{AvoidWords: DefaultAvoidWords}.preferMain(videos)
+	sort.Slice(videos, func(i, j int) bool {
+		return videos[i].Length() > videos[j].Length()
+	})
+	return videos[0]
+}
+
+// PrioritiseFile applies the streaming priorities: the whole file at normal
+// priority, the first 5 % and last 1 % boosted for fast start and seeking.
+func PrioritiseFile(t *torrent.Torrent, f *torrent.File) {
+	prioStart := f.Length() / 20 // 5%
+	prioEnd := f.Length() / 100  // 1%
+	PrioritiseFileBounds(t, f, prioStart, prioEnd)
+}
+
+// PrioritiseFileBounds is PrioritiseFile with explicit head and tail sizes
+// in bytes.
+func PrioritiseFileBounds(t *torrent.Torrent, f *torrent.File, head, tail int64) {
+	// Set sequential priority on the entire file
+	f.SetPriority(torrent.PiecePriorityNormal)
+	setPieceSequential(t, f, head, tail)
+}
+
+// setPieceSequential boosts sequential priority on the file and
+// ultra-boosts the first and last chunks so seek + playback starts fast.
+func setPieceSequential(t *torrent.Torrent, f *torrent.File, prioStart, prioEnd int64) {
+	span := PieceRange(f)
+	for i := span.Begin; i < span.End; i++ {
+		start, end := span.FileBounds(i)
+		p := t.Piece(i)
+		if start < prioStart || end > span.FileLength-prioEnd {
+			p.SetPriority(torrent.PiecePriorityNow) // start/end: highest
+		} else {
+			p.SetPriority(torrent.PiecePriorityNormal)
+		}
+	}
+}
+
+// UnprioritiseFile takes old and its pieces to no priority after a switch to
+// keep, leaving the pieces the two files share alone.
+func UnprioritiseFile(t *torrent.Torrent, old, keep *torrent.File) {
+	old.SetPriority(torrent.PiecePriorityNone)
+	span, kept := PieceRange(old), PieceRange(keep)
+	for i := span.Begin; i < span.End; i++ {
+		if i >= kept.Begin && i < kept.End {
+			continue
+		}
+		t.Piece(i).SetPriority(torrent.PiecePriorityNone)
+	}
+}
+
+// HoldFile takes f and its incomplete pieces to no priority, leaving only
+// readers to fetch, and answers the pieces' priorities for ReleaseFile. A
+// piece's priority is the highest of its own, its files' and its readers',
+// so the file alone going to none holds nothing back.
+func HoldFile(t *torrent.Torrent, f *torrent.File) map[int]types.PiecePriority {
+	f.SetPriority(torrent.PiecePriorityNone)
+	held := map[int]types.PiecePriority{}
+	span := PieceRange(f)
+	for i := span.Begin; i < span.End; i++ {
+		ps := t.PieceState(i)
+		if ps.Complete {
+			continue
+		}
+		if ps.Priority != torrent.PiecePriorityNone { // none while it hashes
+			held[i] = ps.Priority
+		}
+		t.Piece(i).SetPriority(torrent.PiecePriorityNone)
+	}
+	return held
+}
+
+// ReleaseFile gives pieces held by HoldFile their priority back, those no
+// one has raised since.
+func ReleaseFile(t *torrent.Torrent, held map[int]types.PiecePriority) {
+	for i, prio := range held {
+		if t.PieceState(i).Priority == torrent.PiecePriorityNone {
+			t.Piece(i).SetPriority(prio)
+		}
+	}
+}
+
+// PrioritiseTail fetches the last n bytes of f first, for players that read
+// the end of a file (MP4 moov, Matroska cues) before they start playing.
+func PrioritiseTail(t *torrent.Torrent, f *torrent.File, n int64) {
+	if n <= 0 {
+		return
+	}
+	span := PieceRange(f)
+	for i := span.End - 1; i >= span.Begin; i-- {
+		_, end := span.FileBounds(i)
+		if end <= span.FileLength-n {
+			break
+		}
+		t.Piece(i).SetPriority(torrent.PiecePriorityNow)
+	}
+}
@@ -0,0 +1,187 @@
+package engine
+
+import (
+	"math"
+	"net"
+	"sync"
+	"sync/atomic"
+
+	"github.com/anacrolix/torrent"
+	pp "github.com/anacrolix/torrent/peer_protocol"
+)
+
+// GeoIP peer preference. The client has no say in whom it requests a
+// piece from, but a peer's request queue depth decides how much of the
+// playback window it can take on: a peer on another continent holding 250
+// requests keeps the next pieces a round trip of 200ms+ away, while a near
+// peer would have sent them already. With a MaxMind-format database loaded,
+// the extended handshake hook caps the queue of far peers, so the window
+// is mostly requested from near ones and the far peers fill in behind.
+// Pipeline tuning (pipeline.go) then grows a capped queue as far as the
+// peer's measured bandwidth-delay product warrants.
+// Home is where the peers say our address is (the handshake's yourip), or
+// set by hand.
+
+const (
+	geoFarKm      = 6000 // another continent, roughly
+	geoFarReqq    = 16
+	geoMidKm      = 2500
+	geoMidReqq    = 64
+	earthRadiusKm = 6371
+)
+
+// Location is what the database knows about an address.
+type Location struct {
+	Lat, Lon  float64
+	HasCoords bool
+	Country   string // ISO 3166 code
+	Continent string // two-letter code
+}
+
+// PeerGeo ranks peers by distance. Its methods are safe for concurrent use.
+type PeerGeo struct {
+	mu     sync.RWMutex
+	db     *mmdb
+	home   Location
+	pinned bool // home set by hand
+
+	capped atomic.Int64
+}
+
+// DefaultGeo is the one NewClientConfig's handshake hook consults; it does
+// nothing until a database is loaded.
+var DefaultGeo = &PeerGeo{}
+
+// Load reads the database at path, replacing any earlier one.
+func (g *PeerGeo) Load(path string) error {
+	db, err := openMMDB(path)
+	if err != nil {
+		return err
+	}
+	g.mu.Lock()
+	g.db = db
+	g.mu.Unlock()
+	return nil
+}
+
+// SetHome pins our location instead of learning it from peers.
+func (g *PeerGeo) SetHome(lat, lon float64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.home, g.pinned = Location{Lat: lat, Lon: lon, HasCoords: true}, true
+}
+
+// Home is our location, if known.
+func (g *PeerGeo) Home() (Location, bool) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.home, g.pinned || g.home != Location{}
+}
+
+// Capped is how many peer connections have had their queue capped.
+func (g *PeerGeo) Capped() int64 { return g.capped.Load() }
+
+// Locate looks ip up; false without a database or a record.
+func (g *PeerGeo) Locate(ip net.IP) (Location, bool) {
+	g.mu.RLock()
+	db := g.db
+	g.mu.RUnlock()
+	if db == nil || ip == nil {
+		return Location{}, false
+	}
+	rec, err := db.lookup(ip)
+	if err != nil || rec == nil {
+		return Location{}, false
+	}
+	var l Location
+	if loc, ok := rec["location"].(map[string]any); ok {
+		lat, okLat := loc["latitude"].(float64)
+		lon, okLon := loc["longitude"].(float64)
+		l.Lat, l.Lon, l.HasCoords = lat, lon, okLat && okLon
+	}
+	if c, ok := rec["country"].(map[string]any); ok {
+		l.Country, _ = c["iso_code"].(string)
+	}
+	if c, ok := rec["continent"].(map[string]any); ok {
+		l.Continent, _ = c["code"].(string)
+	}
+	return l, l.HasCoords || l.Country != "" || l.Continent != ""
+}
+
+// Reqq is the request queue depth for a peer at ip, 0 for no cap.
+func (g *PeerGeo) Reqq(ip net.IP) int {
+	home, ok := g.Home()
+	if !ok {
+		return 0
+	}
+	l, ok := g.Locate(ip)
+	if !ok {
+		return 0
+	}
+	switch {
+	case home.HasCoords && l.HasCoords:
+		d := distanceKm(home, l)
+		if d > geoFarKm {
+			return geoFarReqq
+		}
+		if d > geoMidKm {
+			return geoMidReqq
+		}
+	case home.Continent != "" && l.Continent != "" && home.Continent != l.Continent:
+		return geoFarReqq
+	case home.Country != "" && l.Country != "" && home.Country != l.Country:
+		return geoMidReqq
+	}
+	return 0
+}
+
+// learnHome takes our location from an address a peer saw us at.
+func (g *PeerGeo) learnHome(ip net.IP) {
+	g.mu.RLock()
+	known := g.pinned || g.home != Location{}
+	g.mu.RUnlock()
+	if known || ip == nil || ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() {
+		return
+	}
+	l, ok := g.Locate(ip)
+	if !ok {
+		return
+	}
+	g.mu.Lock()
+	if !g.pinned && g.home == (Location{}) {
+		g.home = l
+	}
+	g.mu.Unlock()
+}
+
+// handshake is the extended handshake hook: far peers get a shallower
+// request queue than they asked for.
+func (g *PeerGeo) handshake(c *torrent.PeerConn, m *pp.ExtendedHandshakeMessage) {
+	g.mu.RLock()
+	loaded := g.db != nil
+	g.mu.RUnlock()
+	if !loaded {
+		return
+	}
+	g.learnHome(net.IP(m.YourIp))
+	host, _, err := net.SplitHostPort(c.RemoteAddr.String())
+	if err != nil {
+		return
+	}
+	reqq := g.Reqq(net.ParseIP(host))
+	if reqq == 0 {
+		return
+	}
+	if m.Reqq == 0 || m.Reqq > reqq { // 0: the peer takes the default 250
+		m.Reqq = reqq
+		g.capped.Add(1)
+	}
+}
+
+// distanceKm is the great-circle distance between a and b.
+func distanceKm(a, b Location) float64 {
+	rad := math.Pi / 180
+	dLat, dLon := (b.Lat-a.Lat)*rad, (b.Lon-a.Lon)*rad
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(a.Lat*rad)*math.Cos(b.Lat*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(min(h, 1)))
+}
@@ -0,0 +1,177 @@
+package engine
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/anacrolix/torrent/storage"
+)
+
+// Disk I/O throttling during playback. On phone flash a burst of piece
+// writes and hash reads for the rest of the torrent queues ahead of the
+// player's reads, and the picture stutters although the data is on disk.
+// While a player is being served, the storage paces writes and hashing of
+// pieces outside the players' windows to a budget; the pieces a player is
+// waiting for go straight through. When playback pauses or stops the budget
+// lifts and the background work catches up.
+
+const (
+	ioThrottleSlice = 50 * time.Millisecond // longest single wait, so a change of window is seen
+	ioHashChunk     = 256 << 10
+)
+
+// IOThrottle paces background disk work. Its methods are safe for
+// concurrent use.
+type IOThrottle struct {
+	RateMBs float64 // background writes and hashing while playing; 0 turns it off
+
+	mu      sync.Mutex
+	windows map[metainfo.Hash][][2]int // pieces [first, last] players wait for
+	tokens  float64
+	last    time.Time
+
+	writes, hashed atomic.Int64 // throttled bytes
+	waited         atomic.Int64 // ns spent waiting
+}
+
+// DefaultIOThrottle is the one NewClientConfig's storage goes through.
+var DefaultIOThrottle = &IOThrottle{}
+
+// IOThrottleStats describes the throttle for /debug/stats.
+type IOThrottleStats struct {
+	RateMBs       float64 `json:"rate_mbs"` // 0: off
+	Playing       bool    `json:"playing"`
+	WindowPieces  int     `json:"window_pieces"`
+	ThrottledMB   float64 `json:"throttled_mb"` // background writes paced
+	HashedMB      float64 `json:"hashed_mb"`    // background hashing paced
+	WaitedSeconds float64 `json:"waited_seconds"`
+}
+
+// SetWindows replaces the piece ranges players are waiting for, by
+// torrent; none means nothing is playing and nothing is throttled.
+func (th *IOThrottle) SetWindows(w map[metainfo.Hash][][2]int) {
+	th.mu.Lock()
+	th.windows = w
+	th.mu.Unlock()
+}
+
+// Stats reports the throttle's state and what it has held back.
+func (th *IOThrottle) Stats() IOThrottleStats {
+	th.mu.Lock()
+	s := IOThrottleStats{RateMBs: th.RateMBs, Playing: len(th.windows) > 0}
+	for _, spans := range th.windows {
+		for _, sp := range spans {
+			s.WindowPieces += sp[1] - sp[0] + 1
+		}
+	}
+	th.mu.Unlock()
+	s.ThrottledMB = float64(th.writes.Load()) / (1 << 20)
+	s.HashedMB = float64(th.hashed.Load()) / (1 << 20)
+	s.WaitedSeconds = time.Duration(th.waited.Load()).Seconds()
+	return s
+}
+
+// throttled reports whether piece i of ih has to wait its turn now.
+func (th *IOThrottle) throttled(ih metainfo.Hash, i int) bool {
+	th.mu.Lock()
+	defer th.mu.Unlock()
+	if th.RateMBs <= 0 || len(th.windows) == 0 {
+		return false
+	}
+	for _, sp := range th.windows[ih] {
+		if i >= sp[0] && i <= sp[1] {
+			return false
+		}
+	}
+	return true
+}
+
+// wait takes n bytes from the budget, sleeping until it has them or the
+// piece stops being throttled.
+func (th *IOThrottle) wait(ih metainfo.Hash, i, n int, count *atomic.Int64) {
+	if !th.throttled(ih, i) {
+		return
+	}
+	count.Add(int64(n))
+	start := time.Now()
+	defer func() { th.waited.Add(int64(time.Since(start))) }()
+	for {
+		th.mu.Lock()
+		rate := th.RateMBs * (1 << 20)
+		now := time.Now()
+		if !th.last.IsZero() {
+			th.tokens = min(th.tokens+rate*now.Sub(th.last).Seconds(), rate/4) // a quarter second of burst
+		}
+		th.last = now
+		if th.tokens >= float64(n) || th.tokens >= rate/4 {
+			th.tokens -= float64(n)
+			th.mu.Unlock()
+			return
+		}
+		short := (float64(n) - th.tokens) / rate
+		th.mu.Unlock()
+		time.Sleep(min(time.Duration(short*float64(time.Second)), ioThrottleSlice))
+		if !th.throttled(ih, i) {
+			return
+		}
+	}
+}
+
+// Wrap puts ci's piece writes and hashing under the throttle.
+func (th *IOThrottle) Wrap(ci storage.ClientImpl) storage.ClientImpl {
+	return throttledClient{ci, th}
+}
+
+type throttledClient struct {
+	storage.ClientImpl
+	th *IOThrottle
+}
+
+func (c throttledClient) OpenTorrent(info *metainfo.Info, ih metainfo.Hash) (storage.TorrentImpl, error) {
+	t, err := c.ClientImpl.OpenTorrent(info, ih)
+	if err != nil || t.Piece == nil {
+		return t, err
+	}
+	piece := t.Piece
+	t.Piece = func(p metainfo.Piece) storage.PieceImpl {
+		return throttledPiece{piece(p), c.th, ih, p.Index(), p.Length()}
+	}
+	return t, nil
+}
+
+type throttledPiece struct {
+	storage.PieceImpl
+	th    *IOThrottle
+	ih    metainfo.Hash
+	index int
+	len   int64
+}
+
+func (p throttledPiece) WriteAt(b []byte, off int64) (int, error) {
+	p.th.wait(p.ih, p.index, len(b), &p.th.writes)
+	return p.PieceImpl.WriteAt(b, off)
+}
+
+// WriteTo is how the client reads a piece to hash it.
+func (p throttledPiece) WriteTo(w io.Writer) (int64, error) {
+	var done int64
+	buf := make([]byte, min(ioHashChunk, p.len))
+	for done < p.len {
+		b := buf[:min(int64(len(buf)), p.len-done)]
+		p.th.wait(p.ih, p.index, len(b), &p.th.hashed)
+		n, err := p.PieceImpl.ReadAt(b, done)
+		if n > 0 {
+			if _, werr := w.Write(b[:n]); werr != nil {
+				return done, werr
+			}
+			done += int64(n)
+		}
+		if err != nil && (err != io.EOF || done < p.len) {
+			return done, err
+		}
+	}
+	return done, nil
+}
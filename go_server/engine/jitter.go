@@ -0,0 +1,94 @@
+package engine
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent"
+)
+
+// Timing jitter. Some networks throttle BitTorrent by its rhythm even when
+// the payload is encrypted: announces exactly one interval apart, a burst
+// of connection attempts to many hosts at once, keepalives on the minute.
+// With jitter on, every tracker announce (HTTP and UDP) waits a random
+// moment before it goes out, which also moves the next one since its
+// interval counts from the end of this one; peer dials are paced instead
+// of bursting; and the keepalive period is drawn per client.
+
+const (
+	jitterMaxAnnounceDelay = 5 * time.Second // well inside the 15s announce timeout
+	jitterDialRate         = 4               // outgoing peer dials per second
+	jitterDialBurst        = 2
+	jitterKeepAliveMin     = 40 * time.Second // peers drop a connection silent for 2 minutes
+	jitterKeepAliveSpread  = 50 * time.Second
+)
+
+// Jitter randomises the client's traffic timing when enabled. Set it up
+// before the client is built.
+type Jitter struct {
+	Enabled bool
+}
+
+// DefaultJitter is the one NewClientConfig applies.
+var DefaultJitter = &Jitter{}
+
+// Apply makes cfg's announces, dials and keepalives irregular. It wraps
+// the tracker dialer cfg already has, so it goes after DualStack's Apply.
+func (j *Jitter) Apply(cfg *torrent.ClientConfig) {
+	if !j.Enabled {
+		return
+	}
+	dial := cfg.TrackerDialContext
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	cfg.TrackerDialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if err := jitterSleep(ctx); err != nil {
+			return nil, err
+		}
+		return dial(ctx, network, addr)
+	}
+	listen := cfg.TrackerListenPacket
+	if listen == nil {
+		listen = net.ListenPacket
+	}
+	cfg.TrackerListenPacket = func(network, addr string) (net.PacketConn, error) {
+		pc, err := listen(network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return &jitterPacketConn{PacketConn: pc}, nil
+	}
+	if cfg.DialRateLimiter != nil {
+		cfg.DialRateLimiter.SetLimit(jitterDialRate)
+		cfg.DialRateLimiter.SetBurst(jitterDialBurst)
+	}
+	cfg.KeepAliveTimeout = jitterKeepAliveMin + time.Duration(rand.Int63n(int64(jitterKeepAliveSpread)))
+}
+
+// jitterSleep waits a random part of jitterMaxAnnounceDelay.
+func jitterSleep(ctx context.Context) error {
+	t := time.NewTimer(time.Duration(rand.Int63n(int64(jitterMaxAnnounceDelay))))
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// jitterPacketConn delays the first packet of a UDP tracker exchange; the
+// client opens one per announce.
+type jitterPacketConn struct {
+	net.PacketConn
+	once sync.Once
+}
+
+func (c *jitterPacketConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	c.once.Do(func() { _ = jitterSleep(context.Background()) })
+	return c.PacketConn.WriteTo(p, addr)
+}
@@ -0,0 +1,87 @@
+package engine
+
+import (
+	"log"
+
+	"github.com/anacrolix/torrent"
+)
+
+// Host lifecycle hooks. Mobile hosts (notably iOS, where the engine runs
+// in-process and exec is not an option) report connectivity from their
+// path monitor and app foreground/background transitions. Data requests
+// are paused while the device is offline or the app is suspended, so
+// peers aren't snubbed for timeouts we caused and the swarm picks up
+// where it left off once we're back. Going to the background alone
+// doesn't pause: the host may hold a background task to finish the
+// download, and downloading goes on until it says the task expired.
+
+// NetworkChanged records the host's connectivity: "wifi", "cellular",
+// "wired", "other" or "none".
+func (e *Engine) NetworkChanged(kind string) {
+	e.mu.Lock()
+	prev := e.status.Network
+	e.status.Network = kind
+	t := e.t
+	e.mu.Unlock()
+	if prev != kind {
+		log.Printf("engine: network %q → %q", prev, kind)
+		DefaultDualStack.Reset() // the new path may route IPv6 differently
+	}
+	e.applyLifecycle(t)
+}
+
+// SetBackground tells the engine the host app went to (or left) the
+// background. Coming back to the foreground ends a suspension.
+func (e *Engine) SetBackground(bg bool) {
+	e.mu.Lock()
+	e.status.Background = bg
+	if !bg {
+		e.status.Suspended = false
+	}
+	t := e.t
+	e.mu.Unlock()
+	e.applyLifecycle(t)
+}
+
+// BackgroundExpired tells the engine the host's background time is up (or
+// it got none): data download pauses until the app is in the foreground
+// again.
+func (e *Engine) BackgroundExpired() {
+	e.mu.Lock()
+	e.status.Suspended = e.status.Background
+	t := e.t
+	e.mu.Unlock()
+	e.applyLifecycle(t)
+}
+
+// BackgroundWorkPending reports whether the engine would still like to run:
+// a stream is loading or the selected file isn't complete yet, and
+// downloading isn't paused. Hosts use it to decide when to end a background
+// task early.
+func (e *Engine) BackgroundWorkPending() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.t == nil || e.status.Suspended || e.status.Network == "none" {
+		return false
+	}
+	if e.f == nil {
+		return e.status.State == "loading"
+	}
+	return e.f.BytesCompleted() < e.f.Length()
+}
+
+// applyLifecycle pauses or resumes data download on t to match the host
+// state.
+func (e *Engine) applyLifecycle(t *torrent.Torrent) {
+	if t == nil {
+		return
+	}
+	e.mu.RLock()
+	paused := e.status.Suspended || e.status.Network == "none"
+	e.mu.RUnlock()
+	if paused {
+		t.DisallowDataDownload()
+	} else {
+		t.AllowDataDownload()
+	}
+}
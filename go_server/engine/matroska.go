@@ -0,0 +1,776 @@
+package engine
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Matroska subtitle tracks. A Matroska file keeps its text subtitles as
+// blocks inside the clusters, interleaved with video, so getting one out
+// means finding those blocks without reading the whole file. ParseMKV reads
+// the EBML header, the track list and the cues (the seek index, often at
+// the end of the file). When the cues index a subtitle track, as mkvmerge
+// writes them, every block of it can be read where it lies: a few bytes of
+// each cluster holding one. Otherwise clusters have to be read in full, and
+// only those already on disk are worth reading. Compressed tracks (zlib,
+// header stripping) are decoded; encrypted ones aren't supported.
+
+const (
+	mkvIDEBML        = 0x1A45DFA3
+	mkvIDSegment     = 0x18538067
+	mkvIDSeekHead    = 0x114D9B74
+	mkvIDSeek        = 0x4DBB
+	mkvIDSeekID      = 0x53AB
+	mkvIDSeekPos     = 0x53AC
+	mkvIDInfo        = 0x1549A966
+	mkvIDTimecodeSc  = 0x2AD7B1
+	mkvIDTracks      = 0x1654AE6B
+	mkvIDTrackEntry  = 0xAE
+	mkvIDTrackNumber = 0xD7
+	mkvIDTrackType   = 0x83
+	mkvIDCodecID     = 0x86
+	mkvIDCodecPriv   = 0x63A2
+	mkvIDLanguage    = 0x22B59C
+	mkvIDLangIETF    = 0x22B59D
+	mkvIDName        = 0x536E
+	mkvIDFlagDefault = 0x88
+	mkvIDFlagForced  = 0x55AA
+	mkvIDEncodings   = 0x6D80
+	mkvIDEncoding    = 0x6240
+	mkvIDEncScope    = 0x5032
+	mkvIDEncType     = 0x5033
+	mkvIDCompression = 0x5034
+	mkvIDCompAlgo    = 0x4254
+	mkvIDCompSetting = 0x4255
+	mkvIDCues        = 0x1C53BB6B
+	mkvIDCuePoint    = 0xBB
+	mkvIDCueTime     = 0xB3
+	mkvIDCuePos      = 0xB7
+	mkvIDCueTrack    = 0xF7
+	mkvIDCueCluster  = 0xF1
+	mkvIDCueRelative = 0xF0
+	mkvIDCluster     = 0x1F43B675
+	mkvIDTimecode    = 0xE7
+	mkvIDSimpleBlock = 0xA3
+	mkvIDBlockGroup  = 0xA0
+	mkvIDBlock       = 0xA1
+	mkvIDBlockDur    = 0x9B
+
+	mkvTrackSubtitle = 0x11
+
+	mkvMaxElement = 64 << 20 // larger than any header, cue list or cluster worth reading
+	mkvTopLevel   = 256      // top-level elements looked at before the first cluster
+)
+
+// MKVTrack is a track of a Matroska file.
+type MKVTrack struct {
+	Number       uint64
+	Type         uint64 // 1 video, 2 audio, 0x11 subtitle
+	CodecID      string // "S_TEXT/UTF8", "S_TEXT/ASS", …
+	CodecPrivate []byte // the [Script Info] and [V4+ Styles] of an ASS track
+	Lang         string // IETF tag when given, else the ISO 639-2 one
+	Name         string
+	Default      bool
+	Forced       bool
+
+	compAlgo  int64 // -1 none, 0 zlib, 3 header stripping
+	stripped  []byte
+	encrypted bool
+}
+
+// MKVCue is one cue point's position for one track.
+type MKVCue struct {
+	Track    uint64
+	Time     uint64 // in timecode units
+	Cluster  int64  // file offset of the cluster
+	Relative int64  // offset of the block in the cluster's data; -1 unknown
+}
+
+// MKVBlock is a frame of a track: for a subtitle, one cue's text.
+type MKVBlock struct {
+	Track    uint64
+	Time     time.Duration
+	Duration time.Duration // 0 unknown
+	Data     []byte
+}
+
+// MKV is what ParseMKV learned about a file.
+type MKV struct {
+	Size          int64
+	Segment       int64 // file offset of the segment's data
+	SegmentEnd    int64
+	TimecodeScale uint64 // ns per timecode unit
+	Tracks        []MKVTrack
+	Cues          []MKVCue
+	FirstCluster  int64 // file offset; 0 when not found
+}
+
+// ebmlElement is an element's header: its ID, where its data starts and
+// how long it is (-1 unknown, as for live-written clusters).
+type ebmlElement struct {
+	ID   uint32
+	Off  int64
+	Data int64
+	Size int64
+}
+
+func (e ebmlElement) end() int64 { return e.Data + e.Size }
+
+var errNotMKV = errors.New("not a Matroska file")
+
+// ParseMKV reads the header, tracks and cues of the Matroska file r.
+func ParseMKV(r io.ReaderAt, size int64) (*MKV, error) {
+	hdr, err := readElement(r, 0, size)
+	if err != nil || hdr.ID != mkvIDEBML || hdr.Size < 0 {
+		return nil, errNotMKV
+	}
+	seg, err := readElement(r, hdr.end(), size)
+	if err != nil || seg.ID != mkvIDSegment {
+		return nil, errNotMKV
+	}
+	m := &MKV{Size: size, Segment: seg.Data, SegmentEnd: size, TimecodeScale: 1000000}
+	if seg.Size >= 0 {
+		m.SegmentEnd = min(seg.end(), size)
+	}
+	seeks := map[uint32]int64{}
+	var haveInfo, haveTracks, haveCues bool
+	off := seg.Data
+	for n := 0; off < m.SegmentEnd && n < mkvTopLevel; n++ {
+		e, err := readElement(r, off, m.SegmentEnd)
+		if err != nil {
+			return nil, err
+		}
+		if e.ID == mkvIDCluster {
+			m.FirstCluster = e.Off
+			break
+		}
+		if e.Size < 0 {
+			break
+		}
+		switch e.ID {
+		case mkvIDSeekHead:
+			b, err := readData(r, e)
+			if err != nil {
+				return nil, err
+			}
+			parseSeekHead(b, seeks)
+		case mkvIDInfo:
+			err, haveInfo = m.readInfo(r, e), true
+		case mkvIDTracks:
+			err, haveTracks = m.readTracks(r, e), true
+		case mkvIDCues:
+			err, haveCues = m.readCues(r, e), true
+		}
+		if err != nil {
+			return nil, err
+		}
+		off = e.end()
+	}
+	// Whatever comes after the clusters, the seek head points at.
+	for _, want := range []struct {
+		id   uint32
+		have *bool
+		read func(io.ReaderAt, ebmlElement) error
+	}{{mkvIDInfo, &haveInfo, m.readInfo}, {mkvIDTracks, &haveTracks, m.readTracks}, {mkvIDCues, &haveCues, m.readCues}} {
+		pos, ok := seeks[want.id]
+		if *want.have || !ok {
+			continue
+		}
+		e, err := readElement(r, m.Segment+pos, m.SegmentEnd)
+		if err != nil || e.ID != want.id || e.Size < 0 {
+			continue // a stale seek head
+		}
+		if err := want.read(r, e); err != nil {
+			return nil, err
+		}
+		*want.have = true
+	}
+	if !haveTracks {
+		return nil, errors.New("matroska: no track list")
+	}
+	return m, nil
+}
+
+// SubtitleTracks are the text subtitle tracks that can be extracted.
+func (m *MKV) SubtitleTracks() []MKVTrack {
+	var out []MKVTrack
+	for _, t := range m.Tracks {
+		if t.Type == mkvTrackSubtitle && MKVSubtitleFormat(t.CodecID) != "" && !t.encrypted {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// Track is track number n; false when there is none.
+func (m *MKV) Track(n uint64) (MKVTrack, bool) {
+	for _, t := range m.Tracks {
+		if t.Number == n {
+			return t, true
+		}
+	}
+	return MKVTrack{}, false
+}
+
+// MKVSubtitleFormat is the sidecar format of a subtitle codec: "srt",
+// "ass" or "vtt"; "" for bitmap and unknown codecs.
+func MKVSubtitleFormat(codecID string) string {
+	switch codecID {
+	case "S_TEXT/UTF8", "S_TEXT/ASCII":
+		return "srt"
+	case "S_TEXT/ASS", "S_TEXT/SSA", "S_ASS", "S_SSA":
+		return "ass"
+	case "S_TEXT/WEBVTT":
+		return "vtt"
+	}
+	return ""
+}
+
+// CuesFor are the cue positions of track, one per block, in file order.
+func (m *MKV) CuesFor(track uint64) []MKVCue {
+	var out []MKVCue
+	seen := map[[2]int64]bool{}
+	for _, c := range m.Cues {
+		if k := [2]int64{c.Cluster, c.Relative}; c.Track == track && !seen[k] {
+			seen[k] = true
+			out = append(out, c)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Cluster != out[j].Cluster {
+			return out[i].Cluster < out[j].Cluster
+		}
+		return out[i].Relative < out[j].Relative
+	})
+	return out
+}
+
+// Indexed reports whether the cues point at track's blocks, so it can be
+// read without the clusters around it.
+func (m *MKV) Indexed(track uint64) bool { return len(m.CuesFor(track)) > 0 }
+
+// clusterPositions are the clusters the cues know of, any track, in order.
+func (m *MKV) clusterPositions() []int64 {
+	seen := map[int64]bool{}
+	var out []int64
+	for _, c := range m.Cues {
+		if !seen[c.Cluster] {
+			seen[c.Cluster] = true
+			out = append(out, c.Cluster)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
+
+// ErrMKVNotPresent is ReadCue's answer when have says the bytes it needs
+// aren't there yet.
+var ErrMKVNotPresent = errors.New("matroska: not downloaded yet")
+
+// ReadCue reads the block c points at, or the blocks of c.Track in its
+// whole cluster when the cue doesn't say where in it the block is. With
+// have, it only reads bytes have reports present; nil reads regardless.
+func (m *MKV) ReadCue(r io.ReaderAt, c MKVCue, have func(off, n int64) bool) ([]MKVBlock, error) {
+	present := func(off, n int64) bool { return have == nil || have(off, min(n, m.SegmentEnd-off)) }
+	if !present(c.Cluster, 12) {
+		return nil, ErrMKVNotPresent
+	}
+	cl, err := readElement(r, c.Cluster, m.SegmentEnd)
+	if err != nil {
+		return nil, err
+	}
+	if cl.ID != mkvIDCluster {
+		return nil, fmt.Errorf("matroska: no cluster at %d", c.Cluster)
+	}
+	if c.Relative < 0 {
+		if cl.Size >= 0 && !present(cl.Off, cl.end()-cl.Off) {
+			return nil, ErrMKVNotPresent
+		}
+		return m.readCluster(r, cl, c.Track)
+	}
+	if !present(cl.Data, 64) {
+		return nil, ErrMKVNotPresent
+	}
+	tc, err := m.clusterTimecode(r, cl)
+	if err != nil {
+		return nil, err
+	}
+	if !present(cl.Data+c.Relative, 12) {
+		return nil, ErrMKVNotPresent
+	}
+	e, err := readElement(r, cl.Data+c.Relative, m.SegmentEnd)
+	if err != nil {
+		return nil, err
+	}
+	if e.Size < 0 || (e.ID != mkvIDBlockGroup && e.ID != mkvIDSimpleBlock) {
+		return nil, fmt.Errorf("matroska: no block at %d", e.Off)
+	}
+	if !present(e.Data, e.Size) {
+		return nil, ErrMKVNotPresent
+	}
+	b, err := readData(r, e)
+	if err != nil {
+		return nil, err
+	}
+	return m.blocks(e.ID, b, tc, c.Track), nil
+}
+
+// Clusters walks the clusters in file order and answers those wholly
+// present, as cues of track without a block position, and whether that was
+// all of them; for a track the cues don't index. With have it reads only
+// present bytes and jumps over missing ones by the clusters the cues know
+// of; nil reads every cluster header, waiting for each.
+func (m *MKV) Clusters(r io.ReaderAt, track uint64, have func(off, n int64) bool) ([]MKVCue, bool) {
+	present := func(off, n int64) bool { return have == nil || have(off, min(n, m.SegmentEnd-off)) }
+	known := m.clusterPositions()
+	var out []MKVCue
+	all := m.FirstCluster > 0
+	for off := m.FirstCluster; off > 0 && off < m.SegmentEnd; {
+		if !present(off, 12) {
+			all = false
+			i := sort.Search(len(known), func(i int) bool { return known[i] > off })
+			if i == len(known) {
+				break
+			}
+			off = known[i]
+			continue
+		}
+		e, err := readElement(r, off, m.SegmentEnd)
+		if err != nil || e.Size < 0 {
+			if err != nil || e.ID == mkvIDCluster {
+				all = false // a live-written cluster can't be stepped over
+			}
+			break
+		}
+		if e.ID == mkvIDCluster {
+			if present(e.Off, e.end()-e.Off) {
+				out = append(out, MKVCue{Track: track, Cluster: e.Off, Relative: -1})
+			} else {
+				all = false
+			}
+		}
+		off = e.end() // cues or tags between clusters are stepped over too
+	}
+	return out, all
+}
+
+// readCluster reads cluster cl whole and answers track's blocks in it.
+func (m *MKV) readCluster(r io.ReaderAt, cl ebmlElement, track uint64) ([]MKVBlock, error) {
+	if cl.Size < 0 {
+		return nil, fmt.Errorf("matroska: cluster at %d has no size", cl.Off)
+	}
+	b, err := readData(r, cl)
+	if err != nil {
+		return nil, err
+	}
+	var tc uint64
+	var out []MKVBlock
+	ebmlChildren(b, func(id uint32, data []byte) {
+		switch id {
+		case mkvIDTimecode:
+			tc = ebmlUint(data)
+		case mkvIDSimpleBlock, mkvIDBlockGroup:
+			out = append(out, m.blocks(id, data, tc, track)...)
+		}
+	})
+	return out, nil
+}
+
+func (m *MKV) clusterTimecode(r io.ReaderAt, cl ebmlElement) (uint64, error) {
+	// The timecode is the cluster's first child in every muxer's output;
+	// look a little further in case of a CRC-32 or void element.
+	n := int64(64)
+	if cl.Size >= 0 {
+		n = min(n, cl.Size)
+	}
+	b := make([]byte, n)
+	k, err := r.ReadAt(b, cl.Data)
+	if k == 0 && err != nil {
+		return 0, err
+	}
+	var tc uint64
+	found := false
+	ebmlChildren(b[:k], func(id uint32, data []byte) {
+		if id == mkvIDTimecode && !found {
+			tc, found = ebmlUint(data), true
+		}
+	})
+	if !found {
+		return 0, fmt.Errorf("matroska: cluster at %d has no timecode up front", cl.Off)
+	}
+	return tc, nil
+}
+
+// blocks decodes a SimpleBlock or BlockGroup of track, nil for another
+// track's.
+func (m *MKV) blocks(id uint32, b []byte, clusterTC, track uint64) []MKVBlock {
+	var block []byte
+	var dur uint64
+	if id == mkvIDSimpleBlock {
+		block = b
+	} else {
+		ebmlChildren(b, func(id uint32, data []byte) {
+			switch id {
+			case mkvIDBlock:
+				block = data
+			case mkvIDBlockDur:
+				dur = ebmlUint(data)
+			}
+		})
+	}
+	num, n := ebmlVint(block, false)
+	if n <= 0 || num != track || len(block) < n+3 {
+		return nil
+	}
+	rel := int16(uint16(block[n])<<8 | uint16(block[n+1]))
+	if block[n+2]&0x06 != 0 {
+		return nil // laced: not used for subtitles
+	}
+	data := block[n+3:]
+	t, _ := m.Track(track)
+	data, err := t.decode(data)
+	if err != nil {
+		return nil
+	}
+	scale := time.Duration(m.TimecodeScale)
+	return []MKVBlock{{Track: track, Time: time.Duration(int64(clusterTC)+int64(rel)) * scale,
+		Duration: time.Duration(dur) * scale, Data: data}}
+}
+
+// decode undoes the track's content compression.
+func (t MKVTrack) decode(b []byte) ([]byte, error) {
+	switch t.compAlgo {
+	case 0:
+		zr, err := zlib.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		return io.ReadAll(io.LimitReader(zr, mkvMaxElement))
+	case 3:
+		return append(append([]byte{}, t.stripped...), b...), nil
+	}
+	return b, nil
+}
+
+func (m *MKV) readInfo(r io.ReaderAt, e ebmlElement) error {
+	b, err := readData(r, e)
+	if err != nil {
+		return err
+	}
+	ebmlChildren(b, func(id uint32, data []byte) {
+		if id == mkvIDTimecodeSc {
+			if v := ebmlUint(data); v > 0 {
+				m.TimecodeScale = v
+			}
+		}
+	})
+	return nil
+}
+
+func (m *MKV) readTracks(r io.ReaderAt, e ebmlElement) error {
+	b, err := readData(r, e)
+	if err != nil {
+		return err
+	}
+	ebmlChildren(b, func(id uint32, data []byte) {
+		if id != mkvIDTrackEntry {
+			return
+		}
+		t := MKVTrack{Lang: "eng", Default: true, compAlgo: -1}
+		var ietf string
+		ebmlChildren(data, func(id uint32, v []byte) {
+			switch id {
+			case mkvIDTrackNumber:
+				t.Number = ebmlUint(v)
+			case mkvIDTrackType:
+				t.Type = ebmlUint(v)
+			case mkvIDCodecID:
+				t.CodecID = ebmlString(v)
+			case mkvIDCodecPriv:
+				t.CodecPrivate = append([]byte{}, v...)
+			case mkvIDLanguage:
+				t.Lang = ebmlString(v)
+			case mkvIDLangIETF:
+				ietf = ebmlString(v)
+			case mkvIDName:
+				t.Name = ebmlString(v)
+			case mkvIDFlagDefault:
+				t.Default = ebmlUint(v) != 0
+			case mkvIDFlagForced:
+				t.Forced = ebmlUint(v) != 0
+			case mkvIDEncodings:
+				t.readEncodings(v)
+			}
+		})
+		if ietf != "" {
+			t.Lang = ietf
+		}
+		m.Tracks = append(m.Tracks, t)
+	})
+	return nil
+}
+
+func (t *MKVTrack) readEncodings(b []byte) {
+	ebmlChildren(b, func(id uint32, enc []byte) {
+		if id != mkvIDEncoding {
+			return
+		}
+		scope, typ := uint64(1), uint64(0)
+		algo, stripped := int64(0), []byte(nil)
+		ebmlChildren(enc, func(id uint32, v []byte) {
+			switch id {
+			case mkvIDEncScope:
+				scope = ebmlUint(v)
+			case mkvIDEncType:
+				typ = ebmlUint(v)
+			case mkvIDCompression:
+				ebmlChildren(v, func(id uint32, v []byte) {
+					switch id {
+					case mkvIDCompAlgo:
+						algo = int64(ebmlUint(v))
+					case mkvIDCompSetting:
+						stripped = append([]byte{}, v...)
+					}
+				})
+			}
+		})
+		switch {
+		case scope&1 == 0: // private data only
+		case typ != 0:
+			t.encrypted = true
+		case algo == 0 || algo == 3:
+			t.compAlgo, t.stripped = algo, stripped
+		default:
+			t.encrypted = true // bzlib or lzo1x: as good as unreadable here
+		}
+	})
+}
+
+func (m *MKV) readCues(r io.ReaderAt, e ebmlElement) error {
+	b, err := readData(r, e)
+	if err != nil {
+		return err
+	}
+	ebmlChildren(b, func(id uint32, data []byte) {
+		if id != mkvIDCuePoint {
+			return
+		}
+		var tm uint64
+		var pos [][]byte
+		ebmlChildren(data, func(id uint32, v []byte) {
+			switch id {
+			case mkvIDCueTime:
+				tm = ebmlUint(v)
+			case mkvIDCuePos:
+				pos = append(pos, v)
+			}
+		})
+		for _, p := range pos {
+			c := MKVCue{Time: tm, Relative: -1}
+			ebmlChildren(p, func(id uint32, v []byte) {
+				switch id {
+				case mkvIDCueTrack:
+					c.Track = ebmlUint(v)
+				case mkvIDCueCluster:
+					c.Cluster = m.Segment + int64(ebmlUint(v))
+				case mkvIDCueRelative:
+					c.Relative = int64(ebmlUint(v))
+				}
+			})
+			m.Cues = append(m.Cues, c)
+		}
+	})
+	return nil
+}
+
+func parseSeekHead(b []byte, seeks map[uint32]int64) {
+	ebmlChildren(b, func(id uint32, data []byte) {
+		if id != mkvIDSeek {
+			return
+		}
+		var sid uint32
+		var pos int64 = -1
+		ebmlChildren(data, func(id uint32, v []byte) {
+			switch id {
+			case mkvIDSeekID:
+				sid = uint32(ebmlUint(v))
+			case mkvIDSeekPos:
+				pos = int64(ebmlUint(v))
+			}
+		})
+		if _, dup := seeks[sid]; sid != 0 && pos >= 0 && !dup {
+			seeks[sid] = pos
+		}
+	})
+}
+
+// readElement reads the element header at off.
+func readElement(r io.ReaderAt, off, limit int64) (ebmlElement, error) {
+	if off >= limit {
+		return ebmlElement{}, io.EOF
+	}
+	b := make([]byte, min(12, limit-off))
+	n, err := r.ReadAt(b, off)
+	if n == 0 && err != nil {
+		return ebmlElement{}, err
+	}
+	b = b[:n]
+	id, k := ebmlVint(b, true)
+	if k <= 0 || k > 4 {
+		return ebmlElement{}, fmt.Errorf("matroska: bad element ID at %d", off)
+	}
+	size, s := ebmlVint(b[k:], false)
+	if s <= 0 {
+		return ebmlElement{}, fmt.Errorf("matroska: bad element size at %d", off)
+	}
+	e := ebmlElement{ID: uint32(id), Off: off, Data: off + int64(k+s), Size: int64(size)}
+	if size == 1<<(7*s)-1 {
+		e.Size = -1 // all ones: unknown
+	}
+	return e, nil
+}
+
+// readData reads an element's data.
+func readData(r io.ReaderAt, e ebmlElement) ([]byte, error) {
+	if e.Size < 0 || e.Size > mkvMaxElement {
+		return nil, fmt.Errorf("matroska: element at %d too large to read", e.Off)
+	}
+	b := make([]byte, e.Size)
+	n, err := r.ReadAt(b, e.Data)
+	if int64(n) < e.Size {
+		if err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return b, nil
+}
+
+// ebmlChildren calls fn for each element in b, a master element's data.
+func ebmlChildren(b []byte, fn func(id uint32, data []byte)) {
+	for len(b) > 0 {
+		id, k := ebmlVint(b, true)
+		if k <= 0 || k > 4 {
+			return
+		}
+		size, s := ebmlVint(b[k:], false)
+		if s <= 0 || size > uint64(len(b)-k-s) {
+			return
+		}
+		fn(uint32(id), b[k+s:k+s+int(size)])
+		b = b[k+s+int(size):]
+	}
+}
+
+// ebmlVint decodes a variable-length integer and answers its length, 0 for
+// a bad one. IDs keep their length marker; sizes and track numbers don't.
+func ebmlVint(b []byte, keepMarker bool) (uint64, int) {
+	if len(b) == 0 || b[0] == 0 {
+		return 0, 0
+	}
+	n := 1
+	for mask := byte(0x80); b[0]&mask == 0; mask >>= 1 {
+		n++
+	}
+	if n > 8 || len(b) < n {
+		return 0, 0
+	}
+	v := uint64(b[0])
+	if !keepMarker {
+		v &= uint64(0xFF >> n)
+	}
+	for _, c := range b[1:n] {
+		v = v<<8 | uint64(c)
+	}
+	return v, n
+}
+
+func ebmlUint(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+func ebmlString(b []byte) string {
+	return strings.TrimRight(string(b), "\x00")
+}
+
+// MKVSubtitleText writes blocks of track t as a sidecar file in
+// MKVSubtitleFormat(t.CodecID): SubRip for text tracks, a full ASS script
+// (the track's header, then a Dialogue line per block) for ASS and SSA,
+// WebVTT for WebVTT. Blocks are sorted by time.
+func MKVSubtitleText(t MKVTrack, blocks []MKVBlock) string {
+	sort.SliceStable(blocks, func(i, j int) bool { return blocks[i].Time < blocks[j].Time })
+	var sb strings.Builder
+	switch MKVSubtitleFormat(t.CodecID) {
+	case "ass":
+		head := strings.TrimRight(strings.ReplaceAll(string(t.CodecPrivate), "\r\n", "\n"), "\n")
+		sb.WriteString(head + "\n")
+		if !strings.Contains(head, "[Events]") {
+			sb.WriteString("\n[Events]\nFormat: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text\n")
+		}
+		for _, b := range blocks {
+			// ReadOrder, Layer, Style, Name, MarginL, MarginR, MarginV, Effect, Text
+			f := strings.SplitN(string(b.Data), ",", 9)
+			if len(f) < 9 {
+				continue
+			}
+			fmt.Fprintf(&sb, "Dialogue: %s,%s,%s,%s\n", f[1], assTime(b.Time), assTime(b.Time+blockDuration(b)), strings.Join(f[2:], ","))
+		}
+	case "vtt":
+		sb.WriteString("WEBVTT\n")
+		for _, b := range blocks {
+			fmt.Fprintf(&sb, "\n%s --> %s\n%s\n", cueTime(b.Time, "."), cueTime(b.Time+blockDuration(b), "."), strings.TrimRight(string(b.Data), "\n"))
+		}
+	default:
+		for i, b := range blocks {
+			fmt.Fprintf(&sb, "%d\n%s --> %s\n%s\n\n", i+1, cueTime(b.Time, ","), cueTime(b.Time+blockDuration(b), ","), strings.TrimRight(string(b.Data), "\r\n"))
+		}
+	}
+	return sb.String()
+}
+
+// MKVAssToSRT turns ASS blocks into SubRip blocks: the text field, without
+// override tags, with \N as a line break.
+func MKVAssToSRT(blocks []MKVBlock) []MKVBlock {
+	out := make([]MKVBlock, 0, len(blocks))
+	for _, b := range blocks {
+		f := strings.SplitN(string(b.Data), ",", 9)
+		if len(f) < 9 {
+			continue
+		}
+		text := srtTagRe.ReplaceAllString(f[8], "")
+		text = strings.NewReplacer(`\N`, "\n", `\n`, "\n", `\h`, " ").Replace(text)
+		b.Data = []byte(text)
+		out = append(out, b)
+	}
+	return out
+}
+
+// blockDuration is b's duration; a subtitle without one shows for a few
+// seconds.
+func blockDuration(b MKVBlock) time.Duration {
+	if b.Duration > 0 {
+		return b.Duration
+	}
+	return 3 * time.Second
+}
+
+func cueTime(d time.Duration, sep string) string {
+	ms := max(d, 0).Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", ms/3600000, ms/60000%60, ms/1000%60, sep, ms%1000)
+}
+
+func assTime(d time.Duration) string {
+	cs := max(d, 0).Milliseconds() / 10
+	return fmt.Sprintf("%d:%02d:%02d.%02d", cs/360000, cs/6000%60, cs/100%60, cs%100)
+}
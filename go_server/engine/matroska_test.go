@@ -0,0 +1,289 @@
+package engine
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// mkvEl writes an element; sizes always take 8 bytes, so where a child
+// lands doesn't depend on what comes before it.
+func mkvEl(id uint32, children ...[]byte) []byte {
+	var b []byte
+	for shift := 24; shift >= 0; shift -= 8 {
+		if c := byte(id >> shift); c != 0 || len(b) > 0 {
+			b = append(b, c)
+		}
+	}
+	data := bytes.Join(children, nil)
+	size := make([]byte, 8)
+	binary.BigEndian.PutUint64(size, uint64(len(data)))
+	size[0] = 0x01
+	return append(append(b, size...), data...)
+}
+
+func mkvUint(id uint32, v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return mkvEl(id, b)
+}
+
+func mkvStr(id uint32, s string) []byte { return mkvEl(id, []byte(s)) }
+
+// mkvBlock is a Block or SimpleBlock's data: track, relative time, flags.
+func mkvBlock(track uint64, rel int16, data string) []byte {
+	return append([]byte{0x80 | byte(track), byte(uint16(rel) >> 8), byte(rel), 0x80}, data...)
+}
+
+func mkvBlockGroup(track uint64, rel int16, dur uint64, data string) []byte {
+	return mkvEl(mkvIDBlockGroup, mkvEl(mkvIDBlock, mkvBlock(track, rel, data)), mkvUint(mkvIDBlockDur, dur))
+}
+
+const testAssLine = `0,0,Default,,0,0,0,,{\i1}Hi\Nthere`
+
+// testMKV is a small file: a video track, an SRT track the cues index, a
+// zlib-compressed ASS track they don't and a PGS one; two clusters, and the
+// cues after them, found through the seek head.
+func testMKV(t testing.TB) []byte {
+	var z bytes.Buffer
+	zw := zlib.NewWriter(&z)
+	zw.Write([]byte(testAssLine))
+	zw.Close()
+
+	header := mkvEl(mkvIDEBML, mkvStr(0x4282, "matroska"))
+	info := mkvEl(mkvIDInfo, mkvUint(mkvIDTimecodeSc, 1000000))
+	tracks := mkvEl(mkvIDTracks,
+		mkvEl(mkvIDTrackEntry, mkvUint(mkvIDTrackNumber, 1), mkvUint(mkvIDTrackType, 1), mkvStr(mkvIDCodecID, "V_MPEG4/ISO/AVC")),
+		mkvEl(mkvIDTrackEntry, mkvUint(mkvIDTrackNumber, 2), mkvUint(mkvIDTrackType, mkvTrackSubtitle),
+			mkvStr(mkvIDCodecID, "S_TEXT/UTF8"), mkvStr(mkvIDLanguage, "ger"), mkvUint(mkvIDFlagDefault, 0)),
+		mkvEl(mkvIDTrackEntry, mkvUint(mkvIDTrackNumber, 3), mkvUint(mkvIDTrackType, mkvTrackSubtitle),
+			mkvStr(mkvIDCodecID, "S_TEXT/ASS"), mkvStr(mkvIDLangIETF, "pt-BR"), mkvStr(mkvIDCodecPriv, "[Script Info]\r\nTitle: x\r\n"),
+			mkvEl(mkvIDEncodings, mkvEl(mkvIDEncoding, mkvEl(mkvIDCompression, mkvUint(mkvIDCompAlgo, 0))))),
+		mkvEl(mkvIDTrackEntry, mkvUint(mkvIDTrackNumber, 4), mkvUint(mkvIDTrackType, mkvTrackSubtitle), mkvStr(mkvIDCodecID, "S_HDMV/PGS")),
+	)
+	seekHead := func(cues uint64) []byte {
+		return mkvEl(mkvIDSeekHead, mkvEl(mkvIDSeek, mkvUint(mkvIDSeekID, mkvIDCues), mkvUint(mkvIDSeekPos, cues)))
+	}
+
+	video := mkvEl(mkvIDSimpleBlock, mkvBlock(1, 0, "frame"))
+	hello := mkvBlockGroup(2, 1000, 2000, "Hello")
+	c1Kids := [][]byte{mkvUint(mkvIDTimecode, 0), video, hello, mkvEl(mkvIDSimpleBlock, mkvBlock(3, 500, z.String()))}
+	c2Kids := [][]byte{mkvUint(mkvIDTimecode, 5000), mkvBlockGroup(2, 0, 1500, "World")}
+	c1, c2 := mkvEl(mkvIDCluster, c1Kids...), mkvEl(mkvIDCluster, c2Kids...)
+
+	c1Pos := uint64(len(seekHead(0)) + len(info) + len(tracks))
+	c2Pos := c1Pos + uint64(len(c1))
+	helloRel := uint64(len(c1Kids[0]) + len(video))
+	cues := mkvEl(mkvIDCues,
+		mkvEl(mkvIDCuePoint, mkvUint(mkvIDCueTime, 0),
+			mkvEl(mkvIDCuePos, mkvUint(mkvIDCueTrack, 1), mkvUint(mkvIDCueCluster, c1Pos))),
+		mkvEl(mkvIDCuePoint, mkvUint(mkvIDCueTime, 1000),
+			mkvEl(mkvIDCuePos, mkvUint(mkvIDCueTrack, 2), mkvUint(mkvIDCueCluster, c1Pos), mkvUint(mkvIDCueRelative, helloRel))),
+		mkvEl(mkvIDCuePoint, mkvUint(mkvIDCueTime, 5000),
+			mkvEl(mkvIDCuePos, mkvUint(mkvIDCueTrack, 1), mkvUint(mkvIDCueCluster, c2Pos)),
+			mkvEl(mkvIDCuePos, mkvUint(mkvIDCueTrack, 2), mkvUint(mkvIDCueCluster, c2Pos), mkvUint(mkvIDCueRelative, uint64(len(c2Kids[0]))))),
+	)
+	segment := mkvEl(mkvIDSegment, seekHead(c2Pos+uint64(len(c2))), info, tracks, c1, c2, cues)
+	return append(header, segment...)
+}
+
+// testHave reports bytes present below limit.
+func testHave(limit int64) func(off, n int64) bool {
+	return func(off, n int64) bool { return off >= 0 && n >= 0 && off+n <= limit }
+}
+
+func TestParseMKV(t *testing.T) {
+	file := testMKV(t)
+	m, err := ParseMKV(bytes.NewReader(file), int64(len(file)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Tracks) != 4 || m.TimecodeScale != 1000000 || m.FirstCluster == 0 {
+		t.Fatalf("%d tracks, scale %d, first cluster at %d", len(m.Tracks), m.TimecodeScale, m.FirstCluster)
+	}
+	for _, tc := range []struct {
+		track   uint64
+		format  string
+		lang    string
+		listed  bool // among SubtitleTracks
+		indexed bool
+	}{
+		{1, "", "eng", false, true},
+		{2, "srt", "ger", true, true},
+		{3, "ass", "pt-BR", true, false},
+		{4, "", "eng", false, false},
+	} {
+		tr, ok := m.Track(tc.track)
+		if !ok {
+			t.Fatalf("no track %d", tc.track)
+		}
+		listed := false
+		for _, s := range m.SubtitleTracks() {
+			listed = listed || s.Number == tc.track
+		}
+		if f := MKVSubtitleFormat(tr.CodecID); f != tc.format || tr.Lang != tc.lang || listed != tc.listed || m.Indexed(tc.track) != tc.indexed {
+			t.Errorf("track %d: format %q lang %q listed %v indexed %v, want %q %q %v %v",
+				tc.track, f, tr.Lang, listed, m.Indexed(tc.track), tc.format, tc.lang, tc.listed, tc.indexed)
+		}
+	}
+	if tr, _ := m.Track(2); tr.Default {
+		t.Errorf("track 2 is default, want not")
+	}
+}
+
+func TestParseMKVErrors(t *testing.T) {
+	file := testMKV(t)
+	tracksless := append(mkvEl(mkvIDEBML, mkvStr(0x4282, "matroska")),
+		mkvEl(mkvIDSegment, mkvEl(mkvIDInfo, mkvUint(mkvIDTimecodeSc, 1000)), mkvEl(mkvIDCluster, mkvUint(mkvIDTimecode, 0)))...)
+	for _, tc := range []struct {
+		name string
+		b    []byte
+	}{
+		{"empty", nil},
+		{"not EBML", []byte("RIFF\x00\x00\x00\x00AVI LIST")},
+		{"header only", mkvEl(mkvIDEBML, mkvStr(0x4282, "matroska"))},
+		{"zero byte", []byte{0, 0, 0, 0}},
+		{"no track list", tracksless},
+		{"cut in the header", file[:10]},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if m, err := ParseMKV(bytes.NewReader(tc.b), int64(len(tc.b))); err == nil {
+				t.Fatalf("parsed %+v, want an error", m)
+			}
+		})
+	}
+}
+
+func TestMKVReadCue(t *testing.T) {
+	file := testMKV(t)
+	r := bytes.NewReader(file)
+	m, err := ParseMKV(r, int64(len(file)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cues := m.CuesFor(2)
+	if len(cues) != 2 || cues[0].Relative < 0 {
+		t.Fatalf("cues %+v, want 2 with block positions", cues)
+	}
+	var blocks []MKVBlock
+	for _, c := range cues {
+		if _, err := m.ReadCue(r, c, testHave(c.Cluster)); !errors.Is(err, ErrMKVNotPresent) {
+			t.Errorf("cue at %d with nothing there: %v, want ErrMKVNotPresent", c.Cluster, err)
+		}
+		b, err := m.ReadCue(r, c, testHave(int64(len(file))))
+		if err != nil {
+			t.Fatal(err)
+		}
+		blocks = append(blocks, b...)
+	}
+	tr, _ := m.Track(2)
+	want := "1\n00:00:01,000 --> 00:00:03,000\nHello\n\n2\n00:00:05,000 --> 00:00:06,500\nWorld\n\n"
+	if got := MKVSubtitleText(tr, blocks); got != want {
+		t.Errorf("got\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestMKVClusters(t *testing.T) {
+	file := testMKV(t)
+	r := bytes.NewReader(file)
+	m, err := ParseMKV(r, int64(len(file)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := m.CuesFor(2)[1].Cluster
+	for _, tc := range []struct {
+		name      string
+		have      func(off, n int64) bool
+		clusters  int
+		all       bool
+		wantLines int
+	}{
+		{"all there", nil, 2, true, 1},
+		{"first cluster only", testHave(second), 1, false, 1},
+		{"nothing", testHave(m.FirstCluster), 0, false, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cues, all := m.Clusters(r, 3, tc.have)
+			if len(cues) != tc.clusters || all != tc.all {
+				t.Fatalf("%d clusters, all %v; want %d, %v", len(cues), all, tc.clusters, tc.all)
+			}
+			var blocks []MKVBlock
+			for _, c := range cues {
+				b, err := m.ReadCue(r, c, tc.have)
+				if err != nil {
+					t.Fatal(err)
+				}
+				blocks = append(blocks, b...)
+			}
+			if len(blocks) != tc.wantLines {
+				t.Fatalf("%d blocks, want %d", len(blocks), tc.wantLines)
+			}
+			if len(blocks) == 0 {
+				return
+			}
+			if string(blocks[0].Data) != testAssLine || blocks[0].Time != 500e6 {
+				t.Errorf("block %q at %s, want the ASS line at 500ms", blocks[0].Data, blocks[0].Time)
+			}
+			if srt := MKVAssToSRT(blocks); string(srt[0].Data) != "Hi\nthere" {
+				t.Errorf("as SubRip %q, want %q", srt[0].Data, "Hi\nthere")
+			}
+		})
+	}
+}
+
+func TestEbmlVint(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		b          []byte
+		keepMarker bool
+		want       uint64
+		wantLen    int
+	}{
+		{"one byte", []byte{0x81}, false, 1, 1},
+		{"one byte ID", []byte{0xA3}, true, 0xA3, 1},
+		{"two bytes", []byte{0x40, 0x02}, false, 2, 2},
+		{"four byte ID", []byte{0x1A, 0x45, 0xDF, 0xA3}, true, mkvIDEBML, 4},
+		{"eight bytes", []byte{0x01, 0, 0, 0, 0, 0, 1, 0}, false, 256, 8},
+		{"zero first byte", []byte{0x00, 0x81}, false, 0, 0},
+		{"cut short", []byte{0x40}, false, 0, 0},
+		{"empty", nil, false, 0, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if v, n := ebmlVint(tc.b, tc.keepMarker); v != tc.want || n != tc.wantLen {
+				t.Errorf("got %d (%d bytes), want %d (%d bytes)", v, n, tc.want, tc.wantLen)
+			}
+		})
+	}
+}
+
+// FuzzMatroska feeds ParseMKV and the block readers damaged files; they may
+// fail but must not panic or read outside what's there.
+func FuzzMatroska(f *testing.F) {
+	file := testMKV(f)
+	f.Add(file)
+	f.Add(file[:len(file)/2])
+	f.Add(file[:len(file)-7])
+	f.Fuzz(func(t *testing.T, b []byte) {
+		r := bytes.NewReader(b)
+		m, err := ParseMKV(r, int64(len(b)))
+		if err != nil {
+			return
+		}
+		have := testHave(int64(len(b)))
+		for _, tr := range m.SubtitleTracks() {
+			var blocks []MKVBlock
+			cues := m.CuesFor(tr.Number)
+			if len(cues) == 0 {
+				cues, _ = m.Clusters(r, tr.Number, have)
+			}
+			for _, c := range cues {
+				bl, _ := m.ReadCue(r, c, have)
+				blocks = append(blocks, bl...)
+			}
+			MKVSubtitleText(tr, blocks)
+			MKVAssToSRT(blocks)
+		}
+	})
+}
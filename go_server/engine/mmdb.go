@@ -0,0 +1,270 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+)
+
+// A reader for MaxMind DB files (GeoLite2/GeoIP2 City or Country, DB-IP's
+// free databases, anything in the format): the binary search tree over IP
+// bits and just enough of the data section decoder to read a record.
+// https://maxmind.github.io/MaxMind-DB/
+
+var mmdbMetadataStart = []byte("\xab\xcd\xefMaxMind.com")
+
+type mmdb struct {
+	buf        []byte
+	tree, data []byte
+	nodeCount  uint
+	recordSize uint
+	ipv4Start  uint // node for ::/96, where IPv4 lives in an IPv6 tree
+	ipVersion  uint
+}
+
+func openMMDB(path string) (*mmdb, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	i := bytes.LastIndex(buf, mmdbMetadataStart)
+	if i < 0 {
+		return nil, errors.New("not a MaxMind DB file")
+	}
+	meta := buf[i+len(mmdbMetadataStart):]
+	v, _, err := (&mmdbDecoder{buf: meta}).decode(0)
+	if err != nil {
+		return nil, fmt.Errorf("metadata: %w", err)
+	}
+	m, _ := v.(map[string]any)
+	db := &mmdb{buf: buf,
+		nodeCount:  uint(mmdbUint(m["node_count"])),
+		recordSize: uint(mmdbUint(m["record_size"])),
+		ipVersion:  uint(mmdbUint(m["ip_version"])),
+	}
+	switch db.recordSize {
+	case 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("unsupported record size %d", db.recordSize)
+	}
+	treeSize := db.nodeCount * db.recordSize / 4
+	if treeSize+16 > uint(i) {
+		return nil, errors.New("search tree runs past the data")
+	}
+	db.tree, db.data = buf[:treeSize], buf[treeSize+16:i]
+	if db.ipVersion == 6 {
+		node := uint(0)
+		for n := 0; n < 96 && node < db.nodeCount; n++ {
+			node = db.record(node, 0)
+		}
+		db.ipv4Start = node
+	}
+	return db, nil
+}
+
+// record is node's left (0) or right (1) record.
+func (db *mmdb) record(node uint, bit uint) uint {
+	switch db.recordSize {
+	case 24:
+		b := db.tree[node*6+bit*3:]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		b := db.tree[node*7:]
+		if bit == 0 {
+			return uint(b[3]&0xf0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0f)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		return uint(binary.BigEndian.Uint32(db.tree[node*8+bit*4:]))
+	}
+}
+
+// lookup returns the record for ip, nil when the database has none.
+func (db *mmdb) lookup(ip net.IP) (map[string]any, error) {
+	node, bits := uint(0), ip.To16()
+	if v4 := ip.To4(); v4 != nil {
+		bits = v4
+		if db.ipVersion == 6 {
+			node = db.ipv4Start
+		}
+	} else if db.ipVersion == 4 {
+		return nil, nil
+	}
+	for i := 0; i < len(bits)*8 && node < db.nodeCount; i++ {
+		node = db.record(node, uint(bits[i/8]>>(7-i%8))&1)
+	}
+	if node <= db.nodeCount { // nodeCount itself: no data
+		return nil, nil
+	}
+	off := node - db.nodeCount - 16
+	if off >= uint(len(db.data)) {
+		return nil, errors.New("record pointer out of range")
+	}
+	v, _, err := (&mmdbDecoder{buf: db.data}).decode(off)
+	m, _ := v.(map[string]any)
+	return m, err
+}
+
+type mmdbDecoder struct {
+	buf   []byte
+	depth int
+}
+
+// decode reads the value at off and returns it with the offset after it.
+func (d *mmdbDecoder) decode(off uint) (any, uint, error) {
+	if d.depth > 32 {
+		return nil, 0, errors.New("data nested too deep")
+	}
+	typ, size, off, err := d.control(off)
+	if err != nil {
+		return nil, 0, err
+	}
+	if typ == 1 { // pointer: size holds its bits
+		ptr, next, err := d.pointer(size, off)
+		if err != nil {
+			return nil, 0, err
+		}
+		d.depth++
+		v, _, err := d.decode(ptr)
+		d.depth--
+		return v, next, err
+	}
+	end := off + size
+	switch typ {
+	case 7, 11: // map, array: size counts entries
+	case 14: // boolean: size is the value
+		return size != 0, off, nil
+	default:
+		if end > uint(len(d.buf)) {
+			return nil, 0, errors.New("value runs past the data")
+		}
+	}
+	switch typ {
+	case 2:
+		return string(d.buf[off:end]), end, nil
+	case 3:
+		if size != 8 {
+			return nil, 0, errors.New("bad double")
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(d.buf[off:end])), end, nil
+	case 4:
+		return d.buf[off:end], end, nil
+	case 5, 6, 9, 10:
+		var n uint64
+		for _, b := range d.buf[off:end] {
+			n = n<<8 | uint64(b) // uint128 keeps its low 64 bits
+		}
+		return n, end, nil
+	case 8:
+		var n int32
+		for _, b := range d.buf[off:end] {
+			n = n<<8 | int32(b)
+		}
+		return int64(n), end, nil
+	case 15:
+		if size != 4 {
+			return nil, 0, errors.New("bad float")
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(d.buf[off:end]))), end, nil
+	case 7:
+		m := make(map[string]any, size)
+		d.depth++
+		defer func() { d.depth-- }()
+		for i := uint(0); i < size; i++ {
+			k, next, err := d.decode(off)
+			if err != nil {
+				return nil, 0, err
+			}
+			v, next, err := d.decode(next)
+			if err != nil {
+				return nil, 0, err
+			}
+			key, _ := k.(string)
+			m[key], off = v, next
+		}
+		return m, off, nil
+	case 11:
+		a := make([]any, 0, size)
+		d.depth++
+		defer func() { d.depth-- }()
+		for i := uint(0); i < size; i++ {
+			v, next, err := d.decode(off)
+			if err != nil {
+				return nil, 0, err
+			}
+			a, off = append(a, v), next
+		}
+		return a, off, nil
+	}
+	return nil, 0, fmt.Errorf("unknown data type %d", typ)
+}
+
+// control reads a control byte and its size bytes. For a pointer, size is
+// the control byte's low five bits.
+func (d *mmdbDecoder) control(off uint) (typ, size, next uint, err error) {
+	if off >= uint(len(d.buf)) {
+		return 0, 0, 0, errors.New("offset past the data")
+	}
+	c := d.buf[off]
+	off++
+	typ = uint(c >> 5)
+	if typ == 0 { // extended
+		if off >= uint(len(d.buf)) {
+			return 0, 0, 0, errors.New("offset past the data")
+		}
+		typ = 7 + uint(d.buf[off])
+		off++
+	}
+	size = uint(c & 0x1f)
+	if typ == 1 || size < 29 {
+		return typ, size, off, nil
+	}
+	n := size - 28 // 1, 2 or 3 more bytes
+	if off+n > uint(len(d.buf)) {
+		return 0, 0, 0, errors.New("size runs past the data")
+	}
+	var v uint
+	for _, b := range d.buf[off : off+n] {
+		v = v<<8 | uint(b)
+	}
+	switch n {
+	case 1:
+		size = 29 + v
+	case 2:
+		size = 285 + v
+	default:
+		size = 65821 + v
+	}
+	return typ, size, off + n, nil
+}
+
+func (d *mmdbDecoder) pointer(bits, off uint) (ptr, next uint, err error) {
+	n := bits>>3&3 + 1
+	if off+n > uint(len(d.buf)) {
+		return 0, 0, errors.New("pointer runs past the data")
+	}
+	var v uint
+	for _, b := range d.buf[off : off+n] {
+		v = v<<8 | uint(b)
+	}
+	switch n {
+	case 1:
+		ptr = (bits&7)<<8 | v
+	case 2:
+		ptr = ((bits&7)<<16 | v) + 2048
+	case 3:
+		ptr = ((bits&7)<<24 | v) + 526336
+	default:
+		ptr = v
+	}
+	return ptr, off + n, nil
+}
+
+func mmdbUint(v any) uint64 {
+	n, _ := v.(uint64)
+	return n
+}
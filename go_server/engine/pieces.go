@@ -0,0 +1,143 @@
+package engine
+
+import (
+	"fmt"
+
+	"github.com/anacrolix/torrent"
+)
+
+// Piece lengths outside [tinyPieceLen, hugePieceLen] get special handling.
+// With huge pieces a single boundary means waiting for 16+ MB from whatever
+// peers happen to hold that piece, so we look further ahead and spread the
+// requests over more connections. Tiny pieces cost a hash + HAVE per few KB,
+// so more of them need to be in flight to keep peers busy.
+const (
+	tinyPieceLen     = 64 << 10 // 64 KiB
+	hugePieceLen     = 8 << 20  // 8 MiB
+	DefaultReadahead = 8 << 20  // 8 MB
+)
+
+// Established connections per torrent: the client default, and what
+// ApplyPieceProfile allows a torrent with huge pieces.
+const (
+	EstablishedConns     = 80
+	hugeEstablishedConns = 120
+)
+
+type PieceProfile struct {
+	Length    int64  `json:"piece_length"`
+	Class     string `json:"piece_class"` // "tiny" | "normal" | "huge"
+	Readahead int64  `json:"readahead"`
+	Warning   string `json:"-"` // surfaced via the server's /info warnings
+}
+
+// ProfilePieces classifies the torrent's piece length and derives the
+// reader readahead that avoids stalls at piece boundaries.
+func ProfilePieces(t *torrent.Torrent) PieceProfile {
+	p := PieceProfile{Length: t.Info().PieceLength, Class: "normal", Readahead: DefaultReadahead}
+	switch {
+	case p.Length >= hugePieceLen:
+		p.Class = "huge"
+		// Keep the next two pieces requested while the current one drains.
+		p.Readahead = 3 * p.Length
+		p.Warning = fmt.Sprintf("piece length %d MiB is very large; playback may pause at piece boundaries on slow swarms", p.Length>>20)
+	case p.Length > 0 && p.Length < tinyPieceLen:
+		p.Class = "tiny"
+		p.Readahead = 2 * DefaultReadahead
+		p.Warning = fmt.Sprintf("piece length %d KiB is very small; expect extra protocol and hashing overhead", p.Length>>10)
+	}
+	return p
+}
+
+// ApplyPieceProfile adjusts per-torrent connection limits so that enough
+// requests are pipelined for the piece size at hand.
+func ApplyPieceProfile(t *torrent.Torrent, p PieceProfile) {
+	if p.Class == "huge" {
+		// More peers means more chunks of the same giant piece in flight.
+		t.SetMaxEstablishedConns(hugeEstablishedConns)
+	}
+}
+
+// MaxConns is the established-connection limit ApplyPieceProfile leaves a
+// torrent with this profile.
+func (p PieceProfile) MaxConns() int {
+	if p.Class == "huge" {
+		return hugeEstablishedConns
+	}
+	return EstablishedConns
+}
+
+// PieceSpan maps a file onto the torrent's pieces. Files rarely start or
+// end on a piece boundary, and the torrent's final piece is usually short,
+// so every offset ↔ piece conversion should go through here.
+type PieceSpan struct {
+	Begin, End  int   // pieces holding the file's bytes: [Begin, End)
+	PieceLength int64 // nominal piece length
+	FileOffset  int64 // where the file starts in the torrent
+	FileLength  int64
+	TotalLength int64 // of the torrent; bounds the final piece
+}
+
+// PieceRange returns the piece span of f. The zero span (no pieces) is
+// returned before the info is known.
+func PieceRange(f *torrent.File) PieceSpan {
+	info := f.Torrent().Info()
+	if info == nil {
+		return PieceSpan{}
+	}
+	return pieceRange(f.Offset(), f.Length(), info.PieceLength, info.TotalLength())
+}
+
+func pieceRange(fileOff, fileLen, pieceLen, total int64) PieceSpan {
+	s := PieceSpan{PieceLength: pieceLen, FileOffset: fileOff, FileLength: fileLen, TotalLength: total}
+	if pieceLen <= 0 {
+		return s
+	}
+	s.Begin = int(fileOff / pieceLen)
+	s.End = s.Begin // an empty file holds no piece
+	if fileLen > 0 {
+		s.End = int((fileOff + fileLen + pieceLen - 1) / pieceLen)
+	}
+	return s
+}
+
+// Len is the number of pieces in the span.
+func (s PieceSpan) Len() int { return s.End - s.Begin }
+
+// Bounds is piece i's byte range in the torrent, [start, end).
+func (s PieceSpan) Bounds(i int) (start, end int64) {
+	start = int64(i) * s.PieceLength
+	return start, min(start+s.PieceLength, s.TotalLength)
+}
+
+// FileBounds is the part of piece i that belongs to the file, in file
+// offsets [start, end); empty when the piece holds none of it.
+func (s PieceSpan) FileBounds(i int) (start, end int64) {
+	ps, pe := s.Bounds(i)
+	start = max(ps, s.FileOffset) - s.FileOffset
+	end = min(pe, s.FileOffset+s.FileLength) - s.FileOffset
+	if end < start {
+		return 0, 0
+	}
+	return start, end
+}
+
+// PiecesFor is how many pieces make up n bytes, rounded up and at least
+// least. Windows and budgets are sized in bytes: a count fit for 1 MiB
+// pieces leaves next to nothing in flight with 16 KiB ones.
+func (s PieceSpan) PiecesFor(n int64, least int) int {
+	if s.PieceLength <= 0 {
+		return least
+	}
+	return max(int((n+s.PieceLength-1)/s.PieceLength), least)
+}
+
+// PieceAt is the piece holding file offset off, clamped to the span so
+// offsets at or past EOF map to the last piece. -1 for an empty span.
+func (s PieceSpan) PieceAt(off int64) int {
+	if s.Len() == 0 {
+		return -1
+	}
+	off = min(max(off, 0), s.FileLength-1)
+	return int((s.FileOffset + off) / s.PieceLength)
+}
@@ -0,0 +1,118 @@
+package engine
+
+import "testing"
+
+const testPiece = 256 << 10
+
+func TestPieceRange(t *testing.T) {
+	for _, tc := range []struct {
+		name                 string
+		off, length, total   int64
+		wantBegin, wantEnd   int
+		wantFirst, wantFinal [2]int64 // FileBounds of Begin and End-1
+	}{
+		{"aligned single file", 0, 4 * testPiece, 4 * testPiece, 0, 4,
+			[2]int64{0, testPiece}, [2]int64{3 * testPiece, 4 * testPiece}},
+		{"short final piece", 0, 3*testPiece + 100, 3*testPiece + 100, 0, 4,
+			[2]int64{0, testPiece}, [2]int64{3 * testPiece, 3*testPiece + 100}},
+		{"starts and ends mid-piece", testPiece + 10, 2 * testPiece, 5 * testPiece, 1, 4,
+			[2]int64{0, testPiece - 10}, [2]int64{2*testPiece - 10, 2 * testPiece}},
+		{"ends exactly on a boundary", 100, 2*testPiece - 100, 3 * testPiece, 0, 2,
+			[2]int64{0, testPiece - 100}, [2]int64{testPiece - 100, 2*testPiece - 100}},
+		{"inside one piece", 10, 20, testPiece, 0, 1,
+			[2]int64{0, 20}, [2]int64{0, 20}},
+		{"last file in a short final piece", 2*testPiece + 50, 100, 2*testPiece + 150, 2, 3,
+			[2]int64{0, 100}, [2]int64{0, 100}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := pieceRange(tc.off, tc.length, testPiece, tc.total)
+			if s.Begin != tc.wantBegin || s.End != tc.wantEnd {
+				t.Fatalf("pieces [%d, %d), want [%d, %d)", s.Begin, s.End, tc.wantBegin, tc.wantEnd)
+			}
+			if a, b := s.FileBounds(s.Begin); [2]int64{a, b} != tc.wantFirst {
+				t.Errorf("first piece covers [%d, %d), want %v", a, b, tc.wantFirst)
+			}
+			if a, b := s.FileBounds(s.End - 1); [2]int64{a, b} != tc.wantFinal {
+				t.Errorf("final piece covers [%d, %d), want %v", a, b, tc.wantFinal)
+			}
+			var sum int64
+			for i := s.Begin; i < s.End; i++ {
+				a, b := s.FileBounds(i)
+				sum += b - a
+			}
+			if sum != tc.length {
+				t.Errorf("pieces cover %d bytes of the file, want %d", sum, tc.length)
+			}
+		})
+	}
+}
+
+func TestPieceRangeEmptyFile(t *testing.T) {
+	s := pieceRange(testPiece+5, 0, testPiece, 2*testPiece)
+	if s.Len() != 0 {
+		t.Fatalf("empty file spans %d pieces", s.Len())
+	}
+	if got := s.PieceAt(0); got != -1 {
+		t.Errorf("PieceAt on empty span = %d, want -1", got)
+	}
+}
+
+func TestPieceRangeNoInfo(t *testing.T) {
+	if s := pieceRange(0, 100, 0, 100); s.Len() != 0 {
+		t.Fatalf("zero piece length spans %d pieces", s.Len())
+	}
+}
+
+func TestPieceAt(t *testing.T) {
+	s := pieceRange(testPiece+10, 2*testPiece, testPiece, 5*testPiece)
+	for _, tc := range []struct {
+		off  int64
+		want int
+	}{
+		{-1, 1},
+		{0, 1},
+		{testPiece - 11, 1},
+		{testPiece - 10, 2},
+		{2*testPiece - 1, 3},
+		{2 * testPiece, 3}, // EOF clamps to the last piece
+		{10 * testPiece, 3},
+	} {
+		if got := s.PieceAt(tc.off); got != tc.want {
+			t.Errorf("PieceAt(%d) = %d, want %d", tc.off, got, tc.want)
+		}
+	}
+}
+
+func TestBoundsFinalPiece(t *testing.T) {
+	s := pieceRange(0, 3*testPiece+100, testPiece, 3*testPiece+100)
+	if a, b := s.Bounds(3); a != 3*testPiece || b != 3*testPiece+100 {
+		t.Errorf("final piece is [%d, %d), want [%d, %d)", a, b, 3*testPiece, 3*testPiece+100)
+	}
+	if a, b := s.FileBounds(5); a != 0 || b != 0 {
+		t.Errorf("piece outside the file covers [%d, %d)", a, b)
+	}
+}
+
+func TestPiecesFor(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		pieceLen int64
+		n        int64
+		least    int
+		want     int
+	}{
+		{"tiny pieces", 16 << 10, 8 << 20, 8, 512},
+		{"64 KiB pieces", 64 << 10, 8 << 20, 8, 128},
+		{"rounds up", testPiece, testPiece + 1, 1, 2},
+		{"ordinary pieces keep the floor", 4 << 20, 8 << 20, 8, 8},
+		{"huge pieces keep the floor", 16 << 20, 4 << 20, 4, 4},
+		{"no info", 0, 8 << 20, 8, 8},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := PieceSpan{PieceLength: tc.pieceLen}
+			if got := s.PiecesFor(tc.n, tc.least); got != tc.want {
+				t.Errorf("PiecesFor(%d, %d) = %d, want %d", tc.n, tc.least, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package engine
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	pp "github.com/anacrolix/torrent/peer_protocol"
+)
+
+// Request pipelining. A connection delivers at most its outstanding
+// requests' worth of data per round trip: sixteen 16 KiB blocks in flight
+// to a peer 300ms away, as over a mobile link, cap it near 850 KB/s however
+// fast it is. The client starts a connection at the depth the peer asks
+// for (the extended handshake's reqq; geoip.go starts far peers shallower)
+// and never raises it. With tuning on, a connection whose queue is full,
+// so that the queue and not the link limits it, doubles its depth every
+// round trip, up to what the peer said it takes. Once it has run a while,
+// one far deeper than its bandwidth-delay product (the shortest request
+// round trip of the last few seconds times the fastest rate blocks arrived
+// at) shrinks to twice that, which keeps the playback window off slow
+// peers' queues.
+
+const (
+	pipeMinDepth    = 4
+	pipeDefaultReqq = 250 // what the client assumes of a peer that names none
+	pipeRateWindow  = time.Second
+	pipeFilterSpan  = 10 * time.Second // the min round trip and max rate are of this long
+	pipeHeadroom    = 2
+)
+
+// Pipeline tunes request queue depths per connection. Set it up before the
+// client is built; it is on unless Static.
+type Pipeline struct {
+	Static bool // keep the client's own depths
+
+	mu    sync.Mutex
+	peers map[*torrent.Peer]*pipePeer
+}
+
+// DefaultPipeline is the one NewClientConfig applies.
+var DefaultPipeline = &Pipeline{}
+
+type pipeKey struct{ index, begin pp.Integer }
+
+type pipePeer struct {
+	limit int // the peer's reqq
+	sent  map[pipeKey]time.Time
+
+	minRTT  time.Duration
+	rttAt   time.Time
+	maxRate float64 // bytes/s
+	rateAt  time.Time
+	bytes   int64 // in the current rate window
+	window  time.Time
+
+	born   time.Time
+	depth  int // the current depth
+	tuned  bool
+	grewAt time.Time
+}
+
+// PipelineStats describes the tuned connections.
+type PipelineStats struct {
+	Peers    int `json:"peers"`
+	MinDepth int `json:"min_depth"`
+	MaxDepth int `json:"max_depth"`
+}
+
+// Apply hooks the tuning into cfg's request callbacks. The depth limit
+// comes from handshake, which NewClientConfig's extended handshake hook
+// calls.
+func (p *Pipeline) Apply(cfg *torrent.ClientConfig) {
+	if p.Static {
+		return
+	}
+	cb := &cfg.Callbacks
+	cb.SentRequest = append(cb.SentRequest, p.sentRequest)
+	cb.ReceivedRequested = append(cb.ReceivedRequested, p.receivedRequested)
+	cb.DeletedRequest = append(cb.DeletedRequest, p.deletedRequest)
+	cb.PeerClosed = append(cb.PeerClosed, p.peerClosed)
+}
+
+// Stats summarises the connections tuned so far.
+func (p *Pipeline) Stats() PipelineStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var s PipelineStats
+	for _, st := range p.peers {
+		if !st.tuned {
+			continue
+		}
+		if s.Peers == 0 || st.depth < s.MinDepth {
+			s.MinDepth = st.depth
+		}
+		s.MaxDepth = max(s.MaxDepth, st.depth)
+		s.Peers++
+	}
+	return s
+}
+
+// handshake records the depth the peer asked for and the one it starts at
+// (geoip.go may have cut it).
+func (p *Pipeline) handshake(c *torrent.PeerConn, asked, start int) {
+	if p.Static {
+		return
+	}
+	if asked == 0 {
+		asked = pipeDefaultReqq
+	}
+	if start == 0 {
+		start = pipeDefaultReqq
+	}
+	p.mu.Lock()
+	st := p.peer(&c.Peer)
+	st.limit, st.depth = asked, min(start, asked)
+	p.mu.Unlock()
+}
+
+// peer is the state for pr, made on first sight. Caller holds p.mu; the
+// callbacks run under the client lock, so pr's fields may be read.
+func (p *Pipeline) peer(pr *torrent.Peer) *pipePeer {
+	if p.peers == nil {
+		p.peers = map[*torrent.Peer]*pipePeer{}
+	}
+	st := p.peers[pr]
+	if st == nil {
+		depth := max(pr.PeerMaxRequests, pipeMinDepth)
+		st = &pipePeer{limit: depth, depth: depth, born: time.Now(), sent: map[pipeKey]time.Time{}}
+		p.peers[pr] = st
+	}
+	return st
+}
+
+func (p *Pipeline) sentRequest(e torrent.PeerRequestEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.peer(e.Peer).sent[pipeKey{e.Index, e.Begin}] = time.Now()
+}
+
+func (p *Pipeline) deletedRequest(e torrent.PeerRequestEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if st := p.peers[e.Peer]; st != nil {
+		delete(st.sent, pipeKey{e.Index, e.Begin})
+	}
+}
+
+func (p *Pipeline) peerClosed(pr *torrent.Peer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.peers, pr)
+}
+
+// receivedRequested takes a round trip and rate sample from a block that
+// came in and retunes the connection's depth. The block's request is still
+// counted as outstanding.
+func (p *Pipeline) receivedRequested(e torrent.PeerMessageEvent) {
+	msg := e.Message
+	now := time.Now()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	st := p.peer(e.Peer)
+	k := pipeKey{msg.Index, msg.Begin}
+	if at, ok := st.sent[k]; ok {
+		delete(st.sent, k)
+		if rtt := now.Sub(at); st.minRTT == 0 || rtt < st.minRTT || now.Sub(st.rttAt) > pipeFilterSpan {
+			st.minRTT, st.rttAt = rtt, now
+		}
+	}
+	if st.window.IsZero() {
+		st.window = now
+	}
+	st.bytes += int64(len(msg.Piece))
+	if span := now.Sub(st.window); span >= pipeRateWindow {
+		if rate := float64(st.bytes) / span.Seconds(); rate > st.maxRate || now.Sub(st.rateAt) > pipeFilterSpan {
+			st.maxRate, st.rateAt = rate, now
+		}
+		st.bytes, st.window = 0, now
+	}
+	if st.minRTT == 0 || len(msg.Piece) == 0 {
+		return
+	}
+	depth := st.depth
+	bdp := st.maxRate * st.minRTT.Seconds() / float64(len(msg.Piece)) // in blocks
+	switch want := int(math.Ceil(pipeHeadroom * bdp)); {
+	case len(st.sent)+1 >= st.depth && now.Sub(st.grewAt) >= st.minRTT:
+		depth, st.grewAt = st.depth*2, now
+	case st.maxRate > 0 && now.Sub(st.born) >= pipeFilterSpan && want < st.depth/2:
+		depth = want
+	}
+	depth = min(max(depth, pipeMinDepth), st.limit)
+	if depth != st.depth {
+		st.depth, st.tuned = depth, true
+		e.Peer.PeerMaxRequests = depth
+	}
+}
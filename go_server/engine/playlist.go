@@ -0,0 +1,102 @@
+package engine
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/anacrolix/torrent"
+)
+
+// PlaylistFiles are the files of t worth playing one after the other: the
+// videos and audio files filter allows, samples and extras left out unless
+// that leaves nothing, and audio tracks that pair with a video (they play
+// alongside it, not after it) left out. Episodes come in season and
+// episode order, then everything else in natural path order (2 before 10).
+func PlaylistFiles(t *torrent.Torrent, filter Filter) []*torrent.File {
+	var videos, media []*torrent.File
+	for _, f := range t.Files() {
+		switch FileType(f.DisplayPath()) {
+		case "video":
+			videos = append(videos, f)
+		case "audio":
+		default:
+			continue
+		}
+		if filter.Allows(f) {
+			media = append(media, f)
+		}
+	}
+	media, _ = filter.preferMain(media)
+	out := media[:0]
+	for _, f := range media {
+		if FileType(f.DisplayPath()) == "audio" && pairsWithVideo(f, videos) {
+			continue
+		}
+		out = append(out, f)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return playlistLess(out[i].DisplayPath(), out[j].DisplayPath()) })
+	return out
+}
+
+func pairsWithVideo(f *torrent.File, videos []*torrent.File) bool {
+	for _, v := range videos {
+		if _, _, ok := PairCompanion(v.DisplayPath(), f.DisplayPath()); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// playlistLess puts episodes first, by season and episode, then the rest by
+// NaturalLess.
+func playlistLess(a, b string) bool {
+	ea, aok := ParseEpisode(a)
+	eb, bok := ParseEpisode(b)
+	switch {
+	case aok && bok && ea != eb:
+		if ea.Season != eb.Season {
+			return ea.Season < eb.Season
+		}
+		return ea.Number < eb.Number
+	case aok != bok:
+		return aok
+	}
+	return NaturalLess(a, b)
+}
+
+// NaturalLess compares paths the way people count: runs of digits by
+// value, the rest case-insensitively, so "Track 2" sorts before "Track 10".
+func NaturalLess(a, b string) bool {
+	la, lb := strings.ToLower(a), strings.ToLower(b)
+	for la != "" && lb != "" {
+		da, db := digitRun(la), digitRun(lb)
+		if da > 0 && db > 0 {
+			na := strings.TrimLeft(la[:da], "0")
+			nb := strings.TrimLeft(lb[:db], "0")
+			if len(na) != len(nb) {
+				return len(na) < len(nb)
+			}
+			if na != nb {
+				return na < nb
+			}
+			la, lb = la[da:], lb[db:]
+			continue
+		}
+		if la[0] != lb[0] {
+			return la[0] < lb[0]
+		}
+		la, lb = la[1:], lb[1:]
+	}
+	if la != lb {
+		return la == ""
+	}
+	return a < b
+}
+
+func digitRun(s string) int {
+	n := 0
+	for n < len(s) && s[n] >= '0' && s[n] <= '9' {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,114 @@
+package engine
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	pp "github.com/anacrolix/torrent/peer_protocol"
+)
+
+// Private torrents (BEP 27). Announcing a private tracker's torrent to the
+// DHT or swapping its peers over PEX gets the account banned, and the
+// anacrolix client ignores the info dict's private flag. So NewClientConfig
+// turns off the client's own DHT announcing and AnnouncePublic does it
+// instead, stopping once the metadata says private; the extended handshake
+// hook drops ut_pex for private torrents, so no PEX goes either way.
+// Connections made before a magnet's metadata arrived may have swapped PEX
+// already; they are closed and redialled. (The client has no LSD.) Extra
+// trackers can only be kept off when the metadata comes with the add, as
+// it does from a private tracker's .torrent file.
+
+const (
+	dhtAnnounceTimeout = 5 * time.Minute // per traversal, as the client's own
+	dhtReannounce      = time.Minute
+)
+
+var announcing sync.Map // *torrent.Torrent → struct{}, while AnnouncePublic runs
+
+// IsPrivate reports whether t's metadata carries the private flag. It is
+// false until the metadata is known.
+func IsPrivate(t *torrent.Torrent) bool {
+	info := t.Info()
+	return info != nil && info.Private != nil && *info.Private
+}
+
+// AnnouncePublic announces t to cl's DHT servers until t closes or turns
+// out to be private. Calling it again for the same torrent does nothing.
+func AnnouncePublic(cl *torrent.Client, t *torrent.Torrent) {
+	if _, running := announcing.LoadOrStore(t, struct{}{}); running {
+		return
+	}
+	for _, s := range cl.DhtServers() {
+		go announceLoop(t, s)
+	}
+	go func() {
+		defer announcing.Delete(t)
+		select {
+		case <-t.GotInfo():
+		case <-t.Closed():
+			return
+		}
+		if IsPrivate(t) {
+			log.Printf("engine: %s is private: no DHT or PEX", t.Name())
+			redialWithoutPex(t)
+		}
+		<-t.Closed()
+	}()
+}
+
+func announceLoop(t *torrent.Torrent, s torrent.DhtServer) {
+	gotInfo := t.GotInfo()
+	for !IsPrivate(t) {
+		done, stop, err := t.AnnounceToDht(s)
+		if err == nil {
+			timeout := time.After(dhtAnnounceTimeout)
+		wait:
+			for {
+				select {
+				case <-done:
+					break wait
+				case <-timeout:
+					break wait
+				case <-gotInfo:
+					if IsPrivate(t) {
+						break wait
+					}
+					gotInfo = nil
+				case <-t.Closed():
+					stop()
+					return
+				}
+			}
+			stop()
+		}
+		select {
+		case <-time.After(dhtReannounce):
+		case <-t.Closed():
+			return
+		}
+	}
+}
+
+// redialWithoutPex closes t's connections, which may have PEX enabled, and
+// dials the same peers again.
+func redialWithoutPex(t *torrent.Torrent) {
+	known := t.KnownSwarm()
+	conns := t.PeerConns()
+	for _, c := range conns {
+		c.Close()
+	}
+	if len(conns) > 0 {
+		t.AddPeers(known)
+	}
+}
+
+// hidePrivatePex is the client's extended handshake hook: the remote's
+// ut_pex is forgotten for private torrents, which leaves PEX off on the
+// connection.
+func hidePrivatePex(c *torrent.PeerConn, m *pp.ExtendedHandshakeMessage) {
+	if IsPrivate(c.Torrent()) {
+		delete(m.M, pp.ExtensionNamePex)
+	}
+}
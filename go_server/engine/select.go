@@ -0,0 +1,302 @@
+package engine
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/anacrolix/torrent"
+)
+
+// Hint narrows automatic file selection. The zero Hint means "no idea".
+type Hint struct {
+	File    string `json:"file,omitempty"`    // exact display path; wins when present in the torrent
+	Title   string `json:"title,omitempty"`   // show or movie title, matched loosely against paths
+	Episode string `json:"episode,omitempty"` // "S02E05", "2x05" or "s2e5"; "105" or "E105" in any season
+}
+
+// Filter keeps files out of automatic selection altogether. It does not
+// apply to an explicitly requested file.
+type Filter struct {
+	DenyExts     []string         // lower-case, with the dot
+	MinSize      int64            // bytes
+	ExcludePaths []*regexp.Regexp // matched against the display path
+
+	// AvoidWords mark samples and extras: a file with one of them as a
+	// word of its path is only picked when nothing else is left.
+	AvoidWords []string // lower-case
+
+	// PreferExts orders containers, best first (say .mp4 before .mkv for
+	// a device that hardware-decodes MP4 only): among the candidates a
+	// file in a listed container wins over a larger one in a container
+	// listed later or not at all. Size decides within a container.
+	PreferExts []string // lower-case, with the dot
+}
+
+// DefaultDenyExts never hold anything playable but can be the largest file
+// in a junk or fake torrent.
+var DefaultDenyExts = []string{".txt", ".nfo", ".exe", ".lnk", ".url", ".scr", ".bat", ".html"}
+
+// DefaultAvoidWords name the videos packs carry besides the feature, some
+// of them large enough to win on size.
+var DefaultAvoidWords = []string{"sample", "extras", "featurette", "featurettes", "trailer", "trailers"}
+
+// Allows reports whether f may be auto-selected.
+func (fl Filter) Allows(f *torrent.File) bool {
+	ext := strings.ToLower(filepath.Ext(f.DisplayPath()))
+	for _, d := range fl.DenyExts {
+		if ext == d {
+			return false
+		}
+	}
+	if f.Length() < fl.MinSize {
+		return false
+	}
+	for _, re := range fl.ExcludePaths {
+		if re.MatchString(f.DisplayPath()) {
+			return false
+		}
+	}
+	return true
+}
+
+// Avoids reports whether f looks like a sample or an extra.
+func (fl Filter) Avoids(f *torrent.File) bool {
+	return hasWord(f.DisplayPath(), fl.AvoidWords)
+}
+
+// preferMain drops the files fl avoids, unless that leaves none; n is how
+// many were dropped.
+func (fl Filter) preferMain(files []*torrent.File) (kept []*torrent.File, n int) {
+	for _, f := range files {
+		if !fl.Avoids(f) {
+			kept = append(kept, f)
+		}
+	}
+	if len(kept) == 0 {
+		return files, 0
+	}
+	return kept, len(files) - len(kept)
+}
+
+// rank is f's place in PreferExts; unlisted containers come last.
+func (fl Filter) rank(f *torrent.File) int {
+	ext := strings.ToLower(filepath.Ext(f.DisplayPath()))
+	for i, e := range fl.PreferExts {
+		if ext == e {
+			return i
+		}
+	}
+	return len(fl.PreferExts)
+}
+
+// sortPreferred orders files best first: by container preference, then
+// largest.
+func (fl Filter) sortPreferred(files []*torrent.File) {
+	sort.SliceStable(files, func(i, j int) bool {
+		if ri, rj := fl.rank(files[i]), fl.rank(files[j]); ri != rj {
+			return ri < rj
+		}
+		return files[i].Length() > files[j].Length()
+	})
+}
+
+// pickedBy says how the first of files, sorted by sortPreferred, beat the
+// others: ", largest of 3" or ", preferred .mp4 of 3"; "" for one file.
+func (fl Filter) pickedBy(files []*torrent.File) string {
+	if len(files) < 2 {
+		return ""
+	}
+	for _, f := range files[1:] {
+		if f.Length() > files[0].Length() {
+			return fmt.Sprintf(", preferred %s of %d", strings.ToLower(filepath.Ext(files[0].DisplayPath())), len(files))
+		}
+	}
+	return ", largest of " + strconv.Itoa(len(files))
+}
+
+// Selection explains which file was picked and why, so apps can confirm
+// the choice or override it with an explicit file.
+type Selection struct {
+	Reason     string `json:"reason"`
+	Episode    string `json:"episode,omitempty"`    // SxxEyy of the chosen file, if it has one
+	Candidates int    `json:"candidates,omitempty"` // video files considered
+	Filtered   int    `json:"filtered,omitempty"`   // files excluded by the Filter
+	Extras     int    `json:"extras,omitempty"`     // samples and extras passed over
+	Hint       *Hint  `json:"hint,omitempty"`
+}
+
+// Episode is a parsed season/episode pair.
+type Episode struct{ Season, Number int }
+
+func (e Episode) String() string { return fmt.Sprintf("S%02dE%02d", e.Season, e.Number) }
+
+var episodeRes = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)(?:^|[^a-z0-9])s(\d{1,2})[ ._-]?e(\d{1,3})(?:[^0-9]|$)`),
+	regexp.MustCompile(`(?i)(?:^|[^a-z0-9])(\d{1,2})x(\d{2,3})(?:[^0-9]|$)`),
+	regexp.MustCompile(`(?i)(?:^|[^a-z0-9])season[ ._-]?(\d{1,2})[ ._-]*(?:episode|ep)[ ._-]?(\d{1,3})(?:[^0-9]|$)`),
+}
+
+// ParseEpisode finds an SxxEyy (or 2x05, Season 2 Episode 5) marker in s.
+func ParseEpisode(s string) (Episode, bool) {
+	for _, re := range episodeRes {
+		if m := re.FindStringSubmatch(s); m != nil {
+			season, _ := strconv.Atoi(m[1])
+			num, _ := strconv.Atoi(m[2])
+			return Episode{season, num}, true
+		}
+	}
+	return Episode{}, false
+}
+
+// titleWords splits s into lower-case alphanumeric words.
+func titleWords(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// hasWord reports whether one of words is a word of path.
+func hasWord(path string, words []string) bool {
+	if len(words) == 0 {
+		return false
+	}
+	for _, w := range titleWords(path) {
+		for _, x := range words {
+			if w == x {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchesTitle reports whether every word of title appears in path.
+func matchesTitle(path string, title []string) bool {
+	have := map[string]bool{}
+	for _, w := range titleWords(path) {
+		have[w] = true
+	}
+	for _, w := range title {
+		if !have[w] {
+			return false
+		}
+	}
+	return true
+}
+
+// SelectFile picks the file to stream:
+//
+//  1. the hinted path, if the torrent has it;
+//  2. among video files, the hinted episode and/or title;
+//  3. for a season pack (several episodes), the earliest episode;
+//  4. otherwise the largest video, then the largest file of any kind.
+//
+// Only files the filter allows are considered after step 1, and samples and
+// extras only when there is nothing else; the file is nil when none are
+// left.
+func SelectFile(t *torrent.Torrent, hint Hint, filter Filter) (*torrent.File, Selection) {
+	sel := Selection{}
+	if hint != (Hint{}) {
+		sel.Hint = &hint
+	}
+	if f := FileByPath(t, hint.File); f != nil {
+		sel.Reason = "requested file"
+		return f, sel.WithEpisode(f)
+	}
+	var allowed, videos []*torrent.File
+	for _, f := range t.Files() {
+		if !filter.Allows(f) {
+			sel.Filtered++
+			continue
+		}
+		allowed = append(allowed, f)
+		if videoExts[strings.ToLower(filepath.Ext(f.DisplayPath()))] {
+			videos = append(videos, f)
+		}
+	}
+	videos, sel.Extras = filter.preferMain(videos)
+	sel.Candidates = len(videos)
+	if len(videos) == 0 {
+		if len(allowed) == 0 {
+			sel.Reason = "every file excluded by selection filters"
+			return nil, sel
+		}
+		allowed, sel.Extras = filter.preferMain(allowed)
+		sort.SliceStable(allowed, func(i, j int) bool { return allowed[i].Length() > allowed[j].Length() })
+		sel.Reason = "largest file (no video found)"
+		return allowed[0], sel
+	}
+	filter.sortPreferred(videos)
+
+	pool, why := videos, []string{}
+	if want, anySeason, err := parseEpisodePattern(hint.Episode); hint.Episode != "" && err == nil {
+		var match []*torrent.File
+		for _, f := range pool {
+			if _, ok := holdsEpisode(f.DisplayPath(), want, anySeason); ok {
+				match = append(match, f)
+			}
+		}
+		if len(match) > 0 {
+			pool = match
+			if anySeason {
+				why = append(why, fmt.Sprintf("episode %d", want.Number))
+			} else {
+				why = append(why, "episode "+want.String())
+			}
+		}
+	}
+	if words := titleWords(hint.Title); len(words) > 0 {
+		var match []*torrent.File
+		for _, f := range pool {
+			if matchesTitle(f.DisplayPath(), words) {
+				match = append(match, f)
+			}
+		}
+		if len(match) > 0 {
+			pool = match
+			why = append(why, fmt.Sprintf("title %q", hint.Title))
+		}
+	}
+	if len(why) > 0 {
+		sel.Reason = "matched " + strings.Join(why, " and ") + filter.pickedBy(pool)
+		return pool[0], sel.WithEpisode(pool[0])
+	}
+
+	// Season pack: start at the beginning rather than with whichever
+	// episode happens to be longest.
+	eps := map[Episode]bool{}
+	var first *torrent.File
+	var firstEp Episode
+	for _, f := range videos {
+		e, _, ok := episodeSpan(f.DisplayPath())
+		if !ok {
+			continue
+		}
+		eps[e] = true
+		if first == nil || e.Season < firstEp.Season || e.Season == firstEp.Season && e.Number < firstEp.Number {
+			first, firstEp = f, e
+		}
+	}
+	if len(eps) > 1 {
+		sel.Reason = fmt.Sprintf("earliest of %d episodes", len(eps))
+		return first, sel.WithEpisode(first)
+	}
+	sel.Reason = "largest video file"
+	if strings.HasPrefix(filter.pickedBy(videos), ", preferred") {
+		sel.Reason = fmt.Sprintf("largest %s video (preferred container)", strings.ToLower(filepath.Ext(videos[0].DisplayPath())))
+	}
+	return videos[0], sel.WithEpisode(videos[0])
+}
+
+// WithEpisode fills in f's episode marker, if it has one.
+func (s Selection) WithEpisode(f *torrent.File) Selection {
+	if e, ok := ParseEpisode(f.DisplayPath()); ok {
+		s.Episode = e.String()
+	}
+	return s
+}
@@ -0,0 +1,114 @@
+package engine
+
+import (
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/anacrolix/torrent"
+)
+
+// ReadyPercent is how much of the file must be downloaded before a session
+// reports "ready".
+const ReadyPercent = 3
+
+// NewReader opens a streaming reader on f with the given readahead.
+func NewReader(f *torrent.File, readahead int64) torrent.Reader {
+	reader := f.NewReader()
+	reader.SetReadahead(readahead)
+	reader.SetResponsive() // Sequential mode
+	return reader
+}
+
+// ServeContent serves rs as a seekable HTTP stream named name (Range
+// requests, Content-Type guessed from the extension).
+func ServeContent(w http.ResponseWriter, r *http.Request, name string, rs io.ReadSeeker) {
+	w.Header().Set("Content-Type", MimeType(name))
+	w.Header().Set("Accept-Ranges", "bytes")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	// Use http.ServeContent for proper Range support + ETag
+	http.ServeContent(w, r, name, time.Time{}, rs)
+}
+
+// MimeType guesses a MIME type from the file extension: video, or one of
+// the companion subtitle/audio formats.
+func MimeType(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".mkv":
+		return "video/x-matroska"
+	case ".avi":
+		return "video/x-msvideo"
+	case ".webm":
+		return "video/webm"
+	case ".srt":
+		return "application/x-subrip"
+	case ".vtt":
+		return "text/vtt"
+	case ".ass", ".ssa":
+		return "text/x-ssa"
+	case ".idx", ".sub":
+		return "application/octet-stream"
+	case ".ac3":
+		return "audio/ac3"
+	case ".eac3":
+		return "audio/eac3"
+	case ".dts":
+		return "audio/vnd.dts"
+	case ".aac":
+		return "audio/aac"
+	case ".mka":
+		return "audio/x-matroska"
+	}
+	return "video/mp4"
+}
+
+// Sample is one reading of a torrent's transfer counters.
+type Sample struct {
+	Bytes       int64   // useful bytes read this session, any file
+	Progress    float64 // 0–100, verified bytes of the streamed file
+	CompletedMB float64 // verified bytes of the streamed file
+	DownloadMB  float64 // Bytes in MB
+	SpeedKBs    float64 // since the previous sample, assuming 1 s apart
+	Peers       int
+
+	Uploaded       int64 // data bytes sent this session
+	UploadedMB     float64
+	UploadSpeedKBs float64
+	Ratio          float64 // Uploaded / Bytes, 0 before anything was read
+	Seeding        bool
+}
+
+// Measure takes a Sample of t for file f; last is the previous sample.
+// Progress comes from the file's verified pieces, so it counts data from
+// earlier runs and ignores other files of the torrent; the session counters
+// say what this run transferred.
+func Measure(t *torrent.Torrent, f *torrent.File, last Sample) Sample {
+	stats := t.Stats()
+	downloaded := stats.BytesReadUsefulData.Int64()
+	uploaded := stats.BytesWrittenData.Int64()
+	completed := f.BytesCompleted()
+	pct := 100.0
+	if f.Length() > 0 {
+		pct = float64(completed) / float64(f.Length()) * 100
+	}
+	s := Sample{
+		Bytes:       downloaded,
+		Progress:    pct,
+		CompletedMB: float64(completed) / (1024 * 1024),
+		DownloadMB:  float64(downloaded) / (1024 * 1024),
+		SpeedKBs:    float64(downloaded-last.Bytes) / 1024, // KB/s
+		Peers:       stats.ActivePeers,
+
+		Uploaded:       uploaded,
+		UploadedMB:     float64(uploaded) / (1024 * 1024),
+		UploadSpeedKBs: float64(uploaded-last.Uploaded) / 1024,
+		Seeding:        t.Seeding(),
+	}
+	if downloaded > 0 {
+		s.Ratio = float64(uploaded) / float64(downloaded)
+	}
+	return s
+}
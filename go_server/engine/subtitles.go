@@ -0,0 +1,212 @@
+package engine
+
+import (
+	"bytes"
+	"path"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Subtitle text encodings. Subtitles in torrents come in whatever their
+// author's editor saved: UTF-8, UTF-16 from Windows tools, and a lot of
+// legacy code pages, which players show as mojibake unless told. DecodeText
+// turns them into UTF-8. Byte order marks are believed; UTF-16 without a
+// mark shows as NUL bytes in every other position; valid UTF-8 is UTF-8.
+// Anything else is a Windows code page: 1251 when the language tag says
+// Cyrillic or most letters are high bytes (as in Cyrillic text); 1250 when
+// the tag is Central European or the text's high bytes vote for it;
+// otherwise 1252, which is right for Western European languages and
+// harmless for plain ASCII. The vote counts every high byte the two code
+// pages read differently and one of them reads as a common letter: ą, ł,
+// ź, ť (rare symbols or unused in 1252) and, more weakly, ě, ř, ů for
+// 1250; à and å for 1252. Bytes both read as letters someone writes (œ
+// and ś, ¼ and Ľ, è and č) don't vote.
+
+// cyrillicLangs are the language tags ("Movie.ru.srt") of subtitles that
+// are written in Cyrillic.
+var cyrillicLangs = map[string]bool{
+	"ru": true, "rus": true, "russian": true, "uk": true, "ukr": true, "ukrainian": true,
+	"bg": true, "bul": true, "bulgarian": true, "sr": true, "srp": true, "serbian": true,
+	"mk": true, "mkd": true, "macedonian": true, "be": true, "bel": true, "belarusian": true,
+}
+
+// centralLangs are the tags of subtitles in Central European languages,
+// which Windows saved as 1250.
+var centralLangs = map[string]bool{
+	"cs": true, "cze": true, "ces": true, "czech": true, "pl": true, "pol": true, "polish": true,
+	"sk": true, "slo": true, "slk": true, "slovak": true, "sl": true, "slv": true, "slovenian": true,
+	"hu": true, "hun": true, "hungarian": true, "hr": true, "hrv": true, "croatian": true,
+	"bs": true, "bos": true, "bosnian": true, "ro": true, "rum": true, "ron": true, "romanian": true,
+	"sq": true, "alb": true, "sqi": true, "albanian": true,
+}
+
+// cp1250Votes are the high bytes' votes: positive for 1250, negative for
+// 1252.
+var cp1250Votes = [256]int8{
+	0xA5: 2, 0xB9: 2, 0xB3: 2, 0x8F: 2, 0x9F: 2, 0x8D: 2, 0x9D: 2, // Ą ą ł Ź ź Ť ť; ¥ ¹ ³ and unused in 1252
+	0xEC: 1, 0xF8: 1, 0xF9: 1, // ě ř ů; ì ø ù in 1252
+	0xE0: -2, 0xC0: -2, 0xE5: -2, 0xC5: -2, // à À å Å; ŕ Ŕ ĺ Ĺ in 1250
+}
+
+// cp1252High and cp1251High map the bytes from 0x80 up to where each code
+// page rejoins a simple rule: Latin-1 from 0xA0 for 1252, а–я order from
+// 0xC0 for 1251.
+var cp1252High = [32]rune{
+	0x20AC, 0xFFFD, 0x201A, 0x0192, 0x201E, 0x2026, 0x2020, 0x2021,
+	0x02C6, 0x2030, 0x0160, 0x2039, 0x0152, 0xFFFD, 0x017D, 0xFFFD,
+	0xFFFD, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014,
+	0x02DC, 0x2122, 0x0161, 0x203A, 0x0153, 0xFFFD, 0x017E, 0x0178,
+}
+
+var cp1251High = [64]rune{
+	0x0402, 0x0403, 0x201A, 0x0453, 0x201E, 0x2026, 0x2020, 0x2021,
+	0x20AC, 0x2030, 0x0409, 0x2039, 0x040A, 0x040C, 0x040B, 0x040F,
+	0x0452, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014,
+	0xFFFD, 0x2122, 0x0459, 0x203A, 0x045A, 0x045C, 0x045B, 0x045F,
+	0x00A0, 0x040E, 0x045E, 0x0408, 0x00A4, 0x0490, 0x00A6, 0x00A7,
+	0x0401, 0x00A9, 0x0404, 0x00AB, 0x00AC, 0x00AD, 0x00AE, 0x0407,
+	0x00B0, 0x00B1, 0x0406, 0x0456, 0x0491, 0x00B5, 0x00B6, 0x00B7,
+	0x0451, 0x2116, 0x0454, 0x00BB, 0x0458, 0x0405, 0x0455, 0x0457,
+}
+
+// cp1250High maps all of 1250's upper half, which keeps few Latin-1 places.
+var cp1250High = [128]rune{
+	0x20AC, 0xFFFD, 0x201A, 0xFFFD, 0x201E, 0x2026, 0x2020, 0x2021,
+	0xFFFD, 0x2030, 0x0160, 0x2039, 0x015A, 0x0164, 0x017D, 0x0179,
+	0xFFFD, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014,
+	0xFFFD, 0x2122, 0x0161, 0x203A, 0x015B, 0x0165, 0x017E, 0x017A,
+	0x00A0, 0x02C7, 0x02D8, 0x0141, 0x00A4, 0x0104, 0x00A6, 0x00A7,
+	0x00A8, 0x00A9, 0x015E, 0x00AB, 0x00AC, 0x00AD, 0x00AE, 0x017B,
+	0x00B0, 0x00B1, 0x02DB, 0x0142, 0x00B4, 0x00B5, 0x00B6, 0x00B7,
+	0x00B8, 0x0105, 0x015F, 0x00BB, 0x013D, 0x02DD, 0x013E, 0x017C,
+	0x0154, 0x00C1, 0x00C2, 0x0102, 0x00C4, 0x0139, 0x0106, 0x00C7,
+	0x010C, 0x00C9, 0x0118, 0x00CB, 0x011A, 0x00CD, 0x00CE, 0x010E,
+	0x0110, 0x0143, 0x0147, 0x00D3, 0x00D4, 0x0150, 0x00D6, 0x00D7,
+	0x0158, 0x016E, 0x00DA, 0x0170, 0x00DC, 0x00DD, 0x0162, 0x00DF,
+	0x0155, 0x00E1, 0x00E2, 0x0103, 0x00E4, 0x013A, 0x0107, 0x00E7,
+	0x010D, 0x00E9, 0x0119, 0x00EB, 0x011B, 0x00ED, 0x00EE, 0x010F,
+	0x0111, 0x0144, 0x0148, 0x00F3, 0x00F4, 0x0151, 0x00F6, 0x00F7,
+	0x0159, 0x016F, 0x00FA, 0x0171, 0x00FC, 0x00FD, 0x0163, 0x02D9,
+}
+
+// IsVobSub reports whether the subtitle at p (slash-separated) is the
+// bitmap half of a VobSub pair: a .sub with an .idx of the same name
+// among paths. Those are binary, not text.
+func IsVobSub(p string, paths []string) bool {
+	if !strings.EqualFold(path.Ext(p), ".sub") {
+		return false
+	}
+	idx := strings.ToLower(strings.TrimSuffix(p, path.Ext(p)) + ".idx")
+	for _, q := range paths {
+		if strings.ToLower(q) == idx {
+			return true
+		}
+	}
+	return false
+}
+
+// DecodeText converts subtitle text b to UTF-8 without a byte order mark
+// and names the encoding it was in. lang is the file's language tag, if
+// known.
+func DecodeText(b []byte, lang string) (string, string) {
+	switch {
+	case bytes.HasPrefix(b, []byte{0xEF, 0xBB, 0xBF}):
+		return string(bytes.ToValidUTF8(b[3:], []byte("\uFFFD"))), "utf-8"
+	case bytes.HasPrefix(b, []byte{0xFF, 0xFE}):
+		return decodeUTF16(b[2:], false), "utf-16le"
+	case bytes.HasPrefix(b, []byte{0xFE, 0xFF}):
+		return decodeUTF16(b[2:], true), "utf-16be"
+	}
+	if even, odd := nulRatio(b, 0), nulRatio(b, 1); odd > 0.3 && even < 0.05 {
+		return decodeUTF16(b, false), "utf-16le"
+	} else if even > 0.3 && odd < 0.05 {
+		return decodeUTF16(b, true), "utf-16be"
+	}
+	if utf8.Valid(b) {
+		return string(b), "utf-8"
+	}
+	lang = strings.ToLower(lang)
+	if cyrillicLangs[lang] || !centralLangs[lang] && looksCyrillic(b) { // a tag beats a guess
+		return decodeCodePage(b, func(c byte) rune {
+			if c >= 0xC0 {
+				return 0x0410 + rune(c-0xC0)
+			}
+			return cp1251High[c-0x80]
+		}), "windows-1251"
+	}
+	if centralLangs[lang] || looksCentralEuropean(b) {
+		return decodeCodePage(b, func(c byte) rune { return cp1250High[c-0x80] }), "windows-1250"
+	}
+	return decodeCodePage(b, func(c byte) rune {
+		if c >= 0xA0 {
+			return rune(c)
+		}
+		return cp1252High[c-0x80]
+	}), "windows-1252"
+}
+
+func decodeUTF16(b []byte, bigEndian bool) string {
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		if bigEndian {
+			u[i] = uint16(b[2*i])<<8 | uint16(b[2*i+1])
+		} else {
+			u[i] = uint16(b[2*i+1])<<8 | uint16(b[2*i])
+		}
+	}
+	return string(utf16.Decode(u))
+}
+
+// nulRatio is the share of NUL bytes at even (parity 0) or odd positions.
+func nulRatio(b []byte, parity int) float64 {
+	n, nul := 0, 0
+	for i := parity; i < len(b); i += 2 {
+		n++
+		if b[i] == 0 {
+			nul++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return float64(nul) / float64(n)
+}
+
+// looksCyrillic reports whether b's letters are mostly high bytes in the
+// range 1251 keeps its alphabet in. Accented Western text has a few high
+// bytes among many ASCII letters.
+func looksCyrillic(b []byte) bool {
+	ascii, high := 0, 0
+	for _, c := range b {
+		switch {
+		case c >= 0xC0:
+			high++
+		case c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			ascii++
+		}
+	}
+	return high > ascii
+}
+
+// looksCentralEuropean reports whether b's high bytes vote for 1250.
+func looksCentralEuropean(b []byte) bool {
+	score := 0
+	for _, c := range b {
+		score += int(cp1250Votes[c])
+	}
+	return score > 0
+}
+
+func decodeCodePage(b []byte, high func(byte) rune) string {
+	var sb strings.Builder
+	sb.Grow(len(b) + len(b)/2)
+	for _, c := range b {
+		if c < 0x80 {
+			sb.WriteByte(c)
+		} else {
+			sb.WriteRune(high(c))
+		}
+	}
+	return sb.String()
+}
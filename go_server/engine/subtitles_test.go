@@ -0,0 +1,91 @@
+package engine
+
+import (
+	"testing"
+	"unicode/utf16"
+)
+
+// encode writes s in a code page given its upper half; s must fit it.
+func encode(t *testing.T, s string, high func(byte) rune) []byte {
+	var out []byte
+next:
+	for _, r := range s {
+		if r < 0x80 {
+			out = append(out, byte(r))
+			continue
+		}
+		for c := 0x80; c < 0x100; c++ {
+			if high(byte(c)) == r {
+				out = append(out, byte(c))
+				continue next
+			}
+		}
+		t.Fatalf("%q has no byte for %q", s, r)
+	}
+	return out
+}
+
+func cp1250(c byte) rune { return cp1250High[c-0x80] }
+
+func cp1251(c byte) rune {
+	if c >= 0xC0 {
+		return 0x0410 + rune(c-0xC0)
+	}
+	return cp1251High[c-0x80]
+}
+
+func cp1252(c byte) rune {
+	if c >= 0xA0 {
+		return rune(c)
+	}
+	return cp1252High[c-0x80]
+}
+
+func utf16LE(s string) []byte {
+	var out []byte
+	for _, u := range utf16.Encode([]rune(s)) {
+		out = append(out, byte(u), byte(u>>8))
+	}
+	return out
+}
+
+func TestDecodeText(t *testing.T) {
+	const (
+		polish  = "Zażółć gęślą jaźń, łódź i źrebię."
+		czech   = "Příliš žluťoučký kůň úpěl ďábelské ódy."
+		russian = "Съешь же ещё этих мягких французских булок."
+		french  = "Le cœur a ses raisons ; à ¼ d'heure près, l'Œuvre est là."
+		danish  = "Hvad så, kære ven? Skål for den gode øl."
+		italian = "Perché non è più così, città mia?"
+	)
+	for _, tc := range []struct {
+		name, lang string
+		in         []byte
+		want, enc  string
+	}{
+		{"ascii", "", []byte("Hello\n"), "Hello\n", "utf-8"},
+		{"utf-8", "", []byte(polish), polish, "utf-8"},
+		{"utf-8 with a byte order mark", "", append([]byte{0xEF, 0xBB, 0xBF}, polish...), polish, "utf-8"},
+		{"utf-16le with a byte order mark", "", append([]byte{0xFF, 0xFE}, utf16LE(russian)...), russian, "utf-16le"},
+		{"utf-16le without one", "", utf16LE("Hello there, " + czech), "Hello there, " + czech, "utf-16le"},
+		{"1251 by its letters", "", encode(t, russian, cp1251), russian, "windows-1251"},
+		{"1251 by the tag", "ru", encode(t, "OK: да", cp1251), "OK: да", "windows-1251"},
+		{"1250 Polish by its letters", "", encode(t, polish, cp1250), polish, "windows-1250"},
+		{"1250 Czech by its letters", "", encode(t, czech, cp1250), czech, "windows-1250"},
+		{"1250 by the tag", "hu", encode(t, "Ő és ű", cp1250), "Ő és ű", "windows-1250"},
+		{"1252 French with œ and ¼", "", encode(t, french, cp1252), french, "windows-1252"},
+		{"1252 Danish", "", encode(t, danish, cp1252), danish, "windows-1252"},
+		{"1252 Italian", "", encode(t, italian, cp1252), italian, "windows-1252"},
+		{"1252 by default", "", encode(t, "Café", cp1252), "Café", "windows-1252"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, enc := DecodeText(tc.in, tc.lang)
+			if enc != tc.enc {
+				t.Errorf("encoding %s, want %s", enc, tc.enc)
+			}
+			if got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
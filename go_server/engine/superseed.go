@@ -0,0 +1,119 @@
+package engine
+
+import (
+	"time"
+
+	"github.com/anacrolix/torrent"
+)
+
+// Super-seeding (BEP 16). An initial seeder in super-seed mode hides that
+// it has everything: each peer is shown one piece at a time, and the next
+// only once that piece has turned up elsewhere in the swarm. Such a peer
+// looks like a leecher that always holds a piece or two we lack and sends
+// them as soon as it shows them, which is how SeedWatch spots it. Waiting
+// for playback-window pieces it will never offer fights the scheme: with a
+// narrow window (data saver, trickle) we aren't even interested in the
+// piece it offers, and the fill governor holds it back like any fill, so
+// the seeder has no reason to show the next. SeedWatch raises the offered
+// pieces to high priority, below the playback window but above fill, so
+// the seeder can move on.
+
+const (
+	superSeedLacking = 2                // pieces we lack that it may show at once
+	superSeedAfter   = 30 * time.Second // of sending while showing that little
+	superSeedReveals = 2                // pieces it must have shown meanwhile
+)
+
+// SwarmShape is what SeedWatch makes of the connected peers.
+type SwarmShape struct {
+	Seeds        int  // peers holding every piece
+	SuperSeeders int  // peers that look like seeders in super-seed mode
+	SingleSource bool // one seed or super-seeder, and nobody else has a piece we lack
+}
+
+// SeedWatch follows t's peers from one Observe to the next.
+type SeedWatch struct {
+	t     *torrent.Torrent
+	peers map[*torrent.PeerConn]*seedObs
+}
+
+type seedObs struct {
+	since   time.Time // sending while showing few pieces we lack, since
+	shown   uint64    // pieces it claimed then
+	flagged bool
+}
+
+// NewSeedWatch starts watching t's peers.
+func NewSeedWatch(t *torrent.Torrent) *SeedWatch {
+	return &SeedWatch{t: t, peers: map[*torrent.PeerConn]*seedObs{}}
+}
+
+// Observe looks at the peers once and takes what super-seeders offer. Call
+// it every few seconds; it does nothing before the metadata.
+func (w *SeedWatch) Observe() SwarmShape {
+	var shape SwarmShape
+	t := w.t
+	if t.Info() == nil {
+		return shape
+	}
+	n := t.NumPieces()
+	have := make([]bool, n)
+	for i := range have {
+		have[i] = t.PieceState(i).Complete
+	}
+	now := time.Now()
+	conns := t.PeerConns()
+	seen := make(map[*torrent.PeerConn]bool, len(conns))
+	sources, others := 0, 0
+	var offered []int
+	for _, pc := range conns {
+		seen[pc] = true
+		pieces := pc.PeerPieces()
+		count := pieces.GetCardinality()
+		var lacking []int
+		pieces.Iterate(func(i uint32) bool {
+			if int(i) < n && !have[i] {
+				lacking = append(lacking, int(i))
+			}
+			return true
+		})
+		if count >= uint64(n) {
+			shape.Seeds++
+			sources++
+			delete(w.peers, pc)
+			continue
+		}
+		o := w.peers[pc]
+		switch {
+		case len(lacking) > superSeedLacking:
+			delete(w.peers, pc) // shows more than a super-seeder would
+		case pc.DownloadRate() > 0:
+			if o == nil {
+				o = &seedObs{since: now, shown: count}
+				w.peers[pc] = o
+			}
+			if !o.flagged && now.Sub(o.since) >= superSeedAfter && count >= o.shown+superSeedReveals {
+				o.flagged = true
+			}
+		}
+		if o = w.peers[pc]; o != nil && o.flagged {
+			shape.SuperSeeders++
+			sources++
+			offered = append(offered, lacking...)
+		} else if len(lacking) > 0 {
+			others++
+		}
+	}
+	for pc := range w.peers {
+		if !seen[pc] {
+			delete(w.peers, pc)
+		}
+	}
+	shape.SingleSource = sources == 1 && others == 0 && !t.Complete.Bool()
+	for _, i := range offered {
+		if p := t.Piece(i); p.State().Priority < torrent.PiecePriorityHigh {
+			p.SetPriority(torrent.PiecePriorityHigh)
+		}
+	}
+	return shape
+}
@@ -0,0 +1,101 @@
+package engine
+
+import (
+	"regexp"
+	"strings"
+)
+
+// SubRip to WebVTT. Players built on web technology (media_kit on the web,
+// HTML video) only take WebVTT, and most subtitles in torrents are SubRip.
+// The two differ in little: a header, a dot instead of a comma before the
+// milliseconds, and no <font> tags. Timings are rewritten in full
+// (hh:mm:ss.mmm) whatever shortcuts the SubRip took (one-digit hours, a dot
+// for the comma, missing milliseconds); SubRip's X1…Y2 coordinates and the
+// {\an8} style tags some editors leave are dropped.
+//
+// SubRip files are read line by line rather than trusted to separate their
+// cues with exactly one empty line: any line ends (CRLF, a doubled CR from
+// a botched conversion, a lone CR), separator lines holding spaces, and
+// cues with no separator at all (a timing line starts a cue, taking the
+// number on the line before it) all turn up in the wild. Text before the
+// first timing line is dropped.
+
+var (
+	srtTimingRe  = regexp.MustCompile(`^\s*(\d+):(\d{1,2}):(\d{1,2})(?:[,.:](\d{1,3}))?\s*-->\s*(\d+):(\d{1,2}):(\d{1,2})(?:[,.:](\d{1,3}))?`)
+	srtTagRe     = regexp.MustCompile(`(?i)</?font[^>]*>|\{\\[^}]*\}`)
+	srtNewlineRe = regexp.MustCompile(`\r+\n|\r`)
+	srtNumberRe  = regexp.MustCompile(`^\d+$`)
+)
+
+// srtCue is a SubRip cue on its way to WebVTT.
+type srtCue struct {
+	id     string
+	timing []string // srtTimingRe's submatches
+	text   []string
+}
+
+// SRTToVTT converts SubRip text (already UTF-8, see DecodeText) to WebVTT.
+func SRTToVTT(srt string) string {
+	srt = strings.TrimPrefix(srt, "\uFEFF")
+	srt = srtNewlineRe.ReplaceAllString(srt, "\n")
+	var cues []*srtCue
+	var cur *srtCue
+	before := "" // the last line outside a cue: its number, if a timing follows
+	for _, l := range strings.Split(srt, "\n") {
+		trimmed := strings.TrimSpace(l)
+		switch {
+		case trimmed == "":
+			cur, before = nil, ""
+		case srtTimingRe.MatchString(l):
+			id := before
+			if strings.Contains(id, "-->") {
+				id = "" // not allowed in an identifier
+			}
+			if cur != nil && len(cur.text) > 0 && srtNumberRe.MatchString(strings.TrimSpace(cur.text[len(cur.text)-1])) {
+				id = strings.TrimSpace(cur.text[len(cur.text)-1]) // no empty line before this cue
+				cur.text = cur.text[:len(cur.text)-1]
+			}
+			cur, before = &srtCue{id: id, timing: srtTimingRe.FindStringSubmatch(l)}, ""
+			cues = append(cues, cur)
+		case cur != nil:
+			cur.text = append(cur.text, l)
+		default:
+			before = trimmed
+		}
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(srt) + len(srt)/16)
+	sb.WriteString("WEBVTT\n")
+	for _, c := range cues {
+		m := c.timing
+		sb.WriteString("\n")
+		if c.id != "" {
+			sb.WriteString(c.id + "\n") // the number as the cue's identifier
+		}
+		sb.WriteString(vttTime(m[1], m[2], m[3], m[4]) + " --> " + vttTime(m[5], m[6], m[7], m[8]) + "\n")
+		for _, l := range c.text {
+			l = srtTagRe.ReplaceAllString(l, "")
+			l = strings.ReplaceAll(l, "-->", "->") // would end the cue text
+			if strings.TrimSpace(l) == "" {
+				continue // a blank line would end the cue
+			}
+			sb.WriteString(l + "\n")
+		}
+	}
+	return sb.String()
+}
+
+func vttTime(h, m, s, ms string) string {
+	pad := func(v string, n int) string {
+		for len(v) < n {
+			v = "0" + v
+		}
+		return v
+	}
+	// "5" after the separator is half a second, as a decimal fraction.
+	for len(ms) < 3 {
+		ms += "0"
+	}
+	return pad(h, 2) + ":" + pad(m, 2) + ":" + pad(s, 2) + "." + ms
+}
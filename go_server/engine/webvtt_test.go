@@ -0,0 +1,50 @@
+package engine
+
+import "testing"
+
+func TestSRTToVTT(t *testing.T) {
+	for _, tc := range []struct {
+		name, srt, want string
+	}{
+		{"plain",
+			"1\n00:00:01,000 --> 00:00:02,500\nHello\n\n2\n00:00:03,000 --> 00:00:04,000\nWorld\n",
+			"WEBVTT\n\n1\n00:00:01.000 --> 00:00:02.500\nHello\n\n2\n00:00:03.000 --> 00:00:04.000\nWorld\n"},
+		{"CRLF and a byte order mark",
+			"\uFEFF1\r\n00:00:01,000 --> 00:00:02,000\r\nHello\r\nthere\r\n\r\n2\r\n00:00:03,000 --> 00:00:04,000\r\nWorld\r\n",
+			"WEBVTT\n\n1\n00:00:01.000 --> 00:00:02.000\nHello\nthere\n\n2\n00:00:03.000 --> 00:00:04.000\nWorld\n"},
+		{"doubled CR",
+			"1\r\r\n00:00:01,000 --> 00:00:02,000\r\r\nHello\r\r\nthere\r\r\n\r\r\n",
+			"WEBVTT\n\n1\n00:00:01.000 --> 00:00:02.000\nHello\nthere\n"},
+		{"lone CR",
+			"1\r00:00:01,000 --> 00:00:02,000\rHello\r\r2\r00:00:03,000 --> 00:00:04,000\rWorld",
+			"WEBVTT\n\n1\n00:00:01.000 --> 00:00:02.000\nHello\n\n2\n00:00:03.000 --> 00:00:04.000\nWorld\n"},
+		{"separator with spaces",
+			"1\n00:00:01,000 --> 00:00:02,000\nHello\n \t\n2\n00:00:03,000 --> 00:00:04,000\nWorld\n",
+			"WEBVTT\n\n1\n00:00:01.000 --> 00:00:02.000\nHello\n\n2\n00:00:03.000 --> 00:00:04.000\nWorld\n"},
+		{"no separator",
+			"1\n00:00:01,000 --> 00:00:02,000\nHello\n2\n00:00:03,000 --> 00:00:04,000\nWorld\n",
+			"WEBVTT\n\n1\n00:00:01.000 --> 00:00:02.000\nHello\n\n2\n00:00:03.000 --> 00:00:04.000\nWorld\n"},
+		{"extra empty lines",
+			"\n\n1\n00:00:01,000 --> 00:00:02,000\nHello\n\n\n\n2\n00:00:03,000 --> 00:00:04,000\nWorld\n\n\n",
+			"WEBVTT\n\n1\n00:00:01.000 --> 00:00:02.000\nHello\n\n2\n00:00:03.000 --> 00:00:04.000\nWorld\n"},
+		{"no numbers",
+			"00:00:01,000 --> 00:00:02,000\nHello\n\n00:00:03,000 --> 00:00:04,000\nWorld\n",
+			"WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nHello\n\n00:00:03.000 --> 00:00:04.000\nWorld\n"},
+		{"loose timings",
+			"1\n0:00:01.5 --> 0:00:02:25 X1:10 X2:20 Y1:30 Y2:40\nHello\n",
+			"WEBVTT\n\n1\n00:00:01.500 --> 00:00:02.250\nHello\n"},
+		{"tags and arrows",
+			"1\n00:00:01,000 --> 00:00:02,000\n{\\an8}<font color=\"#ff0000\">Red</font> <i>it</i>\nA --> B\n",
+			"WEBVTT\n\n1\n00:00:01.000 --> 00:00:02.000\nRed <i>it</i>\nA -> B\n"},
+		{"text before the first cue",
+			"garbage\nmore\n\n1\n00:00:01,000 --> 00:00:02,000\nHello\n",
+			"WEBVTT\n\n1\n00:00:01.000 --> 00:00:02.000\nHello\n"},
+		{"empty", "", "WEBVTT\n"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := SRTToVTT(tc.srt); got != tc.want {
+				t.Errorf("got\n%q\nwant\n%q", got, tc.want)
+			}
+		})
+	}
+}
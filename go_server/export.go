@@ -0,0 +1,306 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/anacrolix/torrent"
+
+	"github.com/roxbox/torrent_server/engine"
+)
+
+// ── Export ────────────────────────────────────────────────────────────────────
+// Copies the active file to a destination path. Progress is journaled next
+// to the destination (<dest>.roxjournal) as the list of byte ranges already
+// on disk, so a crash or restart resumes exactly where the copy stopped.
+// Unfinished jobs are remembered in exports.json and pick up again as soon
+// as the same torrent file is active.
+
+const (
+	exportStateFile   = "exports.json"
+	exportJournalExt  = ".roxjournal"
+	exportChunk       = 1 << 20  // 1 MB per read
+	exportJournalStep = 16 << 20 // fsync + journal every 16 MB
+)
+
+type exportJournal struct {
+	InfoHash string     `json:"info_hash"`
+	File     string     `json:"file"`
+	Size     int64      `json:"size"`
+	Copied   byteRanges `json:"copied"`
+}
+
+type exportJob struct {
+	Profile  string `json:"profile"`
+	Dest     string `json:"dest"`
+	InfoHash string `json:"info_hash"`
+	File     string `json:"file"`
+	Size     int64  `json:"size"`
+	Copied   int64  `json:"copied"`
+	State    string `json:"state"` // "pending" | "running" | "done" | "error" | "canceled"
+	Error    string `json:"error,omitempty"`
+
+	cancel context.CancelFunc
+}
+
+var (
+	exportMu sync.Mutex
+	exports  = map[string]*exportJob{}
+)
+
+func loadExports() {
+	var pending []*exportJob
+	if err := loadJSON(exportStateFile, &pending); err != nil {
+		log.Printf("export: load state: %v", err)
+	}
+	exportMu.Lock()
+	for _, j := range pending {
+		if j.Profile == "" {
+			j.Profile = defaultProfile
+		}
+		j.State = "pending"
+		exports[j.Dest] = j
+	}
+	exportMu.Unlock()
+}
+
+// saveExports persists unfinished jobs. Caller holds exportMu.
+func saveExports() {
+	var pending []*exportJob
+	for _, j := range exports {
+		if j.State == "pending" || j.State == "running" {
+			pending = append(pending, j)
+		}
+	}
+	if err := saveJSON(exportStateFile, pending); err != nil {
+		log.Printf("export: save state: %v", err)
+	}
+}
+
+// resumeExports restarts pending jobs for the file that just became active.
+func resumeExports(t *torrent.Torrent, f *torrent.File) {
+	ih := t.InfoHash().HexString()
+	exportMu.Lock()
+	defer exportMu.Unlock()
+	for _, j := range exports {
+		if j.State == "pending" && j.InfoHash == ih && j.File == f.DisplayPath() {
+			log.Printf("export: resuming %s", j.Dest)
+			runExport(j, f)
+		}
+	}
+}
+
+// runExport starts the copy goroutine. Caller holds exportMu.
+func runExport(j *exportJob, f *torrent.File) {
+	ctx, cancel := context.WithCancel(context.Background())
+	j.cancel = cancel
+	j.State = "running"
+	j.Error = ""
+	saveExports()
+
+	go func() {
+		err := copyExport(ctx, j, f)
+		exportMu.Lock()
+		defer exportMu.Unlock()
+		switch {
+		case errors.Is(err, context.Canceled):
+			if j.State == "running" {
+				// Session stopped under us: resume when it comes back.
+				j.State = "pending"
+			}
+		case err != nil:
+			j.State, j.Error = "error", err.Error()
+			log.Printf("export: %s: %v", j.Dest, err)
+		default:
+			j.State = "done"
+			log.Printf("export: %s complete", j.Dest)
+		}
+		saveExports()
+	}()
+}
+
+func copyExport(ctx context.Context, j *exportJob, f *torrent.File) error {
+	journalPath := j.Dest + exportJournalExt
+	journal := exportJournal{InfoHash: j.InfoHash, File: j.File, Size: j.Size}
+	if b, err := os.ReadFile(journalPath); err == nil {
+		var prev exportJournal
+		if json.Unmarshal(b, &prev) == nil && prev.InfoHash == j.InfoHash &&
+			prev.File == j.File && prev.Size == j.Size {
+			journal.Copied = prev.Copied
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(j.Dest), 0755); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(j.Dest, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	if err := out.Truncate(j.Size); err != nil {
+		return err
+	}
+
+	writeJournal := func() error {
+		// Data first, then the journal that vouches for it.
+		if err := out.Sync(); err != nil {
+			return err
+		}
+		b, _ := json.Marshal(&journal)
+		tmp := journalPath + ".tmp"
+		if err := os.WriteFile(tmp, b, 0644); err != nil {
+			return err
+		}
+		return os.Rename(tmp, journalPath)
+	}
+
+	reader := f.NewReader()
+	defer reader.Close()
+	reader.SetReadahead(engine.DefaultReadahead)
+
+	buf := make([]byte, exportChunk)
+	var sinceJournal int64
+	for _, gap := range journal.Copied.Gaps(j.Size) {
+		if _, err := reader.Seek(gap.Start, io.SeekStart); err != nil {
+			return err
+		}
+		for pos := gap.Start; pos < gap.End; {
+			n := int64(len(buf))
+			if rem := gap.End - pos; rem < n {
+				n = rem
+			}
+			read, err := io.ReadFull(readerWithContext{ctx, reader}, buf[:n])
+			if read > 0 {
+				if _, werr := out.WriteAt(buf[:read], pos); werr != nil {
+					return werr
+				}
+				journal.Copied.Add(pos, pos+int64(read))
+				pos += int64(read)
+				sinceJournal += int64(read)
+
+				exportMu.Lock()
+				j.Copied = journal.Copied.Covered()
+				exportMu.Unlock()
+			}
+			if err != nil {
+				_ = writeJournal()
+				return err
+			}
+			if sinceJournal >= exportJournalStep {
+				if err := writeJournal(); err != nil {
+					return err
+				}
+				sinceJournal = 0
+			}
+		}
+	}
+	if err := out.Sync(); err != nil {
+		return err
+	}
+	return os.Remove(journalPath)
+}
+
+type readerWithContext struct {
+	ctx context.Context
+	r   torrent.Reader
+}
+
+func (r readerWithContext) Read(p []byte) (int, error) {
+	return r.r.ReadContext(r.ctx, p)
+}
+
+// cancelExports pauses running jobs reading from a torrent whose session
+// just stopped.
+func cancelExports(infoHash string) {
+	exportMu.Lock()
+	defer exportMu.Unlock()
+	for _, j := range exports {
+		if j.InfoHash == infoHash && j.cancel != nil {
+			j.cancel()
+		}
+	}
+}
+
+// ── GET|POST|DELETE /export ───────────────────────────────────────────────────
+//
+//	GET            → the profile's export jobs
+//	POST ?dest=    → export the active file to dest (resumes from its journal)
+//	DELETE ?dest=  → cancel; the journal is kept so a later POST resumes
+func handleExport(w http.ResponseWriter, r *http.Request) {
+	sess := sessionFor(w, r)
+	if sess == nil {
+		return
+	}
+	_ = r.ParseForm()
+	dest := r.FormValue("dest")
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		if dest == "" || !filepath.IsAbs(dest) {
+			http.Error(w, "absolute dest param required", 400)
+			return
+		}
+		t, f := sess.current()
+		if f == nil {
+			http.Error(w, "no active torrent", 503)
+			return
+		}
+		exportMu.Lock()
+		if j := exports[dest]; j != nil && j.State == "running" {
+			exportMu.Unlock()
+			http.Error(w, "export to this path already running", 409)
+			return
+		}
+		j := &exportJob{
+			Profile:  sess.profile.ID,
+			Dest:     dest,
+			InfoHash: t.InfoHash().HexString(),
+			File:     f.DisplayPath(),
+			Size:     f.Length(),
+		}
+		exports[dest] = j
+		runExport(j, f)
+		exportMu.Unlock()
+	case http.MethodDelete:
+		exportMu.Lock()
+		j := exports[dest]
+		if j == nil || j.Profile != sess.profile.ID {
+			exportMu.Unlock()
+			http.Error(w, "unknown export", 404)
+			return
+		}
+		j.State = "canceled"
+		if j.cancel != nil {
+			j.cancel()
+		}
+		saveExports()
+		exportMu.Unlock()
+		w.WriteHeader(200)
+		fmt.Fprint(w, "canceled")
+		return
+	default:
+		http.Error(w, "GET, POST or DELETE only", 405)
+		return
+	}
+
+	exportMu.Lock()
+	list := make([]exportJob, 0, len(exports))
+	for _, j := range exports {
+		if j.Profile == sess.profile.ID {
+			list = append(list, *j)
+		}
+	}
+	exportMu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(list)
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/anacrolix/torrent"
+
+	"github.com/roxbox/torrent_server/engine"
+)
+
+// ── Extra trackers ────────────────────────────────────────────────────────────
+// Trackers appended to every torrent the server adds, for magnets that
+// ship with a single dead one. They come from a list file (-trackers or
+// ROXBOX_TRACKERS_FILE; one URL per line as trackerslist publishes them,
+// # comments allowed; re-read on SIGHUP) and from the list the app sets
+// with PUT /trackers, kept in trackers.json.
+
+const extraTrackersFile = "trackers.json"
+
+var trackersFlag = flag.String("trackers", "", "file of tracker URLs to add to every torrent, one per line (env ROXBOX_TRACKERS_FILE)")
+
+var (
+	extraTrackersMu sync.Mutex
+	fileTrackers    []string // from the list file
+	configTrackers  []string // from PUT /trackers
+)
+
+type trackersConfig struct {
+	Trackers []string `json:"trackers"`
+}
+
+type trackersResponse struct {
+	Trackers []string `json:"trackers"`       // set with PUT
+	File     []string `json:"file,omitempty"` // from the list file
+	FilePath string   `json:"file_path,omitempty"`
+}
+
+func trackersFilePath() string {
+	if *trackersFlag != "" {
+		return *trackersFlag
+	}
+	return os.Getenv("ROXBOX_TRACKERS_FILE")
+}
+
+// loadExtraTrackers reads the list file and trackers.json.
+func loadExtraTrackers() {
+	var file []string
+	if path := trackersFilePath(); path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			log.Printf("trackers: %v", err)
+		} else {
+			file, err = readTrackerList(f)
+			f.Close()
+			if err != nil {
+				log.Printf("trackers: %s: %v", path, err)
+			}
+		}
+	}
+	var cfg trackersConfig
+	if err := loadJSON(extraTrackersFile, &cfg); err != nil {
+		log.Printf("trackers: load: %v", err)
+	}
+	extraTrackersMu.Lock()
+	fileTrackers, configTrackers = file, cfg.Trackers
+	extraTrackersMu.Unlock()
+	if n := len(file) + len(cfg.Trackers); n > 0 {
+		log.Printf("Extra trackers: %d", n)
+	}
+}
+
+// readTrackerList parses a list file, skipping blank lines, comments and
+// anything that isn't a tracker URL.
+func readTrackerList(r io.Reader) ([]string, error) {
+	var out []string
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if checkTrackerURL(line) == nil {
+			out = append(out, line)
+		}
+	}
+	return out, sc.Err()
+}
+
+func checkTrackerURL(s string) error {
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("%q: not a tracker URL", s)
+	}
+	switch u.Scheme {
+	case "udp", "http", "https", "ws", "wss":
+		return nil
+	}
+	return fmt.Errorf("%q: tracker scheme must be udp, http(s) or ws(s)", s)
+}
+
+// extraTrackerTiers is the combined list, one tier per tracker.
+func extraTrackerTiers() [][]string {
+	extraTrackersMu.Lock()
+	defer extraTrackersMu.Unlock()
+	seen := map[string]bool{}
+	var tiers [][]string
+	for _, tr := range append(append([]string{}, configTrackers...), fileTrackers...) {
+		if !seen[tr] {
+			seen[tr] = true
+			tiers = append(tiers, []string{tr})
+		}
+	}
+	return tiers
+}
+
+// addExtraTrackers gives t the extra trackers (addSpec). A private
+// torrent keeps to its own tracker.
+func addExtraTrackers(t *torrent.Torrent) {
+	if engine.IsPrivate(t) {
+		return
+	}
+	if tiers := extraTrackerTiers(); len(tiers) > 0 {
+		t.AddTrackers(tiers)
+	}
+}
+
+// ── GET | PUT /trackers ───────────────────────────────────────────────────────
+// PUT {"trackers": […]} replaces the configured list and adds it to the
+// torrents already running.
+func handleTrackers(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var cfg trackersConfig
+		if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&cfg); err != nil {
+			http.Error(w, "invalid JSON body: "+err.Error(), 400)
+			return
+		}
+		for _, tr := range cfg.Trackers {
+			if err := checkTrackerURL(tr); err != nil {
+				http.Error(w, err.Error(), 400)
+				return
+			}
+		}
+		if err := saveJSON(extraTrackersFile, cfg); err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		extraTrackersMu.Lock()
+		configTrackers = cfg.Trackers
+		extraTrackersMu.Unlock()
+		for _, t := range currentClient().Torrents() {
+			addExtraTrackers(t)
+		}
+	default:
+		http.Error(w, "GET or PUT only", 405)
+		return
+	}
+	extraTrackersMu.Lock()
+	resp := trackersResponse{Trackers: append([]string{}, configTrackers...), File: fileTrackers, FilePath: trackersFilePath()}
+	extraTrackersMu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
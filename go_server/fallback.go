@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/anacrolix/torrent"
+)
+
+// ── Fallback magnets ──────────────────────────────────────────────────────────
+// An /add can list alternative magnets for the same title (JSON fallbacks,
+// repeated fallback param), best first. While the session waits for
+// metadata, a source that has had no peers for fallbackNoPeers, or no
+// metadata after fallbackAfter, is dropped for the next one that adds. The
+// last source is kept however slow it is. /status reports the active source
+// under fallback, and every switch is an event.
+
+const (
+	maxFallbacks     = 10
+	fallbackAfter    = time.Minute      // no metadata
+	fallbackNoPeers  = 30 * time.Second // no peers connected
+	fallbackCheckGap = 5 * time.Second
+)
+
+// fallbackStatus is the fallback part of /status.
+type fallbackStatus struct {
+	Active  int      `json:"active"`           // 0: the add's own magnet, then fallbacks in order
+	Sources int      `json:"sources"`          // the magnet and its fallbacks
+	Failed  []string `json:"failed,omitempty"` // why earlier sources were dropped
+}
+
+// validateFallbacks checks an add's fallback magnets.
+func validateFallbacks(uris []string) error {
+	if len(uris) > maxFallbacks {
+		return fmt.Errorf("at most %d fallbacks", maxFallbacks)
+	}
+	for i, u := range uris {
+		if _, err := torrent.TorrentSpecFromMagnetUri(u); err != nil {
+			return fmt.Errorf("fallbacks[%d]: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// awaitInfo waits for t's metadata, failing over to cmd's fallbacks while
+// sources stall. It returns the torrent that got its metadata, or false
+// once cmd is superseded or the session stopped.
+func (s *session) awaitInfo(cmd *addCmd, t *torrent.Torrent) (*torrent.Torrent, bool) {
+	opts := cmd.opts
+	fb := &fallbackStatus{Sources: 1 + len(opts.Fallbacks)}
+	tried := 0 // fallbacks used up
+	if len(opts.Fallbacks) > 0 {
+		s.setFallback(fb)
+	}
+	tick := time.NewTicker(fallbackCheckGap)
+	defer tick.Stop()
+	since, lastPeer := time.Now(), time.Now()
+	for {
+		select {
+		case <-t.GotInfo():
+			return t, true
+		case <-cmd.cancel:
+			s.event(opts.RequestID, "superseded") // the next add or /stop releases t
+			return nil, false
+		case now := <-tick.C:
+			if tried == len(opts.Fallbacks) {
+				continue
+			}
+			if t.Stats().ActivePeers > 0 {
+				lastPeer = now
+			}
+			var reason string
+			switch {
+			case now.Sub(lastPeer) >= fallbackNoPeers:
+				reason = fmt.Sprintf("no peers for %v", fallbackNoPeers)
+			case now.Sub(since) >= fallbackAfter:
+				reason = fmt.Sprintf("no metadata after %v", fallbackAfter)
+			default:
+				continue
+			}
+			from := fb.Active
+			fb.Failed = append(fb.Failed, fmt.Sprintf("source %d: %s", from, reason))
+			var nt *torrent.Torrent
+			for nt == nil && tried < len(opts.Fallbacks) {
+				tried++
+				var err error
+				if nt, err = s.profile.addMagnet(opts.Fallbacks[tried-1]); err == nil && nt == t {
+					nt, err = nil, errors.New("same torrent as the stalled source")
+				}
+				if err != nil {
+					fb.Failed = append(fb.Failed, fmt.Sprintf("source %d: %v", tried, err))
+				}
+			}
+			if nt == nil {
+				s.event(opts.RequestID, fmt.Sprintf("source %d stalled (%s), no fallback could be added", from, reason))
+				s.setFallback(fb)
+				continue
+			}
+			fb.Active = tried
+			s.mu.Lock()
+			if s.torr != t { // stopped meanwhile
+				s.mu.Unlock()
+				releaseTorrent(nt, s)
+				return nil, false
+			}
+			s.torr = nt
+			s.status.InfoHash = nt.InfoHash().HexString()
+			s.mu.Unlock()
+			s.setFallback(fb)
+			releaseTorrent(t, s)
+			log.Printf("Fallback: source %d stalled (%s), now source %d", from, reason, fb.Active)
+			s.event(opts.RequestID, fmt.Sprintf("source %d stalled (%s), switched to source %d", from, reason, fb.Active))
+			t, since, lastPeer = nt, now, now
+			if opts.Paused {
+				s.pauseUntilRead(t) // player.go
+			}
+			seedCachedPeers(t)
+			go peerCacheLoop(t)
+		}
+	}
+}
+
+// setFallback publishes a copy of fb in the status.
+func (s *session) setFallback(fb *fallbackStatus) {
+	c := *fb
+	c.Failed = append([]string(nil), fb.Failed...)
+	s.mu.Lock()
+	s.status.Fallback = &c
+	s.touch()
+	s.mu.Unlock()
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/anacrolix/torrent"
+
+	"github.com/roxbox/torrent_server/engine"
+)
+
+// fileEntry is one file of the active torrent as listed by /files.
+type fileEntry struct {
+	Index    int     `json:"index"`
+	Path     string  `json:"path"`
+	Size     int64   `json:"size"`
+	Type     string  `json:"type"`           // from the extension (engine.FileType)
+	Progress float64 `json:"progress"`       // % of the file on disk
+	Role     string  `json:"role,omitempty"` // "video" (streamed) | "subtitle" | "audio" (its companions)
+	Lang     string  `json:"lang,omitempty"`
+	URL      string  `json:"url"`
+}
+
+type filesResponse struct {
+	Files []fileEntry `json:"files"`
+}
+
+// fileIndex is f's position in t.Files(), the index /files uses.
+func fileIndex(t *torrent.Torrent, f *torrent.File) int {
+	for i, g := range t.Files() {
+		if g == f {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *session) fileURL(i int) string {
+	q := url.Values{"index": {strconv.Itoa(i)}}
+	if s.profile.ID != defaultProfile {
+		q.Set("profile", s.profile.ID)
+	}
+	if s.hash != "" {
+		return "http://" + advertiseHost() + ":" + port + "/torrents/" + s.hash + "/files/raw?" + q.Encode()
+	}
+	return "http://" + advertiseHost() + ":" + port + "/files/raw?" + q.Encode()
+}
+
+func newFileEntry(s *session, t *torrent.Torrent, f *torrent.File, role, lang string) fileEntry {
+	e := fileEntry{
+		Index: fileIndex(t, f),
+		Path:  f.DisplayPath(),
+		Size:  f.Length(),
+		Type:  engine.FileType(f.DisplayPath()),
+		Role:  role,
+		Lang:  lang,
+	}
+	if f.Length() > 0 {
+		e.Progress = float64(f.BytesCompleted()) / float64(f.Length()) * 100
+	}
+	if role == "video" {
+		e.URL = s.streamURL()
+	} else {
+		e.URL = s.fileURL(e.Index)
+	}
+	return e
+}
+
+// ── GET /files ────────────────────────────────────────────────────────────────
+// Every file of the session's torrent in index order, so the app can show
+// what a multi-file torrent holds. The streamed video has role "video" and
+// the subtitle/audio files paired with it their kind. A local video lists
+// itself and its companions.
+func handleFiles(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "GET only", 405)
+		return
+	}
+	if sess := sessionFor(w, r); sess != nil {
+		writeFiles(w, sess)
+	}
+}
+
+type fileRole struct{ kind, lang string }
+
+// fileRoles marks the streamed file f and its companions.
+func fileRoles(f *torrent.File, comps []engine.Companion) map[*torrent.File]fileRole {
+	roles := map[*torrent.File]fileRole{}
+	if f != nil {
+		roles[f] = fileRole{kind: "video"}
+	}
+	for _, c := range comps {
+		roles[c.File] = fileRole{c.Kind, c.Lang}
+	}
+	return roles
+}
+
+// writeFiles answers /files for sess (/torrents/{hash}/files too).
+func writeFiles(w http.ResponseWriter, sess *session) {
+	sess.mu.RLock()
+	t, f, comps, local := sess.torr, sess.file, sess.companions, sess.local
+	sess.mu.RUnlock()
+	if local != nil {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(localFiles(sess, local))
+		return
+	}
+	if t == nil || t.Info() == nil {
+		http.Error(w, "no torrent info yet", 503)
+		return
+	}
+	roles := fileRoles(f, comps)
+	out := filesResponse{Files: []fileEntry{}}
+	for _, g := range t.Files() {
+		ro := roles[g]
+		out.Files = append(out.Files, newFileEntry(sess, t, g, ro.kind, ro.lang))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+// ── GET /files/raw?index=N ────────────────────────────────────────────────────
+// Serves a file of the active torrent, or a companion of a local video, by
+// index (Range requests supported). The streamed file goes through the
+// session's watermark, if it has one.
+func handleFileRaw(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "GET only", 405)
+		return
+	}
+	if sess := sessionFor(w, r); sess != nil {
+		serveFileRaw(w, r, sess)
+	}
+}
+
+// serveFileRaw answers /files/raw for sess (/torrents/{hash}/files/raw too).
+func serveFileRaw(w http.ResponseWriter, r *http.Request, sess *session) {
+	i, err := strconv.Atoi(r.URL.Query().Get("index"))
+	sess.mu.RLock()
+	local := sess.local
+	sess.mu.RUnlock()
+	if local != nil {
+		if err != nil || i < 0 || i >= len(local.Companions) {
+			http.Error(w, "index out of range", 404)
+			return
+		}
+		serveLocal(w, r, local.Companions[i].Path)
+		return
+	}
+	t, streamed := sess.current()
+	if t == nil || t.Info() == nil {
+		http.Error(w, "no active torrent", 503)
+		return
+	}
+	files := t.Files()
+	if err != nil || i < 0 || i >= len(files) {
+		http.Error(w, "index out of range", 404)
+		return
+	}
+	f := files[i]
+	if wm := sess.watermarkSpec(); wm != nil && f == streamed {
+		serveWatermarked(w, r, sess, f, engine.DefaultReadahead, wm)
+		return
+	}
+	reader := engine.NewReader(f, engine.DefaultReadahead)
+	defer reader.Close()
+	engine.ServeContent(w, r, f.DisplayPath(), reader)
+}
@@ -0,0 +1,107 @@
+//go:build !minimal && !no_geoip
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/roxbox/torrent_server/engine"
+)
+
+// ── GeoIP peer preference ─────────────────────────────────────────────────────
+// With -geoip pointing at a MaxMind-format database (GeoLite2 City or
+// Country, DB-IP Lite, …), peers on another continent get a shallow
+// request queue, so the playback window is fetched from near peers first
+// (engine/geoip.go). Our own location comes from the address peers see us
+// at, or from -geoip-home when that is wrong (a VPN, say).
+
+var (
+	geoipFlag     = flag.String("geoip", "", "MaxMind-format database (.mmdb) for preferring near peers (env ROXBOX_GEOIP)")
+	geoipHomeFlag = flag.String("geoip-home", "", `our location as "lat,lon", instead of looking up the address peers see (env ROXBOX_GEOIP_HOME)`)
+)
+
+var geoipErr error // why the database didn't load
+
+func init() {
+	registerModule(module{
+		Name:  "geoip",
+		Start: loadGeoIP,
+		Probe: func() capability {
+			switch {
+			case geoipPath() == "":
+				return capability{Compiled: true, Detail: "set -geoip to a MaxMind-format database"}
+			case geoipErr != nil:
+				return capability{Compiled: true, Detail: geoipErr.Error()}
+			}
+			detail := "home unknown until a peer reports our address"
+			if home, ok := engine.DefaultGeo.Home(); ok {
+				detail = geoHomeString(home)
+			}
+			return capability{Compiled: true, Enabled: true,
+				Detail: fmt.Sprintf("%s; %d far peers capped", detail, engine.DefaultGeo.Capped())}
+		},
+	})
+}
+
+func geoipPath() string {
+	if *geoipFlag != "" {
+		return *geoipFlag
+	}
+	return os.Getenv("ROXBOX_GEOIP")
+}
+
+func loadGeoIP() {
+	path := geoipPath()
+	if path == "" {
+		return
+	}
+	if geoipErr = engine.DefaultGeo.Load(path); geoipErr != nil {
+		log.Printf("geoip: %s: %v", path, geoipErr)
+		return
+	}
+	home := *geoipHomeFlag
+	if home == "" {
+		home = os.Getenv("ROXBOX_GEOIP_HOME")
+	}
+	if home != "" {
+		lat, lon, err := parseLatLon(home)
+		if err != nil {
+			log.Printf("geoip: home %q: %v", home, err)
+		} else {
+			engine.DefaultGeo.SetHome(lat, lon)
+		}
+	}
+	log.Printf("GeoIP: %s", path)
+}
+
+func parseLatLon(s string) (lat, lon float64, err error) {
+	a, b, ok := strings.Cut(s, ",")
+	if !ok {
+		return 0, 0, fmt.Errorf(`want "lat,lon"`)
+	}
+	if lat, err = strconv.ParseFloat(strings.TrimSpace(a), 64); err != nil || lat < -90 || lat > 90 {
+		return 0, 0, fmt.Errorf("bad latitude %q", a)
+	}
+	if lon, err = strconv.ParseFloat(strings.TrimSpace(b), 64); err != nil || lon < -180 || lon > 180 {
+		return 0, 0, fmt.Errorf("bad longitude %q", b)
+	}
+	return lat, lon, nil
+}
+
+func geoHomeString(l engine.Location) string {
+	parts := []string{}
+	if l.HasCoords {
+		parts = append(parts, fmt.Sprintf("%.2f,%.2f", l.Lat, l.Lon))
+	}
+	for _, s := range []string{l.Country, l.Continent} {
+		if s != "" {
+			parts = append(parts, s)
+		}
+	}
+	return "home " + strings.Join(parts, " ")
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/anacrolix/torrent"
+)
+
+// ── Playback handoff ──────────────────────────────────────────────────────────
+// A handoff bundle is everything another roxbox instance needs to continue
+// where this one stopped: what to stream, where the player was and a swarm
+// snapshot so it starts with warm peers.
+
+const handoffFormat = 1
+
+type HandoffBundle struct {
+	Format        int           `json:"format"`
+	ServerVersion string        `json:"server_version"`
+	Created       time.Time     `json:"created"`
+	Name          string        `json:"name"`
+	File          string        `json:"file"`
+	PlayerState   string        `json:"player_state,omitempty"`
+	PositionSec   float64       `json:"position_sec"`
+	Swarm         SwarmSnapshot `json:"swarm"`
+}
+
+// ── GET /handoff   → bundle for the active session
+// ── POST /handoff  ← bundle from another device; replaces the active session
+func handleHandoff(w http.ResponseWriter, r *http.Request) {
+	sess := sessionFor(w, r)
+	if sess == nil {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		sess.mu.RLock()
+		t, f, st := sess.torr, sess.file, sess.status
+		sess.mu.RUnlock()
+		if t == nil || f == nil {
+			http.Error(w, "no active torrent", 503)
+			return
+		}
+		b := HandoffBundle{
+			Format:        handoffFormat,
+			ServerVersion: version,
+			Created:       time.Now(),
+			Name:          t.Name(),
+			File:          f.DisplayPath(),
+			PlayerState:   st.PlayerState,
+			PositionSec:   st.PositionSec,
+			Swarm:         snapshotSwarm(t),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(b)
+
+	case http.MethodPost:
+		var b HandoffBundle
+		if err := json.NewDecoder(io.LimitReader(r.Body, 4<<20)).Decode(&b); err != nil {
+			http.Error(w, "invalid handoff bundle: "+err.Error(), 400)
+			return
+		}
+		if b.Format != handoffFormat {
+			http.Error(w, fmt.Sprintf("unsupported handoff format %d", b.Format), 400)
+			return
+		}
+		if b.Swarm.Magnet == "" {
+			http.Error(w, "handoff bundle has no magnet", 400)
+			return
+		}
+		snap := b.Swarm
+		id, ok := sess.start(addOptions{File: b.File, ResumeAt: b.PositionSec, RequestID: requestID(r)}, func() (*torrent.Torrent, error) {
+			t, err := sess.profile.addMagnet(snap.Magnet)
+			if err != nil {
+				return nil, fmt.Errorf("AddMagnet: %v", err)
+			}
+			importSwarm(t, &snap)
+			return t, nil
+		})
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"status":        addStatus(ok),
+			"add_id":        id,
+			"info_hash":     snap.InfoHash,
+			"resume_at_sec": b.PositionSec,
+		})
+
+	default:
+		http.Error(w, "GET or POST only", 405)
+	}
+}
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/anacrolix/torrent/tracker"
+
+	"github.com/roxbox/torrent_server/engine"
+)
+
+// ── Client self-healing ───────────────────────────────────────────────────────
+// The torrent client can wedge: sockets dead after a network change the OS
+// never reported, the DHT with no good nodes left, every torrent at zero
+// peers while its trackers still count seeders. With -self-heal (env
+// ROXBOX_SELF_HEAL; a duration such as 5m, off by default) a torrent that
+// is downloading with no peers for that long gets its trackers scraped,
+// and if they report a swarm, or if the DHT has had no good nodes for that
+// long too, the client is closed and built again. A dead DHT alone is no
+// reason: with every torrent fed, a rebuild only costs connections. Nor is
+// anything while a player is reading: closing the client would cut its
+// stream. Sessions are added back with the file they were streaming and
+// their known peers, background downloads carry on, and the event log gets
+// a "client" event. Rebuilds come out of an error budget of healBudget an
+// hour; when it's spent the client is left alone until the hour is up.
+
+const (
+	healCheckEvery = 30 * time.Second
+	healBudget     = 3 // rebuilds per healWindow
+	healWindow     = time.Hour
+	healScrapeMax  = 3 // trackers scraped per starved torrent
+	healScrapeWait = 15 * time.Second
+)
+
+var selfHealFlag = flag.Duration("self-heal", 0, "rebuild the torrent client when torrents have had no peers this long despite a live swarm or with the DHT down, and nothing is streaming; 0 (the default) turns it off (env ROXBOX_SELF_HEAL)")
+
+var (
+	clientMu sync.RWMutex // guards client across a rebuild
+
+	// newClientConfig is the torrent client's configuration, for the first
+	// client and every rebuild.
+	newClientConfig = func() *torrent.ClientConfig {
+		cfg := engine.NewClientConfig(cacheDir)
+		cfg.Logger = torrentLogger() // per-module levels, see loglevel.go
+		return cfg
+	}
+
+	heal struct {
+		sync.Mutex
+		after     time.Duration
+		dhtDead   time.Time                      // since when the DHT has had no good nodes
+		starved   map[*torrent.Torrent]time.Time // downloading torrents with no peers, since
+		rebuilds  []time.Time                    // within healWindow
+		exhausted bool                           // logged that the budget is spent
+	}
+)
+
+// currentClient is the torrent client, which a rebuild replaces.
+func currentClient() *torrent.Client {
+	clientMu.RLock()
+	defer clientMu.RUnlock()
+	return client
+}
+
+// startSelfHeal reads -self-heal and starts watching the client.
+func startSelfHeal() {
+	after, given := *selfHealFlag, false
+	flag.Visit(func(f *flag.Flag) { given = given || f.Name == "self-heal" })
+	if v := os.Getenv("ROXBOX_SELF_HEAL"); v != "" && !given {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			log.Printf("self-heal: ROXBOX_SELF_HEAL: %v", err)
+		} else {
+			after = d
+		}
+	}
+	if after <= 0 {
+		return
+	}
+	heal.after = after
+	heal.starved = map[*torrent.Torrent]time.Time{}
+	go healLoop()
+}
+
+func healLoop() {
+	defer guard()
+	tick := time.NewTicker(healCheckEvery)
+	defer tick.Stop()
+	for range tick.C {
+		reason := diagnoseClient()
+		if reason == "" || streaming() || !spendHealBudget(reason) {
+			continue
+		}
+		rebuildClient(reason)
+	}
+}
+
+// diagnoseClient says what is wrong with the client, or "".
+func diagnoseClient() string {
+	cl := currentClient()
+	now := time.Now()
+	heal.Lock()
+	after := heal.after
+	if dhtDead(cl) {
+		if heal.dhtDead.IsZero() {
+			heal.dhtDead = now
+		}
+	} else {
+		heal.dhtDead = time.Time{}
+	}
+	noDHT := !heal.dhtDead.IsZero() && now.Sub(heal.dhtDead) >= after
+	var due []*torrent.Torrent
+	wanting := wantingTorrents()
+	for t := range heal.starved {
+		if !wanting[t] {
+			delete(heal.starved, t)
+		}
+	}
+	for t := range wanting {
+		if t.Stats().ActivePeers > 0 {
+			delete(heal.starved, t)
+			continue
+		}
+		since, ok := heal.starved[t]
+		if !ok {
+			heal.starved[t] = now
+		} else if now.Sub(since) >= after {
+			due = append(due, t)
+		}
+	}
+	heal.Unlock()
+
+	// Only a swarm the trackers still see, or a DHT that went down, makes
+	// zero peers our fault.
+	if len(due) > 0 && noDHT {
+		return fmt.Sprintf("%s had no peers for %v and the DHT no good nodes", due[0].Name(), after)
+	}
+	for _, t := range due {
+		n := scrapeSwarm(t)
+		heal.Lock()
+		if n == 0 {
+			heal.starved[t] = time.Now() // look again after another spell
+		}
+		heal.Unlock()
+		if n > 0 {
+			return fmt.Sprintf("%s had no peers for %v while its trackers count %d", t.Name(), after, n)
+		}
+	}
+	return ""
+}
+
+// streaming reports whether a player is reading from any session.
+func streaming() bool {
+	profilesMu.Lock()
+	var all []*session
+	for _, p := range profiles {
+		all = append(all, p.sessions()...)
+	}
+	profilesMu.Unlock()
+	for _, s := range all {
+		s.readersMu.Lock()
+		n := len(s.readers)
+		s.readersMu.Unlock()
+		if n > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// dhtDead reports whether cl runs a DHT and none of its servers has a good
+// node.
+func dhtDead(cl *torrent.Client) bool {
+	servers := cl.DhtServers()
+	if len(servers) == 0 {
+		return false
+	}
+	for _, s := range servers {
+		w, ok := s.(torrent.AnacrolixDhtServerWrapper)
+		if !ok || w.Server.Stats().GoodNodes > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// wantingTorrents are the torrents that should have peers: sessions
+// downloading or fetching metadata under the global limits, and background
+// downloads still missing data.
+func wantingTorrents() map[*torrent.Torrent]bool {
+	out := map[*torrent.Torrent]bool{}
+	for _, e := range rankSessions() {
+		if e.state == "downloading" || e.state == "metadata" {
+			out[e.t] = true
+		}
+	}
+	downloadsMu.Lock()
+	for t := range downloads {
+		if t.Info() == nil || t.BytesMissing() > 0 {
+			out[t] = true
+		}
+	}
+	downloadsMu.Unlock()
+	return out
+}
+
+// scrapeSwarm asks t's first trackers how many peers its swarm has.
+func scrapeSwarm(t *torrent.Torrent) int {
+	ih := t.InfoHash()
+	n, asked := 0, 0
+	mi := t.Metainfo()
+	for _, tier := range mi.UpvertedAnnounceList() {
+		for _, u := range tier {
+			if asked == healScrapeMax {
+				return n
+			}
+			asked++
+			cl, err := tracker.NewClient(u, tracker.NewClientOpts{})
+			if err != nil {
+				continue
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), healScrapeWait)
+			res, err := cl.Scrape(ctx, []metainfo.Hash{ih})
+			cancel()
+			cl.Close()
+			if err == nil && len(res) > 0 {
+				n = max(n, int(res[0].Seeders+res[0].Leechers))
+			}
+		}
+	}
+	return n
+}
+
+// spendHealBudget takes a rebuild from the budget; false when it's spent.
+func spendHealBudget(reason string) bool {
+	heal.Lock()
+	defer heal.Unlock()
+	now := time.Now()
+	kept := heal.rebuilds[:0]
+	for _, at := range heal.rebuilds {
+		if now.Sub(at) < healWindow {
+			kept = append(kept, at)
+		}
+	}
+	heal.rebuilds = kept
+	if len(heal.rebuilds) >= healBudget {
+		if !heal.exhausted {
+			heal.exhausted = true
+			log.Printf("self-heal: %s, but %d rebuilds in the last hour is the budget", reason, healBudget)
+			recordEvent(event{Kind: "client", Message: "self-heal budget spent: " + reason})
+		}
+		return false
+	}
+	heal.exhausted = false
+	heal.rebuilds = append(heal.rebuilds, now)
+	return true
+}
+
+// readd is what a rebuild needs to bring a torrent back.
+type readd struct {
+	spec  *torrent.TorrentSpec
+	peers []torrent.PeerInfo
+}
+
+func readdOf(t *torrent.Torrent) readd {
+	spec := &torrent.TorrentSpec{InfoHash: t.InfoHash(), DisplayName: t.Name()}
+	if mi := t.Metainfo(); t.Info() != nil {
+		if s, err := torrent.TorrentSpecFromMetaInfoErr(&mi); err == nil {
+			spec = s
+		}
+	} else {
+		spec.Trackers = mi.UpvertedAnnounceList()
+	}
+	return readd{spec: spec, peers: t.KnownSwarm()}
+}
+
+// rebuildClient closes the client, builds a new one and adds back every
+// session and background download.
+func rebuildClient(reason string) {
+	log.Printf("self-heal: %s: rebuilding the torrent client", reason)
+
+	type sessionReadd struct {
+		s    *session
+		opts addOptions
+		r    readd
+	}
+	var sessions []sessionReadd
+	profilesMu.Lock()
+	var all []*session
+	for _, p := range profiles {
+		all = append(all, p.sessions()...)
+	}
+	profilesMu.Unlock()
+	for _, s := range all {
+		s.mu.RLock()
+		t, f, pos := s.torr, s.file, s.status.PositionSec
+		s.mu.RUnlock()
+		s.addMu.Lock()
+		c := s.addLatest
+		s.addMu.Unlock()
+		if t == nil || c == nil {
+			continue
+		}
+		opts := c.opts
+		if f != nil {
+			opts.File, opts.FileIndex = f.DisplayPath(), nil
+		}
+		opts.ResumeAt, opts.Paused, opts.RequestID, opts.Fallbacks = pos, false, "", nil
+		sessions = append(sessions, sessionReadd{s, opts, readdOf(t)})
+	}
+	downloadsMu.Lock()
+	type downloadReadd struct {
+		onDone func(*torrent.Torrent)
+		r      readd
+	}
+	var dls []downloadReadd
+	for t, onDone := range downloads {
+		dls = append(dls, downloadReadd{onDone, readdOf(t)})
+	}
+	downloadsMu.Unlock()
+
+	clientMu.Lock()
+	client.Close()
+	for {
+		cl, err := torrent.NewClient(newClientConfig())
+		if err == nil {
+			client = cl
+			break
+		}
+		log.Printf("self-heal: new client: %v", err) // the old listeners close in the background
+		time.Sleep(time.Second)
+	}
+	clientMu.Unlock()
+
+	heal.Lock()
+	heal.dhtDead = time.Time{}
+	clear(heal.starved)
+	heal.Unlock()
+
+	for _, d := range dls {
+		t, err := addSpec(d.r.spec)
+		if err != nil {
+			log.Printf("self-heal: %s: %v", d.r.spec.DisplayName, err)
+			continue
+		}
+		t.AddPeers(d.r.peers)
+		runDownload(t, d.onDone)
+	}
+	for _, sr := range sessions {
+		sr := sr
+		go sr.s.start(sr.opts, func() (*torrent.Torrent, error) {
+			t, err := sr.s.profile.addSpec(sr.r.spec)
+			if err != nil {
+				return nil, err
+			}
+			t.AddPeers(sr.r.peers)
+			return t, nil
+		})
+		sr.s.event("", "recovered: the torrent client was rebuilt ("+reason+")")
+	}
+	recordEvent(event{Kind: "client", Message: "torrent client rebuilt: " + reason,
+		Data: map[string]any{"sessions": len(sessions), "downloads": len(dls)}})
+}
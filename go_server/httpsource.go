@@ -0,0 +1,423 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/anacrolix/torrent/metainfo"
+
+	"github.com/roxbox/torrent_server/engine"
+)
+
+// ── HTTP sources ──────────────────────────────────────────────────────────────
+// /add?url= takes a direct http(s) link to a video (a debrid service's, say)
+// as well as one to a .torrent. A video link becomes the session's source in
+// place of a torrent: /stream serves it with Range support and /status reports
+// it like a download. The file is fetched in blocks into a cache file in the
+// profile dir, starting wherever the player reads and then filling the rest,
+// so seeks and re-reads are served locally; the cache goes with the session.
+// Servers that don't do Range requests are fetched front to back.
+
+const (
+	httpBlock   = 1 << 20 // cache granularity
+	httpRun     = 32      // blocks per Range request
+	httpRetries = 5
+)
+
+// sourceClient has no overall timeout: a block run can take a while.
+var sourceClient = &http.Client{Transport: &http.Transport{
+	Proxy:                 http.ProxyFromEnvironment,
+	ResponseHeaderTimeout: 30 * time.Second,
+}}
+
+type httpSource struct {
+	URL         string
+	Name        string
+	ContentType string
+	Size        int64
+	ranges      bool // the server answers Range requests
+	auth        fetchAuth
+
+	InfoHash string // the torrent a debrid copy stands in for
+	Provider string // which service, for debrid copies
+
+	path    string // cache file
+	file    *os.File
+	ctx     context.Context
+	cancel  context.CancelFunc
+	fetched atomic.Int64 // bytes downloaded
+
+	mu      sync.Mutex
+	have    []bool
+	done    int           // blocks in have
+	want    int           // block the latest read is waiting for
+	changed chan struct{} // closed when a block arrives or the fill fails
+	err     error         // the fill gave up
+}
+
+// fetchSource looks at what url serves: a .torrent comes back parsed, a
+// video as an httpSource that isn't open yet.
+func fetchSource(u string, auth fetchAuth) (*metainfo.MetaInfo, *httpSource, error) {
+	req, err := newFetchRequest(context.Background(), u, auth)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Range", "bytes=0-")
+	resp, err := fetchClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		return nil, nil, fmt.Errorf("fetch %s: %s", u, resp.Status)
+	}
+	ct, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	name := sourceName(resp)
+	media := strings.HasPrefix(ct, "video/") || strings.HasPrefix(ct, "audio/") || engine.IsVideo(name)
+	if !media {
+		br := bufio.NewReader(resp.Body)
+		if b, _ := br.Peek(1); ct != "application/x-bittorrent" && !strings.HasSuffix(name, ".torrent") && (len(b) == 0 || b[0] != 'd') {
+			return nil, nil, fmt.Errorf("%s is neither a .torrent nor a video (%s)", u, resp.Header.Get("Content-Type"))
+		}
+		mi, err := metainfo.Load(io.LimitReader(br, maxTorrentFileSize))
+		if err != nil {
+			return nil, nil, fmt.Errorf("parse %s: %w", u, err)
+		}
+		return mi, nil, nil
+	}
+
+	src := &httpSource{URL: u, Name: name, ContentType: resp.Header.Get("Content-Type"), Size: resp.ContentLength, auth: auth}
+	if resp.StatusCode == http.StatusPartialContent {
+		src.ranges = true
+		if _, total, ok := strings.Cut(resp.Header.Get("Content-Range"), "/"); ok {
+			src.Size, _ = strconv.ParseInt(total, 10, 64)
+		}
+	}
+	if src.Size <= 0 {
+		return nil, nil, fmt.Errorf("%s: the server doesn't say how big the file is", u)
+	}
+	return nil, src, nil
+}
+
+// sourceName is the file name from Content-Disposition or the URL path.
+func sourceName(resp *http.Response) string {
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+		return path.Base(params["filename"])
+	}
+	return path.Base(resp.Request.URL.Path)
+}
+
+// open creates the cache file in dir and starts filling it.
+func (s *httpSource) open(dir string) error {
+	sum := sha1.Sum([]byte(s.URL))
+	s.path = filepath.Join(dir, "http", hex.EncodeToString(sum[:8])+".part")
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if err := f.Truncate(s.Size); err != nil {
+		f.Close()
+		return err
+	}
+	s.file = f
+	s.have = make([]bool, (s.Size+httpBlock-1)/httpBlock)
+	s.changed = make(chan struct{})
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	go s.fill()
+	return nil
+}
+
+// close stops the fill and removes the cache file.
+func (s *httpSource) close() {
+	s.cancel()
+	s.file.Close()
+	os.Remove(s.path)
+}
+
+// notify wakes readers. Caller holds s.mu.
+func (s *httpSource) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// nextMissing is the first block to fetch: from the wanted one on, then from
+// the start; -1 when the cache is complete. Caller holds s.mu.
+func (s *httpSource) nextMissing() int {
+	from := s.want
+	if !s.ranges {
+		from = 0
+	}
+	for i := from; i < len(s.have); i++ {
+		if !s.have[i] {
+			return i
+		}
+	}
+	for i := 0; i < from; i++ {
+		if !s.have[i] {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *httpSource) fill() {
+	defer guard()
+	failures := 0
+	for s.ctx.Err() == nil {
+		s.mu.Lock()
+		b := s.nextMissing()
+		s.mu.Unlock()
+		if b < 0 {
+			return
+		}
+		err := s.fetch(b)
+		if err == nil || s.ctx.Err() != nil {
+			failures = 0
+			continue
+		}
+		if failures++; failures >= httpRetries {
+			log.Printf("http source %s: giving up: %v", s.Name, err)
+			s.mu.Lock()
+			s.err = err
+			s.notify()
+			s.mu.Unlock()
+			return
+		}
+		debugf("source", "fetch block %d: %v (retrying)", b, err)
+		select {
+		case <-time.After(time.Duration(failures) * 2 * time.Second):
+		case <-s.ctx.Done():
+		}
+	}
+}
+
+// fetch downloads a run of missing blocks from b on. It returns early,
+// without error, once a reader wants a block outside the run.
+func (s *httpSource) fetch(b int) error {
+	end := len(s.have)
+	if !s.ranges {
+		b = 0 // the whole body again
+	} else {
+		s.mu.Lock()
+		end = b
+		for end < len(s.have) && end < b+httpRun && !s.have[end] {
+			end++
+		}
+		s.mu.Unlock()
+	}
+	req, err := newFetchRequest(s.ctx, s.URL, s.auth)
+	if err != nil {
+		return err
+	}
+	if s.ranges {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", int64(b)*httpBlock, min(int64(end)*httpBlock, s.Size)-1))
+	}
+	resp, err := sourceClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	want := http.StatusOK
+	if s.ranges {
+		want = http.StatusPartialContent
+	}
+	if resp.StatusCode != want {
+		return fmt.Errorf("fetch: %s", resp.Status)
+	}
+
+	buf := make([]byte, httpBlock)
+	for i := b; i < end; i++ {
+		off := int64(i) * httpBlock
+		n := min(httpBlock, s.Size-off)
+		if _, err := io.ReadFull(resp.Body, buf[:n]); err != nil {
+			return err
+		}
+		s.fetched.Add(n)
+		if _, err := s.file.WriteAt(buf[:n], off); err != nil {
+			return err
+		}
+		s.mu.Lock()
+		if !s.have[i] {
+			s.have[i] = true
+			s.done++
+		}
+		s.notify()
+		jump := s.ranges && !s.have[s.want] && (s.want < i || s.want >= end)
+		s.mu.Unlock()
+		if jump { // a seek: start over where the reader is
+			return nil
+		}
+	}
+	return nil
+}
+
+// wait blocks until block b is cached.
+func (s *httpSource) wait(ctx context.Context, b int) error {
+	for {
+		s.mu.Lock()
+		if s.have[b] {
+			s.mu.Unlock()
+			return nil
+		}
+		if s.err != nil {
+			s.mu.Unlock()
+			return s.err
+		}
+		s.want = b
+		changed := s.changed
+		s.mu.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.ctx.Done():
+			return errors.New("source closed")
+		}
+	}
+}
+
+// progress is the cached share of the file in percent.
+func (s *httpSource) progress() (pct float64, completed int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	completed = min(int64(s.done)*httpBlock, s.Size)
+	return float64(completed) / float64(s.Size) * 100, completed
+}
+
+// sourceReader reads the source through its cache.
+type sourceReader struct {
+	src *httpSource
+	ctx context.Context
+	pos int64
+}
+
+func (r *sourceReader) Read(p []byte) (int, error) {
+	if r.pos >= r.src.Size {
+		return 0, io.EOF
+	}
+	b := int(r.pos / httpBlock)
+	if err := r.src.wait(r.ctx, b); err != nil {
+		return 0, err
+	}
+	n := min(int64(len(p)), int64(b+1)*httpBlock-r.pos, r.src.Size-r.pos)
+	m, err := r.src.file.ReadAt(p[:n], r.pos)
+	r.pos += int64(m)
+	return m, err
+}
+
+func (r *sourceReader) Seek(off int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		off += r.pos
+	case io.SeekEnd:
+		off += r.src.Size
+	}
+	if off < 0 {
+		return 0, errors.New("negative position")
+	}
+	r.pos = off
+	return off, nil
+}
+
+// addSource makes src the session's source, replacing whatever it had.
+func (s *session) addSource(w http.ResponseWriter, r *http.Request, src *httpSource) {
+	s.mu.RLock()
+	cur := s.http
+	s.mu.RUnlock()
+	if cur != nil && cur.URL == src.URL {
+		st := s.snapshotStatus()
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"status": "exists", "state": st.State, "stream_url": st.StreamURL})
+		return
+	}
+
+	s.supersede()
+	s.stop()
+	if err := src.open(s.profile.dir); err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	s.mu.Lock()
+	s.http = src
+	s.status = StatusResponse{State: "loading", StreamURL: s.streamURL()}
+	s.touch()
+	s.mu.Unlock()
+	s.active()
+	s.profile.appendHistory(historyEntry{Time: time.Now(), Name: src.Name, File: src.URL})
+	s.event(requestID(r), "source: "+src.Name)
+	go s.sourceLoop(src)
+
+	w.Header().Set("Content-Type", "application/json")
+	resp := map[string]any{"status": "loading", "stream_url": s.streamURL()}
+	if src.Provider != "" {
+		resp["debrid"] = src.Provider
+	}
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// sourceLoop is statsLoop for an HTTP source.
+func (s *session) sourceLoop(src *httpSource) {
+	defer guard()
+	var last int64
+	for {
+		time.Sleep(time.Second)
+		s.mu.RLock()
+		if s.http != src {
+			s.mu.RUnlock()
+			return
+		}
+		s.mu.RUnlock()
+
+		pct, completed := src.progress()
+		fetched := src.fetched.Load()
+		src.mu.Lock()
+		first, err := src.have[0], src.err
+		src.mu.Unlock()
+
+		s.mu.Lock()
+		st := &s.status
+		st.Progress = pct
+		st.CompletedMB = float64(completed) / (1 << 20)
+		st.DownloadMB = float64(fetched) / (1 << 20)
+		st.SpeedKBs = float64(fetched-last) / 1024
+		switch {
+		case err != nil:
+			st.State, st.Error = "error", err.Error()
+		case first:
+			st.State = "ready"
+		}
+		s.touch()
+		s.mu.Unlock()
+		last = fetched
+	}
+}
+
+// sourceURL checks a url= param.
+func sourceURL(u string) error {
+	p, err := url.Parse(u)
+	if err != nil || (p.Scheme != "http" && p.Scheme != "https") || p.Host == "" {
+		return errors.New("url must be an http(s) URL of a .torrent or a video file")
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ── Idempotency keys ──────────────────────────────────────────────────────────
+// Mobile clients retry control calls after a timeout without knowing whether
+// the first attempt ran. With an Idempotency-Key header the first response
+// to a key is remembered and replayed for retries (marked with
+// Idempotent-Replayed: true); a retry that arrives while the original is
+// still running waits for it. Reusing a key for a different request is a
+// 422. Keys are per profile and forgotten after idempotencyTTL. Only
+// finished 2xx and 4xx answers are remembered: after a 5xx, a redirect or
+// a panic the key is free for the retry (a retry already waiting gets a
+// 500).
+
+const (
+	idempotencyHeader  = "Idempotency-Key"
+	idempotencyTTL     = 24 * time.Hour
+	idempotencyMaxKey  = 255
+	idempotencyMaxBody = maxTorrentFileSize + maxAddBody
+)
+
+// idempotentPaths are the routes wrapped with withIdempotency (for the
+// OpenAPI document).
+var idempotentPaths = map[string]bool{"/add": true, "/stop": true, "/export": true}
+
+type idemEntry struct {
+	fingerprint [32]byte
+	done        chan struct{} // closed once the response below is set
+	status      int
+	header      http.Header
+	body        []byte
+	expires     time.Time
+}
+
+var (
+	idemMu      sync.Mutex
+	idemEntries = map[string]*idemEntry{}
+)
+
+// idemRecorder captures a response while passing it through.
+type idemRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *idemRecorder) WriteHeader(code int) {
+	if rec.status == 0 {
+		rec.status = code
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *idemRecorder) Write(p []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = 200
+	}
+	rec.body.Write(p)
+	return rec.ResponseWriter.Write(p)
+}
+
+// withIdempotency makes h's non-GET requests honour Idempotency-Key.
+func withIdempotency(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyHeader)
+		if key == "" || r.Method == http.MethodGet || r.Method == http.MethodHead {
+			h(w, r)
+			return
+		}
+		if len(key) > idempotencyMaxKey {
+			http.Error(w, "Idempotency-Key too long", 400)
+			return
+		}
+		p, err := profileFor(r)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		// As much as /add takes (addrequest.go): a .torrent and its form.
+		body, err := io.ReadAll(io.LimitReader(r.Body, idempotencyMaxBody+1))
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		if len(body) > idempotencyMaxBody {
+			http.Error(w, "request body too large", 413)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		hash := sha256.New()
+		io.WriteString(hash, r.Method+" "+r.URL.RequestURI()+"\n"+r.Header.Get("Content-Type")+"\n")
+		hash.Write(body)
+		var fp [32]byte
+		copy(fp[:], hash.Sum(nil))
+
+		id := p.ID + "\x00" + r.URL.Path + "\x00" + key
+		now := time.Now()
+		idemMu.Lock()
+		for k, e := range idemEntries {
+			if e.expires.Before(now) && isClosed(e.done) {
+				delete(idemEntries, k)
+			}
+		}
+		e, seen := idemEntries[id]
+		if !seen {
+			e = &idemEntry{fingerprint: fp, done: make(chan struct{}), expires: now.Add(idempotencyTTL)}
+			idemEntries[id] = e
+		}
+		idemMu.Unlock()
+
+		if seen {
+			if e.fingerprint != fp {
+				http.Error(w, "Idempotency-Key was used for a different request", 422)
+				return
+			}
+			select {
+			case <-e.done:
+			case <-r.Context().Done():
+				return
+			}
+			for k, v := range e.header {
+				w.Header()[k] = v
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(e.status)
+			_, _ = w.Write(e.body)
+			return
+		}
+
+		rec := &idemRecorder{ResponseWriter: w}
+		defer func() {
+			crash := recover()
+			if rec.status == 0 && crash == nil {
+				rec.status = 200 // what net/http sends for a handler that wrote nothing
+			}
+			e.status, e.header, e.body = rec.status, w.Header().Clone(), rec.body.Bytes()
+			if crash != nil || e.status < 200 || e.status >= 300 && e.status < 400 || e.status >= 500 {
+				// Not an answer to replay: the retry runs the request again.
+				idemMu.Lock()
+				delete(idemEntries, id)
+				idemMu.Unlock()
+				if crash != nil {
+					e.status, e.header, e.body = 500, http.Header{"Content-Type": {"text/plain; charset=utf-8"}}, []byte("the original request failed\n")
+				}
+			}
+			close(e.done)
+			if crash != nil {
+				panic(crash)
+			}
+		}()
+		h(rec, r)
+	}
+}
+
+func isClosed(ch chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"github.com/anacrolix/torrent"
+)
+
+// ── Idle sessions ─────────────────────────────────────────────────────────────
+// A session nobody reads from or polls is probably forgotten: the app was
+// killed, or a prefetch was never played. After the profile's idle pause
+// time its torrent stops downloading; after the drop time the session is
+// stopped altogether. Any stream read, status poll or player report counts
+// as activity and resumes a paused session.
+
+const (
+	defaultIdlePause = 15 * time.Minute
+	defaultIdleDrop  = time.Hour
+	idleCheckEvery   = 30 * time.Second
+)
+
+// idleTTLs returns the profile's idle limits; 0 means never.
+func (p *profile) idleTTLs() (pause, drop time.Duration) {
+	p.mu.Lock()
+	set := p.settings
+	p.mu.Unlock()
+	ttl := func(mins int, def time.Duration) time.Duration {
+		switch {
+		case mins < 0:
+			return 0
+		case mins == 0:
+			return def
+		}
+		return time.Duration(mins) * time.Minute
+	}
+	return ttl(set.IdlePauseMins, defaultIdlePause), ttl(set.IdleDropMins, defaultIdleDrop)
+}
+
+// active records activity on the session, resuming it if it was paused.
+func (s *session) active() {
+	s.lastActive.Store(time.Now().UnixNano())
+	s.playerMu.Lock()
+	paused, held := s.idlePaused, s.addPaused || s.limitHeld
+	s.idlePaused = false
+	s.playerMu.Unlock()
+	if !paused {
+		return
+	}
+	t, _ := s.current()
+	if t != nil && !held { // an add with paused waits for its first read, a queued one for a slot
+		t.AllowDataDownload()
+	}
+	kickLimits()
+	s.mu.Lock()
+	s.status.IdlePaused = false
+	s.touch()
+	s.mu.Unlock()
+	s.event("", "resumed after idle pause")
+}
+
+// checkIdle pauses or drops the session once it has been idle long enough.
+func (s *session) checkIdle(now time.Time) {
+	t, f := s.current()
+	if t == nil {
+		return
+	}
+	if s.hash != "" && (f == nil || f.BytesCompleted() < f.Length()) {
+		return // background downloads are unattended by design (torrents.go)
+	}
+	pause, drop := s.profile.idleTTLs()
+	idle := now.Sub(time.Unix(0, s.lastActive.Load()))
+	switch {
+	case drop > 0 && idle >= drop:
+		log.Printf("profile %s: idle for %s, dropping %s", s.profile.ID, idle.Round(time.Second), t.Name())
+		s.supersede()
+		s.stop()
+		s.profile.forgetBackground(s)
+		s.event("", "dropped after idle")
+	case pause > 0 && idle >= pause:
+		s.playerMu.Lock()
+		already := s.idlePaused || s.addPaused
+		s.playerMu.Unlock()
+		if already || torrentInUseElsewhere(t, s) {
+			return
+		}
+		s.playerMu.Lock()
+		s.idlePaused = true
+		s.playerMu.Unlock()
+		t.DisallowDataDownload()
+		s.mu.Lock()
+		s.status.IdlePaused = true
+		s.touch()
+		s.mu.Unlock()
+		log.Printf("profile %s: idle for %s, pausing %s", s.profile.ID, idle.Round(time.Second), t.Name())
+		s.event("", "paused while idle")
+	}
+}
+
+// idleLoop checks every profile's sessions for idleness.
+func idleLoop() {
+	defer guard()
+	for now := range time.Tick(idleCheckEvery) {
+		profilesMu.Lock()
+		var sessions []*session
+		for _, p := range profiles {
+			sessions = append(sessions, p.sessions()...)
+		}
+		profilesMu.Unlock()
+		for _, s := range sessions {
+			s.checkIdle(now)
+		}
+	}
+}
+
+// torrentInUseElsewhere reports whether another session, of this or another
+// profile, holds t (the client holds a single Torrent per infohash).
+func torrentInUseElsewhere(t *torrent.Torrent, from *session) bool {
+	profilesMu.Lock()
+	defer profilesMu.Unlock()
+	for _, p := range profiles {
+		for _, s := range p.sessions() {
+			if s == from {
+				continue
+			}
+			if other, _ := s.current(); other == t {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/roxbox/torrent_server/engine"
+)
+
+// InfoResponse describes the active torrent once metadata is known.
+type InfoResponse struct {
+	Name      string   `json:"name"`
+	InfoHash  string   `json:"info_hash"`
+	NumPieces int      `json:"num_pieces"`
+	File      string   `json:"file"`
+	FileSize  int64    `json:"file_size"`
+	Warnings  []string `json:"warnings,omitempty"`
+	// Selection says why File was picked; POST /select to override.
+	Selection engine.Selection `json:"selection"`
+	engine.PieceProfile
+}
+
+// ── GET /info ─────────────────────────────────────────────────────────────────
+func handleInfo(w http.ResponseWriter, r *http.Request) {
+	if sess := sessionFor(w, r); sess != nil {
+		writeInfo(w, sess)
+	}
+}
+
+// writeInfo writes sess's InfoResponse.
+func writeInfo(w http.ResponseWriter, sess *session) {
+	sess.mu.RLock()
+	t, f, prof, sel := sess.torr, sess.file, sess.pieces, sess.selection
+	sess.mu.RUnlock()
+
+	if t == nil || f == nil {
+		http.Error(w, "no torrent info yet", 503)
+		return
+	}
+	info := InfoResponse{
+		Name:         t.Name(),
+		InfoHash:     t.InfoHash().HexString(),
+		NumPieces:    t.NumPieces(),
+		File:         f.DisplayPath(),
+		FileSize:     f.Length(),
+		Selection:    sel,
+		PieceProfile: prof,
+	}
+	if prof.Warning != "" {
+		info.Warnings = append(info.Warnings, prof.Warning)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(info)
+}
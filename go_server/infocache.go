@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/hex"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+
+	"github.com/roxbox/torrent_server/engine"
+)
+
+// ── Info-dictionary cache ─────────────────────────────────────────────────────
+// Every torrent whose metadata we've seen (streams, background downloads,
+// RSS prefetches) leaves its bencoded info dict in cacheDir/infocache.
+// Re-adding a known infohash then skips the metadata exchange and piece
+// requests start straight away. Entries are checked against the infohash
+// before use and sealed like other state when a key is configured.
+
+const (
+	infoCacheDir = "infocache"
+	infoCacheMax = 300 // entries; oldest are pruned
+)
+
+// infoCacheRoot is the cache's directory. Background work takes it when it
+// starts rather than reading cacheDir later.
+func infoCacheRoot() string {
+	return filepath.Join(cacheDir, infoCacheDir)
+}
+
+func infoCachePath(dir string, ih metainfo.Hash) string {
+	return filepath.Join(dir, ih.HexString()+".info")
+}
+
+// cachedInfo returns the info bytes for ih, or nil.
+func cachedInfo(ih metainfo.Hash) []byte {
+	b, err := os.ReadFile(infoCachePath(infoCacheRoot(), ih))
+	if err != nil {
+		return nil
+	}
+	if isSealed(b) {
+		if b, err = unseal(b); err != nil {
+			return nil
+		}
+	}
+	if metainfo.HashBytes(b) != ih {
+		return nil // stale or corrupt
+	}
+	return b
+}
+
+// withCachedInfo fills in spec's info dict from the cache when missing.
+func withCachedInfo(spec *torrent.TorrentSpec) {
+	if len(spec.InfoBytes) > 0 {
+		return
+	}
+	if b := cachedInfo(spec.InfoHash); b != nil {
+		spec.InfoBytes = b
+		log.Printf("Metadata for %s from info cache", spec.InfoHash.HexString())
+	}
+}
+
+// rememberInfo stores t's info dict in dir once it's known.
+func rememberInfo(t *torrent.Torrent, dir string) {
+	select {
+	case <-t.GotInfo():
+	case <-t.Closed():
+		return
+	}
+	path := infoCachePath(dir, t.InfoHash())
+	if _, err := os.Stat(path); err == nil {
+		now := time.Now()
+		_ = os.Chtimes(path, now, now) // keep recently used entries
+		return
+	}
+	b := t.Metainfo().InfoBytes
+	if len(b) == 0 {
+		return
+	}
+	if sealKey != nil {
+		var err error
+		if b, err = seal(b); err != nil {
+			return
+		}
+	}
+	_ = os.MkdirAll(dir, 0700)
+	if err := os.WriteFile(path, b, 0600); err != nil {
+		log.Printf("info cache: %v", err)
+		return
+	}
+	pruneInfoCache(dir)
+}
+
+func pruneInfoCache(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) <= infoCacheMax {
+		return
+	}
+	type ent struct {
+		name string
+		mod  int64
+	}
+	var all []ent
+	for _, e := range entries {
+		name := e.Name()
+		if _, err := hex.DecodeString(name[:len(name)-len(filepath.Ext(name))]); err != nil {
+			continue
+		}
+		if fi, err := e.Info(); err == nil {
+			all = append(all, ent{name, fi.ModTime().UnixNano()})
+		}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].mod < all[j].mod })
+	for _, e := range all[:max(len(all)-infoCacheMax, 0)] {
+		_ = os.Remove(filepath.Join(dir, e.name))
+	}
+}
+
+// addSpec adds spec to the client, reusing a cached info dict, and caches
+// the metadata for next time.
+func addSpec(spec *torrent.TorrentSpec) (*torrent.Torrent, error) {
+	withCachedInfo(spec)
+	templatePasskeys(spec) // passkeys.go
+	cl := currentClient()
+	t, _, err := cl.AddTorrentSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+	go rememberInfo(t, infoCacheRoot())
+	engine.AnnouncePublic(cl, t) // DHT, unless private
+	addExtraTrackers(t)          // extratrackers.go
+	moduleAdded(t)
+	return t, nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/anacrolix/torrent"
+
+	"github.com/roxbox/torrent_server/engine"
+)
+
+// ── GET /inspect?magnet=… ─────────────────────────────────────────────────────
+// Fetches a magnet's metadata (from the info cache when we have it, else
+// from peers over ut_metadata) and lists its files without downloading any
+// data, so the app can show a file picker first. A torrent added just for
+// this is dropped again; one a session already has is left alone.
+
+const (
+	defaultInspectWait = 60 * time.Second
+	maxInspectWait     = 2 * time.Minute
+)
+
+type inspectFile struct {
+	Index int    `json:"index"` // as file_index on /add
+	Path  string `json:"path"`
+	Size  int64  `json:"size"`
+	Video bool   `json:"video"`
+}
+
+type inspectResponse struct {
+	InfoHash    string        `json:"info_hash"`
+	Name        string        `json:"name"`
+	TotalSize   int64         `json:"total_size"`
+	PieceLength int64         `json:"piece_length"`
+	NumPieces   int           `json:"num_pieces"`
+	Files       []inspectFile `json:"files"`
+	// Selected is the file /add would stream with the same hints.
+	Selected  int              `json:"selected"`
+	Selection engine.Selection `json:"selection"`
+	Source    string           `json:"source"` // "cache" | "swarm" | "session"
+}
+
+func handleInspect(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "GET only", 405)
+		return
+	}
+	p, err := profileFor(r)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	q := r.URL.Query()
+	spec, err := torrent.TorrentSpecFromMagnetUri(q.Get("magnet"))
+	if err != nil {
+		http.Error(w, "magnet: "+err.Error(), 400)
+		return
+	}
+	for _, tr := range q["tracker"] {
+		spec.Trackers = append(spec.Trackers, []string{tr})
+	}
+	wait := defaultInspectWait
+	if d, err := time.ParseDuration(q.Get("timeout")); err == nil && d > 0 {
+		wait = min(d, maxInspectWait)
+	}
+
+	source := "swarm"
+	t, known := currentClient().Torrent(spec.InfoHash)
+	if known {
+		source = "session"
+	} else {
+		if cachedInfo(spec.InfoHash) != nil {
+			source = "cache"
+		}
+		if t, err = p.addSpec(spec); err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		defer func() {
+			if !torrentInUseElsewhere(t, nil) {
+				t.Drop()
+			}
+		}()
+	}
+
+	select {
+	case <-t.GotInfo():
+	case <-time.After(wait):
+		http.Error(w, "timed out waiting for metadata", 504)
+		return
+	case <-r.Context().Done():
+		return
+	}
+
+	info := t.Info()
+	resp := inspectResponse{
+		InfoHash:    t.InfoHash().HexString(),
+		Name:        t.Name(),
+		TotalSize:   info.TotalLength(),
+		PieceLength: info.PieceLength,
+		NumPieces:   t.NumPieces(),
+		Files:       []inspectFile{},
+		Selected:    -1,
+		Source:      source,
+	}
+	for i, f := range t.Files() {
+		resp.Files = append(resp.Files, inspectFile{Index: i, Path: f.DisplayPath(), Size: f.Length(), Video: engine.IsVideo(f.DisplayPath())})
+	}
+	hint := engine.Hint{Title: q.Get("title"), Episode: q.Get("episode")}
+	if f, sel := engine.SelectFile(t, hint, p.selectFilter()); f != nil {
+		resp.Selected, resp.Selection = fileIndex(t, f), sel
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// ── Instance identity ─────────────────────────────────────────────────────────
+// Every process gets a random UUID, sent as X-Roxbox-Instance on every
+// response. The app remembers the one it started (or first talked to) and
+// treats a different value as a restarted or foreign server: sessions, seqs
+// and stream URLs from before are not valid there.
+//
+// Before binding we also check nothing already answers /health on our port.
+// Some platforms let a second socket bind 127.0.0.1 next to one on 0.0.0.0
+// (or the reverse), so the app could end up talking to a stale roxbox or
+// another program without the bind ever failing.
+
+const instanceHeader = "X-Roxbox-Instance"
+
+var instanceID = newInstanceID()
+
+// newInstanceID returns a random (version 4) UUID.
+func newInstanceID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// withInstance stamps responses with the instance ID.
+func withInstance(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(instanceHeader, instanceID)
+		h.ServeHTTP(w, r)
+	})
+}
+
+// checkPortFree fails if something already serves HTTP on port, naming the
+// other roxbox instance when it is one.
+func checkPortFree(port string) error {
+	client := &http.Client{Timeout: 2 * time.Second}
+	resp, err := client.Get("http://" + net.JoinHostPort("127.0.0.1", port) + "/health")
+	if err != nil {
+		return nil // nothing listening, or not speaking HTTP: bind decides
+	}
+	resp.Body.Close()
+	if other := resp.Header.Get(instanceHeader); other != "" {
+		return fmt.Errorf("port %s is already served by another roxbox instance (%s); stop it or pick another port with ROXBOX_PORT", port, other)
+	}
+	return fmt.Errorf("port %s is already served by another program (/health answered %d); pick another port with ROXBOX_PORT", port, resp.StatusCode)
+}
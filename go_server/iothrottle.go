@@ -0,0 +1,82 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/anacrolix/torrent/metainfo"
+
+	"github.com/roxbox/torrent_server/engine"
+)
+
+// ── Disk I/O throttling during playback ───────────────────────────────────────
+// While a player is reading a stream, writes and hashing of the pieces
+// outside its readahead window are held to -io-throttle MB/s (env
+// ROXBOX_IO_THROTTLE; 0 turns it off) so they don't crowd out its reads on
+// slow flash (engine/iothrottle.go). A paused player (trickle mode) or none
+// at all lifts the limit. /debug/stats reports what was held back.
+
+const (
+	defaultIOThrottle = 8 // MB/s
+	ioWindowEvery     = 500 * time.Millisecond
+)
+
+var ioThrottleFlag = flag.Float64("io-throttle", defaultIOThrottle, "MB/s of background piece writes and hashing while a player is reading; 0 turns it off (env ROXBOX_IO_THROTTLE)")
+
+// applyIOThrottle reads -io-throttle for the clients built from now on.
+func applyIOThrottle() {
+	rate, given := *ioThrottleFlag, false
+	flag.Visit(func(f *flag.Flag) { given = given || f.Name == "io-throttle" })
+	if v := os.Getenv("ROXBOX_IO_THROTTLE"); v != "" && !given {
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			log.Printf("io-throttle: ROXBOX_IO_THROTTLE: %v", err)
+		} else {
+			rate = n
+		}
+	}
+	engine.DefaultIOThrottle.RateMBs = max(rate, 0)
+	if rate > 0 {
+		go ioWindowLoop()
+	}
+}
+
+// ioWindowLoop keeps the throttle told which pieces players wait for.
+func ioWindowLoop() {
+	defer guard()
+	tick := time.NewTicker(ioWindowEvery)
+	defer tick.Stop()
+	for range tick.C {
+		engine.DefaultIOThrottle.SetWindows(playbackWindows())
+	}
+}
+
+// playbackWindows are the pieces under the readahead of every reader of a
+// session that isn't paused, by torrent.
+func playbackWindows() map[metainfo.Hash][][2]int {
+	profilesMu.Lock()
+	var all []*session
+	for _, p := range profiles {
+		all = append(all, p.sessions()...)
+	}
+	profilesMu.Unlock()
+	out := map[metainfo.Hash][][2]int{}
+	for _, s := range all {
+		t, f := s.current()
+		if t == nil || f == nil || t.Info() == nil || s.trickling() {
+			continue
+		}
+		span := engine.PieceRange(f)
+		s.readersMu.Lock()
+		for rd := range s.readers {
+			pos, ahead := rd.pos.Load(), max(rd.readahead.Load(), 1)
+			ih := t.InfoHash()
+			out[ih] = append(out[ih], [2]int{span.PieceAt(pos), span.PieceAt(pos + ahead - 1)})
+		}
+		s.readersMu.Unlock()
+	}
+	return out
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/roxbox/torrent_server/engine"
+)
+
+// ── Timing jitter ─────────────────────────────────────────────────────────────
+// -jitter (or ROXBOX_JITTER=1) makes announces, peer dials and keepalives
+// irregular, for networks that throttle BitTorrent by its timing even when
+// it is encrypted (engine/jitter.go). It costs a few seconds per announce
+// and slows the first peer connections a little.
+
+var jitterFlag = flag.Bool("jitter", false, "randomise announce and connection timing (env ROXBOX_JITTER)")
+
+func init() {
+	capabilityProbes["jitter"] = func() capability {
+		if !engine.DefaultJitter.Enabled {
+			return capability{Compiled: true, Detail: "set -jitter to randomise announce and connection timing"}
+		}
+		return capability{Compiled: true, Enabled: true}
+	}
+}
+
+// applyJitter turns jitter on for the clients built from now on.
+func applyJitter() {
+	on := *jitterFlag
+	if v := os.Getenv("ROXBOX_JITTER"); v != "" && !on {
+		on, _ = strconv.ParseBool(v)
+	}
+	if !on {
+		return
+	}
+	engine.DefaultJitter.Enabled = true
+	log.Println("Timing jitter: on")
+}
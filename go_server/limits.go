@@ -0,0 +1,315 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent"
+)
+
+// ── Global limits ─────────────────────────────────────────────────────────────
+// Limits over every profile's sessions together: at most -max-active
+// torrents download at once, and at most -max-conns peer connections are
+// open in total (env ROXBOX_MAX_ACTIVE, ROXBOX_MAX_CONNS; 0 is no limit).
+// Torrents over the active limit wait with data download off, in order:
+// sessions someone is reading from, then streaming (primary) sessions, then
+// background ones, each oldest first. Torrents still fetching metadata,
+// complete or paused don't take a slot. The connection budget is split over
+// the downloading torrents, a streaming one counting double; waiting ones
+// hold none. GET /sessions lists every session and its place.
+
+const (
+	limitsCheckEvery = 2 * time.Second
+	minConnShare     = 4 // connections a downloading torrent gets at least
+)
+
+var (
+	maxActiveFlag = flag.Int("max-active", 0, "download at most this many torrents at once across all profiles, 0 for no limit (env ROXBOX_MAX_ACTIVE)")
+	maxConnsFlag  = flag.Int("max-conns", 0, "open at most this many peer connections in total, 0 for no limit (env ROXBOX_MAX_CONNS)")
+)
+
+type globalLimits struct {
+	MaxActive int `json:"max_active"` // 0: no limit
+	MaxConns  int `json:"max_conns"`  // 0: no limit
+}
+
+var (
+	limits      globalLimits // set once by startLimits
+	limitsMu    sync.Mutex   // serialises enforceLimits
+	limitsConns = map[*torrent.Torrent]int{}
+	limitsPeers = map[*torrent.Torrent][]torrent.PeerInfo{} // of torrents waiting without connections
+	limitsKick  = make(chan struct{}, 1)
+)
+
+// startLimits reads the limits and starts enforcing them.
+func startLimits() {
+	limit := func(v int, env string) int {
+		if v == 0 {
+			v, _ = strconv.Atoi(os.Getenv(env))
+		}
+		return max(v, 0)
+	}
+	limits = globalLimits{MaxActive: limit(*maxActiveFlag, "ROXBOX_MAX_ACTIVE"), MaxConns: limit(*maxConnsFlag, "ROXBOX_MAX_CONNS")}
+	if limits.MaxActive > 0 || limits.MaxConns > 0 {
+		log.Printf("Global limits: %d active torrents, %d connections (0: none)", limits.MaxActive, limits.MaxConns)
+	}
+	go limitsLoop()
+}
+
+// kickLimits has the limits looked at now rather than on the next tick.
+func kickLimits() {
+	select {
+	case limitsKick <- struct{}{}:
+	default:
+	}
+}
+
+func limitsLoop() {
+	defer guard()
+	tick := time.NewTicker(limitsCheckEvery)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+		case <-limitsKick:
+		}
+		enforceLimits()
+	}
+}
+
+// limitEntry is one session's place under the limits.
+type limitEntry struct {
+	s       *session
+	t       *torrent.Torrent
+	stream  bool // the profile's primary session
+	reading bool
+	added   time.Time
+	state   string // "metadata" | "downloading" | "queued" | "paused" | "complete"
+	pos     int    // in the wait, from 1
+	conns   int    // connection limit under max_conns
+}
+
+// rankSessions works out which sessions may download. A torrent held by
+// several sessions takes one slot, ranked by its best session.
+func rankSessions() []*limitEntry {
+	profilesMu.Lock()
+	var sessions []*session
+	for _, p := range profiles {
+		sessions = append(sessions, p.sessions()...)
+	}
+	profilesMu.Unlock()
+
+	var all, candidates []*limitEntry
+	for _, s := range sessions {
+		s.mu.RLock()
+		t, f, added, maxConns := s.torr, s.file, s.added, s.pieces.MaxConns()
+		s.mu.RUnlock()
+		if t == nil {
+			continue
+		}
+		s.readersMu.Lock()
+		reading := len(s.readers) > 0
+		s.readersMu.Unlock()
+		s.playerMu.Lock()
+		paused := s.idlePaused || s.addPaused
+		s.playerMu.Unlock()
+
+		e := &limitEntry{s: s, t: t, stream: s.hash == "", reading: reading, added: added, conns: maxConns}
+		switch {
+		case f == nil || t.Info() == nil:
+			e.state = "metadata"
+		case f.BytesCompleted() >= f.Length():
+			e.state = "complete"
+		case paused:
+			e.state = "paused"
+		default:
+			candidates = append(candidates, e)
+		}
+		all = append(all, e)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.reading != b.reading {
+			return a.reading
+		}
+		if a.stream != b.stream {
+			return a.stream
+		}
+		return a.added.Before(b.added)
+	})
+	slot := map[*torrent.Torrent]*limitEntry{} // the best session of each torrent
+	var downloading []*limitEntry
+	waiting := 0
+	for _, e := range candidates {
+		if best := slot[e.t]; best != nil {
+			e.state, e.pos = best.state, best.pos
+			continue
+		}
+		slot[e.t] = e
+		if limits.MaxActive == 0 || len(downloading) < limits.MaxActive {
+			e.state = "downloading"
+			downloading = append(downloading, e)
+		} else {
+			waiting++
+			e.state, e.pos, e.conns = "queued", waiting, 0
+		}
+	}
+
+	if limits.MaxConns > 0 {
+		weight := func(e *limitEntry) int {
+			if e.stream || e.reading {
+				return 2
+			}
+			return 1
+		}
+		total := 0
+		for _, e := range downloading {
+			total += weight(e)
+		}
+		for _, e := range downloading {
+			e.conns = min(e.conns, max(minConnShare, limits.MaxConns*weight(e)/total))
+		}
+		for _, e := range candidates {
+			if best := slot[e.t]; best != e {
+				e.conns = best.conns
+			}
+		}
+	}
+	return all
+}
+
+// enforceLimits holds back the torrents over the limits and lets the
+// others go.
+func enforceLimits() {
+	limitsMu.Lock()
+	defer limitsMu.Unlock()
+	seen := map[*torrent.Torrent]bool{}
+	for _, e := range rankSessions() {
+		seen[e.t] = true
+		e.s.applyLimit(e)
+		prev, ok := limitsConns[e.t]
+		if limits.MaxConns == 0 || (ok && prev == e.conns) {
+			continue
+		}
+		if e.conns == 0 { // its peers are dialled again once it gets a slot
+			limitsPeers[e.t] = e.t.KnownSwarm()
+		}
+		e.t.SetMaxEstablishedConns(e.conns)
+		limitsConns[e.t] = e.conns
+		if peers := limitsPeers[e.t]; e.conns > 0 && len(peers) > 0 {
+			e.t.AddPeers(peers)
+			delete(limitsPeers, e.t)
+		}
+	}
+	for t := range limitsConns {
+		if !seen[t] {
+			delete(limitsConns, t)
+			delete(limitsPeers, t)
+		}
+	}
+}
+
+// applyLimit queues the session or lets it download.
+func (s *session) applyLimit(e *limitEntry) {
+	queued := e.state == "queued"
+	s.playerMu.Lock()
+	was := s.limitHeld
+	s.limitHeld = queued
+	paused := s.idlePaused || s.addPaused
+	s.playerMu.Unlock()
+
+	switch {
+	case queued && !was:
+		e.t.DisallowDataDownload()
+		s.event("", fmt.Sprintf("waiting: %d torrents are downloading already", limits.MaxActive))
+	case !queued && was && !paused:
+		e.t.AllowDataDownload()
+		s.event("", "started: a download slot is free")
+	}
+	s.mu.Lock()
+	if s.torr == e.t && (s.status.GlobalQueued != queued || s.status.GlobalPosition != e.pos) {
+		s.status.GlobalQueued, s.status.GlobalPosition = queued, e.pos
+		s.touch()
+	}
+	s.mu.Unlock()
+}
+
+// ── GET /sessions ─────────────────────────────────────────────────────────────
+// Every session of every profile, in the order the limits rank them.
+
+type sessionEntry struct {
+	Profile  string `json:"profile"`
+	InfoHash string `json:"info_hash"`
+	Name     string `json:"name,omitempty"`
+	Kind     string `json:"kind"`               // "stream" | "background"
+	State    string `json:"state"`              // "metadata" | "downloading" | "queued" | "paused" | "complete"
+	Position int    `json:"position,omitempty"` // among the queued, from 1
+	Reading  bool   `json:"reading,omitempty"`
+	Peers    int    `json:"peers"`
+	MaxConns int    `json:"max_conns,omitempty"` // its share of limits.max_conns
+}
+
+type sessionsResponse struct {
+	Limits      globalLimits   `json:"limits"`
+	Downloading int            `json:"downloading"` // torrents, not sessions
+	Queued      int            `json:"queued"`
+	Sessions    []sessionEntry `json:"sessions"`
+}
+
+func handleSessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "GET only", 405)
+		return
+	}
+	entries := rankSessions()
+	rank := map[string]int{"downloading": 0, "queued": 1, "metadata": 2, "paused": 3, "complete": 4}
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if rank[a.state] != rank[b.state] {
+			return rank[a.state] < rank[b.state]
+		}
+		return a.pos < b.pos
+	})
+	resp := sessionsResponse{Limits: limits, Sessions: []sessionEntry{}}
+	counted := map[*torrent.Torrent]bool{}
+	for _, e := range entries {
+		se := sessionEntry{
+			Profile:  e.s.profile.ID,
+			InfoHash: e.t.InfoHash().HexString(),
+			Kind:     "background",
+			State:    e.state,
+			Position: e.pos,
+			Reading:  e.reading,
+			Peers:    e.t.Stats().ActivePeers,
+		}
+		if e.stream {
+			se.Kind = "stream"
+		}
+		if e.t.Info() != nil {
+			se.Name = e.t.Name()
+		}
+		if limits.MaxConns > 0 && e.state == "downloading" {
+			se.MaxConns = e.conns
+		}
+		switch {
+		case counted[e.t]:
+		case e.state == "downloading":
+			resp.Downloading++
+		case e.state == "queued":
+			resp.Queued++
+		}
+		counted[e.t] = true
+		resp.Sessions = append(resp.Sessions, se)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
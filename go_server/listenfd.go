@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+)
+
+// ── Inherited listener ────────────────────────────────────────────────────────
+// The HTTP listener can come from the parent process instead of being
+// bound here. The parent binds the port before starting roxbox, so it knows
+// the port is ours (nobody can squat on it meanwhile) and can connect at
+// once: connections wait in the backlog until the server is up, with no
+// polling of /health. Two ways:
+//
+//   - systemd socket activation: LISTEN_FDS/LISTEN_PID, the first socket
+//     (fd 3) is used. A matching roxbox.socket unit has ListenStream=8888.
+//   - -listen-fd N (or ROXBOX_LISTEN_FD=N): fd N is the listening socket,
+//     or one end of a socketpair the parent sends it over (SCM_RIGHTS; the
+//     Android app does this, since it can't hand a forked process
+//     arbitrary fds). Unix only.
+//
+// The port in stream URLs is the listener's; ROXBOX_PORT is ignored.
+
+const sdListenFDsStart = 3 // SD_LISTEN_FDS_START
+
+var listenFDFlag = flag.Int("listen-fd", -1, "serve on an inherited listening socket, or one received over this socketpair fd (env ROXBOX_LISTEN_FD)")
+
+// inheritedListener returns the listener the parent passed, or nil when it
+// passed none.
+func inheritedListener() (net.Listener, error) {
+	fd, from := -1, ""
+	if n, err := strconv.Atoi(os.Getenv("LISTEN_FDS")); err == nil && n > 0 &&
+		os.Getenv("LISTEN_PID") == strconv.Itoa(os.Getpid()) {
+		if n > 1 {
+			log.Printf("systemd passed %d sockets; serving on the first", n)
+		}
+		fd, from = sdListenFDsStart, "systemd"
+	}
+	// Not for children of ours (the environment says which process they're for)
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDNAMES")
+	if fd < 0 {
+		fd, from = *listenFDFlag, "-listen-fd"
+		if v := os.Getenv("ROXBOX_LISTEN_FD"); v != "" && fd < 0 {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("ROXBOX_LISTEN_FD: %v", err)
+			}
+			fd, from = n, "ROXBOX_LISTEN_FD"
+		}
+	}
+	if fd < 0 {
+		return nil, nil
+	}
+	lfd, err := listenerFD(fd) // listenfd_unix.go
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", from, err)
+	}
+	f := os.NewFile(uintptr(lfd), "listener")
+	ln, err := net.FileListener(f) // dups the fd
+	f.Close()
+	if err != nil {
+		return nil, fmt.Errorf("%s: fd %d: %w", from, lfd, err)
+	}
+	addr, ok := ln.Addr().(*net.TCPAddr)
+	if !ok {
+		ln.Close()
+		return nil, fmt.Errorf("%s: fd %d listens on %s, want TCP", from, lfd, ln.Addr().Network())
+	}
+	port = strconv.Itoa(addr.Port)
+	log.Printf("Serving on %s from %s", addr, from)
+	return ln, nil
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !darwin
+
+package main
+
+import "errors"
+
+func listenerFD(fd int) (int, error) {
+	return -1, errors.New("inherited listeners are not supported on this platform")
+}
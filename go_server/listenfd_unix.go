@@ -0,0 +1,43 @@
+//go:build linux || darwin
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+)
+
+// listenerFD returns fd when it is a listening socket. Otherwise fd is a
+// socketpair end and the listener is received over it; fd is closed.
+func listenerFD(fd int) (int, error) {
+	listening, err := syscall.GetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_ACCEPTCONN)
+	if err != nil {
+		return -1, fmt.Errorf("fd %d is not a socket: %w", fd, err)
+	}
+	if listening != 0 {
+		return fd, nil
+	}
+	defer syscall.Close(fd)
+	buf, oob := make([]byte, 1), make([]byte, syscall.CmsgSpace(4))
+	_, oobn, _, _, err := syscall.Recvmsg(fd, buf, oob, 0)
+	if err != nil {
+		return -1, fmt.Errorf("fd %d: receive listener: %w", fd, err)
+	}
+	msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	if err != nil || len(msgs) == 0 {
+		return -1, fmt.Errorf("fd %d: the parent sent no file descriptor", fd)
+	}
+	fds, err := syscall.ParseUnixRights(&msgs[0])
+	if err != nil || len(fds) == 0 {
+		return -1, fmt.Errorf("fd %d: the parent sent no file descriptor", fd)
+	}
+	for _, extra := range fds[1:] {
+		syscall.Close(extra)
+	}
+	if listening, _ := syscall.GetsockoptInt(fds[0], syscall.SOL_SOCKET, syscall.SO_ACCEPTCONN); listening == 0 {
+		syscall.Close(fds[0])
+		return -1, errors.New("the socket the parent sent is not listening")
+	}
+	return fds[0], nil
+}
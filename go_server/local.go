@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/roxbox/torrent_server/engine"
+)
+
+// ── POST /add/local?path=<absolute path> ──────────────────────────────────────
+// Plays a video that is already on the device through the same endpoints as
+// a torrent: /stream serves it (Range requests, MIME type from the name),
+// /files lists it with the subtitle and audio files beside it, and it goes
+// into the profile history. Only video files are accepted.
+
+type localMedia struct {
+	Path       string
+	Size       int64
+	Companions []localCompanion
+}
+
+type localCompanion struct {
+	Path string
+	Kind string // "subtitle" | "audio"
+	Lang string
+}
+
+func handleAddLocal(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST only", 405)
+		return
+	}
+	sess := sessionFor(w, r)
+	if sess == nil {
+		return
+	}
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		path = r.FormValue("path")
+	}
+	if !filepath.IsAbs(path) {
+		http.Error(w, "path must be an absolute file path", 400)
+		return
+	}
+	path = filepath.Clean(path)
+	fi, err := os.Stat(path)
+	if err != nil {
+		http.Error(w, err.Error(), 404)
+		return
+	}
+	if !fi.Mode().IsRegular() || !engine.IsVideo(path) {
+		http.Error(w, "not a video file", 415)
+		return
+	}
+
+	sess.supersede()
+	sess.stop()
+	m := &localMedia{Path: path, Size: fi.Size(), Companions: localCompanions(path)}
+	sess.mu.Lock()
+	sess.local = m
+	sess.status = StatusResponse{State: "ready", Progress: 100, CompletedMB: float64(m.Size) / (1 << 20), StreamURL: sess.streamURL()}
+	sess.touch()
+	sess.mu.Unlock()
+	sess.active()
+	sess.profile.appendHistory(historyEntry{Time: time.Now(), Name: filepath.Base(path), File: path})
+	sess.event(requestID(r), "ready: local "+path)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"status": "ready", "stream_url": sess.streamURL()})
+}
+
+// localCompanions finds the subtitle and audio files paired with the
+// video at path, beside it or in a Subs folder next to it.
+func localCompanions(path string) []localCompanion {
+	dir := filepath.Dir(path)
+	var candidates []string
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		switch {
+		case e.Type().IsRegular():
+			candidates = append(candidates, filepath.Join(dir, e.Name()))
+		case e.IsDir() && engine.IsSubtitleDir(e.Name()):
+			sub, _ := os.ReadDir(filepath.Join(dir, e.Name()))
+			for _, s := range sub {
+				if s.Type().IsRegular() {
+					candidates = append(candidates, filepath.Join(dir, e.Name(), s.Name()))
+				}
+			}
+		}
+	}
+	sort.Strings(candidates)
+	var out []localCompanion
+	for _, c := range candidates {
+		if c == path {
+			continue
+		}
+		if kind, lang, ok := engine.PairCompanion(filepath.ToSlash(path), filepath.ToSlash(c)); ok {
+			out = append(out, localCompanion{Path: c, Kind: kind, Lang: lang})
+		}
+	}
+	return out
+}
+
+// serveLocal serves a file of a local session (Range requests supported).
+func serveLocal(w http.ResponseWriter, r *http.Request, path string) {
+	f, err := os.Open(path)
+	if err != nil {
+		http.Error(w, err.Error(), 404)
+		return
+	}
+	defer f.Close()
+	engine.ServeContent(w, r, filepath.Base(path), f)
+}
+
+// localFiles is /files for a local video.
+func localFiles(s *session, m *localMedia) filesResponse {
+	out := filesResponse{Files: []fileEntry{{Index: -1, Path: m.Path, Size: m.Size, Type: engine.FileType(m.Path), Progress: 100, Role: "video", URL: s.streamURL()}}}
+	for i, c := range m.Companions {
+		var size int64
+		if fi, err := os.Stat(c.Path); err == nil {
+			size = fi.Size()
+		}
+		out.Files = append(out.Files, fileEntry{Index: i, Path: c.Path, Size: size, Type: engine.FileType(c.Path), Progress: 100, Role: c.Kind, Lang: c.Lang, URL: s.fileURL(i)})
+	}
+	return out
+}
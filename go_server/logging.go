@@ -0,0 +1,127 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+)
+
+// ── Log file ──────────────────────────────────────────────────────────────────
+// By default logs go to stderr (the Flutter app captures them). With -log or
+// in -daemon mode they go to a file instead, which is reopened on SIGHUP so
+// external rotation (logrotate, newsyslog) works.
+//
+// The file is also rotated by size: once it would exceed -log-max-mb it
+// becomes <path>.1 (older ones shift to .2, .3 …) and at most
+// -log-max-files rotated files are kept. -log-max-mb 0 disables this.
+
+var (
+	logMaxMB    = flag.Int("log-max-mb", 10, "rotate the log file at this size in MB (0: never)")
+	logMaxFiles = flag.Int("log-max-files", 3, "rotated log files to keep")
+)
+
+type logFile struct {
+	path     string
+	maxSize  int64 // 0: no rotation
+	maxFiles int
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+var activeLog *logFile
+
+func openLogFile(path string) (*logFile, error) {
+	l := &logFile{path: path, maxSize: int64(*logMaxMB) << 20, maxFiles: max(*logMaxFiles, 1)}
+	if err := l.reopen(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *logFile) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.maxSize > 0 && l.size > 0 && l.size+int64(len(p)) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			fmt.Fprintf(os.Stderr, "log rotation: %v\n", err)
+		}
+	}
+	n, err := l.f.Write(p)
+	l.size += int64(n)
+	return n, err
+}
+
+// rotate shifts path → path.1 → path.2 … dropping the oldest, and starts a
+// new file. Caller holds l.mu.
+func (l *logFile) rotate() error {
+	_ = os.Remove(fmt.Sprintf("%s.%d", l.path, l.maxFiles))
+	for i := l.maxFiles - 1; i >= 1; i-- {
+		_ = os.Rename(fmt.Sprintf("%s.%d", l.path, i), fmt.Sprintf("%s.%d", l.path, i+1))
+	}
+	closed := false
+	renameErr := os.Rename(l.path, l.path+".1")
+	if renameErr != nil { // Windows won't rename an open file
+		_ = l.f.Close()
+		closed = true
+		renameErr = os.Rename(l.path, l.path+".1")
+	}
+	// If the rename failed we reopen (and keep growing) the same file
+	// rather than losing the log. If the new file can't be opened, the
+	// log stays in the old one, wherever it is now; the next try is another
+	// -log-max-mb on, not every line.
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		l.size = 0
+		if closed {
+			old := l.path
+			if renameErr == nil {
+				old += ".1"
+			}
+			if of, oerr := os.OpenFile(old, os.O_WRONLY|os.O_APPEND, 0644); oerr == nil {
+				l.f = of
+			}
+		}
+		return err
+	}
+	if !closed {
+		_ = l.f.Close()
+	}
+	l.f, l.size = f, 0
+	return renameErr
+}
+
+// reopen switches to a fresh handle on path, e.g. after the file was moved
+// away by a rotation tool.
+func (l *logFile) reopen() error {
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	var size int64
+	if fi, err := f.Stat(); err == nil {
+		size = fi.Size()
+	}
+	l.mu.Lock()
+	old := l.f
+	l.f, l.size = f, size
+	l.mu.Unlock()
+	if old != nil {
+		_ = old.Close()
+	}
+	return nil
+}
+
+// setLogFile redirects the standard logger to path.
+func setLogFile(path string) {
+	l, err := openLogFile(path)
+	if err != nil {
+		log.Printf("log file %s: %v (staying on stderr)", path, err)
+		return
+	}
+	activeLog = l
+	log.SetOutput(l)
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	alog "github.com/anacrolix/log"
+)
+
+// ── Per-module log levels ─────────────────────────────────────────────────────
+// Verbosity can be raised for one subsystem at runtime while reproducing a
+// problem, without restarting and losing the swarm state:
+//
+//	dht, tracker, peer, torrent  messages from the torrent library
+//	reader                       /stream readers (ranges, readahead)
+//	http                         control API requests
+//
+// Everything defaults to "info". Torrent library output is routed through
+// the standard logger, so it also lands in the -log file.
+
+var logModules = []string{"dht", "tracker", "peer", "torrent", "reader", "http"}
+
+var (
+	logLevelsMu sync.RWMutex
+	logLevels   = map[string]alog.Level{}
+)
+
+var levelNames = map[string]alog.Level{
+	"debug":   alog.Debug,
+	"info":    alog.Info,
+	"warning": alog.Warning,
+	"error":   alog.Error,
+	"off":     alog.Never,
+}
+
+func moduleLevel(module string) alog.Level {
+	logLevelsMu.RLock()
+	defer logLevelsMu.RUnlock()
+	if l, ok := logLevels[module]; ok {
+		return l
+	}
+	return alog.Info
+}
+
+func logEnabled(module string, level alog.Level) bool {
+	min := moduleLevel(module)
+	if min == alog.Never {
+		return false
+	}
+	return !level.LessThan(min)
+}
+
+// debugf logs for module when it's at debug level.
+func debugf(module, format string, args ...any) {
+	if logEnabled(module, alog.Debug) {
+		log.Printf("["+module+"] "+format, args...)
+	}
+}
+
+// torrentLogger is the client's logger: everything is passed through and
+// filtered per module by moduleHandler.
+func torrentLogger() alog.Logger {
+	var l alog.Logger
+	l.SetHandlers(moduleHandler{})
+	return l.WithDefaultLevel(alog.Info).WithFilterLevel(alog.Debug)
+}
+
+type moduleHandler struct{}
+
+func (moduleHandler) Handle(r alog.Record) {
+	m := recordModule(r.Names)
+	if !logEnabled(m, r.Level) {
+		return
+	}
+	log.Printf("[%s %s] %s", m, r.Level.LogString(), strings.TrimRight(r.Text(), "\n"))
+}
+
+// recordModule maps a library log record to one of logModules from its
+// logger names and source package.
+func recordModule(names []string) string {
+	for _, n := range names {
+		switch {
+		case n == "dht" || strings.Contains(n, "anacrolix/dht"):
+			return "dht"
+		case strings.Contains(n, "tracker") || strings.HasPrefix(n, "announce"):
+			return "tracker"
+		case strings.HasPrefix(n, "peerconn.go") || strings.HasPrefix(n, "peer.go") ||
+			strings.Contains(n, "peer_protocol") || strings.HasPrefix(n, "handshake"):
+			return "peer"
+		}
+	}
+	return "torrent"
+}
+
+// withRequestLog logs control API requests at http debug level.
+func withRequestLog(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !logEnabled("http", alog.Debug) {
+			h.ServeHTTP(w, r)
+			return
+		}
+		start := time.Now()
+		debugf("http", "[%s] → %s %s", requestID(r), r.Method, r.URL.RequestURI())
+		h.ServeHTTP(w, r)
+		debugf("http", "[%s] ← %s %s (%s)", requestID(r), r.Method, r.URL.Path, time.Since(start).Round(time.Millisecond))
+	})
+}
+
+// ── GET|POST /debug/loglevel ──────────────────────────────────────────────────
+//
+//	GET                              → {"dht": "info", …}
+//	POST ?module=dht&level=debug     → set one module (module=all for every one)
+func handleLogLevel(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		module, name := r.FormValue("module"), strings.ToLower(r.FormValue("level"))
+		level, ok := levelNames[name]
+		if !ok {
+			http.Error(w, "level must be debug, info, warning, error or off", 400)
+			return
+		}
+		targets := []string{module}
+		if module == "all" {
+			targets = logModules
+		} else if !knownModule(module) {
+			http.Error(w, fmt.Sprintf("module must be one of %s or all", strings.Join(logModules, ", ")), 400)
+			return
+		}
+		logLevelsMu.Lock()
+		for _, m := range targets {
+			logLevels[m] = level
+		}
+		logLevelsMu.Unlock()
+		log.Printf("Log level for %s set to %s", module, name)
+	default:
+		http.Error(w, "GET or POST only", 405)
+		return
+	}
+
+	out := map[string]string{}
+	for _, m := range logModules {
+		l := moduleLevel(m)
+		for n, nl := range levelNames {
+			if nl == l {
+				out[m] = n
+			}
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+func knownModule(m string) bool {
+	for _, k := range logModules {
+		if k == m {
+			return true
+		}
+	}
+	return false
+}
@@ -2,6 +2,7 @@
 // Compiles to a single binary that runs on Android ARM64.
 // Starts a sequential-download torrent session and serves the video
 // file over HTTP on 127.0.0.1:8888 so media_kit can play it.
+// The streaming core lives in ./engine for embedding without this HTTP layer.
 //
 // Build for Android ARM64:
 //   GOOS=android GOARCH=arm64 CGO_ENABLED=0 \
@@ -10,351 +11,643 @@
 // Build for Android ARMv7:
 //   GOOS=android GOARCH=arm GOARM=7 CGO_ENABLED=0 \
 //     go build -ldflags="-s -w" -o torrent_server_arm .
+//
+// For Android 10+ package it as jniLibs/<abi>/libroxbox.so (see native.go).
+//
+// `torrent_server selftest-e2e` checks add → stream → seek → stop on-device
+// against a built-in local seeder (selftest.go); `bench-storage` checks the
+// cache dir keeps up with a target bitrate (bench.go); `openapi` prints the
+// API document the Dart client in clients/dart is generated from.
 
 package main
 
 import (
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
-	"path/filepath"
-	"sort"
 	"strconv"
 	"strings"
-	"sync"
 	"syscall"
 	"time"
 
 	"github.com/anacrolix/torrent"
-	"github.com/anacrolix/torrent/storage"
+
+	"github.com/roxbox/torrent_server/engine"
 )
 
 // ── Status struct sent back to Flutter ────────────────────────────────────────
 type StatusResponse struct {
 	State       string  `json:"state"`        // "idle" | "loading" | "ready" | "error"
-	Progress    float64 `json:"progress"`     // 0–100
-	DownloadMB  float64 `json:"download_mb"`
+	Progress    float64 `json:"progress"`     // 0–100, verified part of the file
+	CompletedMB float64 `json:"completed_mb"` // verified part of the file
+	DownloadMB  float64 `json:"download_mb"`  // downloaded this session
 	SpeedKBs    float64 `json:"speed_kbs"`
 	Peers       int     `json:"peers"`
+	UploadedMB     float64 `json:"uploaded_mb"`      // sent this session
+	UploadSpeedKBs float64 `json:"upload_speed_kbs"`
+	Ratio          float64 `json:"ratio"`            // uploaded / downloaded this session
+	Seeding        bool    `json:"seeding"`          // complete and serving the swarm
 	StreamURL   string  `json:"stream_url"`   // http://127.0.0.1:8888/stream
 	Error       string  `json:"error,omitempty"`
+	InfoHash    string  `json:"info_hash,omitempty"` // session id for /session/{id}/…
+	PlayerState string  `json:"player_state,omitempty"` // last state from /player/state
+	PositionSec float64 `json:"position_sec,omitempty"` // last position from /player/state
+	DurationSec float64 `json:"duration_sec,omitempty"` // duration from /player/state
+	ResumeAtSec float64 `json:"resume_at_sec,omitempty"` // seek here after a handoff
+	Trickle     bool    `json:"trickle,omitempty"`      // paused long enough to stop bulk download
+	Paused      bool    `json:"paused,omitempty"`       // added paused; resumes on the first read or "playing"
+	IdlePaused  bool    `json:"idle_paused,omitempty"`  // nobody read or polled for a while (idle.go)
+	DataSaver   bool    `json:"data_saver,omitempty"`   // only the window past the position is fetched (datasaver.go)
+	FillThrottled bool  `json:"fill_throttled,omitempty"` // background fill held back for the playback window (bandwidth.go)
+	GlobalQueued bool   `json:"global_queued,omitempty"`   // waiting for a download slot under -max-active (limits.go)
+	GlobalPosition int  `json:"global_position,omitempty"` // place in that wait, from 1
+	Private     bool    `json:"private,omitempty"`      // private-tracker torrent: no DHT, PEX or extra trackers (engine/private.go)
+	SuperSeeding bool   `json:"super_seeding,omitempty"` // a peer looks like a seeder in super-seed mode (engine/superseed.go)
+	SwarmWarning string `json:"swarm_warning,omitempty"` // why speed is capped by the swarm, e.g. a single seed
+	Availability float64 `json:"availability"` // distributed copies among connected peers (engine/availability.go)
+	WindowAvailability *float64 `json:"window_availability,omitempty"` // the same for the missing pieces of the playback windows; absent when none are missing
+	Fallback    *fallbackStatus `json:"fallback,omitempty"` // which of the add's magnets is active (fallback.go)
+	Timings     *StartupTimings `json:"timings,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"` // from the /add that started the session
+	AddID       uint64  `json:"add_id,omitempty"` // the add that owns the session (addqueue.go)
 }
 
 // ── Global state ───────────────────────────────────────────────────────────────
+// Per-stream state lives in each profile's session (session.go).
 var (
-	mu          sync.RWMutex
-	currentFile *torrent.File
-	currentTorr *torrent.Torrent
-	client      *torrent.Client
-	cacheDir    string
-	status      = StatusResponse{State: "idle"}
-	port        = "8888"
+	client   *torrent.Client
+	cacheDir string
+	port     = "8888"
+	version  = "dev" // -ldflags "-X main.version=..."
 )
 
 func main() {
+	flag.Parse()
+	switch flag.Arg(0) {
+	case "selftest-e2e":
+		os.Exit(runSelfTestE2E()) // isolated; doesn't touch the real cache
+	case "bench-storage":
+		os.Exit(runBenchStorage())
+	case "openapi":
+		os.Exit(printOpenAPI()) // input for cmd/dartgen
+	}
+
 	// Allow overriding port and cache dir via env
 	if p := os.Getenv("ROXBOX_PORT"); p != "" {
 		port = p
 	}
+	// A listener from the parent, which sets the port (listenfd.go)
+	ln, err := inheritedListener()
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Before anything else grabs sockets or the cache (instance.go)
+	if ln == nil {
+		if err := checkPortFree(port); err != nil {
+			log.Fatal(err)
+		}
+	}
 	cacheDir = os.Getenv("ROXBOX_CACHE")
 	if cacheDir == "" {
-		cacheDir = filepath.Join(os.TempDir(), "roxbox_torrent")
+		cacheDir = defaultCacheDir()
 	}
 	_ = os.MkdirAll(cacheDir, 0755)
+	defer setupDaemon()()
+	initSealKey()
+	checkExecLocation()
 
 	// Init torrent client
-	cfg := torrent.NewDefaultClientConfig()
-	cfg.DataDir = cacheDir
-	cfg.DefaultStorage = storage.NewFileByInfoHash(cacheDir)
-	cfg.Seed = false // We're a pure leecher for streaming
-	cfg.EstablishedConnsPerTorrent = 80
-	cfg.HalfOpenConnsPerTorrent = 50
-	cfg.NoDHT = false
-	cfg.NoDefaultPortForwarding = true
-	// Sequential read optimisation: high connection count, fast unchoke
-	cfg.DisableIPv6 = false
-
-	var err error
-	client, err = torrent.NewClient(cfg)
+	applyDNS()        // -dns / ROXBOX_DNS for trackers (dns.go)
+	applyJitter()     // -jitter / ROXBOX_JITTER (jitter.go)
+	applyPipeline()   // -static-pipeline / ROXBOX_STATIC_PIPELINE (pipeline.go)
+	applyIOThrottle() // -io-throttle / ROXBOX_IO_THROTTLE (iothrottle.go)
+
+	client, err = torrent.NewClient(newClientConfig()) // heal.go rebuilds it
 	if err != nil {
 		log.Fatalf("torrent client: %v", err)
 	}
-	defer client.Close()
-
-	// HTTP routes
-	mux := http.NewServeMux()
-	mux.HandleFunc("/add",    handleAdd)    // POST  ?magnet=...
-	mux.HandleFunc("/status", handleStatus) // GET
-	mux.HandleFunc("/stream", handleStream) // GET  (video bytes)
-	mux.HandleFunc("/stop",   handleStop)   // POST
-	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(200)
-		fmt.Fprint(w, "OK")
-	})
-
-	addr := "127.0.0.1:" + port
+	defer func() { currentClient().Close() }()
+
+	loadSecrets()
+	loadTokens()
+	loadExtraTrackers()
+	loadBans()
+	startLimits()
+	startSelfHeal()
+	startStallWatch()
+	loadPeerCache()
+	startModules()
+	loadExports()
+	loadTelemetry()
+	go idleLoop()
+
+	mux := newMux()
+
+	addr := listenHost() + ":" + port
+	if ln != nil {
+		addr = ln.Addr().String()
+	}
 	log.Printf("RoxBox server listening on %s", addr)
 
-	srv := &http.Server{Addr: addr, Handler: mux}
+	srv := &http.Server{Addr: addr, Handler: withInstance(withTokens(withTracing(withRequestLog(withRecover(mux)))))}
 
 	// Graceful shutdown
 	go func() {
 		sig := make(chan os.Signal, 1)
-		signal.Notify(sig, syscall.SIGTERM, syscall.SIGINT)
-		<-sig
+		signal.Notify(sig, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP)
+		for s := range sig {
+			if s == syscall.SIGHUP {
+				reload() // graceful reload, streams keep going
+				continue
+			}
+			break
+		}
 		log.Println("Shutting down…")
 		srv.Close()
+		stopTray()
 	}()
 
-	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		log.Fatal(err)
+	serve := func() {
+		listen := srv.ListenAndServe
+		if ln != nil {
+			listen = func() error { return srv.Serve(ln) }
+		}
+		if err := listen(); err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}
+	if runService != nil && runService(serve, func() { srv.Close() }) {
+		return
 	}
+	if *desktopMode && runTray != nil {
+		go serve()
+		runTray(func() { srv.Close() }) // tray needs the main goroutine
+		return
+	}
+	serve()
+}
+
+// newMux registers the HTTP routes (also used by the self-test harness).
+func newMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/add",    withIdempotency(handleAdd))    // POST  ?magnet=...
+	mux.HandleFunc("/status", handleStatus) // GET
+	mux.HandleFunc("/status/wait", handleStatusWait) // GET ?state=ready&timeout=30s
+	mux.HandleFunc("/status/ws", handleStatusWS) // GET  (WebSocket, status deltas)
+	mux.HandleFunc("/info",   handleInfo)   // GET
+	mux.HandleFunc("/inspect", handleInspect) // GET ?magnet= (metadata only, file picker)
+	mux.HandleFunc("/parse",  handleParse)  // GET ?magnet= (decode and validate, adds nothing)
+	mux.HandleFunc("/mediainfo", handleMediaInfo) // GET ?supports=<codec,…>
+	mux.HandleFunc("/player/state", handlePlayerState) // POST ?state=playing|paused|buffering
+	mux.HandleFunc("/tee",    handleTee)    // GET | POST ?path= | DELETE
+	mux.HandleFunc("/export", withIdempotency(handleExport)) // GET | POST ?dest= | DELETE ?dest=
+	mux.HandleFunc("/session/", handleSession) // GET /session/{id}/swarm/export
+	mux.HandleFunc("/handoff", handleHandoff)  // GET (export) | POST (import)
+	mux.HandleFunc("/snapshot", handleSnapshot) // GET  (engine state for the app's saved state)
+	mux.HandleFunc("/restore", handleRestore)   // POST (that state back after process death)
+	mux.HandleFunc("/party/create", handlePartyCreate) // POST ?relay=&member= (coordinate a watch party)
+	mux.HandleFunc("/party/join", handlePartyJoin)     // POST ?url=&member=
+	mux.HandleFunc("/party/state", handlePartyState)   // GET | POST | DELETE [?party=]
+	mux.HandleFunc("/stream", handleStream) // GET  (video bytes) ?player=
+	mux.HandleFunc("/stream/profiles", handleStreamProfiles) // GET  (player streaming profiles)
+	mux.HandleFunc("/seek/nearest", handleSeekNearest) // GET ?offset=<bytes>
+	mux.HandleFunc("/torrents", handleTorrents)   // GET | POST (background add)
+	mux.HandleFunc("/torrents/", handleTorrent)   // GET /torrents/{hash}/status|stream, POST …/stop
+	mux.HandleFunc("/queue",  handleQueue)  // GET | POST (batch add) | DELETE ?id=
+	mux.HandleFunc("/trackers", handleTrackers) // GET | PUT (extra trackers for every torrent)
+	mux.HandleFunc("/bans",   handleBans)   // GET | POST | DELETE ?ip= (banned peers)
+	mux.HandleFunc(passkeyRoute, handlePasskeyAnnounce) // GET (the client's announces to trackers with a passkey)
+	mux.HandleFunc(passkeyScrapeRoute, handlePasskeyAnnounce) // GET (and scrapes)
+	mux.HandleFunc("/sessions", handleSessions) // GET  (every profile's sessions under the global limits)
+	mux.HandleFunc("/stop",   withIdempotency(handleStop))   // POST
+	mux.HandleFunc("/files",  handleFiles)  // GET  (every file; streamed one + companions marked)
+	mux.HandleFunc("/files/raw", handleFileRaw) // GET ?index=
+	mux.HandleFunc("/subtitles", handleSubtitles)  // GET  (the torrent's subtitle files)
+	mux.HandleFunc("/subtitles/", handleSubtitles) // GET  /subtitles/{index} (one as UTF-8 text)
+	mux.HandleFunc("/tree",   handleTree)   // GET ?path=&depth= (the files as a directory tree)
+	mux.HandleFunc("/select", handleSelect) // POST ?file=<index>|pattern=S01E05 (stream another file of the torrent)
+	mux.HandleFunc("/playlist.m3u", handlePlaylist) // GET  (M3U8 of the torrent's videos and audio, in episode order)
+	mux.HandleFunc("/download.zip", handleDownloadZip) // GET  (every file of the torrent as one archive)
+	mux.HandleFunc("/watermark", handleWatermark) // GET | PUT | DELETE
+	mux.HandleFunc("/add/url", handleAddURL) // POST  ?url=<page>[&selector=<regexp>]
+	mux.HandleFunc("/add/local", handleAddLocal) // POST  ?path=<downloaded video>
+	mux.HandleFunc("/profile/settings", handleProfileSettings) // GET | PUT
+	mux.HandleFunc("/profile/history",  handleProfileHistory)  // GET | DELETE
+	mux.HandleFunc("/secrets", handleSecrets) // GET | PUT ?name= | DELETE ?name=
+	mux.HandleFunc("/tokens", handleTokens)   // GET | POST | DELETE ?name= (API tokens for other devices)
+	mux.HandleFunc("/openapi.json", handleOpenAPI) // GET  (OpenAPI 3 spec of this API)
+	mux.HandleFunc("/capabilities", handleCapabilities) // GET  (optional features of this build)
+	mux.HandleFunc("/debug/loglevel", handleLogLevel) // GET | POST ?module=&level=
+	mux.HandleFunc("/debug/events", handleEvents) // GET ?request_id=&kind=&since=&limit=
+	mux.HandleFunc("/debug/priorities", handlePriorities) // GET  (piece priorities of the active file)
+	mux.HandleFunc("/debug/stats", handleDebugStats) // GET  (disk I/O throttle, request pipelines, runtime)
+	mux.HandleFunc("/debug/rangestats", handleRangeStats) // GET | DELETE (Range patterns per player)
+	mux.HandleFunc("/telemetry", handleTelemetry) // GET | PUT (opt-in)
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(200)
+		fmt.Fprint(w, "OK")
+	})
+	moduleRoutes(mux) // optional subsystems in this build (modules.go)
+	return mux
 }
 
-// ── POST /add?magnet=<uri> ────────────────────────────────────────────────────
+// ── POST /add?magnet=|url= | JSON | .torrent body (addrequest.go) ───────────
 func handleAdd(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "POST only", 405)
 		return
 	}
-	magnetURI := r.URL.Query().Get("magnet")
-	if magnetURI == "" {
-		_ = r.ParseForm()
-		magnetURI = r.FormValue("magnet")
+	sess := sessionFor(w, r)
+	if sess == nil {
+		return
+	}
+	req, snap, ok := readAddRequest(w, r) // addrequest.go
+	if !ok {
+		return
+	}
+	if req.Source == nil {
+		req.Source = moduleSource(sess, req, requestID(r)) // a debrid copy (modules.go)
 	}
-	if magnetURI == "" {
-		http.Error(w, "magnet param required", 400)
+	if req.Source != nil {
+		sess.addSource(w, r, req.Source) // httpsource.go
 		return
 	}
 
-	// Stop any active torrent
-	handleStopInternal()
+	opts := req.options(requestID(r))
+	id, status := sess.startOnce(opts, sess.requestAdd(req, snap))
 
-	mu.Lock()
-	status = StatusResponse{State: "loading", Progress: 0}
-	mu.Unlock()
+	resp := map[string]any{"status": status, "add_id": id}
+	if status == "exists" {
+		st := sess.snapshotStatus()
+		resp["info_hash"], resp["state"], resp["stream_url"] = opts.InfoHash, st.State, st.StreamURL
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
 
-	go func() {
-		t, err := client.AddMagnet(magnetURI)
+// requestAdd adds req's magnet or .torrent to the session's profile,
+// warm-started from snap.
+func (s *session) requestAdd(req addRequest, snap *SwarmSnapshot) func() (*torrent.Torrent, error) {
+	return func() (*torrent.Torrent, error) {
+		if req.Metainfo != nil {
+			t, err := s.profile.addMetainfo(req.Metainfo, req.Trackers...)
+			if err != nil {
+				return nil, fmt.Errorf("AddTorrent: %v", err)
+			}
+			importSwarm(t, snap)
+			addPeerHints(t, req) // peers.go
+			return t, nil
+		}
+		t, err := s.profile.addMagnet(req.Magnet, req.Trackers...)
 		if err != nil {
-			setError(fmt.Sprintf("AddMagnet: %v", err))
-			return
+			return nil, fmt.Errorf("AddMagnet: %v", err)
 		}
+		importSwarm(t, snap)
+		addPeerHints(t, req) // peers.go
+		return t, nil
+	}
+}
 
-		mu.Lock()
-		currentTorr = t
-		mu.Unlock()
+// addOptions tweak how a new session is brought up.
+type addOptions struct {
+	File     string  // display path to stream instead of the auto-picked file
+	Title    string  // selection hints (engine.SelectFile)
+	Episode  string
+	ResumeAt float64 // playback position (seconds) the app should seek to
+	Labels   map[string]string // caller's tags, echoed in /status
+	Policy   *streamPolicy     // checked after the profile's policy
+	RequestID string           // the request that started it (event log)
+	FileIndex *int             // file to stream by index; wins over File
+	InfoHash  string           // when known up front; "" disables dedup (addqueue.go)
+	Paused    bool             // fetch metadata only until the first read
+	Fallbacks []string         // magnets to fail over to (fallback.go)
+	Prio      streamPrio
+}
 
-		log.Println("Waiting for torrent info…")
-		<-t.GotInfo()
-		log.Printf("Got info: %s", t.Name())
+// streamPrio adjusts the streaming priorities for one add.
+type streamPrio struct {
+	Bulk      bool  // plain download: no head/tail boost
+	HeadBytes int64 // boosted at the start; 0 is 5% of the file
+	TailBytes int64 // boosted at the end; 0 is 1%
+}
 
-		// Pick the largest file (the video)
-		f := largestFile(t)
-		if f == nil {
-			setError("no video file found in torrent")
-			return
+// bringUp replaces the session's torrent with the one cmd adds and brings
+// it up for streaming. Runs on the session's add worker (addqueue.go); gives
+// up quietly once a newer add supersedes cmd.
+func (s *session) bringUp(cmd *addCmd) {
+	opts := cmd.opts
+	// Stop any active torrent
+	s.stop()
+	s.active()
+
+	s.mu.Lock()
+	s.status = StatusResponse{State: "loading", Progress: 0, ResumeAtSec: opts.ResumeAt, Labels: opts.Labels, AddID: cmd.id}
+	s.startTimings()
+	s.touch()
+	s.mu.Unlock()
+	s.event(opts.RequestID, "loading")
+	close(cmd.begun)
+
+	t, err := cmd.add()
+	if cmd.superseded() {
+		if err == nil {
+			releaseTorrent(t, s)
 		}
+		s.event(opts.RequestID, "superseded")
+		return
+	}
+	if err != nil {
+		s.setError(err.Error())
+		return
+	}
 
-		mu.Lock()
-		currentFile = f
-		mu.Unlock()
-
-		// Prioritise the first 5 % and last 1 % of the file for fast seeking
-		prioStart := f.Length() / 20 // 5%
-		prioEnd   := f.Length() / 100 // 1%
-
-		f.Download()
-
-		// Set sequential priority on the entire file
-		f.SetPriority(torrent.PiecePriorityNormal)
-		setPieceSequential(t, f, prioStart, prioEnd)
+	s.mu.Lock()
+	s.torr = t
+	s.prio = opts.Prio
+	s.status.InfoHash = t.InfoHash().HexString()
+	s.touch()
+	s.mu.Unlock()
+	if opts.Paused {
+		s.pauseUntilRead(t) // player.go
+	}
 
-		// Start stats loop
-		go statsLoop(t, f)
+	seedCachedPeers(t)
+	go peerCacheLoop(t)
 
-		mu.Lock()
-		status.State = "ready"
-		status.StreamURL = "http://127.0.0.1:" + port + "/stream"
-		mu.Unlock()
+	log.Println("Waiting for torrent info…")
+	t, ok := s.awaitInfo(cmd, t) // fails over to the add's fallbacks (fallback.go)
+	if !ok {
+		return
+	}
+	log.Printf("Got info: %s", t.Name())
+	s.markTiming("metadata")
+
+	// Pick the video: the requested file, a hinted episode/title, or
+	// the largest video
+	hint := engine.Hint{File: opts.File, Title: opts.Title, Episode: opts.Episode}
+	if i := opts.FileIndex; i != nil {
+		if *i >= len(t.Files()) {
+			s.setError(fmt.Sprintf("file_index %d out of range: the torrent has %d files", *i, len(t.Files())))
+			return
+		}
+		hint.File = t.Files()[*i].DisplayPath()
+	}
+	f, sel := engine.SelectFile(t, hint, s.profile.selectFilter())
+	if f == nil {
+		s.setError("no video file found in torrent: " + sel.Reason)
+		return
+	}
+	if err := s.profile.checkPolicy(t, f); err != nil {
+		s.stop()
+		s.setError(err.Error())
+		return
+	}
+	if opts.Policy != nil {
+		if err := opts.Policy.check(t, f, "request"); err != nil {
+			s.stop()
+			s.setError(err.Error())
+			return
+		}
+	}
+	s.profile.recordHistory(t, f)
 
-		log.Println("Stream ready at", "http://127.0.0.1:"+port+"/stream")
-	}()
+	prof := engine.ProfilePieces(t)
+	engine.ApplyPieceProfile(t, prof)
+	if prof.Warning != "" {
+		log.Println("WARNING:", prof.Warning)
+	}
 
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(map[string]string{"status": "loading"})
+	s.mu.Lock()
+	if s.torr != t { // stopped meanwhile
+		s.mu.Unlock()
+		return
+	}
+	s.file = f
+	s.pieces = prof
+	s.selection = sel
+	s.status.Private = engine.IsPrivate(t)
+	s.mu.Unlock()
+	log.Printf("Selected %s (%s)", f.DisplayPath(), sel.Reason)
+
+	s.prioritise(t, f) // datasaver.go
+
+	comps := engine.FindCompanions(t, f)
+	engine.PrioritiseCompanions(comps)
+	s.mu.Lock()
+	s.companions = comps
+	s.mu.Unlock()
+
+	resumeExports(t, f)
+	go s.watchFirstPiece(t, f)
+
+	// Start stats loop
+	go s.statsLoop(t, f)
+	go s.governFill(t, f) // bandwidth.go
+	kickLimits()          // limits.go
+
+	s.mu.Lock()
+	if s.torr != t {
+		s.mu.Unlock()
+		return
+	}
+	s.status.State = "ready"
+	s.status.StreamURL = s.streamURL()
+	s.status.DataSaver = s.profile.dataSaverSecs() > 0
+	s.touch()
+	s.mu.Unlock()
+
+	log.Println("Stream ready at", s.streamURL())
+	s.event(opts.RequestID, "ready: "+f.DisplayPath())
 }
 
 // ── GET /status ───────────────────────────────────────────────────────────────
 func handleStatus(w http.ResponseWriter, r *http.Request) {
-	mu.RLock()
-	s := status
-	mu.RUnlock()
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(s)
+	sess := sessionFor(w, r)
+	if sess == nil {
+		return
+	}
+	writeStatus(w, r, sess) // statusdelta.go
 }
 
 // ── GET /stream ───────────────────────────────────────────────────────────────
 // Serves the torrent file as a seekable HTTP stream (supports Range requests).
 func handleStream(w http.ResponseWriter, r *http.Request) {
-	mu.RLock()
-	f := currentFile
-	mu.RUnlock()
+	sess := sessionFor(w, r)
+	if sess == nil {
+		return
+	}
+	serveStream(w, r, sess)
+}
+
+// serveStream serves sess's file (/stream and /torrents/{hash}/stream).
+func serveStream(w http.ResponseWriter, r *http.Request, sess *session) {
+	if r.URL.Query().Has("file") && !selectByParam(w, r, sess) { // selectfile.go
+		return
+	}
+	prof := streamProfileFor(r) // streamprofiles.go
+	sess.mu.RLock()
+	t, f, local, src := sess.torr, sess.file, sess.local, sess.http
+	readahead := prof.readahead(sess.pieces)
+	pieceLen := sess.pieces.Length
+	sess.mu.RUnlock()
+	if local != nil {
+		recordRange(r, local.Size)
+		sess.active()
+		serveLocal(w, r, local.Path)
+		return
+	}
+	if src != nil {
+		recordRange(r, src.Size)
+		sess.active()
+		if src.ContentType != "" {
+			w.Header().Set("Content-Type", src.ContentType)
+		}
+		engine.ServeContent(w, r, src.Name, &sourceReader{src: src, ctx: r.Context()})
+		return
+	}
+	window, _, saver := sess.dataSaverWindow()
+	if saver {
+		readahead = min(readahead, max(window, pieceLen))
+	}
 
 	if f == nil {
 		http.Error(w, "no active torrent", 503)
 		return
 	}
-
-	reader := f.NewReader()
-	reader.SetReadahead(8 * 1024 * 1024) // 8 MB readahead
-	reader.SetResponsive()               // Sequential mode
-
-	name := f.DisplayPath()
-	// Guess MIME from extension
-	ext := strings.ToLower(filepath.Ext(name))
-	mime := "video/mp4"
-	switch ext {
-	case ".mkv":
-		mime = "video/x-matroska"
-	case ".avi":
-		mime = "video/x-msvideo"
-	case ".webm":
-		mime = "video/webm"
+	recordRange(r, f.Length()) // rangestats.go
+	if !sess.trickling() && !saver {
+		engine.PrioritiseTail(t, f, prof.TailPrefetch)
+	}
+	if prof.NoWait {
+		w = headerFlusher{w}
+	}
+	if wm := sess.watermarkSpec(); wm != nil {
+		serveWatermarked(w, r, sess, f, readahead, wm)
+		return
 	}
 
-	w.Header().Set("Content-Type", mime)
-	w.Header().Set("Accept-Ranges", "bytes")
-	w.Header().Set("Cache-Control", "no-cache")
+	debugf("reader", "open %s range=%q player=%s readahead=%d", f.DisplayPath(), r.Header.Get("Range"), prof.Name, readahead)
+	reader := sess.trackReader(engine.NewReader(f, readahead), readahead) // 8 MB unless the piece size calls for more
+	defer reader.Close()
+	defer debugf("reader", "close %s", f.DisplayPath())
 
-	// Use http.ServeContent for proper Range support + ETag
-	http.ServeContent(w, r, name, time.Time{}, reader)
+	var rs io.ReadSeeker = &teeReader{Reader: reader, sess: sess, reqID: requestID(r)}
+	if saver {
+		rs = &windowedReader{ReadSeeker: rs, sess: sess, ctx: r.Context()} // datasaver.go
+	}
+	engine.ServeContent(pace(w, reader, 2*pieceLen), r, f.DisplayPath(), rs) // backpressure.go
 }
 
 // ── POST /stop ────────────────────────────────────────────────────────────────
 func handleStop(w http.ResponseWriter, r *http.Request) {
-	handleStopInternal()
+	sess := sessionFor(w, r)
+	if sess == nil {
+		return
+	}
+	sess.supersede()
+	sess.stop()
+	sess.event(requestID(r), "stopped")
 	w.WriteHeader(200)
 	fmt.Fprint(w, "stopped")
 }
 
-func handleStopInternal() {
-	mu.Lock()
-	defer mu.Unlock()
-	if currentTorr != nil {
-		currentTorr.Drop()
-		currentTorr = nil
-		currentFile = nil
+func (s *session) stop() {
+	s.resetPlayerState()
+	s.stopTee()
+	s.mu.Lock()
+	s.flushTimings()
+	t := s.torr
+	s.torr = nil
+	s.file = nil
+	s.pieces = engine.PieceProfile{}
+	s.prio = streamPrio{}
+	s.selection = engine.Selection{}
+	s.companions = nil
+	s.local = nil
+	src := s.http
+	s.http = nil
+	s.status = StatusResponse{State: "idle"}
+	s.touch()
+	s.mu.Unlock()
+	if src != nil {
+		src.close()
 	}
-	status = StatusResponse{State: "idle"}
-}
-
-// ── Helpers ───────────────────────────────────────────────────────────────────
-
-func largestFile(t *torrent.Torrent) *torrent.File {
-	files := t.Files()
-	if len(files) == 0 {
-		return nil
-	}
-	videoExts := map[string]bool{
-		".mp4": true, ".mkv": true, ".avi": true,
-		".mov": true, ".wmv": true, ".webm": true,
-		".m4v": true, ".ts": true,
-	}
-	// Filter to video files only
-	var videos []*torrent.File
-	for _, f := range files {
-		ext := strings.ToLower(filepath.Ext(f.DisplayPath()))
-		if videoExts[ext] {
-			videos = append(videos, f)
-		}
-	}
-	if len(videos) == 0 {
-		// Fall back to all files
-		videos = files
+	if t != nil {
+		cancelExports(t.InfoHash().HexString())
+		releaseTorrent(t, s)
+		kickLimits() // a slot may be free
 	}
-	sort.Slice(videos, func(i, j int) bool {
-		return videos[i].Length() > videos[j].Length()
-	})
-	return videos[0]
 }
 
-// setPieceSequential boosts sequential priority on the file and
-// ultra-boosts the first and last chunks so seek + playback starts fast.
-func setPieceSequential(t *torrent.Torrent, f *torrent.File, prioStart, prioEnd int64) {
-	info   := t.Info()
-	pieceLen := int64(info.PieceLength)
-	if pieceLen == 0 {
-		return
-	}
-	fileOff  := f.Offset()
-	fileEnd  := fileOff + f.Length()
-	startEnd := fileOff + prioStart
-	tailStart := fileEnd - prioEnd
-
-	for i := 0; i < t.NumPieces(); i++ {
-		pieceStart := int64(i) * pieceLen
-		pieceEnd   := pieceStart + pieceLen
-		if pieceEnd <= fileOff || pieceStart >= fileEnd {
-			continue // outside our file
-		}
-		p := t.Piece(i)
-		if pieceStart < startEnd || pieceStart >= tailStart {
-			p.SetPriority(torrent.PiecePriorityNow) // start/end: highest
-		} else {
-			p.SetPriority(torrent.PiecePriorityNormal)
-		}
-	}
-}
+// ── Helpers ───────────────────────────────────────────────────────────────────
 
-func setError(msg string) {
-	mu.Lock()
-	status = StatusResponse{State: "error", Error: msg}
-	mu.Unlock()
+func (s *session) setError(msg string) {
+	s.mu.Lock()
+	s.status = StatusResponse{State: "error", Error: msg}
+	s.touch()
+	s.mu.Unlock()
+	s.event("", "error: "+msg)
 	log.Println("ERROR:", msg)
 }
 
-// statsLoop updates the global status struct every second.
-func statsLoop(t *torrent.Torrent, f *torrent.File) {
-	var lastBytes int64
-	for {
+// statsLoop updates the session's status struct every second.
+func (s *session) statsLoop(t *torrent.Torrent, f *torrent.File) {
+	defer guard()
+	var last engine.Sample
+	var shape engine.SwarmShape
+	var avail swarmAvailability
+	seeds := engine.NewSeedWatch(t) // super-seeders, single-seed swarms
+	for n := 0; ; n++ {
 		time.Sleep(time.Second)
-		mu.RLock()
-		if currentTorr != t {
-			mu.RUnlock()
+		s.mu.RLock()
+		if s.torr != t || s.file != f { // stopped, or switched to another file (selectfile.go)
+			s.mu.RUnlock()
 			return
 		}
-		mu.RUnlock()
-
-		stats     := t.Stats()
-		downloaded := stats.BytesReadUsefulData.Int64()
-		speed      := float64(downloaded-lastBytes) / 1024 // KB/s
-		lastBytes   = downloaded
-		pct         := float64(downloaded) / float64(f.Length()) * 100
-		if pct > 100 {
-			pct = 100
+		s.mu.RUnlock()
+
+		m := engine.Measure(t, f, last)
+		last = m
+		if n%swarmShapeEvery == 0 {
+			shape = seeds.Observe()
+			avail = s.availability(t, f)
 		}
 
-		mu.Lock()
-		status.Progress    = pct
-		status.DownloadMB  = float64(downloaded) / (1024 * 1024)
-		status.SpeedKBs    = speed
-		status.Peers       = stats.ActivePeers
-		if status.State != "error" {
-			if pct >= 3 {
-				status.State = "ready"
+		s.mu.Lock()
+		st := &s.status
+		st.Progress       = m.Progress
+		st.CompletedMB    = m.CompletedMB
+		st.DownloadMB     = m.DownloadMB
+		st.SpeedKBs       = m.SpeedKBs
+		st.Peers          = m.Peers
+		st.UploadedMB     = m.UploadedMB
+		st.UploadSpeedKBs = m.UploadSpeedKBs
+		st.Ratio          = m.Ratio
+		st.Seeding        = m.Seeding
+		st.SuperSeeding   = shape.SuperSeeders > 0
+		st.SwarmWarning   = swarmWarning(shape)
+		st.Availability, st.WindowAvailability = avail.swarm, avail.window
+		if st.State != "error" && !st.DataSaver && !st.Paused { // these never buffer ahead to ReadyPercent
+			if m.Progress >= engine.ReadyPercent {
+				st.State = "ready"
 			} else {
-				status.State = "loading"
+				st.State = "loading"
 			}
 		}
-		mu.Unlock()
+		ready := st.State == "ready"
+		s.touch()
+		s.mu.Unlock()
+		if ready {
+			s.markTiming("ready") // only the first transition is kept
+		}
 
 		log.Printf("[%s] %.1f%% | %.1f MB | %.0f KB/s | %d peers",
-			t.Name(), pct, float64(downloaded)/(1024*1024), speed, stats.ActivePeers)
+			t.Name(), m.Progress, m.DownloadMB, m.SpeedKBs, m.Peers)
 	}
 }
 
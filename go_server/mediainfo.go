@@ -0,0 +1,303 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/roxbox/torrent_server/engine"
+)
+
+// ── GET /mediainfo[?supports=<codec,…>] ───────────────────────────────────────
+// Container and codecs of the active file, read from its first pieces (the
+// ones streaming fetches first anyway), with codec_warnings for codecs the
+// device probably can't decode: DTS-HD or TrueHD audio that plays silent, AV1
+// without a hardware decoder. The app lists what the device decodes in
+// supports; without it a conservative default set is assumed. No ffmpeg is
+// needed: Matroska CodecIDs and MP4 sample entries are matched directly.
+
+const (
+	mediaProbeBytes   = 2 << 20
+	mediaProbeTimeout = 30 * time.Second
+)
+
+// defaultDecoders is what nearly every Android device plays.
+var defaultDecoders = []string{"h264", "hevc", "vp8", "vp9", "mpeg2", "aac", "ac3", "eac3", "mp3", "opus", "vorbis", "flac", "pcm"}
+
+var codecAdvice = map[string]string{
+	"dts":    "DTS audio: many phones can't decode it and play silence",
+	"dts-hd": "DTS-HD audio: rarely decodable on phones; prefer a release with AC-3 or AAC",
+	"truehd": "Dolby TrueHD audio: rarely decodable on phones; prefer a release with AC-3 or AAC",
+	"av1":    "AV1 video: needs a recent chipset for hardware decoding; software decoding may stutter",
+}
+
+type codecWarning struct {
+	Codec   string `json:"codec"`
+	Kind    string `json:"kind"` // "audio" | "video"
+	Message string `json:"message"`
+}
+
+type mediaInfo struct {
+	File          string         `json:"file"`
+	Container     string         `json:"container"` // "matroska" | "mp4" | "unknown"
+	Video         []string       `json:"video"`
+	Audio         []string       `json:"audio"`
+	CodecWarnings []codecWarning `json:"codec_warnings"`
+	ProbedBytes   int64          `json:"probed_bytes"`
+	Note          string         `json:"note,omitempty"`
+}
+
+func handleMediaInfo(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "GET only", 405)
+		return
+	}
+	sess := sessionFor(w, r)
+	if sess == nil {
+		return
+	}
+	sess.mu.RLock()
+	f := sess.file
+	readahead := sess.pieces.Readahead
+	sess.mu.RUnlock()
+	if f == nil {
+		http.Error(w, "no active torrent", 503)
+		return
+	}
+	sess.active()
+
+	ctx, cancel := context.WithTimeout(r.Context(), mediaProbeTimeout)
+	defer cancel()
+	reader := sess.trackReader(engine.NewReader(f, readahead), readahead)
+	defer reader.Close()
+	head := make([]byte, min(int64(mediaProbeBytes), f.Length()))
+	n, err := readFullContext(ctx, reader, head)
+	if n == 0 && err != nil {
+		http.Error(w, "reading the first pieces: "+err.Error(), 504)
+		return
+	}
+
+	info := probeMedia(head[:n])
+	info.File = f.DisplayPath()
+	info.ProbedBytes = int64(n)
+	if err != nil && info.Note == "" {
+		info.Note = "first pieces only partly downloaded; ask again for a fuller answer"
+	}
+	info.CodecWarnings = codecWarnings(info, parseSupports(r))
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(info)
+}
+
+// readFullContext fills b from r, stopping early when ctx is done.
+func readFullContext(ctx context.Context, r interface {
+	ReadContext(context.Context, []byte) (int, error)
+}, b []byte) (int, error) {
+	n := 0
+	for n < len(b) {
+		m, err := r.ReadContext(ctx, b[n:])
+		n += m
+		if errors.Is(err, io.EOF) {
+			return n, nil
+		} else if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// parseSupports collects ?supports= (comma-separated or repeated); nil when absent.
+func parseSupports(r *http.Request) map[string]bool {
+	vals := r.URL.Query()["supports"]
+	if len(vals) == 0 {
+		return nil
+	}
+	out := map[string]bool{}
+	for _, v := range vals {
+		for _, c := range strings.Split(v, ",") {
+			if c = strings.ToLower(strings.TrimSpace(c)); c != "" {
+				out[c] = true
+			}
+		}
+	}
+	return out
+}
+
+func codecWarnings(info mediaInfo, supports map[string]bool) []codecWarning {
+	if supports == nil {
+		supports = map[string]bool{}
+		for _, c := range defaultDecoders {
+			supports[c] = true
+		}
+	}
+	out := []codecWarning{}
+	add := func(kind string, codecs []string) {
+		for _, c := range codecs {
+			if supports[c] {
+				continue
+			}
+			msg := codecAdvice[c]
+			if msg == "" {
+				msg = c + " " + kind + ": not in the device's supported codecs"
+			}
+			out = append(out, codecWarning{Codec: c, Kind: kind, Message: msg})
+		}
+	}
+	add("video", info.Video)
+	add("audio", info.Audio)
+	return out
+}
+
+// probeMedia identifies the container and codecs in the head of a file.
+func probeMedia(head []byte) mediaInfo {
+	info := mediaInfo{Container: "unknown", Video: []string{}, Audio: []string{}}
+	seen := map[string]bool{}
+	add := func(kind, codec string) {
+		if codec == "" || seen[codec] {
+			return
+		}
+		seen[codec] = true
+		if kind == "video" {
+			info.Video = append(info.Video, codec)
+		} else {
+			info.Audio = append(info.Audio, codec)
+		}
+	}
+	switch {
+	case bytes.HasPrefix(head, []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		info.Container = "matroska"
+		for _, id := range matroskaCodecIDs(head) {
+			add(matroskaCodec(id))
+		}
+		if len(seen) == 0 {
+			info.Note = "no track entries in the first pieces"
+		}
+	case len(head) >= 8 && string(head[4:8]) == "ftyp":
+		info.Container = "mp4"
+		for i := 0; ; {
+			j := bytes.Index(head[i:], []byte("stsd"))
+			if j < 0 {
+				break
+			}
+			i += j + 4
+			if at := i + 12; at+4 <= len(head) {
+				add(mp4Codec(string(head[at : at+4])))
+			}
+		}
+		if !bytes.Contains(head, []byte("moov")) {
+			info.Note = "moov box isn't at the start of the file; codecs are known once the end is downloaded"
+		}
+	}
+	return info
+}
+
+// matroskaCodecIDs finds CodecID elements (ID 0x86, one-byte size, "A_…",
+// "V_…") without walking the full EBML tree.
+func matroskaCodecIDs(head []byte) []string {
+	var ids []string
+	for i := 0; i+2 < len(head); i++ {
+		if head[i] != 0x86 || head[i+1]&0x80 == 0 {
+			continue
+		}
+		n := int(head[i+1] & 0x7f)
+		if n < 3 || n > 32 || i+2+n > len(head) {
+			continue
+		}
+		id := head[i+2 : i+2+n]
+		if !(bytes.HasPrefix(id, []byte("A_")) || bytes.HasPrefix(id, []byte("V_"))) {
+			continue
+		}
+		printable := true
+		for _, c := range id {
+			if c < 0x20 || c > 0x7e {
+				printable = false
+				break
+			}
+		}
+		if printable {
+			ids = append(ids, string(id))
+		}
+	}
+	return ids
+}
+
+func matroskaCodec(id string) (kind, codec string) {
+	kind = "audio"
+	if strings.HasPrefix(id, "V_") {
+		kind = "video"
+	}
+	switch {
+	case id == "V_MPEG4/ISO/AVC":
+		return kind, "h264"
+	case id == "V_MPEGH/ISO/HEVC":
+		return kind, "hevc"
+	case id == "V_AV1":
+		return kind, "av1"
+	case id == "V_VP9":
+		return kind, "vp9"
+	case id == "V_VP8":
+		return kind, "vp8"
+	case id == "V_MPEG2":
+		return kind, "mpeg2"
+	case strings.HasPrefix(id, "A_AAC"):
+		return kind, "aac"
+	case id == "A_AC3":
+		return kind, "ac3"
+	case id == "A_EAC3":
+		return kind, "eac3"
+	case id == "A_DTS":
+		return kind, "dts"
+	case strings.HasPrefix(id, "A_DTS/"): // EXPRESS, LOSSLESS
+		return kind, "dts-hd"
+	case id == "A_TRUEHD":
+		return kind, "truehd"
+	case id == "A_OPUS":
+		return kind, "opus"
+	case id == "A_VORBIS":
+		return kind, "vorbis"
+	case id == "A_FLAC":
+		return kind, "flac"
+	case id == "A_MPEG/L3":
+		return kind, "mp3"
+	case strings.HasPrefix(id, "A_PCM"):
+		return kind, "pcm"
+	}
+	return kind, strings.ToLower(id[2:])
+}
+
+func mp4Codec(fourcc string) (kind, codec string) {
+	switch fourcc {
+	case "avc1", "avc3":
+		return "video", "h264"
+	case "hvc1", "hev1":
+		return "video", "hevc"
+	case "av01":
+		return "video", "av1"
+	case "vp09":
+		return "video", "vp9"
+	case "mp4a":
+		return "audio", "aac"
+	case "ac-3":
+		return "audio", "ac3"
+	case "ec-3":
+		return "audio", "eac3"
+	case "dtsc":
+		return "audio", "dts"
+	case "dtsh", "dtsl", "dtse":
+		return "audio", "dts-hd"
+	case "mlpa":
+		return "audio", "truehd"
+	case "Opus":
+		return "audio", "opus"
+	case "fLaC":
+		return "audio", "flac"
+	case ".mp3":
+		return "audio", "mp3"
+	}
+	return "", "" // subtitle and timecode tracks, or not a sample entry
+}
@@ -0,0 +1,192 @@
+// Package mobile is the gomobile-bindable facade over the engine, for apps
+// that run RoxBox in-process instead of exec'ing the sidecar binary (which
+// Android 10+ forbids from writable app directories).
+//
+// Only gomobile-friendly types appear in signatures: strings, numbers,
+// bool, error and the Status struct. The engine is a process-wide
+// singleton so platform-channel handlers can call the static methods
+// directly.
+//
+// Build the Android library with:
+//
+//	gomobile bind -target=android -javapkg=app.roxbox -o roxbox.aar ./mobile
+//
+// and the iOS framework with:
+//
+//	gomobile bind -target=ios -prefix=RBX -o Roxbox.xcframework ./mobile
+//
+// Nothing here execs external binaries, so the same facade works on iOS.
+// The host forwards NWPathMonitor updates to NetworkChanged and app
+// lifecycle events to EnterBackground / EnterForeground; while a
+// background task is held it can poll BackgroundWorkPending, and calls
+// BackgroundExpired when the task runs out.
+package mobile
+
+import (
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/roxbox/torrent_server/engine"
+)
+
+// Status mirrors engine.Status with bindable field types.
+type Status struct {
+	State       string // "idle" | "loading" | "ready" | "error"
+	Progress    float64
+	CompletedMB float64
+	DownloadMB  float64
+	SpeedKBs    float64
+	Peers       int
+	InfoHash    string
+	Name        string
+	Error       string
+	Network     string
+	Background  bool
+}
+
+var (
+	mu   sync.Mutex
+	eng  *engine.Engine
+	srv  *http.Server
+	addr string
+)
+
+// Start creates the engine with its data under dataDir and serves the
+// stream on 127.0.0.1:port (0 picks a free port; see StreamURL).
+func Start(dataDir string, port int) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if eng != nil {
+		return errors.New("already started")
+	}
+	e, err := engine.NewEngine(engine.Config{DataDir: dataDir})
+	if err != nil {
+		return err
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:"+strconv.Itoa(port))
+	if err != nil {
+		e.Close()
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/stream", e.Stream)
+	srv = &http.Server{Handler: mux}
+	go func(s *http.Server) {
+		if err := s.Serve(ln); err != nil && err != http.ErrServerClosed {
+			log.Printf("mobile: stream server: %v", err)
+		}
+	}(srv)
+	eng, addr = e, ln.Addr().String()
+	return nil
+}
+
+// AddMagnet replaces the current stream; poll GetStatus for progress.
+func AddMagnet(uri string) error {
+	e, err := running()
+	if err != nil {
+		return err
+	}
+	return e.AddMagnet(uri)
+}
+
+// GetStatus returns the current stream status ("idle" before Start).
+func GetStatus() *Status {
+	e, err := running()
+	if err != nil {
+		return &Status{State: "idle"}
+	}
+	s := e.Status()
+	return &Status{
+		State:       s.State,
+		Progress:    s.Progress,
+		CompletedMB: s.CompletedMB,
+		DownloadMB:  s.DownloadMB,
+		SpeedKBs:    s.SpeedKBs,
+		Peers:       s.Peers,
+		InfoHash:    s.InfoHash,
+		Name:        s.Name,
+		Error:       s.Error,
+		Network:     s.Network,
+		Background:  s.Background,
+	}
+}
+
+// StreamURL is the address to hand to the player, "" before Start.
+func StreamURL() string {
+	mu.Lock()
+	defer mu.Unlock()
+	if addr == "" {
+		return ""
+	}
+	return "http://" + addr + "/stream"
+}
+
+// Stop drops the current torrent but keeps the engine running.
+func Stop() {
+	if e, err := running(); err == nil {
+		e.Stop()
+	}
+}
+
+// NetworkChanged forwards the host's path-monitor state: "wifi",
+// "cellular", "wired", "other" or "none".
+func NetworkChanged(kind string) {
+	if e, err := running(); err == nil {
+		e.NetworkChanged(kind)
+	}
+}
+
+// EnterBackground records that the app went to the background. Downloading
+// goes on while the host holds a background task.
+func EnterBackground() {
+	if e, err := running(); err == nil {
+		e.SetBackground(true)
+	}
+}
+
+// BackgroundExpired pauses data download: the host's background task ran
+// out, or it has none.
+func BackgroundExpired() {
+	if e, err := running(); err == nil {
+		e.BackgroundExpired()
+	}
+}
+
+// EnterForeground resumes data download if it was paused.
+func EnterForeground() {
+	if e, err := running(); err == nil {
+		e.SetBackground(false)
+	}
+}
+
+// BackgroundWorkPending reports whether the engine still has a download
+// in progress; hosts can end their background task once it's false.
+func BackgroundWorkPending() bool {
+	e, err := running()
+	return err == nil && e.BackgroundWorkPending()
+}
+
+// Shutdown stops streaming and releases the engine and its listener.
+func Shutdown() {
+	mu.Lock()
+	defer mu.Unlock()
+	if eng == nil {
+		return
+	}
+	_ = srv.Close()
+	eng.Close()
+	eng, srv, addr = nil, nil, ""
+}
+
+func running() (*engine.Engine, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if eng == nil {
+		return nil, errors.New("engine not started")
+	}
+	return eng, nil
+}
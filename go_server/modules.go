@@ -0,0 +1,94 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/anacrolix/torrent"
+)
+
+// ── Optional subsystems ───────────────────────────────────────────────────────
+// Subsystems that a lean, streaming-only build can do without sit in files
+// behind build tags and plug themselves in from init: their routes, API
+// docs, startup work and /capabilities entry. Nothing else refers to them,
+// so leaving a file out of the build removes the feature cleanly.
+//
+//	go build .                    full build
+//	go build -tags minimal .      streaming only (binary size matters in an APK)
+//	go build -tags no_rss .       leave out single subsystems
+//
+// Subsystems and their tags:
+//
+//	rss.go      RSS watcher           no_rss
+//	tracker.go  LAN tracker           no_tracker
+//	debrid.go   debrid service        no_debrid
+//	geoip.go    near-peer preference  no_geoip
+//	relay.go    remote control relay  no_relay
+//	tray.go     desktop tray icon     opt-in with tray (never in minimal)
+//
+// New optional subsystems (casting, HLS, search, WebDAV) follow the same
+// pattern: `//go:build !minimal && !no_<name>` and a registerModule call.
+
+type module struct {
+	Name   string
+	Routes func(mux *http.ServeMux)
+	Docs   []apiRoute
+	Start  func()                                                         // after the torrent client is up
+	Added  func(t *torrent.Torrent)                                       // every torrent the server adds
+	Source func(s *session, req addRequest, requestID string) *httpSource // an /add to stream over HTTP instead; nil: P2P
+	Probe  func() capability                                              // default: compiled and enabled
+}
+
+var modules []module
+
+func registerModule(m module) {
+	modules = append(modules, m)
+	if m.Probe == nil {
+		m.Probe = func() capability { return capability{Compiled: true, Enabled: true} }
+	}
+	capabilityProbes[m.Name] = m.Probe
+}
+
+func moduleRoutes(mux *http.ServeMux) {
+	for _, m := range modules {
+		if m.Routes != nil {
+			m.Routes(mux)
+		}
+	}
+}
+
+func startModules() {
+	for _, m := range modules {
+		if m.Start != nil {
+			m.Start()
+		}
+	}
+}
+
+func moduleAdded(t *torrent.Torrent) {
+	for _, m := range modules {
+		if m.Added != nil {
+			m.Added(t)
+		}
+	}
+}
+
+// moduleSource asks the modules for an HTTP source to stream req from.
+func moduleSource(s *session, req addRequest, requestID string) *httpSource {
+	for _, m := range modules {
+		if m.Source != nil {
+			if src := m.Source(s, req, requestID); src != nil {
+				return src
+			}
+		}
+	}
+	return nil
+}
+
+// allAPIDocs is apiDocs plus the routes of the modules in this build.
+func allAPIDocs() []apiRoute {
+	out := append([]apiRoute{}, apiDocs...)
+	for _, m := range modules {
+		out = append(out, m.Docs...)
+	}
+	return out
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// ── Native-library packaging (Android W^X) ────────────────────────────────────
+// Android 10+ (targetSdk >= 29) refuses to exec files from writable app
+// directories. Packaging the server as jniLibs/<abi>/libroxbox.so makes the
+// installer extract it into the read-only native library dir, where exec is
+// allowed:
+//
+//	GOOS=android GOARCH=arm64 CGO_ENABLED=0 \
+//	  go build -buildmode=pie -ldflags="-s -w" -o jniLibs/arm64-v8a/libroxbox.so .
+//
+// The app then starts applicationInfo.nativeLibraryDir + "/libroxbox.so"
+// (with android:extractNativeLibs="true"). The file is an ordinary PIE
+// executable with the usual main entry; only the name follows the .so
+// convention.
+
+// writableAppDirs are path fragments of app-private data directories,
+// which are mounted noexec for modern target SDKs.
+var writableAppDirs = []string{"/data/data/", "/data/user/", "/sdcard/", "/storage/emulated/"}
+
+// checkExecLocation warns when we were started from a location Android
+// will stop allowing exec from, so the app can switch to the jniLibs build.
+func checkExecLocation() {
+	exe, err := os.Executable()
+	if err != nil {
+		return
+	}
+	exe, _ = filepath.EvalSymlinks(exe)
+	for _, d := range writableAppDirs {
+		if strings.Contains(exe, d) && !strings.Contains(exe, "/lib/") {
+			log.Printf("WARNING: running from writable app dir %s; Android 10+ blocks exec here — ship libroxbox.so in jniLibs instead", filepath.Dir(exe))
+			return
+		}
+	}
+}
+
+// execError annotates a failed exec of an external helper (e.g. ffmpeg)
+// when the cause is the platform's exec restriction rather than a missing
+// or broken binary, and logs it once per call site.
+func execError(path string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, syscall.EACCES) || errors.Is(err, syscall.EPERM) {
+		err = fmt.Errorf("exec %s denied (%w): the OS forbids running binaries from this directory; use a copy in the app's native library dir", path, err)
+		log.Println("ERROR:", err)
+	}
+	return err
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ── Network simulator ─────────────────────────────────────────────────────────
+// A shaping TCP proxy placed between the self-test seeder and our client, so
+// piece-priority and stall-recovery behaviour can be measured against a
+// reproducible "bad network":
+//
+//	torrent_server -netsim rate=300,latency=120ms,loss=2,seed=7 selftest-e2e
+//
+// rate is per-peer throughput in KB/s (0 = unlimited), latency is one-way
+// delay, loss is the percentage of 16 KiB segments that need a
+// retransmission (costing an RTO and blocking what follows, like TCP).
+// The same seed always drops the same segments.
+
+var netSimFlag = flag.String("netsim", "", "simulate the self-test network: rate=KB/s,latency=dur,loss=%,seed=n")
+
+const simSegment = 16 << 10
+
+type netSim struct {
+	RateKBs float64
+	Latency time.Duration
+	LossPct float64
+	Seed    int64
+}
+
+func parseNetSim(s string) (*netSim, error) {
+	if s == "" {
+		return nil, nil
+	}
+	n := &netSim{Seed: 1}
+	for _, kv := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(kv), "=")
+		if !ok {
+			return nil, fmt.Errorf("netsim: %q is not key=value", kv)
+		}
+		var err error
+		switch k {
+		case "rate":
+			n.RateKBs, err = strconv.ParseFloat(v, 64)
+		case "latency":
+			if n.Latency, err = time.ParseDuration(v); err != nil {
+				var ms float64
+				ms, err = strconv.ParseFloat(v, 64) // bare number: milliseconds
+				n.Latency = time.Duration(ms * float64(time.Millisecond))
+			}
+		case "loss":
+			n.LossPct, err = strconv.ParseFloat(v, 64)
+		case "seed":
+			n.Seed, err = strconv.ParseInt(v, 10, 64)
+		default:
+			return nil, fmt.Errorf("netsim: unknown key %q", k)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("netsim: bad %s: %v", k, err)
+		}
+	}
+	if n.RateKBs < 0 || n.Latency < 0 || n.LossPct < 0 || n.LossPct >= 100 {
+		return nil, fmt.Errorf("netsim: values out of range")
+	}
+	return n, nil
+}
+
+func (n *netSim) String() string {
+	rate := "unlimited"
+	if n.RateKBs > 0 {
+		rate = fmt.Sprintf("%g KB/s", n.RateKBs)
+	}
+	return fmt.Sprintf("rate %s, latency %s, loss %g%%, seed %d", rate, n.Latency, n.LossPct, n.Seed)
+}
+
+// rto is the stall a lost segment costs.
+func (n *netSim) rto() time.Duration {
+	return max(200*time.Millisecond, 2*n.Latency)
+}
+
+type simProxy struct {
+	sim    *netSim
+	target string
+	ln     net.Listener
+
+	mu    sync.Mutex
+	conns int64
+	open  []net.Conn
+}
+
+// startSimProxy forwards connections on a loopback port to target through
+// the simulated link.
+func startSimProxy(target string, sim *netSim) (*simProxy, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	p := &simProxy{sim: sim, target: target, ln: ln}
+	go p.serve()
+	return p, nil
+}
+
+func (p *simProxy) Addr() string { return p.ln.Addr().String() }
+
+func (p *simProxy) Close() {
+	_ = p.ln.Close()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, c := range p.open {
+		_ = c.Close()
+	}
+}
+
+func (p *simProxy) serve() {
+	for {
+		in, err := p.ln.Accept()
+		if err != nil {
+			return
+		}
+		out, err := net.Dial("tcp", p.target)
+		if err != nil {
+			in.Close()
+			continue
+		}
+		p.mu.Lock()
+		p.conns++
+		// Each direction of each connection gets its own deterministic
+		// loss sequence.
+		seed := p.sim.Seed*1000 + p.conns*2
+		p.open = append(p.open, in, out)
+		p.mu.Unlock()
+		go p.shape(out, in, rand.New(rand.NewSource(seed)))
+		go p.shape(in, out, rand.New(rand.NewSource(seed+1)))
+	}
+}
+
+type simChunk struct {
+	b   []byte
+	due time.Time
+}
+
+// shape copies src to dst applying latency, loss and the rate limit.
+func (p *simProxy) shape(dst, src net.Conn, rng *rand.Rand) {
+	ch := make(chan simChunk, 64)
+	go func() {
+		defer close(ch)
+		for {
+			buf := make([]byte, simSegment)
+			n, err := src.Read(buf)
+			if n > 0 {
+				due := time.Now().Add(p.sim.Latency)
+				if rng.Float64()*100 < p.sim.LossPct {
+					due = due.Add(p.sim.rto())
+				}
+				ch <- simChunk{buf[:n], due}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	var next time.Time // when the link is free again
+	broken := false
+	for c := range ch {
+		if broken {
+			continue // drain until the reader sees src close
+		}
+		time.Sleep(time.Until(c.due))
+		if p.sim.RateKBs > 0 {
+			if now := time.Now(); next.Before(now) {
+				next = now
+			}
+			next = next.Add(time.Duration(float64(len(c.b)) / (p.sim.RateKBs * 1024) * float64(time.Second)))
+			time.Sleep(time.Until(next))
+		}
+		if _, err := dst.Write(c.b); err != nil {
+			broken = true
+			_ = src.Close()
+		}
+	}
+	if tc, ok := dst.(*net.TCPConn); ok {
+		_ = tc.CloseWrite()
+	}
+}
@@ -0,0 +1,626 @@
+package main
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ── OpenAPI ───────────────────────────────────────────────────────────────────
+// apiDocs describes every control endpoint; /openapi.json turns it into an
+// OpenAPI 3 document, deriving JSON schemas from the Go types the handlers
+// actually encode. Add an entry here whenever a route is added in main().
+
+type apiParam struct {
+	Name     string
+	Desc     string
+	Required bool
+	Type     string // "string" (default) | "number" | "integer" | "boolean"
+	Enum     []string
+}
+
+type apiOp struct {
+	Method  string
+	Summary string
+	Params  []apiParam
+	Body    any    // JSON request body type, nil for none
+	Resp    any    // JSON 200 response type, nil for text/plain
+	RawResp string // non-JSON 200 content type (e.g. video)
+
+	WebSocket bool // GET upgrades; Resp is the message type
+
+	OptionalBody bool // Body is an alternative to the params
+}
+
+type apiRoute struct {
+	Path string
+	Ops  []apiOp
+}
+
+var apiDocs = []apiRoute{
+	{"/add", []apiOp{{Method: "POST", Summary: "Start streaming a magnet, .torrent or direct video URL (replaces the profile's session); params, a JSON body, a raw application/x-bittorrent body or a multipart form with a torrent file part. Answers status loading, superseded (a newer add won) or exists (the session already has this torrent and file; info_hash, state and stream_url included) and the add_id",
+		Params: []apiParam{
+			{Name: "magnet", Desc: "magnet URI (required unless url, the JSON body or an uploaded .torrent names the torrent)"},
+			{Name: "url", Desc: "http(s) URL of a .torrent file for the server to fetch, or of a video to stream through the server's cache"},
+			{Name: "cookie", Desc: "Cookie header for fetching url (else the stored cookie:<domain> secret)"},
+			{Name: "authorization", Desc: "Authorization header for fetching url (else the stored authorization:<domain> secret)"},
+			{Name: "tracker", Desc: "extra tracker URL; repeatable"},
+			{Name: "peer", Desc: "host:port of a peer to dial, like a magnet's x.pe; repeatable"},
+			{Name: "fallback", Desc: "another magnet for the same title, switched to while the ones before it stall without peers or metadata; repeatable, in order"},
+			{Name: "swarm", Desc: "swarm snapshot (JSON or base64) to warm-start from"},
+			{Name: "file", Desc: "display path of the file to stream (overrides auto-selection)"},
+			{Name: "title", Desc: "title hint for file selection"},
+			{Name: "episode", Desc: "episode hint for file selection, e.g. S02E05, or 105 in an absolute-numbered pack"},
+		},
+		Body: addRequestJSON{}, OptionalBody: true,
+		Resp: map[string]any{}}}},
+	{"/add/url", []apiOp{{Method: "POST", Summary: "Resolve a page, magnet or .torrent URL and start streaming it",
+		Params: []apiParam{
+			{Name: "url", Desc: "page, magnet or .torrent URL", Required: true},
+			{Name: "selector", Desc: "extra link regexp, tried before the defaults"},
+			{Name: "cookie", Desc: "Cookie header for fetching the page and the .torrent (else the stored cookie:<domain> secret)"},
+			{Name: "authorization", Desc: "Authorization header for fetching the page and the .torrent (else the stored authorization:<domain> secret)"},
+		},
+		Resp: map[string]any{}}}},
+	{"/add/local", []apiOp{{Method: "POST", Summary: "Stream a video already on disk through /stream and /files (replaces the profile's session)",
+		Params: []apiParam{{Name: "path", Desc: "absolute path of the video file", Required: true}},
+		Resp:   map[string]any{}}}},
+	{"/status", []apiOp{{Method: "GET", Summary: "Session status; with changed_since, only the fields changed since that X-Status-Seq",
+		Params: []apiParam{{Name: "changed_since", Desc: "sequence number from a previous X-Status-Seq (response is then a statusDelta)", Type: "integer"}},
+		Resp:   StatusResponse{}}}},
+	{"/status/ws", []apiOp{{Method: "GET", Summary: "WebSocket pushing a statusDelta per change; first message brings the client up to date",
+		Params: []apiParam{{Name: "changed_since", Desc: "last seq seen, when reconnecting", Type: "integer"}},
+		Resp:   statusDelta{}, WebSocket: true}}},
+	{"/status/wait", []apiOp{{Method: "GET", Summary: "Long-poll until the status matches; X-Condition-Met tells whether it did",
+		Params: []apiParam{
+			{Name: "state", Desc: "comma-separated states to wait for, e.g. ready"},
+			{Name: "min_progress", Desc: "minimum progress %", Type: "number"},
+			{Name: "min_peers", Type: "integer"},
+			{Name: "timeout", Desc: "e.g. 30s (max 2m)"},
+		},
+		Resp: StatusResponse{}}}},
+	{"/info", []apiOp{{Method: "GET", Summary: "Torrent and piece layout details", Resp: InfoResponse{}}}},
+	{"/mediainfo", []apiOp{{Method: "GET", Summary: "Container and codecs of the active file from its first pieces, with codec_warnings for codecs the device likely can't decode",
+		Params: []apiParam{{Name: "supports", Desc: "codecs the device decodes, comma-separated (e.g. h264,hevc,aac,ac3); a conservative default when absent"}},
+		Resp:   mediaInfo{}}}},
+	{"/stream", []apiOp{{Method: "GET", Summary: "Selected file bytes; supports Range requests (not with a watermark)",
+		Params: []apiParam{
+			{Name: "t", Desc: "start position in seconds (watermarked streams only)", Type: "number"},
+			{Name: "player", Desc: "streaming profile (see /stream/profiles); detected from the User-Agent when absent"},
+			{Name: "file", Desc: "index from /files: switch the session to that file first, as /select", Type: "integer"},
+		},
+		RawResp: "video/*"}}},
+	{"/stream/profiles", []apiOp{{Method: "GET", Summary: "Player streaming profiles (readahead, tail prefetch, nowait) and the one this client is detected as",
+		Resp: streamProfilesResponse{}}}},
+	{"/seek/nearest", []apiOp{{Method: "GET", Summary: "Buffered byte of the active file closest to offset, for snap-to-buffered seeking",
+		Params: []apiParam{{Name: "offset", Required: true, Type: "integer", Desc: "byte offset in the file"}},
+		Resp:   seekNearest{}}}},
+	{"/torrents", []apiOp{
+		{Method: "GET", Summary: "Every torrent session of the profile, primary first", Resp: []torrentEntry{}},
+		{Method: "POST", Summary: "Add a magnet in a background session, next to the primary one; same params as /add. Answers info_hash, stream_url, add_id and status loading, superseded or exists",
+			Params: []apiParam{
+				{Name: "magnet", Desc: "magnet URI (required unless url, the JSON body or an uploaded .torrent names the torrent)"},
+				{Name: "url", Desc: "http(s) URL of a .torrent file for the server to fetch"},
+				{Name: "cookie", Desc: "Cookie header for fetching url (else the stored cookie:<domain> secret)"},
+				{Name: "authorization", Desc: "Authorization header for fetching url (else the stored authorization:<domain> secret)"},
+				{Name: "tracker", Desc: "extra tracker URL; repeatable"},
+				{Name: "peer", Desc: "host:port of a peer to dial, like a magnet's x.pe; repeatable"},
+				{Name: "swarm", Desc: "swarm snapshot (JSON or base64) to warm-start from"},
+				{Name: "file", Desc: "display path of the file to stream (overrides auto-selection)"},
+				{Name: "title", Desc: "title hint for file selection"},
+				{Name: "episode", Desc: "episode hint for file selection, e.g. S02E05, or 105 in an absolute-numbered pack"},
+			},
+			Body: addRequestJSON{}, OptionalBody: true,
+			Resp: map[string]any{}}}},
+	{"/torrents/{hash}/status", []apiOp{{Method: "GET", Summary: "Status of the session holding hash",
+		Params: []apiParam{
+			{Name: "hash", Desc: "infohash", Required: true},
+			{Name: "changed_since", Desc: "sequence number from a previous X-Status-Seq (response is then a statusDelta)", Type: "integer"},
+		},
+		Resp: StatusResponse{}}}},
+	{"/torrents/{hash}/stream", []apiOp{{Method: "GET", Summary: "Selected file bytes of the session holding hash; supports Range requests",
+		Params: []apiParam{
+			{Name: "hash", Desc: "infohash", Required: true},
+			{Name: "player", Desc: "streaming profile, as for /stream"},
+			{Name: "file", Desc: "index from /torrents/{hash}/files: switch to that file first, as /torrents/{hash}/select", Type: "integer"},
+		},
+		RawResp: "video/*"}}},
+	{"/torrents/{hash}/stop", []apiOp{{Method: "POST", Summary: "Stop the session holding hash; a background session is removed",
+		Params: []apiParam{{Name: "hash", Desc: "infohash", Required: true}}}}},
+	{"/torrents/{hash}/peers", []apiOp{{Method: "POST", Summary: "Dial known peers for the session holding hash; a JSON body {\"peers\": [\"host:port\", …]} or repeated peer params. Answers added and posted counts",
+		Params: []apiParam{
+			{Name: "hash", Desc: "infohash", Required: true},
+			{Name: "peer", Desc: "host:port of a peer; repeatable"},
+		},
+		Resp: map[string]int{}}}},
+	{"/torrents/{hash}/export.torrent", []apiOp{{Method: "GET", Summary: "The session's .torrent (bencoded metainfo), once its metadata is resolved; 409 before; needs a full token, as it carries tracker passkeys",
+		Params:  []apiParam{{Name: "hash", Desc: "infohash", Required: true}},
+		RawResp: "application/x-bittorrent"}}},
+	{"/torrents/{hash}/files", []apiOp{{Method: "GET", Summary: "Every file of the session holding hash, as /files",
+		Params: []apiParam{{Name: "hash", Desc: "infohash", Required: true}},
+		Resp:   filesResponse{}}}},
+	{"/torrents/{hash}/tree", []apiOp{{Method: "GET", Summary: "The files of the session holding hash as a directory tree, as /tree",
+		Params: []apiParam{
+			{Name: "hash", Desc: "infohash", Required: true},
+			{Name: "path", Desc: "directory to answer instead of the whole torrent"},
+			{Name: "depth", Desc: "levels to list, as for /tree", Type: "integer"},
+		},
+		Resp: treeDir{}}}},
+	{"/torrents/{hash}/files/raw", []apiOp{{Method: "GET", Summary: "A file of the session holding hash by index; supports Range requests",
+		Params: []apiParam{
+			{Name: "hash", Desc: "infohash", Required: true},
+			{Name: "index", Desc: "index from /torrents/{hash}/files", Required: true, Type: "integer"},
+		},
+		RawResp: "application/octet-stream"}}},
+	{"/torrents/{hash}/playlist.m3u", []apiOp{{Method: "GET", Summary: "M3U8 playlist of the session holding hash, as /playlist.m3u",
+		Params:  []apiParam{{Name: "hash", Desc: "infohash", Required: true}},
+		RawResp: "audio/x-mpegurl"}}},
+	{"/torrents/{hash}/subtitles", []apiOp{{Method: "GET", Summary: "The subtitle files of the session holding hash, as /subtitles",
+		Params: []apiParam{{Name: "hash", Desc: "infohash", Required: true}},
+		Resp:   subtitlesResponse{}}}},
+	{"/torrents/{hash}/subtitles/{index}", []apiOp{{Method: "GET", Summary: "A subtitle file of the session holding hash as UTF-8 text, as /subtitles/{index}",
+		Params: []apiParam{
+			{Name: "hash", Desc: "infohash", Required: true},
+			{Name: "index", Desc: "index from /torrents/{hash}/subtitles", Required: true, Type: "integer"},
+		}}}},
+	{"/torrents/{hash}/subtitles/{index}.vtt", []apiOp{{Method: "GET", Summary: "A .srt or .vtt subtitle of the session holding hash as WebVTT, as /subtitles/{index}.vtt",
+		Params: []apiParam{
+			{Name: "hash", Desc: "infohash", Required: true},
+			{Name: "index", Desc: "index from /torrents/{hash}/subtitles", Required: true, Type: "integer"},
+		},
+		RawResp: "text/vtt"}}},
+	{"/torrents/{hash}/subtitles/embedded", []apiOp{{Method: "GET", Summary: "The text subtitle tracks inside the Matroska video of the session holding hash, as /subtitles/embedded",
+		Params: []apiParam{{Name: "hash", Desc: "infohash", Required: true}},
+		Resp:   embeddedSubtitlesResponse{}}}},
+	{"/torrents/{hash}/subtitles/embedded/{track}", []apiOp{{Method: "GET", Summary: "An embedded subtitle track of the session holding hash as a sidecar file, as /subtitles/embedded/{track}",
+		Params: []apiParam{
+			{Name: "hash", Desc: "infohash", Required: true},
+			{Name: "track", Desc: "track from /torrents/{hash}/subtitles/embedded", Required: true, Type: "integer"},
+			{Name: "wait", Type: "boolean", Desc: "true: wait for every block of the track"},
+		}}}},
+	{"/torrents/{hash}/subtitles/embedded/{track}.vtt", []apiOp{{Method: "GET", Summary: "An embedded subtitle track of the session holding hash as WebVTT, as /subtitles/embedded/{track}.vtt",
+		Params: []apiParam{
+			{Name: "hash", Desc: "infohash", Required: true},
+			{Name: "track", Desc: "track from /torrents/{hash}/subtitles/embedded", Required: true, Type: "integer"},
+			{Name: "wait", Type: "boolean", Desc: "true: wait for every block of the track"},
+		},
+		RawResp: "text/vtt"}}},
+	{"/torrents/{hash}/download.zip", []apiOp{{Method: "GET", Summary: "Every file of the session holding hash as a ZIP archive, as /download.zip",
+		Params:  []apiParam{{Name: "hash", Desc: "infohash", Required: true}},
+		RawResp: "application/zip"}}},
+	{"/torrents/{hash}/select", []apiOp{{Method: "POST", Summary: "Stream another file of the session holding hash, as /select",
+		Params: []apiParam{
+			{Name: "hash", Desc: "infohash", Required: true},
+			{Name: "file", Desc: "index from /torrents/{hash}/files", Type: "integer"},
+			{Name: "pattern", Desc: "episode, as for /select"},
+		},
+		Resp: InfoResponse{}}}},
+	{"/parse", []apiOp{{Method: "GET", Summary: "Decode and check a magnet without adding it: infohash, name, trackers, peer hints and what /add would reject",
+		Params: []apiParam{{Name: "magnet", Desc: "magnet URI", Required: true}},
+		Resp:   parseResponse{}}}},
+	{"/inspect", []apiOp{{Method: "GET", Summary: "Fetch a magnet's metadata only and list its files, without downloading data or touching the session",
+		Params: []apiParam{
+			{Name: "magnet", Desc: "magnet URI", Required: true},
+			{Name: "tracker", Desc: "extra tracker URL; repeatable"},
+			{Name: "title", Desc: "title hint, as for /add, for the selected file"},
+			{Name: "episode", Desc: "episode hint, as for /add, for the selected file"},
+			{Name: "timeout", Desc: "how long to wait for metadata, e.g. 30s (default 60s, max 2m)"},
+		},
+		Resp: inspectResponse{}}}},
+	{"/queue", []apiOp{
+		{Method: "GET", Summary: "The profile's download queue: policy, concurrency and items with their position and session status", Resp: queueResponse{}},
+		{Method: "POST", Summary: "Queue torrents to download in background sessions, in policy order with at most concurrency running; a JSON body or repeated magnet params",
+			Params: []apiParam{
+				{Name: "magnet", Desc: "magnet URI to queue; repeatable"},
+				{Name: "policy", Desc: "order queued items start in", Enum: []string{"fifo", "priority"}},
+				{Name: "concurrency", Desc: "downloads running at once (default 1)", Type: "integer"},
+			},
+			Body: queueAddRequest{}, OptionalBody: true, Resp: queueResponse{}},
+		{Method: "DELETE", Summary: "Remove an item (stopping its download), or without id every item not downloading",
+			Params: []apiParam{{Name: "id", Desc: "queue item id", Type: "integer"}}, Resp: queueResponse{}},
+	}},
+	{"/trackers", []apiOp{
+		{Method: "GET", Summary: "Extra trackers added to every torrent: the configured list and the list file's", Resp: trackersResponse{}},
+		{Method: "PUT", Summary: "Replace the configured extra trackers; running torrents get them too", Body: trackersConfig{}, Resp: trackersResponse{}},
+	}},
+	{"/bans", []apiOp{
+		{Method: "GET", Summary: "Banned peers, newest first: the client's bans for corrupt data or protocol violations and manual ones", Resp: bansState{}},
+		{Method: "POST", Summary: "Ban a peer IP (a week unless duration says otherwise) and close its connections", Body: banRequest{}, Resp: bansState{}},
+		{Method: "DELETE", Summary: "Lift a ban", Params: []apiParam{{Name: "ip", Required: true}}, Resp: bansState{}},
+	}},
+	{"/sessions", []apiOp{
+		{Method: "GET", Summary: "Every profile's sessions under the global limits (-max-active, -max-conns): downloading, queued with their position, paused, complete", Resp: sessionsResponse{}},
+	}},
+	{"/watermark", []apiOp{
+		{Method: "GET", Summary: "The session's burned-in text overlay", Resp: watermarkSpec{}},
+		{Method: "PUT", Summary: "Burn a text overlay into /stream (and /files/raw of the streamed file) via ffmpeg; font_file names a font in the -fonts directory", Body: watermarkSpec{}, Resp: watermarkSpec{}},
+		{Method: "DELETE", Summary: "Remove the overlay"},
+	}},
+	{"/files", []apiOp{{Method: "GET", Summary: "Every file of the session's torrent with its type and progress; the streamed one has role video, its paired subtitle/audio files their kind", Resp: filesResponse{}}}},
+	{"/files/raw", []apiOp{{Method: "GET", Summary: "A file of the torrent by index; supports Range requests (the streamed file is re-encoded without them while a watermark is set)",
+		Params: []apiParam{{Name: "index", Desc: "index from /files", Required: true, Type: "integer"}}, RawResp: "application/octet-stream"}}},
+	{"/subtitles", []apiOp{{Method: "GET", Summary: "The subtitle files of the torrent (srt, ass, ssa, vtt, sub, idx), the streamed video's companions first", Resp: subtitlesResponse{}}}},
+	{"/subtitles/{index}", []apiOp{{Method: "GET", Summary: "A subtitle file by its /files index as UTF-8 text/plain, converted from the encoding it was saved in (X-Roxbox-Charset names it); the bitmap .sub of a VobSub pair is served as it is. Waits for the file to download",
+		Params: []apiParam{{Name: "index", Desc: "index from /subtitles", Required: true, Type: "integer"}}}}},
+	{"/subtitles/{index}.vtt", []apiOp{{Method: "GET", Summary: "A .srt or .vtt subtitle file as WebVTT for web-based players: SubRip is converted on the fly (cue timings rewritten, <font> and {\\an8} tags dropped) after the same encoding detection as /subtitles/{index}; other formats answer 415",
+		Params:  []apiParam{{Name: "index", Desc: "index from /subtitles", Required: true, Type: "integer"}},
+		RawResp: "text/vtt"}}},
+	{"/subtitles/embedded", []apiOp{{Method: "GET", Summary: "The text subtitle tracks (SubRip, ASS, WebVTT) inside the streamed Matroska video, read from its header and cues; indexed tracks (the cues point at their blocks) are extracted ahead of the video. An empty list with a note when the file isn't Matroska",
+		Resp: embeddedSubtitlesResponse{}}}},
+	{"/subtitles/embedded/{track}", []apiOp{{Method: "GET", Summary: "An embedded subtitle track as a sidecar file in its own format (srt, ass or vtt), UTF-8. Holds the blocks downloaded so far and raises the pieces holding the rest; X-Roxbox-Complete says whether it is the whole track and X-Roxbox-Cues how many cues it has",
+		Params: []apiParam{
+			{Name: "track", Desc: "track from /subtitles/embedded", Required: true, Type: "integer"},
+			{Name: "wait", Type: "boolean", Desc: "true: wait for every block of the track (for a track the cues don't index, the whole file)"},
+		}}}},
+	{"/subtitles/embedded/{track}.vtt", []apiOp{{Method: "GET", Summary: "An embedded subtitle track as WebVTT (ASS converted without its styling), as /subtitles/embedded/{track}",
+		Params: []apiParam{
+			{Name: "track", Desc: "track from /subtitles/embedded", Required: true, Type: "integer"},
+			{Name: "wait", Type: "boolean", Desc: "true: wait for every block of the track"},
+		},
+		RawResp: "text/vtt"}}},
+	{"/tree", []apiOp{{Method: "GET", Summary: "The files of /files as a directory tree; each directory has the size, bytes on disk, progress and file count of everything under it",
+		Params: []apiParam{
+			{Name: "path", Desc: "directory to answer instead of the whole torrent, as a path from the tree"},
+			{Name: "depth", Desc: "levels to list; deeper directories come with their totals only (truncated). 0 or absent lists all", Type: "integer"},
+		},
+		Resp: treeDir{}}}},
+	{"/select", []apiOp{{Method: "POST", Summary: "Stream another file of the torrent: its priorities, readahead and companions replace the old file's, and /status starts over for it. Answers /info",
+		Params: []apiParam{
+			{Name: "file", Desc: "index from /files", Type: "integer"},
+			{Name: "pattern", Desc: "episode whose video to stream instead of file: S01E05, 1x05, Season 1 Episode 5, or E05 in a one-season pack"},
+		},
+		Resp: InfoResponse{}}}},
+	{"/playlist.m3u", []apiOp{{Method: "GET", Summary: "M3U8 playlist of the torrent's videos and audio files in episode order; each entry is /stream?file=<index>, which switches the session to it",
+		RawResp: "audio/x-mpegurl"}}},
+	{"/download.zip", []apiOp{{Method: "GET", Summary: "Every file of the torrent as a ZIP archive (stored, not compressed), written as the pieces complete; no Content-Length; 403 while a watermark is set",
+		RawResp: "application/zip"}}},
+	{"/stop", []apiOp{{Method: "POST", Summary: "Stop the profile's session"}}},
+	{"/player/state", []apiOp{{Method: "POST", Summary: "Report player state; long pauses enter trickle mode",
+		Params: []apiParam{
+			{Name: "state", Required: true, Enum: []string{"playing", "paused", "buffering"}},
+			{Name: "position", Desc: "playback position in seconds", Type: "number"},
+			{Name: "duration", Desc: "media duration in seconds (sizes the data saver window)", Type: "number"},
+		}}}},
+	{"/tee", []apiOp{
+		{Method: "GET", Summary: "Stream tee status", Resp: teeStatus{}},
+		{Method: "POST", Summary: "Mirror streamed bytes into a local file",
+			Params: []apiParam{{Name: "path", Desc: "output file in the tee directory (-tee-dir), relative or absolute; must not exist", Required: true}}, Resp: teeStatus{}},
+		{Method: "DELETE", Summary: "Stop the tee (the partial .part file is kept)"},
+	}},
+	{"/export", []apiOp{
+		{Method: "GET", Summary: "List export jobs", Resp: []exportJob{}},
+		{Method: "POST", Summary: "Export the active file; resumes from its journal",
+			Params: []apiParam{{Name: "dest", Desc: "absolute destination path", Required: true}}, Resp: []exportJob{}},
+		{Method: "DELETE", Summary: "Cancel an export",
+			Params: []apiParam{{Name: "dest", Required: true}}},
+	}},
+	{"/session/{id}/swarm/export", []apiOp{{Method: "GET", Summary: "Swarm snapshot of the active session",
+		Params: []apiParam{{Name: "id", Desc: "infohash of the session", Required: true}},
+		Resp:   SwarmSnapshot{}}}},
+	{"/handoff", []apiOp{
+		{Method: "GET", Summary: "Export a playback handoff bundle", Resp: HandoffBundle{}},
+		{Method: "POST", Summary: "Continue playback from another device's bundle", Body: HandoffBundle{}, Resp: map[string]any{}},
+	}},
+	{"/snapshot", []apiOp{{Method: "GET", Summary: "Every profile's policies and torrent sessions (file, position, options, peers) as an opaque string for the app's saved state",
+		Resp: snapshotBlob{}}}},
+	{"/restore", []apiOp{{Method: "POST", Summary: "Bring back the state of a /snapshot after a restart: settings are written and sessions added again at their position; ones already holding their torrent are left as they are",
+		Body: snapshotBlob{}, Resp: []restoredSession{}}}},
+	{"/party/create", []apiOp{{Method: "POST", Summary: "Start a watch party around the session's file with this instance as coordinator; answers the URL other instances join with; 409 for a private torrent. With a body, host a party for a coordinator elsewhere (as its relay); 503 when this instance hosts the most it will",
+		Params: []apiParam{
+			{Name: "relay", Desc: "base URL of a roxbox instance every device reaches, to host the party there"},
+			{Name: "member", Desc: "name to show in the party (default: the host name)"},
+		},
+		Body: partySetup{}, OptionalBody: true, Resp: map[string]any{}}}},
+	{"/party/join", []apiOp{{Method: "POST", Summary: "Join a watch party: starts its file at the party clock's position and reports buffering to its host",
+		Params: []apiParam{
+			{Name: "url", Desc: "join URL from /party/create", Required: true},
+			{Name: "member", Desc: "name to show in the party (default: the host name)"},
+		},
+		Resp: map[string]any{}}}},
+	{"/party/state", []apiOp{
+		{Method: "GET", Summary: "This device's side of its party: the party, where playback should be now and how far the player is off. With party=, the hosted party itself",
+			Params: []apiParam{{Name: "party", Desc: "ID of a party this instance hosts; with it no API token is needed"}}, Resp: partyView{}},
+		{Method: "POST", Summary: "Coordinator: play, pause or seek the party. With party=, a member's report to the party's host, answered with the party",
+			Params: []apiParam{{Name: "party", Desc: "ID of a party this instance hosts"}}, Body: partyControl{}, Resp: partyView{}},
+		{Method: "DELETE", Summary: "Leave the party; a coordinator hosting it ends it"},
+	}},
+	{"/profile/settings", []apiOp{
+		{Method: "GET", Summary: "Profile policies", Resp: profileSettings{}},
+		{Method: "PUT", Summary: "Replace profile policies", Body: profileSettings{}, Resp: profileSettings{}},
+	}},
+	{"/profile/history", []apiOp{
+		{Method: "GET", Summary: "Profile watch history", Resp: []historyEntry{}},
+		{Method: "DELETE", Summary: "Clear profile watch history"},
+	}},
+	{"/secrets", []apiOp{
+		{Method: "GET", Summary: "Names of stored secrets", Resp: map[string]any{}},
+		{Method: "PUT", Summary: "Store a secret (requires ROXBOX_SECRET_KEY); cookie:<domain> and authorization:<domain> log fetches from that site in; passkey:<domain> goes into that tracker's announce URLs, replacing the old one in running torrents from their next announce",
+			Params: []apiParam{{Name: "name", Required: true}}, Body: struct {
+				Value string `json:"value"`
+			}{}},
+		{Method: "DELETE", Summary: "Remove a secret", Params: []apiParam{{Name: "name", Required: true}}},
+	}},
+	{"/tokens", []apiOp{
+		{Method: "GET", Summary: "API tokens (names and scopes). Once one exists, requests from other hosts need a token: Authorization: Bearer or token=", Resp: []apiToken{}},
+		{Method: "POST", Summary: "Create a token; the answer is the only time it is shown. A read token may only GET status, files and streams",
+			Body: struct {
+				Name  string `json:"name"`
+				Scope string `json:"scope"`
+			}{}, Resp: tokenCreated{}},
+		{Method: "DELETE", Summary: "Revoke a token", Params: []apiParam{{Name: "name", Required: true}}, Resp: []apiToken{}},
+	}},
+	{"/debug/events", []apiOp{{Method: "GET", Summary: "Recent request spans and server events, oldest first",
+		Params: []apiParam{
+			{Name: "request_id", Desc: "only events of this X-Request-ID"},
+			{Name: "kind", Desc: "request, stall or session"},
+			{Name: "since", Desc: "only events after this seq", Type: "integer"},
+			{Name: "limit", Desc: "newest N (default 200)", Type: "integer"},
+		},
+		Resp: []event{}}}},
+	{"/debug/priorities", []apiOp{{Method: "GET",
+		Summary: "Effective priority of every piece of the active file, with each /stream reader's offset and readahead",
+		Resp:    prioritiesResponse{}}}},
+	{"/debug/stats", []apiOp{{Method: "GET",
+		Summary: "Disk I/O throttle (background writes and hashing paced while a player reads), request pipeline depths and Go runtime counters",
+		Resp:    debugStats{}}}},
+	{"/debug/rangestats", []apiOp{
+		{Method: "GET", Summary: "Range request patterns per player User-Agent: open probes, tail reads, seek distribution", Resp: []rangeAgentStats{}},
+		{Method: "DELETE", Summary: "Reset the range statistics"}}},
+	{"/debug/loglevel", []apiOp{
+		{Method: "GET", Summary: "Current log level per module", Resp: map[string]string{}},
+		{Method: "POST", Summary: "Change one module's log level at runtime",
+			Params: []apiParam{
+				{Name: "module", Required: true, Enum: append(append([]string{}, logModules...), "all")},
+				{Name: "level", Required: true, Enum: []string{"debug", "info", "warning", "error", "off"}},
+			},
+			Resp: map[string]string{}},
+	}},
+	{"/telemetry", []apiOp{
+		{Method: "GET", Summary: "Telemetry settings", Resp: telemetrySettings{}},
+		{Method: "PUT", Summary: "Opt in to or out of anonymous crash and QoE reports", Body: telemetrySettings{}, Resp: telemetrySettings{}},
+	}},
+	{"/capabilities", []apiOp{{Method: "GET", Summary: "Optional features compiled into this binary and whether they are usable now",
+		Resp: capabilitiesResponse{}}}},
+	{"/health", []apiOp{{Method: "GET", Summary: "Liveness probe (every response carries X-Roxbox-Instance, this process's UUID)"}}},
+	{"/openapi.json", []apiOp{{Method: "GET", Summary: "This document", Resp: map[string]any{}}}},
+}
+
+var (
+	openAPIOnce sync.Once
+	openAPIDoc  []byte
+)
+
+// ── GET /openapi.json ─────────────────────────────────────────────────────────
+func handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	openAPIOnce.Do(func() {
+		openAPIDoc, _ = json.MarshalIndent(buildOpenAPI(), "", "  ")
+	})
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(openAPIDoc)
+}
+
+// printOpenAPI implements `torrent_server openapi`.
+func printOpenAPI() int {
+	b, err := json.MarshalIndent(buildOpenAPI(), "", "  ")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	os.Stdout.Write(append(b, '\n'))
+	return 0
+}
+
+func buildOpenAPI() map[string]any {
+	sg := &schemaGen{defs: map[string]any{}}
+	paths := map[string]any{}
+	profileParam := map[string]any{
+		"name": profileHeader, "in": "header", "required": false,
+		"description": "profile namespace (also accepted as ?profile=)",
+		"schema":      map[string]any{"type": "string", "pattern": profileIDRe.String()},
+	}
+	traceParam := map[string]any{
+		"name": requestIDHeader, "in": "header", "required": false,
+		"description": "trace ID echoed in the response, error JSON and /debug/events (generated if absent)",
+		"schema":      map[string]any{"type": "string", "maxLength": 128},
+	}
+	idemParam := map[string]any{
+		"name": idempotencyHeader, "in": "header", "required": false,
+		"description": "retries with the same key replay the first response",
+		"schema":      map[string]any{"type": "string", "maxLength": idempotencyMaxKey},
+	}
+
+	for _, rt := range allAPIDocs() {
+		item := map[string]any{}
+		for _, op := range rt.Ops {
+			params := []any{profileParam, traceParam}
+			if idempotentPaths[rt.Path] && op.Method != "GET" {
+				params = append(params, idemParam)
+			}
+			for _, p := range op.Params {
+				typ := p.Type
+				if typ == "" {
+					typ = "string"
+				}
+				schema := map[string]any{"type": typ}
+				if len(p.Enum) > 0 {
+					schema["enum"] = p.Enum
+				}
+				in := "query"
+				if strings.Contains(rt.Path, "{"+p.Name+"}") {
+					in = "path"
+				}
+				params = append(params, map[string]any{
+					"name": p.Name, "in": in, "required": p.Required || in == "path",
+					"description": p.Desc, "schema": schema,
+				})
+			}
+
+			ok := map[string]any{"description": "OK"}
+			switch {
+			case op.RawResp != "":
+				ok["content"] = map[string]any{op.RawResp: map[string]any{
+					"schema": map[string]any{"type": "string", "format": "binary"}}}
+			case op.Resp != nil:
+				ok["content"] = map[string]any{"application/json": map[string]any{
+					"schema": sg.schema(reflect.TypeOf(op.Resp))}}
+			default:
+				ok["content"] = map[string]any{"text/plain": map[string]any{
+					"schema": map[string]any{"type": "string"}}}
+			}
+			o := map[string]any{
+				"summary":     op.Summary,
+				"operationId": operationID(op.Method, rt.Path),
+				"parameters":  params,
+				"responses": map[string]any{
+					"200":     ok,
+					"default": map[string]any{"description": "Error (text/plain message)"},
+				},
+			}
+			if op.WebSocket {
+				o["x-websocket"] = true
+			}
+			if op.Body != nil {
+				o["requestBody"] = map[string]any{"required": !op.OptionalBody, "content": map[string]any{
+					"application/json": map[string]any{"schema": sg.schema(reflect.TypeOf(op.Body))}}}
+			}
+			item[strings.ToLower(op.Method)] = o
+		}
+		paths[rt.Path] = item
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "RoxBox torrent streaming server",
+			"version": version,
+		},
+		"servers":    []any{map[string]any{"url": "http://" + advertiseHost() + ":" + port}},
+		"paths":      paths,
+		"components": map[string]any{"schemas": sg.defs},
+	}
+}
+
+// operationID turns "GET /profile/settings" into "getProfileSettings".
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool {
+		return r == '/' || r == '.' || r == '{' || r == '}' || r == '_'
+	}) {
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
+
+// schemaGen derives JSON schemas from Go types, following encoding/json
+// rules for field names, omitempty and embedded structs.
+type schemaGen struct {
+	defs map[string]any
+}
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	rawJSONType = reflect.TypeOf(json.RawMessage(nil))
+	textType    = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+func (g *schemaGen) schema(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	if t == rawJSONType {
+		return map[string]any{} // any JSON value
+	}
+	if t.Kind() == reflect.Struct && t.Implements(textType) { // netip.Addr and the like
+		return map[string]any{"type": "string"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		name := schemaName(t)
+		if name == "" {
+			return g.object(t)
+		}
+		if _, ok := g.defs[name]; !ok {
+			g.defs[name] = map[string]any{} // placeholder breaks recursion
+			g.defs[name] = g.object(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]any{} // interface{}: anything
+}
+
+func (g *schemaGen) object(t reflect.Type) map[string]any {
+	props := map[string]any{}
+	var required []string
+	g.fields(t, props, &required)
+	sort.Strings(required)
+	obj := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		obj["required"] = required
+	}
+	return obj
+}
+
+func (g *schemaGen) fields(t reflect.Type, props map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.fields(ft, props, required)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = g.schema(f.Type)
+		if !strings.Contains(opts, "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// schemaName gives exported names to component schemas; anonymous structs
+// are inlined.
+func schemaName(t reflect.Type) string {
+	n := t.Name()
+	if n == "" {
+		return ""
+	}
+	return strings.ToUpper(n[:1]) + n[1:]
+}
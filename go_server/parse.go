@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+// ── GET /parse?magnet=… ───────────────────────────────────────────────────────
+// Decodes a magnet without adding anything: its infohash, display name,
+// trackers and x.pe peer hints, plus what is wrong with it, so the app can
+// turn a bad link down before it starts a session. Besides a magnet /add
+// can't start at all, a tracker or peer hint that can't be used is an
+// error: the client would silently skip it.
+
+type parseResponse struct {
+	Valid    bool                `json:"valid"`
+	InfoHash string              `json:"info_hash,omitempty"`
+	Name     string              `json:"name,omitempty"`   // dn
+	Trackers []string            `json:"trackers"`         // tr
+	Peers    []string            `json:"peers"`            // x.pe
+	Params   map[string][]string `json:"params,omitempty"` // the rest (xl, ws, as…)
+	Errors   []string            `json:"errors,omitempty"`
+}
+
+func handleParse(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "GET only", 405)
+		return
+	}
+	uri := r.URL.Query().Get("magnet")
+	if uri == "" {
+		http.Error(w, "magnet param required", 400)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(parseMagnet(uri))
+}
+
+func parseMagnet(uri string) parseResponse {
+	resp := parseResponse{Trackers: []string{}, Peers: []string{}}
+	m, err := metainfo.ParseMagnetUri(uri)
+	if err != nil {
+		resp.Errors = append(resp.Errors, "magnet: "+err.Error())
+		return resp
+	}
+	resp.InfoHash, resp.Name = m.InfoHash.HexString(), m.DisplayName
+	for _, tr := range m.Trackers {
+		resp.Trackers = append(resp.Trackers, tr)
+		if err := checkTrackerURL(tr); err != nil {
+			resp.Errors = append(resp.Errors, "tracker "+err.Error())
+		}
+	}
+	resp.Peers = append(resp.Peers, m.Params["x.pe"]...)
+	if len(resp.Peers) > maxPeerHints {
+		resp.Errors = append(resp.Errors, fmt.Sprintf("x.pe: at most %d peers", maxPeerHints))
+	} else {
+		for _, pe := range resp.Peers {
+			if _, err := peerInfos([]string{pe}); err != nil {
+				resp.Errors = append(resp.Errors, "x.pe: "+err.Error())
+			}
+		}
+	}
+	for k, v := range m.Params {
+		if k != "x.pe" {
+			if resp.Params == nil {
+				resp.Params = map[string][]string{}
+			}
+			resp.Params[k] = v
+		}
+	}
+	resp.Valid = len(resp.Errors) == 0
+	return resp
+}
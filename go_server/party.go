@@ -0,0 +1,654 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+
+	"github.com/roxbox/torrent_server/engine"
+)
+
+// ── Watch party: /party/create · /party/join · /party/state ──────────────────
+// Several devices watching the same torrent in step. POST /party/create
+// makes this instance the coordinator of a party around the profile's
+// session: it hosts the party's state (the magnet and file, who is in it
+// and how far each has buffered, and one playback clock) and answers a
+// join URL. POST /party/join?url= on another instance fetches that state,
+// starts the same file at the clock's position and keeps reporting to the
+// host. The clock runs while the coordinator plays and holds while any
+// member is loading or buffering, so nobody is left behind. Each device
+// works out the host's clock from the round trip of its reports, and GET
+// /party/state tells its app where playback should be now and how far its
+// player is off; the app seeks when that drifts. The coordinator's app
+// plays, pauses and seeks for everyone with POST /party/state.
+//
+// Devices that can't reach each other (different networks) meet at a relay:
+// any roxbox instance all of them reach, given as relay= at create time.
+// The relay then hosts the party and the coordinator controls it there
+// with the party's key. Over the LAN the coordinator runs with -lan.
+//
+// The party's ID is what lets a device in: /party/state?party= needs no
+// API token (tokens.go), so IDs are 128 random bits. An instance hosts at
+// most maxHostedParties at once. Since anyone with the ID reads the magnet,
+// it carries only trackers without a passkey, and a private torrent can't
+// be watched together at all.
+
+const (
+	partyTick      = time.Second
+	partyMemberTTL = 10 * time.Second // a member not heard from this long has left
+	partyIdleTTL   = time.Hour        // a hosted party nobody reported to this long is gone
+	partyTimeout   = 3 * time.Second
+
+	maxHostedParties = 16
+)
+
+var partyClient = &http.Client{Timeout: partyTimeout}
+
+// partyMember is a device in the party as it last reported.
+type partyMember struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	State       string    `json:"state"` // "loading" | "ready" | "playing" | "paused" | "buffering"
+	PositionSec float64   `json:"position_sec"`
+	Seen        time.Time `json:"seen"` // host time
+}
+
+// partyState is a party as its host keeps it.
+type partyState struct {
+	ID          string        `json:"id"`
+	Name        string        `json:"name,omitempty"`
+	Magnet      string        `json:"magnet"`
+	File        string        `json:"file,omitempty"`
+	Playing     bool          `json:"playing"`
+	PositionSec float64       `json:"position_sec"` // the clock at clock_at
+	ClockAt     time.Time     `json:"clock_at"`     // host time
+	Waiting     bool          `json:"waiting"`      // a member is loading or buffering: the clock holds
+	Members     []partyMember `json:"members"`
+	ServerTime  time.Time     `json:"server_time"` // host time of the answer, for clock sync
+}
+
+// partySetup is what a host needs to start a party; a coordinator sends it
+// to its relay.
+type partySetup struct {
+	ID          string  `json:"id"`
+	Key         string  `json:"key"` // controls the clock
+	Name        string  `json:"name,omitempty"`
+	Magnet      string  `json:"magnet"`
+	File        string  `json:"file,omitempty"`
+	Playing     bool    `json:"playing,omitempty"`
+	PositionSec float64 `json:"position_sec,omitempty"`
+}
+
+// partyReport is what a device sends the host every tick; answered with
+// the party's state.
+type partyReport struct {
+	MemberID    string        `json:"member_id"`
+	Member      string        `json:"member,omitempty"`
+	State       string        `json:"state"`
+	PositionSec float64       `json:"position_sec"`
+	Key         string        `json:"key,omitempty"`     // the coordinator's, with control
+	Control     *partyControl `json:"control,omitempty"` // play, pause or seek the party
+}
+
+// partyControl sets the party's clock.
+type partyControl struct {
+	Playing     bool    `json:"playing"`
+	PositionSec float64 `json:"position_sec"`
+}
+
+// partyView is this device's side of its party (GET /party/state).
+type partyView struct {
+	Role              string     `json:"role"` // "coordinator" | "member"
+	JoinURL           string     `json:"join_url"`
+	Party             partyState `json:"party"`
+	OffsetMs          float64    `json:"offset_ms"`           // the host's clock minus this one
+	TargetPositionSec float64    `json:"target_position_sec"` // where playback should be now
+	DriftSec          float64    `json:"drift_sec"`           // the player's last position minus the target
+	Error             string     `json:"error,omitempty"`     // why the host couldn't be reached lately
+}
+
+type hostedParty struct {
+	key  string
+	st   partyState
+	seen time.Time // last report
+}
+
+// partyRole is a profile's part in a party.
+type partyRole struct {
+	id     string
+	host   string // base URL of the host; "" when this instance hosts it
+	key    string // set for the coordinator
+	member string // this device's member ID
+	name   string
+	stop   chan struct{}
+
+	mu     sync.Mutex
+	last   partyState
+	offset time.Duration
+	err    string
+}
+
+var parties struct {
+	sync.Mutex
+	hosted map[string]*hostedParty
+	roles  map[string]*partyRole // by profile ID
+}
+
+var (
+	errNoParty      = errors.New("no such party")
+	errPartyKey     = errors.New("only the coordinator controls the party")
+	errPartiesFull  = fmt.Errorf("this instance already hosts %d parties", maxHostedParties)
+	errShortPartyID = errors.New("a party id needs 32 characters or more")
+)
+
+// at is the party's clock at host time t.
+func (st partyState) at(t time.Time) float64 {
+	if !st.Playing || st.Waiting {
+		return st.PositionSec
+	}
+	return st.PositionSec + t.Sub(st.ClockAt).Seconds()
+}
+
+// hostParty starts hosting the party s describes.
+func hostParty(s partySetup) error {
+	if s.ID == "" || s.Key == "" || s.Magnet == "" {
+		return errors.New("a party needs an id, a key and a magnet")
+	}
+	if len(s.ID) < 32 {
+		return errShortPartyID // it is all that guards the party's state
+	}
+	if _, err := torrent.TorrentSpecFromMagnetUri(s.Magnet); err != nil {
+		return fmt.Errorf("magnet: %v", err)
+	}
+	parties.Lock()
+	defer parties.Unlock()
+	if parties.hosted == nil {
+		parties.hosted = map[string]*hostedParty{}
+	}
+	for id, h := range parties.hosted {
+		if time.Since(h.seen) > partyIdleTTL {
+			delete(parties.hosted, id) // a relay's coordinator that never said goodbye
+		}
+	}
+	h := parties.hosted[s.ID]
+	if h != nil && h.key != s.Key {
+		return errors.New("party id taken")
+	}
+	if h == nil && len(parties.hosted) >= maxHostedParties {
+		return errPartiesFull
+	}
+	parties.hosted[s.ID] = &hostedParty{key: s.Key, seen: time.Now(), st: partyState{ID: s.ID, Name: s.Name, Magnet: s.Magnet, File: s.File,
+		Playing: s.Playing, PositionSec: s.PositionSec, ClockAt: time.Now(), Members: []partyMember{}}}
+	return nil
+}
+
+// reportParty takes rep into hosted party id and answers its state.
+func reportParty(id string, rep partyReport) (partyState, error) {
+	now := time.Now()
+	parties.Lock()
+	defer parties.Unlock()
+	h := parties.hosted[id]
+	if h == nil {
+		return partyState{}, errNoParty
+	}
+	h.seen = now
+	h.settle(now)
+	if rep.Control != nil {
+		if rep.Key != h.key {
+			return partyState{}, errPartyKey
+		}
+		h.st.Playing, h.st.PositionSec, h.st.ClockAt = rep.Control.Playing, max(rep.Control.PositionSec, 0), now
+	}
+	if rep.MemberID != "" {
+		m := partyMember{ID: rep.MemberID, Name: rep.Member, State: rep.State, PositionSec: rep.PositionSec, Seen: now}
+		found := false
+		for i := range h.st.Members {
+			if h.st.Members[i].ID == m.ID {
+				h.st.Members[i], found = m, true
+			}
+		}
+		if !found {
+			h.st.Members = append(h.st.Members, m)
+		}
+	}
+	h.settle(now)
+	return h.state(now), nil
+}
+
+// hostedState answers hosted party id's state.
+func hostedState(id string) (partyState, error) {
+	now := time.Now()
+	parties.Lock()
+	defer parties.Unlock()
+	h := parties.hosted[id]
+	if h == nil {
+		return partyState{}, errNoParty
+	}
+	h.settle(now)
+	return h.state(now), nil
+}
+
+// settle drops members gone quiet and holds or releases the clock as
+// members buffer. Caller holds parties.
+func (h *hostedParty) settle(now time.Time) {
+	members := h.st.Members[:0]
+	waiting := false
+	for _, m := range h.st.Members {
+		if now.Sub(m.Seen) > partyMemberTTL {
+			continue
+		}
+		members = append(members, m)
+		waiting = waiting || m.State == "loading" || m.State == "buffering"
+	}
+	h.st.Members = members
+	if waiting != h.st.Waiting {
+		h.st.PositionSec, h.st.ClockAt = h.st.at(now), now
+		h.st.Waiting = waiting
+	}
+}
+
+// state is a copy of the party to answer with. Caller holds parties.
+func (h *hostedParty) state(now time.Time) partyState {
+	st := h.st
+	st.Members = append([]partyMember{}, h.st.Members...)
+	sort.Slice(st.Members, func(i, j int) bool { return st.Members[i].Name < st.Members[j].Name })
+	st.ServerTime = now
+	return st
+}
+
+// ── POST /party/create[?relay=<url>&member=<name>] ───────────────────────────
+// With a partySetup body, hosts a party for a coordinator elsewhere (this
+// instance is its relay).
+func handlePartyCreate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST only", 405)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 64<<10))
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	if len(bytes.TrimSpace(body)) > 0 {
+		var s partySetup
+		if err := json.Unmarshal(body, &s); err != nil {
+			http.Error(w, "invalid JSON body: "+err.Error(), 400)
+			return
+		}
+		if err := hostParty(s); err != nil {
+			code := 400
+			if err == errPartiesFull {
+				code = 503
+			}
+			http.Error(w, err.Error(), code)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"party_id": s.ID})
+		return
+	}
+
+	p, err := profileFor(r)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	relay := strings.TrimRight(r.URL.Query().Get("relay"), "/")
+	if relay != "" {
+		if u, err := url.Parse(relay); err != nil || u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+			http.Error(w, "relay must be an http(s) URL", 400)
+			return
+		}
+	}
+	t, f := p.sess.current()
+	if t == nil || f == nil {
+		http.Error(w, "nothing is playing to watch together", 409)
+		return
+	}
+	if engine.IsPrivate(t) {
+		// Members would announce to the tracker as this account, from
+		// their own addresses.
+		http.Error(w, "a private torrent can't be watched together", 409)
+		return
+	}
+	st := p.sess.snapshotStatus()
+	s := partySetup{ID: newID() + newID(), Key: newID() + newID(), Name: t.Name(), Magnet: partyMagnet(t), File: f.DisplayPath(),
+		Playing: st.PlayerState == "playing", PositionSec: st.PositionSec}
+	if relay == "" {
+		err = hostParty(s)
+	} else {
+		err = partyPost(relay+"/party/create", s, nil)
+	}
+	if err != nil {
+		http.Error(w, "create party: "+err.Error(), 502)
+		return
+	}
+	role := p.joinParty(s.ID, relay, s.Key, r.URL.Query().Get("member"))
+	log.Printf("party: %s created for %s", s.ID, s.Name)
+	p.sess.event(requestID(r), "party "+s.ID+" created")
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"party_id": s.ID, "join_url": role.joinURL()})
+}
+
+// partyMagnet is t's magnet for the members, who may be anyone holding the
+// party ID: the infohash, the name and the trackers that need no passkey.
+func partyMagnet(t *torrent.Torrent) string {
+	m := metainfo.Magnet{InfoHash: t.InfoHash(), DisplayName: t.Name()}
+	mi := t.Metainfo()
+	for _, tier := range mi.UpvertedAnnounceList() {
+		for _, tr := range tier {
+			if publicTrackerURL(tr) == tr && !slices.Contains(m.Trackers, tr) {
+				m.Trackers = append(m.Trackers, tr)
+			}
+		}
+	}
+	return m.String()
+}
+
+// ── POST /party/join?url=<join url>[&member=<name>] ──────────────────────────
+func handlePartyJoin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST only", 405)
+		return
+	}
+	p, err := profileFor(r)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	host, id, err := parseJoinURL(r.URL.Query().Get("url"))
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	var st partyState
+	t0 := time.Now()
+	if err := partyGet(host+"/party/state?party="+url.QueryEscape(id), &st); err != nil {
+		http.Error(w, "join party: "+err.Error(), 502)
+		return
+	}
+	offset := clockOffset(st.ServerTime, t0, time.Now())
+	resumeAt := st.at(time.Now().Add(offset))
+	role := p.joinParty(id, host, "", r.URL.Query().Get("member"))
+	role.mu.Lock()
+	role.last, role.offset = st, offset
+	role.mu.Unlock()
+	addID, ok := p.sess.start(addOptions{File: st.File, ResumeAt: resumeAt, RequestID: requestID(r)}, func() (*torrent.Torrent, error) {
+		t, err := p.addMagnet(st.Magnet)
+		if err != nil {
+			return nil, fmt.Errorf("AddMagnet: %v", err)
+		}
+		return t, nil
+	})
+	p.sess.event(requestID(r), "joined party "+id)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"party_id": id, "status": addStatus(ok), "add_id": addID, "resume_at_sec": resumeAt})
+}
+
+// parseJoinURL splits a join URL into the host's base URL and the party ID.
+func parseJoinURL(s string) (host, id string, err error) {
+	u, err := url.Parse(s)
+	if err != nil || u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return "", "", errors.New("url must be the party's http(s) join URL")
+	}
+	if id = u.Query().Get("party"); id == "" {
+		return "", "", errors.New("join URL has no party=")
+	}
+	base, _, _ := strings.Cut(u.Path, "/party/state")
+	return u.Scheme + "://" + u.Host + strings.TrimRight(base, "/"), id, nil
+}
+
+// ── GET | POST | DELETE /party/state[?party=<id>] ────────────────────────────
+// With party=, the hosted party: GET answers it, POST takes a member's
+// report. Without, this device's side: GET its view, POST (coordinator
+// only) plays, pauses or seeks the party, DELETE leaves it.
+func handlePartyState(w http.ResponseWriter, r *http.Request) {
+	if id := r.URL.Query().Get("party"); id != "" {
+		var st partyState
+		var err error
+		switch r.Method {
+		case http.MethodGet:
+			st, err = hostedState(id)
+		case http.MethodPost:
+			var rep partyReport
+			if err := json.NewDecoder(io.LimitReader(r.Body, 64<<10)).Decode(&rep); err != nil {
+				http.Error(w, "invalid JSON body: "+err.Error(), 400)
+				return
+			}
+			st, err = reportParty(id, rep)
+		default:
+			http.Error(w, "GET or POST only", 405)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), partyErrCode(err))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(st)
+		return
+	}
+
+	p, err := profileFor(r)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	parties.Lock()
+	role := parties.roles[p.ID]
+	parties.Unlock()
+	if role == nil {
+		http.Error(w, "not in a party", 404)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		if role.key == "" {
+			http.Error(w, errPartyKey.Error(), 403)
+			return
+		}
+		var c partyControl
+		if err := json.NewDecoder(io.LimitReader(r.Body, 64<<10)).Decode(&c); err != nil {
+			http.Error(w, "invalid JSON body: "+err.Error(), 400)
+			return
+		}
+		if err := role.report(p.sess, &c); err != nil {
+			http.Error(w, err.Error(), partyErrCode(err))
+			return
+		}
+	case http.MethodDelete:
+		p.leaveParty()
+		p.sess.event(requestID(r), "left party "+role.id)
+		w.WriteHeader(200)
+		fmt.Fprint(w, "left")
+		return
+	default:
+		http.Error(w, "GET, POST or DELETE only", 405)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(role.view(p.sess))
+}
+
+func partyErrCode(err error) int {
+	switch err {
+	case errNoParty:
+		return 404
+	case errPartyKey:
+		return 403
+	}
+	return 502
+}
+
+// joinParty makes p a member of party id (the coordinator with key) and
+// starts reporting to its host, leaving any party it was in.
+func (p *profile) joinParty(id, host, key, name string) *partyRole {
+	p.leaveParty()
+	if name == "" {
+		name, _ = os.Hostname()
+		if p.ID != defaultProfile {
+			name += "/" + p.ID
+		}
+	}
+	role := &partyRole{id: id, host: host, key: key, member: instanceID + "/" + p.ID, name: name, stop: make(chan struct{})}
+	parties.Lock()
+	if parties.roles == nil {
+		parties.roles = map[string]*partyRole{}
+	}
+	parties.roles[p.ID] = role
+	parties.Unlock()
+	go role.loop(p.sess)
+	return role
+}
+
+// leaveParty stops p's reports; a party p hosts itself ends.
+func (p *profile) leaveParty() {
+	parties.Lock()
+	defer parties.Unlock()
+	role := parties.roles[p.ID]
+	if role == nil {
+		return
+	}
+	close(role.stop)
+	delete(parties.roles, p.ID)
+	if role.host == "" && role.key != "" {
+		delete(parties.hosted, role.id)
+	}
+}
+
+func (role *partyRole) loop(s *session) {
+	defer guard()
+	tick := time.NewTicker(partyTick)
+	defer tick.Stop()
+	for {
+		if err := role.report(s, nil); err != nil && err != errNoParty {
+			log.Printf("party: %s: %v", role.id, err)
+		}
+		select {
+		case <-role.stop:
+			return
+		case <-tick.C:
+		}
+	}
+}
+
+// report sends the host how this device is doing (and c, the coordinator's
+// control, if any) and keeps the answer.
+func (role *partyRole) report(s *session, c *partyControl) error {
+	st := s.snapshotStatus()
+	rep := partyReport{MemberID: role.member, Member: role.name, State: memberState(st), PositionSec: st.PositionSec, Control: c}
+	if c != nil {
+		rep.Key = role.key
+	}
+	var out partyState
+	var err error
+	t0 := time.Now()
+	if role.host == "" {
+		out, err = reportParty(role.id, rep)
+	} else {
+		err = partyPost(role.host+"/party/state?party="+url.QueryEscape(role.id), rep, &out)
+	}
+	role.mu.Lock()
+	defer role.mu.Unlock()
+	if err != nil {
+		role.err = err.Error()
+		return err
+	}
+	role.last, role.err = out, ""
+	if role.host != "" {
+		role.offset = clockOffset(out.ServerTime, t0, time.Now())
+	}
+	return nil
+}
+
+// memberState is what a session reports to its party.
+func memberState(st StatusResponse) string {
+	switch {
+	case st.State != "ready":
+		return "loading"
+	case st.PlayerState != "":
+		return st.PlayerState
+	}
+	return "ready"
+}
+
+// view is this device's side of the party now.
+func (role *partyRole) view(s *session) partyView {
+	role.mu.Lock()
+	defer role.mu.Unlock()
+	v := partyView{Role: "member", JoinURL: role.joinURL(), Party: role.last, OffsetMs: float64(role.offset) / float64(time.Millisecond), Error: role.err}
+	if role.key != "" {
+		v.Role = "coordinator"
+	}
+	v.TargetPositionSec = role.last.at(time.Now().Add(role.offset))
+	v.DriftSec = s.snapshotStatus().PositionSec - v.TargetPositionSec
+	return v
+}
+
+// joinURL is what other devices join with.
+func (role *partyRole) joinURL() string {
+	host := role.host
+	if host == "" {
+		host = "http://" + advertiseHost() + ":" + port
+	}
+	return host + "/party/state?party=" + url.QueryEscape(role.id)
+}
+
+// clockOffset is how far a host that answered at hostTime, for a request
+// sent at t0 and answered at t1, is ahead of this clock.
+func clockOffset(hostTime, t0, t1 time.Time) time.Duration {
+	return hostTime.Sub(t0.Add(t1.Sub(t0) / 2))
+}
+
+func partyGet(u string, out any) error {
+	resp, err := partyClient.Get(u)
+	if err != nil {
+		return err
+	}
+	return partyAnswer(resp, out)
+}
+
+func partyPost(u string, in, out any) error {
+	b, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	resp, err := partyClient.Post(u, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	return partyAnswer(resp, out)
+}
+
+func partyAnswer(resp *http.Response, out any) error {
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		switch resp.StatusCode {
+		case 404:
+			return errNoParty
+		case 403:
+			return errPartyKey
+		}
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(out)
+}
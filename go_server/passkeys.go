@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/bencode"
+)
+
+// ── Tracker passkeys ──────────────────────────────────────────────────────────
+// Private trackers put the account's passkey in the announce URL and make
+// users rotate it now and then, which used to mean re-adding every torrent.
+// A passkey is stored in the secret store as "passkey:<domain>" (PUT
+// /secrets; the domain matches as for site credentials) and an announce
+// URL says where it goes with {passkey}. On a domain with a stored passkey
+// the key already in a URL is recognised too: a passkey= param or a path
+// segment of 32 or more hex digits, as in .torrent files from before the
+// rotation. HTTP trackers with a passkey announce through /passkey/announce
+// on this server, which puts the current passkey in at announce time, so a
+// new one is used from the next announce of every torrent. UDP trackers get
+// the passkey filled in when the torrent is added.
+
+const (
+	passkeySecretPrefix = "passkey:"
+	passkeyPlaceholder  = "{passkey}"
+	passkeyRoute        = "/passkey/announce"
+	passkeyScrapeRoute  = "/passkey/scrape"
+	passkeyTimeout      = 30 * time.Second
+	maxAnnounceResponse = 1 << 20
+)
+
+var passkeySegment = regexp.MustCompile(`^[0-9a-fA-F]{32,}$`)
+
+var passkeyClient = &http.Client{Timeout: passkeyTimeout}
+
+// passkeyTemplate returns u with {passkey} where its passkey goes; false
+// when u has no passkey to manage.
+func passkeyTemplate(u string) (string, bool) {
+	if strings.Contains(u, passkeyPlaceholder) {
+		return u, true
+	}
+	if strings.Contains(u, "%7Bpasskey%7D") || strings.Contains(u, "%7bpasskey%7d") {
+		return strings.NewReplacer("%7Bpasskey%7D", passkeyPlaceholder, "%7bpasskey%7d", passkeyPlaceholder).Replace(u), true
+	}
+	pu, err := url.Parse(u)
+	if err != nil || siteSecret(passkeySecretPrefix, pu.Hostname()) == "" {
+		return u, false
+	}
+	if q := pu.Query(); q.Get("passkey") != "" {
+		q.Del("passkey")
+		pu.RawQuery = q.Encode()
+		if pu.RawQuery != "" {
+			pu.RawQuery += "&"
+		}
+		pu.RawQuery += "passkey=" + passkeyPlaceholder
+		return pu.String(), true
+	}
+	segs := strings.Split(pu.Path, "/")
+	for i, s := range segs {
+		if passkeySegment.MatchString(s) {
+			segs[i] = passkeyPlaceholder
+			pu.Path = strings.Join(segs, "/")
+			return strings.Replace(pu.String(), "%7Bpasskey%7D", passkeyPlaceholder, 1), true
+		}
+	}
+	return u, false
+}
+
+// fillPasskey puts the stored passkey into a template.
+func fillPasskey(tmpl string) (string, error) {
+	pu, err := url.Parse(strings.ReplaceAll(tmpl, passkeyPlaceholder, "x"))
+	if err != nil {
+		return "", err
+	}
+	key := siteSecret(passkeySecretPrefix, pu.Hostname())
+	if key == "" {
+		return "", fmt.Errorf("no passkey stored for %s (secret %s%s)", pu.Hostname(), passkeySecretPrefix, pu.Hostname())
+	}
+	return strings.ReplaceAll(tmpl, passkeyPlaceholder, url.PathEscape(key)), nil
+}
+
+// passkeyProxyPrefix starts the announce URL of a proxied tracker.
+func passkeyProxyPrefix() string {
+	return "http://" + net.JoinHostPort("127.0.0.1", port) + passkeyRoute + "?to="
+}
+
+// trackerWithPasskey is the URL the client should announce to for u.
+func trackerWithPasskey(u string) string {
+	tmpl, ok := passkeyTemplate(u)
+	if !ok {
+		return u
+	}
+	if strings.HasPrefix(tmpl, "http://") || strings.HasPrefix(tmpl, "https://") {
+		return passkeyProxyPrefix() + url.QueryEscape(tmpl)
+	}
+	if filled, err := fillPasskey(tmpl); err == nil {
+		return filled
+	}
+	return u
+}
+
+// templatePasskeys rewrites spec's trackers for their passkeys.
+func templatePasskeys(spec *torrent.TorrentSpec) {
+	for _, tier := range spec.Trackers {
+		for i, u := range tier {
+			tier[i] = trackerWithPasskey(u)
+		}
+	}
+}
+
+// publicTrackerURL undoes trackerWithPasskey for a URL that leaves this
+// server (an exported .torrent or magnet): the tracker's own URL with the
+// current passkey.
+func publicTrackerURL(u string) string {
+	rest, ok := strings.CutPrefix(u, passkeyProxyPrefix())
+	if !ok {
+		return u
+	}
+	tmpl, err := url.QueryUnescape(rest)
+	if err != nil {
+		return u
+	}
+	if filled, err := fillPasskey(tmpl); err == nil {
+		return filled
+	}
+	return tmpl
+}
+
+// ── GET /passkey/announce|scrape?to=<template>&<params> ───────────────────────
+// The torrent client's side of a proxied tracker; loopback only.
+func handlePasskeyAnnounce(w http.ResponseWriter, r *http.Request) {
+	host, _, _ := net.SplitHostPort(r.RemoteAddr)
+	if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+		http.Error(w, "loopback only", 403)
+		return
+	}
+	// The announce params carry binary info_hash and peer_id, so the raw
+	// query is passed on as it came.
+	var tmpl string
+	var rest []string
+	for _, kv := range strings.Split(r.URL.RawQuery, "&") {
+		if v, ok := strings.CutPrefix(kv, "to="); ok {
+			tmpl, _ = url.QueryUnescape(v)
+		} else if kv != "" {
+			rest = append(rest, kv)
+		}
+	}
+	if tmpl == "" {
+		passkeyFailure(w, "passkey: to param required")
+		return
+	}
+	if r.URL.Path == passkeyScrapeRoute { // the client's scrape URL for the proxy
+		i := strings.LastIndex(tmpl, "/announce")
+		if i < 0 {
+			passkeyFailure(w, "passkey: tracker has no scrape URL")
+			return
+		}
+		tmpl = tmpl[:i] + "/scrape" + tmpl[i+len("/announce"):]
+	}
+	target, err := fillPasskey(tmpl)
+	if err != nil {
+		passkeyFailure(w, "passkey: "+err.Error())
+		return
+	}
+	if len(rest) > 0 {
+		sep := "?"
+		if strings.Contains(target, "?") {
+			sep = "&"
+		}
+		target += sep + strings.Join(rest, "&")
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), passkeyTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		passkeyFailure(w, "passkey: bad tracker URL") // err would quote the passkey
+		return
+	}
+	req.Header.Set("User-Agent", r.Header.Get("User-Agent")) // trackers whitelist clients
+	resp, err := passkeyClient.Do(req)
+	if err != nil {
+		passkeyFailure(w, "passkey: tracker unreachable") // err would quote the passkey
+		return
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "" {
+		w.Header().Set("Content-Type", ct)
+	}
+	w.WriteHeader(resp.StatusCode)
+	_, _ = io.Copy(w, io.LimitReader(resp.Body, maxAnnounceResponse))
+}
+
+// passkeyFailure answers an announce with a BEP 3 failure reason.
+func passkeyFailure(w http.ResponseWriter, reason string) {
+	w.Header().Set("Content-Type", "text/plain")
+	_ = bencode.NewEncoder(w).Encode(map[string]string{"failure reason": reason})
+}
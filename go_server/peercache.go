@@ -0,0 +1,161 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent"
+
+	"github.com/roxbox/torrent_server/engine"
+)
+
+// ── Peer cache ────────────────────────────────────────────────────────────────
+// Peers that actually delivered data are remembered per infohash, plus a
+// global "hot" list across all torrents. On (re-)add they're dialed at once,
+// long before the first tracker or DHT response comes back.
+
+const (
+	peerCacheFile     = "peers.json"
+	peersPerTorrent   = 50
+	hotPeersMax       = 100
+	peerCacheMaxAge   = 7 * 24 * time.Hour
+	peerCacheInterval = 30 * time.Second
+)
+
+type cachedPeer struct {
+	Addr     string    `json:"addr"`
+	LastSeen time.Time `json:"last_seen"`
+	Hits     int       `json:"hits"` // snapshots in which the peer was delivering
+}
+
+type peerCacheState struct {
+	Torrents map[string][]cachedPeer `json:"torrents"`
+	Hot      []cachedPeer            `json:"hot"`
+}
+
+var (
+	peerCacheMu sync.Mutex
+	peerCache   = peerCacheState{Torrents: map[string][]cachedPeer{}}
+	peerLoops   = map[string]*torrent.Torrent{} // infohash → the torrent its peerCacheLoop watches
+)
+
+func loadPeerCache() {
+	peerCacheMu.Lock()
+	defer peerCacheMu.Unlock()
+	if err := loadJSON(peerCacheFile, &peerCache); err != nil {
+		log.Printf("peercache: load: %v", err)
+	}
+	if peerCache.Torrents == nil {
+		peerCache.Torrents = map[string][]cachedPeer{}
+	}
+}
+
+// seedCachedPeers hands every remembered peer for t (and the global hot
+// list) to the client so dialing starts immediately.
+func seedCachedPeers(t *torrent.Torrent) {
+	peerCacheMu.Lock()
+	seen := map[string]bool{}
+	var infos []torrent.PeerInfo
+	for _, list := range [][]cachedPeer{peerCache.Torrents[t.InfoHash().HexString()], peerCache.Hot} {
+		for _, p := range list {
+			if seen[p.Addr] || time.Since(p.LastSeen) > peerCacheMaxAge {
+				continue
+			}
+			seen[p.Addr] = true
+			infos = append(infos, torrent.PeerInfo{
+				Addr:   torrent.StringAddr(p.Addr),
+				Source: torrent.PeerSourceDirect,
+			})
+		}
+	}
+	peerCacheMu.Unlock()
+
+	if len(infos) > 0 {
+		n := t.AddPeers(infos)
+		log.Printf("peercache: dialing %d cached peers", n)
+	}
+}
+
+// peerCacheLoop periodically records peers currently sending us data.
+// Every way a torrent comes in starts it, so it runs once per infohash: a
+// second call for the same torrent returns at once.
+func peerCacheLoop(t *torrent.Torrent) {
+	ih := t.InfoHash().HexString()
+	peerCacheMu.Lock()
+	if peerLoops[ih] == t {
+		peerCacheMu.Unlock()
+		return
+	}
+	peerLoops[ih] = t // a re-added torrent takes over from the closed one
+	peerCacheMu.Unlock()
+	defer func() {
+		peerCacheMu.Lock()
+		if peerLoops[ih] == t {
+			delete(peerLoops, ih)
+		}
+		peerCacheMu.Unlock()
+	}()
+	for {
+		select {
+		case <-t.Closed():
+			return
+		case <-time.After(peerCacheInterval):
+		}
+		var good []string
+		for _, pc := range t.PeerConns() {
+			if pc.DownloadRate() > 0 {
+				good = append(good, pc.RemoteAddr.String())
+			}
+		}
+		if len(good) == 0 {
+			continue
+		}
+
+		now := time.Now()
+		peerCacheMu.Lock()
+		peerCache.Torrents[ih] = mergePeers(peerCache.Torrents[ih], good, now, peersPerTorrent)
+		if !engine.IsPrivate(t) { // a private swarm stays with its torrent
+			peerCache.Hot = mergePeers(peerCache.Hot, good, now, hotPeersMax)
+		}
+		for h, list := range peerCache.Torrents {
+			if len(list) > 0 && now.Sub(list[0].LastSeen) > peerCacheMaxAge {
+				delete(peerCache.Torrents, h)
+			}
+		}
+		err := saveJSON(peerCacheFile, &peerCache)
+		peerCacheMu.Unlock()
+		if err != nil {
+			log.Printf("peercache: save: %v", err)
+		}
+	}
+}
+
+// mergePeers folds addrs into list and keeps the best max entries, most
+// recently seen (then most reliable) first.
+func mergePeers(list []cachedPeer, addrs []string, now time.Time, max int) []cachedPeer {
+	idx := map[string]int{}
+	for i, p := range list {
+		idx[p.Addr] = i
+	}
+	for _, a := range addrs {
+		if i, ok := idx[a]; ok {
+			list[i].LastSeen = now
+			list[i].Hits++
+			continue
+		}
+		idx[a] = len(list)
+		list = append(list, cachedPeer{Addr: a, LastSeen: now, Hits: 1})
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		if !list[i].LastSeen.Equal(list[j].LastSeen) {
+			return list[i].LastSeen.After(list[j].LastSeen)
+		}
+		return list[i].Hits > list[j].Hits
+	})
+	if len(list) > max {
+		list = list[:max]
+	}
+	return list
+}
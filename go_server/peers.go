@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+// ── Peer hints ────────────────────────────────────────────────────────────────
+// Known peers get a torrent going when its trackers are dead: a magnet's
+// x.pe= addresses (BEP 9, dialled by the client as the torrent is added),
+// the add's "peers" list, and addresses posted later to
+// /torrents/{hash}/peers. All are host:port.
+
+const maxPeerHints = 200
+
+// peerInfos checks host:port addresses and makes PeerInfos of them.
+func peerInfos(addrs []string) ([]torrent.PeerInfo, error) {
+	if len(addrs) > maxPeerHints {
+		return nil, fmt.Errorf("at most %d peers", maxPeerHints)
+	}
+	infos := make([]torrent.PeerInfo, 0, len(addrs))
+	for _, a := range addrs {
+		host, port, err := net.SplitHostPort(a)
+		if n, perr := strconv.Atoi(port); err != nil || perr != nil || host == "" || n < 1 || n > 65535 {
+			return nil, fmt.Errorf("peer %q: want host:port", a)
+		}
+		infos = append(infos, torrent.PeerInfo{Addr: torrent.StringAddr(a), Source: torrent.PeerSourceDirect, Trusted: true})
+	}
+	return infos, nil
+}
+
+// addPeerHints gives t the add's peers and logs how many hints it had.
+func addPeerHints(t *torrent.Torrent, req addRequest) {
+	if m, err := metainfo.ParseMagnetUri(req.Magnet); err == nil && len(m.Params["x.pe"]) > 0 {
+		log.Printf("%d peer hints from x.pe", len(m.Params["x.pe"]))
+	}
+	if infos, _ := peerInfos(req.Peers); len(infos) > 0 { // checked by validate
+		log.Printf("added %d of %d peers from the request", t.AddPeers(infos), len(infos))
+	}
+}
+
+// ── POST /torrents/{hash}/peers ───────────────────────────────────────────────
+// {"peers": ["1.2.3.4:6881", …]} or repeated peer= params.
+func handleTorrentPeers(w http.ResponseWriter, r *http.Request, sess *session) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST only", 405)
+		return
+	}
+	var body struct {
+		Peers []string `json:"peers"`
+	}
+	if mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mt == "application/json" {
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxAddBody)).Decode(&body); err != nil {
+			http.Error(w, "invalid JSON body: "+err.Error(), 400)
+			return
+		}
+	} else {
+		_ = r.ParseForm()
+		body.Peers = r.Form["peer"]
+	}
+	infos, err := peerInfos(body.Peers)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	if len(infos) == 0 {
+		http.Error(w, "no peers given", 400)
+		return
+	}
+	t, _ := sess.current()
+	if t == nil {
+		http.Error(w, "no active torrent", 503)
+		return
+	}
+	n := t.AddPeers(infos)
+	sess.event(requestID(r), fmt.Sprintf("added %d of %d posted peers", n, len(infos)))
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]int{"added": n, "posted": len(infos)})
+}
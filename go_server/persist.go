@@ -0,0 +1,64 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// newID returns a random hex identifier for persisted records.
+func newID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// loadJSON reads cacheDir/<name> into v. A missing file is not an error:
+// v is simply left untouched so callers keep their defaults.
+func loadJSON(name string, v any) error {
+	return loadJSONAt(filepath.Join(cacheDir, name), v)
+}
+
+// saveJSON writes v to cacheDir/<name>.
+func saveJSON(name string, v any) error {
+	return saveJSONAt(filepath.Join(cacheDir, name), v)
+}
+
+func loadJSONAt(path string, v any) error {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if isSealed(b) {
+		if b, err = unseal(b); err != nil {
+			return err
+		}
+	}
+	return json.Unmarshal(b, v)
+}
+
+// saveJSONAt writes via a temp file + rename so a crash mid-write never
+// leaves a truncated state file behind. With a seal key configured the
+// file is encrypted (secrets.go).
+func saveJSONAt(path string, v any) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if sealKey != nil {
+		if b, err = seal(b); err != nil {
+			return err
+		}
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
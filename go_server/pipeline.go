@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/roxbox/torrent_server/engine"
+)
+
+// ── Request pipeline tuning ───────────────────────────────────────────────────
+// Each peer connection's request queue is sized from its measured
+// bandwidth-delay product (engine/pipeline.go), so high-latency links keep
+// enough requests in flight. -static-pipeline (or ROXBOX_STATIC_PIPELINE=1)
+// goes back to the depths the peers ask for, to compare.
+
+var staticPipelineFlag = flag.Bool("static-pipeline", false, "keep the request queue depths peers ask for instead of tuning them per connection (env ROXBOX_STATIC_PIPELINE)")
+
+func init() {
+	capabilityProbes["pipeline"] = func() capability {
+		if engine.DefaultPipeline.Static {
+			return capability{Compiled: true, Detail: "off (-static-pipeline)"}
+		}
+		st := engine.DefaultPipeline.Stats()
+		detail := "no connection tuned yet"
+		if st.Peers > 0 {
+			detail = fmt.Sprintf("%d connections, %d–%d requests in flight", st.Peers, st.MinDepth, st.MaxDepth)
+		}
+		return capability{Compiled: true, Enabled: true, Detail: detail}
+	}
+}
+
+// applyPipeline reads -static-pipeline for the clients built from now on.
+func applyPipeline() {
+	static := *staticPipelineFlag
+	if v := os.Getenv("ROXBOX_STATIC_PIPELINE"); v != "" && !static {
+		static, _ = strconv.ParseBool(v)
+	}
+	if static {
+		engine.DefaultPipeline.Static = true
+		log.Println("Request pipeline tuning: off")
+	}
+}
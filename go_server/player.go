@@ -0,0 +1,271 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/anacrolix/torrent"
+
+	"github.com/roxbox/torrent_server/engine"
+)
+
+// ── Player state & trickle mode ───────────────────────────────────────────────
+// The app reports playing / paused / buffering. When the player stays paused
+// longer than the trickle delay we stop the bulk download: readers shrink to
+// a small readahead and the file drops to no priority, so only what a
+// reader actually touches is fetched. Play or buffering restores everything
+// immediately.
+
+const trickleReadahead = 1 << 20 // 1 MB
+
+var trickleDelay = 30 * time.Second
+
+func init() {
+	if s := os.Getenv("ROXBOX_PAUSE_TRICKLE_SECS"); s != "" {
+		if n, err := strconv.Atoi(s); err == nil && n >= 0 {
+			trickleDelay = time.Duration(n) * time.Second
+		}
+	}
+}
+
+// trackedReader is a /stream reader registered with its session, so its
+// readahead can be adjusted while it's being served and /debug/priorities
+// can show where it is. Close unregisters it.
+type trackedReader struct {
+	torrent.Reader
+	sess      *session
+	pos       atomic.Int64
+	readahead atomic.Int64
+	base      int64       // readahead it was opened with, restored after trickle
+	paced     atomic.Bool // readahead cut by backpressure (backpressure.go)
+}
+
+// trackReader registers r, opened with the given readahead.
+func (s *session) trackReader(r torrent.Reader, readahead int64) *trackedReader {
+	tr := &trackedReader{Reader: r, sess: s, base: readahead}
+	tr.readahead.Store(readahead)
+	s.readersMu.Lock()
+	s.readers[tr] = struct{}{}
+	s.readersMu.Unlock()
+
+	s.playerMu.Lock()
+	if s.trickleOn {
+		tr.SetReadahead(trickleReadahead)
+	}
+	s.playerMu.Unlock()
+	return tr
+}
+
+func (r *trackedReader) Read(p []byte) (int, error) {
+	r.sess.active()
+	r.sess.resumeAddPaused()
+	n, err := r.Reader.Read(p)
+	r.pos.Add(int64(n))
+	return n, err
+}
+
+func (r *trackedReader) Seek(off int64, whence int) (int64, error) {
+	pos, err := r.Reader.Seek(off, whence)
+	if err == nil {
+		r.pos.Store(pos)
+	}
+	return pos, err
+}
+
+func (r *trackedReader) SetReadahead(n int64) {
+	r.readahead.Store(n)
+	r.Reader.SetReadahead(n)
+}
+
+func (r *trackedReader) Close() error {
+	r.sess.readersMu.Lock()
+	delete(r.sess.readers, r)
+	r.sess.readersMu.Unlock()
+	return r.Reader.Close()
+}
+
+func (s *session) setReadersReadahead(n int64) {
+	s.readersMu.Lock()
+	defer s.readersMu.Unlock()
+	for r := range s.readers {
+		r.SetReadahead(n)
+	}
+}
+
+// trickling reports whether the session is in trickle mode.
+func (s *session) trickling() bool {
+	s.playerMu.Lock()
+	defer s.playerMu.Unlock()
+	return s.trickleOn
+}
+
+// restoreReadersReadahead puts every reader back on its own readahead.
+func (s *session) restoreReadersReadahead() {
+	s.readersMu.Lock()
+	defer s.readersMu.Unlock()
+	for r := range s.readers {
+		r.paced.Store(false)
+		r.SetReadahead(r.base)
+	}
+}
+
+// ── POST /player/state?state=playing|paused|buffering[&position=&duration=] ──
+func handlePlayerState(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST only", 405)
+		return
+	}
+	sess := sessionFor(w, r)
+	if sess == nil {
+		return
+	}
+	sess.active()
+	_ = r.ParseForm()
+	state := r.FormValue("state")
+	switch state {
+	case "playing", "paused", "buffering":
+	default:
+		http.Error(w, "state must be playing, paused or buffering", 400)
+		return
+	}
+
+	var position, duration float64
+	if p := r.FormValue("position"); p != "" {
+		var err error
+		if position, err = strconv.ParseFloat(p, 64); err != nil || position < 0 {
+			http.Error(w, "position must be seconds >= 0", 400)
+			return
+		}
+	}
+	if d := r.FormValue("duration"); d != "" {
+		var err error
+		if duration, err = strconv.ParseFloat(d, 64); err != nil || duration < 0 {
+			http.Error(w, "duration must be seconds >= 0", 400)
+			return
+		}
+	}
+
+	sess.playerMu.Lock()
+	if sess.playerTimer != nil {
+		sess.playerTimer.Stop()
+		sess.playerTimer = nil
+	}
+	if state == "paused" {
+		sess.playerTimer = time.AfterFunc(trickleDelay, sess.enterTrickle)
+	} else if sess.trickleOn {
+		sess.leaveTrickle()
+	}
+	sess.playerMu.Unlock()
+	if state == "playing" {
+		sess.resumeAddPaused()
+	}
+
+	sess.mu.Lock()
+	sess.status.PlayerState = state
+	if position > 0 {
+		sess.status.PositionSec = position
+	}
+	if duration > 0 {
+		sess.status.DurationSec = duration
+	}
+	sess.touch()
+	sess.mu.Unlock()
+
+	w.WriteHeader(200)
+	fmt.Fprint(w, state)
+}
+
+func (s *session) enterTrickle() {
+	t, f := s.current()
+
+	s.playerMu.Lock()
+	defer s.playerMu.Unlock()
+	if f == nil || s.trickleOn {
+		return
+	}
+	s.trickleOn = true
+	s.setReadersReadahead(trickleReadahead)
+	s.trickleFile, s.trickleHeld = f, engine.HoldFile(t, f)
+
+	s.mu.Lock()
+	s.status.Trickle = true
+	s.touch()
+	s.mu.Unlock()
+	log.Println("Player paused, entering trickle mode")
+}
+
+// leaveTrickle restores full streaming priorities. Caller holds playerMu.
+func (s *session) leaveTrickle() {
+	s.trickleOn = false
+	s.mu.Lock()
+	t, f := s.torr, s.file
+	s.status.Trickle = false
+	s.touch()
+	s.mu.Unlock()
+
+	s.restoreReadersReadahead()
+	if t != nil && f != nil {
+		if s.trickleFile == f {
+			engine.ReleaseFile(t, s.trickleHeld)
+		}
+		s.prioritise(t, f)
+	}
+	s.trickleFile, s.trickleHeld = nil, nil
+	log.Println("Player resumed, leaving trickle mode")
+}
+
+// pauseUntilRead holds t at metadata only for an add with paused set,
+// unless another session is downloading it.
+func (s *session) pauseUntilRead(t *torrent.Torrent) {
+	if torrentInUseElsewhere(t, s) {
+		return
+	}
+	t.DisallowDataDownload()
+	s.playerMu.Lock()
+	s.addPaused = true
+	s.playerMu.Unlock()
+	s.mu.Lock()
+	s.status.Paused = true
+	s.touch()
+	s.mu.Unlock()
+}
+
+// resumeAddPaused starts the download of a session added paused.
+func (s *session) resumeAddPaused() {
+	s.playerMu.Lock()
+	paused, held := s.addPaused, s.limitHeld
+	s.addPaused = false
+	s.playerMu.Unlock()
+	if !paused {
+		return
+	}
+	t, _ := s.current()
+	if t != nil && !held {
+		t.AllowDataDownload()
+	}
+	kickLimits() // a read ranks it higher
+	s.mu.Lock()
+	s.status.Paused = false
+	s.touch()
+	s.mu.Unlock()
+	s.event("", "resumed after paused add")
+}
+
+// resetPlayerState forgets the player state when the session goes away.
+func (s *session) resetPlayerState() {
+	s.playerMu.Lock()
+	defer s.playerMu.Unlock()
+	if s.playerTimer != nil {
+		s.playerTimer.Stop()
+		s.playerTimer = nil
+	}
+	s.trickleOn = false
+	s.idlePaused = false
+	s.addPaused = false
+	s.limitHeld = false
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/bencode"
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/anacrolix/torrent/storage"
+	"github.com/anacrolix/torrent/types"
+
+	"github.com/roxbox/torrent_server/engine"
+)
+
+const testPieceLength = 16 << 10
+
+// offlineTorrent adds a torrent of files with the given lengths to a client
+// of its own, with no data and no peers: every piece stays incomplete.
+func offlineTorrent(t *testing.T, lengths ...int64) *torrent.Torrent {
+	t.Helper()
+	info := metainfo.Info{Name: "show", PieceLength: testPieceLength}
+	var total int64
+	for i, n := range lengths {
+		info.Files = append(info.Files, metainfo.FileInfo{Path: []string{string(rune('a'+i)) + ".mkv"}, Length: n})
+		total += n
+	}
+	info.Pieces = make([]byte, (total+testPieceLength-1)/testPieceLength*20)
+	mi := metainfo.MetaInfo{}
+	var err error
+	if mi.InfoBytes, err = bencode.Marshal(info); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	cfg := loopbackConfig(torrent.NewDefaultClientConfig())
+	cfg.DataDir = dir
+	cfg.DefaultStorage = storage.NewFile(dir)
+	cl, err := torrent.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cl.Close() })
+	tor, err := cl.AddTorrent(&mi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tor.VerifyData() // settles the pieces' completion
+	return tor
+}
+
+func piecePriorities(tor *torrent.Torrent, f *torrent.File) []types.PiecePriority {
+	span := engine.PieceRange(f)
+	var prios []types.PiecePriority
+	for i := span.Begin; i < span.End; i++ {
+		prios = append(prios, tor.PieceState(i).Priority)
+	}
+	return prios
+}
+
+func TestTrickleHoldsPieces(t *testing.T) {
+	tor := offlineTorrent(t, 40*testPieceLength+100, 3*testPieceLength)
+	f := tor.Files()[0]
+	s := newSession(&profile{})
+	s.torr, s.file = tor, f
+	s.prioritise(tor, f)
+	before := piecePriorities(tor, f)
+	for i, p := range before {
+		if p == torrent.PiecePriorityNone {
+			t.Fatalf("piece %d not wanted before trickle mode", i)
+		}
+	}
+
+	s.enterTrickle()
+	for i, p := range piecePriorities(tor, f) {
+		if p != torrent.PiecePriorityNone {
+			t.Errorf("piece %d at priority %d in trickle mode, want none", i, p)
+		}
+	}
+
+	s.playerMu.Lock()
+	s.leaveTrickle()
+	s.playerMu.Unlock()
+	for i, p := range piecePriorities(tor, f) {
+		if p != before[i] {
+			t.Errorf("piece %d at priority %d after trickle mode, want %d", i, p, before[i])
+		}
+	}
+}
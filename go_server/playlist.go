@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/roxbox/torrent_server/engine"
+)
+
+// ── GET /playlist.m3u ─────────────────────────────────────────────────────────
+// The videos and audio files of the session's torrent as an M3U8 playlist,
+// in episode order (engine.PlaylistFiles), so a player can go through a
+// season pack or an album without the app picking each file. Every entry
+// is the stream URL with file=<index>, so the session switches to a file
+// as the player reaches it (selectfile.go) and downloads what is playing.
+
+// playlistEntry is the stream URL for file i of the session's torrent.
+func (s *session) playlistEntry(i int) string {
+	u := s.streamURL()
+	sep := "?"
+	if strings.Contains(u, "?") {
+		sep = "&"
+	}
+	return u + sep + "file=" + strconv.Itoa(i)
+}
+
+func handlePlaylist(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "GET only", 405)
+		return
+	}
+	if sess := sessionFor(w, r); sess != nil {
+		writePlaylist(w, sess)
+	}
+}
+
+// writePlaylist answers /playlist.m3u for sess (/torrents/{hash}/playlist.m3u too).
+func writePlaylist(w http.ResponseWriter, sess *session) {
+	sess.mu.RLock()
+	t, local := sess.torr, sess.local
+	sess.mu.RUnlock()
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n")
+	switch {
+	case local != nil:
+		fmt.Fprintf(&b, "#EXTINF:-1,%s\n%s\n", m3uTitle(local.Path), sess.streamURL())
+	case t == nil || t.Info() == nil:
+		http.Error(w, errNoFileYet.Error(), 503)
+		return
+	default:
+		for _, f := range engine.PlaylistFiles(t, sess.profile.selectFilter()) {
+			fmt.Fprintf(&b, "#EXTINF:-1,%s\n%s\n", m3uTitle(f.DisplayPath()), sess.playlistEntry(fileIndex(t, f)))
+		}
+	}
+	w.Header().Set("Content-Type", "audio/x-mpegurl; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="playlist.m3u8"`)
+	_, _ = w.Write([]byte(b.String()))
+}
+
+// m3uTitle is the entry title for a file: its name without the extension,
+// on one line.
+func m3uTitle(p string) string {
+	name := path.Base(strings.ReplaceAll(p, `\`, "/"))
+	name = strings.TrimSuffix(name, path.Ext(name))
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(name)
+}
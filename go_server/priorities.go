@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/anacrolix/torrent/types"
+
+	"github.com/roxbox/torrent_server/engine"
+)
+
+// ── GET /debug/priorities ─────────────────────────────────────────────────────
+// The effective priority of every piece of the active file, next to where
+// each /stream reader is and how far its readahead reaches, to check the
+// head/tail boost and reader-driven priorities against what was intended.
+
+type readerWindow struct {
+	Offset     int64 `json:"offset"`          // within the file
+	Readahead  int64 `json:"readahead"`       // bytes
+	Piece      int   `json:"piece"`           // torrent piece index at offset
+	AheadUntil int   `json:"ahead_until"`     // last piece covered by readahead
+	Paced      bool  `json:"paced,omitempty"` // readahead cut by backpressure
+}
+
+type pieceEntry struct {
+	Index    int    `json:"index"`
+	Priority string `json:"priority"`
+	Complete bool   `json:"complete"`
+	Partial  bool   `json:"partial,omitempty"`
+}
+
+type prioritiesResponse struct {
+	File        string         `json:"file"`
+	FileOffset  int64          `json:"file_offset"` // in the torrent
+	FileLength  int64          `json:"file_length"`
+	PieceLength int64          `json:"piece_length"`
+	Trickle     bool           `json:"trickle"`
+	Readers     []readerWindow `json:"readers"`
+	Pieces      []pieceEntry   `json:"pieces"`
+}
+
+func priorityName(p types.PiecePriority) string {
+	switch p {
+	case types.PiecePriorityNone:
+		return "none"
+	case types.PiecePriorityNormal:
+		return "normal"
+	case types.PiecePriorityHigh:
+		return "high"
+	case types.PiecePriorityReadahead:
+		return "readahead"
+	case types.PiecePriorityNext:
+		return "next"
+	case types.PiecePriorityNow:
+		return "now"
+	}
+	return "unknown"
+}
+
+func handlePriorities(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "GET only", 405)
+		return
+	}
+	sess := sessionFor(w, r)
+	if sess == nil {
+		return
+	}
+	t, f := sess.current()
+	if t == nil || f == nil || t.Info() == nil {
+		http.Error(w, "no active torrent", 503)
+		return
+	}
+	span := engine.PieceRange(f)
+	resp := prioritiesResponse{
+		File:        f.DisplayPath(),
+		FileOffset:  span.FileOffset,
+		FileLength:  span.FileLength,
+		PieceLength: span.PieceLength,
+		Readers:     []readerWindow{},
+	}
+	sess.playerMu.Lock()
+	resp.Trickle = sess.trickleOn
+	sess.playerMu.Unlock()
+
+	sess.readersMu.Lock()
+	for rd := range sess.readers {
+		pos, ahead := rd.pos.Load(), rd.readahead.Load()
+		resp.Readers = append(resp.Readers, readerWindow{
+			Offset:     pos,
+			Readahead:  ahead,
+			Piece:      span.PieceAt(pos),
+			AheadUntil: span.PieceAt(pos + ahead - 1),
+			Paced:      rd.paced.Load(),
+		})
+	}
+	sess.readersMu.Unlock()
+
+	for i := span.Begin; i < span.End; i++ {
+		ps := t.PieceState(i)
+		resp.Pieces = append(resp.Pieces, pieceEntry{
+			Index:    i,
+			Priority: priorityName(ps.Priority),
+			Complete: ps.Complete,
+			Partial:  ps.Partial,
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
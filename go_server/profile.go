@@ -0,0 +1,444 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/anacrolix/torrent/storage"
+
+	"github.com/roxbox/torrent_server/engine"
+)
+
+// ── Profiles ──────────────────────────────────────────────────────────────────
+// The app can namespace everything by a profile ID (X-Roxbox-Profile header
+// or ?profile=). Each profile has its own streaming session, torrent data
+// directory, settings/policies and watch history. Requests without a
+// profile use "default", which lives directly in the cache dir as before.
+//
+// The client holds one torrent per infohash, so a torrent open in one
+// profile can't be added in another: it would share the first profile's
+// storage and priorities, and stopping it in one would stop it in both.
+// Such an add fails until the first profile lets go. There are at most
+// maxProfiles profiles besides the default one.
+
+const (
+	defaultProfile  = "default"
+	profileHeader   = "X-Roxbox-Profile"
+	historyFile     = "history.json"
+	settingsFile    = "settings.json"
+	historyMaxItems = 200
+	maxProfiles     = 32
+)
+
+var profileIDRe = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+var errProfileLimit = fmt.Errorf("at most %d profiles besides the default one", maxProfiles)
+
+// profileSettings are per-profile policies enforced when a session starts.
+type profileSettings struct {
+	streamPolicy
+
+	// Auto-selection filters (engine.Filter). A null deny list means
+	// engine.DefaultDenyExts; [] allows every extension. Likewise a null
+	// avoid list means engine.DefaultAvoidWords (sample, extras, …): files
+	// with one of these words in their path are picked only when nothing
+	// else is left.
+	SelectDenyExts     []string `json:"select_deny_exts"`
+	SelectMinSizeMB    int64    `json:"select_min_size_mb,omitempty"`
+	SelectExcludePaths []string `json:"select_exclude_paths,omitempty"` // regexps, case-insensitive
+	SelectAvoidWords   []string `json:"select_avoid_words"`
+	// Containers to pick first, best first (".mp4", "mkv"): a file in one
+	// wins over a larger file in a container listed later or not at all.
+	SelectPreferExts []string `json:"select_prefer_exts,omitempty"`
+
+	// Idle session limits in minutes (idle.go); 0 is the default, <0 never.
+	IdlePauseMins int `json:"idle_pause_mins,omitempty"`
+	IdleDropMins  int `json:"idle_drop_mins,omitempty"`
+
+	// Data saver (datasaver.go): download only this many seconds past the
+	// playback position; 0 is off. Priorities follow from the next add.
+	DataSaverSecs int `json:"data_saver_secs,omitempty"`
+
+	// Bandwidth tiers (bandwidth.go): percent of the download rate that
+	// background fill may use while a playback window has missing pieces.
+	// 0 is the default (25), 100 turns the tiers off, <0 pauses fill then.
+	FillSharePct int `json:"fill_share_pct,omitempty"`
+	// The order fill takes the rest of the file in: "rarest" (the default)
+	// takes the pieces fewest peers have first, "sequential" goes on from
+	// the playback window.
+	FillOrder string `json:"fill_order,omitempty"`
+}
+
+// validate checks settings before they are stored or taken from disk: by
+// PUT /profile/settings, a restored snapshot and a reload.
+func (s profileSettings) validate() error {
+	if _, err := regexp.Compile(s.BlockPattern); err != nil {
+		return fmt.Errorf("block_pattern: %v", err)
+	}
+	for _, pat := range s.SelectExcludePaths {
+		if _, err := regexp.Compile(pat); err != nil {
+			return fmt.Errorf("select_exclude_paths: %v", err)
+		}
+	}
+	if !validFillOrder(s.FillOrder) {
+		return errors.New(`fill_order: "rarest" or "sequential"`)
+	}
+	return nil
+}
+
+// streamPolicy limits what may be streamed: a profile's standing policy,
+// or an extra one attached to a single /add.
+type streamPolicy struct {
+	// BlockPattern rejects torrents whose name or selected file matches.
+	BlockPattern  string `json:"block_pattern,omitempty"`
+	MaxFileSizeMB int64  `json:"max_file_size_mb,omitempty"`
+}
+
+type historyEntry struct {
+	Time     time.Time `json:"time"`
+	InfoHash string    `json:"info_hash"`
+	Name     string    `json:"name"`
+	File     string    `json:"file"`
+}
+
+type profile struct {
+	ID      string
+	dir     string             // torrent data + state files
+	storage storage.ClientImpl // nil: the client's default storage
+	sess    *session
+
+	mu         sync.Mutex
+	settings   profileSettings
+	history    []historyEntry
+	background map[string]*session // infohash → session (torrents.go)
+	queue      *downloadQueue      // queue.go
+}
+
+var (
+	profilesMu sync.Mutex
+	profiles   = map[string]*profile{}
+
+	ownersMu sync.Mutex
+	owners   = map[*torrent.Torrent]*profile{} // who added each torrent, until it closes
+
+	errOtherProfile = errors.New("this torrent is open in another profile; stop it there first")
+)
+
+// getProfile returns the profile with the given (validated) ID, creating
+// it and loading its persisted state on first use.
+func getProfile(id string) *profile {
+	profilesMu.Lock()
+	defer profilesMu.Unlock()
+	if p := profiles[id]; p != nil {
+		return p
+	}
+	p := &profile{ID: id, dir: cacheDir}
+	if id != defaultProfile {
+		p.dir = filepath.Join(cacheDir, "profiles", id)
+		_ = os.MkdirAll(p.dir, 0755)
+		p.storage = engine.DefaultIOThrottle.Wrap(storage.NewFileByInfoHash(p.dir))
+	}
+	p.sess = newSession(p)
+	if err := loadJSONAt(filepath.Join(p.dir, settingsFile), &p.settings); err != nil {
+		log.Printf("profile %s: load settings: %v", id, err)
+	}
+	if err := loadJSONAt(filepath.Join(p.dir, historyFile), &p.history); err != nil {
+		log.Printf("profile %s: load history: %v", id, err)
+	}
+	p.loadQueue()
+	profiles[id] = p
+	return p
+}
+
+// profileFor resolves the profile a request belongs to.
+func profileFor(r *http.Request) (*profile, error) {
+	id := r.Header.Get(profileHeader)
+	if id == "" {
+		id = r.URL.Query().Get("profile")
+	}
+	if id == "" {
+		id = defaultProfile
+	}
+	if !profileIDRe.MatchString(id) {
+		return nil, fmt.Errorf("invalid profile id")
+	}
+	if !profileRoom(id) {
+		return nil, errProfileLimit
+	}
+	return getProfile(id), nil
+}
+
+// profileRoom reports whether profile id exists or another may be made.
+func profileRoom(id string) bool { return profilesRoom([]string{id}) }
+
+// profilesRoom reports whether the profiles ids exist or fit beside the
+// others, all of them together.
+func profilesRoom(ids []string) bool {
+	have := map[string]bool{defaultProfile: true}
+	profilesMu.Lock()
+	for id := range profiles {
+		have[id] = true
+	}
+	profilesMu.Unlock()
+	entries, _ := os.ReadDir(filepath.Join(cacheDir, "profiles"))
+	for _, e := range entries {
+		have[e.Name()] = true
+	}
+	added := 0
+	for _, id := range ids {
+		if !have[id] {
+			have[id] = true
+			added++
+		}
+	}
+	return added == 0 || len(entries)+added <= maxProfiles
+}
+
+// sessionFor returns the request's session, or writes a 400 and returns nil.
+func sessionFor(w http.ResponseWriter, r *http.Request) *session {
+	p, err := profileFor(r)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return nil
+	}
+	return p.sess
+}
+
+// query is the suffix that routes a URL to this profile (players can't set
+// headers, so stream URLs carry it in the query string).
+func (p *profile) query() string {
+	if p.ID == defaultProfile {
+		return ""
+	}
+	return "?profile=" + url.QueryEscape(p.ID)
+}
+
+// addSpec adds a torrent backed by this profile's data directory. A
+// torrent another profile added, or one added outside any profile (which
+// lives in the default storage), is refused.
+func (p *profile) addSpec(spec *torrent.TorrentSpec) (*torrent.Torrent, error) {
+	ownersMu.Lock()
+	defer ownersMu.Unlock()
+	if t, ok := currentClient().Torrent(spec.InfoHash); ok {
+		if owner, known := owners[t]; known && owner != p || !known && p.storage != nil {
+			return nil, errOtherProfile
+		}
+	}
+	if p.storage != nil {
+		spec.Storage = p.storage
+	}
+	t, err := addSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+	if _, known := owners[t]; !known {
+		owners[t] = p
+		go func() {
+			<-t.Closed()
+			ownersMu.Lock()
+			delete(owners, t)
+			ownersMu.Unlock()
+		}()
+	}
+	return t, nil
+}
+
+// addMagnet adds uri; extra trackers are appended, one tier each.
+func (p *profile) addMagnet(uri string, trackers ...string) (*torrent.Torrent, error) {
+	spec, err := torrent.TorrentSpecFromMagnetUri(uri)
+	if err != nil {
+		return nil, err
+	}
+	for _, tr := range trackers {
+		spec.Trackers = append(spec.Trackers, []string{tr})
+	}
+	return p.addSpec(spec)
+}
+
+// addMetainfo adds a parsed .torrent; extra trackers as for addMagnet.
+func (p *profile) addMetainfo(mi *metainfo.MetaInfo, trackers ...string) (*torrent.Torrent, error) {
+	spec, err := torrent.TorrentSpecFromMetaInfoErr(mi)
+	if err != nil {
+		return nil, err
+	}
+	for _, tr := range trackers {
+		spec.Trackers = append(spec.Trackers, []string{tr})
+	}
+	return p.addSpec(spec)
+}
+
+// addByURI is the profile-aware counterpart of the package-level addByURI.
+func (p *profile) addByURI(uri string, auth fetchAuth) (*torrent.Torrent, error) {
+	if strings.HasPrefix(uri, "magnet:") {
+		return p.addMagnet(uri)
+	}
+	mi, err := fetchMetainfo(uri, auth)
+	if err != nil {
+		return nil, err
+	}
+	return p.addMetainfo(mi)
+}
+
+// checkPolicy enforces the profile's settings against the picked file.
+func (p *profile) checkPolicy(t *torrent.Torrent, f *torrent.File) error {
+	p.mu.Lock()
+	set := p.settings
+	p.mu.Unlock()
+	return set.streamPolicy.check(t, f, "profile")
+}
+
+// check applies the policy to the picked file; scope names it in errors.
+func (pol streamPolicy) check(t *torrent.Torrent, f *torrent.File, scope string) error {
+	if pol.BlockPattern != "" {
+		re, err := regexp.Compile("(?i)" + pol.BlockPattern)
+		if err == nil && (re.MatchString(t.Name()) || re.MatchString(f.DisplayPath())) {
+			return fmt.Errorf("blocked by %s policy", scope)
+		}
+	}
+	if pol.MaxFileSizeMB > 0 && f.Length() > pol.MaxFileSizeMB<<20 {
+		return fmt.Errorf("file exceeds %s size limit of %d MB", scope, pol.MaxFileSizeMB)
+	}
+	return nil
+}
+
+// selectFilter builds the auto-selection filter from the settings.
+func (p *profile) selectFilter() engine.Filter {
+	p.mu.Lock()
+	set := p.settings
+	p.mu.Unlock()
+
+	fl := engine.Filter{DenyExts: engine.DefaultDenyExts, MinSize: set.SelectMinSizeMB << 20}
+	if set.SelectDenyExts != nil {
+		fl.DenyExts = normaliseExts(set.SelectDenyExts)
+	}
+	fl.PreferExts = normaliseExts(set.SelectPreferExts)
+	for _, pat := range set.SelectExcludePaths {
+		if re, err := regexp.Compile("(?i)" + pat); err == nil {
+			fl.ExcludePaths = append(fl.ExcludePaths, re)
+		}
+	}
+	fl.AvoidWords = engine.DefaultAvoidWords
+	if set.SelectAvoidWords != nil {
+		fl.AvoidWords = nil
+		for _, w := range set.SelectAvoidWords {
+			if w = strings.ToLower(strings.TrimSpace(w)); w != "" {
+				fl.AvoidWords = append(fl.AvoidWords, w)
+			}
+		}
+	}
+	return fl
+}
+
+// normaliseExts lower-cases extensions and gives them their dot.
+func normaliseExts(exts []string) []string {
+	var out []string
+	for _, ext := range exts {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if ext != "" && !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		out = append(out, ext)
+	}
+	return out
+}
+
+func (p *profile) recordHistory(t *torrent.Torrent, f *torrent.File) {
+	p.appendHistory(historyEntry{
+		Time:     time.Now(),
+		InfoHash: t.InfoHash().HexString(),
+		Name:     t.Name(),
+		File:     f.DisplayPath(),
+	})
+}
+
+func (p *profile) appendHistory(e historyEntry) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.history = append(p.history, e)
+	if len(p.history) > historyMaxItems {
+		p.history = p.history[len(p.history)-historyMaxItems:]
+	}
+	if err := saveJSONAt(filepath.Join(p.dir, historyFile), p.history); err != nil {
+		log.Printf("profile %s: save history: %v", p.ID, err)
+	}
+}
+
+// ── GET|PUT /profile/settings ─────────────────────────────────────────────────
+func handleProfileSettings(w http.ResponseWriter, r *http.Request) {
+	p, err := profileFor(r)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var in profileSettings
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			http.Error(w, "invalid JSON body: "+err.Error(), 400)
+			return
+		}
+		if err := in.validate(); err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		p.mu.Lock()
+		p.settings = in
+		err := saveJSONAt(filepath.Join(p.dir, settingsFile), p.settings)
+		p.mu.Unlock()
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+	default:
+		http.Error(w, "GET or PUT only", 405)
+		return
+	}
+	p.mu.Lock()
+	out := p.settings
+	p.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+// ── GET|DELETE /profile/history ───────────────────────────────────────────────
+func handleProfileHistory(w http.ResponseWriter, r *http.Request) {
+	p, err := profileFor(r)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		p.mu.Lock()
+		out := append([]historyEntry{}, p.history...)
+		p.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(out)
+	case http.MethodDelete:
+		p.mu.Lock()
+		p.history = nil
+		err := saveJSONAt(filepath.Join(p.dir, historyFile), p.history)
+		p.mu.Unlock()
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		w.WriteHeader(200)
+		fmt.Fprint(w, "cleared")
+	default:
+		http.Error(w, "GET or DELETE only", 405)
+	}
+}
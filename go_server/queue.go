@@ -0,0 +1,444 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ── Download queue ────────────────────────────────────────────────────────────
+// A profile's queue holds torrents to download one after another (or a few
+// at a time), for "get the whole season overnight": each item becomes a
+// background session (torrents.go) when its turn comes and counts as running
+// until its file is complete. The policy orders what starts next: "fifo" by
+// when items were queued, "priority" by their priority (higher first) and
+// then by when. The queue survives restarts; items that were running start
+// again and pick up the pieces already on disk. The global limits
+// (limits.go) apply on top: a running item may still wait for a slot.
+// An item's credentials for fetching its url (sitecreds.go) stay out of
+// queue.json: the secret store keeps them as "queue:<profile>/<id>:cookie"
+// and "…:authorization" until the item is removed, so queuing them needs
+// ROXBOX_SECRET_KEY.
+//
+//	GET    /queue          items with their position and session status
+//	POST   /queue          queue items, optionally setting policy/concurrency
+//	DELETE /queue?id=N     remove an item, stopping its download
+//	DELETE /queue          remove every item that isn't downloading
+
+const (
+	queueFile       = "queue.json"
+	maxQueueItems   = 500
+	queuePoll       = 2 * time.Second
+	queuePolicyFIFO = "fifo"
+	queuePolicyPrio = "priority"
+)
+
+type queueItem struct {
+	ID       int        `json:"id"`
+	Priority int        `json:"priority,omitempty"` // higher starts first under "priority"
+	Request  addRequest `json:"request"`
+	State    string     `json:"state"` // queued | downloading | done | stopped | error
+	InfoHash string     `json:"info_hash,omitempty"`
+	Error    string     `json:"error,omitempty"`
+	Added    time.Time  `json:"added"`
+}
+
+type downloadQueue struct {
+	mu          sync.Mutex
+	Policy      string       `json:"policy"`
+	Concurrency int          `json:"concurrency"`
+	NextID      int          `json:"next_id"`
+	Items       []*queueItem `json:"items"`
+
+	running bool          // worker started
+	kick    chan struct{} // wakes the worker early
+}
+
+// queueEntry is an item as /queue lists it.
+type queueEntry struct {
+	*queueItem
+	Position int             `json:"position,omitempty"` // 1-based turn among queued items
+	Status   *StatusResponse `json:"status,omitempty"`   // its session, while it has one
+}
+
+type queueResponse struct {
+	Policy      string       `json:"policy"`
+	Concurrency int          `json:"concurrency"`
+	Items       []queueEntry `json:"items"`
+	Added       []int        `json:"added,omitempty"` // ids queued by this POST
+}
+
+// queueAddItem is one torrent to queue: an /add request plus its priority.
+type queueAddItem struct {
+	addRequest
+	fetchAuthJSON
+	Priority int `json:"priority,omitempty"`
+}
+
+type queueAddRequest struct {
+	Items       []queueAddItem `json:"items,omitempty"`
+	Magnets     []string       `json:"magnets,omitempty"`     // shorthand for items with only a magnet
+	Policy      string         `json:"policy,omitempty"`      // fifo | priority
+	Concurrency int            `json:"concurrency,omitempty"` // downloads at once (default 1)
+}
+
+// loadQueue restores the profile's queue and resumes it. Caller is getProfile.
+func (p *profile) loadQueue() {
+	q := &downloadQueue{Policy: queuePolicyFIFO, Concurrency: 1}
+	if err := loadJSONAt(filepath.Join(p.dir, queueFile), q); err != nil {
+		log.Printf("profile %s: load queue: %v", p.ID, err)
+	}
+	pending := false
+	for _, it := range q.Items {
+		if it.State == "downloading" {
+			it.State = "queued"
+		}
+		pending = pending || it.State == "queued"
+	}
+	p.queue = q
+	if pending {
+		q.mu.Lock()
+		q.startWorker(p)
+		q.mu.Unlock()
+	}
+}
+
+// save writes the queue to the profile dir. Caller holds q.mu.
+func (q *downloadQueue) save(p *profile) {
+	if err := saveJSONAt(filepath.Join(p.dir, queueFile), q); err != nil {
+		log.Printf("profile %s: save queue: %v", p.ID, err)
+	}
+}
+
+// startWorker starts the worker if it isn't running. Caller holds q.mu.
+func (q *downloadQueue) startWorker(p *profile) {
+	if q.running {
+		return
+	}
+	q.running = true
+	q.kick = make(chan struct{}, 1)
+	go q.worker(p)
+}
+
+func (q *downloadQueue) wake() {
+	select {
+	case q.kick <- struct{}{}:
+	default:
+	}
+}
+
+func (q *downloadQueue) worker(p *profile) {
+	defer guard()
+	for {
+		q.step(p)
+		select {
+		case <-q.kick:
+		case <-time.After(queuePoll):
+		}
+	}
+}
+
+// ordered returns the queued items in the order they'll start. Caller
+// holds q.mu.
+func (q *downloadQueue) ordered() []*queueItem {
+	var out []*queueItem
+	for _, it := range q.Items {
+		if it.State == "queued" {
+			out = append(out, it)
+		}
+	}
+	if q.Policy == queuePolicyPrio {
+		sort.SliceStable(out, func(i, j int) bool { return out[i].Priority > out[j].Priority })
+	}
+	return out
+}
+
+// step settles running items and starts queued ones up to the concurrency.
+func (q *downloadQueue) step(p *profile) {
+	q.mu.Lock()
+	changed := false
+	running := 0
+	for _, it := range q.Items {
+		if it.State != "downloading" {
+			continue
+		}
+		sess := p.sessionByHash(it.InfoHash)
+		if sess == nil {
+			it.State, changed = "stopped", true // stopped through /torrents, or dropped
+			continue
+		}
+		st := sess.snapshotStatus()
+		if st.State == "error" {
+			it.State, it.Error, changed = "error", st.Error, true
+			continue
+		}
+		if _, f := sess.current(); f != nil && f.BytesCompleted() >= f.Length() {
+			it.State, changed = "done", true
+			sess.event("", "queue: download complete")
+			continue
+		}
+		running++
+	}
+	var next []*queueItem
+	for _, it := range q.ordered() {
+		if running+len(next) >= q.Concurrency {
+			break
+		}
+		it.State, changed = "downloading", true
+		next = append(next, it)
+	}
+	if changed {
+		q.save(p)
+	}
+	q.mu.Unlock()
+
+	for _, it := range next {
+		hash, err := q.startItem(p, it)
+		q.mu.Lock()
+		switch {
+		case it.State == "removed": // while starting
+			if err == nil {
+				p.stopBackground(hash, "removed from the queue")
+			}
+		case err == errBackgroundFull:
+			it.State = "queued" // try again when a slot frees up
+		case err != nil:
+			it.State, it.Error = "error", err.Error()
+		default:
+			it.InfoHash = hash
+		}
+		q.save(p)
+		q.mu.Unlock()
+	}
+}
+
+// startItem brings an item up as a background session.
+func (q *downloadQueue) startItem(p *profile, it *queueItem) (hash string, err error) {
+	req := it.Request
+	req.fetchAuth = fetchAuth{Cookie: getSecret(queueSecret(p, it, "cookie")), Authorization: getSecret(queueSecret(p, it, "authorization"))}
+	if req.URL != "" && req.Magnet == "" {
+		if req.Metainfo, err = fetchMetainfo(req.URL, req.fetchAuth.forURL(req.URL)); err != nil {
+			return "", err
+		}
+	}
+	snap, _ := req.swarm() // checked when queued
+	sess, _, _, err := p.startBackground(req, snap, fmt.Sprintf("queue-%d", it.ID))
+	if err != nil {
+		return "", err
+	}
+	return sess.hash, nil
+}
+
+// queueSecret names an item's stored credential of the given kind.
+func queueSecret(p *profile, it *queueItem, kind string) string {
+	return fmt.Sprintf("queue:%s/%d:%s", p.ID, it.ID, kind)
+}
+
+// saveQueueAuth moves the item's credentials into the secret store.
+func saveQueueAuth(p *profile, it *queueItem) error {
+	a := it.Request.fetchAuth
+	it.Request.fetchAuth = fetchAuth{}
+	if a == (fetchAuth{}) {
+		return nil
+	}
+	if err := setSecret(queueSecret(p, it, "cookie"), a.Cookie); err != nil {
+		return err
+	}
+	return setSecret(queueSecret(p, it, "authorization"), a.Authorization)
+}
+
+// forgetQueueAuth removes the item's stored credentials.
+func forgetQueueAuth(p *profile, it *queueItem) {
+	for _, kind := range []string{"cookie", "authorization"} {
+		if name := queueSecret(p, it, kind); getSecret(name) != "" {
+			if err := setSecret(name, ""); err != nil {
+				log.Printf("profile %s: queue item %d: %v", p.ID, it.ID, err)
+			}
+		}
+	}
+}
+
+// ── GET | POST | DELETE /queue ────────────────────────────────────────────────
+func handleQueue(w http.ResponseWriter, r *http.Request) {
+	p, err := profileFor(r)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	q := p.queue
+	var added []int
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		qr, err := parseQueueAdd(r)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		for i, item := range qr.Items {
+			if item.addRequest.fetchAuth != (fetchAuth{}) && sealKey == nil {
+				http.Error(w, fmt.Sprintf("item %d: queuing a cookie or authorization needs ROXBOX_SECRET_KEY", i), 409)
+				return
+			}
+		}
+		q.mu.Lock()
+		if len(q.Items)+len(qr.Items) > maxQueueItems {
+			q.mu.Unlock()
+			http.Error(w, fmt.Sprintf("at most %d queue items; remove finished ones first", maxQueueItems), 409)
+			return
+		}
+		if qr.Policy != "" {
+			q.Policy = qr.Policy
+		}
+		if qr.Concurrency > 0 {
+			q.Concurrency = qr.Concurrency
+		}
+		for _, item := range qr.Items {
+			q.NextID++
+			it := &queueItem{ID: q.NextID, Priority: item.Priority, Request: item.addRequest, State: "queued", Added: time.Now()}
+			if err := saveQueueAuth(p, it); err != nil {
+				log.Printf("profile %s: queue item %d: %v", p.ID, it.ID, err)
+			}
+			q.Items = append(q.Items, it)
+			added = append(added, q.NextID)
+		}
+		q.save(p)
+		q.startWorker(p)
+		q.mu.Unlock()
+		q.wake()
+		p.sess.event(requestID(r), fmt.Sprintf("queued %d torrent(s)", len(added)))
+	case http.MethodDelete:
+		if s := r.URL.Query().Get("id"); s != "" {
+			id, _ := strconv.Atoi(s)
+			if !q.remove(p, id) {
+				http.Error(w, "unknown queue item", 404)
+				return
+			}
+		} else {
+			q.mu.Lock()
+			kept := q.Items[:0]
+			for _, it := range q.Items {
+				if it.State == "downloading" {
+					kept = append(kept, it)
+				} else {
+					forgetQueueAuth(p, it)
+				}
+			}
+			q.Items = kept
+			q.save(p)
+			q.mu.Unlock()
+		}
+	default:
+		http.Error(w, "GET, POST or DELETE only", 405)
+		return
+	}
+	resp := q.list(p)
+	resp.Added = added
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// remove drops an item, stopping its background session if it's running.
+func (q *downloadQueue) remove(p *profile, id int) bool {
+	q.mu.Lock()
+	var it *queueItem
+	for i, c := range q.Items {
+		if c.ID == id {
+			it = c
+			q.Items = append(q.Items[:i], q.Items[i+1:]...)
+			break
+		}
+	}
+	state := ""
+	if it != nil {
+		state, it.State = it.State, "removed"
+		forgetQueueAuth(p, it)
+		q.save(p)
+	}
+	q.mu.Unlock()
+	if it == nil {
+		return false
+	}
+	if state == "downloading" && it.InfoHash != "" {
+		p.stopBackground(it.InfoHash, "removed from the queue")
+		q.wake()
+	}
+	return true
+}
+
+func (q *downloadQueue) list(p *profile) queueResponse {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	pos := map[*queueItem]int{}
+	for i, it := range q.ordered() {
+		pos[it] = i + 1
+	}
+	out := queueResponse{Policy: q.Policy, Concurrency: q.Concurrency, Items: []queueEntry{}}
+	for _, it := range q.Items {
+		e := queueEntry{queueItem: it, Position: pos[it]}
+		if it.InfoHash != "" && (it.State == "downloading" || it.State == "done") {
+			if sess := p.sessionByHash(it.InfoHash); sess != nil {
+				st := sess.snapshotStatus()
+				e.Status = &st
+			}
+		}
+		out.Items = append(out.Items, e)
+	}
+	return out
+}
+
+// parseQueueAdd reads a POST /queue: a JSON queueAddRequest, or form params
+// (magnet repeated, policy, concurrency).
+func parseQueueAdd(r *http.Request) (queueAddRequest, error) {
+	var qr queueAddRequest
+	mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mt {
+	case "application/json":
+		dec := json.NewDecoder(io.LimitReader(r.Body, maxAddBody))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&qr); err != nil {
+			return qr, fmt.Errorf("invalid JSON body: %v", err)
+		}
+	case "", "application/x-www-form-urlencoded":
+		_ = r.ParseForm()
+		qr.Magnets = r.Form["magnet"]
+		qr.Policy = r.FormValue("policy")
+		qr.Concurrency, _ = strconv.Atoi(r.FormValue("concurrency"))
+	default:
+		return qr, errors.New("Content-Type must be application/json or form-encoded")
+	}
+	for _, m := range qr.Magnets {
+		qr.Items = append(qr.Items, queueAddItem{addRequest: addRequest{Magnet: m}})
+	}
+	switch qr.Policy {
+	case "", queuePolicyFIFO, queuePolicyPrio:
+	default:
+		return qr, errors.New("policy must be fifo or priority")
+	}
+	if qr.Concurrency < 0 || qr.Concurrency > maxBackground {
+		return qr, fmt.Errorf("concurrency must be 1..%d", maxBackground)
+	}
+	for i, item := range qr.Items {
+		qr.Items[i].addRequest.fetchAuth = item.fetchAuthJSON.auth()
+		err := item.validate()
+		if err == nil {
+			_, err = item.swarm()
+		}
+		if err == nil && item.Magnet != "" {
+			_, err = item.infoHash()
+		}
+		if err != nil {
+			return qr, fmt.Errorf("item %d: %v", i, err)
+		}
+	}
+	return qr, nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import "sort"
+
+// byteRange is the half-open interval [Start, End).
+type byteRange struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+}
+
+// byteRanges is a sorted list of non-overlapping, non-adjacent ranges.
+type byteRanges []byteRange
+
+// Add merges [start, end) into the set.
+func (rs *byteRanges) Add(start, end int64) {
+	if end <= start {
+		return
+	}
+	s := *rs
+	// First range that ends at or after start can merge with us.
+	i := sort.Search(len(s), func(i int) bool { return s[i].End >= start })
+	j := i
+	for j < len(s) && s[j].Start <= end {
+		if s[j].Start < start {
+			start = s[j].Start
+		}
+		if s[j].End > end {
+			end = s[j].End
+		}
+		j++
+	}
+	merged := append(append(append(byteRanges{}, s[:i]...), byteRange{start, end}), s[j:]...)
+	*rs = merged
+}
+
+// Covered returns the total number of bytes in the set.
+func (rs byteRanges) Covered() (n int64) {
+	for _, r := range rs {
+		n += r.End - r.Start
+	}
+	return n
+}
+
+// Gaps returns the ranges in [0, size) not yet in the set.
+func (rs byteRanges) Gaps(size int64) (gaps []byteRange) {
+	var pos int64
+	for _, r := range rs {
+		if r.Start > pos {
+			gaps = append(gaps, byteRange{pos, min(r.Start, size)})
+		}
+		pos = max(pos, r.End)
+		if pos >= size {
+			return gaps
+		}
+	}
+	if pos < size {
+		gaps = append(gaps, byteRange{pos, size})
+	}
+	return gaps
+}
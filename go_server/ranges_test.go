@@ -0,0 +1,48 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestByteRanges(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		add     [][2]int64
+		want    byteRanges
+		covered int64
+		gaps    []byteRange // in [0, 100)
+	}{
+		{"empty", nil, nil, 0, []byteRange{{0, 100}}},
+		{"empty range ignored", [][2]int64{{10, 10}, {20, 5}}, nil, 0, []byteRange{{0, 100}}},
+		{"one", [][2]int64{{10, 20}}, byteRanges{{10, 20}}, 10, []byteRange{{0, 10}, {20, 100}}},
+		{"disjoint", [][2]int64{{10, 20}, {30, 40}}, byteRanges{{10, 20}, {30, 40}}, 20, []byteRange{{0, 10}, {20, 30}, {40, 100}}},
+		{"out of order", [][2]int64{{30, 40}, {10, 20}, {50, 60}}, byteRanges{{10, 20}, {30, 40}, {50, 60}}, 30, []byteRange{{0, 10}, {20, 30}, {40, 50}, {60, 100}}},
+		{"adjacent merge", [][2]int64{{10, 20}, {20, 30}}, byteRanges{{10, 30}}, 20, []byteRange{{0, 10}, {30, 100}}},
+		{"adjacent before", [][2]int64{{20, 30}, {10, 20}}, byteRanges{{10, 30}}, 20, []byteRange{{0, 10}, {30, 100}}},
+		{"overlap", [][2]int64{{10, 25}, {20, 30}}, byteRanges{{10, 30}}, 20, []byteRange{{0, 10}, {30, 100}}},
+		{"inside", [][2]int64{{10, 40}, {20, 30}}, byteRanges{{10, 40}}, 30, []byteRange{{0, 10}, {40, 100}}},
+		{"spans several", [][2]int64{{10, 20}, {30, 40}, {50, 60}, {15, 55}}, byteRanges{{10, 60}}, 50, []byteRange{{0, 10}, {60, 100}}},
+		{"from zero", [][2]int64{{0, 50}}, byteRanges{{0, 50}}, 50, []byteRange{{50, 100}}},
+		{"to EOF", [][2]int64{{50, 100}}, byteRanges{{50, 100}}, 50, []byteRange{{0, 50}}},
+		{"past EOF", [][2]int64{{50, 120}}, byteRanges{{50, 120}}, 70, []byteRange{{0, 50}}},
+		{"starts past EOF", [][2]int64{{10, 20}, {110, 120}}, byteRanges{{10, 20}, {110, 120}}, 20, []byteRange{{0, 10}, {20, 100}}},
+		{"complete", [][2]int64{{0, 60}, {40, 100}}, byteRanges{{0, 100}}, 100, nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var rs byteRanges
+			for _, a := range tc.add {
+				rs.Add(a[0], a[1])
+			}
+			if !slices.Equal(rs, tc.want) {
+				t.Errorf("ranges %v, want %v", rs, tc.want)
+			}
+			if n := rs.Covered(); n != tc.covered {
+				t.Errorf("covered %d, want %d", n, tc.covered)
+			}
+			if gaps := rs.Gaps(100); !slices.Equal(gaps, tc.gaps) {
+				t.Errorf("gaps %v, want %v", gaps, tc.gaps)
+			}
+		})
+	}
+}
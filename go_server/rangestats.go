@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ── GET | DELETE /debug/rangestats ────────────────────────────────────────────
+// How each player (by User-Agent) reads a stream: whole-file GETs, open
+// probes at byte 0, tail reads (MP4 moov, Matroska cues) and where its seeks
+// land. Players differ a lot here; these numbers are the input for choosing
+// a prioritisation preset per player. In memory only, since start or the
+// last DELETE.
+
+const (
+	rangeStatsMaxAgents = 32
+	rangeStatsRecent    = 20
+	rangeTailWindow     = 16 << 20 // reads starting this close to the end are tail reads
+	rangeSeekBuckets    = 10
+)
+
+type rangeSample struct {
+	Time   time.Time `json:"time"`
+	Kind   string    `json:"kind"` // "full" | "open" | "tail" | "seek"
+	Offset int64     `json:"offset"`
+	Length int64     `json:"length,omitempty"` // requested length; 0 when open-ended
+}
+
+type rangeAgentStats struct {
+	UserAgent string    `json:"user_agent"`
+	Requests  int       `json:"requests"`
+	Full      int       `json:"full"`    // no Range header
+	Open      int       `json:"open"`    // from byte 0
+	Tail      int       `json:"tail"`    // near or relative to the end
+	Seek      int       `json:"seek"`    // anywhere else
+	Bounded   int       `json:"bounded"` // with an explicit end (probing reads)
+	Seeks     []int     `json:"seeks"`   // seek positions per tenth of the file
+	Pattern   string    `json:"pattern"` // rough summary, see rangePattern
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+
+	Recent []rangeSample `json:"recent"` // newest last
+}
+
+var rangeStats struct {
+	sync.Mutex
+	agents map[string]*rangeAgentStats
+}
+
+// recordRange notes a stream request for a file of the given length.
+func recordRange(r *http.Request, length int64) {
+	ua := r.UserAgent()
+	if ua == "" {
+		ua = "(none)"
+	}
+	if len(ua) > 120 {
+		ua = ua[:120]
+	}
+	s := classifyRange(r.Header.Get("Range"), length)
+	s.Time = time.Now()
+	debugf("reader", "range %s off=%d len=%d ua=%q", s.Kind, s.Offset, s.Length, ua)
+
+	rangeStats.Lock()
+	defer rangeStats.Unlock()
+	if rangeStats.agents == nil {
+		rangeStats.agents = map[string]*rangeAgentStats{}
+	}
+	a := rangeStats.agents[ua]
+	if a == nil {
+		if len(rangeStats.agents) >= rangeStatsMaxAgents {
+			evictOldestAgent()
+		}
+		a = &rangeAgentStats{UserAgent: ua, Seeks: make([]int, rangeSeekBuckets), FirstSeen: s.Time}
+		rangeStats.agents[ua] = a
+	}
+	a.Requests++
+	a.LastSeen = s.Time
+	switch s.Kind {
+	case "full":
+		a.Full++
+	case "open":
+		a.Open++
+	case "tail":
+		a.Tail++
+	case "seek":
+		a.Seek++
+		if length > 0 {
+			a.Seeks[min(int(s.Offset*rangeSeekBuckets/length), rangeSeekBuckets-1)]++
+		}
+	}
+	if s.Length > 0 {
+		a.Bounded++
+	}
+	a.Recent = append(a.Recent, s)
+	if len(a.Recent) > rangeStatsRecent {
+		a.Recent = a.Recent[len(a.Recent)-rangeStatsRecent:]
+	}
+}
+
+// evictOldestAgent makes room for a new agent. Caller holds rangeStats.
+func evictOldestAgent() {
+	var oldest *rangeAgentStats
+	for _, a := range rangeStats.agents {
+		if oldest == nil || a.LastSeen.Before(oldest.LastSeen) {
+			oldest = a
+		}
+	}
+	if oldest != nil {
+		delete(rangeStats.agents, oldest.UserAgent)
+	}
+}
+
+// classifyRange sorts a Range header (first range only) into a rangeSample.
+func classifyRange(h string, length int64) rangeSample {
+	spec, ok := strings.CutPrefix(strings.TrimSpace(h), "bytes=")
+	if !ok {
+		return rangeSample{Kind: "full"}
+	}
+	spec, _, _ = strings.Cut(spec, ",")
+	first, last, _ := strings.Cut(strings.TrimSpace(spec), "-")
+	if first == "" { // suffix range: the last n bytes
+		n, _ := strconv.ParseInt(last, 10, 64)
+		return rangeSample{Kind: "tail", Offset: max(length-n, 0), Length: n}
+	}
+	off, err := strconv.ParseInt(first, 10, 64)
+	if err != nil {
+		return rangeSample{Kind: "full"}
+	}
+	s := rangeSample{Offset: off}
+	if end, err := strconv.ParseInt(last, 10, 64); err == nil && end >= off {
+		s.Length = end - off + 1
+	}
+	switch {
+	case off == 0:
+		s.Kind = "open"
+	case length > 0 && off >= length-min(rangeTailWindow, length/10):
+		s.Kind = "tail"
+	default:
+		s.Kind = "seek"
+	}
+	return s
+}
+
+// rangePattern labels an agent's habits. "tail-first" players read the end
+// before playing (the tail pieces should be fetched early), "seek-heavy"
+// ones jump around, "sequential" ones mostly read straight through.
+func rangePattern(a *rangeAgentStats) string {
+	opens := a.Open + a.Full
+	switch {
+	case a.Requests < 3:
+		return "unknown"
+	case a.Tail > 0 && a.Tail*2 >= opens:
+		return "tail-first"
+	case a.Seek > opens*2:
+		return "seek-heavy"
+	}
+	return "sequential"
+}
+
+func handleRangeStats(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		rangeStats.Lock()
+		out := []rangeAgentStats{}
+		for _, a := range rangeStats.agents {
+			c := *a
+			c.Seeks = append([]int(nil), a.Seeks...)
+			c.Recent = append([]rangeSample(nil), a.Recent...)
+			c.Pattern = rangePattern(a)
+			out = append(out, c)
+		}
+		rangeStats.Unlock()
+		sort.Slice(out, func(i, j int) bool { return out[i].Requests > out[j].Requests })
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(out)
+	case http.MethodDelete:
+		rangeStats.Lock()
+		rangeStats.agents = nil
+		rangeStats.Unlock()
+		w.WriteHeader(204)
+	default:
+		http.Error(w, "GET or DELETE only", 405)
+	}
+}
@@ -0,0 +1,580 @@
+//go:build !minimal && !no_relay
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ── Remote control relay ──────────────────────────────────────────────────────
+// With -relay pointing at a WebSocket relay, the server keeps a connection
+// out to it, so the user's phone can control the box at home from anywhere
+// (add torrents, watch /status) without a port opened or forwarded. The
+// relay only passes messages along: everything between phone and server is
+// sealed with AES-256-GCM under a key the two share (relay_key in the
+// secret store, or ROXBOX_RELAY_KEY), so the relay reads neither requests
+// nor answers. It pairs them by a channel ID derived from the key. The
+// key has to be generated (16 random bytes or more, in hex, as from
+// `openssl rand -hex 16`): the relay sees the channel ID, and one
+// derived from a passphrase could be guessed offline.
+//
+// The relay's side is small: the server connects to
+// <relay>?channel=<id>&role=server and phones to …&role=client; every
+// message from a client goes to its channel's server and every message from
+// the server to its clients. A channel has one server; another one is
+// refused until it drops. Any roxbox with a public address can be that
+// relay with -relay-hub, at /relay/hub.
+//
+// A message is base64 of nonce ‖ ciphertext, the plaintext a relayRequest
+// (phone → server) or a relayResponse (server → phone); the direction is
+// in the additional data, so an answer can't be sent back as a request.
+// Each request runs through the API with full rights, as with a full token;
+// its ID and time keep a replayed message from running twice. Answers are
+// capped (relayMaxBody): video stays on the LAN.
+
+var (
+	relayFlag    = flag.String("relay", "", "ws:// or wss:// URL of a relay for remote control without open ports (env ROXBOX_RELAY)")
+	relayHubFlag = flag.Bool("relay-hub", false, "serve /relay/hub, a relay for other roxbox instances and their phones")
+)
+
+const (
+	relayMaxBody    = 1 << 20
+	relayMaxMessage = 2 << 20 // a sealed, base64-encoded answer
+	relayTimeout    = time.Minute
+	relayWindow     = 5 * time.Minute // how far a request's time may be off
+	relayPing       = 30 * time.Second
+	relayIdle       = 3 * relayPing
+	relayParallel   = 8
+	relayBackoffMax = time.Minute
+
+	relayKeySecret = "relay_key"
+	relayKeyMin    = 16 // bytes; 128 bits
+	relayAADReq    = "roxbox-relay/request"
+	relayAADResp   = "roxbox-relay/response"
+)
+
+var relayChannelRe = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// relayRequest is an API call from the phone.
+type relayRequest struct {
+	ID     string            `json:"id"`
+	Sent   int64             `json:"sent"` // Unix ms
+	Method string            `json:"method"`
+	Path   string            `json:"path"` // with the query
+	Header map[string]string `json:"header,omitempty"`
+	Body   []byte            `json:"body,omitempty"`
+}
+
+// relayResponse is its answer.
+type relayResponse struct {
+	ID     string            `json:"id"`
+	Status int               `json:"status"`
+	Header map[string]string `json:"header,omitempty"` // Content-Type and the request ID
+	Body   []byte            `json:"body,omitempty"`
+}
+
+// relayStatus is GET /relay.
+type relayStatus struct {
+	Enabled   bool      `json:"enabled"`
+	URL       string    `json:"url,omitempty"`
+	Channel   string    `json:"channel,omitempty"`
+	Connected bool      `json:"connected"`
+	Since     time.Time `json:"since,omitempty"` // connected since
+	Requests  int64     `json:"requests"`
+	Rejected  int64     `json:"rejected"` // not sealed with our key, or replayed
+	Error     string    `json:"error,omitempty"`
+	Hub       bool      `json:"hub"`
+	HubConns  int       `json:"hub_conns,omitempty"`
+}
+
+var relay struct {
+	sync.Mutex
+	aead     cipher.AEAD
+	channel  string
+	conn     *wsConn
+	since    time.Time
+	requests int64
+	rejected int64
+	err      string
+	seen     map[string]time.Time // request IDs within relayWindow
+}
+
+var relayHandler = sync.OnceValue(func() http.Handler {
+	return withTracing(withRequestLog(withRecover(newMux())))
+})
+
+func init() {
+	openRoutes["/relay/hub"] = true // its messages are sealed end to end
+	registerModule(module{
+		Name: "relay",
+		Routes: func(mux *http.ServeMux) {
+			mux.HandleFunc("/relay", handleRelay)        // GET  (remote control relay connection)
+			mux.HandleFunc("/relay/hub", handleRelayHub) // GET  (WebSocket; with -relay-hub)
+		},
+		Docs: []apiRoute{
+			{"/relay", []apiOp{{Method: "GET", Summary: "The connection to the remote control relay (-relay) and, with -relay-hub, the relay served here",
+				Resp: relayStatus{}}}},
+			{"/relay/hub", []apiOp{{Method: "GET", Summary: "WebSocket relay between a roxbox server and its phones, paired by channel; forwards their sealed messages as they are; a second server for a channel gets 409 while the first is connected; needs -relay-hub",
+				Params: []apiParam{
+					{Name: "channel", Required: true, Desc: "32 hex digits derived from the shared key"},
+					{Name: "role", Required: true, Enum: []string{"server", "client"}},
+				},
+				Resp: "", WebSocket: true}}},
+		},
+		Start: startRelay,
+		Probe: func() capability {
+			st := relayState()
+			switch {
+			case st.Enabled && st.Connected:
+				return capability{Compiled: true, Enabled: true, Detail: "connected to " + st.URL}
+			case st.Enabled:
+				return capability{Compiled: true, Enabled: true, Detail: "connecting to " + st.URL + ": " + st.Error}
+			case st.Hub:
+				return capability{Compiled: true, Enabled: true, Detail: "serving /relay/hub"}
+			}
+			return capability{Compiled: true, Detail: "start with -relay and a relay_key secret (or ROXBOX_RELAY_KEY)"}
+		},
+	})
+}
+
+func relayURL() string {
+	if *relayFlag != "" {
+		return *relayFlag
+	}
+	return os.Getenv("ROXBOX_RELAY")
+}
+
+// startRelay takes the shared key and keeps the relay connection up.
+func startRelay() {
+	key := os.Getenv("ROXBOX_RELAY_KEY")
+	_ = os.Unsetenv("ROXBOX_RELAY_KEY")
+	if key == "" {
+		key = getSecret(relayKeySecret)
+	}
+	u := relayURL()
+	if u == "" {
+		return
+	}
+	if key == "" {
+		log.Printf("relay: no key (relay_key secret or ROXBOX_RELAY_KEY); not connecting")
+		return
+	}
+	raw, err := relayKeyBytes(key)
+	if err != nil {
+		log.Printf("relay: %v; not connecting", err)
+		return
+	}
+	aead, channel := relayKeys(raw)
+	relay.Lock()
+	relay.aead, relay.channel = aead, channel
+	relay.Unlock()
+	go relayLoop(u)
+}
+
+// relayKeyBytes decodes the shared key, which must be random bytes in hex
+// (base64 would pass plain words): a fast hash of anything a person would
+// pick falls to a dictionary.
+func relayKeyBytes(key string) ([]byte, error) {
+	key = strings.TrimSpace(key)
+	b, err := hex.DecodeString(key)
+	if err != nil || len(b) < relayKeyMin {
+		return nil, fmt.Errorf("relay key must be %d or more random bytes in hex (openssl rand -hex %d)", relayKeyMin, relayKeyMin)
+	}
+	seen := map[byte]bool{}
+	for _, c := range b {
+		seen[c] = true
+	}
+	if len(seen) < len(b)/2 {
+		return nil, errors.New("relay key is too repetitive to be random; generate one (openssl rand -hex 16)")
+	}
+	return b, nil
+}
+
+// relayKeys derives the cipher and the channel ID from the decoded key; the
+// phone derives the same.
+func relayKeys(key []byte) (cipher.AEAD, string) {
+	k := sha256.Sum256(append([]byte("roxbox relay key\x00"), key...))
+	block, _ := aes.NewCipher(k[:])
+	aead, _ := cipher.NewGCM(block)
+	ch := sha256.Sum256(append([]byte("roxbox relay channel\x00"), key...))
+	return aead, hex.EncodeToString(ch[:16])
+}
+
+func relayLoop(base string) {
+	backoff := time.Second
+	for {
+		start := time.Now()
+		err := relayConnect(base)
+		relay.Lock()
+		relay.conn, relay.err = nil, err.Error()
+		relay.Unlock()
+		if time.Since(start) > relayBackoffMax {
+			backoff = time.Second
+		}
+		log.Printf("relay: %v; reconnecting in %s", err, backoff)
+		time.Sleep(backoff)
+		backoff = min(2*backoff, relayBackoffMax)
+	}
+}
+
+// relayConnect serves the phone's requests over one connection to the
+// relay, until it drops.
+func relayConnect(base string) error {
+	u, err := url.Parse(base)
+	if err != nil {
+		return err
+	}
+	relay.Lock()
+	q := u.Query()
+	q.Set("channel", relay.channel)
+	q.Set("role", "server")
+	relay.Unlock()
+	u.RawQuery = q.Encode()
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	c, err := wsDial(ctx, u.String(), relayMaxMessage, relayIdle)
+	cancel()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	relay.Lock()
+	relay.conn, relay.since, relay.err = c, time.Now(), ""
+	relay.Unlock()
+	log.Printf("relay: connected to %s", u.Host)
+	ping := time.NewTicker(relayPing)
+	defer ping.Stop()
+	sem := make(chan struct{}, relayParallel)
+	for {
+		select {
+		case msg := <-c.Messages():
+			sem <- struct{}{}
+			go func() {
+				defer func() { <-sem }()
+				if out := relayServe(msg); out != nil {
+					_ = c.WriteText(out)
+				}
+			}()
+		case <-ping.C:
+			if err := c.Ping(); err != nil {
+				return err
+			}
+		case <-c.Closed():
+			return errors.New("connection closed")
+		}
+	}
+}
+
+// relayServe opens msg, runs the request and answers it sealed; nil for a
+// message that isn't ours to answer.
+func relayServe(msg []byte) []byte {
+	relay.Lock()
+	aead := relay.aead
+	relay.Unlock()
+	var req relayRequest
+	if err := relayOpen(aead, msg, relayAADReq, &req); err != nil || !relayFresh(req) {
+		relay.Lock()
+		relay.rejected++
+		relay.Unlock()
+		return nil
+	}
+	relay.Lock()
+	relay.requests++
+	relay.Unlock()
+	resp := relayRun(req)
+	out, err := relaySeal(aead, resp, relayAADResp)
+	if err != nil {
+		log.Printf("relay: %v", err)
+		return nil
+	}
+	return out
+}
+
+// relayFresh accepts a request sent within relayWindow whose ID hasn't
+// been seen.
+func relayFresh(req relayRequest) bool {
+	now := time.Now()
+	sent := time.UnixMilli(req.Sent)
+	if req.ID == "" || sent.Before(now.Add(-relayWindow)) || sent.After(now.Add(relayWindow)) {
+		return false
+	}
+	relay.Lock()
+	defer relay.Unlock()
+	if relay.seen == nil {
+		relay.seen = map[string]time.Time{}
+	}
+	for id, t := range relay.seen {
+		if now.Sub(t) > 2*relayWindow {
+			delete(relay.seen, id)
+		}
+	}
+	if _, dup := relay.seen[req.ID]; dup {
+		return false
+	}
+	relay.seen[req.ID] = now
+	return true
+}
+
+// relayRun runs req through the API.
+func relayRun(req relayRequest) relayResponse {
+	out := relayResponse{ID: req.ID}
+	if req.Method == "" {
+		req.Method = http.MethodGet
+	}
+	if !strings.HasPrefix(req.Path, "/") || strings.HasPrefix(req.Path, "/relay") {
+		out.Status, out.Body = 400, []byte("bad path\n")
+		return out
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), relayTimeout)
+	defer cancel()
+	r, err := http.NewRequestWithContext(ctx, req.Method, "http://relay"+req.Path, bytes.NewReader(req.Body))
+	if err != nil {
+		out.Status, out.Body = 400, []byte(err.Error()+"\n")
+		return out
+	}
+	for k, v := range req.Header {
+		r.Header.Set(k, v)
+	}
+	if len(req.Body) > 0 && r.Header.Get("Content-Type") == "" {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	r.RemoteAddr = "relay:0"
+	rec := &relayRecorder{header: http.Header{}}
+	relayHandler().ServeHTTP(rec, r)
+	if rec.tooLarge {
+		out.Status, out.Body = 502, []byte(fmt.Sprintf("answer larger than the relay's %d MB\n", relayMaxBody>>20))
+		return out
+	}
+	out.Status, out.Body = rec.status, rec.body.Bytes()
+	if out.Status == 0 {
+		out.Status = 200
+	}
+	out.Header = map[string]string{}
+	for _, k := range []string{"Content-Type", requestIDHeader} {
+		if v := rec.header.Get(k); v != "" {
+			out.Header[k] = v
+		}
+	}
+	return out
+}
+
+// relayRecorder keeps a handler's answer, up to relayMaxBody.
+type relayRecorder struct {
+	header   http.Header
+	status   int
+	body     bytes.Buffer
+	tooLarge bool
+}
+
+func (r *relayRecorder) Header() http.Header { return r.header }
+
+func (r *relayRecorder) WriteHeader(code int) {
+	if r.status == 0 {
+		r.status = code
+	}
+}
+
+func (r *relayRecorder) Write(p []byte) (int, error) {
+	if r.status == 0 {
+		r.status = 200
+	}
+	if r.body.Len()+len(p) > relayMaxBody {
+		r.tooLarge = true
+		return 0, errors.New("relay: answer too large")
+	}
+	return r.body.Write(p)
+}
+
+func relaySeal(aead cipher.AEAD, v any, aad string) ([]byte, error) {
+	plain, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	sealed := aead.Seal(nonce, nonce, plain, []byte(aad))
+	return []byte(base64.StdEncoding.EncodeToString(sealed)), nil
+}
+
+func relayOpen(aead cipher.AEAD, msg []byte, aad string, v any) error {
+	if aead == nil {
+		return errors.New("no key")
+	}
+	b, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(msg)))
+	if err != nil {
+		return err
+	}
+	if len(b) < aead.NonceSize() {
+		return errors.New("message too short")
+	}
+	plain, err := aead.Open(nil, b[:aead.NonceSize()], b[aead.NonceSize():], []byte(aad))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(plain, v)
+}
+
+func relayState() relayStatus {
+	relay.Lock()
+	st := relayStatus{Enabled: relay.aead != nil, Channel: relay.channel, Connected: relay.conn != nil,
+		Requests: relay.requests, Rejected: relay.rejected, Error: relay.err, Hub: *relayHubFlag}
+	if st.Connected {
+		st.Since = relay.since
+	}
+	relay.Unlock()
+	if st.Enabled {
+		st.URL = redactURL(relayURL())
+	}
+	hub.Lock()
+	for _, ch := range hub.channels {
+		st.HubConns += len(ch.clients)
+		if ch.server != nil {
+			st.HubConns++
+		}
+	}
+	hub.Unlock()
+	return st
+}
+
+// redactURL drops credentials from u.
+func redactURL(u string) string {
+	p, err := url.Parse(u)
+	if err != nil {
+		return ""
+	}
+	p.User = nil
+	return p.String()
+}
+
+// ── GET /relay ────────────────────────────────────────────────────────────────
+func handleRelay(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "GET only", 405)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(relayState())
+}
+
+// ── GET /relay/hub ────────────────────────────────────────────────────────────
+// The relay itself: it forwards sealed messages between a channel's server
+// and its clients and can read none of them. Anyone can claim a role, so a
+// second server for a channel is turned away rather than taking it over;
+// the first one's slot frees when it drops or stops answering pings.
+
+type hubChannel struct {
+	server  *wsConn
+	clients map[*wsConn]bool
+}
+
+var hub struct {
+	sync.Mutex
+	channels map[string]*hubChannel
+}
+
+func hubHasServer(channel string) bool {
+	hub.Lock()
+	defer hub.Unlock()
+	ch := hub.channels[channel]
+	return ch != nil && ch.server != nil
+}
+
+func handleRelayHub(w http.ResponseWriter, r *http.Request) {
+	if !*relayHubFlag {
+		http.Error(w, "relay hub disabled (start with -relay-hub)", 404)
+		return
+	}
+	q := r.URL.Query()
+	channel, role := q.Get("channel"), q.Get("role")
+	if !relayChannelRe.MatchString(channel) || role != "server" && role != "client" {
+		http.Error(w, "channel (32 hex digits) and role=server|client required", 400)
+		return
+	}
+	if role == "server" && hubHasServer(channel) {
+		http.Error(w, "channel already has a server", 409)
+		return
+	}
+	c := wsUpgradeMessages(w, r, relayMaxMessage, relayIdle)
+	if c == nil {
+		return
+	}
+	hub.Lock()
+	if hub.channels == nil {
+		hub.channels = map[string]*hubChannel{}
+	}
+	ch := hub.channels[channel]
+	if ch == nil {
+		ch = &hubChannel{clients: map[*wsConn]bool{}}
+		hub.channels[channel] = ch
+	}
+	if role == "server" {
+		if ch.server != nil { // lost a race with another server
+			hub.Unlock()
+			c.Close()
+			return
+		}
+		ch.server = c
+	} else {
+		ch.clients[c] = true
+	}
+	hub.Unlock()
+	defer func() {
+		hub.Lock()
+		if ch.server == c {
+			ch.server = nil
+		}
+		delete(ch.clients, c)
+		if ch.server == nil && len(ch.clients) == 0 && hub.channels[channel] == ch {
+			delete(hub.channels, channel)
+		}
+		hub.Unlock()
+		c.Close()
+	}()
+	ping := time.NewTicker(relayPing)
+	defer ping.Stop()
+	for {
+		select {
+		case msg := <-c.Messages():
+			hub.Lock()
+			var to []*wsConn
+			if role == "server" {
+				for cl := range ch.clients {
+					to = append(to, cl)
+				}
+			} else if ch.server != nil {
+				to = append(to, ch.server)
+			}
+			hub.Unlock()
+			for _, peer := range to {
+				_ = peer.WriteText(msg)
+			}
+		case <-ping.C:
+			if c.Ping() != nil {
+				return
+			}
+		case <-c.Closed():
+			return
+		}
+	}
+}
@@ -0,0 +1,371 @@
+//go:build !minimal && !no_rss
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent"
+)
+
+// ── RSS watcher ───────────────────────────────────────────────────────────────
+// Feeds are polled periodically; items whose title passes the include /
+// exclude regexes are queued as background downloads (never streamed).
+// Feeds and already-seen items survive restarts via rss.json.
+
+func init() {
+	registerModule(module{
+		Name:   "rss",
+		Routes: func(mux *http.ServeMux) { mux.HandleFunc("/rss", handleRSS) }, // GET | POST | PUT | DELETE
+		Docs: []apiRoute{{"/rss", []apiOp{
+			{Method: "GET", Summary: "Feeds and queued items", Resp: rssState{}},
+			{Method: "POST", Summary: "Add a feed", Body: rssFeed{}, Resp: rssFeed{}},
+			{Method: "PUT", Summary: "Replace a feed", Params: []apiParam{{Name: "id", Required: true}}, Body: rssFeed{}, Resp: rssFeed{}},
+			{Method: "DELETE", Summary: "Remove a feed", Params: []apiParam{{Name: "id", Required: true}}},
+		}}},
+		Start: startRSS,
+	})
+}
+
+const (
+	rssStateFile       = "rss.json"
+	rssDefaultInterval = 15 // minutes
+	rssMaxItems        = 500
+)
+
+type rssFeed struct {
+	ID          string    `json:"id"`
+	URL         string    `json:"url"`
+	Include     string    `json:"include,omitempty"` // regexp matched against item title
+	Exclude     string    `json:"exclude,omitempty"` // regexp; a match skips the item
+	IntervalMin int       `json:"interval_min"`
+	LastChecked time.Time `json:"last_checked"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+type rssItem struct {
+	GUID     string    `json:"guid"`
+	FeedID   string    `json:"feed_id"`
+	Title    string    `json:"title"`
+	Link     string    `json:"link"`
+	InfoHash string    `json:"info_hash,omitempty"`
+	QueuedAt time.Time `json:"queued_at"`
+	Done     bool      `json:"done"`
+	Error    string    `json:"error,omitempty"`
+}
+
+type rssState struct {
+	Feeds []*rssFeed `json:"feeds"`
+	Items []*rssItem `json:"items"`
+}
+
+var (
+	rssMu sync.Mutex
+	rss   = rssState{}
+)
+
+// rssDoc covers RSS 2.0 plus the torznab/jackett "magneturl" attribute.
+type rssDoc struct {
+	Items []struct {
+		Title     string `xml:"title"`
+		Link      string `xml:"link"`
+		GUID      string `xml:"guid"`
+		Enclosure struct {
+			URL string `xml:"url,attr"`
+		} `xml:"enclosure"`
+		Attrs []struct {
+			Name  string `xml:"name,attr"`
+			Value string `xml:"value,attr"`
+		} `xml:"attr"`
+	} `xml:"channel>item"`
+}
+
+// startRSS restores persisted feeds, resumes unfinished downloads and
+// starts the polling loop.
+func startRSS() {
+	rssMu.Lock()
+	if err := loadJSON(rssStateFile, &rss); err != nil {
+		log.Printf("rss: load state: %v", err)
+	}
+	var pending []*rssItem
+	for _, it := range rss.Items {
+		if !it.Done && it.Error == "" {
+			pending = append(pending, it)
+		}
+	}
+	rssMu.Unlock()
+
+	for _, it := range pending {
+		queueRSSItem(it)
+	}
+	go rssLoop()
+}
+
+func rssLoop() {
+	for {
+		rssMu.Lock()
+		var due []*rssFeed
+		now := time.Now()
+		for _, f := range rss.Feeds {
+			if now.Sub(f.LastChecked) >= time.Duration(f.IntervalMin)*time.Minute {
+				due = append(due, f)
+			}
+		}
+		rssMu.Unlock()
+
+		for _, f := range due {
+			checkFeed(f)
+		}
+		time.Sleep(time.Minute)
+	}
+}
+
+// checkFeed fetches one feed and queues every new matching item.
+func checkFeed(feed *rssFeed) {
+	rssMu.Lock()
+	url, include, exclude := feed.URL, feed.Include, feed.Exclude
+	rssMu.Unlock()
+
+	doc, err := fetchFeed(url)
+
+	rssMu.Lock()
+	feed.LastChecked = time.Now()
+	feed.LastError = ""
+	if err != nil {
+		feed.LastError = err.Error()
+		rssMu.Unlock()
+		saveRSS()
+		log.Printf("rss: %s: %v", url, err)
+		return
+	}
+	// Regexes were validated when the feed was stored.
+	incRe, _ := compileOptional(include)
+	excRe, _ := compileOptional(exclude)
+	seen := make(map[string]bool, len(rss.Items))
+	for _, it := range rss.Items {
+		seen[it.FeedID+"\x00"+it.GUID] = true
+	}
+
+	var queued []*rssItem
+	for _, x := range doc.Items {
+		link := x.Enclosure.URL
+		for _, a := range x.Attrs {
+			if a.Name == "magneturl" && a.Value != "" {
+				link = a.Value
+			}
+		}
+		if link == "" {
+			link = x.Link
+		}
+		guid := x.GUID
+		if guid == "" {
+			guid = link
+		}
+		if link == "" || seen[feed.ID+"\x00"+guid] {
+			continue
+		}
+		if incRe != nil && !incRe.MatchString(x.Title) {
+			continue
+		}
+		if excRe != nil && excRe.MatchString(x.Title) {
+			continue
+		}
+		it := &rssItem{
+			GUID:     guid,
+			FeedID:   feed.ID,
+			Title:    strings.TrimSpace(x.Title),
+			Link:     link,
+			QueuedAt: time.Now(),
+		}
+		rss.Items = append(rss.Items, it)
+		seen[feed.ID+"\x00"+guid] = true
+		queued = append(queued, it)
+	}
+	trimRSSItems()
+	rssMu.Unlock()
+
+	for _, it := range queued {
+		log.Printf("rss: queued %q", it.Title)
+		queueRSSItem(it)
+	}
+	saveRSS()
+}
+
+func fetchFeed(url string) (*rssDoc, error) {
+	req, err := newFetchRequest(context.Background(), url, fetchAuth{})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := fetchClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch: %s", resp.Status)
+	}
+	var doc rssDoc
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, 5<<20)).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("parse: %w", err)
+	}
+	return &doc, nil
+}
+
+func queueRSSItem(it *rssItem) {
+	t, err := startDownload(it.Link, func(*torrent.Torrent) {
+		rssMu.Lock()
+		it.Done = true
+		rssMu.Unlock()
+		saveRSS()
+	})
+	rssMu.Lock()
+	if err != nil {
+		it.Error = err.Error()
+		log.Printf("rss: %q: %v", it.Title, err)
+	} else {
+		it.InfoHash = t.InfoHash().HexString()
+	}
+	rssMu.Unlock()
+}
+
+// trimRSSItems caps the seen-item history, dropping the oldest finished
+// or failed entries. Pending and running ones stay, over the cap if need
+// be, so they are neither fetched again nor lost. Caller holds rssMu.
+func trimRSSItems() {
+	over := len(rss.Items) - rssMaxItems
+	if over <= 0 {
+		return
+	}
+	kept := rss.Items[:0]
+	for _, it := range rss.Items {
+		if over > 0 && (it.Done || it.Error != "") {
+			over--
+			continue
+		}
+		kept = append(kept, it)
+	}
+	rss.Items = kept
+}
+
+func saveRSS() {
+	rssMu.Lock()
+	defer rssMu.Unlock()
+	if err := saveJSON(rssStateFile, &rss); err != nil {
+		log.Printf("rss: save state: %v", err)
+	}
+}
+
+func compileOptional(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	return regexp.Compile(expr)
+}
+
+// validateFeed checks a feed definition before it's stored.
+func validateFeed(f *rssFeed) error {
+	if !strings.HasPrefix(f.URL, "http://") && !strings.HasPrefix(f.URL, "https://") {
+		return fmt.Errorf("url must be http(s)")
+	}
+	if _, err := compileOptional(f.Include); err != nil {
+		return fmt.Errorf("include: %v", err)
+	}
+	if _, err := compileOptional(f.Exclude); err != nil {
+		return fmt.Errorf("exclude: %v", err)
+	}
+	if f.IntervalMin <= 0 {
+		f.IntervalMin = rssDefaultInterval
+	}
+	return nil
+}
+
+// ── GET|POST|PUT|DELETE /rss ──────────────────────────────────────────────────
+//
+//	GET                  → {"feeds": [...], "items": [...]}
+//	POST   {feed json}   → create
+//	PUT    ?id= {json}   → replace url / filters / interval
+//	DELETE ?id=          → remove feed (its queued downloads keep running)
+func handleRSS(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	switch r.Method {
+	case http.MethodGet:
+		rssMu.Lock()
+		b, err := json.Marshal(&rss)
+		rssMu.Unlock()
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(b)
+
+	case http.MethodPost, http.MethodPut:
+		var in rssFeed
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			http.Error(w, "invalid JSON body: "+err.Error(), 400)
+			return
+		}
+		if err := validateFeed(&in); err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		rssMu.Lock()
+		var feed *rssFeed
+		if r.Method == http.MethodPost {
+			feed = &rssFeed{ID: newID()}
+			rss.Feeds = append(rss.Feeds, feed)
+		} else {
+			for _, f := range rss.Feeds {
+				if f.ID == id {
+					feed = f
+				}
+			}
+			if feed == nil {
+				rssMu.Unlock()
+				http.Error(w, "unknown feed id", 404)
+				return
+			}
+		}
+		feed.URL, feed.Include, feed.Exclude = in.URL, in.Include, in.Exclude
+		feed.IntervalMin = in.IntervalMin
+		feed.LastChecked = time.Time{} // re-check on the next loop tick
+		out := *feed
+		rssMu.Unlock()
+		saveRSS()
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(out)
+
+	case http.MethodDelete:
+		rssMu.Lock()
+		found := false
+		for i, f := range rss.Feeds {
+			if f.ID == id {
+				rss.Feeds = append(rss.Feeds[:i], rss.Feeds[i+1:]...)
+				found = true
+				break
+			}
+		}
+		rssMu.Unlock()
+		if !found {
+			http.Error(w, "unknown feed id", 404)
+			return
+		}
+		saveRSS()
+		w.WriteHeader(200)
+		fmt.Fprint(w, "deleted")
+
+	default:
+		http.Error(w, "GET, POST, PUT or DELETE only", 405)
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+)
+
+// ── Encrypted state & secret store ────────────────────────────────────────────
+// When the host app passes ROXBOX_SECRET_KEY at startup (ideally a random
+// value kept in the platform keystore), every state file written through
+// saveJSONAt is sealed with AES-256-GCM. Plain files from older versions
+// are still read and get encrypted on their next save.
+//
+// Secrets (tracker credentials, API tokens…) live in secrets.json and are
+// only ever stored encrypted: without a key the store refuses writes.
+
+const (
+	secretsFile = "secrets.json"
+	sealMagic   = "RBX1"
+)
+
+var (
+	sealKey []byte // nil: state files are plain JSON
+
+	secretsMu sync.Mutex
+	secrets   = map[string]string{}
+)
+
+// initSealKey derives the AES key from ROXBOX_SECRET_KEY and scrubs the
+// variable so child processes don't inherit it.
+func initSealKey() {
+	k := os.Getenv("ROXBOX_SECRET_KEY")
+	if k == "" {
+		return
+	}
+	_ = os.Unsetenv("ROXBOX_SECRET_KEY")
+	sum := sha256.Sum256([]byte(k))
+	sealKey = sum[:]
+}
+
+func seal(plain []byte) ([]byte, error) {
+	block, err := aes.NewCipher(sealKey)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	out := append([]byte(sealMagic), nonce...)
+	return gcm.Seal(out, nonce, plain, []byte(sealMagic)), nil
+}
+
+func isSealed(b []byte) bool {
+	return bytes.HasPrefix(b, []byte(sealMagic))
+}
+
+func unseal(b []byte) ([]byte, error) {
+	if sealKey == nil {
+		return nil, errors.New("state file is encrypted but no ROXBOX_SECRET_KEY was given")
+	}
+	block, err := aes.NewCipher(sealKey)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	b = b[len(sealMagic):]
+	if len(b) < gcm.NonceSize() {
+		return nil, errors.New("encrypted state file is truncated")
+	}
+	plain, err := gcm.Open(nil, b[:gcm.NonceSize()], b[gcm.NonceSize():], []byte(sealMagic))
+	if err != nil {
+		return nil, errors.New("cannot decrypt state file (wrong key?)")
+	}
+	return plain, nil
+}
+
+func loadSecrets() {
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	if err := loadJSON(secretsFile, &secrets); err != nil {
+		log.Printf("secrets: load: %v", err)
+	}
+	if secrets == nil {
+		secrets = map[string]string{}
+	}
+}
+
+// getSecret returns a stored secret, or "" when unset.
+func getSecret(name string) string {
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	return secrets[name]
+}
+
+func setSecret(name, value string) error {
+	if sealKey == nil {
+		return errors.New("secret storage needs ROXBOX_SECRET_KEY")
+	}
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	if value == "" {
+		delete(secrets, name)
+	} else {
+		secrets[name] = value
+	}
+	return saveJSON(secretsFile, secrets)
+}
+
+// ── GET|PUT|DELETE /secrets ───────────────────────────────────────────────────
+//
+//	GET                 → names of stored secrets (never the values)
+//	PUT ?name= {value}  → store {"value": "..."}
+//	DELETE ?name=       → remove
+func handleSecrets(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	switch r.Method {
+	case http.MethodGet:
+		secretsMu.Lock()
+		names := make([]string, 0, len(secrets))
+		for n := range secrets {
+			names = append(names, n)
+		}
+		secretsMu.Unlock()
+		sort.Strings(names)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"encrypted": sealKey != nil, "names": names})
+		return
+	case http.MethodPut, http.MethodDelete:
+		if name == "" {
+			http.Error(w, "name param required", 400)
+			return
+		}
+		var in struct {
+			Value string `json:"value"`
+		}
+		if r.Method == http.MethodPut {
+			if err := json.NewDecoder(r.Body).Decode(&in); err != nil || in.Value == "" {
+				http.Error(w, `JSON body {"value": "..."} required`, 400)
+				return
+			}
+		}
+		if err := setSecret(name, in.Value); err != nil {
+			http.Error(w, err.Error(), 409)
+			return
+		}
+		w.WriteHeader(200)
+		fmt.Fprint(w, "ok")
+	default:
+		http.Error(w, "GET, PUT or DELETE only", 405)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/anacrolix/torrent"
+
+	"github.com/roxbox/torrent_server/engine"
+)
+
+// ── GET /seek/nearest?offset=<bytes> ──────────────────────────────────────────
+// The buffered byte closest to offset in the active file, so the player can
+// snap a seek to data it can play at once instead of landing in a hole.
+// Pieces already verified count as buffered; failing those, a piece with
+// some chunks in is the cheapest place to wait. run_start/run_end bound the
+// contiguous run of such pieces around the answer.
+
+type seekNearest struct {
+	Offset   int64 `json:"offset"`   // as asked
+	Nearest  int64 `json:"nearest"`  // where to seek
+	Buffered bool  `json:"buffered"` // nearest is in a verified piece
+	Partial  bool  `json:"partial,omitempty"`
+	RunStart int64 `json:"run_start"`
+	RunEnd   int64 `json:"run_end"` // exclusive
+}
+
+func handleSeekNearest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "GET only", 405)
+		return
+	}
+	sess := sessionFor(w, r)
+	if sess == nil {
+		return
+	}
+	t, f := sess.current()
+	if t == nil || f == nil {
+		http.Error(w, "no active torrent", 503)
+		return
+	}
+	off, err := strconv.ParseInt(r.URL.Query().Get("offset"), 10, 64)
+	if err != nil || off < 0 || off >= f.Length() {
+		http.Error(w, "offset must be a byte offset within the file", 400)
+		return
+	}
+	sess.active()
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(nearestBuffered(t, engine.PieceRange(f), off))
+}
+
+// nearestBuffered searches outward from off's piece for a verified piece,
+// then for a partially downloaded one.
+func nearestBuffered(t *torrent.Torrent, span engine.PieceSpan, off int64) seekNearest {
+	res := seekNearest{Offset: off, Nearest: off, RunStart: off, RunEnd: off}
+	states := make([]torrent.PieceState, span.Len())
+	for i := range states {
+		states[i] = t.PieceState(span.Begin + i)
+	}
+	complete := func(i int) bool { return states[i-span.Begin].Complete }
+	partial := func(i int) bool { return states[i-span.Begin].Partial }
+
+	for _, ok := range []func(int) bool{complete, partial} {
+		best, bestDist := -1, int64(-1)
+		var bestOff int64
+		for i := span.Begin; i < span.End; i++ {
+			if !ok(i) {
+				continue
+			}
+			start, end := span.FileBounds(i)
+			at := min(max(off, start), end-1)
+			d := at - off
+			if d < 0 {
+				d = -d
+			}
+			if best < 0 || d < bestDist {
+				best, bestDist, bestOff = i, d, at
+			}
+		}
+		if best < 0 {
+			continue
+		}
+		res.Nearest = bestOff
+		res.Buffered = complete(best)
+		res.Partial = !res.Buffered
+		lo, hi := best, best
+		for lo > span.Begin && ok(lo-1) {
+			lo--
+		}
+		for hi+1 < span.End && ok(hi+1) {
+			hi++
+		}
+		res.RunStart, _ = span.FileBounds(lo)
+		_, res.RunEnd = span.FileBounds(hi)
+		return res
+	}
+	return res
+}
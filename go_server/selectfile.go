@@ -0,0 +1,146 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/anacrolix/torrent"
+
+	"github.com/roxbox/torrent_server/engine"
+)
+
+// ── Switching files ───────────────────────────────────────────────────────────
+// Automatic selection streams one file of a torrent, which is the wrong one
+// as often as not in a season pack. POST /select?file=<index> (or a GET of
+// /stream?file=<index>), or /select?pattern=S01E05 for the episode's video
+// (engine.MatchEpisode), moves the session to another file of the same
+// torrent without re-adding it: the old file and its companions stop
+// downloading, the new one gets the streaming priorities, readahead and
+// companions, and /status starts over for it.
+
+var errNoFileYet = errors.New("no torrent info yet")
+
+// fileIndexParam reads the file param as an index into t's files.
+func fileIndexParam(r *http.Request, t *torrent.Torrent) (*torrent.File, error) {
+	i, err := strconv.Atoi(r.URL.Query().Get("file"))
+	if err != nil {
+		return nil, errors.New("file must be an index from /files")
+	}
+	files := t.Files()
+	if i < 0 || i >= len(files) {
+		return nil, fmt.Errorf("file %d out of range: the torrent has %d files", i, len(files))
+	}
+	return files[i], nil
+}
+
+// switchFile makes f the session's file. Only the selection changes when f
+// already is.
+func (s *session) switchFile(t *torrent.Torrent, f *torrent.File, sel engine.Selection, reqID string) error {
+	if err := s.profile.checkPolicy(t, f); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	if s.torr != t || s.file == nil {
+		s.mu.Unlock()
+		return errNoFileYet
+	}
+	old, oldComps := s.file, s.companions
+	if old == f {
+		s.selection = sel
+		s.mu.Unlock()
+		return nil
+	}
+	s.file = f
+	s.selection = sel
+	s.companions = nil
+	st := &s.status
+	st.State, st.Progress, st.CompletedMB = "loading", 0, 0
+	st.PositionSec, st.DurationSec, st.ResumeAtSec = 0, 0, 0
+	st.WindowAvailability = nil
+	s.touch()
+	s.mu.Unlock()
+	s.stopTee() // sized and offset for the old file
+
+	lowerEmbeddedCues(old)
+	engine.UnprioritiseFile(t, old, f) // its pieces carry priorities of their own
+	for _, c := range oldComps {
+		c.File.SetPriority(torrent.PiecePriorityNone)
+	}
+	s.profile.recordHistory(t, f)
+	s.prioritise(t, f) // datasaver.go
+	comps := engine.FindCompanions(t, f)
+	engine.PrioritiseCompanions(comps)
+	s.mu.Lock()
+	if s.file == f {
+		s.companions = comps
+	}
+	s.mu.Unlock()
+
+	resumeExports(t, f)
+	go s.statsLoop(t, f) // the old file's loops see the switch and return
+	go s.governFill(t, f)
+	log.Printf("Switched to %s (%s)", f.DisplayPath(), sel.Reason)
+	s.event(reqID, "switched: "+f.DisplayPath())
+	return nil
+}
+
+// ── POST /select?file=<index>|pattern=<episode> ───────────────────────────────
+func handleSelect(w http.ResponseWriter, r *http.Request) {
+	if sess := sessionFor(w, r); sess != nil {
+		serveSelect(w, r, sess)
+	}
+}
+
+// serveSelect answers /select for sess (/torrents/{hash}/select too) with
+// the session's /info.
+func serveSelect(w http.ResponseWriter, r *http.Request, sess *session) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST only", 405)
+		return
+	}
+	if selectByParam(w, r, sess) {
+		writeInfo(w, sess) // info.go
+	}
+}
+
+// selectByParam switches sess to the file the request names, answering the
+// request itself when it can't.
+func selectByParam(w http.ResponseWriter, r *http.Request, sess *session) bool {
+	t, cur := sess.current()
+	if t == nil || cur == nil {
+		http.Error(w, errNoFileYet.Error(), 503)
+		return false
+	}
+	q := r.URL.Query()
+	var f *torrent.File
+	var sel engine.Selection
+	var err error
+	switch {
+	case q.Has("file") && q.Has("pattern"):
+		http.Error(w, "file or pattern, not both", 400)
+		return false
+	case q.Has("pattern"):
+		if f, sel, err = engine.MatchEpisode(t, q.Get("pattern"), sess.profile.selectFilter()); err != nil {
+			http.Error(w, err.Error(), 404)
+			return false
+		}
+	default:
+		if f, err = fileIndexParam(r, t); err != nil {
+			http.Error(w, err.Error(), 400)
+			return false
+		}
+		sel = engine.Selection{Reason: "selected file"}.WithEpisode(f)
+	}
+	if err := sess.switchFile(t, f, sel, requestID(r)); err != nil {
+		code := 403 // the stream policy
+		if err == errNoFileYet {
+			code = 503
+		}
+		http.Error(w, err.Error(), code)
+		return false
+	}
+	return true
+}
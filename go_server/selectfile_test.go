@@ -0,0 +1,26 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/anacrolix/torrent"
+
+	"github.com/roxbox/torrent_server/engine"
+)
+
+func TestUnprioritiseOldFile(t *testing.T) {
+	// The files share a piece: a's last, b's first.
+	tor := offlineTorrent(t, 10*testPieceLength+100, 6*testPieceLength)
+	old, f := tor.Files()[0], tor.Files()[1]
+	engine.PrioritiseFile(tor, old)
+	engine.UnprioritiseFile(tor, old, f)
+	engine.PrioritiseFile(tor, f)
+
+	span, kept := engine.PieceRange(old), engine.PieceRange(f)
+	for i := span.Begin; i < span.End; i++ {
+		p := tor.PieceState(i).Priority
+		if shared := i >= kept.Begin; shared != (p != torrent.PiecePriorityNone) {
+			t.Errorf("piece %d (shared %v) at priority %d", i, shared, p)
+		}
+	}
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/bencode"
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/anacrolix/torrent/storage"
+
+	"github.com/roxbox/torrent_server/engine"
+)
+
+// ── End-to-end self-test ──────────────────────────────────────────────────────
+// An isolated harness: a local seeder serving a generated file, a loopback
+// leecher client, and the real HTTP handlers on an httptest server. It drives
+// the add → ready → range seek → stop flow and checks every byte.
+//
+// Used by the test suite and by `roxbox selftest-e2e`, which lets users
+// check that streaming works on their device without any network.
+
+const selfTestSize = 6 << 20 // 6 MiB
+
+type e2eEnv struct {
+	dir    string
+	data   []byte
+	magnet string
+	seeder *torrent.Client
+	leech  *torrent.Client
+	srv    *httptest.Server
+	proxy  *simProxy // nil without -netsim
+}
+
+// loopbackConfig is a client config that only talks over 127.0.0.1.
+func loopbackConfig(cfg *torrent.ClientConfig) *torrent.ClientConfig {
+	cfg.ListenHost = torrent.LoopbackListenHost
+	cfg.ListenPort = 0
+	cfg.NoDHT = true
+	cfg.DisableTrackers = true
+	cfg.DisableUTP = true
+	cfg.DisableIPv6 = true
+	cfg.NoDefaultPortForwarding = true
+	cfg.Logger = torrentLogger()
+	return cfg
+}
+
+// e2eCache is the cacheDir of every harness in the process. It is set once:
+// goroutines of an earlier harness's sessions may still be reading it.
+var e2eCache struct {
+	once sync.Once
+	dir  string
+	err  error
+}
+
+// e2eCacheDir points cacheDir at the process's harness cache directory.
+func e2eCacheDir() error {
+	e2eCache.once.Do(func() {
+		if e2eCache.dir, e2eCache.err = os.MkdirTemp("", "roxbox-e2e-cache-"); e2eCache.err == nil {
+			cacheDir = e2eCache.dir
+		}
+	})
+	return e2eCache.err
+}
+
+// removeE2ECache removes the harness cache directory once no harness is left.
+func removeE2ECache() {
+	if e2eCache.dir != "" {
+		_ = os.RemoveAll(e2eCache.dir)
+	}
+}
+
+// newE2EEnv sets up the harness. It points the package globals (client,
+// cacheDir) at temporary directories, so it must not run next to a live
+// server in the same process. Each harness gets a leecher data directory of
+// its own; cacheDir is shared (e2eCacheDir).
+// With sim set, the seeder is reached through a simulated link.
+func newE2EEnv(size int, sim *netSim) (env *e2eEnv, err error) {
+	dir, err := os.MkdirTemp("", "roxbox-e2e-")
+	if err != nil {
+		return nil, err
+	}
+	env = &e2eEnv{dir: dir}
+	defer func() {
+		if err != nil {
+			env.Close()
+		}
+	}()
+
+	// Content: random bytes named like a video so it gets picked.
+	seedDir := filepath.Join(dir, "seed")
+	if err = os.MkdirAll(seedDir, 0755); err != nil {
+		return
+	}
+	env.data = make([]byte, size)
+	if _, err = rand.Read(env.data); err != nil {
+		return
+	}
+	path := filepath.Join(seedDir, "selftest.mp4")
+	if err = os.WriteFile(path, env.data, 0644); err != nil {
+		return
+	}
+	info := metainfo.Info{PieceLength: 256 << 10}
+	if err = info.BuildFromFilePath(path); err != nil {
+		return
+	}
+	mi := metainfo.MetaInfo{}
+	if mi.InfoBytes, err = bencode.Marshal(info); err != nil {
+		return
+	}
+
+	// Seeder
+	scfg := loopbackConfig(torrent.NewDefaultClientConfig())
+	scfg.Seed = true
+	scfg.DataDir = seedDir
+	scfg.DefaultStorage = storage.NewFile(seedDir)
+	if env.seeder, err = torrent.NewClient(scfg); err != nil {
+		return
+	}
+	st, err := env.seeder.AddTorrent(&mi)
+	if err != nil {
+		return
+	}
+	st.VerifyData()
+	if !st.Seeding() {
+		return env, errors.New("seeder does not have the complete file")
+	}
+	addrs := env.seeder.ListenAddrs()
+	if len(addrs) == 0 {
+		return env, errors.New("seeder is not listening")
+	}
+	seedAddr := addrs[0].String()
+	if sim != nil {
+		if env.proxy, err = startSimProxy(seedAddr, sim); err != nil {
+			return
+		}
+		seedAddr = env.proxy.Addr()
+	}
+	ih := mi.HashInfoBytes()
+	env.magnet = mi.Magnet(&ih, &info).String() + "&x.pe=" + seedAddr
+
+	// Leecher + HTTP layer
+	if err = e2eCacheDir(); err != nil {
+		return
+	}
+	dataDir := filepath.Join(dir, "data")
+	if err = os.MkdirAll(dataDir, 0755); err != nil {
+		return
+	}
+	if env.leech, err = torrent.NewClient(loopbackConfig(engine.NewClientConfig(dataDir))); err != nil {
+		return
+	}
+	clientMu.Lock()
+	client = env.leech
+	clientMu.Unlock()
+	profilesMu.Lock()
+	profiles = map[string]*profile{} // sessions from a previous run point at old dirs
+	profilesMu.Unlock()
+	env.srv = httptest.NewServer(withInstance(withTracing(withRecover(newMux()))))
+	return env, nil
+}
+
+func (e *e2eEnv) Close() {
+	if e.srv != nil {
+		_, _ = e.post("/stop")
+		e.srv.Close()
+	}
+	if e.leech != nil {
+		e.leech.Close()
+	}
+	if e.proxy != nil {
+		e.proxy.Close()
+	}
+	if e.seeder != nil {
+		e.seeder.Close()
+	}
+	_ = os.RemoveAll(e.dir)
+}
+
+func (e *e2eEnv) post(path string) (*http.Response, error) {
+	resp, err := http.Post(e.srv.URL+path, "application/x-www-form-urlencoded", nil)
+	if err == nil {
+		resp.Body.Close()
+	}
+	return resp, err
+}
+
+func (e *e2eEnv) add() error {
+	resp, err := http.PostForm(e.srv.URL+"/add", map[string][]string{"magnet": {e.magnet}})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("/add: HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (e *e2eEnv) status() (StatusResponse, error) {
+	var st StatusResponse
+	resp, err := http.Get(e.srv.URL + "/status")
+	if err != nil {
+		return st, err
+	}
+	defer resp.Body.Close()
+	err = json.NewDecoder(resp.Body).Decode(&st)
+	return st, err
+}
+
+func (e *e2eEnv) waitReady(timeout time.Duration) (StatusResponse, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		st, err := e.status()
+		if err != nil {
+			return st, err
+		}
+		switch st.State {
+		case "ready":
+			return st, nil
+		case "error":
+			return st, fmt.Errorf("session error: %s", st.Error)
+		}
+		if time.Now().After(deadline) {
+			return st, fmt.Errorf("not ready after %s (state %q, %.1f%%, %d peers)", timeout, st.State, st.Progress, st.Peers)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// checkRange fetches [off, off+n) through /stream and compares it with the
+// seeded content.
+func (e *e2eEnv) checkRange(off, n int64) error {
+	req, _ := http.NewRequest("GET", e.srv.URL+"/stream", nil)
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", off, off+n-1))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent {
+		return fmt.Errorf("range %d+%d: HTTP %d", off, n, resp.StatusCode)
+	}
+	got, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("range %d+%d: %v", off, n, err)
+	}
+	if !bytes.Equal(got, e.data[off:off+n]) {
+		return fmt.Errorf("range %d+%d: content mismatch (%d bytes read)", off, n, len(got))
+	}
+	return nil
+}
+
+func (e *e2eEnv) checkStopped() error {
+	if _, err := e.post("/stop"); err != nil {
+		return err
+	}
+	st, err := e.status()
+	if err != nil {
+		return err
+	}
+	if st.State != "idle" {
+		return fmt.Errorf("state after stop is %q", st.State)
+	}
+	resp, err := http.Get(e.srv.URL + "/stream")
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 503 {
+		return fmt.Errorf("/stream after stop: HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// selfTestSteps is the flow shared by the test suite and the command.
+func selfTestSteps(e *e2eEnv) []struct {
+	Name string
+	Run  func() error
+} {
+	size := int64(len(e.data))
+	return []struct {
+		Name string
+		Run  func() error
+	}{
+		{"add", e.add},
+		{"ready", func() error { _, err := e.waitReady(30 * time.Second); return err }},
+		{"range start", func() error { return e.checkRange(0, 64<<10) }},
+		{"range seek middle", func() error { return e.checkRange(size/2+12345, 300<<10) }},
+		{"range tail", func() error { return e.checkRange(size-1000, 1000) }},
+		{"range back", func() error { return e.checkRange(size/4, 128<<10) }},
+		{"stop", e.checkStopped},
+	}
+}
+
+// runSelfTestE2E implements `roxbox selftest-e2e`; it returns the exit code.
+func runSelfTestE2E() int {
+	sim, err := parseNetSim(*netSimFlag)
+	if err != nil {
+		fmt.Println("FAIL", err)
+		return 2
+	}
+	if sim != nil {
+		fmt.Println("simulated network:", sim)
+	}
+	start := time.Now()
+	env, err := newE2EEnv(selfTestSize, sim)
+	if err != nil {
+		fmt.Println("FAIL setup:", err)
+		return 1
+	}
+	defer removeE2ECache()
+	defer env.Close()
+	for _, step := range selfTestSteps(env) {
+		t0 := time.Now()
+		if err := step.Run(); err != nil {
+			fmt.Printf("FAIL %-18s %v\n", step.Name, err)
+			return 1
+		}
+		fmt.Printf("ok   %-18s %s\n", step.Name, time.Since(t0).Round(time.Millisecond))
+	}
+	fmt.Printf("PASS (%s)\n", time.Since(start).Round(time.Millisecond))
+	return 0
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	removeE2ECache()
+	os.Exit(code)
+}
+
+func TestEndToEnd(t *testing.T) {
+	if testing.Short() {
+		t.Skip("end-to-end flow needs loopback networking")
+	}
+	env, err := newE2EEnv(selfTestSize, nil)
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	defer env.Close()
+
+	for _, step := range selfTestSteps(env) {
+		if !t.Run(step.Name, func(t *testing.T) {
+			if err := step.Run(); err != nil {
+				t.Fatal(err)
+			}
+		}) {
+			return // later steps depend on earlier ones
+		}
+	}
+}
+
+func TestReAddAfterStop(t *testing.T) {
+	if testing.Short() {
+		t.Skip("end-to-end flow needs loopback networking")
+	}
+	env, err := newE2EEnv(1<<20, nil)
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	defer env.Close()
+
+	for i := 0; i < 2; i++ {
+		if err := env.add(); err != nil {
+			t.Fatalf("add #%d: %v", i+1, err)
+		}
+		if _, err := env.waitReady(30 * time.Second); err != nil {
+			t.Fatalf("ready #%d: %v", i+1, err)
+		}
+		if err := env.checkRange(4096, 8192); err != nil {
+			t.Fatalf("range #%d: %v", i+1, err)
+		}
+		if err := env.checkStopped(); err != nil {
+			t.Fatalf("stop #%d: %v", i+1, err)
+		}
+	}
+}
+
+func TestEndToEndSimulatedNetwork(t *testing.T) {
+	if testing.Short() {
+		t.Skip("end-to-end flow needs loopback networking")
+	}
+	sim, err := parseNetSim("rate=2000,latency=20ms,loss=1,seed=42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	env, err := newE2EEnv(1<<20, sim)
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	defer env.Close()
+
+	for _, step := range selfTestSteps(env) {
+		if err := step.Run(); err != nil {
+			t.Fatalf("%s: %v", step.Name, err)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+//go:build windows
+
+package main
+
+import (
+	"log"
+
+	"golang.org/x/sys/windows/svc"
+)
+
+// Windows service support. Register with e.g.
+//
+//	sc create roxbox binPath= "C:\roxbox\roxbox.exe -lan" start= auto
+//
+// Under the service manager daemon defaults (log file, PID file) apply
+// automatically; "sc stop" and system shutdown stop the server gracefully.
+
+func init() {
+	if ok, err := svc.IsWindowsService(); err != nil || !ok {
+		return
+	}
+	*daemonMode = true
+	runService = func(serve, stop func()) bool {
+		go serve()
+		if err := svc.Run("roxbox", &winService{stop: stop}); err != nil {
+			log.Printf("service: %v", err)
+		}
+		return true
+	}
+}
+
+type winService struct {
+	stop func()
+}
+
+func (s *winService) Execute(args []string, req <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	const accepts = svc.AcceptStop | svc.AcceptShutdown | svc.AcceptParamChange
+	status <- svc.Status{State: svc.Running, Accepts: accepts}
+	for c := range req {
+		switch c.Cmd {
+		case svc.Interrogate:
+			status <- c.CurrentStatus
+		case svc.ParamChange:
+			reload()
+		case svc.Stop, svc.Shutdown:
+			status <- svc.Status{State: svc.StopPending}
+			s.stop()
+			return false, 0
+		}
+	}
+	return false, 0
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/types"
+
+	"github.com/roxbox/torrent_server/engine"
+)
+
+// session is one profile's streaming slot: the torrent being watched, the
+// file picked from it and everything derived from those.
+type session struct {
+	profile *profile
+	hash    string // infohash of a background session (torrents.go), "" for the primary one
+
+	mu     sync.RWMutex
+	torr   *torrent.Torrent
+	file   *torrent.File
+	pieces engine.PieceProfile
+	prio   streamPrio // the add's priority overrides
+	status StatusResponse
+	tee    *streamTee
+
+	statusCh chan struct{} // closed on the next status change (statuswait.go)
+
+	// Delta tracking (statusdelta.go), guarded by mu.
+	seq, seqBase uint64
+	fieldSeq     map[string]uint64
+	lastFields   map[string]json.RawMessage
+
+	selection  engine.Selection   // why file was picked (/info)
+	companions []engine.Companion // subtitle/audio files paired with file
+	watermark  *watermarkSpec     // burned into /stream when set (watermark.go)
+	local      *localMedia        // a file on disk played instead of a torrent (local.go)
+	http       *httpSource        // a video URL played instead of a torrent (httpsource.go)
+
+	// Startup timing (timing.go), guarded by mu.
+	added           time.Time
+	timingsRecorded bool
+
+	// Add queue (addqueue.go).
+	addMu     sync.Mutex
+	addSeq    uint64
+	addLatest *addCmd
+	addQueue  chan *addCmd
+
+	// Player state (player.go).
+	playerMu    sync.Mutex
+	playerTimer *time.Timer
+	trickleOn   bool
+	idlePaused  bool         // idle.go
+	addPaused   bool         // added with paused, not read yet
+	limitHeld   bool         // waiting under the global limits (limits.go)
+	lastActive  atomic.Int64 // unix nanos of the last read, poll or report
+	// What trickle mode holds back: the file and its pieces' priorities.
+	trickleFile *torrent.File
+	trickleHeld map[int]types.PiecePriority
+	readersMu   sync.Mutex
+	readers     map[*trackedReader]struct{}
+}
+
+func newSession(p *profile) *session {
+	return &session{
+		profile: p,
+		status:  StatusResponse{State: "idle"},
+		readers: map[*trackedReader]struct{}{},
+	}
+}
+
+// event records a session state change in the event log.
+func (s *session) event(requestID, msg string) {
+	recordEvent(event{Kind: "session", RequestID: requestID, Profile: s.profile.ID, Message: msg})
+}
+
+// current returns the active torrent and file (either may be nil).
+func (s *session) current() (*torrent.Torrent, *torrent.File) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.torr, s.file
+}
+
+func (s *session) snapshotStatus() StatusResponse {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.status
+}
+
+// streamURL is where the player should fetch this session's bytes.
+func (s *session) streamURL() string {
+	if s.hash != "" {
+		return "http://" + advertiseHost() + ":" + port + "/torrents/" + s.hash + "/stream" + s.profile.query()
+	}
+	return "http://" + advertiseHost() + ":" + port + "/stream" + s.profile.query()
+}
+
+// releaseTorrent drops t unless another session (this profile's background
+// downloads or another profile) still holds it (one Torrent per infohash)
+// or it went with its client (heal.go).
+func releaseTorrent(t *torrent.Torrent, from *session) {
+	select {
+	case <-t.Closed():
+		return
+	default:
+	}
+	if !torrentInUseElsewhere(t, from) {
+		t.Drop()
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ── Site credentials ──────────────────────────────────────────────────────────
+// Private trackers serve .torrent files (and their RSS feeds) only to a
+// logged-in account. Fetches the server makes for the app — /add?url=,
+// /add/url, queued adds, feeds, HTTP sources — send a Cookie and/or
+// Authorization header: the ones given with the request (cookie= and
+// authorization= params or JSON fields), else the ones stored for the
+// site in the secret store as "cookie:<domain>" and
+// "authorization:<domain>". Given ones go only to the host of the URL the
+// request names: a .torrent link on an indexer page may point anywhere,
+// and gets the stored ones for its own host at most. The domain is the URL's host or any parent of
+// it, so "cookie:example.org" covers tracker.example.org too. The HTTP
+// client drops both headers on a redirect to another domain.
+
+const (
+	cookieSecretPrefix = "cookie:"
+	authSecretPrefix   = "authorization:"
+)
+
+// fetchAuth is a request's own credentials for the URL it names. It never
+// goes out as JSON, where it would land in queue.json, snapshots and GET
+// /queue; requests send it as fetchAuthJSON.
+type fetchAuth struct {
+	Cookie        string `json:"-"`
+	Authorization string `json:"-"`
+	host          string // authHost of the URL they were given for; none: they go nowhere
+}
+
+// fetchAuthJSON is fetchAuth in a JSON request body.
+type fetchAuthJSON struct {
+	Cookie        string `json:"cookie,omitempty"`
+	Authorization string `json:"authorization,omitempty"` // the whole header value, e.g. "Bearer …"
+}
+
+func (j fetchAuthJSON) auth() fetchAuth {
+	return fetchAuth{Cookie: j.Cookie, Authorization: j.Authorization}
+}
+
+// authForm reads cookie= and authorization= params.
+func authForm(r *http.Request) fetchAuth {
+	return fetchAuth{Cookie: r.FormValue("cookie"), Authorization: r.FormValue("authorization")}
+}
+
+// forURL ties a to the host of u, the URL the caller gave them for.
+func (a fetchAuth) forURL(u string) fetchAuth {
+	if p, err := url.Parse(u); err == nil {
+		a.host = authHost(p)
+	}
+	return a
+}
+
+// authHost is u's host and port, the default one spelled out.
+func authHost(u *url.URL) string {
+	host, port := strings.TrimSuffix(strings.ToLower(u.Hostname()), "."), u.Port()
+	if port == "" {
+		port = map[string]string{"http": "80", "https": "443"}[strings.ToLower(u.Scheme)]
+	}
+	if host == "" {
+		return ""
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// apply sets req's credentials: a's own if req goes to the host they were
+// given for, else the stored ones for its host.
+func (a fetchAuth) apply(req *http.Request) {
+	host := req.URL.Hostname()
+	if a.host == "" || a.host != authHost(req.URL) {
+		a.Cookie, a.Authorization = "", ""
+	}
+	if a.Cookie == "" {
+		a.Cookie = siteSecret(cookieSecretPrefix, host)
+	}
+	if a.Authorization == "" {
+		a.Authorization = siteSecret(authSecretPrefix, host)
+	}
+	if a.Cookie != "" {
+		req.Header.Set("Cookie", a.Cookie)
+	}
+	if a.Authorization != "" {
+		req.Header.Set("Authorization", a.Authorization)
+	}
+}
+
+// siteSecret is the stored secret prefix+domain for host or the closest
+// parent domain that has one.
+func siteSecret(prefix, host string) string {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if net.ParseIP(host) != nil {
+		return getSecret(prefix + host)
+	}
+	for host != "" {
+		if v := getSecret(prefix + host); v != "" {
+			return v
+		}
+		_, parent, ok := strings.Cut(host, ".")
+		if !ok || !strings.Contains(parent, ".") { // stop above example.org
+			break
+		}
+		host = parent
+	}
+	return ""
+}
+
+// newFetchRequest is a GET of u carrying a's credentials.
+func newFetchRequest(ctx context.Context, u string, a fetchAuth) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	a.apply(req)
+	return req, nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// credsServer records the credentials each path was fetched with.
+type credsServer struct {
+	*httptest.Server
+	mu  sync.Mutex
+	got map[string][2]string // path → cookie, authorization
+}
+
+func newCredsServer(page func() string) *credsServer {
+	s := &credsServer{got: map[string][2]string{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.got[r.URL.Path] = [2]string{r.Header.Get("Cookie"), r.Header.Get("Authorization")}
+		s.mu.Unlock()
+		if r.URL.Path == "/page" {
+			fmt.Fprint(w, page())
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	return s
+}
+
+func (s *credsServer) creds(path string) [2]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.got[path]
+}
+
+func TestFetchAuthStaysWithPageHost(t *testing.T) {
+	// The indexer answers on 127.0.0.1, the .torrent link on localhost: two
+	// hosts as far as credentials go.
+	other := newCredsServer(func() string { return "" })
+	defer other.Close()
+	otherURL := strings.Replace(other.URL, "127.0.0.1", "localhost", 1)
+	var link string
+	indexer := newCredsServer(func() string { return `<a href="` + link + `">get</a>` })
+	defer indexer.Close()
+
+	secretsMu.Lock()
+	saved := secrets
+	secrets = map[string]string{cookieSecretPrefix + "localhost": "stored=1"}
+	secretsMu.Unlock()
+	defer func() {
+		secretsMu.Lock()
+		secrets = saved
+		secretsMu.Unlock()
+	}()
+
+	given := fetchAuth{Cookie: "session=secret", Authorization: "Bearer token"}
+	for _, tc := range []struct {
+		name    string
+		link    string
+		server  *credsServer
+		want    [2]string
+		explain string
+	}{
+		{"same host", indexer.URL + "/x.torrent", indexer, [2]string{"session=secret", "Bearer token"}, "the page's own host gets them"},
+		{"other host", otherURL + "/x.torrent", other, [2]string{"stored=1", ""}, "only the stored cookie for localhost"},
+		{"other port", "http://127.0.0.1:1/x.torrent", nil, [2]string{}, "a port is a host of its own"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			link = tc.link
+			auth := given.forURL(indexer.URL + "/page")
+			got, _, err := resolvePageLink(indexer.URL+"/page", mustSelectors(t), auth)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.link {
+				t.Fatalf("link %q, want %q", got, tc.link)
+			}
+			if c := indexer.creds("/page"); c != [2]string{"session=secret", "Bearer token"} {
+				t.Errorf("page fetched with %q, want the given credentials", c)
+			}
+			if tc.server == nil {
+				req, err := newFetchRequest(context.Background(), got, auth)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if c := [2]string{req.Header.Get("Cookie"), req.Header.Get("Authorization")}; c != tc.want {
+					t.Errorf("link would get %q, want %q: %s", c, tc.want, tc.explain)
+				}
+				return
+			}
+			_, _ = fetchMetainfo(got, auth) // 404: only the headers matter
+			if c := tc.server.creds("/x.torrent"); c != tc.want {
+				t.Errorf(".torrent fetched with %q, want %q: %s", c, tc.want, tc.explain)
+			}
+		})
+	}
+}
+
+func TestFetchAuthUnbound(t *testing.T) {
+	req, err := newFetchRequest(context.Background(), "https://tracker.example/x.torrent", fetchAuth{Cookie: "session=secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := req.Header.Get("Cookie"); c != "" {
+		t.Errorf("credentials tied to no URL went out: %q", c)
+	}
+	if req, _ = newFetchRequest(context.Background(), "https://TRACKER.example:443/x", fetchAuth{Cookie: "a=1"}.forURL("https://tracker.example/page")); req.Header.Get("Cookie") != "a=1" {
+		t.Errorf("the same host spelled differently lost its credentials")
+	}
+}
+
+func mustSelectors(t *testing.T) []*regexp.Regexp {
+	res, err := linkSelectors(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
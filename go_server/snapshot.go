@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ── GET /snapshot · POST /restore ─────────────────────────────────────────────
+// When the OS kills the app it often kills the server with it. GET /snapshot
+// answers the engine's state as one opaque string the app keeps in its saved
+// instance state: every profile's policies and every torrent session, primary
+// and background, with its file, playback position, streaming options and
+// connected peers. POST /restore with that string after a restart brings it
+// all back: settings are written, sessions are added again at their position
+// and dial the peers they had, and the info cache (infocache.go) lets them
+// skip the metadata exchange. A session that already holds the same torrent
+// is left as it is, so restoring into a server that survived does nothing.
+// Local files and video URLs aren't in the snapshot; the app adds them again
+// itself. The string is gzipped JSON in URL-safe base64, sealed like state
+// files when a key is configured (secrets.go).
+
+const (
+	snapshotFormat  = 1
+	snapshotMaxBody = 4 << 20
+)
+
+// engineSnapshot is what the blob holds.
+type engineSnapshot struct {
+	Format        int               `json:"format"`
+	ServerVersion string            `json:"server_version"`
+	Taken         time.Time         `json:"taken"`
+	Profiles      []profileSnapshot `json:"profiles"`
+}
+
+type profileSnapshot struct {
+	ID       string            `json:"id"`
+	Settings profileSettings   `json:"settings"`
+	Sessions []sessionSnapshot `json:"sessions,omitempty"`
+}
+
+// sessionSnapshot is a session as the add that brings it back.
+type sessionSnapshot struct {
+	Primary     bool       `json:"primary,omitempty"`
+	Add         addRequest `json:"add"`
+	PositionSec float64    `json:"position_sec,omitempty"`
+}
+
+// snapshotBlob is the body of GET /snapshot and POST /restore.
+type snapshotBlob struct {
+	Snapshot string    `json:"snapshot"`           // opaque
+	Taken    time.Time `json:"taken,omitempty"`    // answers only
+	Sessions int       `json:"sessions,omitempty"` // answers only
+}
+
+type restoredSession struct {
+	Profile  string `json:"profile"`
+	InfoHash string `json:"info_hash"`
+	Primary  bool   `json:"primary,omitempty"`
+	Status   string `json:"status"` // as /add: "loading" | "exists"
+	AddID    uint64 `json:"add_id,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+func handleSnapshot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "GET only", 405)
+		return
+	}
+	snap := takeSnapshot()
+	blob, err := encodeSnapshot(snap)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	n := 0
+	for _, p := range snap.Profiles {
+		n += len(p.Sessions)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(snapshotBlob{Snapshot: blob, Taken: snap.Taken, Sessions: n})
+}
+
+func handleRestore(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST only", 405)
+		return
+	}
+	var in snapshotBlob
+	if err := json.NewDecoder(io.LimitReader(r.Body, snapshotMaxBody)).Decode(&in); err != nil {
+		http.Error(w, "invalid JSON body: "+err.Error(), 400)
+		return
+	}
+	snap, err := decodeSnapshot(in.Snapshot)
+	if err != nil {
+		http.Error(w, "invalid snapshot: "+err.Error(), 400)
+		return
+	}
+	out, err := restoreSnapshot(snap, requestID(r))
+	if errors.Is(err, errProfileLimit) {
+		http.Error(w, err.Error(), 400)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+// takeSnapshot reads the state of every loaded profile.
+func takeSnapshot() engineSnapshot {
+	snap := engineSnapshot{Format: snapshotFormat, ServerVersion: version, Taken: time.Now(), Profiles: []profileSnapshot{}}
+	profilesMu.Lock()
+	ps := make([]*profile, 0, len(profiles))
+	for _, p := range profiles {
+		ps = append(ps, p)
+	}
+	profilesMu.Unlock()
+	sort.Slice(ps, func(i, j int) bool { return ps[i].ID < ps[j].ID })
+	for _, p := range ps {
+		p.mu.Lock()
+		e := profileSnapshot{ID: p.ID, Settings: p.settings}
+		p.mu.Unlock()
+		for _, s := range p.sessions() {
+			if ss, ok := s.snapshot(); ok {
+				ss.Primary = s == p.sess
+				e.Sessions = append(e.Sessions, ss)
+			}
+		}
+		snap.Profiles = append(snap.Profiles, e)
+	}
+	return snap
+}
+
+// snapshot is the add that brings s back where it is; false when it holds
+// no torrent.
+func (s *session) snapshot() (sessionSnapshot, bool) {
+	s.mu.RLock()
+	t, f, pos := s.torr, s.file, s.status.PositionSec
+	s.mu.RUnlock()
+	s.addMu.Lock()
+	c := s.addLatest
+	s.addMu.Unlock()
+	if t == nil || c == nil {
+		return sessionSnapshot{}, false
+	}
+	opts := c.opts
+	req := addRequest{Magnet: magnetOf(t), Title: opts.Title, Episode: opts.Episode,
+		Labels: opts.Labels, Policy: opts.Policy, HeadBytes: opts.Prio.HeadBytes, TailBytes: opts.Prio.TailBytes}
+	if f != nil {
+		req.File = f.DisplayPath()
+	}
+	if opts.Prio.Bulk {
+		sequential := false
+		req.Sequential = &sequential
+	}
+	s.playerMu.Lock()
+	req.Paused = s.trickleOn || s.addPaused
+	s.playerMu.Unlock()
+	for _, pc := range t.PeerConns() {
+		req.Peers = append(req.Peers, pc.RemoteAddr.String())
+	}
+	return sessionSnapshot{Add: req, PositionSec: pos}, true
+}
+
+// restoreSnapshot writes back the profiles' settings and adds their
+// sessions again. Nothing is written unless every profile fits under
+// maxProfiles.
+func restoreSnapshot(snap engineSnapshot, requestID string) ([]restoredSession, error) {
+	out := []restoredSession{}
+	ids := make([]string, 0, len(snap.Profiles))
+	for _, ps := range snap.Profiles {
+		ids = append(ids, ps.ID)
+	}
+	if !profilesRoom(ids) {
+		return out, errProfileLimit
+	}
+	for _, ps := range snap.Profiles {
+		p := getProfile(ps.ID)
+		p.mu.Lock()
+		p.settings = ps.Settings
+		err := saveJSONAt(filepath.Join(p.dir, settingsFile), p.settings)
+		p.mu.Unlock()
+		if err != nil {
+			return out, fmt.Errorf("profile %s: %v", p.ID, err)
+		}
+		for _, ss := range ps.Sessions {
+			out = append(out, p.restoreSession(ss, requestID))
+		}
+	}
+	return out, nil
+}
+
+// restoreSession adds ss again in p, as the primary session or in the
+// background.
+func (p *profile) restoreSession(ss sessionSnapshot, requestID string) restoredSession {
+	req := ss.Add
+	req.ResumeAt = ss.PositionSec
+	res := restoredSession{Profile: p.ID, Primary: ss.Primary}
+	hash, err := req.infoHash()
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	res.InfoHash = hash
+	if ss.Primary {
+		if st := p.sess.snapshotStatus(); st.InfoHash == hash && st.State != "error" {
+			res.AddID, res.Status = st.AddID, "exists"
+			return res
+		}
+		res.AddID, res.Status = p.sess.startOnce(req.options(requestID), p.sess.requestAdd(req, nil))
+		return res
+	}
+	_, res.AddID, res.Status, err = p.startBackground(req, nil, requestID)
+	if err != nil {
+		res.Error = err.Error()
+	}
+	return res
+}
+
+// validate checks what restoring snap takes on trust.
+func (snap engineSnapshot) validate() error {
+	if snap.Format != snapshotFormat {
+		return fmt.Errorf("unsupported snapshot format %d", snap.Format)
+	}
+	for _, ps := range snap.Profiles {
+		if !profileIDRe.MatchString(ps.ID) {
+			return fmt.Errorf("invalid profile id %q", ps.ID)
+		}
+		if err := ps.Settings.validate(); err != nil {
+			return fmt.Errorf("profile %s: %v", ps.ID, err)
+		}
+	}
+	return nil
+}
+
+func encodeSnapshot(snap engineSnapshot) (string, error) {
+	js, err := json.Marshal(snap)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	zw, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	_, _ = zw.Write(js)
+	if err := zw.Close(); err != nil {
+		return "", err
+	}
+	b := buf.Bytes()
+	if sealKey != nil {
+		if b, err = seal(b); err != nil {
+			return "", err
+		}
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func decodeSnapshot(s string) (engineSnapshot, error) {
+	var snap engineSnapshot
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(strings.TrimSpace(s), "="))
+	if err != nil {
+		return snap, err
+	}
+	if isSealed(b) {
+		if b, err = unseal(b); err != nil {
+			return snap, err
+		}
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return snap, err
+	}
+	if err := json.NewDecoder(io.LimitReader(zr, 64<<20)).Decode(&snap); err != nil {
+		return snap, err
+	}
+	return snap, snap.validate()
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+const maxTorrentFileSize = 10 << 20 // 10 MB is far beyond any sane .torrent
+
+var fetchClient = &http.Client{Timeout: 30 * time.Second}
+
+// addByURI adds a torrent from either a magnet link or an http(s) URL
+// pointing at a .torrent file.
+func addByURI(uri string) (*torrent.Torrent, error) {
+	switch {
+	case strings.HasPrefix(uri, "magnet:"):
+		spec, err := torrent.TorrentSpecFromMagnetUri(uri)
+		if err != nil {
+			return nil, err
+		}
+		return addSpec(spec)
+	case strings.HasPrefix(uri, "http://"), strings.HasPrefix(uri, "https://"):
+		mi, err := fetchMetainfo(uri, fetchAuth{})
+		if err != nil {
+			return nil, err
+		}
+		spec, err := torrent.TorrentSpecFromMetaInfoErr(mi)
+		if err != nil {
+			return nil, err
+		}
+		return addSpec(spec)
+	}
+	return nil, fmt.Errorf("unsupported torrent URI %q", uri)
+}
+
+// fetchMetainfo downloads and parses a remote .torrent file.
+func fetchMetainfo(url string, auth fetchAuth) (*metainfo.MetaInfo, error) {
+	req, err := newFetchRequest(context.Background(), url, auth)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := fetchClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch %s: %s", url, resp.Status)
+	}
+	mi, err := metainfo.Load(io.LimitReader(resp.Body, maxTorrentFileSize))
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", url, err)
+	}
+	return mi, nil
+}
+
+// startDownload adds uri as a background download: every file is fetched
+// in full, nothing is prioritised for streaming and the active /stream
+// session is left alone. onDone (optional) runs once all data is verified.
+func startDownload(uri string, onDone func(t *torrent.Torrent)) (*torrent.Torrent, error) {
+	t, err := addByURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	runDownload(t, onDone)
+	return t, nil
+}
+
+var (
+	downloadsMu sync.Mutex
+	downloads   = map[*torrent.Torrent]func(*torrent.Torrent){} // running, with their onDone (heal.go)
+)
+
+// runDownload fetches all of t in the background.
+func runDownload(t *torrent.Torrent, onDone func(t *torrent.Torrent)) {
+	downloadsMu.Lock()
+	downloads[t] = onDone
+	downloadsMu.Unlock()
+	seedCachedPeers(t)
+	go peerCacheLoop(t)
+	go func() {
+		defer func() {
+			downloadsMu.Lock()
+			delete(downloads, t)
+			downloadsMu.Unlock()
+		}()
+		select {
+		case <-t.GotInfo():
+		case <-t.Closed():
+			return
+		}
+		log.Printf("Background download started: %s", t.Name())
+		t.DownloadAll()
+		for t.BytesMissing() > 0 {
+			select {
+			case <-t.Closed():
+				return
+			case <-time.After(10 * time.Second):
+			}
+		}
+		log.Printf("Background download complete: %s", t.Name())
+		if onDone != nil {
+			onDone(t)
+		}
+	}()
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent"
+
+	"github.com/roxbox/torrent_server/engine"
+)
+
+// ── Re-adding stalled torrents ────────────────────────────────────────────────
+// A torrent can sit at zero progress with a healthy client: its trackers
+// moved, the peers it found went away and the announce intervals are long.
+// With -readd-stalled (env ROXBOX_READD_STALLED; a duration, 0 turns it
+// off) a session that wants data and has gained none for that long has its
+// torrent dropped and added again: the same file and position, its known
+// peers, and the torrent's trackers together with the extra trackers
+// re-read from the list file and trackers.json (extratrackers.go), so it
+// announces afresh everywhere. The pieces on disk stay, and the session's
+// event log says why it was re-added. Self-healing (heal.go) covers the
+// case where the whole client is at fault.
+
+const (
+	defaultReaddStalled = 10 * time.Minute
+	stallCheckEvery     = 30 * time.Second
+)
+
+var readdStalledFlag = flag.Duration("readd-stalled", defaultReaddStalled, "drop and re-add a torrent with refreshed trackers when its session has downloaded nothing this long; 0 turns it off (env ROXBOX_READD_STALLED)")
+
+var stalls struct {
+	sync.Mutex
+	after time.Duration
+	seen  map[*session]stallMark
+}
+
+// stallMark is a session's torrent, its progress and when that last changed.
+type stallMark struct {
+	t     *torrent.Torrent
+	bytes int64
+	since time.Time
+}
+
+// startStallWatch reads -readd-stalled and starts watching the sessions.
+func startStallWatch() {
+	after, given := *readdStalledFlag, false
+	flag.Visit(func(f *flag.Flag) { given = given || f.Name == "readd-stalled" })
+	if v := os.Getenv("ROXBOX_READD_STALLED"); v != "" && !given {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			log.Printf("readd-stalled: ROXBOX_READD_STALLED: %v", err)
+		} else {
+			after = d
+		}
+	}
+	if after <= 0 {
+		return
+	}
+	stalls.after = after
+	stalls.seen = map[*session]stallMark{}
+	go stallLoop()
+}
+
+func stallLoop() {
+	defer guard()
+	tick := time.NewTicker(stallCheckEvery)
+	defer tick.Stop()
+	for range tick.C {
+		for _, s := range stalledSessions() {
+			readdStalled(s)
+		}
+	}
+}
+
+// stalledSessions are the sessions due for a re-add.
+func stalledSessions() []*session {
+	now := time.Now()
+	stalls.Lock()
+	defer stalls.Unlock()
+	live := map[*session]bool{}
+	dueT := map[*torrent.Torrent]bool{} // sessions sharing a torrent re-add it once
+	var due []*session
+	for _, e := range rankSessions() {
+		if e.state != "downloading" && e.state != "metadata" || !e.s.wantsData(e.t) {
+			continue
+		}
+		live[e.s] = true
+		bytes := e.t.BytesCompleted()
+		m, ok := stalls.seen[e.s]
+		if !ok || m.t != e.t || m.bytes != bytes {
+			stalls.seen[e.s] = stallMark{t: e.t, bytes: bytes, since: now}
+			continue
+		}
+		if now.Sub(m.since) >= stalls.after && !dueT[e.t] {
+			dueT[e.t] = true
+			due = append(due, e.s)
+			delete(stalls.seen, e.s)
+		}
+	}
+	for s := range stalls.seen {
+		if !live[s] {
+			delete(stalls.seen, s)
+		}
+	}
+	return due
+}
+
+// wantsData reports whether t has pieces the session is waiting for: the
+// metadata, or a missing piece it hasn't deprioritised. A paused player
+// (trickle mode) or the data saver holding the download back is no stall.
+func (s *session) wantsData(t *torrent.Torrent) bool {
+	if t.Info() == nil {
+		return true
+	}
+	if s.trickling() || s.profile.dataSaverSecs() > 0 {
+		return false
+	}
+	for i := 0; i < t.NumPieces(); i++ {
+		if ps := t.PieceState(i); !ps.Complete && ps.Priority != torrent.PiecePriorityNone {
+			return true
+		}
+	}
+	return false
+}
+
+// readdStalled drops s's torrent and adds it again with fresh trackers.
+func readdStalled(s *session) {
+	s.mu.RLock()
+	t, f, pos := s.torr, s.file, s.status.PositionSec
+	s.mu.RUnlock()
+	s.addMu.Lock()
+	c := s.addLatest
+	s.addMu.Unlock()
+	if t == nil || c == nil || c.superseded() {
+		return
+	}
+	opts := c.opts
+	if f != nil {
+		opts.File, opts.FileIndex = f.DisplayPath(), nil
+	}
+	opts.ResumeAt, opts.Paused, opts.RequestID, opts.Fallbacks = pos, false, "", nil
+	r := readdOf(t)     // heal.go
+	loadExtraTrackers() // addSpec adds them, new lines of the list file included
+	urls := map[string]bool{}
+	tiers := r.spec.Trackers
+	if !engine.IsPrivate(t) {
+		tiers = append(append([][]string{}, tiers...), extraTrackerTiers()...)
+	}
+	for _, tier := range tiers {
+		for _, u := range tier {
+			urls[u] = true
+		}
+	}
+	trackers := len(urls)
+
+	why := fmt.Sprintf("no progress for %v", stalls.after)
+	log.Printf("readd-stalled: %s: %s: re-adding with %d trackers", t.Name(), why, trackers)
+	go s.start(opts, func() (*torrent.Torrent, error) {
+		t, err := s.profile.addSpec(r.spec)
+		if err != nil {
+			return nil, err
+		}
+		t.AddPeers(r.peers)
+		return t, nil
+	})
+	s.event("", fmt.Sprintf("re-added: %s; announcing to %d trackers", why, trackers))
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// ── Status deltas ─────────────────────────────────────────────────────────────
+// Each status change gets a sequence number, returned in the X-Status-Seq
+// header. GET /status?changed_since=<seq> then returns only the top-level
+// fields that changed after seq:
+//
+//	{"seq": 1792210731533, "changed": {"progress": 12.5, "peers": 9}, "removed": ["error"]}
+//
+// Sequences start from the session's creation time in milliseconds, so a
+// seq from before a server restart is always older than anything current
+// and gets every field back ("full": true when nothing can be assumed).
+
+type statusDelta struct {
+	Seq     uint64                     `json:"seq"`
+	Full    bool                       `json:"full,omitempty"`
+	Changed map[string]json.RawMessage `json:"changed"`
+	Removed []string                   `json:"removed,omitempty"`
+}
+
+// recordFields diffs the status against the last recorded one and stamps
+// changed fields with a new seq. Caller holds s.mu.
+func (s *session) recordFields() {
+	var cur map[string]json.RawMessage
+	b, _ := json.Marshal(s.status)
+	if json.Unmarshal(b, &cur) != nil {
+		return
+	}
+	if s.fieldSeq == nil {
+		s.seqBase = uint64(time.Now().UnixMilli())
+		s.seq = s.seqBase
+		s.fieldSeq = map[string]uint64{}
+		for k := range cur {
+			s.fieldSeq[k] = s.seq
+		}
+		s.lastFields = cur
+		return
+	}
+	next := s.seq + 1
+	changed := false
+	for k, v := range cur {
+		if old, ok := s.lastFields[k]; !ok || !bytes.Equal(old, v) {
+			s.fieldSeq[k], changed = next, true
+		}
+	}
+	for k := range s.lastFields {
+		if _, ok := cur[k]; !ok {
+			s.fieldSeq[k], changed = next, true
+		}
+	}
+	if changed {
+		s.seq = next
+	}
+	s.lastFields = cur
+}
+
+// statusSince returns the changes after seq.
+func (s *session) statusSince(seq uint64) statusDelta {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fieldSeq == nil {
+		s.recordFields()
+	}
+	d := statusDelta{Seq: s.seq, Changed: map[string]json.RawMessage{}}
+	if seq < s.seqBase || seq > s.seq {
+		d.Full = true // other process, or from the future
+		seq = 0
+	}
+	for k, at := range s.fieldSeq {
+		if at <= seq {
+			continue
+		}
+		if v, ok := s.lastFields[k]; ok {
+			d.Changed[k] = v
+		} else if !d.Full {
+			d.Removed = append(d.Removed, k)
+		}
+	}
+	sort.Strings(d.Removed)
+	return d
+}
+
+// statusSeq is the current sequence number.
+func (s *session) statusSeq() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fieldSeq == nil {
+		s.recordFields()
+	}
+	return s.seq
+}
+
+// writeStatus answers /status: the full struct, or a delta with
+// changed_since.
+func writeStatus(w http.ResponseWriter, r *http.Request, sess *session) {
+	sess.active() // a poll keeps the session alive (idle.go)
+	w.Header().Set("Content-Type", "application/json")
+	if v := r.URL.Query().Get("changed_since"); v != "" {
+		since, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			http.Error(w, "changed_since must be a sequence number", 400)
+			return
+		}
+		d := sess.statusSince(since)
+		w.Header().Set("X-Status-Seq", strconv.FormatUint(d.Seq, 10))
+		_ = json.NewEncoder(w).Encode(d)
+		return
+	}
+	seq := sess.statusSeq()
+	st := sess.snapshotStatus()
+	w.Header().Set("X-Status-Seq", strconv.FormatUint(seq, 10))
+	_ = json.NewEncoder(w).Encode(st)
+}
+
+// ── GET /status/ws[?changed_since=<seq>] ──────────────────────────────────────
+// WebSocket push of the same deltas: the first message brings the client up
+// to date (full, unless changed_since is still valid), then one message per
+// change. Clients reconnect with the last seq they saw.
+func handleStatusWS(w http.ResponseWriter, r *http.Request) {
+	sess := sessionFor(w, r)
+	if sess == nil {
+		return
+	}
+	since, _ := strconv.ParseUint(r.URL.Query().Get("changed_since"), 10, 64)
+	c := wsUpgrade(w, r)
+	if c == nil {
+		return
+	}
+	defer c.Close()
+	keepalive := time.NewTicker(30 * time.Second)
+	defer keepalive.Stop()
+	for first := true; ; first = false {
+		sess.active() // an open socket keeps the session alive
+		_, changed := sess.statusWatch()
+		d := sess.statusSince(since)
+		if first || d.Full || len(d.Changed) > 0 || len(d.Removed) > 0 {
+			b, _ := json.Marshal(d)
+			if c.WriteText(b) != nil {
+				return
+			}
+		}
+		since = d.Seq
+		select {
+		case <-changed:
+		case <-keepalive.C:
+			if c.writeFrame(wsPing, nil) != nil {
+				return
+			}
+		case <-c.Closed():
+			return
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ── Status change notification ────────────────────────────────────────────────
+// Every write to a session's status goes through touch, which stamps the
+// changed fields for deltas and wakes anyone long-polling /status/wait.
+
+const (
+	defaultStatusWait = 30 * time.Second
+	maxStatusWait     = 2 * time.Minute
+)
+
+// touch marks the status as changed. Caller holds s.mu.
+func (s *session) touch() {
+	s.recordFields()
+	if s.statusCh != nil {
+		close(s.statusCh)
+	}
+	s.statusCh = make(chan struct{})
+}
+
+// statusWatch returns the current status and a channel closed on the next
+// change.
+func (s *session) statusWatch() (StatusResponse, <-chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.statusCh == nil {
+		s.statusCh = make(chan struct{})
+	}
+	return s.status, s.statusCh
+}
+
+// statusCond is the predicate of a /status/wait call; zero fields match
+// anything.
+type statusCond struct {
+	States      []string
+	MinProgress float64
+	MinPeers    int
+}
+
+func parseStatusCond(q map[string][]string) (statusCond, error) {
+	var c statusCond
+	get := func(k string) string {
+		if v := q[k]; len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	}
+	if s := get("state"); s != "" {
+		c.States = strings.Split(s, ",")
+	}
+	var err error
+	if s := get("min_progress"); s != "" {
+		if c.MinProgress, err = strconv.ParseFloat(s, 64); err != nil {
+			return c, err
+		}
+	}
+	if s := get("min_peers"); s != "" {
+		if c.MinPeers, err = strconv.Atoi(s); err != nil {
+			return c, err
+		}
+	}
+	return c, nil
+}
+
+func (c statusCond) met(st StatusResponse) bool {
+	if len(c.States) > 0 {
+		ok := false
+		for _, s := range c.States {
+			ok = ok || s == st.State
+		}
+		if !ok {
+			return false
+		}
+	}
+	return st.Progress >= c.MinProgress && st.Peers >= c.MinPeers
+}
+
+// ── GET /status/wait?state=ready&timeout=30s ──────────────────────────────────
+// Long-polls until the status matches (state is a comma list; also
+// min_progress and min_peers) or the timeout passes. The body is the
+// status either way; X-Condition-Met says which. An error state ends the
+// wait early unless it was asked for, since nothing will change after it.
+func handleStatusWait(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "GET only", 405)
+		return
+	}
+	sess := sessionFor(w, r)
+	if sess == nil {
+		return
+	}
+	sess.active()
+	q := r.URL.Query()
+	cond, err := parseStatusCond(q)
+	if err != nil {
+		http.Error(w, "bad condition: "+err.Error(), 400)
+		return
+	}
+	timeout := defaultStatusWait
+	if s := q.Get("timeout"); s != "" {
+		if timeout, err = time.ParseDuration(s); err != nil {
+			secs, err2 := strconv.ParseFloat(s, 64) // bare number: seconds
+			if err2 != nil {
+				http.Error(w, "bad timeout", 400)
+				return
+			}
+			timeout = time.Duration(secs * float64(time.Second))
+		}
+	}
+	timeout = min(max(timeout, 0), maxStatusWait)
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	st, changed := sess.statusWatch()
+	met := cond.met(st)
+wait:
+	for !met && st.State != "error" {
+		select {
+		case <-changed:
+		case <-deadline.C:
+			break wait
+		case <-r.Context().Done():
+			return
+		}
+		st, changed = sess.statusWatch()
+		met = cond.met(st)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Condition-Met", strconv.FormatBool(met))
+	_ = json.NewEncoder(w).Encode(st)
+}
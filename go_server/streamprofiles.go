@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/roxbox/torrent_server/engine"
+)
+
+// ── Player streaming profiles ─────────────────────────────────────────────────
+// Players read a stream differently (see /debug/rangestats), so /stream
+// tunes itself per player: how far ahead to download, how much of the file
+// end to fetch up front, and whether to send the response headers before
+// any data is there. The profile comes from ?player= (?profile= already
+// picks the user profile) or else from the User-Agent; unknown players get
+// the torrent's own defaults.
+
+type streamProfile struct {
+	Name         string `json:"name"`
+	Readahead    int64  `json:"readahead"`     // bytes; 0 keeps the torrent's default
+	TailPrefetch int64  `json:"tail_prefetch"` // bytes at the end of the file fetched first
+	NoWait       bool   `json:"nowait"`        // headers at once, for players with short header timeouts
+
+	agents []string // lower-case User-Agent substrings
+}
+
+var streamProfiles = []streamProfile{
+	// mpv waits patiently and reads the index at the end only on demand.
+	{Name: "mpv", Readahead: 16 << 20, TailPrefetch: 4 << 20, agents: []string{"mpv", "media_kit", "media-kit"}},
+	// ExoPlayer/Media3 gives up on a connection after 8 s without headers.
+	{Name: "exoplayer", Readahead: 8 << 20, TailPrefetch: 2 << 20, NoWait: true, agents: []string{"exoplayer", "androidxmedia3", "media3"}},
+	// VLC keeps its own large cache and probes the end before playing.
+	{Name: "vlc", Readahead: 32 << 20, TailPrefetch: 8 << 20, NoWait: true, agents: []string{"vlc"}},
+	// Browsers read the end of an MP4 for its moov box right after opening.
+	{Name: "browser", Readahead: 8 << 20, TailPrefetch: 8 << 20, NoWait: true, agents: []string{"mozilla"}},
+}
+
+var defaultStreamProfile = streamProfile{Name: "default"}
+
+// streamProfileFor picks the profile for a /stream request.
+func streamProfileFor(r *http.Request) streamProfile {
+	if name := strings.ToLower(r.URL.Query().Get("player")); name != "" {
+		for _, p := range streamProfiles {
+			if p.Name == name {
+				return p
+			}
+		}
+		return defaultStreamProfile
+	}
+	ua := strings.ToLower(r.UserAgent())
+	for _, p := range streamProfiles {
+		for _, a := range p.agents {
+			if strings.Contains(ua, a) {
+				return p
+			}
+		}
+	}
+	return defaultStreamProfile
+}
+
+// readahead is the reader readahead under p; never below two pieces.
+func (p streamProfile) readahead(pieces engine.PieceProfile) int64 {
+	if p.Readahead == 0 {
+		return pieces.Readahead
+	}
+	return max(p.Readahead, 2*pieces.Length)
+}
+
+// headerFlusher sends the response headers as soon as they're written.
+type headerFlusher struct {
+	http.ResponseWriter
+}
+
+func (w headerFlusher) WriteHeader(code int) {
+	w.ResponseWriter.WriteHeader(code)
+	w.Flush()
+}
+
+func (w headerFlusher) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// ── GET /stream/profiles ──────────────────────────────────────────────────────
+type streamProfilesResponse struct {
+	Profiles []streamProfile `json:"profiles"`
+	Detected string          `json:"detected"` // what this request would get
+}
+
+func handleStreamProfiles(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "GET only", 405)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(streamProfilesResponse{
+		Profiles: append(append([]streamProfile{}, streamProfiles...), defaultStreamProfile),
+		Detected: streamProfileFor(r).Name,
+	})
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anacrolix/torrent"
+
+	"github.com/roxbox/torrent_server/engine"
+)
+
+// ── GET /subtitles · GET /subtitles/{index} ───────────────────────────────────
+// The subtitle files of the session's torrent, for a player to load beside
+// /stream: every .srt, .ass, .ssa, .vtt, .sub and .idx, not only the ones
+// paired with the streamed video (those come first, marked companion).
+// /subtitles/{index} serves one, by its /files index, as UTF-8 text/plain
+// whatever it was saved in (engine.DecodeText); X-Roxbox-Charset names the
+// encoding it came in. The bitmap .sub of a VobSub pair is served as it is.
+// /subtitles/{index}.vtt serves a .srt or .vtt as WebVTT, converted on the
+// fly (engine.SRTToVTT), for players that take nothing else. Tracks inside
+// a Matroska video are under /subtitles/embedded (embeddedsubs.go).
+// A file still downloading is waited for. A local video lists the subtitle
+// companions beside it, by their /files index.
+
+const maxSubtitleBytes = 16 << 20
+
+type subtitleEntry struct {
+	Index     int     `json:"index"` // as /files; /subtitles/{index} serves it
+	Path      string  `json:"path"`
+	Size      int64   `json:"size"`
+	Format    string  `json:"format"` // the extension: srt, ass, ssa, vtt, sub, idx
+	Lang      string  `json:"lang,omitempty"`
+	Companion bool    `json:"companion"` // paired with the streamed video
+	Progress  float64 `json:"progress"`  // % on disk
+	URL       string  `json:"url"`
+	VTTURL    string  `json:"vtt_url,omitempty"` // as WebVTT, for .srt and .vtt
+}
+
+type subtitlesResponse struct {
+	Subtitles []subtitleEntry `json:"subtitles"`
+}
+
+// subtitleURL is the URL of /subtitles/{rel} for s.
+func (s *session) subtitleURL(rel string) string {
+	var q string
+	if s.profile.ID != defaultProfile {
+		q = "?" + url.Values{"profile": {s.profile.ID}}.Encode()
+	}
+	if s.hash != "" {
+		return "http://" + advertiseHost() + ":" + port + "/torrents/" + s.hash + "/subtitles/" + rel + q
+	}
+	return "http://" + advertiseHost() + ":" + port + "/subtitles/" + rel + q
+}
+
+// withVTT fills in e's WebVTT URL when it converts.
+func (e subtitleEntry) withVTT(s *session) subtitleEntry {
+	if convertsToVTT(e.Format) {
+		e.VTTURL = s.subtitleURL(strconv.Itoa(e.Index) + ".vtt")
+	}
+	return e
+}
+
+func convertsToVTT(format string) bool { return format == "srt" || format == "vtt" }
+
+func handleSubtitles(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "GET only", 405)
+		return
+	}
+	sess := sessionFor(w, r)
+	if sess == nil {
+		return
+	}
+	if rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/subtitles"), "/"); rest != "" {
+		serveSubtitle(w, r, sess, rest)
+		return
+	}
+	writeSubtitles(w, sess)
+}
+
+// writeSubtitles answers /subtitles for sess (/torrents/{hash}/subtitles too).
+func writeSubtitles(w http.ResponseWriter, sess *session) {
+	sess.mu.RLock()
+	t, comps, local := sess.torr, sess.companions, sess.local
+	sess.mu.RUnlock()
+	out := subtitlesResponse{Subtitles: []subtitleEntry{}}
+	switch {
+	case local != nil:
+		for i, c := range local.Companions {
+			if c.Kind != "subtitle" {
+				continue
+			}
+			e := subtitleEntry{Index: i, Path: c.Path, Format: subtitleFormat(c.Path), Lang: c.Lang, Companion: true, Progress: 100, URL: sess.subtitleURL(strconv.Itoa(i))}.withVTT(sess)
+			if fi, err := os.Stat(c.Path); err == nil {
+				e.Size = fi.Size()
+			}
+			out.Subtitles = append(out.Subtitles, e)
+		}
+	case t == nil || t.Info() == nil:
+		http.Error(w, "no torrent info yet", 503)
+		return
+	default:
+		paired := map[*torrent.File]string{}
+		for _, c := range comps {
+			if c.Kind == "subtitle" {
+				paired[c.File] = c.Lang
+			}
+		}
+		for i, f := range t.Files() {
+			p := f.DisplayPath()
+			if engine.FileType(p) != "subtitle" {
+				continue
+			}
+			lang, companion := paired[f]
+			if !companion {
+				lang = subtitleLang(p)
+			}
+			e := subtitleEntry{Index: i, Path: p, Size: f.Length(), Format: subtitleFormat(p), Lang: lang, Companion: companion, Progress: 100, URL: sess.subtitleURL(strconv.Itoa(i))}.withVTT(sess)
+			if f.Length() > 0 {
+				e.Progress = float64(f.BytesCompleted()) / float64(f.Length()) * 100
+			}
+			out.Subtitles = append(out.Subtitles, e)
+		}
+		sort.SliceStable(out.Subtitles, func(i, j int) bool {
+			a, b := out.Subtitles[i], out.Subtitles[j]
+			if a.Companion != b.Companion {
+				return a.Companion
+			}
+			return engine.NaturalLess(a.Path, b.Path)
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+// serveSubtitle answers /subtitles/{index}[.vtt] for sess (/torrents/{hash}/subtitles/{index} too).
+func serveSubtitle(w http.ResponseWriter, r *http.Request, sess *session, index string) {
+	if rest, ok := strings.CutPrefix(index, "embedded"); ok && (rest == "" || rest[0] == '/') {
+		serveEmbedded(w, r, sess, strings.TrimPrefix(rest, "/")) // embeddedsubs.go
+		return
+	}
+	index, vtt := strings.CutSuffix(index, ".vtt")
+	i, err := strconv.Atoi(index)
+	sess.mu.RLock()
+	t, local := sess.torr, sess.local
+	sess.mu.RUnlock()
+	var name, lang string
+	var b []byte
+	switch {
+	case local != nil:
+		if err != nil || i < 0 || i >= len(local.Companions) || local.Companions[i].Kind != "subtitle" {
+			http.Error(w, "no such subtitle", 404)
+			return
+		}
+		c := local.Companions[i]
+		if vtt && !convertsToVTT(subtitleFormat(c.Path)) {
+			http.Error(w, "only .srt and .vtt subtitles convert to WebVTT", 415)
+			return
+		}
+		if engine.IsVobSub(filepath.ToSlash(c.Path), localPaths(local)) {
+			serveLocal(w, r, c.Path)
+			return
+		}
+		name, lang = filepath.Base(c.Path), c.Lang
+		if b, err = readLocalSubtitle(c.Path); err != nil {
+			http.Error(w, err.Error(), subtitleErrCode(err))
+			return
+		}
+	case t == nil || t.Info() == nil:
+		http.Error(w, "no torrent info yet", 503)
+		return
+	default:
+		files := t.Files()
+		if err != nil || i < 0 || i >= len(files) || engine.FileType(files[i].DisplayPath()) != "subtitle" {
+			http.Error(w, "no such subtitle", 404)
+			return
+		}
+		f := files[i]
+		if vtt && !convertsToVTT(subtitleFormat(f.DisplayPath())) {
+			http.Error(w, "only .srt and .vtt subtitles convert to WebVTT", 415)
+			return
+		}
+		var paths []string
+		for _, g := range files {
+			paths = append(paths, g.DisplayPath())
+		}
+		if engine.IsVobSub(f.DisplayPath(), paths) {
+			reader := engine.NewReader(f, engine.DefaultReadahead)
+			defer reader.Close()
+			engine.ServeContent(w, r, f.DisplayPath(), reader)
+			return
+		}
+		name, lang = path.Base(f.DisplayPath()), sess.langOf(f)
+		if b, err = readTorrentSubtitle(r.Context(), f); err != nil {
+			http.Error(w, err.Error(), subtitleErrCode(err))
+			return
+		}
+	}
+	text, charset := engine.DecodeText(b, lang)
+	ctype := "text/plain; charset=utf-8"
+	if vtt {
+		if subtitleFormat(name) == "srt" {
+			text = engine.SRTToVTT(text)
+		}
+		name, ctype = strings.TrimSuffix(name, path.Ext(name))+".vtt", "text/vtt; charset=utf-8"
+	}
+	w.Header().Set("Content-Type", ctype)
+	w.Header().Set("X-Roxbox-Charset", charset)
+	w.Header().Set("Cache-Control", "no-cache")
+	http.ServeContent(w, r, name, time.Time{}, strings.NewReader(text))
+}
+
+var errSubtitleTooLarge = fmt.Errorf("subtitle file larger than %d MB", maxSubtitleBytes>>20)
+
+func subtitleErrCode(err error) int {
+	if err == errSubtitleTooLarge {
+		return 413
+	}
+	return 500
+}
+
+// readTorrentSubtitle reads f whole, waiting for its pieces until ctx is done.
+func readTorrentSubtitle(ctx context.Context, f *torrent.File) ([]byte, error) {
+	if f.Length() > maxSubtitleBytes {
+		return nil, errSubtitleTooLarge
+	}
+	reader := engine.NewReader(f, f.Length())
+	defer reader.Close()
+	b := make([]byte, f.Length())
+	_, err := io.ReadFull(ctxReader{ctx, reader}, b) // zipstream.go
+	return b, err
+}
+
+func readLocalSubtitle(p string) ([]byte, error) {
+	fi, err := os.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+	if fi.Size() > maxSubtitleBytes {
+		return nil, errSubtitleTooLarge
+	}
+	return os.ReadFile(p)
+}
+
+// localPaths are the slash-separated paths of a local video's companions.
+func localPaths(m *localMedia) []string {
+	var out []string
+	for _, c := range m.Companions {
+		out = append(out, filepath.ToSlash(c.Path))
+	}
+	return out
+}
+
+// langOf is the language of subtitle f: its tag as a companion of the
+// streamed video, or one read from its name.
+func (s *session) langOf(f *torrent.File) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, c := range s.companions {
+		if c.File == f {
+			return c.Lang
+		}
+	}
+	return subtitleLang(f.DisplayPath())
+}
+
+// subtitleLang reads a language tag from a subtitle's name: "Movie.en.srt"
+// gives "en", "Subs/English.srt" gives "English".
+func subtitleLang(p string) string {
+	dir, name := path.Split(p)
+	base := strings.TrimSuffix(name, path.Ext(name))
+	if i := strings.LastIndexByte(base, '.'); i >= 0 {
+		if tag := base[i+1:]; len(tag) >= 2 && len(tag) <= 3 && isLetters(tag) {
+			return tag
+		}
+		return ""
+	}
+	if engine.IsSubtitleDir(path.Base(strings.TrimSuffix(dir, "/"))) {
+		return strings.TrimLeft(base, "0123456789_ ")
+	}
+	return ""
+}
+
+func isLetters(s string) bool {
+	for _, c := range s {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+func subtitleFormat(p string) string {
+	return strings.TrimPrefix(strings.ToLower(path.Ext(filepath.ToSlash(p))), ".")
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/anacrolix/torrent"
+
+	"github.com/roxbox/torrent_server/engine"
+)
+
+// ── Swarm snapshots ───────────────────────────────────────────────────────────
+// A snapshot captures who we're connected to and which pieces they hold, so
+// another device adding the same torrent can dial those peers straight away
+// instead of waiting on trackers and the DHT (phone → TV handoff).
+
+type SwarmSnapshot struct {
+	InfoHash  string    `json:"info_hash"`
+	Magnet    string    `json:"magnet"`
+	Taken     time.Time `json:"taken"`
+	Peers     []string  `json:"peers"`
+	NumPieces int       `json:"num_pieces,omitempty"`
+	// Have is one character per piece, '1' where we hold the verified piece.
+	Have string `json:"have,omitempty"`
+	// Availability counts the connected peers holding each piece.
+	Availability []int `json:"availability,omitempty"`
+}
+
+func magnetOf(t *torrent.Torrent) string {
+	ih := t.InfoHash()
+	mi := t.Metainfo()
+	for _, tier := range mi.AnnounceList {
+		for i, tr := range tier {
+			tier[i] = publicTrackerURL(tr) // passkeys.go
+		}
+	}
+	return mi.Magnet(&ih, t.Info()).String()
+}
+
+func snapshotSwarm(t *torrent.Torrent) SwarmSnapshot {
+	snap := SwarmSnapshot{
+		InfoHash: t.InfoHash().HexString(),
+		Magnet:   magnetOf(t),
+		Taken:    time.Now(),
+	}
+	conns := t.PeerConns()
+	for _, pc := range conns {
+		snap.Peers = append(snap.Peers, pc.RemoteAddr.String())
+	}
+	if t.Info() == nil {
+		return snap
+	}
+	snap.NumPieces = t.NumPieces()
+	have := make([]byte, snap.NumPieces)
+	snap.Availability = make([]int, snap.NumPieces)
+	for i := range have {
+		have[i] = '0'
+		if t.PieceState(i).Complete {
+			have[i] = '1'
+		}
+	}
+	for _, pc := range conns {
+		pc.PeerPieces().Iterate(func(i uint32) bool {
+			if int(i) < snap.NumPieces {
+				snap.Availability[i]++
+			}
+			return true
+		})
+	}
+	snap.Have = string(have)
+	return snap
+}
+
+// swarmShapeEvery is how often, in statsLoop seconds, the peers are looked
+// over for seeds and super-seeders.
+const swarmShapeEvery = 5
+
+// swarmWarning explains a swarm that caps the download speed, or "".
+func swarmWarning(shape engine.SwarmShape) string {
+	switch {
+	case !shape.SingleSource:
+		return ""
+	case shape.SuperSeeders > 0:
+		return "single-seed swarm in super-seed mode: the seeder hands out one piece at a time, so expect slow, out-of-order progress"
+	default:
+		return "single-seed swarm: one peer has the data we lack, so speed is capped by its upload"
+	}
+}
+
+// swarmAvailability is the distributed copies of the torrent and of what
+// the playback windows still miss (nil when nothing is missing).
+type swarmAvailability struct {
+	swarm  float64
+	window *float64
+}
+
+func (s *session) availability(t *torrent.Torrent, f *torrent.File) swarmAvailability {
+	copies := engine.PieceCopies(t)
+	if copies == nil {
+		return swarmAvailability{}
+	}
+	a := swarmAvailability{swarm: engine.DistributedCopies(copies, nil)}
+	span := engine.PieceRange(f)
+	var missing []int
+	seen := map[int]bool{}
+	for _, w := range s.readerWindows(span) {
+		for i := max(w[0], span.Begin); i < min(w[1], span.End); i++ {
+			if !seen[i] && !t.PieceState(i).Complete {
+				seen[i] = true
+				missing = append(missing, i)
+			}
+		}
+	}
+	if len(missing) > 0 {
+		d := engine.DistributedCopies(copies, missing)
+		a.window = &d
+	}
+	return a
+}
+
+// decodeSwarm accepts a snapshot as raw JSON or base64-encoded JSON (the
+// latter survives being passed around as a form value or deep link).
+func decodeSwarm(s string) (*SwarmSnapshot, error) {
+	s = strings.TrimSpace(s)
+	b := []byte(s)
+	if !strings.HasPrefix(s, "{") {
+		var err error
+		if b, err = base64.StdEncoding.DecodeString(s); err != nil {
+			if b, err = base64.URLEncoding.DecodeString(s); err != nil {
+				return nil, fmt.Errorf("swarm: neither JSON nor base64")
+			}
+		}
+	}
+	var snap SwarmSnapshot
+	if err := json.Unmarshal(b, &snap); err != nil {
+		return nil, fmt.Errorf("swarm: %v", err)
+	}
+	return &snap, nil
+}
+
+// importSwarm warm-starts t with the peers from a snapshot of the same torrent.
+func importSwarm(t *torrent.Torrent, snap *SwarmSnapshot) {
+	if snap == nil || !strings.EqualFold(snap.InfoHash, t.InfoHash().HexString()) {
+		return
+	}
+	infos := make([]torrent.PeerInfo, 0, len(snap.Peers))
+	for _, p := range snap.Peers {
+		infos = append(infos, torrent.PeerInfo{Addr: torrent.StringAddr(p), Source: torrent.PeerSourceDirect})
+	}
+	n := t.AddPeers(infos)
+	log.Printf("swarm: imported %d peers from snapshot", n)
+}
+
+// ── GET /session/{id}/swarm/export ────────────────────────────────────────────
+// {id} is the infohash of the active session.
+func handleSession(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) != 4 || parts[2] != "swarm" || parts[3] != "export" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "GET only", 405)
+		return
+	}
+	sess := sessionFor(w, r)
+	if sess == nil {
+		return
+	}
+	t, _ := sess.current()
+	if t == nil || !strings.EqualFold(parts[1], t.InfoHash().HexString()) {
+		http.Error(w, "unknown session", 404)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(snapshotSwarm(t))
+}
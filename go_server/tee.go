@@ -0,0 +1,262 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent"
+)
+
+// ── Stream tee ────────────────────────────────────────────────────────────────
+// While enabled, every byte served on /stream is also written at the same
+// offset into an output file in the tee directory. Seeks leave holes that
+// are filled if the player later reads them, so a full watch leaves a
+// complete copy. The bytes go to <path>.part, renamed to path once complete;
+// neither may exist beforehand.
+
+var teeDirFlag = flag.String("tee-dir", "", "directory /tee writes into (default <cache>/tee)")
+
+type streamTee struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File // path + ".part" until done
+	size    int64
+	covered byteRanges
+	done    bool
+}
+
+// teePath resolves a /tee path, relative to the tee directory or absolute
+// inside it, and creates its directory.
+func teePath(p string) (string, error) {
+	dir := *teeDirFlag
+	if dir == "" {
+		dir = filepath.Join(cacheDir, "tee")
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(dir, p)
+	}
+	p = filepath.Clean(p)
+	if rel, err := filepath.Rel(dir, p); err != nil || rel == "." || !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("path must be a file in the tee directory %s", dir)
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return "", err
+	}
+	// A symlinked directory inside could still lead out.
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", err
+	}
+	realParent, err := filepath.EvalSymlinks(filepath.Dir(p))
+	if err != nil {
+		return "", err
+	}
+	if rel, err := filepath.Rel(realDir, realParent); err != nil || !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("path must be a file in the tee directory %s", dir)
+	}
+	return p, nil
+}
+
+func openTee(path string, size int64) (*streamTee, error) {
+	if _, err := os.Lstat(path); err == nil {
+		return nil, &fs.PathError{Op: "tee", Path: path, Err: fs.ErrExist}
+	}
+	f, err := os.OpenFile(path+".part", os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return nil, err
+	}
+	// Pre-size (sparse where supported) so out-of-order writes land in place.
+	if err := f.Truncate(size); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return &streamTee{path: path, file: f, size: size}, nil
+}
+
+func (t *streamTee) writeAt(p []byte, off int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.file == nil || t.done {
+		return
+	}
+	if _, err := t.file.WriteAt(p, off); err != nil {
+		log.Printf("tee: write %s: %v", t.path, err)
+		return
+	}
+	t.covered.Add(off, off+int64(len(p)))
+	if t.covered.Covered() >= t.size {
+		t.done = true
+		t.finish()
+	}
+}
+
+// finish moves the complete copy to its path. Caller holds t.mu.
+func (t *streamTee) finish() {
+	part := t.file.Name()
+	_ = t.file.Sync()
+	_ = t.file.Close() // Windows renames closed files only
+	t.file = nil
+	if _, err := os.Lstat(t.path); err == nil {
+		log.Printf("tee: %s complete, kept as %s: the name was taken meanwhile", t.path, part)
+		return
+	}
+	if err := os.Rename(part, t.path); err != nil {
+		log.Printf("tee: %s complete, left as %s: %v", t.path, part, err)
+		return
+	}
+	log.Printf("tee: %s complete", t.path)
+}
+
+func (t *streamTee) close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.file != nil {
+		_ = t.file.Close()
+		t.file = nil
+	}
+}
+
+type teeStatus struct {
+	Path     string `json:"path"`
+	Size     int64  `json:"size"`
+	Written  int64  `json:"written"`
+	Complete bool   `json:"complete"`
+}
+
+func (t *streamTee) status() teeStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return teeStatus{Path: t.path, Size: t.size, Written: t.covered.Covered(), Complete: t.done}
+}
+
+func (s *session) stopTee() {
+	s.mu.Lock()
+	t := s.tee
+	s.tee = nil
+	s.mu.Unlock()
+	if t != nil {
+		t.close()
+	}
+}
+
+func (s *session) activeTee() *streamTee {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.tee
+}
+
+// teeReader mirrors reads from the torrent reader into the active tee.
+// It also marks the "first_byte" startup milestone.
+type teeReader struct {
+	torrent.Reader
+	sess   *session
+	pos    int64
+	served bool
+	reqID  string // /stream request, for stall events
+}
+
+func (r *teeReader) Read(p []byte) (int, error) {
+	start := time.Now()
+	n, err := r.Reader.Read(p)
+	if d := time.Since(start); d >= stallThreshold && r.served {
+		telemetryStall(d) // waits before the first byte count as startup
+		recordEvent(event{Kind: "stall", RequestID: r.reqID, Profile: r.sess.profile.ID,
+			Message: "reader blocked", Data: map[string]any{"offset": r.pos, "ms": d.Milliseconds()}})
+	}
+	if n > 0 {
+		if !r.served {
+			r.served = true
+			r.sess.markTiming("first_byte")
+		}
+		if t := r.sess.activeTee(); t != nil {
+			t.writeAt(p[:n], r.pos)
+		}
+		r.pos += int64(n)
+	}
+	return n, err
+}
+
+func (r *teeReader) Seek(off int64, whence int) (int64, error) {
+	pos, err := r.Reader.Seek(off, whence)
+	if err == nil {
+		r.pos = pos
+	}
+	return pos, err
+}
+
+var _ io.ReadSeeker = (*teeReader)(nil)
+
+// ── GET|POST|DELETE /tee ──────────────────────────────────────────────────────
+//
+//	GET            → current tee status
+//	POST ?path=    → start teeing the active file into path in the tee directory
+//	DELETE         → stop teeing (the partial .part file is kept)
+func handleTee(w http.ResponseWriter, r *http.Request) {
+	sess := sessionFor(w, r)
+	if sess == nil {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		_ = r.ParseForm()
+		if r.FormValue("path") == "" {
+			http.Error(w, "path param required", 400)
+			return
+		}
+		path, err := teePath(r.FormValue("path"))
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		_, f := sess.current()
+		if f == nil {
+			http.Error(w, "no active torrent", 503)
+			return
+		}
+		t, err := openTee(path, f.Length())
+		if errors.Is(err, fs.ErrExist) {
+			http.Error(w, err.Error(), 409)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		sess.stopTee()
+		sess.mu.Lock()
+		sess.tee = t
+		sess.mu.Unlock()
+		log.Printf("tee: writing stream to %s", path)
+	case http.MethodDelete:
+		sess.stopTee()
+		w.WriteHeader(200)
+		fmt.Fprint(w, "stopped")
+		return
+	default:
+		http.Error(w, "GET, POST or DELETE only", 405)
+		return
+	}
+
+	t := sess.activeTee()
+	if t == nil {
+		http.Error(w, "no active tee", 404)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(t.status())
+}
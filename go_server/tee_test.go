@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTeePath(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(dir, "out")); err != nil {
+		t.Fatal(err)
+	}
+	saved := *teeDirFlag
+	*teeDirFlag = dir
+	defer func() { *teeDirFlag = saved }()
+
+	for _, tc := range []struct {
+		in   string
+		want string // "" for refused
+	}{
+		{"copy.mkv", filepath.Join(dir, "copy.mkv")},
+		{"show/copy.mkv", filepath.Join(dir, "show", "copy.mkv")},
+		{filepath.Join(dir, "copy.mkv"), filepath.Join(dir, "copy.mkv")},
+		{"../copy.mkv", ""},
+		{"show/../../copy.mkv", ""},
+		{filepath.Join(outside, "copy.mkv"), ""},
+		{"/etc/passwd", ""},
+		{".", ""},
+		{dir, ""},
+		{"out/copy.mkv", ""}, // through a symlink
+	} {
+		t.Run(tc.in, func(t *testing.T) {
+			got, err := teePath(tc.in)
+			if tc.want == "" {
+				if err == nil {
+					t.Errorf("got %s, want it refused", got)
+				}
+				return
+			}
+			if err != nil || got != tc.want {
+				t.Errorf("got %q, %v; want %q", got, err, tc.want)
+			}
+		})
+	}
+}
+
+func TestTeeWritesPartThenRenames(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "copy.mkv")
+	tee, err := openTee(path, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tee.close()
+	if _, err := openTee(path, 10); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("a second tee into the same path: %v, want it refused", err)
+	}
+	tee.writeAt([]byte("world"), 5)
+	if _, err := os.Stat(path); err == nil {
+		t.Errorf("%s exists before the copy is complete", path)
+	}
+	tee.writeAt([]byte("hello"), 0)
+	if b, err := os.ReadFile(path); err != nil || string(b) != "helloworld" {
+		t.Errorf("read %q, %v; want the complete copy", b, err)
+	}
+	if _, err := os.Stat(path + ".part"); err == nil {
+		t.Errorf("the .part file is left over")
+	}
+	if _, err := openTee(path, 10); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("a tee over a finished copy: %v, want it refused", err)
+	}
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ── Opt-in telemetry ──────────────────────────────────────────────────────────
+// Off unless the user enables it (PUT /telemetry {"enabled": true}). When on
+// we send two kinds of anonymous reports to the configured endpoint:
+//
+//   - crash: a panic's stack with directories, quoted strings, hashes and
+//     URLs stripped, uploaded on the next start (the process is gone by
+//     then);
+//   - qoe: hourly aggregates — sessions, time-to-first-byte percentiles and
+//     playback stalls.
+//
+// Reports carry a random install ID, the version and the OS/arch; never
+// torrent names, infohashes, addresses or paths. Disabling is a kill
+// switch: aggregates and any pending crash report are discarded.
+
+const (
+	telemetryFile     = "telemetry.json"
+	crashFile         = "crash.txt"
+	telemetryInterval = time.Hour
+	stallThreshold    = time.Second // a /stream read blocking this long is a stall
+)
+
+type telemetrySettings struct {
+	Enabled   bool   `json:"enabled"`
+	Endpoint  string `json:"endpoint,omitempty"`
+	InstallID string `json:"install_id,omitempty"`
+}
+
+type qoeReport struct {
+	InstallID string `json:"install_id"`
+	Version   string `json:"version"`
+	Platform  string `json:"platform"`
+	PeriodSec int64  `json:"period_sec"`
+	Sessions  int    `json:"sessions"`
+	TTFBP50Ms int64  `json:"ttfb_p50_ms,omitempty"`
+	TTFBP90Ms int64  `json:"ttfb_p90_ms,omitempty"`
+	Stalls    int    `json:"stalls"`
+	StallMs   int64  `json:"stall_ms"`
+}
+
+type crashReport struct {
+	InstallID string `json:"install_id"`
+	Version   string `json:"version"`
+	Platform  string `json:"platform"`
+	Stack     string `json:"stack"`
+}
+
+var (
+	telemetryMu  sync.Mutex
+	telemetryCfg telemetrySettings
+	qoeSince     = time.Now()
+	qoeTTFB      []int64
+	qoeStalls    int
+	qoeStallMs   int64
+)
+
+func loadTelemetry() {
+	telemetryMu.Lock()
+	if err := loadJSON(telemetryFile, &telemetryCfg); err != nil {
+		log.Printf("telemetry: load: %v", err)
+	}
+	if telemetryCfg.Endpoint == "" {
+		telemetryCfg.Endpoint = os.Getenv("ROXBOX_TELEMETRY_URL")
+	}
+	cfg := telemetryCfg
+	telemetryMu.Unlock()
+
+	if cfg.Enabled {
+		go sendPendingCrash()
+	} else {
+		_ = os.Remove(filepath.Join(cacheDir, crashFile))
+	}
+	go telemetryLoop()
+}
+
+func telemetryOn() bool {
+	telemetryMu.Lock()
+	defer telemetryMu.Unlock()
+	return telemetryCfg.Enabled && telemetryCfg.Endpoint != ""
+}
+
+// telemetryTTFB records one session's time to first byte.
+func telemetryTTFB(ms int64) {
+	if !telemetryOn() {
+		return
+	}
+	telemetryMu.Lock()
+	qoeTTFB = append(qoeTTFB, ms)
+	telemetryMu.Unlock()
+}
+
+// telemetryStall records a playback read that blocked for d.
+func telemetryStall(d time.Duration) {
+	if !telemetryOn() {
+		return
+	}
+	telemetryMu.Lock()
+	qoeStalls++
+	qoeStallMs += d.Milliseconds()
+	telemetryMu.Unlock()
+}
+
+func telemetryLoop() {
+	for range time.Tick(telemetryInterval) {
+		flushQoE()
+	}
+}
+
+// flushQoE uploads and resets the aggregates.
+func flushQoE() {
+	telemetryMu.Lock()
+	cfg := telemetryCfg
+	rep := qoeReport{
+		InstallID: cfg.InstallID,
+		Version:   version,
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+		PeriodSec: int64(time.Since(qoeSince).Seconds()),
+		Sessions:  len(qoeTTFB),
+		Stalls:    qoeStalls,
+		StallMs:   qoeStallMs,
+	}
+	if n := len(qoeTTFB); n > 0 {
+		sort.Slice(qoeTTFB, func(i, j int) bool { return qoeTTFB[i] < qoeTTFB[j] })
+		rep.TTFBP50Ms = qoeTTFB[n/2]
+		rep.TTFBP90Ms = qoeTTFB[n*9/10]
+	}
+	qoeSince, qoeTTFB, qoeStalls, qoeStallMs = time.Now(), nil, 0, 0
+	telemetryMu.Unlock()
+
+	if !cfg.Enabled || cfg.Endpoint == "" || (rep.Sessions == 0 && rep.Stalls == 0) {
+		return
+	}
+	if err := uploadTelemetry(cfg.Endpoint, "qoe", rep); err != nil {
+		log.Printf("telemetry: %v", err)
+	}
+}
+
+func uploadTelemetry(endpoint, kind string, payload any) error {
+	b, err := json.Marshal(map[string]any{"kind": kind, "report": payload})
+	if err != nil {
+		return err
+	}
+	resp, err := fetchClient.Post(endpoint, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("upload %s: HTTP %d", kind, resp.StatusCode)
+	}
+	return nil
+}
+
+// ── Crashes ───────────────────────────────────────────────────────────────────
+
+var (
+	stackDirRe  = regexp.MustCompile(`(?m)^(\s+)\S*/([^/\s]+\.go:\d+)`)
+	stackDataRe = regexp.MustCompile(`"[^"\n]*"|[a-zA-Z][a-zA-Z0-9+.-]*://\S+|\b[0-9a-fA-F]{32,}\b`)
+	// An unquoted absolute path, as in "open /home/<user>/…: …": up to the
+	// next colon or the line's end, since a file name may hold spaces.
+	stackPathRe = regexp.MustCompile(`(?m)(^|[\s=(\[,'])(?:~?/[^\s/:]|[A-Za-z]:[\\/])[^\n:]*`)
+	// Peer and tracker addresses, as in "dial tcp 1.2.3.4:6881": IPv4,
+	// IPv6 (bracketed, full or with ::) and host:port. A file:line such as
+	// "session.go:120" is a host:port to the pattern and is kept.
+	stackAddrRe = regexp.MustCompile(`\[[0-9A-Fa-f.]*:[0-9A-Fa-f:.]*\](?::\d+)?|\b(?:\d{1,3}\.){3}\d{1,3}(?::\d+)?\b|(?:[0-9A-Fa-f]{1,4}:){7}[0-9A-Fa-f]{1,4}\b|(?:[0-9A-Fa-f]{1,4}(?::[0-9A-Fa-f]{1,4})*)?::(?:[0-9A-Fa-f]{1,4}(?::[0-9A-Fa-f]{1,4})*)?|\b[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)+:\d+\b`)
+)
+
+// anonymizeStack drops everything that could identify the user or what
+// they were watching, keeping function names and file:line.
+func anonymizeStack(s string) string {
+	s = stackDirRe.ReplaceAllString(s, "$1$2")
+	s = stackDataRe.ReplaceAllString(s, "<redacted>")
+	s = stackAddrRe.ReplaceAllStringFunc(s, func(m string) string {
+		if i := strings.LastIndexByte(m, ':'); i > 0 && strings.HasSuffix(m[:i], ".go") {
+			return m
+		}
+		return "<redacted>"
+	})
+	return stackPathRe.ReplaceAllString(s, "$1<redacted>")
+}
+
+// guard is deferred at the top of long-lived goroutines: a panic is saved
+// for the next start's crash report, then re-raised.
+func guard() {
+	if v := recover(); v != nil {
+		saveCrash(v, debug.Stack())
+		panic(v)
+	}
+}
+
+// withRecover turns handler panics into a 500 and a crash report instead
+// of net/http's log line.
+func withRecover(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if v := recover(); v != nil {
+				if v == http.ErrAbortHandler {
+					panic(v)
+				}
+				stack := debug.Stack()
+				log.Printf("[%s] panic serving %s: %v\n%s", requestID(r), r.URL.Path, v, stack)
+				saveCrash(v, stack)
+				http.Error(w, "internal error", 500)
+			}
+		}()
+		h.ServeHTTP(w, r)
+	})
+}
+
+func saveCrash(v any, stack []byte) {
+	if !telemetryOn() {
+		return
+	}
+	text := anonymizeStack(fmt.Sprintf("panic: %v\n\n%s", v, stack))
+	_ = os.WriteFile(filepath.Join(cacheDir, crashFile), []byte(text), 0600)
+	go sendPendingCrash() // may not finish if we're about to exit
+}
+
+func sendPendingCrash() {
+	path := filepath.Join(cacheDir, crashFile)
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	telemetryMu.Lock()
+	cfg := telemetryCfg
+	telemetryMu.Unlock()
+	if cfg.Endpoint == "" {
+		return
+	}
+	err = uploadTelemetry(cfg.Endpoint, "crash", crashReport{
+		InstallID: cfg.InstallID,
+		Version:   version,
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+		Stack:     string(b),
+	})
+	if err != nil {
+		log.Printf("telemetry: %v", err)
+		return
+	}
+	_ = os.Remove(path)
+}
+
+// ── GET|PUT /telemetry ────────────────────────────────────────────────────────
+//
+//	GET                                → current settings
+//	PUT {"enabled": bool, "endpoint"}  → opt in / out (kill switch)
+func handleTelemetry(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var in telemetrySettings
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			http.Error(w, "bad JSON: "+err.Error(), 400)
+			return
+		}
+		telemetryMu.Lock()
+		if in.Endpoint != "" {
+			telemetryCfg.Endpoint = in.Endpoint
+		}
+		telemetryCfg.Enabled = in.Enabled
+		if in.Enabled && telemetryCfg.InstallID == "" {
+			telemetryCfg.InstallID = newID()
+		}
+		if !in.Enabled {
+			qoeTTFB, qoeStalls, qoeStallMs = nil, 0, 0
+		}
+		err := saveJSON(telemetryFile, telemetryCfg)
+		telemetryMu.Unlock()
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		if !in.Enabled {
+			_ = os.Remove(filepath.Join(cacheDir, crashFile))
+		}
+		log.Printf("Telemetry enabled=%v", in.Enabled)
+	default:
+		http.Error(w, "GET or PUT only", 405)
+		return
+	}
+	telemetryMu.Lock()
+	cfg := telemetryCfg
+	telemetryMu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(cfg)
+}
@@ -0,0 +1,34 @@
+package main
+
+import "testing"
+
+func TestAnonymizeStack(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"frame", "\t/home/alice/src/roxbox/go_server/session.go:120 +0x1d", "\tsession.go:120 +0x1d"},
+		{"module frame", "\t/home/alice/go/pkg/mod/github.com/anacrolix/torrent@v1.55.0/reader.go:77 +0x2c", "\treader.go:77 +0x2c"},
+		{"kept", "goroutine 7 [running]:\nmain.(*session).prioritise(0xc000123456, {0x1, 0x2})", "goroutine 7 [running]:\nmain.(*session).prioritise(0xc000123456, {0x1, 0x2})"},
+		{"clock", "at 09:17:30 in stream.go:88", "at 09:17:30 in stream.go:88"},
+		{"quoted name", `panic: no file "Show.S01E02.mkv" in torrent`, "panic: no file <redacted> in torrent"},
+		{"URL", "fetch https://tracker.example/announce?passkey=abc failed", "fetch <redacted> failed"},
+		{"infohash", "torrent 0123456789abcdef0123456789abcdef01234567 closed", "torrent <redacted> closed"},
+		{"Unix path", "open /home/alice/Movies/My Film.mkv: permission denied", "open <redacted>: permission denied"},
+		{"home path", "stat ~/Movies/film.mkv: no such file", "stat <redacted>: no such file"},
+		{"Windows path", `open C:\Users\alice\Videos\film.mkv: access denied`, "open <redacted>: access denied"},
+		{"IPv4 with port", "dial tcp 1.2.3.4:6881: i/o timeout", "dial tcp <redacted>: i/o timeout"},
+		{"IPv4", "banned peer 192.168.1.20 for bad data", "banned peer <redacted> for bad data"},
+		{"IPv6 with port", "dial tcp [2001:db8::1]:6881: connection refused", "dial tcp <redacted>: connection refused"},
+		{"IPv6", "peer 2001:db8:0:0:0:0:0:1 left, fe80::1 too", "peer <redacted> left, <redacted> too"},
+		{"loopback IPv6", "listen on ::1 failed", "listen on <redacted> failed"},
+		{"host with port", "dial tcp tracker.example.org:6969: no route to host", "dial tcp <redacted>: no route to host"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := anonymizeStack(tc.in); got != tc.want {
+				t.Errorf("got  %q\nwant %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"time"
+
+	"github.com/anacrolix/torrent"
+
+	"github.com/roxbox/torrent_server/engine"
+)
+
+// ── Startup timing ────────────────────────────────────────────────────────────
+// Milestones of a session's time-to-play, as milliseconds since /add.
+// Once the first byte has been served the breakdown goes to the analytics
+// store; sessions stopped before that are recorded as incomplete.
+
+type StartupTimings struct {
+	AddedAt      int64 `json:"added_at"` // unix ms
+	MetadataMs   int64 `json:"metadata_ms,omitempty"`
+	FirstPieceMs int64 `json:"first_piece_ms,omitempty"`
+	ReadyMs      int64 `json:"ready_ms,omitempty"`
+	FirstByteMs  int64 `json:"first_byte_ms,omitempty"`
+}
+
+type timingRecord struct {
+	InfoHash string `json:"info_hash,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Complete bool   `json:"complete"`
+	StartupTimings
+}
+
+// startTimings resets the milestones for a new session. Caller holds s.mu.
+func (s *session) startTimings() {
+	s.added = time.Now()
+	s.timingsRecorded = false
+	s.status.Timings = &StartupTimings{AddedAt: s.added.UnixMilli()}
+}
+
+// markTiming stores the elapsed time for a milestone the first time it's
+// reached: "metadata", "first_piece", "ready" or "first_byte".
+func (s *session) markTiming(stage string) {
+	s.mu.Lock()
+	tm := s.status.Timings
+	if tm == nil {
+		s.mu.Unlock()
+		return
+	}
+	ms := time.Since(s.added).Milliseconds()
+	var slot *int64
+	switch stage {
+	case "metadata":
+		slot = &tm.MetadataMs
+	case "first_piece":
+		slot = &tm.FirstPieceMs
+	case "ready":
+		slot = &tm.ReadyMs
+	case "first_byte":
+		slot = &tm.FirstByteMs
+	}
+	if slot == nil || *slot != 0 {
+		s.mu.Unlock()
+		return
+	}
+	*slot = max(ms, 1) // 0 means "not reached"
+	s.touch()
+	var rec *timingRecord
+	if stage == "first_byte" && !s.timingsRecorded {
+		s.timingsRecorded = true
+		rec = s.newTimingRecord(true)
+	}
+	s.mu.Unlock()
+
+	if rec != nil {
+		recordAnalytics("startup", rec)
+		telemetryTTFB(rec.FirstByteMs)
+	}
+}
+
+// newTimingRecord snapshots the current milestones. Caller holds s.mu.
+func (s *session) newTimingRecord(complete bool) *timingRecord {
+	rec := &timingRecord{Complete: complete, StartupTimings: *s.status.Timings}
+	if s.torr != nil {
+		rec.InfoHash = s.torr.InfoHash().HexString()
+		if s.torr.Info() != nil {
+			rec.Name = s.torr.Name()
+		}
+	}
+	return rec
+}
+
+// flushTimings records an unfinished breakdown when a session is stopped
+// before serving anything. Caller holds s.mu.
+func (s *session) flushTimings() {
+	if s.status.Timings == nil || s.timingsRecorded {
+		return
+	}
+	s.timingsRecorded = true
+	rec := s.newTimingRecord(false)
+	go recordAnalytics("startup", rec)
+}
+
+// watchFirstPiece marks "first_piece" once any piece of f is verified.
+func (s *session) watchFirstPiece(t *torrent.Torrent, f *torrent.File) {
+	sub := t.SubscribePieceStateChanges()
+	defer sub.Close()
+	span := engine.PieceRange(f)
+	begin, end := span.Begin, span.End
+	for i := begin; i < end; i++ {
+		if t.PieceState(i).Complete {
+			s.markTiming("first_piece")
+			return
+		}
+	}
+	for {
+		select {
+		case ev := <-sub.Values:
+			if ev.Complete && ev.Index >= begin && ev.Index < end {
+				s.markTiming("first_piece")
+				return
+			}
+		case <-t.Closed():
+			return
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ── API tokens ────────────────────────────────────────────────────────────────
+// Without tokens the API is open to whoever can reach it: loopback, or the
+// LAN with -lan. Once a token exists, requests from other hosts must carry
+// one, as "Authorization: Bearer <token>" or token=<token> in the query
+// (players can't set headers). Requests from this host need none, so the
+// app on the device keeps working.
+//
+// A token's scope is "full" or "read". A read token (the living-room TV, a
+// guest's phone) can watch: status, files and streams of every session,
+// and nothing else. Everything that changes state, and switching files
+// with /stream?file=, answers 403, so a guest device can't stop or replace
+// the session mid-movie. Tokens are managed on /tokens with full access;
+// only their SHA-256 is stored.
+
+const (
+	tokensFile  = "tokens.json"
+	tokenPrefix = "rbx_"
+	scopeFull   = "full"
+	scopeRead   = "read"
+)
+
+type apiToken struct {
+	Name    string    `json:"name"`
+	Scope   string    `json:"scope"`          // "full" | "read"
+	Hash    string    `json:"hash,omitempty"` // hex SHA-256 of the token
+	Created time.Time `json:"created"`
+}
+
+var (
+	tokensMu sync.RWMutex
+	tokens   []apiToken
+)
+
+var tokenNameRe = regexp.MustCompile(`^[A-Za-z0-9 _.-]{1,64}$`)
+
+// readRoutes are what a read token may GET.
+var readRoutes = map[string]bool{
+	"/status": true, "/status/wait": true, "/status/ws": true, "/info": true, "/mediainfo": true,
+	"/files": true, "/files/raw": true, "/tree": true, "/subtitles": true, "/download.zip": true, "/stream": true, "/stream/profiles": true, "/seek/nearest": true,
+	"/torrents": true, "/sessions": true, "/capabilities": true, "/openapi.json": true, "/health": true,
+}
+
+// openRoutes need no token: /health, and what optional subsystems add
+// for other devices that have none (tracker.go, relay.go).
+var openRoutes = map[string]bool{"/health": true}
+
+// openWith are routes a request opens by naming what it is after, in the
+// given query param: a party's ID is the capability to its hosted state
+// (party.go), so member devices need no token.
+var openWith = map[string]string{"/party/state": "party"}
+
+// readTorrentRoutes are the /torrents/{hash}/… ones. Not export.torrent:
+// it carries the owner's passkey (passkeys.go).
+var readTorrentRoutes = map[string]bool{"status": true, "stream": true, "files": true, "files/raw": true, "tree": true, "subtitles": true, "download.zip": true}
+
+func loadTokens() {
+	tokensMu.Lock()
+	defer tokensMu.Unlock()
+	if err := loadJSON(tokensFile, &tokens); err != nil {
+		log.Printf("tokens: %v", err)
+	}
+}
+
+func hashToken(tok string) string {
+	sum := sha256.Sum256([]byte(tok))
+	return hex.EncodeToString(sum[:])
+}
+
+// tokenScope is the scope of tok; "" for no such token.
+func tokenScope(tok string) string {
+	h := hashToken(tok)
+	tokensMu.RLock()
+	defer tokensMu.RUnlock()
+	for _, t := range tokens {
+		if t.Hash == h {
+			return t.Scope
+		}
+	}
+	return ""
+}
+
+// fromThisHost reports whether the request comes from loopback or one of
+// this machine's own addresses (its player following a -lan stream URL).
+func fromThisHost(r *http.Request) bool {
+	host, _, _ := net.SplitHostPort(r.RemoteAddr)
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	if ip.IsLoopback() {
+		return true
+	}
+	addrs, _ := net.InterfaceAddrs()
+	for _, a := range addrs {
+		if ipn, ok := a.(*net.IPNet); ok && ipn.IP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// readAllowed reports whether a read token may make request r.
+func readAllowed(r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	path := r.URL.Path
+	if rest, ok := strings.CutPrefix(path, "/torrents/"); ok {
+		_, sub, _ := strings.Cut(rest, "/")
+		if strings.HasPrefix(sub, "subtitles/") {
+			sub = "subtitles" // subtitles/{index}
+		}
+		if !readTorrentRoutes[sub] {
+			return false
+		}
+		path = "/" + sub
+	} else if strings.HasPrefix(path, "/subtitles/") {
+		path = "/subtitles"
+	} else if !readRoutes[path] {
+		return false
+	}
+	return path != "/stream" || !r.URL.Query().Has("file") // switching files (selectfile.go)
+}
+
+// withTokens enforces the tokens, once there are any.
+func withTokens(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tok, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if q := r.URL.Query(); q.Has("token") {
+			if tok == "" {
+				tok = q.Get("token")
+			}
+			q.Del("token") // kept out of logs and handlers
+			r.URL.RawQuery = q.Encode()
+		}
+		tokensMu.RLock()
+		none := len(tokens) == 0
+		tokensMu.RUnlock()
+		scope := scopeFull
+		switch {
+		case tok != "":
+			if scope = tokenScope(tok); scope == "" {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, "unknown token", 401)
+				return
+			}
+		case none || fromThisHost(r) || openRoutes[r.URL.Path]:
+		case openWith[r.URL.Path] != "" && r.URL.Query().Get(openWith[r.URL.Path]) != "":
+		default:
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "token required", 401)
+			return
+		}
+		if scope == scopeRead && !readAllowed(r) {
+			http.Error(w, "read-only token", 403)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// tokenCreated is the answer to POST /tokens: the only time the token is shown.
+type tokenCreated struct {
+	apiToken
+	Token string `json:"token"`
+}
+
+// ── GET | POST | DELETE ?name= /tokens ────────────────────────────────────────
+func handleTokens(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var req struct {
+			Name  string `json:"name"`
+			Scope string `json:"scope"`
+		}
+		if err := json.NewDecoder(io.LimitReader(r.Body, 1<<16)).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON body: "+err.Error(), 400)
+			return
+		}
+		if !tokenNameRe.MatchString(req.Name) {
+			http.Error(w, "name: 1-64 letters, digits, spaces, _ . or -", 400)
+			return
+		}
+		if req.Scope == "" {
+			req.Scope = scopeRead
+		}
+		if req.Scope != scopeFull && req.Scope != scopeRead {
+			http.Error(w, `scope must be "full" or "read"`, 400)
+			return
+		}
+		b := make([]byte, 24)
+		if _, err := rand.Read(b); err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		tok := tokenPrefix + hex.EncodeToString(b)
+		t := apiToken{Name: req.Name, Scope: req.Scope, Hash: hashToken(tok), Created: time.Now()}
+		tokensMu.Lock()
+		for _, o := range tokens {
+			if o.Name == t.Name {
+				tokensMu.Unlock()
+				http.Error(w, "a token with that name exists", 409)
+				return
+			}
+		}
+		next := append(append([]apiToken(nil), tokens...), t)
+		err := saveJSON(tokensFile, next)
+		if err == nil {
+			tokens = next
+		}
+		tokensMu.Unlock()
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		t.Hash = ""
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(tokenCreated{apiToken: t, Token: tok})
+		return
+	case http.MethodDelete:
+		name := r.URL.Query().Get("name")
+		tokensMu.Lock()
+		next := make([]apiToken, 0, len(tokens))
+		for _, t := range tokens {
+			if t.Name != name {
+				next = append(next, t)
+			}
+		}
+		if len(next) == len(tokens) {
+			tokensMu.Unlock()
+			http.Error(w, "no such token", 404)
+			return
+		}
+		err := saveJSON(tokensFile, next)
+		if err == nil {
+			tokens = next
+		}
+		tokensMu.Unlock()
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+	default:
+		http.Error(w, "GET, POST or DELETE only", 405)
+		return
+	}
+	tokensMu.RLock()
+	list := make([]apiToken, len(tokens))
+	for i, t := range tokens {
+		t.Hash = ""
+		list[i] = t
+	}
+	tokensMu.RUnlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].Created.Before(list[j].Created) })
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(list)
+}
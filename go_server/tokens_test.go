@@ -0,0 +1,106 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWithTokens(t *testing.T) {
+	const full, read = tokenPrefix + "full", tokenPrefix + "read"
+	tokensMu.Lock()
+	saved := tokens
+	tokens = []apiToken{{Name: "owner", Scope: scopeFull, Hash: hashToken(full)}, {Name: "tv", Scope: scopeRead, Hash: hashToken(read)}}
+	tokensMu.Unlock()
+	defer func() {
+		tokensMu.Lock()
+		tokens = saved
+		tokensMu.Unlock()
+	}()
+
+	var seenQuery string
+	h := withTokens(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { seenQuery = r.URL.RawQuery }))
+	const remote, local = "192.0.2.7:40000", "127.0.0.1:40000"
+	for _, tc := range []struct {
+		name   string
+		method string
+		target string
+		from   string
+		bearer string
+		want   int
+	}{
+		{"no token from elsewhere", "GET", "/status", remote, "", 401},
+		{"unknown token", "GET", "/status", remote, tokenPrefix + "nope", 401},
+		{"loopback needs none", "POST", "/stop", local, "", 200},
+		{"loopback with a read token is read-only", "POST", "/stop", local, read, 403},
+		{"health is open", "GET", "/health", remote, "", 200},
+		{"party state opens with its ID", "GET", "/party/state?party=abc", remote, "", 200},
+		{"party state without an ID", "GET", "/party/state", remote, "", 401},
+		{"party ID opens nothing else", "GET", "/status?party=abc", remote, "", 401},
+
+		{"full: anything", "POST", "/stop", remote, full, 200},
+		{"full: switch files", "GET", "/stream?file=2", remote, full, 200},
+		{"full: export", "GET", "/torrents/abc/export.torrent", remote, full, 200},
+
+		{"read: status", "GET", "/status", remote, read, 200},
+		{"read: HEAD a stream", "HEAD", "/stream", remote, read, 200},
+		{"read: stream", "GET", "/stream?profile=tv", remote, read, 200},
+		{"read: switch files", "GET", "/stream?file=2", remote, read, 403},
+		{"read: POST a read route", "POST", "/status", remote, read, 403},
+		{"read: DELETE", "DELETE", "/torrents/abc", remote, read, 403},
+		{"read: subtitle by index", "GET", "/subtitles/2", remote, read, 200},
+		{"read: tokens", "GET", "/tokens", remote, read, 403},
+		{"read: queue", "GET", "/queue", remote, read, 403},
+		{"read: torrent status", "GET", "/torrents/abc/status", remote, read, 200},
+		{"read: torrent stream", "GET", "/torrents/abc/stream", remote, read, 200},
+		{"read: torrent switch files", "GET", "/torrents/abc/stream?file=1", remote, read, 403},
+		{"read: torrent subtitle by index", "GET", "/torrents/abc/subtitles/1", remote, read, 200},
+		{"read: torrent files/raw", "GET", "/torrents/abc/files/raw?path=a.mkv", remote, read, 200},
+		{"read: torrent export carries the passkey", "GET", "/torrents/abc/export.torrent", remote, read, 403},
+		{"read: torrent stop", "GET", "/torrents/abc/stop", remote, read, 403},
+		{"read: torrent select", "POST", "/torrents/abc/select?file=1", remote, read, 403},
+		{"read: the torrent list", "GET", "/torrents", remote, read, 200},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(tc.method, tc.target, nil)
+			r.RemoteAddr = tc.from
+			if tc.bearer != "" {
+				r.Header.Set("Authorization", "Bearer "+tc.bearer)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != tc.want {
+				t.Errorf("%s %s: %d, want %d (%s)", tc.method, tc.target, w.Code, tc.want, w.Body)
+			}
+		})
+	}
+
+	t.Run("token in the query", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "/stream?token="+read+"&profile=tv", nil)
+		r.RemoteAddr = remote
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != 200 || seenQuery != "profile=tv" {
+			t.Errorf("%d with query %q, want 200 and the token gone", w.Code, seenQuery)
+		}
+	})
+}
+
+func TestWithTokensNoneYet(t *testing.T) {
+	tokensMu.Lock()
+	saved := tokens
+	tokens = nil
+	tokensMu.Unlock()
+	defer func() {
+		tokensMu.Lock()
+		tokens = saved
+		tokensMu.Unlock()
+	}()
+	r := httptest.NewRequest("POST", "/stop", nil)
+	r.RemoteAddr = "192.0.2.7:40000"
+	w := httptest.NewRecorder()
+	withTokens(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})).ServeHTTP(w, r)
+	if w.Code != 200 {
+		t.Errorf("%d before any token exists, want 200", w.Code)
+	}
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+// ── Multiple torrents per profile ─────────────────────────────────────────────
+// A profile's primary session is the one /add, /status and /stream work on.
+// POST /torrents adds a torrent in a background session of its own instead,
+// so one title can keep downloading while another streams. Every session of
+// the profile, primary included, is reachable by infohash:
+//
+//	GET  /torrents                    all sessions of the profile
+//	POST /torrents                    add a background session (as /add)
+//	GET  /torrents/{hash}/status      that session's status
+//	GET  /torrents/{hash}/stream      that session's file
+//	POST /torrents/{hash}/stop        stop it (a background session goes away)
+//	POST /torrents/{hash}/peers       dial known peers (peers.go)
+//	GET  /torrents/{hash}/export.torrent  its .torrent, once the metadata is in
+//	GET  /torrents/{hash}/files       every file of it (as /files)
+//	GET  /torrents/{hash}/files/raw   one of them by index (as /files/raw)
+//	GET  /torrents/{hash}/tree        them as a directory tree (as /tree)
+//	GET  /torrents/{hash}/subtitles   its subtitle files (as /subtitles)
+//	GET  /torrents/{hash}/subtitles/{index}  one of them as UTF-8 text
+//	GET  /torrents/{hash}/subtitles/{index}.vtt  one of them as WebVTT
+//	GET  /torrents/{hash}/subtitles/embedded[/{track}[.vtt]]  tracks inside its Matroska video
+//	POST /torrents/{hash}/select      stream another of them (as /select)
+//	GET  /torrents/{hash}/playlist.m3u  its videos as a playlist (as /playlist.m3u)
+//	GET  /torrents/{hash}/download.zip  all its files as one archive (as /download.zip)
+//
+// Background sessions aren't paused or dropped for idleness until their file
+// is complete (idle.go).
+
+// maxBackground bounds a profile's background sessions.
+const maxBackground = 8
+
+type torrentEntry struct {
+	InfoHash string         `json:"info_hash"`
+	Name     string         `json:"name,omitempty"`
+	File     string         `json:"file,omitempty"`
+	Primary  bool           `json:"primary"` // the session /status and /stream serve
+	Status   StatusResponse `json:"status"`
+}
+
+// sessions returns the primary session and then the background ones.
+func (p *profile) sessions() []*session {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := []*session{p.sess}
+	for _, s := range p.background {
+		out = append(out, s)
+	}
+	return out
+}
+
+// sessionByHash finds the session holding the given infohash. A background
+// session wins over the primary one when both hold it.
+func (p *profile) sessionByHash(hash string) *session {
+	hash = strings.ToLower(hash)
+	p.mu.Lock()
+	s := p.background[hash]
+	p.mu.Unlock()
+	if s != nil {
+		return s
+	}
+	if p.sess.snapshotStatus().InfoHash == hash {
+		return p.sess
+	}
+	return nil
+}
+
+// forgetBackground removes a stopped background session.
+func (p *profile) forgetBackground(s *session) {
+	if s.hash == "" {
+		return
+	}
+	p.mu.Lock()
+	if p.background[s.hash] == s {
+		delete(p.background, s.hash)
+	}
+	p.mu.Unlock()
+}
+
+// ── GET | POST /torrents ──────────────────────────────────────────────────────
+func handleTorrents(w http.ResponseWriter, r *http.Request) {
+	p, err := profileFor(r)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		entries := []torrentEntry{}
+		for _, s := range p.sessions() {
+			st := s.snapshotStatus()
+			hash := st.InfoHash
+			if hash == "" {
+				hash = s.hash // a background session that failed keeps its key
+			}
+			if hash == "" {
+				continue // idle primary, or still resolving
+			}
+			e := torrentEntry{InfoHash: hash, Primary: s == p.sess, Status: st}
+			if t, f := s.current(); t != nil {
+				if t.Info() != nil {
+					e.Name = t.Name()
+				}
+				if f != nil {
+					e.File = f.DisplayPath()
+				}
+			}
+			entries = append(entries, e)
+		}
+		sort.SliceStable(entries, func(i, j int) bool { return entries[i].Primary && !entries[j].Primary })
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(entries)
+	case http.MethodPost:
+		addBackground(w, r, p)
+	default:
+		http.Error(w, "GET or POST only", 405)
+	}
+}
+
+// addBackground starts a background session for the request's torrent.
+func addBackground(w http.ResponseWriter, r *http.Request, p *profile) {
+	req, snap, ok := readAddRequest(w, r)
+	if !ok {
+		return
+	}
+	if req.Source != nil {
+		http.Error(w, "background sessions take torrents; url is a video", 400)
+		return
+	}
+	if len(req.Fallbacks) > 0 {
+		http.Error(w, "fallbacks are for /add: a background session is keyed by its infohash", 400)
+		return
+	}
+	sess, id, status, err := p.startBackground(req, snap, requestID(r))
+	if err == errBackgroundFull {
+		http.Error(w, err.Error(), 409)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"info_hash": sess.hash, "stream_url": sess.streamURL(), "status": status, "add_id": id})
+}
+
+var errBackgroundFull = fmt.Errorf("at most %d background torrents; stop one first", maxBackground)
+
+// startBackground starts a background session for req's torrent, or finds
+// the one that has it already (status "exists").
+func (p *profile) startBackground(req addRequest, snap *SwarmSnapshot, requestID string) (sess *session, id uint64, status string, err error) {
+	hash, err := req.infoHash()
+	if err != nil {
+		return nil, 0, "", err
+	}
+
+	p.mu.Lock()
+	sess = p.background[hash]
+	if sess == nil && len(p.background) >= maxBackground {
+		p.mu.Unlock()
+		return nil, 0, "", errBackgroundFull
+	}
+	existing := sess != nil
+	if !existing {
+		if p.background == nil {
+			p.background = map[string]*session{}
+		}
+		sess = newSession(p)
+		sess.hash = hash
+		p.background[hash] = sess
+	}
+	p.mu.Unlock()
+
+	if existing {
+		return sess, sess.snapshotStatus().AddID, "exists", nil
+	}
+	id, ok := sess.start(req.options(requestID), sess.requestAdd(req, snap))
+	return sess, id, addStatus(ok), nil
+}
+
+// stopBackground stops and forgets the background session holding hash.
+func (p *profile) stopBackground(hash, why string) {
+	p.mu.Lock()
+	sess := p.background[hash]
+	p.mu.Unlock()
+	if sess == nil {
+		return
+	}
+	sess.supersede()
+	sess.stop()
+	p.forgetBackground(sess)
+	sess.event("", why)
+}
+
+// ── /torrents/{hash}/status | stream | stop | peers | export.torrent | files | tree | subtitles | select | playlist.m3u | download.zip
+func handleTorrent(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) == 4 && parts[2] == "files" && parts[3] == "raw" {
+		parts = []string{parts[0], parts[1], "files/raw"}
+	}
+	var subtitle string // /torrents/{hash}/subtitles/{index} | embedded[/{track}]
+	if len(parts) >= 4 && parts[2] == "subtitles" {
+		parts, subtitle = parts[:3], strings.Join(parts[3:], "/")
+	}
+	if len(parts) != 3 {
+		http.NotFound(w, r)
+		return
+	}
+	p, err := profileFor(r)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	sess := p.sessionByHash(parts[1])
+	if sess == nil {
+		http.Error(w, "unknown torrent", 404)
+		return
+	}
+	switch parts[2] {
+	case "status":
+		if r.Method != http.MethodGet {
+			http.Error(w, "GET only", 405)
+			return
+		}
+		writeStatus(w, r, sess) // statusdelta.go
+	case "stream":
+		serveStream(w, r, sess)
+	case "peers":
+		handleTorrentPeers(w, r, sess)
+	case "export.torrent":
+		if r.Method != http.MethodGet {
+			http.Error(w, "GET only", 405)
+			return
+		}
+		exportTorrentFile(w, sess)
+	case "files":
+		if r.Method != http.MethodGet {
+			http.Error(w, "GET only", 405)
+			return
+		}
+		writeFiles(w, sess) // files.go
+	case "tree":
+		if r.Method != http.MethodGet {
+			http.Error(w, "GET only", 405)
+			return
+		}
+		writeTree(w, r, sess) // tree.go
+	case "files/raw":
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "GET only", 405)
+			return
+		}
+		serveFileRaw(w, r, sess)
+	case "subtitles":
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "GET only", 405)
+			return
+		}
+		if subtitle != "" {
+			serveSubtitle(w, r, sess, subtitle) // subtitles.go
+		} else {
+			writeSubtitles(w, sess)
+		}
+	case "select":
+		serveSelect(w, r, sess) // selectfile.go
+	case "playlist.m3u":
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "GET only", 405)
+			return
+		}
+		writePlaylist(w, sess) // playlist.go
+	case "download.zip":
+		if r.Method != http.MethodGet {
+			http.Error(w, "GET only", 405)
+			return
+		}
+		serveZip(w, r, sess) // zipstream.go
+	case "stop":
+		if r.Method != http.MethodPost {
+			http.Error(w, "POST only", 405)
+			return
+		}
+		sess.supersede()
+		sess.stop()
+		p.forgetBackground(sess)
+		sess.event(requestID(r), "stopped")
+		w.WriteHeader(200)
+		fmt.Fprint(w, "stopped")
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// exportTorrentFile writes the session's metainfo as a .torrent, so the
+// same content can be added again later without resolving the magnet. The
+// embedded tracker this server adds for LAN peers is left out, and private
+// trackers get their own URL with the current passkey.
+func exportTorrentFile(w http.ResponseWriter, sess *session) {
+	t, _ := sess.current()
+	if t == nil || t.Info() == nil {
+		http.Error(w, "metadata not resolved yet", 409)
+		return
+	}
+	mi := t.Metainfo()
+	own := net.JoinHostPort(advertiseHost(), port)
+	var tiers metainfo.AnnounceList
+	for _, tier := range mi.AnnounceList {
+		var keep []string
+		for _, tr := range tier {
+			tr = publicTrackerURL(tr) // passkeys.go
+			if u, err := url.Parse(tr); err != nil || u.Host != own {
+				keep = append(keep, tr)
+			}
+		}
+		if len(keep) > 0 {
+			tiers = append(tiers, keep)
+		}
+	}
+	mi.AnnounceList = tiers
+	if len(tiers) > 0 {
+		mi.Announce = tiers[0][0]
+	}
+	mi.Comment, mi.CreatedBy = "", "RoxBox "+version
+	w.Header().Set("Content-Type", "application/x-bittorrent")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": t.Name() + ".torrent"}))
+	_ = mi.Write(w)
+}
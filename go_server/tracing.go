@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ── Request tracing ───────────────────────────────────────────────────────────
+// Every request gets an X-Request-ID (the caller's, if it sent a sane one),
+// echoed in the response, in http debug logs and in error bodies for
+// clients that Accept JSON. Each request is recorded as a span in the event
+// log, next to server-side events (stalls, session changes) tagged with the
+// same ID, so a player hiccup can be lined up with what the server did.
+
+const requestIDHeader = "X-Request-ID"
+
+type ctxKey int
+
+const requestIDKey ctxKey = iota
+
+// requestID returns the request's trace ID ("" outside withTracing).
+func requestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey).(string)
+	return id
+}
+
+func newRequestID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// validRequestID accepts caller-supplied IDs that are safe to log.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		if c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// traceWriter records the status and size of a response while keeping the
+// Flusher and Hijacker of the underlying writer reachable.
+type traceWriter struct {
+	http.ResponseWriter
+	r      *http.Request
+	status int
+	bytes  int64
+	jsonEr bool // rewriting a text error as JSON
+}
+
+func (tw *traceWriter) WriteHeader(code int) {
+	if tw.status != 0 {
+		return
+	}
+	tw.status = code
+	h := tw.Header()
+	if code >= 400 && strings.HasPrefix(h.Get("Content-Type"), "text/plain") &&
+		strings.Contains(tw.r.Header.Get("Accept"), "application/json") {
+		tw.jsonEr = true
+		h.Set("Content-Type", "application/json")
+		h.Del("Content-Length")
+		h.Del("X-Content-Type-Options")
+	}
+	tw.ResponseWriter.WriteHeader(code)
+}
+
+func (tw *traceWriter) Write(p []byte) (int, error) {
+	if tw.status == 0 {
+		tw.WriteHeader(200)
+	}
+	if tw.jsonEr {
+		b, _ := json.Marshal(map[string]string{
+			"error":      strings.TrimSpace(string(p)),
+			"request_id": requestID(tw.r),
+		})
+		n, err := tw.ResponseWriter.Write(append(b, '\n'))
+		tw.bytes += int64(n)
+		return len(p), err
+	}
+	n, err := tw.ResponseWriter.Write(p)
+	tw.bytes += int64(n)
+	return n, err
+}
+
+func (tw *traceWriter) Flush() {
+	if fl, ok := tw.ResponseWriter.(http.Flusher); ok {
+		fl.Flush()
+	}
+}
+
+func (tw *traceWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := tw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijack not supported")
+	}
+	tw.status = http.StatusSwitchingProtocols
+	return hj.Hijack()
+}
+
+func (tw *traceWriter) Unwrap() http.ResponseWriter { return tw.ResponseWriter }
+
+// withTracing assigns request IDs and records request spans.
+func withTracing(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		r = r.WithContext(context.WithValue(r.Context(), requestIDKey, id))
+		tw := &traceWriter{ResponseWriter: w, r: r}
+		start := time.Now()
+		defer func() {
+			if tw.status == 0 {
+				tw.status = 200
+			}
+			d := time.Since(start)
+			if tw.status >= 500 {
+				log.Printf("[%s] %s %s → %d (%s)", id, r.Method, r.URL.Path, tw.status, d.Round(time.Millisecond))
+			}
+			if r.URL.Path == "/debug/events" {
+				return // don't flood the log with reads of itself
+			}
+			recordEvent(event{
+				Kind:      "request",
+				RequestID: id,
+				Message:   r.Method + " " + r.URL.Path,
+				Data: map[string]any{
+					"status":      tw.status,
+					"bytes":       tw.bytes,
+					"duration_ms": d.Milliseconds(),
+					"start":       start.UnixMilli(),
+				},
+			})
+		}()
+		h.ServeHTTP(tw, r)
+	})
+}
+
+// ── Event log ─────────────────────────────────────────────────────────────────
+// An in-memory ring of recent events for /debug/events.
+
+const eventLogSize = 2000
+
+type event struct {
+	Seq       uint64         `json:"seq"`
+	Time      time.Time      `json:"time"`
+	Kind      string         `json:"kind"` // "request" | "stall" | "session" | …
+	RequestID string         `json:"request_id,omitempty"`
+	Profile   string         `json:"profile,omitempty"`
+	Message   string         `json:"message,omitempty"`
+	Data      map[string]any `json:"data,omitempty"`
+}
+
+var eventLog struct {
+	sync.Mutex
+	seq  uint64
+	ring []event
+	next int
+}
+
+func recordEvent(e event) {
+	eventLog.Lock()
+	defer eventLog.Unlock()
+	eventLog.seq++
+	e.Seq = eventLog.seq
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	if len(eventLog.ring) < eventLogSize {
+		eventLog.ring = append(eventLog.ring, e)
+		return
+	}
+	eventLog.ring[eventLog.next] = e
+	eventLog.next = (eventLog.next + 1) % eventLogSize
+}
+
+// events returns matching events, oldest first, at most limit of the newest.
+func events(match func(event) bool, limit int) []event {
+	eventLog.Lock()
+	defer eventLog.Unlock()
+	out := []event{}
+	n := len(eventLog.ring)
+	for i := 0; i < n; i++ {
+		e := eventLog.ring[(eventLog.next+i)%n]
+		if match(e) {
+			out = append(out, e)
+		}
+	}
+	if limit > 0 && len(out) > limit {
+		out = out[len(out)-limit:]
+	}
+	return out
+}
+
+// ── GET /debug/events?request_id=&kind=&since=&limit= ─────────────────────────
+func handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "GET only", 405)
+		return
+	}
+	q := r.URL.Query()
+	rid, kind := q.Get("request_id"), q.Get("kind")
+	since, _ := strconv.ParseUint(q.Get("since"), 10, 64)
+	limit, err := strconv.Atoi(q.Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = 200
+	}
+	out := events(func(e event) bool {
+		return e.Seq > since && (rid == "" || e.RequestID == rid) && (kind == "" || e.Kind == kind)
+	}, limit)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(out)
+}
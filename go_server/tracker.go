@@ -0,0 +1,251 @@
+//go:build !minimal && !no_tracker
+
+package main
+
+import (
+	"encoding/binary"
+	"flag"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/bencode"
+
+	"github.com/roxbox/torrent_server/engine"
+)
+
+// ── Embedded tracker ──────────────────────────────────────────────────────────
+// With -tracker, /announce and /scrape serve a minimal HTTP tracker (BEP 3,
+// compact peers per BEP 23, IPv6 per BEP 7) and every torrent we add
+// announces to it. A magnet exported from this device then carries our
+// tracker URL, so another roxbox on the LAN finds us without any external
+// tracker or the public DHT. Run with -lan so the tracker is reachable.
+// Swarms live in memory only.
+
+var trackerFlag = flag.Bool("tracker", false, "serve a BitTorrent tracker at /announce for LAN sharing (use with -lan)")
+
+const (
+	trackerInterval  = 60 * time.Second
+	trackerPeerTTL   = 3 * trackerInterval
+	trackerMaxSwarms = 1000
+	trackerMaxPeers  = 500 // per swarm
+	trackerNumWant   = 50
+)
+
+type trackerPeer struct {
+	ip     net.IP
+	port   int
+	seeder bool
+	seen   time.Time
+}
+
+var trk struct {
+	sync.Mutex
+	swarms map[string]map[string]*trackerPeer // info_hash → peer_id → peer
+}
+
+func init() {
+	// LAN peers announce without tokens; the tracker only hands out peers.
+	openRoutes["/announce"], openRoutes["/scrape"] = true, true
+	registerModule(module{
+		Name: "tracker",
+		Routes: func(mux *http.ServeMux) {
+			mux.HandleFunc("/announce", handleAnnounce) // GET  (BitTorrent HTTP tracker)
+			mux.HandleFunc("/scrape", handleScrape)     // GET
+		},
+		Docs: []apiRoute{
+			{"/announce", []apiOp{{Method: "GET", Summary: "BitTorrent tracker announce (BEP 3); needs -tracker",
+				Params: []apiParam{
+					{Name: "info_hash", Required: true, Desc: "20 raw bytes, URL-encoded"},
+					{Name: "peer_id", Required: true, Desc: "20 raw bytes, URL-encoded"},
+					{Name: "port", Required: true, Type: "integer"},
+					{Name: "left", Type: "integer", Desc: "bytes still missing; 0 marks a seeder"},
+					{Name: "event", Enum: []string{"started", "stopped", "completed"}},
+					{Name: "compact", Type: "integer", Desc: "1 (default) for compact peer lists"},
+					{Name: "numwant", Type: "integer"},
+				},
+				RawResp: "text/plain"}}},
+			{"/scrape", []apiOp{{Method: "GET", Summary: "BitTorrent tracker scrape; needs -tracker",
+				Params:  []apiParam{{Name: "info_hash", Desc: "repeatable; all swarms when absent"}},
+				RawResp: "text/plain"}}},
+		},
+		Added: func(t *torrent.Torrent) {
+			if *trackerFlag && !engine.IsPrivate(t) {
+				t.AddTrackers([][]string{{localTrackerURL()}})
+			}
+		},
+		Probe: func() capability {
+			if !*trackerFlag {
+				return capability{Compiled: true, Detail: "start with -tracker (and -lan)"}
+			}
+			return capability{Compiled: true, Enabled: true, Detail: localTrackerURL()}
+		},
+	})
+}
+
+// localTrackerURL is the announce URL other devices should use.
+func localTrackerURL() string {
+	return "http://" + net.JoinHostPort(advertiseHost(), port) + "/announce"
+}
+
+func writeBencode(w http.ResponseWriter, v any) {
+	b, err := bencode.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	_, _ = w.Write(b)
+}
+
+func trackerFailure(w http.ResponseWriter, reason string) {
+	writeBencode(w, map[string]any{"failure reason": reason})
+}
+
+// ── GET /announce ─────────────────────────────────────────────────────────────
+func handleAnnounce(w http.ResponseWriter, r *http.Request) {
+	if !*trackerFlag {
+		http.Error(w, "tracker disabled (start with -tracker)", 404)
+		return
+	}
+	q := r.URL.Query()
+	ih, peerID := q.Get("info_hash"), q.Get("peer_id")
+	if len(ih) != 20 || len(peerID) != 20 {
+		trackerFailure(w, "info_hash and peer_id must be 20 bytes")
+		return
+	}
+	p, err := strconv.Atoi(q.Get("port"))
+	if err != nil || p <= 0 || p > 65535 {
+		trackerFailure(w, "invalid port")
+		return
+	}
+	host, _, _ := net.SplitHostPort(r.RemoteAddr)
+	ip := net.ParseIP(host)
+	if ip == nil {
+		trackerFailure(w, "unknown peer address")
+		return
+	}
+	numWant, err := strconv.Atoi(q.Get("numwant"))
+	if err != nil || numWant < 0 || numWant > 200 {
+		numWant = trackerNumWant
+	}
+
+	now := time.Now()
+	trk.Lock()
+	if trk.swarms == nil {
+		trk.swarms = map[string]map[string]*trackerPeer{}
+	}
+	swarm := trk.swarms[ih]
+	if swarm == nil {
+		if len(trk.swarms) >= trackerMaxSwarms {
+			trk.Unlock()
+			trackerFailure(w, "tracker full")
+			return
+		}
+		swarm = map[string]*trackerPeer{}
+		trk.swarms[ih] = swarm
+	}
+	expireSwarm(swarm, now)
+	if q.Get("event") == "stopped" {
+		delete(swarm, peerID)
+	} else if swarm[peerID] != nil || len(swarm) < trackerMaxPeers {
+		swarm[peerID] = &trackerPeer{ip: ip, port: p, seeder: q.Get("left") == "0", seen: now}
+	}
+	complete, incomplete := swarmCounts(swarm)
+
+	var compact4, compact6 []byte
+	var list []map[string]any
+	for id, peer := range swarm {
+		if len(list)+len(compact4)/6+len(compact6)/18 >= numWant {
+			break
+		}
+		if id == peerID {
+			continue
+		}
+		switch {
+		case q.Get("compact") == "0":
+			list = append(list, map[string]any{"peer id": id, "ip": peer.ip.String(), "port": peer.port})
+		case peer.ip.To4() != nil:
+			compact4 = append(compact4, peer.ip.To4()...)
+			compact4 = binary.BigEndian.AppendUint16(compact4, uint16(peer.port))
+		default:
+			compact6 = append(compact6, peer.ip.To16()...)
+			compact6 = binary.BigEndian.AppendUint16(compact6, uint16(peer.port))
+		}
+	}
+	if len(swarm) == 0 {
+		delete(trk.swarms, ih)
+	}
+	trk.Unlock()
+
+	resp := map[string]any{
+		"interval":   int(trackerInterval / time.Second),
+		"complete":   complete,
+		"incomplete": incomplete,
+	}
+	if q.Get("compact") == "0" {
+		if list == nil {
+			list = []map[string]any{}
+		}
+		resp["peers"] = list
+	} else {
+		resp["peers"] = string(compact4)
+		if len(compact6) > 0 {
+			resp["peers6"] = string(compact6)
+		}
+	}
+	writeBencode(w, resp)
+}
+
+// ── GET /scrape ───────────────────────────────────────────────────────────────
+func handleScrape(w http.ResponseWriter, r *http.Request) {
+	if !*trackerFlag {
+		http.Error(w, "tracker disabled (start with -tracker)", 404)
+		return
+	}
+	hashes := r.URL.Query()["info_hash"]
+	now := time.Now()
+	files := map[string]any{}
+	trk.Lock()
+	if len(hashes) == 0 {
+		for ih := range trk.swarms {
+			hashes = append(hashes, ih)
+		}
+		sort.Strings(hashes)
+	}
+	for _, ih := range hashes {
+		swarm := trk.swarms[ih]
+		if swarm == nil {
+			continue
+		}
+		expireSwarm(swarm, now)
+		complete, incomplete := swarmCounts(swarm)
+		files[ih] = map[string]any{"complete": complete, "incomplete": incomplete, "downloaded": 0}
+	}
+	trk.Unlock()
+	writeBencode(w, map[string]any{"files": files})
+}
+
+// expireSwarm drops peers that stopped announcing. Caller holds trk.
+func expireSwarm(swarm map[string]*trackerPeer, now time.Time) {
+	for id, p := range swarm {
+		if now.Sub(p.seen) > trackerPeerTTL {
+			delete(swarm, id)
+		}
+	}
+}
+
+func swarmCounts(swarm map[string]*trackerPeer) (complete, incomplete int) {
+	for _, p := range swarm {
+		if p.seeder {
+			complete++
+		} else {
+			incomplete++
+		}
+	}
+	return
+}
@@ -0,0 +1,59 @@
+//go:build tray
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/getlantern/systray"
+)
+
+// System-tray status for desktop builds:
+//
+//	go build -tags tray .
+//
+// (Linux needs the gtk3 and libayatana-appindicator3 dev packages.)
+
+func init() {
+	runTray = func(quit func()) {
+		systray.Run(trayReady, quit)
+	}
+	stopTray = systray.Quit
+}
+
+func trayReady() {
+	systray.SetTitle("RoxBox")
+	systray.SetTooltip("RoxBox torrent streaming")
+	status := systray.AddMenuItem("Idle", "Current stream")
+	status.Disable()
+	url := systray.AddMenuItem("Listening on "+advertiseHost()+":"+port, "")
+	url.Disable()
+	systray.AddSeparator()
+	quitItem := systray.AddMenuItem("Quit", "Stop the server")
+
+	go func() {
+		tick := time.NewTicker(2 * time.Second)
+		defer tick.Stop()
+		for {
+			select {
+			case <-quitItem.ClickedCh:
+				systray.Quit()
+				return
+			case <-tick.C:
+				status.SetTitle(trayStatus())
+			}
+		}
+	}()
+}
+
+func trayStatus() string {
+	st := getProfile(defaultProfile).sess.snapshotStatus()
+	switch st.State {
+	case "ready", "loading":
+		return fmt.Sprintf("%s %.1f%% · %.0f KB/s · %d peers", st.State, st.Progress, st.SpeedKBs, st.Peers)
+	case "error":
+		return "Error: " + st.Error
+	}
+	return "Idle"
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/roxbox/torrent_server/engine"
+)
+
+// ── GET /tree?path=&depth= ────────────────────────────────────────────────────
+// The files of /files as a directory tree, for a folder browser: every
+// directory carries the size, bytes on disk and file count of everything
+// under it. A torrent of thousands of files can be browsed a level at a
+// time: path= answers the subtree of one directory, and depth= stops
+// listing after that many levels (the directories below still come with
+// their totals, but no contents). Entries sort naturally, "Disc 2" before
+// "Disc 10".
+
+// treeDir is a directory of the torrent; the root is the torrent itself.
+type treeDir struct {
+	Name      string      `json:"name"`
+	Path      string      `json:"path"` // "" for the root
+	Size      int64       `json:"size"`
+	Completed int64       `json:"completed"` // bytes on disk
+	Progress  float64     `json:"progress"`  // % of size on disk
+	FileCount int         `json:"file_count"`
+	Truncated bool        `json:"truncated,omitempty"` // past depth: dirs and files left out
+	Dirs      []*treeDir  `json:"dirs"`
+	Files     []fileEntry `json:"files"`
+}
+
+func handleTree(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "GET only", 405)
+		return
+	}
+	if sess := sessionFor(w, r); sess != nil {
+		writeTree(w, r, sess)
+	}
+}
+
+// writeTree answers /tree for sess (/torrents/{hash}/tree too).
+func writeTree(w http.ResponseWriter, r *http.Request, sess *session) {
+	q := r.URL.Query()
+	depth := 0
+	if v := q.Get("depth"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "depth must be a whole number of levels (0 for all)", 400)
+			return
+		}
+		depth = n
+	}
+	sess.mu.RLock()
+	t, f, comps, local := sess.torr, sess.file, sess.companions, sess.local
+	sess.mu.RUnlock()
+	var root *treeDir
+	switch {
+	case local != nil:
+		root = &treeDir{Name: filepath.Base(local.Path)}
+		for _, e := range localFiles(sess, local).Files {
+			root.add(nil, e) // the video and its companions, wherever they are
+		}
+	case t == nil || t.Info() == nil:
+		http.Error(w, "no torrent info yet", 503)
+		return
+	default:
+		roles := fileRoles(f, comps) // files.go
+		root = &treeDir{Name: t.Name()}
+		for _, g := range t.Files() {
+			ro := roles[g]
+			e := newFileEntry(sess, t, g, ro.kind, ro.lang)
+			dirs := strings.Split(e.Path, "/")
+			root.add(dirs[:len(dirs)-1], e)
+		}
+	}
+	if p := strings.Trim(q.Get("path"), "/"); p != "" {
+		if root = root.find(strings.Split(p, "/")); root == nil {
+			http.Error(w, "no such directory", 404)
+			return
+		}
+	}
+	root.finish(depth)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(root)
+}
+
+// add puts e in the directory dirs below d, counting it in every directory
+// on the way.
+func (d *treeDir) add(dirs []string, e fileEntry) {
+	completed := int64(e.Progress / 100 * float64(e.Size))
+	for _, name := range dirs {
+		d.Size += e.Size
+		d.Completed += completed
+		d.FileCount++
+		var sub *treeDir
+		for _, c := range d.Dirs {
+			if c.Name == name {
+				sub = c
+				break
+			}
+		}
+		if sub == nil {
+			sub = &treeDir{Name: name, Path: strings.TrimPrefix(d.Path+"/"+name, "/")}
+			d.Dirs = append(d.Dirs, sub)
+		}
+		d = sub
+	}
+	d.Size += e.Size
+	d.Completed += completed
+	d.FileCount++
+	d.Files = append(d.Files, e)
+}
+
+// find is the directory at dirs below d; nil when there is none.
+func (d *treeDir) find(dirs []string) *treeDir {
+	for _, name := range dirs {
+		var sub *treeDir
+		for _, c := range d.Dirs {
+			if c.Name == name {
+				sub = c
+				break
+			}
+		}
+		if sub == nil {
+			return nil
+		}
+		d = sub
+	}
+	return d
+}
+
+// finish works out progress, sorts the entries and cuts the tree at depth
+// levels below d (0 keeps all).
+func (d *treeDir) finish(depth int) {
+	d.setProgress()
+	for _, c := range d.Dirs {
+		if depth != 1 {
+			c.finish(max(depth-1, 0))
+			continue
+		}
+		c.setProgress()
+		c.Truncated = c.FileCount > 0
+		c.Dirs, c.Files = []*treeDir{}, []fileEntry{}
+	}
+	sort.Slice(d.Dirs, func(i, j int) bool { return engine.NaturalLess(d.Dirs[i].Name, d.Dirs[j].Name) })
+	sort.Slice(d.Files, func(i, j int) bool { return engine.NaturalLess(d.Files[i].Path, d.Files[j].Path) })
+	if d.Dirs == nil {
+		d.Dirs = []*treeDir{}
+	}
+	if d.Files == nil {
+		d.Files = []fileEntry{}
+	}
+}
+
+func (d *treeDir) setProgress() {
+	d.Progress = 100
+	if d.Size > 0 {
+		d.Progress = float64(d.Completed) / float64(d.Size) * 100
+	}
+}
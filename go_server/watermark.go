@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/anacrolix/torrent"
+
+	"github.com/roxbox/torrent_server/engine"
+)
+
+// ── Watermark overlay ─────────────────────────────────────────────────────────
+// With a watermark set on a session, /stream re-encodes the video through
+// ffmpeg with the text burned in (e.g. the viewer's user name), to
+// discourage redistribution of internal videos. This is a deterrent, not
+// DRM: the output is an ordinary non-seekable Matroska stream, and players
+// start elsewhere by requesting /stream?t=<seconds>.
+//
+// ffmpeg reads the torrent file from a private loopback listener, so it can
+// seek in the source (MP4 indexes are often at the end) while the pieces
+// are still downloading. While a watermark is set, /files/raw serves the
+// streamed file watermarked too and /download.zip refuses the torrent.
+// font_file names a font in the fonts directory, never a path.
+
+var (
+	ffmpegFlag = flag.String("ffmpeg", "", "ffmpeg binary for watermarking (default: libffmpeg.so next to the executable, then PATH)")
+	fontsFlag  = flag.String("fonts", "", "directory of fonts a watermark's font_file may name (default <cache>/fonts)")
+)
+
+type watermarkSpec struct {
+	Text     string  `json:"text"`
+	Position string  `json:"position,omitempty"`  // top-left | top-right | bottom-left | bottom-right (default) | center
+	Opacity  float64 `json:"opacity,omitempty"`   // 0–1, default 0.5
+	FontFile string  `json:"font_file,omitempty"` // file name in the fonts directory
+}
+
+// fontPath resolves a font_file in the fonts directory. Only a plain file
+// name that exists there is accepted.
+func fontPath(name string) (string, error) {
+	if name == "" || name != filepath.Base(name) || strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return "", fmt.Errorf("font_file must be a file name in the fonts directory")
+	}
+	dir := *fontsFlag
+	if dir == "" {
+		dir = filepath.Join(cacheDir, "fonts")
+	}
+	p := filepath.Join(dir, name)
+	if st, err := os.Stat(p); err != nil || !st.Mode().IsRegular() {
+		return "", fmt.Errorf("no font %q in %s", name, dir)
+	}
+	return p, nil
+}
+
+var watermarkPositions = map[string]string{
+	"top-left":     "x=20:y=20",
+	"top-right":    "x=w-tw-20:y=20",
+	"bottom-left":  "x=20:y=h-th-20",
+	"bottom-right": "x=w-tw-20:y=h-th-20",
+	"center":       "x=(w-tw)/2:y=(h-th)/2",
+}
+
+// ffmpegPath finds the ffmpeg binary. On Android it has to come from the
+// app's native library dir like the server itself (see native.go).
+func ffmpegPath() (string, error) {
+	if *ffmpegFlag != "" {
+		return *ffmpegFlag, nil
+	}
+	if exe, err := os.Executable(); err == nil {
+		p := filepath.Join(filepath.Dir(exe), "libffmpeg.so")
+		if _, err := os.Stat(p); err == nil {
+			return p, nil
+		}
+	}
+	return exec.LookPath("ffmpeg")
+}
+
+// filterEscape escapes a value for use inside an ffmpeg filter argument.
+func filterEscape(s string) string {
+	s = filepath.ToSlash(s)
+	return strings.NewReplacer(`\`, `\\`, `:`, `\:`, `'`, `\'`, `,`, `\,`).Replace(s)
+}
+
+// drawtext builds the filter; the text goes through textfile so it needs
+// no escaping.
+func (wm watermarkSpec) drawtext(textFile string) string {
+	pos := watermarkPositions[wm.Position]
+	if pos == "" {
+		pos = watermarkPositions["bottom-right"]
+	}
+	opacity := wm.Opacity
+	if opacity <= 0 || opacity > 1 {
+		opacity = 0.5
+	}
+	f := fmt.Sprintf("drawtext=textfile=%s:fontcolor=white@%.2f:fontsize=h/24:borderw=2:bordercolor=black@%.2f:%s",
+		filterEscape(textFile), opacity, opacity, pos)
+	if font, err := fontPath(wm.FontFile); err == nil {
+		f += ":fontfile=" + filterEscape(font)
+	}
+	return f
+}
+
+func (s *session) watermarkSpec() *watermarkSpec {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.watermark
+}
+
+// serveWatermarked streams f re-encoded with the watermark burned in.
+func serveWatermarked(w http.ResponseWriter, r *http.Request, sess *session, f *torrent.File, readahead int64, wm *watermarkSpec) {
+	bin, err := ffmpegPath()
+	if err != nil {
+		http.Error(w, "watermark needs ffmpeg: "+err.Error(), 500)
+		return
+	}
+	start, _ := strconv.ParseFloat(r.URL.Query().Get("t"), 64)
+
+	// Private source for ffmpeg.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	src := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reader := sess.trackReader(engine.NewReader(f, readahead), readahead)
+		defer reader.Close()
+		engine.ServeContent(w, r, f.DisplayPath(), reader)
+	})}
+	go func() { _ = src.Serve(ln) }()
+	defer src.Close()
+
+	textFile, err := os.CreateTemp("", "roxbox-wm-*.txt")
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	defer os.Remove(textFile.Name())
+	_, _ = textFile.WriteString(wm.Text)
+	textFile.Close()
+
+	args := []string{"-hide_banner", "-loglevel", "error", "-nostdin"}
+	if start > 0 {
+		args = append(args, "-ss", strconv.FormatFloat(start, 'f', 3, 64))
+	}
+	args = append(args,
+		"-i", "http://"+ln.Addr().String()+"/source",
+		"-vf", wm.drawtext(textFile.Name()),
+		"-c:v", "libx264", "-preset", "veryfast", "-tune", "zerolatency",
+		"-c:a", "copy", "-c:s", "copy",
+		"-f", "matroska", "pipe:1")
+	cmd := exec.CommandContext(r.Context(), bin, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	if err := cmd.Start(); err != nil {
+		http.Error(w, execError(bin, err).Error(), 500)
+		return
+	}
+	debugf("reader", "watermark %s from %.1fs", f.DisplayPath(), start)
+
+	w.Header().Set("Content-Type", "video/x-matroska")
+	w.Header().Set("Accept-Ranges", "none")
+	w.Header().Set("Cache-Control", "no-cache")
+	_, _ = io.Copy(flushWriter{w}, out)
+	if err := cmd.Wait(); err != nil && r.Context().Err() == nil {
+		log.Printf("watermark: ffmpeg: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+}
+
+// flushWriter pushes each chunk to the client as ffmpeg produces it.
+type flushWriter struct{ w http.ResponseWriter }
+
+func (fw flushWriter) Write(p []byte) (int, error) {
+	n, err := fw.w.Write(p)
+	if fl, ok := fw.w.(http.Flusher); ok {
+		fl.Flush()
+	}
+	return n, err
+}
+
+// ── GET|PUT|DELETE /watermark ─────────────────────────────────────────────────
+// The session's overlay; it stays in place across adds until deleted.
+func handleWatermark(w http.ResponseWriter, r *http.Request) {
+	sess := sessionFor(w, r)
+	if sess == nil {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var in watermarkSpec
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			http.Error(w, "invalid JSON body: "+err.Error(), 400)
+			return
+		}
+		if strings.TrimSpace(in.Text) == "" {
+			http.Error(w, "text required", 400)
+			return
+		}
+		if in.Position != "" && watermarkPositions[in.Position] == "" {
+			http.Error(w, "unknown position", 400)
+			return
+		}
+		if in.FontFile != "" {
+			if _, err := fontPath(in.FontFile); err != nil {
+				http.Error(w, err.Error(), 400)
+				return
+			}
+		}
+		if _, err := ffmpegPath(); err != nil {
+			http.Error(w, "watermark needs ffmpeg: "+err.Error(), 501)
+			return
+		}
+		sess.mu.Lock()
+		sess.watermark = &in
+		sess.mu.Unlock()
+	case http.MethodDelete:
+		sess.mu.Lock()
+		sess.watermark = nil
+		sess.mu.Unlock()
+		w.WriteHeader(204)
+		return
+	default:
+		http.Error(w, "GET, PUT or DELETE only", 405)
+		return
+	}
+	wm := sess.watermarkSpec()
+	if wm == nil {
+		http.Error(w, "no watermark set", 404)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(wm)
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ── Minimal WebSocket server (RFC 6455) ───────────────────────────────────────
+// Just enough for pushing JSON to clients: text frames out, ping/pong and
+// close handled, client data frames discarded. No extensions, no
+// fragmentation on send. Connections that need what the peer says
+// (wsUpgradeMessages, and wsDial for the client side) get its messages,
+// reassembled, from Messages.
+
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const (
+	wsText  = 0x1
+	wsClose = 0x8
+	wsPing  = 0x9
+	wsPong  = 0xA
+)
+
+type wsConn struct {
+	conn   net.Conn
+	br     *bufio.Reader
+	client bool          // we dialled: frames we send are masked
+	msgs   chan []byte   // the peer's messages; nil: discarded
+	max    int           // largest frame or message read
+	idle   time.Duration // the peer is gone after this long silent; 0: never
+	mu     sync.Mutex    // serialises writes
+	closed chan struct{}
+	once   sync.Once
+}
+
+// wsUpgrade completes the handshake, or writes an HTTP error and returns nil.
+func wsUpgrade(w http.ResponseWriter, r *http.Request) *wsConn {
+	conn, br := wsAccept(w, r)
+	if conn == nil {
+		return nil
+	}
+	return newWSConn(conn, br, false, 0, 0)
+}
+
+// wsUpgradeMessages is wsUpgrade for a peer whose messages, up to max
+// bytes, are wanted.
+func wsUpgradeMessages(w http.ResponseWriter, r *http.Request, max int, idle time.Duration) *wsConn {
+	conn, br := wsAccept(w, r)
+	if conn == nil {
+		return nil
+	}
+	return newWSConn(conn, br, false, max, idle)
+}
+
+func newWSConn(conn net.Conn, br *bufio.Reader, client bool, max int, idle time.Duration) *wsConn {
+	c := &wsConn{conn: conn, br: br, client: client, max: wsMaxFrame, idle: idle, closed: make(chan struct{})}
+	if max > 0 {
+		c.max, c.msgs = max, make(chan []byte, 16)
+	}
+	go c.readLoop()
+	return c
+}
+
+func wsAccept(w http.ResponseWriter, r *http.Request) (net.Conn, *bufio.Reader) {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") ||
+		!strings.Contains(strings.ToLower(r.Header.Get("Connection")), "upgrade") {
+		http.Error(w, "WebSocket upgrade required", 426)
+		return nil, nil
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" || r.Header.Get("Sec-WebSocket-Version") != "13" {
+		http.Error(w, "unsupported WebSocket handshake", 400)
+		return nil, nil
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "connection cannot be upgraded", 500)
+		return nil, nil
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return nil, nil
+	}
+	_, _ = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + wsAcceptKey(key) + "\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, nil
+	}
+	return conn, rw.Reader
+}
+
+func wsAcceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// wsDial opens a WebSocket to a ws:// or wss:// URL and reads the server's
+// messages, up to max bytes, from Messages.
+func wsDial(ctx context.Context, rawURL string, max int, idle time.Duration) (*wsConn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	addr := u.Host
+	switch u.Scheme {
+	case "ws":
+		u.Scheme = "http"
+		if u.Port() == "" {
+			addr = net.JoinHostPort(u.Hostname(), "80")
+		}
+	case "wss":
+		u.Scheme = "https"
+		if u.Port() == "" {
+			addr = net.JoinHostPort(u.Hostname(), "443")
+		}
+	default:
+		return nil, fmt.Errorf("websocket: %q is not a ws:// or wss:// URL", rawURL)
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "https" {
+		tc := tls.Client(conn, &tls.Config{ServerName: u.Hostname()})
+		if err := tc.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tc
+	}
+	if dl, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(dl)
+	}
+	var nonce [16]byte
+	_, _ = rand.Read(nonce[:])
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	br := bufio.NewReader(conn)
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		conn.Close()
+		return nil, fmt.Errorf("websocket: %s answered %s", u.Host, resp.Status)
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") != wsAcceptKey(key) {
+		conn.Close()
+		return nil, errors.New("websocket: bad handshake answer")
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return newWSConn(conn, br, true, max, idle), nil
+}
+
+// Closed is closed when the peer goes away or Close is called.
+func (c *wsConn) Closed() <-chan struct{} { return c.closed }
+
+// Messages delivers the peer's text and binary messages, for connections
+// made to read them; it is never closed, so select on Closed too.
+func (c *wsConn) Messages() <-chan []byte { return c.msgs }
+
+// Ping checks that the peer is still there; its pong counts as hearing
+// from it.
+func (c *wsConn) Ping() error { return c.writeFrame(wsPing, nil) }
+
+func (c *wsConn) Close() {
+	c.once.Do(func() {
+		_ = c.writeFrame(wsClose, []byte{0x03, 0xE8}) // 1000 normal closure
+		close(c.closed)
+		c.conn.Close()
+	})
+}
+
+// WriteText sends one text message.
+func (c *wsConn) WriteText(p []byte) error { return c.writeFrame(wsText, p) }
+
+func (c *wsConn) writeFrame(op byte, p []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	hdr := []byte{0x80 | op}
+	var bit byte
+	if c.client {
+		bit = 0x80 // a client masks everything it sends
+	}
+	switch n := len(p); {
+	case n < 126:
+		hdr = append(hdr, bit|byte(n))
+	case n <= 0xFFFF:
+		hdr = append(hdr, bit|126, byte(n>>8), byte(n))
+	default:
+		hdr = append(hdr, bit|127)
+		hdr = binary.BigEndian.AppendUint64(hdr, uint64(n))
+	}
+	if c.client {
+		var mask [4]byte
+		_, _ = rand.Read(mask[:])
+		hdr = append(hdr, mask[:]...)
+		masked := make([]byte, len(p))
+		for i := range p {
+			masked[i] = p[i] ^ mask[i%4]
+		}
+		p = masked
+	}
+	_ = c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	if _, err := c.conn.Write(append(hdr, p...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// readLoop answers pings and notices the close handshake or a dead peer;
+// it hands on messages when they are wanted.
+func (c *wsConn) readLoop() {
+	defer c.Close()
+	var msg []byte
+	for {
+		if c.idle > 0 {
+			_ = c.conn.SetReadDeadline(time.Now().Add(c.idle))
+		}
+		fin, op, payload, err := c.readFrame()
+		if err != nil {
+			return
+		}
+		switch op {
+		case wsPing:
+			_ = c.writeFrame(wsPong, payload)
+		case wsClose:
+			return
+		case wsPong:
+		default: // data: text, binary or a continuation
+			if c.msgs == nil {
+				continue
+			}
+			if msg = append(msg, payload...); len(msg) > c.max {
+				return
+			}
+			if !fin {
+				continue
+			}
+			select {
+			case c.msgs <- msg:
+			case <-c.closed:
+				return
+			}
+			msg = nil
+		}
+	}
+}
+
+const wsMaxFrame = 64 << 10 // clients have nothing big to say
+
+func (c *wsConn) readFrame() (bool, byte, []byte, error) {
+	var h [2]byte
+	if _, err := io.ReadFull(c.br, h[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin := h[0]&0x80 != 0
+	op := h[0] & 0x0F
+	masked := h[1]&0x80 != 0
+	n := uint64(h[1] & 0x7F)
+	switch n {
+	case 126:
+		var b [2]byte
+		if _, err := io.ReadFull(c.br, b[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(b[:]))
+	case 127:
+		var b [8]byte
+		if _, err := io.ReadFull(c.br, b[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(b[:])
+	}
+	if n > uint64(c.max) {
+		return false, 0, nil, errors.New("websocket: frame too large")
+	}
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+	p := make([]byte, n)
+	if _, err := io.ReadFull(c.br, p); err != nil {
+		return false, 0, nil, err
+	}
+	if masked {
+		for i := range p {
+			p[i] ^= mask[i%4]
+		}
+	}
+	return fin, op, p, nil
+}
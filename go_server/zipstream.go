@@ -0,0 +1,98 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/anacrolix/torrent"
+
+	"github.com/roxbox/torrent_server/engine"
+)
+
+// ── GET /download.zip ─────────────────────────────────────────────────────────
+// Every file of the session's torrent as one ZIP archive, built while it is
+// sent, so a finished torrent can be handed to another app in a single
+// request. Files are stored, not compressed (video doesn't shrink and the
+// phone's CPU stays free), in the torrent's order and under its paths. Each
+// file is read through a torrent reader, so an unfinished torrent downloads
+// in archive order and the response waits for pieces as they complete. The
+// archive has no Content-Length: its size is only known once written. A
+// watermarked session (watermark.go) has no archive: it would hold the
+// video without the watermark.
+
+func handleDownloadZip(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "GET only", 405)
+		return
+	}
+	if sess := sessionFor(w, r); sess != nil {
+		serveZip(w, r, sess)
+	}
+}
+
+// serveZip answers /download.zip for sess (/torrents/{hash}/download.zip too).
+func serveZip(w http.ResponseWriter, r *http.Request, sess *session) {
+	sess.mu.RLock()
+	t, local := sess.torr, sess.local
+	sess.mu.RUnlock()
+	if local != nil {
+		http.Error(w, "a local file has no torrent to archive", 409)
+		return
+	}
+	if t == nil || t.Info() == nil {
+		http.Error(w, "no torrent info yet", 503)
+		return
+	}
+	if sess.watermarkSpec() != nil {
+		http.Error(w, "the video is watermarked; stream it instead", 403)
+		return
+	}
+	modified := time.Now()
+	if mi := t.Metainfo(); mi.CreationDate > 0 {
+		modified = time.Unix(mi.CreationDate, 0)
+	}
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": t.Name() + ".zip"}))
+	w.Header().Set("Cache-Control", "no-cache")
+	zw := zip.NewWriter(w)
+	for _, f := range t.Files() {
+		if err := zipFile(r.Context(), zw, f, modified); err != nil {
+			log.Printf("download.zip: %s: %v", t.Name(), err)
+			return // the client sees a truncated archive
+		}
+	}
+	if err := zw.Close(); err != nil {
+		log.Printf("download.zip: %s: %v", t.Name(), err)
+	}
+}
+
+// zipFile stores f in zw, waiting for its pieces until ctx is done.
+func zipFile(ctx context.Context, zw *zip.Writer, f *torrent.File, modified time.Time) error {
+	fw, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     f.Path(),
+		Method:   zip.Store,
+		Modified: modified,
+	})
+	if err != nil {
+		return err
+	}
+	reader := engine.NewReader(f, engine.DefaultReadahead)
+	defer reader.Close()
+	// CopyN: a file's reader may hand out the rest of its last piece.
+	_, err = io.CopyN(fw, ctxReader{ctx, reader}, f.Length())
+	return err
+}
+
+// ctxReader reads from a torrent reader until ctx is done, so a client
+// that goes away doesn't leave a read waiting for a piece.
+type ctxReader struct {
+	ctx context.Context
+	r   torrent.Reader
+}
+
+func (c ctxReader) Read(b []byte) (int, error) { return c.r.ReadContext(c.ctx, b) }